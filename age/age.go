@@ -0,0 +1,211 @@
+// Package age implements the recipient and identity interfaces of the age
+// file encryption library (filippo.io/age) with the keys of the ecies
+// package, so that files can be encrypted with age to keys managed by an
+// existing PKI, including keys held by a KeyProvider.
+//
+// X25519 keys use the native "X25519" stanzas of age, and can be exchanged
+// with the age tool and its "age1" recipients. P-256 keys use the "piv-p256"
+// stanzas of age-plugin-yubikey.
+package age
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	agelib "filippo.io/age"
+	"github.com/foundriesio/go-ecies"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrUnsupportedKey = fmt.Errorf("age: only X25519 and P-256 keys are supported")
+	ErrInvalidStanza  = fmt.Errorf("age: invalid recipient stanza")
+)
+
+const (
+	x25519Type  = "X25519"
+	x25519Label = "age-encryption.org/v1/X25519"
+	p256Type    = "piv-p256"
+	p256Label   = "piv-p256"
+	p256TagSize = 4
+	fileKeySize = 16
+)
+
+// The unpadded base64 encoding of the stanza arguments.
+var b64 = base64.RawStdEncoding.Strict()
+
+// stanzaType returns the type of the stanzas for keys on curve.
+func stanzaType(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case ecies.X25519():
+		return x25519Type, nil
+	case elliptic.P256():
+		return p256Type, nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+// p256Tag identifies the recipient of a piv-p256 stanza: the first bytes of
+// the SHA-256 of its compressed public key.
+func p256Tag(pub []byte) []byte {
+	h := sha256.Sum256(pub)
+	return h[:p256TagSize]
+}
+
+// wrappingKey derives the key wrapping the file key from the shared secret,
+// with the ephemeral and recipient public keys as salt.
+func wrappingKey(label string, shared, eph, pub []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(eph)+len(pub))
+	salt = append(salt, eph...)
+	salt = append(salt, pub...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(label)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Recipient encrypts age file keys to an X25519 or P-256 public key.
+type Recipient struct {
+	pub *ecies.PublicKey
+	typ string
+}
+
+var _ agelib.Recipient = &Recipient{}
+
+// NewRecipient returns the age recipient for pub.
+func NewRecipient(pub *ecies.PublicKey) (*Recipient, error) {
+	typ, err := stanzaType(pub.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{pub: pub, typ: typ}, nil
+}
+
+// Wrap encrypts the file key to the recipient with an ephemeral key.
+func (r *Recipient) Wrap(fileKey []byte) ([]*agelib.Stanza, error) {
+	eph, err := ecies.GenerateKey(rand.Reader, r.pub.Curve, nil)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.GenerateShared(r.pub)
+	if err != nil {
+		return nil, err
+	}
+	ephBytes := ecies.CompressPublicKey(&eph.PublicKey)
+	pubBytes := ecies.CompressPublicKey(r.pub)
+
+	s := &agelib.Stanza{Type: r.typ}
+	label := x25519Label
+	if r.typ == p256Type {
+		label = p256Label
+		s.Args = append(s.Args, b64.EncodeToString(p256Tag(pubBytes)))
+	}
+	s.Args = append(s.Args, b64.EncodeToString(ephBytes))
+
+	key, err := wrappingKey(label, shared, ephBytes, pubBytes)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	s.Body = aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil)
+	return []*agelib.Stanza{s}, nil
+}
+
+// Identity decrypts the age file keys encrypted to the public key of a key
+// provider.
+type Identity struct {
+	prv ecies.KeyProvider
+	typ string
+}
+
+var _ agelib.Identity = &Identity{}
+
+// NewIdentity returns the age identity for the key of prv.
+func NewIdentity(prv ecies.KeyProvider) (*Identity, error) {
+	typ, err := stanzaType(prv.Public().Curve)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{prv: prv, typ: typ}, nil
+}
+
+// Unwrap decrypts the file key from the first stanza addressed to the
+// identity. Stanzas of other types, or for other keys, are skipped.
+func (i *Identity) Unwrap(stanzas []*agelib.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		fileKey, err := i.unwrap(s)
+		if errors.Is(err, agelib.ErrIncorrectIdentity) {
+			continue
+		}
+		return fileKey, err
+	}
+	return nil, agelib.ErrIncorrectIdentity
+}
+
+func (i *Identity) unwrap(s *agelib.Stanza) ([]byte, error) {
+	if s.Type != i.typ {
+		return nil, agelib.ErrIncorrectIdentity
+	}
+	pub := i.prv.Public()
+	pubBytes := ecies.CompressPublicKey(pub)
+	label := x25519Label
+	args := s.Args
+	if i.typ == p256Type {
+		label = p256Label
+		if len(args) != 2 {
+			return nil, ErrInvalidStanza
+		}
+		tag, err := b64.DecodeString(args[0])
+		if err != nil || len(tag) != p256TagSize {
+			return nil, ErrInvalidStanza
+		}
+		if subtle.ConstantTimeCompare(tag, p256Tag(pubBytes)) != 1 {
+			return nil, agelib.ErrIncorrectIdentity
+		}
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return nil, ErrInvalidStanza
+	}
+	ephBytes, err := b64.DecodeString(args[0])
+	if err != nil {
+		return nil, ErrInvalidStanza
+	}
+	eph, err := ecies.DecompressPublicKey(pub.Curve, ephBytes)
+	if err != nil {
+		return nil, ErrInvalidStanza
+	}
+	if len(s.Body) != fileKeySize+chacha20poly1305.Overhead {
+		return nil, ErrInvalidStanza
+	}
+	shared, err := i.prv.GenerateShared(eph)
+	if err != nil {
+		return nil, ErrInvalidStanza
+	}
+
+	key, err := wrappingKey(label, shared, ephBytes, pubBytes)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), s.Body, nil)
+	if err != nil {
+		// As in age, a stanza which doesn't decrypt is for another key.
+		return nil, agelib.ErrIncorrectIdentity
+	}
+	return fileKey, nil
+}
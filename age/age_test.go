@@ -0,0 +1,152 @@
+package age
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	agelib "filippo.io/age"
+	"github.com/foundriesio/go-ecies"
+)
+
+// bech32Data returns the data of a Bech32 string, without verifying its
+// checksum.
+func bech32Data(s string) []byte {
+	const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	s = strings.ToLower(s)
+	s = s[strings.LastIndexByte(s, '1')+1 : len(s)-6]
+	var out []byte
+	var acc, bits uint
+	for _, c := range s {
+		acc = acc<<5 | uint(strings.IndexRune(charset, c))
+		if bits += 5; bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	return out
+}
+
+func encrypt(t *testing.T, message []byte, recipients ...agelib.Recipient) []byte {
+	var out bytes.Buffer
+	w, err := agelib.Encrypt(&out, recipients...)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	w.Write(message)
+	if err = w.Close(); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	return out.Bytes()
+}
+
+func decrypt(in []byte, identities ...agelib.Identity) ([]byte, error) {
+	r, err := agelib.Decrypt(bytes.NewReader(in), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Ensure that files round trip with X25519 and P-256 keys, and that other keys
+// are skipped.
+func TestAge(t *testing.T) {
+	message := []byte("Hello, age.")
+	for _, curve := range []elliptic.Curve{ecies.X25519(), elliptic.P256()} {
+		prv, err := ecies.GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		other, err := ecies.GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		r, err := NewRecipient(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		rOther, err := NewRecipient(&other.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		id, err := NewIdentity(prv)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		file := encrypt(t, message, rOther, r)
+		if pt, err := decrypt(file, id); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("age: file not decrypted", err)
+			t.FailNow()
+		}
+		file = encrypt(t, message, rOther)
+		if _, err := decrypt(file, id); err == nil {
+			fmt.Println("age: decrypted a file for another key")
+			t.FailNow()
+		}
+	}
+
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = NewRecipient(&prv.PublicKey); err != ErrUnsupportedKey {
+		fmt.Println("age: accepted a P-384 key")
+		t.FailNow()
+	}
+}
+
+// Ensure that X25519 keys interoperate with the native age keys.
+func TestAgeX25519(t *testing.T) {
+	native, err := agelib.GenerateX25519Identity()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub, err := ecies.DecompressPublicKey(ecies.X25519(), bech32Data(native.Recipient().String()))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv, err := ecies.NewPrivateKey(ecies.X25519(), bech32Data(native.String()))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Equal(ecies.CompressPublicKey(&prv.PublicKey), ecies.CompressPublicKey(pub)) {
+		fmt.Println("age: keys don't match")
+		t.FailNow()
+	}
+	message := []byte("Hello, age.")
+
+	r, err := NewRecipient(pub)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := decrypt(encrypt(t, message, r), native); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("age: file not decrypted by age", err)
+		t.FailNow()
+	}
+	id, err := NewIdentity(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := decrypt(encrypt(t, message, native.Recipient()), id); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("age: age file not decrypted", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,156 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// Compatibility with the ECIES algorithms of the Apple Security framework,
+// eciesEncryptionCofactorX963SHA256AESGCM and its siblings, with which iOS
+// and macOS encrypt to EC keys, including Secure Enclave keys:
+//
+//	z = X coordinate of r·Q
+//	K = X9.63-KDF(z, R), R being the uncompressed ephemeral key
+//	Ke = K[:16], or K[:32] on curves larger than 256 bits
+//	iv = 16 zero bytes, or K[len(Ke):] in the variable IV algorithms
+//	ciphertext = R || AES-GCM(Ke, iv, m) with an empty AAD and a 16-byte tag
+//
+// The cofactor and standard algorithms are the same, as the supported curves
+// have a cofactor of 1.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+)
+
+// appleIVSize is the size of the AES-GCM IV, which Apple doesn't shorten to
+// the usual 12 bytes.
+const appleIVSize = 16
+
+// AppleParams select an ECIES algorithm of the Apple Security framework.
+type AppleParams struct {
+	Hash       func() hash.Hash // hash of the X9.63 KDF
+	VariableIV bool             // derive the IV along with the key, rather than use a zero IV
+}
+
+// The parameters of the eciesEncryptionCofactorX963SHA*AESGCM and
+// eciesEncryptionCofactorVariableIVX963SHA*AESGCM algorithms, and of their
+// eciesEncryptionStandard counterparts.
+var (
+	AppleX963SHA256AESGCM           = &AppleParams{Hash: sha256.New}
+	AppleX963SHA384AESGCM           = &AppleParams{Hash: sha512.New384}
+	AppleX963SHA512AESGCM           = &AppleParams{Hash: sha512.New}
+	AppleVariableIVX963SHA256AESGCM = &AppleParams{Hash: sha256.New, VariableIV: true}
+	AppleVariableIVX963SHA384AESGCM = &AppleParams{Hash: sha512.New384, VariableIV: true}
+	AppleVariableIVX963SHA512AESGCM = &AppleParams{Hash: sha512.New, VariableIV: true}
+)
+
+// keyLen returns the AES key length Apple uses on curve.
+func (params *AppleParams) keyLen(curve elliptic.Curve) int {
+	if curve.Params().BitSize > 256 {
+		return 32
+	}
+	return 16
+}
+
+// Apple only supports the NIST curves.
+func (params *AppleParams) enforce(curve elliptic.Curve) error {
+	switch curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+	default:
+		return ErrInvalidCurve
+	}
+	return enforcePolicy(nil, SuiteApple, curve, params.Hash().Size(), params.keyLen(curve))
+}
+
+// aead derives the AES-GCM instance and IV from the shared secret z and the
+// encoded ephemeral key Rb.
+func (params *AppleParams) aead(curve elliptic.Curve, Rb, z []byte) (cipher.AEAD, []byte, error) {
+	keyLen := params.keyLen(curve)
+	kdLen := keyLen
+	if params.VariableIV {
+		kdLen += appleIVSize
+	}
+	K, err := x963KDF(params.Hash(), z, Rb, kdLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(K[:keyLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, appleIVSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	iv := make([]byte, appleIVSize)
+	if params.VariableIV {
+		iv = K[keyLen:]
+	}
+	return aead, iv, nil
+}
+
+// EncryptApple encrypts a message as SecKeyCreateEncryptedData does with the
+// algorithm of params. If params is nil, AppleX963SHA256AESGCM is used.
+func EncryptApple(rand io.Reader, pub *PublicKey, params *AppleParams, m []byte) (ct []byte, err error) {
+	if params == nil {
+		params = AppleX963SHA256AESGCM
+	}
+	if err = params.enforce(pub.Curve); err != nil {
+		return
+	}
+	z, Rb, err := encapsulate(rand, pub, nil, false)
+	if err != nil {
+		return
+	}
+	aead, iv, err := params.aead(pub.Curve, Rb, z)
+	if err != nil {
+		return
+	}
+	ct = make([]byte, len(Rb), len(Rb)+len(m)+aead.Overhead())
+	copy(ct, Rb)
+	return aead.Seal(ct, iv, m, nil), nil
+}
+
+// DecryptApple decrypts a message encrypted by SecKeyCreateEncryptedData with
+// the algorithm of params. If params is nil, AppleX963SHA256AESGCM is used.
+func DecryptApple(prv KeyProvider, params *AppleParams, c []byte) (m []byte, err error) {
+	if params == nil {
+		params = AppleX963SHA256AESGCM
+	}
+	pub := prv.Public()
+	if err = params.enforce(pub.Curve); err != nil {
+		return
+	}
+	if len(c) == 0 {
+		err = ErrInvalidMessage
+		return
+	}
+	rLen := pointSize(pub.Curve, c[0], UncompressedPointsOnly)
+	if rLen == 0 {
+		err = ErrInvalidPublicKey
+		return
+	} else if len(c) < rLen+aes.BlockSize {
+		err = ErrInvalidMessage
+		return
+	}
+	R, err := parseEncapsulation(pub, c[:rLen], UncompressedPointsOnly)
+	if err != nil {
+		return
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return
+	}
+	aead, iv, err := params.aead(pub.Curve, c[:rLen], z)
+	if err != nil {
+		return
+	}
+	if m, err = aead.Open(nil, iv, c[rLen:], nil); err != nil {
+		err = ErrInvalidMessage
+	}
+	return
+}
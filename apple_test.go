@@ -0,0 +1,153 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// Ensure that messages round trip with every Apple algorithm, and that keys
+// on curves Apple doesn't support are rejected.
+func TestApple(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, params := range []*AppleParams{
+			nil,
+			AppleX963SHA384AESGCM,
+			AppleX963SHA512AESGCM,
+			AppleVariableIVX963SHA256AESGCM,
+			AppleVariableIVX963SHA384AESGCM,
+			AppleVariableIVX963SHA512AESGCM,
+		} {
+			for _, message := range [][]byte{nil, []byte("Hello, world."), make([]byte, 100)} {
+				ct, err := EncryptApple(rand.Reader, &prv.PublicKey, params, message)
+				if err != nil {
+					fmt.Println(err.Error())
+					t.FailNow()
+				}
+				pt, err := DecryptApple(prv, params, ct)
+				if err != nil {
+					fmt.Println(err.Error())
+					t.FailNow()
+				} else if !bytes.Equal(pt, message) {
+					fmt.Println("ecies: plaintext doesn't match message")
+					t.FailNow()
+				}
+				ct[len(ct)-1] ^= 1
+				if _, err := DecryptApple(prv, params, ct); err != ErrInvalidMessage {
+					fmt.Println("ecies: decrypted a tampered message")
+					t.FailNow()
+				}
+			}
+		}
+	}
+
+	prv, err := GenerateKey(rand.Reader, Secp256k1(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptApple(rand.Reader, &prv.PublicKey, nil, []byte("message")); err != ErrInvalidCurve {
+		fmt.Println("ecies: encrypted on secp256k1")
+		t.FailNow()
+	}
+}
+
+// Rebuild the blobs of SecKeyCreateEncryptedData with the standard library,
+// following the description of the algorithms in SecKey.h, and ensure that
+// they are decrypted, for every algorithm on P-256 and P-384: the cofactor
+// and standard algorithms only differ on curves with a cofactor. No blobs
+// from an Apple device or simulator were available: this checks the reading
+// of SecKey.h, not the Security framework itself, whose ciphertexts belong
+// here once one is at hand.
+func TestVectorApple(t *testing.T) {
+	m := []byte("Hello, Secure Enclave.")
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		eph, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		z, err := eph.GenerateShared(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		Rb := elliptic.Marshal(curve, eph.X, eph.Y)
+		// AES-128 on P-256, AES-256 on larger curves.
+		keyLen := 16
+		if curve != elliptic.P256() {
+			keyLen = 32
+		}
+
+		for _, c := range []struct {
+			Params     *AppleParams
+			Hash       func() hash.Hash
+			VariableIV bool
+		}{
+			{AppleX963SHA256AESGCM, sha256.New, false},
+			{AppleX963SHA384AESGCM, sha512.New384, false},
+			{AppleX963SHA512AESGCM, sha512.New, false},
+			{AppleVariableIVX963SHA256AESGCM, sha256.New, true},
+			{AppleVariableIVX963SHA384AESGCM, sha512.New384, true},
+			{AppleVariableIVX963SHA512AESGCM, sha512.New, true},
+		} {
+			// X9.63 KDF with the ephemeral key as SharedInfo, for the
+			// AES key and, with a variable IV, the IV.
+			var K []byte
+			for counter := uint32(1); len(K) < keyLen+16; counter++ {
+				h := c.Hash()
+				h.Write(z)
+				h.Write(binary.BigEndian.AppendUint32(nil, counter))
+				h.Write(Rb)
+				K = h.Sum(K)
+			}
+			iv := make([]byte, 16)
+			if c.VariableIV {
+				iv = K[keyLen : keyLen+16]
+			}
+			block, _ := aes.NewCipher(K[:keyLen])
+			aead, _ := cipher.NewGCMWithNonceSize(block, 16)
+			ct := aead.Seal(append([]byte{}, Rb...), iv, m, nil)
+
+			pt, err := DecryptApple(prv, c.Params, ct)
+			if err != nil {
+				fmt.Println(curve.Params().Name, err.Error())
+				t.FailNow()
+			} else if !bytes.Equal(pt, m) {
+				fmt.Println(curve.Params().Name, "ecies: plaintext doesn't match message")
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure that messages aren't encrypted to points off the curve.
+func TestOffCurveApple(t *testing.T) {
+	pub := offCurveKey(t, elliptic.P256())
+	if _, err := EncryptApple(rand.Reader, pub, nil, []byte("message")); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: encrypted to an off-curve point", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,64 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// ASCII armor for ciphertexts: a PEM block naming the curve and parameters of
+// the recipient, for embedding encrypted blobs in configuration files, email
+// or YAML.
+
+import (
+	"encoding/pem"
+)
+
+var ErrInvalidArmor = newError(KindEncoding, "ecies: invalid armored message")
+
+const armorType = "ECIES MESSAGE"
+
+// Armor encodes a ciphertext for pub as an "ECIES MESSAGE" PEM block. Its
+// "Curve" header names the curve of pub, and its "Params" header the
+// parameters, unless they have no suite identifier.
+func Armor(pub *PublicKey, c []byte) ([]byte, error) {
+	if len(c) == 0 {
+		return nil, ErrInvalidMessage
+	}
+	headers := map[string]string{"Curve": pub.Curve.Params().Name}
+	params := pub.Params
+	if params == nil {
+		params = ParamsFromCurve(pub.Curve)
+	}
+	if params != nil {
+		if name, ok := suiteName(params); ok {
+			headers["Params"] = name
+		}
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: armorType, Headers: headers, Bytes: c}), nil
+}
+
+// Unarmor decodes a ciphertext armored by Armor. The headers, if present,
+// must match the curve and parameters of pub, the recipient key, which is
+// needed to decrypt the ciphertext anyway.
+func Unarmor(pub *PublicKey, in []byte) ([]byte, error) {
+	p, _ := pem.Decode(in)
+	if p == nil || p.Type != armorType || len(p.Bytes) == 0 {
+		return nil, ErrInvalidArmor
+	}
+	if curve, ok := p.Headers["Curve"]; ok && curve != pub.Curve.Params().Name {
+		return nil, ErrInvalidCurve
+	}
+	if name, ok := p.Headers["Params"]; ok {
+		params := pub.Params
+		if params == nil {
+			params = ParamsFromCurve(pub.Curve)
+		}
+		if params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+		if own, ok := suiteName(params); !ok || own != name {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	return p.Bytes, nil
+}
+
+func isArmor(in []byte) bool { return pemType(in) == armorType }
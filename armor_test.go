@@ -0,0 +1,78 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"testing"
+)
+
+// Ensure armored ciphertexts name the curve and parameters of the recipient,
+// round trip, and are rejected for other keys.
+func TestArmor(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	armored, err := Armor(&prv.PublicKey, ct)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	p, _ := pem.Decode(armored)
+	if p == nil || p.Type != "ECIES MESSAGE" || p.Headers["Curve"] != "P-384" ||
+		p.Headers["Params"] != "AES-192-CTR/HMAC-SHA-384" {
+		fmt.Println("ecies: unexpected armor")
+		t.FailNow()
+	}
+	if f, err := DetectFormat(armored); err != nil || f != FormatArmor {
+		fmt.Println("ecies: armored ciphertext not detected", err)
+		t.FailNow()
+	}
+
+	back, err := Unarmor(&prv.PublicKey, armored)
+	if err != nil || !bytes.Equal(back, ct) {
+		fmt.Println("ecies: armored ciphertext does not round trip", err)
+		t.FailNow()
+	}
+	env, err := MigrateCiphertext(&prv.PublicKey, armored)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, env, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: migrated armored ciphertext not decrypted", err)
+		t.FailNow()
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = Unarmor(&other.PublicKey, armored); err != ErrInvalidCurve {
+		fmt.Println("ecies: armor for the wrong curve accepted", err)
+		t.FailNow()
+	}
+	prv.Params = ECIES_AES256_SHA512
+	if _, err = Unarmor(&prv.PublicKey, armored); err != ErrUnsupportedECIESParameters {
+		fmt.Println("ecies: armor for the wrong parameters accepted", err)
+		t.FailNow()
+	}
+	if _, err = Unarmor(&prv.PublicKey, ct); err != ErrInvalidArmor {
+		fmt.Println("ecies: accepted an unarmored ciphertext", err)
+		t.FailNow()
+	}
+}
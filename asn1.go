@@ -1,12 +1,19 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
 package ecies
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
+	"errors"
 	"fmt"
-	"math/big"
+	"strings"
+	"time"
 )
 
 var (
@@ -14,8 +21,6 @@ var (
 	ansiX962Scheme = []int{1, 2, 840, 10045}
 )
 
-var ErrInvalidPrivateKey = fmt.Errorf("ecies: invalid private key")
-
 func doScheme(base, v []int) asn1.ObjectIdentifier {
 	var oidInts asn1.ObjectIdentifier
 	oidInts = append(oidInts, base...)
@@ -30,6 +35,10 @@ var (
 	secgNamedCurveP256 = secgNamedCurve{1, 2, 840, 10045, 3, 1, 7}
 	secgNamedCurveP384 = secgNamedCurve{1, 3, 132, 0, 34}
 	secgNamedCurveP521 = secgNamedCurve{1, 3, 132, 0, 35}
+	// SEC 2 section A.2.1
+	secgNamedCurveSecp256k1 = secgNamedCurve{1, 3, 132, 0, 10}
+	// GM/T 0006
+	secgNamedCurveSM2 = secgNamedCurve{1, 2, 156, 10197, 1, 301}
 )
 
 func (curve secgNamedCurve) Equal(curve2 secgNamedCurve) bool {
@@ -54,8 +63,12 @@ func namedCurveFromOID(curve secgNamedCurve) elliptic.Curve {
 		return elliptic.P384()
 	case curve.Equal(secgNamedCurveP521):
 		return elliptic.P521()
+	case curve.Equal(secgNamedCurveSecp256k1):
+		return Secp256k1()
+	case curve.Equal(secgNamedCurveSM2):
+		return SM2()
 	}
-	return nil
+	return registeredCurveFromOID(curve)
 }
 
 func oidFromNamedCurve(curve elliptic.Curve) (secgNamedCurve, bool) {
@@ -68,9 +81,13 @@ func oidFromNamedCurve(curve elliptic.Curve) (secgNamedCurve, bool) {
 		return secgNamedCurveP384, true
 	case elliptic.P521():
 		return secgNamedCurveP521, true
+	case Secp256k1():
+		return secgNamedCurveSecp256k1, true
+	case SM2():
+		return secgNamedCurveSM2, true
 	}
 
-	return nil, false
+	return registeredOID(curve)
 }
 
 // asnAlgorithmIdentifier represents the ASN.1 structure of the same name.
@@ -111,7 +128,7 @@ type asnPrivateKey struct {
 	Version asnECPrivKeyVer
 	Private []byte
 	Curve   secgNamedCurve `asn1:"optional"`
-	Public  asn1.BitString
+	Public  asn1.BitString `asn1:"optional"`
 }
 
 type asnECDHAlgorithm asnAlgorithmIdentifier
@@ -129,6 +146,11 @@ var (
 	dhSinglePass_stdDH_sha512kdf = asnECDHAlgorithm{
 		Algorithm: doScheme(secgScheme, []int{11, 3}),
 	}
+	// ecdh carries the KDF, and its hash function, as parameters. It is used
+	// for the hash functions without a dhSinglePass identifier.
+	ecdhWithKDF = asnECDHAlgorithm{
+		Algorithm: doScheme(secgScheme, []int{12}),
+	}
 )
 
 func (a asnECDHAlgorithm) Cmp(b asnECDHAlgorithm) bool {
@@ -150,6 +172,24 @@ var asnNISTConcatenationKDF = asnKeyDerivationFunction{
 	Algorithm: doScheme(secgScheme, []int{17, 1}),
 }
 
+var asnX963KDF = asnKeyDerivationFunction{
+	Algorithm: doScheme(secgScheme, []int{17, 0}),
+}
+
+// HKDF identifiers, see RFC 8619 section 2. The hash function also appears
+// in the ECDH algorithm identifier.
+var (
+	hkdfWithSHA256 = asnKeyDerivationFunction{
+		Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 28},
+	}
+	hkdfWithSHA384 = asnKeyDerivationFunction{
+		Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 29},
+	}
+	hkdfWithSHA512 = asnKeyDerivationFunction{
+		Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 30},
+	}
+)
+
 func (a asnKeyDerivationFunction) Cmp(b asnKeyDerivationFunction) bool {
 	if len(a.Algorithm) != len(b.Algorithm) {
 		return false
@@ -180,6 +220,21 @@ var (
 	aes256CTRinECIES = asnSymmetricEncryption{
 		Algorithm: doScheme(secgScheme, []int{21, 2}),
 	}
+	// NIST AES-GCM identifiers, see RFC 5084 section 3.2. With an AEAD, the
+	// MAC algorithm identifier repeats the symmetric one.
+	aes128GCM = asnSymmetricEncryption{
+		Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6},
+	}
+	aes192GCM = asnSymmetricEncryption{
+		Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26},
+	}
+	aes256GCM = asnSymmetricEncryption{
+		Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46},
+	}
+	// id-alg-AEADChaCha20Poly1305, see RFC 8103 section 4.
+	chacha20Poly1305 = asnSymmetricEncryption{
+		Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 18},
+	}
 )
 
 func (a asnSymmetricEncryption) Cmp(b asnSymmetricEncryption) bool {
@@ -200,6 +255,23 @@ var (
 	hmacFull = asnMessageAuthenticationCode{
 		Algorithm: doScheme(secgScheme, []int{22}),
 	}
+	// SEC 1 section C.4 CMAC identifiers.
+	cmacAES128 = asnMessageAuthenticationCode{
+		Algorithm: doScheme(secgScheme, []int{24}),
+	}
+	cmacAES192 = asnMessageAuthenticationCode{
+		Algorithm: doScheme(secgScheme, []int{25}),
+	}
+	cmacAES256 = asnMessageAuthenticationCode{
+		Algorithm: doScheme(secgScheme, []int{26}),
+	}
+	// KMAC identifiers, see RFC 8702 section 2.2.
+	kmacWithSHAKE128 = asnMessageAuthenticationCode{
+		Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 19},
+	}
+	kmacWithSHAKE256 = asnMessageAuthenticationCode{
+		Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 20},
+	}
 )
 
 func (a asnMessageAuthenticationCode) Cmp(b asnMessageAuthenticationCode) bool {
@@ -232,6 +304,11 @@ func marshalSubjectPublicKeyInfo(pub *PublicKey) (subj asnSubjectPublicKeyInfo,
 		return
 	}
 	subj.Supplements.ECDomain = curve
+	if pub.Params != nil && (pub.Params.dem == demXChaCha20Poly1305 || pub.Params.dem == demAESCBC || pub.Params.KDF != nil ||
+		pub.Params.EphemeralInKDF || pub.Params.MACLabelLength) {
+		err = ErrUnsupportedECIESParameters
+		return
+	}
 	if pub.Params != nil {
 		subj.Supplements.ECCAlgorithms.ECDH = paramsToASNECDH(pub.Params)
 		subj.Supplements.ECCAlgorithms.ECIES = paramsToASNECIES(pub.Params)
@@ -253,34 +330,73 @@ func MarshalPublic(pub *PublicKey) ([]byte, error) {
 	return asn1.Marshal(subj)
 }
 
-// Decode a DER-encoded public key.
+// Decode a DER-encoded public key, either in the format written by
+// MarshalPublic or in the standard one written by MarshalPublicPKIX. The point
+// may be in any of the SEC 1 formats.
 func UnmarshalPublic(in []byte) (pub *PublicKey, err error) {
+	return unmarshalPublic(in, AllowAllPoints, false)
+}
+
+// Decode a DER-encoded public key, rejecting points in the hybrid format,
+// inputs over MaxEncodedKeySize, trailing data, and parameters which can't be
+// decoded in full.
+func UnmarshalPublicStrict(in []byte) (pub *PublicKey, err error) {
+	return unmarshalPublic(in, AllowCompressedPoints, true)
+}
+
+func unmarshalPublic(in []byte, policy PointFormatPolicy, strict bool) (pub *PublicKey, err error) {
 	var subj asnSubjectPublicKeyInfo
+	var rest []byte
 
-	if _, err = asn1.Unmarshal(in, &subj); err != nil {
+	if strict {
+		if err = checkEncodingSize(ErrInvalidPublicKey, in); err != nil {
+			return
+		}
+	}
+	if rest, err = asn1.Unmarshal(in, &subj); err != nil {
+		// The algorithm of a standard key is an AlgorithmIdentifier
+		// rather than an OID.
+		if pub, err2 := unmarshalPublicPKIX(in, policy); err2 == nil {
+			return pub, nil
+		}
+		err = asn1Error(ErrInvalidPublicKey, err)
+		return
+	}
+	if strict && len(rest) != 0 {
+		err = asn1Error(ErrInvalidPublicKey, nil)
 		return
 	}
 	if !subj.Algorithm.Equal(idEcPublicKeySupplemented) {
 		err = ErrInvalidPublicKey
 		return
 	}
+	if strict && subj.PublicKey.BitLength != len(subj.PublicKey.Bytes)*8 {
+		err = ErrInvalidPublicKey
+		return
+	}
 	pub = new(PublicKey)
 	pub.Curve = namedCurveFromOID(subj.Supplements.ECDomain)
-	x, y := elliptic.Unmarshal(pub.Curve, subj.PublicKey.Bytes)
+	x, y := unmarshalPoint(pub.Curve, subj.PublicKey.Bytes, policy)
 	if x == nil {
-		err = ErrInvalidPublicKey
-		return
+		return nil, ErrInvalidPublicKey
 	}
 	pub.X = x
 	pub.Y = y
 	pub.Params = new(ECIESParams)
-	asnECIEStoParams(subj.Supplements.ECCAlgorithms.ECIES, pub.Params)
-	asnECDHtoParams(subj.Supplements.ECCAlgorithms.ECDH, pub.Params)
-	if pub.Params == nil {
+	algs := subj.Supplements.ECCAlgorithms
+	if !asnECDHtoParams(algs.ECDH, pub.Params) || !asnECIEStoParams(algs.ECIES, pub.Params) || !pub.Params.complete() {
+		if strict && subj.Supplements.ECCAlgorithms.hasAlgorithms() {
+			return nil, ErrUnsupportedECIESParameters
+		}
+		// Parameters which can't be decoded are replaced by the default
+		// ones of the curve, rather than left unusable.
 		if pub.Params = ParamsFromCurve(pub.Curve); pub.Params == nil {
-			err = ErrInvalidPublicKey
+			return nil, ErrInvalidPublicKey
 		}
 	}
+	if err = ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
 	return
 }
 
@@ -316,11 +432,18 @@ func MarshalPrivate(prv *PrivateKey) ([]byte, error) {
 	return asn1.Marshal(ecprv)
 }
 
-// Decode a private key from a DER-encoded format.
+// Decode a private key from a DER-encoded format, either the one written by
+// MarshalPrivate or the SEC 1 one written by MarshalPrivateSEC1. A missing
+// public key is derived from the scalar, with the default parameters of the
+// curve, while an embedded one which doesn't match the scalar is rejected.
 func UnmarshalPrivate(in []byte) (prv *PrivateKey, err error) {
 	var ecprv asnPrivateKey
 
 	if _, err = asn1.Unmarshal(in, &ecprv); err != nil {
+		if prv, err2 := UnmarshalPrivateSEC1(in); err2 == nil {
+			return prv, nil
+		}
+		err = asn1Error(ErrInvalidPrivateKey, err)
 		return
 	} else if ecprv.Version != asnECPrivKeyVer1 {
 		err = ErrInvalidPrivateKey
@@ -329,31 +452,114 @@ func UnmarshalPrivate(in []byte) (prv *PrivateKey, err error) {
 
 	privateCurve := namedCurveFromOID(ecprv.Curve)
 	if privateCurve == nil {
+		// The public key being optional, SEC 1 keys decode as well, with
+		// their tagged fields skipped.
+		if prv, err2 := UnmarshalPrivateSEC1(in); err2 == nil {
+			return prv, nil
+		}
 		err = ErrInvalidPrivateKey
 		return
 	}
 
-	prv = new(PrivateKey)
-	prv.D = new(big.Int).SetBytes(ecprv.Private)
-
-	if pub, err := UnmarshalPublic(ecprv.Public.Bytes); err != nil {
+	if prv, err = privateFromScalar(privateCurve, ecprv.Private); err != nil {
+		return
+	}
+	// The public key is derived from the scalar when it is missing, and
+	// must match it otherwise.
+	if len(ecprv.Public.Bytes) == 0 {
+		return
+	}
+	pub, err := UnmarshalPublic(ecprv.Public.Bytes)
+	if err != nil {
 		return nil, err
-	} else {
-		prv.PublicKey = *pub
 	}
-
+	if err = checkKeyPair(prv, pub); err != nil {
+		return nil, err
+	}
+	prv.PublicKey.Params = pub.Params
 	return
 }
 
-// Export a public key to PEM format.
-func ExportPublicPEM(pub *PublicKey) (out []byte, err error) {
-	der, err := MarshalPublic(pub)
-	if err != nil {
-		return
+var errKeyPairMismatch = errors.New("public key doesn't match the private scalar")
+
+// privateFromScalar returns the private key for the big-endian scalar d, with
+// the default parameters of the curve.
+func privateFromScalar(curve elliptic.Curve, d []byte) (*PrivateKey, error) {
+	if len(d) > (curve.Params().N.BitLen()+7)/8 {
+		return nil, ErrInvalidPrivateKey
 	}
+	return NewPrivateKey(curve, d)
+}
 
+// checkKeyPair checks that pub is the public key of prv.
+func checkKeyPair(prv *PrivateKey, pub *PublicKey) error {
+	if pub.Curve != prv.Curve {
+		return wrapError(ErrInvalidPrivateKey, ErrInvalidCurve)
+	}
+	if pub.X.Cmp(prv.X) != 0 || pub.Y.Cmp(prv.Y) != 0 {
+		return wrapError(ErrInvalidPrivateKey, errKeyPairMismatch)
+	}
+	return nil
+}
+
+var ErrInvalidKeyMetadata = newError(KindEncoding, "ecies: key metadata can't hold line breaks")
+
+// KeyMetadata is carried in the headers of exported PEM blocks, to track the
+// provenance of a key. Comment and KeyID are header values, on a line of their
+// own: they can't hold line breaks.
+type KeyMetadata struct {
+	Comment string
+	Created time.Time
+	KeyID   string
+}
+
+const (
+	pemHeaderComment = "Comment"
+	pemHeaderCreated = "Created"
+	pemHeaderKeyID   = "Key-ID"
+)
+
+func (meta *KeyMetadata) headers() (map[string]string, error) {
+	if meta == nil {
+		return nil, nil
+	}
+	if strings.ContainsAny(meta.Comment, "\r\n") || strings.ContainsAny(meta.KeyID, "\r\n") {
+		return nil, ErrInvalidKeyMetadata
+	}
+	headers := make(map[string]string)
+	if meta.Comment != "" {
+		headers[pemHeaderComment] = meta.Comment
+	}
+	if !meta.Created.IsZero() {
+		headers[pemHeaderCreated] = meta.Created.UTC().Format(time.RFC3339)
+	}
+	if meta.KeyID != "" {
+		headers[pemHeaderKeyID] = meta.KeyID
+	}
+	return headers, nil
+}
+
+func metadataFromHeaders(headers map[string]string) (*KeyMetadata, error) {
+	meta := &KeyMetadata{
+		Comment: headers[pemHeaderComment],
+		KeyID:   headers[pemHeaderKeyID],
+	}
+	if created, ok := headers[pemHeaderCreated]; ok {
+		t, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return nil, fmt.Errorf("ecies: invalid %s PEM header: %w", pemHeaderCreated, err)
+		}
+		meta.Created = t
+	}
+	return meta, nil
+}
+
+func encodePEM(typ string, der []byte, meta *KeyMetadata) (out []byte, err error) {
 	var block pem.Block
-	block.Type = "ELLIPTIC CURVE PUBLIC KEY"
+	block.Type = typ
+	if block.Headers, err = meta.headers(); err != nil {
+		return
+	}
 	block.Bytes = der
 
 	buf := new(bytes.Buffer)
@@ -366,45 +572,275 @@ func ExportPublicPEM(pub *PublicKey) (out []byte, err error) {
 	return
 }
 
+// Export a public key to PEM format.
+func ExportPublicPEM(pub *PublicKey) (out []byte, err error) {
+	return ExportPublicPEMWithMetadata(pub, nil)
+}
+
+// Export a public key to PEM format, recording meta in the PEM headers.
+func ExportPublicPEMWithMetadata(pub *PublicKey, meta *KeyMetadata) (out []byte, err error) {
+	der, err := MarshalPublic(pub)
+	if err != nil {
+		return
+	}
+	return encodePEM("ELLIPTIC CURVE PUBLIC KEY", der, meta)
+}
+
 // Export a private key to PEM format.
 func ExportPrivatePEM(prv *PrivateKey) (out []byte, err error) {
+	return ExportPrivatePEMWithMetadata(prv, nil)
+}
+
+// Export a private key to PEM format, recording meta in the PEM headers.
+func ExportPrivatePEMWithMetadata(prv *PrivateKey, meta *KeyMetadata) (out []byte, err error) {
 	der, err := MarshalPrivate(prv)
 	if err != nil {
 		return
 	}
+	return encodePEM("ELLIPTIC CURVE PRIVATE KEY", der, meta)
+}
 
-	var block pem.Block
-	block.Type = "ELLIPTIC CURVE PRIVATE KEY"
-	block.Bytes = der
+// Import a PEM-encoded public key. Besides the "ELLIPTIC CURVE PUBLIC KEY"
+// blocks written by ExportPublicPEM, standard "PUBLIC KEY" (PKIX) and
+// "CERTIFICATE" blocks holding an ECDSA key are accepted. Unrelated blocks
+// are skipped, and the first public key found is returned.
+func ImportPublicPEM(in []byte) (pub *PublicKey, err error) {
+	pub, _, err = importPublicPEM(in)
+	return
+}
 
-	buf := new(bytes.Buffer)
-	err = pem.Encode(buf, &block)
+// Import a PEM-encoded public key like ImportPublicPEM, along with the
+// metadata found in the headers of its PEM block.
+func ImportPublicPEMWithMetadata(in []byte) (pub *PublicKey, meta *KeyMetadata, err error) {
+	pub, p, err := importPublicPEM(in)
 	if err != nil {
-		return
-	} else {
-		out = buf.Bytes()
+		return nil, nil, err
 	}
+	meta, err = metadataFromHeaders(p.Headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pub, meta, nil
+}
+
+func importPublicPEM(in []byte) (*PublicKey, *pem.Block, error) {
+	for {
+		var p *pem.Block
+		p, in = pem.Decode(in)
+		if p == nil {
+			return nil, nil, ErrInvalidPublicKey
+		}
+
+		var pub *PublicKey
+		var err error
+		switch p.Type {
+		case "ELLIPTIC CURVE PUBLIC KEY":
+			pub, err = UnmarshalPublic(p.Bytes)
+		case "PUBLIC KEY":
+			pub, err = UnmarshalPublicPKIX(p.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(p.Bytes); err == nil {
+				pub, err = importECDSAPublic(cert.PublicKey)
+			}
+		default:
+			continue
+		}
+		return pub, p, err
+	}
+}
+
+// Import a PEM-encoded private key. Besides the "ELLIPTIC CURVE PRIVATE KEY"
+// blocks written by ExportPrivatePEM, standard "EC PRIVATE KEY" (SEC 1) and
+// "PRIVATE KEY" (PKCS #8) blocks holding an ECDSA key are accepted. Unrelated
+// blocks are skipped, and the first private key found is returned. Encrypted
+// keys are rejected with ErrEncryptedKey: they require a passphrase, see
+// ImportPrivatePEMEncrypted.
+func ImportPrivatePEM(in []byte) (prv *PrivateKey, err error) {
+	prv, _, err = importPrivatePEM(in)
 	return
 }
 
-// Import a PEM-encoded public key.
-func ImportPublicPEM(in []byte) (pub *PublicKey, err error) {
-	p, _ := pem.Decode(in)
-	if p == nil || p.Type != "ELLIPTIC CURVE PUBLIC KEY" {
+// Import a PEM-encoded private key like ImportPrivatePEM, along with the
+// metadata found in the headers of its PEM block.
+func ImportPrivatePEMWithMetadata(in []byte) (prv *PrivateKey, meta *KeyMetadata, err error) {
+	prv, p, err := importPrivatePEM(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err = metadataFromHeaders(p.Headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prv, meta, nil
+}
+
+func importPrivatePEM(in []byte) (*PrivateKey, *pem.Block, error) {
+	for {
+		var p *pem.Block
+		p, in = pem.Decode(in)
+		if p == nil {
+			return nil, nil, ErrInvalidPrivateKey
+		}
+		if !isPrivatePEMType(p.Type) {
+			continue
+		}
+		if isLegacyEncrypted(p) || p.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, nil, ErrEncryptedKey
+		}
+		prv, err := parsePrivatePEMBlock(p)
+		return prv, p, err
+	}
+}
+
+func isPrivatePEMType(typ string) bool {
+	switch typ {
+	case "ELLIPTIC CURVE PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		return true
+	}
+	return false
+}
+
+func parsePrivatePEMBlock(p *pem.Block) (*PrivateKey, error) {
+	switch p.Type {
+	case "EC PRIVATE KEY":
+		return UnmarshalPrivateSEC1(p.Bytes)
+	case "PRIVATE KEY":
+		return UnmarshalPrivatePKCS8(p.Bytes)
+	}
+	return UnmarshalPrivate(p.Bytes)
+}
+
+func importECDSAPublic(key interface{}) (*PublicKey, error) {
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
 		return nil, ErrInvalidPublicKey
 	}
+	pub := ImportECDSAPublic(ecKey)
+	if err := ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
 
-	pub, err = UnmarshalPublic(p.Bytes)
-	return
+var (
+	ErrEncryptedKey         = newError(KindKey, "ecies: private key is encrypted, a passphrase is required")
+	ErrLegacyPEMEncrypted   = newError(KindUnsupported, "ecies: legacy PEM encryption is not enabled")
+	ErrUnsupportedPEMCipher = newError(KindUnsupported, "ecies: unsupported PEM cipher")
+)
+
+// PEMOptions tune the import of PEM-encoded private keys in
+// ImportPrivatePEMWithOptions.
+type PEMOptions struct {
+	// Password decrypts encrypted private keys.
+	Password []byte
+	// AllowLegacyEncryption enables the legacy OpenSSL "Proc-Type: 4,ENCRYPTED"
+	// blocks with a DEK-Info header. Their key derivation is weak, so keys
+	// imported this way should be re-exported in a stronger format.
+	AllowLegacyEncryption bool
 }
 
-// Import a PEM-encoded private key.
-func ImportPrivatePEM(in []byte) (prv *PrivateKey, err error) {
-	p, _ := pem.Decode(in)
-	if p == nil || p.Type != "ELLIPTIC CURVE PRIVATE KEY" {
-		return nil, ErrInvalidPrivateKey
+// Import a PEM-encoded private key like ImportPrivatePEM, decrypting it if
+// needed. If opts is nil, encrypted keys are rejected with ErrEncryptedKey.
+func ImportPrivatePEMWithOptions(in []byte, opts *PEMOptions) (prv *PrivateKey, err error) {
+	if opts == nil {
+		opts = &PEMOptions{}
+	}
+	for {
+		var p *pem.Block
+		p, in = pem.Decode(in)
+		if p == nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		if !isPrivatePEMType(p.Type) {
+			continue
+		}
+		if p.Type == "ENCRYPTED PRIVATE KEY" {
+			if len(opts.Password) == 0 {
+				return nil, ErrEncryptedKey
+			}
+			return decryptPKCS8(p.Bytes, opts.Password)
+		}
+		if isLegacyEncrypted(p) {
+			if !opts.AllowLegacyEncryption {
+				return nil, ErrLegacyPEMEncrypted
+			}
+			if p, err = decryptLegacyPEM(p, opts.Password); err != nil {
+				return nil, err
+			}
+			if prv, err = parsePrivatePEMBlock(p); err != nil {
+				// A wrong password only has about a 1 in 256 chance to
+				// get through the padding check, but may still do so.
+				return nil, ErrInvalidPassword
+			}
+			return prv, nil
+		}
+		return parsePrivatePEMBlock(p)
 	}
+}
 
-	prv, err = UnmarshalPrivate(p.Bytes)
-	return
+func isLegacyEncrypted(p *pem.Block) bool {
+	return strings.Contains(p.Headers["Proc-Type"], "ENCRYPTED")
+}
+
+// asnCiphertext represents the ECIES-Ciphertext-Value structure of SEC 1,
+// appendix C.
+type asnCiphertext struct {
+	EphemeralPublicKey  []byte
+	SymmetricCiphertext []byte
+	MACTag              []byte
+}
+
+// MarshalCiphertextASN1 encodes a raw ciphertext for pub, as returned by
+// Encrypt, as the SEC 1 ECIES-Ciphertext-Value structure: the ephemeral
+// public key, the encrypted message and the tag in distinct DER fields. With
+// an AEAD, the encrypted message carries the nonce and the tag is the AEAD
+// tag. Ciphertexts with a timestamp or validity header are rejected.
+func MarshalCiphertextASN1(pub *PublicKey, c []byte) ([]byte, error) {
+	params := pub.Params
+	if params == nil {
+		params = ParamsFromCurve(pub.Curve)
+	}
+	if params == nil {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	if len(c) == 0 {
+		return nil, ErrInvalidMessage
+	}
+	rLen := pointSize(pub.Curve, c[0], AllowAllPoints)
+	if rLen == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	_, tagLen := params.demOverhead()
+	if len(c) < rLen+tagLen {
+		return nil, ErrInvalidMessage
+	}
+	return asn1.Marshal(asnCiphertext{
+		EphemeralPublicKey:  c[:rLen],
+		SymmetricCiphertext: c[rLen : len(c)-tagLen],
+		MACTag:              c[len(c)-tagLen:],
+	})
+}
+
+// UnmarshalCiphertextASN1 decodes a SEC 1 ECIES-Ciphertext-Value structure
+// into the raw ciphertext expected by Decrypt. The sizes of the fields are
+// not checked against any key.
+func UnmarshalCiphertextASN1(der []byte) ([]byte, error) {
+	var ct asnCiphertext
+	if rest, err := asn1.Unmarshal(der, &ct); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidMessage, err)
+	}
+	if len(ct.EphemeralPublicKey) == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	c := make([]byte, 0, len(ct.EphemeralPublicKey)+len(ct.SymmetricCiphertext)+len(ct.MACTag))
+	c = append(c, ct.EphemeralPublicKey...)
+	c = append(c, ct.SymmetricCiphertext...)
+	return append(c, ct.MACTag...), nil
+}
+
+func isASN1Ciphertext(in []byte) bool {
+	var ct asnCiphertext
+	rest, err := asn1.Unmarshal(in, &ct)
+	return err == nil && len(rest) == 0
 }
@@ -0,0 +1,468 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Tests of the DER and PEM encodings of keys and ciphertexts, which the
+// ecies_tiny profile leaves out.
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Ensure a public key can be successfully marshalled and unmarshalled, and
+// that the decoded key is the same as the original.
+func TestMarshalPublic(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	out, err := MarshalPublic(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	pub, err := UnmarshalPublic(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	if !cmpPublic(prv.PublicKey, *pub) {
+		fmt.Println("ecies: failed to unmarshal public key")
+		t.FailNow()
+	}
+}
+
+// Ensure that a private key can be encoded into DER format, and that
+// the resulting key is properly parsed back into a public key.
+func TestMarshalPrivate(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	out, err := MarshalPrivate(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	dumpEnc(out)
+
+	prv2, err := UnmarshalPrivate(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	if !cmpPrivate(prv, prv2) {
+		fmt.Println("ecdh: private key import failed")
+		t.FailNow()
+	}
+}
+
+// Ensure that keys compare equal to their copies and decoded forms only, and
+// that their fingerprint doesn't depend on the encoding.
+func TestKeyEqualFingerprint(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), Secp256k1(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		other, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublicPKIX(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublicPKIX(der)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pub.Params = nil
+		if !prv.PublicKey.Equal(pub) || pub.Fingerprint() != prv.PublicKey.Fingerprint() {
+			fmt.Println("ecies: decoded public key differs")
+			t.FailNow()
+		}
+		if prv.PublicKey.Equal(&other.PublicKey) || prv.PublicKey.Fingerprint() == other.PublicKey.Fingerprint() {
+			fmt.Println("ecies: distinct public keys are equal")
+			t.FailNow()
+		}
+		if prv.PublicKey.Equal(prv.PublicKey.ExportECDSA()) {
+			fmt.Println("ecies: public key equal to another type")
+			t.FailNow()
+		}
+
+		copied := &PrivateKey{prv.PublicKey, new(big.Int).Set(prv.D)}
+		if !prv.Equal(copied) || prv.Equal(other) || prv.Equal(prv.ExportECDSA()) {
+			fmt.Println("ecies: private key comparison failed")
+			t.FailNow()
+		}
+		copied.D.Add(copied.D, big.NewInt(1))
+		if prv.Equal(copied) {
+			fmt.Println("ecies: private keys with distinct scalars are equal")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that the public key of a private key is derived from the scalar
+// when missing, and checked against it otherwise.
+func TestUnmarshalPrivatePublicKey(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), Secp256k1()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		other, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ecprv, err := marshalPrivateKey(prv)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		ecprv.Public = asn1.BitString{}
+		der, err := asn1.Marshal(ecprv)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, unmarshal := range []func([]byte) (*PrivateKey, error){UnmarshalPrivate, UnmarshalPrivateStrict} {
+			prv2, err := unmarshal(der)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			} else if !cmpPrivate(prv, prv2) || prv2.Params != ParamsFromCurve(curve) {
+				fmt.Println("ecies: public key not derived from the scalar")
+				t.FailNow()
+			}
+		}
+
+		pub, _ := MarshalPublic(&other.PublicKey)
+		ecprv.Public = asn1.BitString{Bytes: pub, BitLength: len(pub) * 8}
+		if der, err = asn1.Marshal(ecprv); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, unmarshal := range []func([]byte) (*PrivateKey, error){UnmarshalPrivate, UnmarshalPrivateStrict} {
+			if _, err := unmarshal(der); !errors.Is(err, ErrInvalidPrivateKey) || !errors.Is(err, errKeyPairMismatch) {
+				fmt.Println("ecies: accepted a mismatched public key:", err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure that a private key can be successfully encoded to PEM format, and
+// the resulting key is properly parsed back in.
+func TestPrivatePEM(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	out, err := ExportPrivatePEM(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	dumpEnc(out)
+
+	prv2, err := ImportPrivatePEM(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !cmpPrivate(prv, prv2) {
+		fmt.Println("ecdh: import from PEM failed")
+		t.FailNow()
+	}
+}
+
+// Ensure that a public key can be successfully encoded to PEM format, and
+// the resulting key is properly parsed back in.
+func TestPublicPEM(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	out, err := ExportPublicPEM(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	dumpEnc(out)
+
+	pub2, err := ImportPublicPEM(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !cmpPublic(prv.PublicKey, *pub2) {
+		fmt.Println("ecdh: import from PEM failed")
+		t.FailNow()
+	}
+}
+
+// Ensure that the standard PEM block types are accepted on import, and that
+// unrelated blocks are skipped.
+func TestStandardPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv := ImportECDSA(key)
+
+	sec1, _ := x509.MarshalECPrivateKey(key)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	spki, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ecies"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	junk := pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: []byte{1, 2, 3}})
+
+	for _, typ := range []struct {
+		Type  string
+		Bytes []byte
+	}{{"EC PRIVATE KEY", sec1}, {"PRIVATE KEY", pkcs8}} {
+		in := append(junk, pem.EncodeToMemory(&pem.Block{Type: typ.Type, Bytes: typ.Bytes})...)
+		prv2, err := ImportPrivatePEM(in)
+		if err != nil {
+			fmt.Println(typ.Type, err.Error())
+			t.FailNow()
+		} else if !cmpPrivate(prv, prv2) {
+			fmt.Println("ecies: import from", typ.Type, "failed")
+			t.FailNow()
+		}
+	}
+
+	for _, typ := range []struct {
+		Type  string
+		Bytes []byte
+	}{{"PUBLIC KEY", spki}, {"CERTIFICATE", cert}} {
+		in := append(junk, pem.EncodeToMemory(&pem.Block{Type: typ.Type, Bytes: typ.Bytes})...)
+		pub2, err := ImportPublicPEM(in)
+		if err != nil {
+			fmt.Println(typ.Type, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub2) {
+			fmt.Println("ecies: import from", typ.Type, "failed")
+			t.FailNow()
+		}
+	}
+
+	if _, err = ImportPrivatePEM(junk); err != ErrInvalidPrivateKey {
+		fmt.Println("ecies: imported a private key from unrelated blocks")
+		t.FailNow()
+	}
+}
+
+// Ensure that key metadata survives a round trip through the PEM headers.
+func TestPEMMetadata(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	meta := &KeyMetadata{
+		Comment: "device key",
+		Created: time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC),
+		KeyID:   "k1",
+	}
+
+	out, err := ExportPrivatePEMWithMetadata(prv, meta)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Contains(out, []byte("Created: 2022-03-04T05:06:07Z")) {
+		fmt.Println("ecies: missing Created header")
+		t.FailNow()
+	}
+	prv2, meta2, err := ImportPrivatePEMWithMetadata(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !cmpPrivate(prv, prv2) || *meta2 != *meta {
+		fmt.Println("ecies: private key metadata round trip failed")
+		t.FailNow()
+	}
+
+	out, err = ExportPublicPEMWithMetadata(&prv.PublicKey, &KeyMetadata{KeyID: "k1"})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	_, meta2, err = ImportPublicPEMWithMetadata(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if meta2.KeyID != "k1" || meta2.Comment != "" || !meta2.Created.IsZero() {
+		fmt.Println("ecies: public key metadata round trip failed")
+		t.FailNow()
+	}
+
+	out, _ = ExportPublicPEM(&prv.PublicKey)
+	if bytes.Contains(out, []byte(":")) {
+		fmt.Println("ecies: unexpected PEM headers")
+		t.FailNow()
+	}
+
+	// Line breaks would let the values forge headers or end the block.
+	for _, meta := range []*KeyMetadata{
+		{Comment: "device key\nKey-ID: forged"},
+		{KeyID: "k1\r\n-----END ELLIPTIC CURVE PUBLIC KEY-----"},
+	} {
+		if _, err = ExportPublicPEMWithMetadata(&prv.PublicKey, meta); err != ErrInvalidKeyMetadata {
+			fmt.Println("ecies: exported metadata with a line break", err)
+			t.FailNow()
+		}
+		if _, err = ExportPrivatePEMWithMetadata(prv, meta); err != ErrInvalidKeyMetadata {
+			fmt.Println("ecies: exported metadata with a line break", err)
+			t.FailNow()
+		}
+	}
+}
+
+// TestMarshalEncryption validates the encode/decode produces a valid
+// ECIES encryption key.
+func TestMarshalEncryption(t *testing.T) {
+	prv1, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	out, err := MarshalPrivate(prv1)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	prv2, err := UnmarshalPrivate(out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv2.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	pt, err := prv2.Decrypt(rand.Reader, ct, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	if !bytes.Equal(pt, message) {
+		fmt.Println("ecies: plaintext doesn't match message")
+		t.FailNow()
+	}
+
+	_, err = prv1.Decrypt(rand.Reader, ct, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+}
+
+// Ensure that public keys in the hybrid point format are accepted, unless in
+// strict mode.
+func TestHybridPublicKey(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		hybrid := elliptic.Marshal(c, prv.X, prv.Y)
+		hybrid[0] = pointHybridEven | byte(prv.Y.Bit(0))
+		at := bytes.Index(der, elliptic.Marshal(c, prv.X, prv.Y))
+		copy(der[at:], hybrid)
+		if pub, err := UnmarshalPublic(der); err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println(name, "ecies: failed to unmarshal hybrid public key")
+			t.FailNow()
+		}
+		if _, err := UnmarshalPublicStrict(der); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: hybrid public key accepted in strict mode")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that secp256k1 private keys round trip through DER, with the SEC
+// curve OID.
+func TestSecp256k1DER(t *testing.T) {
+	key, err := ecdsa.GenerateKey(Secp256k1(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv := ImportECDSA(key)
+	der, err := MarshalPrivate(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if oid, ok := oidFromNamedCurve(Secp256k1()); !ok || !oid.Equal(secgNamedCurve{1, 3, 132, 0, 10}) {
+		fmt.Println("ecies: unexpected secp256k1 OID")
+		t.FailNow()
+	}
+	prv2, err := UnmarshalPrivate(der)
+	if err != nil || !cmpPrivate(prv, prv2) || prv2.Curve != Secp256k1() {
+		fmt.Println("ecies: secp256k1 private key doesn't match", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,100 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Key attestation, to check that a public key is held in hardware before
+// encrypting to it.
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNoAttestation       = newError(KindUnsupported, "ecies: key provider does not support attestation")
+	ErrInvalidAttestation  = newError(KindAuthentication, "ecies: invalid key attestation")
+	ErrAttestationMismatch = newError(KindKey, "ecies: attestation does not certify the public key")
+)
+
+// Attestation is a statement by a hardware vendor that a key was generated
+// in, and can't leave, a device. Most devices (YubiHSM, PIV tokens, cloud
+// HSMs and TPMs with an attestation CA) issue it as a certificate chain:
+// Certificates holds the certificate for the key first, followed by the
+// intermediates up to, but not including, the vendor root.
+type Attestation struct {
+	// Format names the device or service issuing the attestation.
+	Format       string
+	Certificates []*x509.Certificate
+}
+
+// Attester is implemented by KeyProviders for hardware keys able to attest
+// them.
+type Attester interface {
+	Attest() (*Attestation, error)
+}
+
+// AttestationOptions configure the verification of attestations.
+type AttestationOptions struct {
+	// Roots are the vendor roots trusted to attest keys.
+	Roots *x509.CertPool
+	// CurrentTime, if not zero, replaces the current time to check the
+	// validity of the certificates.
+	CurrentTime time.Time
+	// Check, if set, is called with the verified certificate chain for any
+	// vendor-specific check, e.g. of the firmware version or key policy
+	// extensions.
+	Check func(chain []*x509.Certificate) error
+}
+
+// VerifyAttestation checks that att chains up to the trusted roots and
+// certifies pub.
+func VerifyAttestation(att *Attestation, pub *PublicKey, opts AttestationOptions) error {
+	if att == nil || len(att.Certificates) == 0 || opts.Roots == nil {
+		return ErrInvalidAttestation
+	}
+	leaf := att.Certificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range att.Certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   opts.CurrentTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+
+	certified, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok || certified.Curve != pub.Curve || certified.X.Cmp(pub.X) != 0 || certified.Y.Cmp(pub.Y) != 0 {
+		return ErrAttestationMismatch
+	}
+	if opts.Check != nil {
+		if err = opts.Check(chains[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyProviderAttestation fetches the attestation of a hardware key
+// provider and verifies it against its public key.
+func VerifyProviderAttestation(prv KeyProvider, opts AttestationOptions) (*Attestation, error) {
+	attester, ok := prv.(Attester)
+	if !ok {
+		return nil, ErrNoAttestation
+	}
+	att, err := attester.Attest()
+	if err != nil {
+		return nil, err
+	}
+	if err = VerifyAttestation(att, prv.Public(), opts); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
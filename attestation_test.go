@@ -0,0 +1,105 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type attestedKey struct {
+	*PrivateKey
+	att *Attestation
+}
+
+func (k attestedKey) Attest() (*Attestation, error) {
+	return k.att, nil
+}
+
+// Ensure attestations are verified against the vendor root and the key.
+func TestAttestation(t *testing.T) {
+	root, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vendor root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &root.PublicKey, root)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rootCert, _ := x509.ParseCertificate(der)
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "attested key"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyAgreement,
+	}
+	der, err = x509.CreateCertificate(rand.Reader, leafTmpl, rootCert, prv.ExportECDSA().Public(), root)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	att := &Attestation{Format: "test", Certificates: []*x509.Certificate{leaf}}
+
+	got, err := VerifyProviderAttestation(attestedKey{prv, att}, AttestationOptions{Roots: roots})
+	if err != nil || got != att {
+		fmt.Println("ecies: valid attestation rejected", err)
+		t.FailNow()
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = VerifyProviderAttestation(attestedKey{other, att}, AttestationOptions{Roots: roots}); err != ErrAttestationMismatch {
+		fmt.Println("ecies: attestation accepted for another key", err)
+		t.FailNow()
+	}
+	if _, err = VerifyProviderAttestation(prv, AttestationOptions{Roots: roots}); err != ErrNoAttestation {
+		fmt.Println("ecies: expected no attestation for a software key", err)
+		t.FailNow()
+	}
+	if err = VerifyAttestation(att, &prv.PublicKey, AttestationOptions{Roots: x509.NewCertPool()}); err == nil {
+		fmt.Println("ecies: attestation accepted from an untrusted root")
+		t.FailNow()
+	}
+	if err = VerifyAttestation(att, &prv.PublicKey, AttestationOptions{Roots: roots, CurrentTime: time.Now().Add(2 * time.Hour)}); err == nil {
+		fmt.Println("ecies: expired attestation accepted")
+		t.FailNow()
+	}
+	rejected := fmt.Errorf("firmware too old")
+	check := func([]*x509.Certificate) error { return rejected }
+	if err = VerifyAttestation(att, &prv.PublicKey, AttestationOptions{Roots: roots, Check: check}); err != rejected {
+		fmt.Println("ecies: vendor check not applied", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,53 @@
+package ecies
+
+// Sender authentication, in the one-pass unified model of NIST SP 800-56A
+// (C(1e, 2s)): the static key agreement between the sender and the recipient
+// is appended to the ephemeral one,
+//
+//	Z = ECDH(r, Q) || ECDH(s, Q)
+//
+// and the KDF shared information is prefixed by the encoded public keys of the
+// sender and the recipient, S || Q || s1. Only the holder of the sender's
+// private key, or of the recipient's, can produce a ciphertext which
+// decrypts.
+
+import (
+	"io"
+)
+
+// EncryptAuthenticated encrypts a message like Encrypt, authenticated by the
+// long-term key of the sender, which must be on the curve of pub. The
+// recipient decrypts it with DecryptAuthenticated and the sender's public key.
+func EncryptAuthenticated(rand io.Reader, sender KeyProvider, pub *PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	return EncryptWithOptions(rand, pub, m, s1, s2, &EncryptOptions{Sender: sender})
+}
+
+// DecryptAuthenticated decrypts a message encrypted by EncryptAuthenticated,
+// and fails with ErrInvalidMessage unless it was encrypted by the holder of
+// the private key of sender.
+func DecryptAuthenticated(prv KeyProvider, sender *PublicKey, c, s1, s2 []byte) ([]byte, error) {
+	return DecryptWithOptions(prv, c, s1, s2, &DecryptOptions{Sender: sender})
+}
+
+// authenticate returns the shared secret and shared information of the
+// one-pass unified model from the ephemeral shared secret z, given the static
+// shared secret zs of the sender and recipient keys.
+func authenticate(z, zs, s1 []byte, sender, recipient *PublicKey) ([]byte, []byte) {
+	S := marshalPoint(sender.Curve, sender.X, sender.Y)
+	Q := marshalPoint(recipient.Curve, recipient.X, recipient.Y)
+	info := make([]byte, 0, len(S)+len(Q)+len(s1))
+	info = append(append(append(info, S...), Q...), s1...)
+	return append(append(make([]byte, 0, len(z)+len(zs)), z...), zs...), info
+}
+
+// checkSender ensures the public key of a sender can be used with the key of
+// the recipient.
+func checkSender(sender, recipient *PublicKey) error {
+	if sender.Curve != recipient.Curve {
+		return ErrInvalidCurve
+	}
+	if sender.X == nil || sender.Y == nil || !sender.Curve.IsOnCurve(sender.X, sender.Y) {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure that authenticated messages only decrypt with the public key of
+// their sender, and not as anonymous messages.
+func TestEncryptAuthenticated(t *testing.T) {
+	message := []byte("Hello, Bob. Alice.")
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P521(), X25519()} {
+		alice, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		bob, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		mallory, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		ct, err := EncryptAuthenticated(rand.Reader, alice, &bob.PublicKey, message, []byte("s1"), []byte("s2"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pt, err := DecryptAuthenticated(bob, &alice.PublicKey, ct, []byte("s1"), []byte("s2"))
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: authenticated message not decrypted", err)
+			t.FailNow()
+		}
+		if _, err = DecryptAuthenticated(bob, &mallory.PublicKey, ct, []byte("s1"), []byte("s2")); err != ErrInvalidMessage {
+			fmt.Println("ecies: message decrypted with the wrong sender", err)
+			t.FailNow()
+		}
+		if _, err = Decrypt(bob, ct, []byte("s1"), []byte("s2")); err != ErrInvalidMessage {
+			fmt.Println("ecies: authenticated message decrypted anonymously", err)
+			t.FailNow()
+		}
+
+		ct, err = Encrypt(rand.Reader, &bob.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err = DecryptAuthenticated(bob, &alice.PublicKey, ct, nil, nil); err != ErrInvalidMessage {
+			fmt.Println("ecies: anonymous message decrypted as authenticated", err)
+			t.FailNow()
+		}
+	}
+
+	alice, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	bob, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptAuthenticated(rand.Reader, alice, &bob.PublicKey, message, nil, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: encrypted between keys on different curves", err)
+		t.FailNow()
+	}
+}
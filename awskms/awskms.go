@@ -0,0 +1,164 @@
+// Package awskms implements an ecies.KeyProvider for AWS KMS key agreement
+// keys (ECC_NIST_P256, ECC_NIST_P384 and ECC_NIST_P521), whose shared secrets
+// are computed by the KMS DeriveSharedSecret API. The private key never
+// leaves KMS.
+package awskms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/foundriesio/go-ecies"
+)
+
+var (
+	ErrNotKeyAgreementKey = fmt.Errorf("awskms: not an ECC key agreement key")
+	ErrKeyNotFound        = fmt.Errorf("awskms: key not found")
+	ErrKeyUnavailable     = fmt.Errorf("awskms: key disabled or unavailable")
+)
+
+// API is the subset of the KMS client used by Key, as implemented by
+// *kms.Client.
+type API interface {
+	GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error)
+	DeriveSharedSecret(ctx context.Context, params *kms.DeriveSharedSecretInput, optFns ...func(*kms.Options)) (*kms.DeriveSharedSecretOutput, error)
+}
+
+// Options tune the calls to KMS.
+type Options struct {
+	// GrantTokens are passed along with each request.
+	GrantTokens []string
+	// MaxAttempts overrides the maximum number of attempts of the retryer
+	// of the client, if not zero.
+	MaxAttempts int
+	// Timeout bounds each key agreement made without a context, through
+	// GenerateShared. If zero, there is no bound but that of the client.
+	Timeout time.Duration
+}
+
+// Key is a KeyProvider for a KMS key. It is safe for concurrent use.
+type Key struct {
+	client API
+	keyID  string
+	opts   Options
+	pub    *ecies.PublicKey
+}
+
+// New returns the KMS key with the given ID, alias or ARN, using a client
+// built from cfg, which carries the region, credentials and retryer.
+func New(ctx context.Context, cfg aws.Config, keyID string, opts *Options) (*Key, error) {
+	return NewWithClient(ctx, kms.NewFromConfig(cfg), keyID, opts)
+}
+
+// NewWithClient returns the KMS key with the given ID, alias or ARN, using
+// an existing client.
+func NewWithClient(ctx context.Context, client API, keyID string, opts *Options) (*Key, error) {
+	k := &Key{client: client, keyID: keyID}
+	if opts != nil {
+		k.opts = *opts
+	}
+	rsp, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{
+		KeyId:       aws.String(keyID),
+		GrantTokens: k.opts.GrantTokens,
+	}, k.optFns()...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if rsp.KeyUsage != types.KeyUsageTypeKeyAgreement {
+		return nil, ErrNotKeyAgreementKey
+	}
+	pub, err := x509.ParsePKIXPublicKey(rsp.PublicKey)
+	if err != nil {
+		return nil, ecies.ErrInvalidPublicKey
+	}
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrNotKeyAgreementKey
+	}
+	if k.pub = ecies.ImportECDSAPublic(ecPub); k.pub.Params == nil {
+		return nil, ecies.ErrInvalidCurve
+	}
+	return k, nil
+}
+
+func (k *Key) optFns() []func(*kms.Options) {
+	if k.opts.MaxAttempts == 0 {
+		return nil
+	}
+	return []func(*kms.Options){func(o *kms.Options) {
+		o.RetryMaxAttempts = k.opts.MaxAttempts
+	}}
+}
+
+// Public returns the public key of the KMS key.
+func (k *Key) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+// GenerateShared computes the shared secret with pub in KMS, within the
+// configured timeout.
+func (k *Key) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	ctx := context.Background()
+	if k.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k.opts.Timeout)
+		defer cancel()
+	}
+	return k.GenerateSharedContext(ctx, pub)
+}
+
+// GenerateSharedContext computes the shared secret with pub in KMS, as a
+// request bound to ctx. It makes Key an ecies.ContextKeyProvider.
+func (k *Key) GenerateSharedContext(ctx context.Context, pub *ecies.PublicKey) ([]byte, error) {
+	if pub.Curve != k.pub.Curve {
+		return nil, ecies.ErrInvalidCurve
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub.ExportECDSA())
+	if err != nil {
+		return nil, ecies.ErrInvalidPublicKey
+	}
+	rsp, err := k.client.DeriveSharedSecret(ctx, &kms.DeriveSharedSecretInput{
+		KeyId:                 aws.String(k.keyID),
+		KeyAgreementAlgorithm: types.KeyAgreementAlgorithmSpecEcdh,
+		PublicKey:             der,
+		GrantTokens:           k.opts.GrantTokens,
+	}, k.optFns()...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(rsp.SharedSecret) != (pub.Curve.Params().BitSize+7)/8 {
+		return nil, ecies.ErrSharedKeyIsPointAtInfinity
+	}
+	return rsp.SharedSecret, nil
+}
+
+// mapError maps the KMS exceptions to the errors of this package, keeping
+// the original error in the message. Other errors are returned as is.
+func mapError(err error) error {
+	var (
+		notFound     *types.NotFoundException
+		disabled     *types.DisabledException
+		invalidState *types.KMSInvalidStateException
+		unavailable  *types.KeyUnavailableException
+		invalidUsage *types.InvalidKeyUsageException
+	)
+	var base error
+	switch {
+	case errors.As(err, &notFound):
+		base = ErrKeyNotFound
+	case errors.As(err, &disabled), errors.As(err, &invalidState), errors.As(err, &unavailable):
+		base = ErrKeyUnavailable
+	case errors.As(err, &invalidUsage):
+		base = ErrNotKeyAgreementKey
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", base, err)
+}
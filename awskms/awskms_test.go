@@ -0,0 +1,124 @@
+package awskms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/foundriesio/go-ecies"
+)
+
+// fakeKMS holds a single key agreement key.
+type fakeKMS struct {
+	prv      *ecies.PrivateKey
+	keyID    string
+	usage    types.KeyUsageType
+	disabled bool
+	attempts int
+	tokens   []string
+}
+
+func (f *fakeKMS) apply(optFns []func(*kms.Options)) {
+	var o kms.Options
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	f.attempts = o.RetryMaxAttempts
+}
+
+func (f *fakeKMS) GetPublicKey(ctx context.Context, params *kms.GetPublicKeyInput, optFns ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	f.apply(optFns)
+	if aws.ToString(params.KeyId) != f.keyID {
+		return nil, &types.NotFoundException{Message: aws.String("no such key")}
+	}
+	der, err := x509.MarshalPKIXPublicKey(f.prv.PublicKey.ExportECDSA())
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{KeyId: params.KeyId, KeyUsage: f.usage, PublicKey: der}, nil
+}
+
+func (f *fakeKMS) DeriveSharedSecret(ctx context.Context, params *kms.DeriveSharedSecretInput, optFns ...func(*kms.Options)) (*kms.DeriveSharedSecretOutput, error) {
+	f.apply(optFns)
+	f.tokens = params.GrantTokens
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.disabled {
+		return nil, &types.DisabledException{Message: aws.String("key disabled")}
+	}
+	if params.KeyAgreementAlgorithm != types.KeyAgreementAlgorithmSpecEcdh {
+		return nil, fmt.Errorf("unexpected algorithm %s", params.KeyAgreementAlgorithm)
+	}
+	pub, err := x509.ParsePKIXPublicKey(params.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := f.prv.GenerateShared(ecies.ImportECDSAPublic(pub.(*ecdsa.PublicKey)))
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DeriveSharedSecretOutput{KeyId: params.KeyId, SharedSecret: shared}, nil
+}
+
+// Ensure that messages are decrypted with a KMS key, with the options passed
+// along, and that the KMS errors are mapped.
+func TestKey(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	fake := &fakeKMS{prv: prv, keyID: "alias/device", usage: types.KeyUsageTypeKeyAgreement}
+	opts := &Options{GrantTokens: []string{"token"}, MaxAttempts: 5}
+	key, err := NewWithClient(context.Background(), fake, "alias/device", opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if key.Public().Curve != elliptic.P384() || key.Public().X.Cmp(prv.X) != 0 {
+		fmt.Println("awskms: wrong public key")
+		t.FailNow()
+	}
+
+	ct, err := ecies.Encrypt(rand.Reader, key.Public(), []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	m, err := ecies.DecryptContext(context.Background(), key, ct, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if string(m) != "message" {
+		fmt.Println("awskms: plaintext doesn't match")
+		t.FailNow()
+	}
+	if fake.attempts != 5 || len(fake.tokens) != 1 {
+		fmt.Println("awskms: options not passed along")
+		t.FailNow()
+	}
+
+	fake.disabled = true
+	if _, err = ecies.Decrypt(key, ct, nil, nil); !errors.Is(err, ErrKeyUnavailable) {
+		fmt.Println("awskms: disabled key not reported", err)
+		t.FailNow()
+	}
+	if _, err = NewWithClient(context.Background(), fake, "alias/other", nil); !errors.Is(err, ErrKeyNotFound) {
+		fmt.Println("awskms: missing key not reported", err)
+		t.FailNow()
+	}
+	fake.usage = types.KeyUsageTypeSignVerify
+	if _, err = NewWithClient(context.Background(), fake, "alias/device", nil); err != ErrNotKeyAgreementKey {
+		fmt.Println("awskms: signing key accepted", err)
+		t.FailNow()
+	}
+}
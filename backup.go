@@ -0,0 +1,72 @@
+package ecies
+
+// Human-readable private key backups, e.g. for printing on paper.
+
+import (
+	"crypto/elliptic"
+	"strings"
+)
+
+// Compact one-byte curve identifiers, used where an OID would be too long.
+var curveIDs = map[elliptic.Curve]byte{
+	elliptic.P256(): 1,
+	elliptic.P384(): 2,
+	elliptic.P521(): 3,
+	X25519():        4,
+	Secp256k1():     5,
+	SM2():           6,
+	X448():          7,
+}
+
+func curveFromID(id byte) elliptic.Curve {
+	for curve, cid := range curveIDs {
+		if cid == id {
+			return curve
+		}
+	}
+	return nil
+}
+
+// The human-readable part of the backup strings.
+const backupHRP = "eciessk"
+
+// ExportPrivateBackup encodes a private key as a Bech32m string, which is
+// case-insensitive and checksummed to catch transcription errors. The curve
+// is carried along with the key.
+func ExportPrivateBackup(prv *PrivateKey) (string, error) {
+	id, ok := curveIDs[prv.Curve]
+	if !ok || prv.D == nil {
+		return "", ErrInvalidPrivateKey
+	}
+	d := make([]byte, (prv.Curve.Params().N.BitLen()+7)/8)
+	if prv.D.BitLen() > len(d)*8 {
+		return "", ErrInvalidPrivateKey
+	}
+	data := append([]byte{id}, prv.D.FillBytes(d)...)
+	return bech32Encode(backupHRP, data, bech32mConst), nil
+}
+
+// ImportPrivateBackup decodes a private key exported with ExportPrivateBackup.
+// Whitespace and dashes, which may be used to group characters on paper, are ignored.
+func ImportPrivateBackup(backup string) (*PrivateKey, error) {
+	backup = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, backup)
+	hrp, data, err := bech32Decode(backup, bech32mConst)
+	if err != nil {
+		return nil, err
+	} else if hrp != backupHRP || len(data) < 1 {
+		return nil, ErrInvalidPrivateKey
+	}
+	curve := curveFromID(data[0])
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if len(data)-1 != (curve.Params().N.BitLen()+7)/8 {
+		return nil, ErrInvalidPrivateKey
+	}
+	return NewPrivateKey(curve, data[1:])
+}
@@ -0,0 +1,84 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure the bech32 codec matches the BIP-173 and BIP-350 test vectors.
+func TestBech32(t *testing.T) {
+	for _, c := range []struct {
+		Input   string
+		Variant bech32Variant
+		Valid   bool
+	}{
+		{"A12UEL5L", bech32Const, true},
+		{"a12uel5l", bech32Const, true},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", bech32Const, true},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", bech32Const, true},
+		{"A1LQFN3A", bech32mConst, true},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", bech32mConst, true},
+		{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", bech32mConst, true},
+		{"A12UEL5L", bech32mConst, false},
+		{"A1LQFN3A", bech32Const, false},
+		{"A12uEL5L", bech32Const, false},
+		{"pzry9x0s0muk", bech32Const, false},
+		{"1pzry9x0s0muk", bech32Const, false},
+		{"li1dgmt3", bech32Const, false},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxx", bech32Const, false},
+	} {
+		hrp, data, err := bech32Decode(c.Input, c.Variant)
+		if (err == nil) != c.Valid {
+			fmt.Println("ecies: unexpected bech32 result for", c.Input, err)
+			t.FailNow()
+		}
+		if err == nil && bech32Encode(hrp, data, c.Variant) != strings.ToLower(c.Input) {
+			fmt.Println("ecies: bech32 round trip failed for", c.Input)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that private keys survive a backup round trip, and that
+// transcription errors are detected.
+func TestPrivateBackup(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		backup, err := ExportPrivateBackup(prv)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		grouped := strings.ToUpper(backup[:10]) + "-" + strings.ToUpper(backup[10:20]) + "\n" + strings.ToUpper(backup[20:])
+		for _, in := range []string{backup, grouped} {
+			prv2, err := ImportPrivateBackup(in)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if !cmpPrivate(prv, prv2) {
+				fmt.Println(name, "ecies: restored key doesn't match")
+				t.FailNow()
+			}
+		}
+
+		typo := []byte(backup)
+		if typo[20] == 'q' {
+			typo[20] = 'p'
+		} else {
+			typo[20] = 'q'
+		}
+		if _, err := ImportPrivateBackup(string(typo)); err != ErrInvalidBech32 {
+			fmt.Println(name, "ecies: restored a key with a typo")
+			t.FailNow()
+		}
+	}
+}
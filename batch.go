@@ -0,0 +1,61 @@
+package ecies
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DecryptBatchOptions tune DecryptBatch.
+type DecryptBatchOptions struct {
+	// Options are used to decrypt each ciphertext, as in DecryptWithOptions.
+	Options *DecryptOptions
+	// Parallelism bounds the number of ciphertexts decrypted concurrently.
+	// It defaults to runtime.GOMAXPROCS(0).
+	Parallelism int
+}
+
+// DecryptResult is the outcome of the decryption of one ciphertext of a
+// batch.
+type DecryptResult struct {
+	Message []byte
+	Err     error
+}
+
+// DecryptBatch decrypts several ciphertexts concurrently, and returns the
+// result of each, in the order of cs. The failure of a ciphertext doesn't
+// stop the others. The key provider, and the replay cache of the options if
+// any, are used concurrently, so they must be safe for concurrent use. If
+// opts is nil, the default options are used.
+func DecryptBatch(prv KeyProvider, cs [][]byte, s1, s2 []byte, opts *DecryptBatchOptions) []DecryptResult {
+	if opts == nil {
+		opts = &DecryptBatchOptions{}
+	}
+	workers := opts.Parallelism
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(cs) {
+		workers = len(cs)
+	}
+
+	results := make([]DecryptResult, len(cs))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				j := int(next.Add(1) - 1)
+				if j >= len(cs) {
+					return
+				}
+				m, err := DecryptWithOptions(prv, cs[j], s1, s2, opts.Options)
+				results[j] = DecryptResult{m, err}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,53 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that batches keep the order of their ciphertexts and report the
+// failures of each.
+func TestDecryptBatch(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	cs := make([][]byte, 100)
+	for i := range cs {
+		if cs[i], err = Encrypt(rand.Reader, &prv.PublicKey, []byte(fmt.Sprint("record ", i)), nil, nil); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+	}
+	cs[42][len(cs[42])-1] ^= 1
+	cs[57] = nil
+
+	for _, opts := range []*DecryptBatchOptions{nil, {Parallelism: 1}, {Parallelism: 7, Options: &DecryptOptions{Strict: true}}} {
+		results := DecryptBatch(prv, cs, nil, nil, opts)
+		if len(results) != len(cs) {
+			fmt.Println("ecies: unexpected number of results")
+			t.FailNow()
+		}
+		for i, r := range results {
+			switch i {
+			case 42, 57:
+				if !errors.Is(r.Err, ErrInvalidMessage) || r.Message != nil {
+					fmt.Println("ecies: invalid ciphertext decrypted", i, r.Err)
+					t.FailNow()
+				}
+			default:
+				if r.Err != nil || string(r.Message) != fmt.Sprint("record ", i) {
+					fmt.Println("ecies: unexpected batch result", i, r.Err)
+					t.FailNow()
+				}
+			}
+		}
+	}
+	if results := DecryptBatch(prv, nil, nil, nil, nil); len(results) != 0 {
+		fmt.Println("ecies: results for an empty batch")
+		t.FailNow()
+	}
+}
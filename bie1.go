@@ -0,0 +1,128 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// The BIE1 encryption format of Electrum and the bitcore/bsv libraries, which
+// is an ECIES variant of its own:
+//
+//	S = r·Q, the full ECDH point rather than its X coordinate
+//	iv || Ke || Km = SHA-512(compressed S)
+//	c = AES-128-CBC(Ke, iv, PKCS#7 padded m)
+//	d = HMAC-SHA-256(Km, "BIE1" || compressed R || c)
+//	ciphertext = "BIE1" || compressed R || c || d
+//
+// Electrum exchanges the ciphertexts base64 encoded.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"io"
+)
+
+var bie1Magic = []byte("BIE1")
+
+// EncryptBIE1 encrypts a message to a public key, usually on the secp256k1
+// curve, in the BIE1 format.
+func EncryptBIE1(rand io.Reader, pub *PublicKey, m []byte) (ct []byte, err error) {
+	if err = ValidatePublicKey(pub); err != nil {
+		return
+	}
+	if err = enforceBIE1(pub.Curve); err != nil {
+		return
+	}
+	R, err := GenerateKey(rand, pub.Curve, nil)
+	if err != nil {
+		return
+	}
+	x, y := affineOrNil(pub.Curve.ScalarMult(pub.X, pub.Y, R.D.Bytes()))
+	if x == nil {
+		err = ErrSharedKeyIsPointAtInfinity
+		return
+	}
+	iv, Ke, Km := bie1Keys(elliptic.MarshalCompressed(pub.Curve, x, y))
+
+	em, err := cbcEncrypt(Ke, iv, m)
+	if err != nil {
+		return
+	}
+
+	ct = append(ct, bie1Magic...)
+	ct = append(ct, elliptic.MarshalCompressed(pub.Curve, R.X, R.Y)...)
+	ct = append(ct, em...)
+	ct = append(ct, bie1Tag(Km, ct)...)
+	return
+}
+
+// BIE1 uses AES-128 and HMAC-SHA256.
+func enforceBIE1(curve elliptic.Curve) error {
+	return enforcePolicy(nil, SuiteBIE1, curve, sha256.Size, 16)
+}
+
+// DecryptBIE1 decrypts a BIE1 ciphertext.
+//
+// The key provider only reveals the X coordinate of the ECDH point, whereas
+// BIE1 derives its keys from the compressed point: the message tags of both
+// candidate points are computed, and the matching one selected.
+func DecryptBIE1(prv KeyProvider, c []byte) (m []byte, err error) {
+	pub := prv.Public()
+	if err = enforceBIE1(pub.Curve); err != nil {
+		return
+	}
+	rLen := 1 + (pub.Curve.Params().BitSize+7)/8
+	if len(c) < len(bie1Magic)+rLen+aes.BlockSize+sha256.Size || !bytes.Equal(c[:len(bie1Magic)], bie1Magic) {
+		err = ErrInvalidMessage
+		return
+	}
+	mStart := len(bie1Magic) + rLen
+	mEnd := len(c) - sha256.Size
+	if (mEnd-mStart)%aes.BlockSize != 0 {
+		err = ErrInvalidMessage
+		return
+	}
+
+	R := new(PublicKey)
+	R.Curve = pub.Curve
+	R.X, R.Y = unmarshalPoint(R.Curve, c[len(bie1Magic):mStart], AllowCompressedPoints)
+	if R.X == nil {
+		err = ErrInvalidPublicKey
+		return
+	}
+
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return
+	}
+	S := append([]byte{pointCompressedEven}, z...)
+	ivEven, KeEven, KmEven := bie1Keys(S)
+	S[0] = pointCompressedOdd
+	ivOdd, KeOdd, KmOdd := bie1Keys(S)
+
+	even := subtle.ConstantTimeCompare(c[mEnd:], bie1Tag(KmEven, c[:mEnd]))
+	odd := subtle.ConstantTimeCompare(c[mEnd:], bie1Tag(KmOdd, c[:mEnd]))
+	if even|odd != 1 {
+		err = ErrInvalidMessage
+		return
+	}
+	iv, Ke := ivOdd, KeOdd
+	if even == 1 {
+		iv, Ke = ivEven, KeEven
+	}
+	return cbcDecrypt(Ke, iv, c[mStart:mEnd])
+}
+
+func bie1Keys(S []byte) (iv, Ke, Km []byte) {
+	K := sha512.Sum512(S)
+	return K[:16], K[16:32], K[32:]
+}
+
+func bie1Tag(Km, msg []byte) []byte {
+	mac := hmac.New(sha256.New, Km)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,67 @@
+package ecies
+
+// Context binding, the v2 transcripts: the KDF and the MAC cover the curve,
+// the parameters, the ephemeral public key and both shared information
+// values, rather than only s1 and s2 respectively, so that a ciphertext
+// can't be replayed under other parameters, nor its ephemeral key swapped.
+//
+// The transcript is the curve name, the parameters, the encoded ephemeral
+// key, s1 and s2, each prefixed by its 32-bit big-endian length. The
+// parameters are their suite name, or a descriptor of their algorithms for
+// parameters without one. The KDF gets the transcript as its shared
+// information, and the MAC, or the AEAD as additional data, too, each after
+// its own label.
+
+import (
+	"crypto/elliptic"
+	"encoding/binary"
+)
+
+const (
+	bindKDFLabel = "ECIES-v2 KDF\x00"
+	bindMACLabel = "ECIES-v2 MAC\x00"
+)
+
+// bindContext returns the shared information of the KDF and the MAC which
+// bind the curve, params and encoded ephemeral key Rb, in place of s1 and s2.
+func bindContext(curve elliptic.Curve, params *ECIESParams, Rb, s1, s2 []byte) (kdfInfo, macInfo []byte) {
+	var t []byte
+	t = appendBound(t, []byte(curve.Params().Name))
+	t = appendBound(t, paramsDescriptor(params))
+	t = appendBound(t, Rb)
+	t = appendBound(t, s1)
+	t = appendBound(t, s2)
+	kdfInfo = append(append(make([]byte, 0, len(bindKDFLabel)+len(t)), bindKDFLabel...), t...)
+	macInfo = append(append(make([]byte, 0, len(bindMACLabel)+len(t)), bindMACLabel...), t...)
+	return kdfInfo, macInfo
+}
+
+func appendBound(out, field []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(field)))
+	return append(out, field...)
+}
+
+// paramsDescriptor identifies params in the transcript: by their suite name,
+// or else by their algorithms and flags. Custom KDFs are only told apart from
+// the standard ones, not from each other.
+func paramsDescriptor(params *ECIESParams) []byte {
+	if name, ok := suiteName(params); ok {
+		return []byte(name)
+	}
+	flags := byte(0)
+	if params.KDF != nil {
+		flags |= 1
+	}
+	if params.EphemeralInKDF {
+		flags |= 2
+	}
+	if params.MACLabelLength {
+		flags |= 4
+	}
+	if params.AEAD != nil {
+		flags |= 8
+	}
+	out := []byte{0, byte(params.hashAlgo), byte(params.dem), byte(params.kdf), byte(params.mac), flags}
+	out = binary.BigEndian.AppendUint16(out, uint16(params.KeyLen))
+	return binary.BigEndian.AppendUint16(out, uint16(params.BlockSize))
+}
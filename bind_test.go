@@ -0,0 +1,58 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure bound ciphertexts round trip, and only open with the same context.
+func TestBindContext(t *testing.T) {
+	for _, params := range []*ECIESParams{nil, ECIES_AES256_SHA512, ECIES_AES128_HKDF_SHA256} {
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		message := []byte("Hello, world.")
+		s1, s2 := []byte("s1"), []byte("s2")
+		ct, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, message, s1, s2, &EncryptOptions{BindContext: true})
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		opts := &DecryptOptions{BindContext: true}
+		m, err := DecryptWithOptions(prv, ct, s1, s2, opts)
+		if err != nil || !bytes.Equal(m, message) {
+			fmt.Println("ecies: bound message not restored", err)
+			t.FailNow()
+		}
+		if _, err = Decrypt(prv, ct, s1, s2); err != ErrInvalidMessage {
+			fmt.Println("ecies: bound message decrypted without its context", err)
+			t.FailNow()
+		}
+		if _, err = DecryptWithOptions(prv, ct, s1, []byte("other"), opts); err != ErrInvalidMessage {
+			fmt.Println("ecies: bound message decrypted with another s2", err)
+			t.FailNow()
+		}
+	}
+
+	// The transcripts tell the parameters and ephemeral keys apart.
+	curve := elliptic.P256()
+	k1, m1 := bindContext(curve, ECIES_AES128_SHA256, []byte{4, 1}, nil, nil)
+	k2, m2 := bindContext(curve, ECIES_AES128_HKDF_SHA256, []byte{4, 1}, nil, nil)
+	k3, _ := bindContext(curve, ECIES_AES128_SHA256, []byte{4, 2}, nil, nil)
+	k4, _ := bindContext(curve, ECIES_AES128_SHA256, []byte{4}, []byte{1}, nil)
+	if bytes.Equal(k1, k2) || bytes.Equal(m1, m2) || bytes.Equal(k1, k3) || bytes.Equal(k1, k4) || bytes.Equal(k1, m1) {
+		fmt.Println("ecies: transcripts not distinct")
+		t.FailNow()
+	}
+	custom := *ECIES_AES128_SHA256
+	custom.EphemeralInKDF = true
+	if bytes.Equal(paramsDescriptor(&custom), paramsDescriptor(ECIES_AES128_SHA256)) {
+		fmt.Println("ecies: custom parameters not told apart")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,177 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// Compatibility with the ECIES implementation of the Botan C++ library
+// (botan/ecies.h), which follows ISO/IEC 18033-2:
+//
+//	z = X coordinate of r·Q
+//	Ke || Km = KDF(R || z), or KDF(z) in single hash mode
+//	c = AES-CBC(Ke, iv, PKCS#7 padded m), with a zero iv unless one is set
+//	d = HMAC(Km, c || label)
+//	ciphertext = R || c || d
+//
+// The cofactor modes are not needed as the supported curves have a cofactor of 1.
+
+import (
+	"crypto/aes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"hash"
+	"io"
+)
+
+// BotanKDF selects the ISO 18033-2 KDF of a Botan ECIES profile.
+type BotanKDF int
+
+const (
+	// BotanKDF1_18033 is Botan's "KDF1-18033", counting from 0.
+	BotanKDF1_18033 BotanKDF = iota
+	// BotanKDF2 is Botan's "KDF2", counting from 1, which is the ANSI X9.63 KDF.
+	BotanKDF2
+)
+
+// BotanParams mirror the Botan ECIES_System_Params.
+type BotanParams struct {
+	KDF            BotanKDF
+	KDFHash        func() hash.Hash // hash of the KDF
+	DEMKeyLen      int              // AES key length of the AES-CBC/PKCS7 DEM
+	MACHash        func() hash.Hash // hash of the HMAC
+	MACKeyLen      int              // HMAC key length
+	Compressed     bool             // encode the ephemeral key in the compressed format
+	SingleHashMode bool             // leave the ephemeral key out of the KDF input
+	IV             []byte           // DEM initialization vector, zero if nil
+}
+
+// BotanDefaultParams match the parameters of Botan's ECIES tests and examples:
+// KDF1-18033(SHA-512), AES-256/CBC, HMAC(SHA-512) with a 32-byte key.
+var BotanDefaultParams = &BotanParams{
+	KDF:       BotanKDF1_18033,
+	KDFHash:   sha512.New,
+	DEMKeyLen: 32,
+	MACHash:   sha512.New,
+	MACKeyLen: 32,
+}
+
+func (params *BotanParams) iv() []byte {
+	if params.IV != nil {
+		return params.IV
+	}
+	return make([]byte, aes.BlockSize)
+}
+
+func (params *BotanParams) deriveKeys(Rb, z []byte) (Ke, Km []byte) {
+	var input []byte
+	if !params.SingleHashMode {
+		input = append(input, Rb...)
+	}
+	input = append(input, z...)
+	var counter uint32
+	if params.KDF == BotanKDF2 {
+		counter = 1
+	}
+	K := counterKDF(params.KDFHash(), input, nil, params.DEMKeyLen+params.MACKeyLen, counter)
+	return K[:params.DEMKeyLen], K[params.DEMKeyLen:]
+}
+
+func (params *BotanParams) tag(Km, c, label []byte) []byte {
+	mac := hmac.New(params.MACHash, Km)
+	mac.Write(c)
+	mac.Write(label)
+	return mac.Sum(nil)
+}
+
+func (params *BotanParams) enforce(curve elliptic.Curve) error {
+	hashSize := params.KDFHash().Size()
+	if size := params.MACHash().Size(); size < hashSize {
+		hashSize = size
+	}
+	keyLen := params.DEMKeyLen
+	if params.MACKeyLen < keyLen {
+		keyLen = params.MACKeyLen
+	}
+	return enforcePolicy(nil, SuiteBotan, curve, hashSize, keyLen)
+}
+
+// EncryptBotan encrypts a message as Botan's ECIES_Encryptor does, with an
+// optional label. If params is nil, BotanDefaultParams are used.
+func EncryptBotan(rand io.Reader, pub *PublicKey, params *BotanParams, m, label []byte) (ct []byte, err error) {
+	if params == nil {
+		params = BotanDefaultParams
+	}
+	if err = ValidatePublicKey(pub); err != nil {
+		return
+	}
+	if err = params.enforce(pub.Curve); err != nil {
+		return
+	}
+	R, err := GenerateKey(rand, pub.Curve, nil)
+	if err != nil {
+		return
+	}
+	z, err := R.GenerateShared(pub)
+	if err != nil {
+		return
+	}
+	Rb := elliptic.Marshal(pub.Curve, R.X, R.Y)
+	if params.Compressed {
+		Rb = elliptic.MarshalCompressed(pub.Curve, R.X, R.Y)
+	}
+	Ke, Km := params.deriveKeys(Rb, z)
+
+	em, err := cbcEncrypt(Ke, params.iv(), m)
+	if err != nil {
+		return
+	}
+	ct = append(ct, Rb...)
+	ct = append(ct, em...)
+	ct = append(ct, params.tag(Km, em, label)...)
+	return
+}
+
+// DecryptBotan decrypts a message encrypted by Botan's ECIES_Encryptor.
+// If params is nil, BotanDefaultParams are used.
+func DecryptBotan(prv KeyProvider, params *BotanParams, c, label []byte) (m []byte, err error) {
+	if params == nil {
+		params = BotanDefaultParams
+	}
+	pub := prv.Public()
+	if err = params.enforce(pub.Curve); err != nil {
+		return
+	}
+	if len(c) == 0 {
+		err = ErrInvalidMessage
+		return
+	}
+	mStart := pointSize(pub.Curve, c[0], AllowCompressedPoints)
+	mEnd := len(c) - params.MACHash().Size()
+	if mStart == 0 {
+		err = ErrInvalidPublicKey
+		return
+	} else if mEnd-mStart < aes.BlockSize {
+		err = ErrInvalidMessage
+		return
+	}
+
+	R := new(PublicKey)
+	R.Curve = pub.Curve
+	R.X, R.Y = unmarshalPoint(R.Curve, c[:mStart], AllowCompressedPoints)
+	if R.X == nil {
+		err = ErrInvalidPublicKey
+		return
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return
+	}
+	Ke, Km := params.deriveKeys(c[:mStart], z)
+
+	if subtle.ConstantTimeCompare(c[mEnd:], params.tag(Km, c[mStart:mEnd], label)) != 1 {
+		err = ErrInvalidMessage
+		return
+	}
+	return cbcDecrypt(Ke, params.iv(), c[mStart:mEnd])
+}
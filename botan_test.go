@@ -0,0 +1,137 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var botanTestParams = []*BotanParams{
+	BotanDefaultParams,
+	{
+		KDF:            BotanKDF2,
+		KDFHash:        sha256.New,
+		DEMKeyLen:      16,
+		MACHash:        sha256.New,
+		MACKeyLen:      16,
+		Compressed:     true,
+		SingleHashMode: true,
+	},
+}
+
+// Ensure that the Botan profiles round trip and authenticate the label.
+func TestBotan(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	message := []byte("Hello, world.")
+	for _, params := range botanTestParams {
+		ct, err := EncryptBotan(rand.Reader, &prv.PublicKey, params, message, []byte("label"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pt, err := DecryptBotan(prv, params, ct, []byte("label"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(pt, message) {
+			fmt.Println("ecies: plaintext doesn't match message")
+			t.FailNow()
+		}
+		if _, err := DecryptBotan(prv, params, ct, []byte("other")); err != ErrInvalidMessage {
+			fmt.Println("ecies: decrypted with the wrong label")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that messages produced by an independent implementation of Botan's
+// ECIES_Encryptor are decrypted: "Hello, Botan." on P-256 for the key d
+// below, with the two profiles of botanTestParams, no IV and the labels
+// listed. The vectors come from a Node.js script written after Botan's
+// ecies.cpp, not from Botan itself, which no Botan build was available to
+// produce: they guard against regressions and misreadings of the layout, but
+// don't prove interoperability. Ciphertexts of Botan's ECIES_Encryptor, with
+// its version, belong here once one is at hand.
+func TestVectorBotan(t *testing.T) {
+	d, _ := hex.DecodeString("1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100")
+	prv, err := NewPrivateKey(DefaultCurve, d)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	for i, v := range []struct {
+		Enc   string
+		Label string
+	}{
+		{"04e48813e656219b4090c282a020f40e07b4e1efd60a3dd17492a1667c5758ee5b760f9b9b1c840b4f4f63ab4043c0537ca29b3512c32e50e56f5e4e8d42d0d31e4f56fb322fda71cab96401d4f7a5943fe894d52ad7b231aa541a209abcb690ff59e5e6a792a9a57fafd6242dfdd588ca2de9d7d4f27cd2e6c4584a5eb0e99c154fbd778db6ce47d4f57c2d216f1a7784", ""},
+		{"02e48813e656219b4090c282a020f40e07b4e1efd60a3dd17492a1667c5758ee5b91b9ac9f2c334860af4a1b86dcfe2f97486f29eb6a09e89687ea742242ba8074197d261b88b54526b9863599b571c724", "label"},
+	} {
+		enc, _ := hex.DecodeString(v.Enc)
+		pt, err := DecryptBotan(prv, botanTestParams[i], enc, []byte(v.Label))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if string(pt) != "Hello, Botan." {
+			fmt.Println("ecies: decrypted doesn't match vector", string(pt))
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that the ISO 18033-2 parameters interoperate with Botan, which
+// follows the same standard when the ephemeral key is part of the KDF input.
+func TestBotanISO18033(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, ECIES_AES128_ISO18033_SHA256)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	params := &BotanParams{
+		KDF:       BotanKDF2,
+		KDFHash:   sha256.New,
+		DEMKeyLen: 16,
+		MACHash:   sha256.New,
+		MACKeyLen: 16,
+	}
+	message := []byte("Hello, ISO 18033-2.")
+
+	ct, err := EncryptBotan(rand.Reader, &prv.PublicKey, params, message, []byte("label"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, ct, nil, []byte("label")); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: Botan message not decrypted", err)
+		t.FailNow()
+	}
+	if ct, err = Encrypt(rand.Reader, &prv.PublicKey, message, nil, []byte("label")); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := DecryptBotan(prv, params, ct, []byte("label")); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: ISO 18033-2 message not decrypted by Botan", err)
+		t.FailNow()
+	}
+}
+
+// Ensure that messages aren't encrypted to points off the curve.
+func TestOffCurveBotan(t *testing.T) {
+	pub := offCurveKey(t, DefaultCurve)
+	if _, err := EncryptBotan(rand.Reader, pub, nil, []byte("message"), nil); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: encrypted to an off-curve point", err)
+		t.FailNow()
+	}
+}
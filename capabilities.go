@@ -0,0 +1,131 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Descriptors of the curves, suites and formats supported by this package,
+// for management planes and CLIs.
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"maps"
+	"sort"
+)
+
+// CurveInfo describes a supported curve.
+type CurveInfo struct {
+	Curve elliptic.Curve
+	Name  string
+	// OID is nil for curves without a registered ASN.1 identifier.
+	OID asn1.ObjectIdentifier
+	// ID is the compact curve identifier of the envelope format, or 0.
+	ID           byte
+	SecurityBits int
+	// PointSize and CompressedPointSize are the sizes of the encoded
+	// ephemeral keys. They are the same for curves without SEC 1 point
+	// encodings, such as X25519.
+	PointSize           int
+	CompressedPointSize int
+	// DefaultParams are the parameters used with keys on this curve.
+	DefaultParams *ECIESParams
+}
+
+// SuiteInfo describes a supported ECIES parameter set.
+type SuiteInfo struct {
+	// ID is the compact suite identifier of the envelope format.
+	ID           byte
+	Name         string
+	Params       *ECIESParams
+	SecurityBits int
+	// IVSize and TagSize are the per message overhead of the suite, in
+	// addition to the ephemeral key.
+	IVSize  int
+	TagSize int
+}
+
+// Overhead returns the size added to messages encrypted with the suite to a
+// key on the curve, with an uncompressed ephemeral key.
+func (s SuiteInfo) Overhead(curve elliptic.Curve) int {
+	return pointSize(curve, pointUncompressed, AllowAllPoints) + s.IVSize + s.TagSize
+}
+
+// curveSecurityBits returns the security level of the curve, half its bit
+// size rounded up, capped to the nearest usual level below.
+func curveSecurityBits(curve elliptic.Curve) int {
+	bits := (curve.Params().BitSize + 1) / 2
+	for _, level := range []int{256, 192, 128, 112} {
+		if bits >= level {
+			return level
+		}
+	}
+	return bits
+}
+
+// SupportedCurves returns the curves which have default parameters, sorted by
+// size, then identifier.
+func SupportedCurves() []CurveInfo {
+	var curves []CurveInfo
+	add := func(curve elliptic.Curve, params *ECIESParams) {
+		info := CurveInfo{
+			Curve:               curve,
+			Name:                curve.Params().Name,
+			ID:                  curveIDs[curve],
+			SecurityBits:        curveSecurityBits(curve),
+			PointSize:           pointSize(curve, pointUncompressed, AllowAllPoints),
+			CompressedPointSize: pointSize(curve, pointCompressedEven, AllowAllPoints),
+			DefaultParams:       params,
+		}
+		if oid, ok := oidFromNamedCurve(curve); ok {
+			info.OID = asn1.ObjectIdentifier(oid)
+		}
+		curves = append(curves, info)
+	}
+	curvesMu.RLock()
+	defaults := maps.Clone(paramsFromCurve)
+	curvesMu.RUnlock()
+	for curve, params := range defaults {
+		add(curve, params)
+	}
+	for curve, params := range paramsFromRawCurve {
+		add(curve, params)
+	}
+	sort.Slice(curves, func(i, j int) bool {
+		bi, bj := curves[i].Curve.Params().BitSize, curves[j].Curve.Params().BitSize
+		return bi < bj || (bi == bj && curves[i].ID < curves[j].ID)
+	})
+	return curves
+}
+
+// suiteSecurityBits returns the security level of a parameter set, bounded by
+// the key length and by half the hash size.
+func suiteSecurityBits(params *ECIESParams) int {
+	bits := 8 * params.KeyLen
+	if h := 4 * params.Hash().Size(); h < bits {
+		bits = h
+	}
+	return bits
+}
+
+// SupportedSuites returns the standard parameter sets, by identifier.
+func SupportedSuites() []SuiteInfo {
+	suites := make([]SuiteInfo, 0, len(suiteIDs))
+	for _, s := range suiteIDs {
+		ivSize, tagSize := s.params.demOverhead()
+		suites = append(suites, SuiteInfo{
+			ID:           s.id,
+			Name:         s.name,
+			Params:       s.params,
+			SecurityBits: suiteSecurityBits(s.params),
+			IVSize:       ivSize,
+			TagSize:      tagSize,
+		})
+	}
+	return suites
+}
+
+// SupportedFormats returns the readable ciphertext and key formats. It is the
+// same list as Formats.
+func SupportedFormats() []*Format {
+	return Formats()
+}
@@ -0,0 +1,71 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure the capability descriptors match the actual encodings.
+func TestCapabilities(t *testing.T) {
+	curves := SupportedCurves()
+	last := len(curves) - 1
+	if len(curves) < 5 || curves[0].Curve != X25519() || curves[0].SecurityBits != 128 ||
+		curves[1].Curve != elliptic.P256() || curves[1].SecurityBits != 128 ||
+		curves[2].Curve != Secp256k1() || curves[2].SecurityBits != 128 ||
+		curves[last].Curve != elliptic.P521() || curves[last].SecurityBits != 256 {
+		fmt.Println("ecies: unexpected supported curves")
+		t.FailNow()
+	}
+	for _, c := range curves {
+		if montgomeryCurve(c.Curve) {
+			if c.ID == 0 || c.PointSize != c.Curve.(rawPointCurve).pointLen() || c.CompressedPointSize != c.PointSize {
+				fmt.Println("ecies: incomplete descriptor for", c.Name)
+				t.FailNow()
+			}
+			continue
+		}
+		if c.OID == nil || c.ID == 0 || c.PointSize != 2*c.CompressedPointSize-1 {
+			fmt.Println("ecies: incomplete descriptor for", c.Name)
+			t.FailNow()
+		}
+	}
+
+	message := []byte("Hello, world.")
+	for _, s := range SupportedSuites() {
+		found := false
+		for _, c := range curves {
+			if c.SecurityBits != s.SecurityBits {
+				continue
+			}
+			found = true
+			prv, err := GenerateKey(rand.Reader, c.Curve, s.Params)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			if len(ct)-len(message) != s.Overhead(c.Curve) || s.SecurityBits != c.SecurityBits {
+				fmt.Println("ecies: wrong descriptor for suite", s.Name)
+				t.FailNow()
+			}
+		}
+		if !found {
+			fmt.Println("ecies: suite not matching any curve", s.Name)
+			t.FailNow()
+		}
+	}
+
+	if len(SupportedFormats()) != len(formats) {
+		fmt.Println("ecies: missing formats")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,46 @@
+package ecies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// cbcEncrypt carries out AES-CBC encryption of the PKCS#7 padded message.
+func cbcEncrypt(key, iv, m []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(m)%aes.BlockSize
+	ct := make([]byte, len(m)+pad)
+	copy(ct, m)
+	for i := len(m); i < len(ct); i++ {
+		ct[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(c, iv).CryptBlocks(ct, ct)
+	return ct, nil
+}
+
+// cbcDecrypt carries out AES-CBC decryption and removes the PKCS#7 padding.
+// It must only be called on authenticated ciphertexts, to not be a padding oracle.
+func cbcDecrypt(key, iv, ct []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ct) == 0 || len(ct)%aes.BlockSize != 0 {
+		return nil, ErrInvalidMessage
+	}
+	m := make([]byte, len(ct))
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(m, ct)
+	pad := int(m[len(m)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, ErrInvalidMessage
+	}
+	for _, b := range m[len(m)-pad:] {
+		if int(b) != pad {
+			return nil, ErrInvalidMessage
+		}
+	}
+	return m[:len(m)-pad], nil
+}
@@ -0,0 +1,38 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/x509"
+	"io"
+)
+
+var ErrCertificateKeyUsage = newError(KindKey, "ecies: certificate key usage does not allow encryption")
+
+// ImportCertificatePublic returns the public key of an X.509 certificate, with
+// the default parameters of its curve, so that the certificate can be used as
+// a recipient. The key must be an EC key on a supported curve, or an X25519
+// key, and the key usage of the certificate, if any, must include key
+// agreement or key encipherment; otherwise ErrCertificateKeyUsage is
+// returned.
+//
+// The certificate isn't verified: its chain, validity period and extended key
+// usages are to be checked by the caller, e.g. with cert.Verify.
+func ImportCertificatePublic(cert *x509.Certificate) (*PublicKey, error) {
+	if cert.KeyUsage != 0 && cert.KeyUsage&(x509.KeyUsageKeyAgreement|x509.KeyUsageKeyEncipherment) == 0 {
+		return nil, ErrCertificateKeyUsage
+	}
+	return unmarshalPublicPKIX(cert.RawSubjectPublicKeyInfo, AllowAllPoints)
+}
+
+// EncryptToCertificate encrypts a message to the public key of an X.509
+// certificate, as Encrypt does, after checking it with
+// ImportCertificatePublic.
+func EncryptToCertificate(rand io.Reader, cert *x509.Certificate, m, s1, s2 []byte) ([]byte, error) {
+	pub, err := ImportCertificatePublic(cert)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(rand, pub, m, s1, s2)
+}
@@ -0,0 +1,75 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// issueCertificate returns a certificate for pub, with the given key usage,
+// issued by a throwaway P-256 CA.
+func issueCertificate(t *testing.T, pub interface{}, usage x509.KeyUsage) *x509.Certificate {
+	ca, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "recipient"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     usage,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, ca)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	return cert
+}
+
+// Ensure that messages encrypted to certificates decrypt with their keys, and
+// that certificates restricted to signing are rejected.
+func TestEncryptToCertificate(t *testing.T) {
+	message := []byte("Hello, PKI.")
+	prv, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	for _, usage := range []x509.KeyUsage{x509.KeyUsageKeyAgreement, 0} {
+		cert := issueCertificate(t, &prv.ExportECDSA().PublicKey, usage)
+		ct, err := EncryptToCertificate(rand.Reader, cert, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: message not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	cert := issueCertificate(t, &prv.ExportECDSA().PublicKey, x509.KeyUsageDigitalSignature)
+	if _, err = EncryptToCertificate(rand.Reader, cert, message, nil, nil); err != ErrCertificateKeyUsage {
+		fmt.Println("ecies: encrypted to a signing certificate", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,91 @@
+package ecies
+
+// Access to the parts of raw ciphertexts, for tools which inspect, log or
+// re-frame them.
+
+import "crypto/elliptic"
+
+// Ciphertext is a raw ciphertext, as returned by Encrypt, split into its
+// parts.
+type Ciphertext struct {
+	ephemeral *PublicKey
+	encoded   []byte
+	body      []byte
+	tag       []byte
+}
+
+// splitCiphertext returns the size of the ephemeral public key which starts
+// c, checking that c holds at least the smallest encrypted message after it:
+// one byte and the tag in CTR mode, or a nonce and the tag with an AEAD.
+func splitCiphertext(curve elliptic.Curve, params *ECIESParams, c []byte, policy PointFormatPolicy) (int, error) {
+	ivLen, tagLen := params.demOverhead()
+	minLen := tagLen + 1
+	if params.AEAD != nil {
+		minLen = ivLen + tagLen
+	}
+	if len(c) == 0 {
+		return 0, refineError(ErrInvalidMessage, KindEncoding, errMessageTooShort)
+	}
+	rLen := pointSize(curve, c[0], policy)
+	if rLen == 0 {
+		return 0, ErrInvalidPublicKey
+	}
+	if len(c) < rLen+minLen {
+		return 0, refineError(ErrInvalidMessage, KindEncoding, errMessageTooShort)
+	}
+	return rLen, nil
+}
+
+// ParseCiphertext splits a raw ciphertext on curve with params, or the
+// default parameters of the curve if params is nil. Ciphertexts with a
+// timestamp or validity header, or in an envelope, aren't accepted. The
+// ciphertext isn't authenticated: only decryption does that.
+func ParseCiphertext(params *ECIESParams, curve elliptic.Curve, ct []byte) (*Ciphertext, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if params == nil {
+		if params = ParamsFromCurve(curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	rLen, err := splitCiphertext(curve, params, ct, AllowAllPoints)
+	if err != nil {
+		return nil, err
+	}
+	R, err := parseEncapsulation(&PublicKey{Curve: curve, Params: params}, ct[:rLen], AllowAllPoints)
+	if err != nil {
+		return nil, err
+	}
+	_, tagLen := params.demOverhead()
+	return &Ciphertext{
+		ephemeral: R,
+		encoded:   ct[:rLen],
+		body:      ct[rLen : len(ct)-tagLen],
+		tag:       ct[len(ct)-tagLen:],
+	}, nil
+}
+
+// EphemeralKey returns the ephemeral public key of the sender.
+func (c *Ciphertext) EphemeralKey() *PublicKey {
+	return c.ephemeral
+}
+
+// Body returns the encrypted message, preceded by its IV or nonce.
+func (c *Ciphertext) Body() []byte {
+	return c.body
+}
+
+// Tag returns the MAC tag, or the AEAD tag.
+func (c *Ciphertext) Tag() []byte {
+	return c.tag
+}
+
+// Bytes returns the raw ciphertext, with the ephemeral public key in its
+// original encoding.
+func (c *Ciphertext) Bytes() []byte {
+	out := make([]byte, 0, len(c.encoded)+len(c.body)+len(c.tag))
+	out = append(out, c.encoded...)
+	out = append(out, c.body...)
+	return append(out, c.tag...)
+}
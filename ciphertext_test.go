@@ -0,0 +1,69 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that ciphertexts are split as MarshalCiphertextASN1 does, and put
+// back together unchanged.
+func TestParseCiphertext(t *testing.T) {
+	for _, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES256_GCM_SHA512, ECIES_CHACHA20POLY1305_SHA256} {
+		prv, err := GenerateKey(rand.Reader, DefaultCurve, params)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("Hello, world."), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		c, err := ParseCiphertext(params, DefaultCurve, ct)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if !bytes.Equal(c.Bytes(), ct) {
+			fmt.Println("ecies: ciphertext changed")
+			t.FailNow()
+		}
+		if _, tagLen := params.demOverhead(); len(c.Tag()) != tagLen {
+			fmt.Println("ecies: unexpected tag size")
+			t.FailNow()
+		}
+		if err = ValidatePublicKey(c.EphemeralKey()); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		der, err := MarshalCiphertextASN1(&prv.PublicKey, ct)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		var fields asnCiphertext
+		if _, err = asn1.Unmarshal(der, &fields); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if !bytes.Equal(fields.SymmetricCiphertext, c.Body()) || !bytes.Equal(fields.MACTag, c.Tag()) {
+			fmt.Println("ecies: ciphertext split differently")
+			t.FailNow()
+		}
+
+		for _, bad := range [][]byte{nil, ct[:len(ct)-len(c.Body())-len(c.Tag())]} {
+			if _, err = ParseCiphertext(params, DefaultCurve, bad); !errors.Is(err, ErrInvalidMessage) {
+				fmt.Println("ecies: parsed a truncated ciphertext:", err)
+				t.FailNow()
+			}
+		}
+	}
+}
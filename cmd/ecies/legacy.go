@@ -0,0 +1,58 @@
+//go:build !ecies_tiny && !ecies_nolegacy
+// +build !ecies_tiny,!ecies_nolegacy
+
+package main
+
+// The -format names of the compatibility suites, which the ecies_nolegacy
+// build leaves out.
+
+import (
+	"crypto/rand"
+	"encoding/json"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+func init() {
+	cipherFormats["ethereum"] = &cipherFormat{
+		encrypt: func(pub *ecies.PublicKey, m, s1, s2 []byte) ([]byte, error) {
+			return ecies.EncryptGeth(rand.Reader, pub, m, s1, s2)
+		},
+		decrypt: func(prv *ecies.PrivateKey, c, s1, s2 []byte) ([]byte, error) {
+			return ecies.DecryptGeth(prv, c, s1, s2)
+		},
+		shared: true,
+	}
+	cipherFormats["eciespy"] = &cipherFormat{
+		encrypt: func(pub *ecies.PublicKey, m, _, _ []byte) ([]byte, error) {
+			return ecies.EncryptEciespy(rand.Reader, pub, m)
+		},
+		decrypt: func(prv *ecies.PrivateKey, c, _, _ []byte) ([]byte, error) {
+			return ecies.DecryptEciespy(prv, c)
+		},
+	}
+	cipherFormats["eccrypto"] = &cipherFormat{
+		encrypt: func(pub *ecies.PublicKey, m, _, _ []byte) ([]byte, error) {
+			msg, err := ecies.EncryptEccrypto(rand.Reader, pub, m)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(msg)
+		},
+		decrypt: func(prv *ecies.PrivateKey, c, _, _ []byte) ([]byte, error) {
+			msg := new(ecies.EccryptoMessage)
+			if err := json.Unmarshal(c, msg); err != nil {
+				return nil, err
+			}
+			return ecies.DecryptEccrypto(prv, msg)
+		},
+	}
+	cipherFormats["apple-x963"] = &cipherFormat{
+		encrypt: func(pub *ecies.PublicKey, m, _, _ []byte) ([]byte, error) {
+			return ecies.EncryptApple(rand.Reader, pub, ecies.AppleX963SHA256AESGCM, m)
+		},
+		decrypt: func(prv *ecies.PrivateKey, c, _, _ []byte) ([]byte, error) {
+			return ecies.DecryptApple(prv, ecies.AppleX963SHA256AESGCM, c)
+		},
+	}
+}
@@ -0,0 +1,55 @@
+//go:build !ecies_tiny && !ecies_nolegacy
+// +build !ecies_tiny,!ecies_nolegacy
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// Ensure messages round trip in each compatibility format, and that the
+// formats reject the flags they don't take.
+func TestRunLegacyFormats(t *testing.T) {
+	message := []byte("Hello, operator.")
+	for format, curve := range map[string]string{
+		"ethereum":   "secp256k1",
+		"eciespy":    "secp256k1",
+		"eccrypto":   "secp256k1",
+		"apple-x963": "P-256",
+	} {
+		key, pub, ct := encryptFormat(t, curve, format, message)
+		var out bytes.Buffer
+		if err := run([]string{"decrypt", "-key", key, "-format", format}, bytes.NewReader(ct), &out); err != nil ||
+			!bytes.Equal(out.Bytes(), message) {
+			fmt.Println("ecies: message not decrypted", format, err)
+			t.FailNow()
+		}
+		// The raw format is go-ethereum's with the default parameters.
+		if err := run([]string{"decrypt", "-key", key}, bytes.NewReader(ct), new(bytes.Buffer)); err == nil && format != "ethereum" {
+			fmt.Println("ecies: decrypted as raw", format)
+			t.FailNow()
+		}
+		if err := run([]string{"encrypt", "-key", pub, "-format", format, "-armor"}, bytes.NewReader(message), new(bytes.Buffer)); err == nil {
+			fmt.Println("ecies: armored", format)
+			t.FailNow()
+		}
+		if format == "eccrypto" && json.Unmarshal(ct, new(ecies.EccryptoMessage)) != nil {
+			fmt.Println("ecies: eccrypto message not JSON")
+			t.FailNow()
+		}
+	}
+	key, _, ct := encryptFormat(t, "secp256k1", "ethereum", message)
+	if err := run([]string{"decrypt", "-key", key, "-format", "ethereum", "-s1", "label"}, bytes.NewReader(ct), new(bytes.Buffer)); err == nil {
+		fmt.Println("ecies: decrypted with other shared information")
+		t.FailNow()
+	}
+	if err := run([]string{"decrypt", "-key", key, "-format", "eciespy", "-s1", "label"}, bytes.NewReader(ct), new(bytes.Buffer)); err == nil {
+		fmt.Println("ecies: eciespy accepted shared information")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,693 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+// Command ecies generates keys and encrypts and decrypts files with the
+// ecies package, for manual operations on the keys and messages of services
+// using it.
+//
+// Usage:
+//
+//	ecies keygen [-curve P-256] [-params NAME] [-out key.pem]
+//	ecies pubkey -key key.pem [-out pub.pem]
+//	ecies encrypt -key pub.pem [-format NAME] [-armor] [-s1 TEXT] [-s2 TEXT] [-in FILE] [-out FILE]
+//	ecies encrypt -key pub.pem -stream [-progress] [-in FILE] [-out FILE]
+//	ecies decrypt -key key.pem [-format NAME] [-s1 TEXT] [-s2 TEXT] [-in FILE] [-out FILE]
+//	ecies decrypt -key key.pem -stream [-progress] [-in FILE] [-out FILE]
+//	ecies armor -key pub.pem [-in FILE] [-out FILE]
+//	ecies dearmor -key pub.pem [-in FILE] [-out FILE]
+//	ecies inspect [-key pub.pem] [-in FILE]
+//
+// Keys are PEM files, as written by keygen. Commands taking a public key
+// also take a private key. Input is read from stdin and output written to
+// stdout unless -in and -out are given. decrypt reads raw, enveloped,
+// ASN.1 and armored ciphertexts.
+//
+// inspect describes keys, with their fingerprint and PEM metadata, and
+// ciphertexts without decrypting them. Envelopes record their curve and
+// parameters; other ciphertexts are parsed with those of -key.
+//
+// -format selects the ciphertext format of encrypt and decrypt: raw, the
+// default, native-v1 for the versioned envelope, or the compatibility suites
+// ethereum (go-ethereum), eciespy, eccrypto (its JSON messages) and
+// apple-x963 (eciesEncryptionCofactorX963SHA256AESGCM), so that the command
+// can debug and convert the ciphertexts of other languages. Only raw and
+// native-v1 ciphertexts can be armored, and only they and ethereum take -s1
+// and -s2.
+//
+// With -stream, encrypt and decrypt use the chunked stream format of
+// ecies.NewEncryptingWriter, with constant memory, so that they can sit in
+// pipelines handling large archives. -progress then reports the size of the
+// input processed on stderr. The output of decrypt -stream is authenticated
+// chunk by chunk: it is only complete if the command succeeds.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+var errUsage = errors.New("usage: ecies keygen|pubkey|encrypt|decrypt|armor|dearmor|inspect [flags]")
+
+// stderr receives the progress reports.
+var stderr io.Writer = os.Stderr
+
+// streamBufferSize is the size of the copies of streams: a chunk of the
+// stream format, so that a slow reader or writer holds the other back.
+const streamBufferSize = 64 * 1024
+
+// progressStep is the amount of input between progress reports.
+const progressStep = 1 << 20
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ecies:", err)
+		os.Exit(1)
+	}
+}
+
+// command holds the flags shared by the commands.
+type command struct {
+	flags    *flag.FlagSet
+	key      string
+	in       string
+	out      string
+	s1, s2   string
+	stream   bool
+	progress bool
+	format   string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func newCommand(name string, stdin io.Reader, stdout io.Writer) *command {
+	cmd := &command{flags: flag.NewFlagSet(name, flag.ContinueOnError), stdin: stdin, stdout: stdout}
+	cmd.flags.SetOutput(io.Discard)
+	cmd.flags.StringVar(&cmd.key, "key", "", "key file")
+	cmd.flags.StringVar(&cmd.in, "in", "", "input file, stdin if not set")
+	cmd.flags.StringVar(&cmd.out, "out", "", "output file, stdout if not set")
+	return cmd
+}
+
+func (cmd *command) sharedInfo() {
+	cmd.flags.StringVar(&cmd.s1, "s1", "", "shared information of the KDF")
+	cmd.flags.StringVar(&cmd.s2, "s2", "", "shared information of the MAC")
+}
+
+func (cmd *command) ciphertextFormat() {
+	names := make([]string, 0, len(cipherFormats))
+	for name := range cipherFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd.flags.StringVar(&cmd.format, "format", "raw", "format of the ciphertext: "+strings.Join(names, ", "))
+}
+
+// cipherFormat returns the format of -format, checking the flags given along
+// with it.
+func (cmd *command) cipherFormat() (*cipherFormat, error) {
+	f, ok := cipherFormats[cmd.format]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("unknown format %q", cmd.format)
+	case cmd.stream && cmd.format != "raw":
+		return nil, errors.New("streams have a format of their own")
+	case !f.shared && (cmd.s1 != "" || cmd.s2 != ""):
+		return nil, fmt.Errorf("%s ciphertexts have no shared information", cmd.format)
+	}
+	return f, nil
+}
+
+func (cmd *command) streaming() {
+	cmd.flags.BoolVar(&cmd.stream, "stream", false, "use the chunked stream format, with constant memory")
+	cmd.flags.BoolVar(&cmd.progress, "progress", false, "report the progress of the stream on stderr")
+}
+
+// checkStreaming checks the flags given along with -stream, or -progress.
+func (cmd *command) checkStreaming() error {
+	switch {
+	case cmd.progress && !cmd.stream:
+		return errors.New("-progress requires -stream")
+	case cmd.stream && (cmd.s1 != "" || cmd.s2 != ""):
+		return errors.New("streams have no shared information")
+	}
+	return nil
+}
+
+func (cmd *command) shared() (s1, s2 []byte) {
+	if cmd.s1 != "" {
+		s1 = []byte(cmd.s1)
+	}
+	if cmd.s2 != "" {
+		s2 = []byte(cmd.s2)
+	}
+	return s1, s2
+}
+
+func (cmd *command) input() ([]byte, error) {
+	if cmd.in == "" {
+		return io.ReadAll(cmd.stdin)
+	}
+	return os.ReadFile(cmd.in)
+}
+
+// output writes data to the output file, readable by its owner only if
+// private is set.
+func (cmd *command) output(data []byte, private bool) error {
+	if cmd.out == "" {
+		_, err := cmd.stdout.Write(data)
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if private {
+		mode = 0o600
+	}
+	return os.WriteFile(cmd.out, data, mode)
+}
+
+// reader opens the input for streaming.
+func (cmd *command) reader() (io.ReadCloser, error) {
+	if cmd.in == "" {
+		return io.NopCloser(cmd.stdin), nil
+	}
+	return os.Open(cmd.in)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writer opens the output for streaming, as output writes it.
+func (cmd *command) writer(private bool) (io.WriteCloser, error) {
+	if cmd.out == "" {
+		return nopWriteCloser{cmd.stdout}, nil
+	}
+	mode := os.FileMode(0o644)
+	if private {
+		mode = 0o600
+	}
+	return os.OpenFile(cmd.out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+}
+
+// pipe streams the input through process to the output, which returns the
+// writer to copy the input to, and closes it all.
+func (cmd *command) pipe(private bool, process func(out io.Writer, in io.Reader) error) error {
+	in, err := cmd.reader()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := cmd.writer(private)
+	if err != nil {
+		return err
+	}
+	var r io.Reader = in
+	if cmd.progress {
+		p := &progressReader{r: in, next: progressStep}
+		defer p.done()
+		r = p
+	}
+	err = process(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// progressReader reports the amount of data read on stderr.
+type progressReader struct {
+	r    io.Reader
+	n    int64
+	next int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if p.n += int64(n); p.n >= p.next {
+		fmt.Fprintf(stderr, "\r%d bytes", p.n)
+		p.next = p.n + progressStep
+	}
+	return n, err
+}
+
+func (p *progressReader) done() {
+	fmt.Fprintf(stderr, "\r%d bytes\n", p.n)
+}
+
+func (cmd *command) privateKey() (*ecies.PrivateKey, error) {
+	if cmd.key == "" {
+		return nil, errors.New("-key is required")
+	}
+	in, err := os.ReadFile(cmd.key)
+	if err != nil {
+		return nil, err
+	}
+	prv := new(ecies.PrivateKey)
+	if err = prv.UnmarshalText(in); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd.key, err)
+	}
+	return prv, nil
+}
+
+func (cmd *command) publicKey() (*ecies.PublicKey, error) {
+	if cmd.key == "" {
+		return nil, errors.New("-key is required")
+	}
+	in, err := os.ReadFile(cmd.key)
+	if err != nil {
+		return nil, err
+	}
+	pub := new(ecies.PublicKey)
+	if err = pub.UnmarshalText(in); err == nil {
+		return pub, nil
+	}
+	prv := new(ecies.PrivateKey)
+	if prv.UnmarshalText(in) == nil {
+		return &prv.PublicKey, nil
+	}
+	return nil, fmt.Errorf("%s: %w", cmd.key, err)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd := newCommand(args[0], stdin, stdout)
+	var exec func(*command) error
+	switch args[0] {
+	case "keygen":
+		exec = keygen(cmd)
+	case "pubkey":
+		exec = pubkey
+	case "encrypt":
+		cmd.sharedInfo()
+		cmd.streaming()
+		cmd.ciphertextFormat()
+		exec = encrypt(cmd)
+	case "decrypt":
+		cmd.sharedInfo()
+		cmd.streaming()
+		cmd.ciphertextFormat()
+		exec = decrypt
+	case "armor":
+		exec = armor
+	case "dearmor":
+		exec = dearmor
+	case "inspect":
+		exec = inspect
+	default:
+		return errUsage
+	}
+	if err := cmd.flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if cmd.flags.NArg() != 0 {
+		return errUsage
+	}
+	return exec(cmd)
+}
+
+func keygen(cmd *command) func(*command) error {
+	curveName := cmd.flags.String("curve", "P-256", "curve of the key")
+	paramsName := cmd.flags.String("params", "", "suite name of the parameters, the default of the curve if not set")
+	return func(cmd *command) error {
+		var curve *ecies.CurveInfo
+		var names []string
+		for _, c := range ecies.SupportedCurves() {
+			if strings.EqualFold(c.Name, *curveName) {
+				curve = &c
+			}
+			names = append(names, c.Name)
+		}
+		if curve == nil {
+			return fmt.Errorf("unknown curve %q, one of %s", *curveName, strings.Join(names, ", "))
+		}
+		var params *ecies.ECIESParams
+		if *paramsName != "" {
+			var err error
+			if params, err = ecies.ParamsFromName(*paramsName); err != nil {
+				return err
+			}
+		}
+		prv, err := ecies.GenerateKey(rand.Reader, curve.Curve, params)
+		if err != nil {
+			return err
+		}
+		defer prv.Wipe()
+		out, err := prv.MarshalText()
+		if err != nil {
+			return err
+		}
+		return cmd.output(out, true)
+	}
+}
+
+func pubkey(cmd *command) error {
+	pub, err := cmd.publicKey()
+	if err != nil {
+		return err
+	}
+	out, err := pub.MarshalText()
+	if err != nil {
+		return err
+	}
+	return cmd.output(out, false)
+}
+
+func encrypt(cmd *command) func(*command) error {
+	armored := cmd.flags.Bool("armor", false, "armor the ciphertext")
+	return func(cmd *command) error {
+		if err := cmd.checkStreaming(); err != nil {
+			return err
+		}
+		if cmd.stream && *armored {
+			return errors.New("streams can't be armored")
+		}
+		f, err := cmd.cipherFormat()
+		if err != nil {
+			return err
+		}
+		if *armored && !f.native {
+			return fmt.Errorf("%s ciphertexts can't be armored", cmd.format)
+		}
+		pub, err := cmd.publicKey()
+		if err != nil {
+			return err
+		}
+		if cmd.stream {
+			return cmd.pipe(false, func(out io.Writer, in io.Reader) error {
+				w, err := ecies.NewEncryptingWriter(pub, out)
+				if err != nil {
+					return err
+				}
+				if _, err = io.CopyBuffer(w, in, make([]byte, streamBufferSize)); err != nil {
+					return err
+				}
+				return w.Close()
+			})
+		}
+		m, err := cmd.input()
+		if err != nil {
+			return err
+		}
+		s1, s2 := cmd.shared()
+		ct, err := f.encrypt(pub, m, s1, s2)
+		if err != nil {
+			return err
+		}
+		if *armored {
+			if ct, err = ecies.Armor(pub, ct); err != nil {
+				return err
+			}
+		}
+		return cmd.output(ct, false)
+	}
+}
+
+// cipherFormat encrypts and decrypts the ciphertexts of a -format.
+type cipherFormat struct {
+	encrypt func(pub *ecies.PublicKey, m, s1, s2 []byte) ([]byte, error)
+	decrypt func(prv *ecies.PrivateKey, c, s1, s2 []byte) ([]byte, error)
+	// shared is set if the format takes -s1 and -s2.
+	shared bool
+	// native is set for the formats of the package, which can be armored.
+	native bool
+}
+
+// cipherFormats maps the names of -format to the formats. The compatibility
+// suites are added by legacy.go.
+var cipherFormats = map[string]*cipherFormat{
+	"raw": {
+		encrypt: func(pub *ecies.PublicKey, m, s1, s2 []byte) ([]byte, error) {
+			return ecies.Encrypt(rand.Reader, pub, m, s1, s2)
+		},
+		decrypt: decryptNative,
+		shared:  true,
+		native:  true,
+	},
+	"native-v1": {
+		encrypt: func(pub *ecies.PublicKey, m, s1, s2 []byte) ([]byte, error) {
+			ct, err := ecies.Encrypt(rand.Reader, pub, m, s1, s2)
+			if err != nil {
+				return nil, err
+			}
+			return ecies.EncodeEnvelope(pub, ct)
+		},
+		decrypt: decryptNative,
+		shared:  true,
+		native:  true,
+	},
+}
+
+// decryptNative decrypts the ciphertexts of the package, in any encoding
+// ciphertext reads.
+func decryptNative(prv *ecies.PrivateKey, in, s1, s2 []byte) ([]byte, error) {
+	ct, _, err := ciphertext(&prv.PublicKey, in)
+	if err != nil {
+		return nil, err
+	}
+	return ecies.Decrypt(prv, ct, s1, s2)
+}
+
+// ciphertext returns the raw or enveloped ciphertext of the input, in any
+// format DetectFormat knows, and its format. Input of unknown format is
+// taken as raw, as the raw ciphertexts of X25519 have no point format byte
+// for DetectFormat to tell.
+func ciphertext(pub *ecies.PublicKey, in []byte) ([]byte, *ecies.Format, error) {
+	f, err := ecies.DetectFormat(in)
+	if err != nil {
+		return in, ecies.FormatRaw, nil
+	}
+	switch f {
+	case ecies.FormatArmor:
+		ct, err := ecies.Unarmor(pub, in)
+		return ct, f, err
+	case ecies.FormatASN1:
+		ct, err := ecies.UnmarshalCiphertextASN1(in)
+		return ct, f, err
+	case ecies.FormatRaw, ecies.FormatEnvelope:
+		return in, f, nil
+	}
+	return nil, nil, fmt.Errorf("%s is not a ciphertext format", f.Name)
+}
+
+func decrypt(cmd *command) error {
+	if err := cmd.checkStreaming(); err != nil {
+		return err
+	}
+	f, err := cmd.cipherFormat()
+	if err != nil {
+		return err
+	}
+	prv, err := cmd.privateKey()
+	if err != nil {
+		return err
+	}
+	defer prv.Wipe()
+	if cmd.stream {
+		return cmd.pipe(true, func(out io.Writer, in io.Reader) error {
+			r, err := ecies.NewDecryptingReader(prv, in)
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyBuffer(out, r, make([]byte, streamBufferSize))
+			return err
+		})
+	}
+	in, err := cmd.input()
+	if err != nil {
+		return err
+	}
+	s1, s2 := cmd.shared()
+	m, err := f.decrypt(prv, in, s1, s2)
+	if err != nil {
+		return err
+	}
+	return cmd.output(m, true)
+}
+
+func armor(cmd *command) error {
+	pub, err := cmd.publicKey()
+	if err != nil {
+		return err
+	}
+	in, err := cmd.input()
+	if err != nil {
+		return err
+	}
+	out, err := ecies.Armor(pub, in)
+	if err != nil {
+		return err
+	}
+	return cmd.output(out, false)
+}
+
+func dearmor(cmd *command) error {
+	pub, err := cmd.publicKey()
+	if err != nil {
+		return err
+	}
+	in, err := cmd.input()
+	if err != nil {
+		return err
+	}
+	out, err := ecies.Unarmor(pub, in)
+	if err != nil {
+		return err
+	}
+	return cmd.output(out, false)
+}
+
+// isEnvelope tells whether ct is in the versioned envelope, which armored
+// and ASN.1 ciphertexts may also hold.
+func isEnvelope(ct []byte) bool {
+	f, err := ecies.DetectFormat(ct)
+	return err == nil && f == ecies.FormatEnvelope
+}
+
+// inspect describes a key, or a ciphertext without decrypting it. Only
+// envelopes carry their curve and parameters: other ciphertexts need -key.
+func inspect(cmd *command) error {
+	in, err := cmd.input()
+	if err != nil {
+		return err
+	}
+	switch f, err := ecies.DetectFormat(in); {
+	case err == nil && f.Kind == ecies.KeyFormat:
+		return inspectKey(cmd, f.Name, f, in)
+	case err != nil && isPEM(in):
+		// Standard PEM blocks, such as the PKCS #8 X25519 keys of keygen.
+		return inspectKey(cmd, "pem", nil, in)
+	}
+	if cmd.key == "" {
+		return inspectEnvelope(cmd, in)
+	}
+	pub, err := cmd.publicKey()
+	if err != nil {
+		return err
+	}
+	ct, f, err := ciphertext(pub, in)
+	if err != nil {
+		return err
+	}
+	if isEnvelope(ct) {
+		ct = ct[ecies.FormatEnvelope.Overhead:]
+	}
+	params := pub.Params
+	if params == nil {
+		params = ecies.ParamsFromCurve(pub.Curve)
+	}
+	parsed, err := ecies.ParseCiphertext(params, pub.Curve, ct)
+	if err != nil {
+		return err
+	}
+	raw := parsed.Bytes()
+	R := raw[:len(raw)-len(parsed.Body())-len(parsed.Tag())]
+	w := new(strings.Builder)
+	fmt.Fprintf(w, "format:      %s\n", f.Name)
+	fmt.Fprintf(w, "curve:       %s\n", pub.Curve.Params().Name)
+	fmt.Fprintf(w, "params:      %s\n", suite(params))
+	fmt.Fprintf(w, "size:        %d\n", len(in))
+	fmt.Fprintf(w, "body:        %d\n", len(parsed.Body()))
+	fmt.Fprintf(w, "ephemeral:   %s\n", hex.EncodeToString(R))
+	fmt.Fprintf(w, "tag:         %s\n", hex.EncodeToString(parsed.Tag()))
+	return cmd.output([]byte(w.String()), false)
+}
+
+// inspectEnvelope describes an envelope from its header.
+func inspectEnvelope(cmd *command, in []byte) error {
+	curve, params, err := ecies.EnvelopeParams(in)
+	if err != nil {
+		return errors.New("-key is required to inspect ciphertexts other than envelopes")
+	}
+	w := new(strings.Builder)
+	fmt.Fprintf(w, "format:      %s\n", ecies.FormatEnvelope.Name)
+	fmt.Fprintf(w, "version:     %d\n", ecies.FormatEnvelope.Version)
+	fmt.Fprintf(w, "curve:       %s\n", curve.Params().Name)
+	fmt.Fprintf(w, "params:      %s\n", suite(params))
+	fmt.Fprintf(w, "size:        %d\n", len(in))
+	return cmd.output([]byte(w.String()), false)
+}
+
+// inspectKey describes a public or private key in the format named name,
+// with the metadata of PEM keys. Private keys are only described by their
+// public part.
+func inspectKey(cmd *command, name string, f *ecies.Format, in []byte) error {
+	var pub *ecies.PublicKey
+	var prv *ecies.PrivateKey
+	var meta *ecies.KeyMetadata
+	var err error
+	switch f {
+	case ecies.FormatDERPublic:
+		pub, err = ecies.UnmarshalPublic(in)
+	case ecies.FormatDERPrivate:
+		prv, err = ecies.UnmarshalPrivate(in)
+	case ecies.FormatBackup:
+		prv, err = ecies.ImportPrivateBackup(strings.TrimSpace(string(in)))
+	case ecies.FormatCompactPublic:
+		pub, err = ecies.DecodePublicBech32(strings.TrimSpace(string(in)))
+	default:
+		if pub, meta, err = ecies.ImportPublicPEMWithMetadata(in); err != nil {
+			prv, meta, err = ecies.ImportPrivatePEMWithMetadata(in)
+		}
+	}
+	if prv != nil {
+		defer prv.Wipe()
+		pub = &prv.PublicKey
+	}
+	if err != nil {
+		return err
+	}
+	params := pub.Params
+	if params == nil {
+		params = ecies.ParamsFromCurve(pub.Curve)
+	}
+	fp := pub.Fingerprint()
+	w := new(strings.Builder)
+	fmt.Fprintf(w, "format:      %s\n", name)
+	fmt.Fprintf(w, "curve:       %s\n", pub.Curve.Params().Name)
+	fmt.Fprintf(w, "params:      %s\n", suite(params))
+	fmt.Fprintf(w, "fingerprint: %s\n", hex.EncodeToString(fp[:]))
+	if meta != nil {
+		if meta.Comment != "" {
+			fmt.Fprintf(w, "comment:     %s\n", meta.Comment)
+		}
+		if !meta.Created.IsZero() {
+			fmt.Fprintf(w, "created:     %s\n", meta.Created.Format(time.RFC3339))
+		}
+		if meta.KeyID != "" {
+			fmt.Fprintf(w, "key id:      %s\n", meta.KeyID)
+		}
+	}
+	return cmd.output([]byte(w.String()), false)
+}
+
+func isPEM(in []byte) bool {
+	p, _ := pem.Decode(in)
+	return p != nil
+}
+
+// suite returns the name of the parameters, or "custom" if they have none.
+func suite(params *ecies.ECIESParams) string {
+	name := "custom"
+	if params == nil {
+		return name
+	}
+	if b, err := json.Marshal(params); err == nil {
+		json.Unmarshal(b, &name)
+	}
+	return name
+}
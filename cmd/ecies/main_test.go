@@ -0,0 +1,255 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package main
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// Ensure a message goes through keygen, pubkey, encrypt, inspect and decrypt.
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key.pem")
+	pub := filepath.Join(dir, "pub.pem")
+	message := []byte("Hello, operator.")
+
+	for _, curve := range []string{"P-384", "X25519"} {
+		var out bytes.Buffer
+		steps := [][]string{
+			{"keygen", "-curve", curve, "-out", key},
+			{"pubkey", "-key", key, "-out", pub},
+		}
+		for _, args := range steps {
+			if err := run(args, nil, &out); err != nil {
+				fmt.Println(args[0], err)
+				t.FailNow()
+			}
+		}
+		for _, armored := range []bool{false, true} {
+			args := []string{"encrypt", "-key", pub, "-s1", "label"}
+			if armored {
+				args = append(args, "-armor")
+			}
+			var ct bytes.Buffer
+			if err := run(args, bytes.NewReader(message), &ct); err != nil {
+				fmt.Println("encrypt", err)
+				t.FailNow()
+			}
+			out.Reset()
+			if err := run([]string{"inspect", "-key", pub}, bytes.NewReader(ct.Bytes()), &out); err != nil ||
+				!strings.Contains(out.String(), "curve:       "+curve) {
+				fmt.Println("ecies: ciphertext not inspected", err, out.String())
+				t.FailNow()
+			}
+			out.Reset()
+			err := run([]string{"decrypt", "-key", key, "-s1", "label"}, bytes.NewReader(ct.Bytes()), &out)
+			if err != nil || !bytes.Equal(out.Bytes(), message) {
+				fmt.Println("ecies: message not decrypted", err)
+				t.FailNow()
+			}
+			if err = run([]string{"decrypt", "-key", pub}, bytes.NewReader(ct.Bytes()), &out); err == nil {
+				fmt.Println("ecies: message decrypted with a public key")
+				t.FailNow()
+			}
+		}
+	}
+	if err := run([]string{"keygen", "-curve", "P-123"}, nil, new(bytes.Buffer)); err == nil {
+		fmt.Println("ecies: key generated on an unknown curve")
+		t.FailNow()
+	}
+	if err := run([]string{"frobnicate"}, nil, new(bytes.Buffer)); err != errUsage {
+		fmt.Println("ecies: unknown command accepted", err)
+		t.FailNow()
+	}
+}
+
+// Ensure large messages stream through encrypt and decrypt with progress.
+func TestRunStream(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key.pem")
+	pub := filepath.Join(dir, "pub.pem")
+	for _, args := range [][]string{
+		{"keygen", "-curve", "P-256", "-out", key},
+		{"pubkey", "-key", key, "-out", pub},
+	} {
+		if err := run(args, nil, new(bytes.Buffer)); err != nil {
+			fmt.Println(args[0], err)
+			t.FailNow()
+		}
+	}
+	var progress bytes.Buffer
+	defer func(w io.Writer) { stderr = w }(stderr)
+	stderr = &progress
+
+	message := bytes.Repeat([]byte("stream "), 400000)
+	var ct, pt bytes.Buffer
+	if err := run([]string{"encrypt", "-key", pub, "-stream", "-progress"}, bytes.NewReader(message), &ct); err != nil {
+		fmt.Println("encrypt", err)
+		t.FailNow()
+	}
+	if err := run([]string{"decrypt", "-key", key, "-stream"}, bytes.NewReader(ct.Bytes()), &pt); err != nil ||
+		!bytes.Equal(pt.Bytes(), message) {
+		fmt.Println("decrypt", err)
+		t.FailNow()
+	}
+	if !strings.HasSuffix(progress.String(), fmt.Sprintf("\r%d bytes\n", len(message))) ||
+		!strings.Contains(progress.String(), "\r1048576 bytes") {
+		fmt.Printf("progress %q\n", progress.String())
+		t.FailNow()
+	}
+
+	ct.Bytes()[ct.Len()-1] ^= 1
+	if err := run([]string{"decrypt", "-key", key, "-stream"}, bytes.NewReader(ct.Bytes()), new(bytes.Buffer)); err == nil {
+		fmt.Println("decrypted a tampered stream")
+		t.FailNow()
+	}
+	for _, args := range [][]string{
+		{"encrypt", "-key", pub, "-stream", "-s1", "label"},
+		{"encrypt", "-key", pub, "-stream", "-armor"},
+		{"decrypt", "-key", key, "-progress"},
+	} {
+		if err := run(args, bytes.NewReader(nil), new(bytes.Buffer)); err == nil {
+			fmt.Println("accepted", args)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure keys and envelopes are inspected without a key.
+func TestInspect(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	raw, err := ecies.GenerateKey(rand.Reader, ecies.X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rawKey, err := raw.MarshalText()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var out bytes.Buffer
+	rawFp := raw.PublicKey.Fingerprint()
+	if err = run([]string{"inspect"}, bytes.NewReader(rawKey), &out); err != nil ||
+		!strings.Contains(out.String(), "curve:       X25519\n") ||
+		!strings.Contains(out.String(), "fingerprint: "+hex.EncodeToString(rawFp[:])+"\n") {
+		fmt.Println("ecies: X25519 key not inspected", err, out.String())
+		t.FailNow()
+	}
+	meta := &ecies.KeyMetadata{Comment: "backup key", Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), KeyID: "k1"}
+	key, err := ecies.ExportPrivatePEMWithMetadata(prv, meta)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub, err := ecies.ExportPublicPEM(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	fp := prv.PublicKey.Fingerprint()
+	for _, in := range [][]byte{key, pub} {
+		out.Reset()
+		if err = run([]string{"inspect"}, bytes.NewReader(in), &out); err != nil ||
+			!strings.Contains(out.String(), "curve:       P-256\n") ||
+			!strings.Contains(out.String(), "fingerprint: "+hex.EncodeToString(fp[:])+"\n") {
+			fmt.Println("ecies: key not inspected", err, out.String())
+			t.FailNow()
+		}
+	}
+	out.Reset()
+	if err = run([]string{"inspect"}, bytes.NewReader(key), &out); err != nil ||
+		!strings.Contains(out.String(), "comment:     backup key\n") ||
+		!strings.Contains(out.String(), "created:     2024-01-02T03:04:05Z\n") ||
+		!strings.Contains(out.String(), "key id:      k1\n") {
+		fmt.Println("ecies: key metadata not inspected", err, out.String())
+		t.FailNow()
+	}
+
+	ct, err := ecies.Encrypt(rand.Reader, &raw.PublicKey, []byte("Hello, operator."), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	env, err := ecies.EncodeEnvelope(&raw.PublicKey, ct)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	out.Reset()
+	if err = run([]string{"inspect"}, bytes.NewReader(env), &out); err != nil ||
+		!strings.Contains(out.String(), "format:      envelope\n") ||
+		!strings.Contains(out.String(), "curve:       X25519\n") {
+		fmt.Println("ecies: envelope not inspected", err, out.String())
+		t.FailNow()
+	}
+	if err = run([]string{"inspect"}, bytes.NewReader(ct), new(bytes.Buffer)); err == nil {
+		fmt.Println("ecies: raw ciphertext inspected without a key")
+		t.FailNow()
+	}
+}
+
+// encryptFormat generates a key on curve and encrypts message to it in
+// format, returning the key files and the ciphertext.
+func encryptFormat(t *testing.T, curve, format string, message []byte) (key, pub string, ct []byte) {
+	dir := t.TempDir()
+	key = filepath.Join(dir, "key.pem")
+	pub = filepath.Join(dir, "pub.pem")
+	for _, args := range [][]string{
+		{"keygen", "-curve", curve, "-out", key},
+		{"pubkey", "-key", key, "-out", pub},
+	} {
+		if err := run(args, nil, new(bytes.Buffer)); err != nil {
+			fmt.Println(args[0], err)
+			t.FailNow()
+		}
+	}
+	var out bytes.Buffer
+	if err := run([]string{"encrypt", "-key", pub, "-format", format}, bytes.NewReader(message), &out); err != nil {
+		fmt.Println("encrypt", format, err)
+		t.FailNow()
+	}
+	return key, pub, out.Bytes()
+}
+
+// Ensure native-v1 ciphertexts are envelopes, and that -format is checked
+// against the other flags.
+func TestRunFormat(t *testing.T) {
+	message := []byte("Hello, operator.")
+	key, pub, ct := encryptFormat(t, "P-256", "native-v1", message)
+	if f, err := ecies.DetectFormat(ct); err != nil || f != ecies.FormatEnvelope {
+		fmt.Println("ecies: native-v1 ciphertext not an envelope", err)
+		t.FailNow()
+	}
+	var out bytes.Buffer
+	if err := run([]string{"decrypt", "-key", key, "-format", "native-v1"}, bytes.NewReader(ct), &out); err != nil ||
+		!bytes.Equal(out.Bytes(), message) {
+		fmt.Println("ecies: native-v1 ciphertext not decrypted", err)
+		t.FailNow()
+	}
+	for _, args := range [][]string{
+		{"encrypt", "-key", pub, "-format", "rot13"},
+		{"encrypt", "-key", pub, "-format", "native-v1", "-stream"},
+	} {
+		if err := run(args, bytes.NewReader(message), new(bytes.Buffer)); err == nil {
+			fmt.Println("accepted", args)
+			t.FailNow()
+		}
+	}
+}
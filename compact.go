@@ -0,0 +1,83 @@
+package ecies
+
+// Compact public key encodings, for conveying recipients in QR codes or URLs.
+// Both carry the curve identifier and the compressed point, with a checksum.
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+)
+
+// The human-readable part of the Bech32m public keys.
+const publicHRP = "eciespk"
+
+// compactChecksumLen is the length of the SHA-256 based checksum appended
+// to the base64url encoded public keys.
+const compactChecksumLen = 4
+
+func compactPublic(pub *PublicKey) ([]byte, error) {
+	id, ok := curveIDs[pub.Curve]
+	if !ok || pub.X == nil || pub.Y == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return append([]byte{id}, CompressPublicKey(pub)...), nil
+}
+
+func parseCompactPublic(data []byte) (*PublicKey, error) {
+	if len(data) < 1 {
+		return nil, ErrInvalidPublicKey
+	}
+	curve := curveFromID(data[0])
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	return DecompressPublicKey(curve, data[1:])
+}
+
+// EncodePublicBech32 encodes a public key as a Bech32m string, e.g. "eciespk1...".
+// The lowercase string can be uppercased to use the QR code alphanumeric mode.
+func EncodePublicBech32(pub *PublicKey) (string, error) {
+	data, err := compactPublic(pub)
+	if err != nil {
+		return "", err
+	}
+	return bech32Encode(publicHRP, data, bech32mConst), nil
+}
+
+// DecodePublicBech32 decodes a public key encoded with EncodePublicBech32.
+func DecodePublicBech32(s string) (*PublicKey, error) {
+	hrp, data, err := bech32Decode(s, bech32mConst)
+	if err != nil {
+		return nil, err
+	} else if hrp != publicHRP {
+		return nil, ErrInvalidPublicKey
+	}
+	return parseCompactPublic(data)
+}
+
+// EncodePublicBase64 encodes a public key as an unpadded base64url string,
+// which is safe to use in URLs.
+func EncodePublicBase64(pub *PublicKey) (string, error) {
+	data, err := compactPublic(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(append(data, sum[:compactChecksumLen]...)), nil
+}
+
+// DecodePublicBase64 decodes a public key encoded with EncodePublicBase64.
+func DecodePublicBase64(s string) (*PublicKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(data) <= compactChecksumLen {
+		return nil, ErrInvalidPublicKey
+	}
+	data, checksum := data[:len(data)-compactChecksumLen], data[len(data)-compactChecksumLen:]
+	sum := sha256.Sum256(data)
+	if subtle.ConstantTimeCompare(sum[:compactChecksumLen], checksum) != 1 {
+		return nil, ErrInvalidPublicKey
+	}
+	return parseCompactPublic(data)
+}
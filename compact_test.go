@@ -0,0 +1,69 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure that public keys survive the compact encodings round trip, and
+// that corruption is detected.
+func TestCompactPublic(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		b32, err := EncodePublicBech32(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !strings.HasPrefix(b32, "eciespk1") {
+			fmt.Println(name, "ecies: unexpected bech32 prefix", b32)
+			t.FailNow()
+		}
+		for _, s := range []string{b32, strings.ToUpper(b32)} {
+			pub, err := DecodePublicBech32(s)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if !cmpPublic(prv.PublicKey, *pub) {
+				fmt.Println(name, "ecies: bech32 public key doesn't match")
+				t.FailNow()
+			}
+		}
+
+		b64, err := EncodePublicBase64(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pub, err := DecodePublicBase64(b64)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println(name, "ecies: base64 public key doesn't match")
+			t.FailNow()
+		}
+
+		corrupt := []byte(b64)
+		if corrupt[5] == 'A' {
+			corrupt[5] = 'B'
+		} else {
+			corrupt[5] = 'A'
+		}
+		if _, err := DecodePublicBase64(string(corrupt)); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: decoded a corrupted base64 public key")
+			t.FailNow()
+		}
+		if _, err := DecodePublicBech32(b64); err == nil {
+			fmt.Println(name, "ecies: decoded base64 as bech32")
+			t.FailNow()
+		}
+	}
+}
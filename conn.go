@@ -0,0 +1,332 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Encrypted connections: a one-round-trip handshake in which the client
+// encapsulates a shared secret to the static public key of the server, then
+// records sealed with directional AEAD keys. Only the server is
+// authenticated, by its knowledge of the private key; clients authenticate,
+// if need be, within the connection.
+//
+// The client sends the encapsulation, in a frame of WriteMessage carrying
+// the suite identifier of the server key; the server replies with 32 random
+// bytes, so that replayed handshakes derive other keys. The keys are derived
+// from the shared secret with the KDF of the parameters and the label,
+// encapsulation and server random as shared information: the client to
+// server key, then the server to client key.
+//
+// Each record is a frame holding the AEAD sealing of a type byte and up to
+// connRecordSize bytes of data, with the record sequence number as the
+// nonce. A close record ends the stream, so that truncations are detected.
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrConnClosed    = newError(KindState, "ecies: connection closed")
+	ErrHandshake     = newError(KindEncoding, "ecies: invalid connection handshake")
+	ErrConnTruncated = newError(KindAuthentication, "ecies: connection closed without a close record")
+)
+
+const (
+	connLabel      = "go-ecies conn v1"
+	connRandomSize = 32
+	connRecordSize = 16 * 1024
+)
+
+// Record types.
+const (
+	recordData  = 0
+	recordClose = 1
+)
+
+// SecureConn is a net.Conn encrypting its traffic for the static key of the
+// server. The handshake runs on the first Read or Write, or on Handshake.
+// Read and Write may be called concurrently with each other.
+type SecureConn struct {
+	net.Conn
+	pub *PublicKey
+	prv KeyProvider
+
+	handshakeMu  sync.Mutex
+	handshakeErr error
+	handshaked   bool
+	// handshakeDone is set once the handshake succeeded, so that Close
+	// needn't wait for a handshake blocked on the network.
+	handshakeDone atomic.Bool
+
+	in  halfConn
+	out halfConn
+}
+
+// halfConn is one direction of a connection.
+type halfConn struct {
+	sync.Mutex
+	aead  cipher.AEAD
+	nonce []byte
+	seq   uint64
+	buf   []byte // decrypted data not read yet
+	err   error
+}
+
+// SecureClient returns a connection to the server holding the private key
+// of pub, over conn.
+func SecureClient(conn net.Conn, pub *PublicKey) *SecureConn {
+	return &SecureConn{Conn: conn, pub: pub}
+}
+
+// SecureServer returns the server side of a connection over conn, for
+// clients knowing the public key of prv.
+func SecureServer(conn net.Conn, prv KeyProvider) *SecureConn {
+	return &SecureConn{Conn: conn, prv: prv}
+}
+
+// Handshake runs the handshake if it hasn't run yet.
+func (c *SecureConn) Handshake() error {
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+	if !c.handshaked {
+		c.handshaked = true
+		if c.prv != nil {
+			c.handshakeErr = c.serverHandshake()
+		} else {
+			c.handshakeErr = c.clientHandshake()
+		}
+		c.handshakeDone.Store(c.handshakeErr == nil)
+	}
+	return c.handshakeErr
+}
+
+func connParams(pub *PublicKey) (*ECIESParams, byte, error) {
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, 0, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, 0, err
+	}
+	suite, ok := suiteID(params)
+	if !ok {
+		return nil, 0, ErrUnsupportedECIESParameters
+	}
+	return params, suite, nil
+}
+
+func (c *SecureConn) clientHandshake() error {
+	if err := ValidatePublicKey(c.pub); err != nil {
+		return err
+	}
+	params, suite, err := connParams(c.pub)
+	if err != nil {
+		return err
+	}
+	z, enc, err := encapsulate(entropy(nil), c.pub, params, false)
+	if err != nil {
+		return err
+	}
+	defer wipe(z)
+	if err = WriteMessage(c.Conn, suite, enc); err != nil {
+		return err
+	}
+	_, random, err := ReadMessage(c.Conn, connRandomSize)
+	if err != nil {
+		return err
+	}
+	if len(random) != connRandomSize {
+		return ErrHandshake
+	}
+	return c.setKeys(params, z, enc, random, true)
+}
+
+func (c *SecureConn) serverHandshake() error {
+	pub := c.prv.Public()
+	params, suite, err := connParams(pub)
+	if err != nil {
+		return err
+	}
+	got, enc, err := ReadMessage(c.Conn, 1+pointSize(pub.Curve, pointUncompressed, AllowAllPoints))
+	if err != nil {
+		return err
+	}
+	if got != suite {
+		return ErrUnsupportedECIESParameters
+	}
+	R, err := parseEncapsulation(pub, enc, AllowCompressedPoints)
+	if err != nil {
+		return err
+	}
+	z, err := c.prv.GenerateShared(R)
+	if err != nil {
+		return err
+	}
+	defer wipe(z)
+	random := make([]byte, connRandomSize)
+	if _, err = io.ReadFull(entropy(nil), random); err != nil {
+		return err
+	}
+	if err = WriteMessage(c.Conn, 0, random); err != nil {
+		return err
+	}
+	return c.setKeys(params, z, enc, random, false)
+}
+
+// setKeys derives the keys of both directions.
+func (c *SecureConn) setKeys(params *ECIESParams, z, enc, random []byte, client bool) error {
+	info := make([]byte, 0, len(connLabel)+len(enc)+len(random))
+	info = append(append(append(info, connLabel...), enc...), random...)
+	keys, err := params.deriveKeys(z, info, 2*params.KeyLen)
+	if err != nil {
+		return err
+	}
+	defer wipe(keys)
+	c2s, err := connAEAD(params, keys[:params.KeyLen])
+	if err != nil {
+		return err
+	}
+	s2c, err := connAEAD(params, keys[params.KeyLen:])
+	if err != nil {
+		return err
+	}
+	if client {
+		c.out.aead, c.in.aead = c2s, s2c
+	} else {
+		c.out.aead, c.in.aead = s2c, c2s
+	}
+	c.out.nonce = make([]byte, c.out.aead.NonceSize())
+	c.in.nonce = make([]byte, c.in.aead.NonceSize())
+	return nil
+}
+
+// connAEAD returns the AEAD of params, or AES-GCM with its key size.
+func connAEAD(params *ECIESParams, key []byte) (cipher.AEAD, error) {
+	if params.AEAD != nil {
+		return params.AEAD(key)
+	}
+	return newAESGCM(key)
+}
+
+// next sets the nonce of the next record, and returns it.
+func (h *halfConn) next() ([]byte, error) {
+	if h.seq == 1<<64-1 {
+		return nil, ErrStreamTooLarge
+	}
+	binary.BigEndian.PutUint64(h.nonce[len(h.nonce)-8:], h.seq)
+	h.seq++
+	return h.nonce, nil
+}
+
+// writeRecord seals and sends a record. The caller holds c.out.
+func (c *SecureConn) writeRecord(typ byte, data []byte) error {
+	if c.out.err != nil {
+		return c.out.err
+	}
+	nonce, err := c.out.next()
+	if err != nil {
+		return err
+	}
+	record := make([]byte, 0, 1+len(data)+c.out.aead.Overhead())
+	record = append(append(record, typ), data...)
+	record = c.out.aead.Seal(record[:0], nonce, record, nil)
+	if err = WriteMessage(c.Conn, 0, record); err != nil {
+		c.out.err = err
+	}
+	return err
+}
+
+// Write encrypts p and sends it in one or more records.
+func (c *SecureConn) Write(p []byte) (n int, err error) {
+	if err = c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	for len(p) > 0 {
+		k := min(len(p), connRecordSize)
+		if err = c.writeRecord(recordData, p[:k]); err != nil {
+			return n, err
+		}
+		n += k
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Read reads and decrypts data sent by the peer. It returns io.EOF once the
+// peer closed the connection, and ErrConnTruncated if the connection ended
+// without the peer closing it.
+func (c *SecureConn) Read(p []byte) (n int, err error) {
+	if err = c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.in.Lock()
+	defer c.in.Unlock()
+	for len(c.in.buf) == 0 {
+		if c.in.err != nil {
+			return 0, c.in.err
+		}
+		c.in.err = c.readRecord()
+	}
+	n = copy(p, c.in.buf)
+	c.in.buf = c.in.buf[n:]
+	return n, nil
+}
+
+// readRecord reads and opens a record. The caller holds c.in.
+func (c *SecureConn) readRecord() error {
+	_, record, err := ReadMessage(c.Conn, 1+connRecordSize+c.in.aead.Overhead())
+	if err == io.EOF {
+		return ErrConnTruncated
+	} else if err != nil {
+		return err
+	}
+	nonce, err := c.in.next()
+	if err != nil {
+		return err
+	}
+	data, err := c.in.aead.Open(record[:0], nonce, record, nil)
+	if err != nil || len(data) == 0 {
+		return ErrInvalidMessage
+	}
+	switch data[0] {
+	case recordData:
+		c.in.buf = data[1:]
+		return nil
+	case recordClose:
+		return io.EOF
+	}
+	return ErrInvalidMessage
+}
+
+// CloseWrite sends a close record, after which the peer reads io.EOF, but
+// leaves the connection open for reading. Further writes fail.
+func (c *SecureConn) CloseWrite() error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.out.err == ErrConnClosed {
+		return nil
+	}
+	err := c.writeRecord(recordClose, nil)
+	c.out.err = ErrConnClosed
+	return err
+}
+
+// Close sends a close record if the handshake completed, then closes the
+// underlying connection, which unblocks a handshake in progress.
+func (c *SecureConn) Close() error {
+	if c.handshakeDone.Load() {
+		c.CloseWrite()
+	}
+	return c.Conn.Close()
+}
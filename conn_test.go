@@ -0,0 +1,119 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// Ensure data goes both ways over a SecureConn, and that the server key is
+// required.
+func TestSecureConn(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		a, b := net.Pipe()
+		client, server := SecureClient(a, &prv.PublicKey), SecureServer(b, prv)
+		request := bytes.Repeat([]byte("ping"), connRecordSize)
+		errc := make(chan error, 1)
+		go func() {
+			got, err := io.ReadAll(server)
+			if err == nil && !bytes.Equal(got, request) {
+				err = errors.New("request corrupted")
+			}
+			if err == nil {
+				_, err = server.Write([]byte("pong"))
+			}
+			server.Close()
+			errc <- err
+		}()
+		if _, err = client.Write(request); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if err = client.CloseWrite(); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		reply, err := io.ReadAll(client)
+		if err != nil || string(reply) != "pong" {
+			fmt.Println("ecies: reply not received", err)
+			t.FailNow()
+		}
+		if err = <-errc; err != nil {
+			fmt.Println("ecies: request not received", err)
+			t.FailNow()
+		}
+		client.Close()
+	}
+
+	prv, _ := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	other, _ := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	a, b := net.Pipe()
+	done := make(chan struct{})
+	go func(client *SecureConn) {
+		client.Write([]byte("hello"))
+		client.Close()
+		close(done)
+	}(SecureClient(a, &other.PublicKey))
+	if _, err := io.ReadAll(SecureServer(b, prv)); err != ErrInvalidMessage {
+		fmt.Println("ecies: connection to another key accepted", err)
+		t.FailNow()
+	}
+	b.Close()
+	<-done
+
+	a, b = net.Pipe()
+	done = make(chan struct{})
+	go func(client *SecureConn, a net.Conn) {
+		client.Write([]byte("hello"))
+		a.Close()
+		close(done)
+	}(SecureClient(a, &prv.PublicKey), a)
+	server := SecureServer(b, prv)
+	if _, err := io.ReadAll(server); err != ErrConnTruncated {
+		fmt.Println("ecies: truncated connection accepted", err)
+		t.FailNow()
+	}
+	server.Close()
+	<-done
+}
+
+// Ensure Close unblocks a handshake stalled on a silent peer.
+func TestSecureConnCloseHandshake(t *testing.T) {
+	prv, _ := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	a, b := net.Pipe()
+	defer a.Close()
+	server := SecureServer(b, prv)
+	errc := make(chan error, 1)
+	go func() { errc <- server.Handshake() }()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		fmt.Println("ecies: Close blocked by the handshake")
+		t.FailNow()
+	}
+	if err := <-errc; err == nil {
+		fmt.Println("ecies: handshake succeeded on a closed connection")
+		t.FailNow()
+	}
+}
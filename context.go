@@ -0,0 +1,44 @@
+package ecies
+
+import "context"
+
+// ContextKeyProvider is a KeyProvider whose key agreement may be a remote
+// call, e.g. to a KMS or an HSM server, which can be cancelled or given a
+// deadline through the context.
+type ContextKeyProvider interface {
+	KeyProvider
+	GenerateSharedContext(ctx context.Context, pub *PublicKey) ([]byte, error)
+}
+
+// contextProvider binds a context to the key agreement of a provider.
+type contextProvider struct {
+	ctx context.Context
+	prv KeyProvider
+}
+
+func (p contextProvider) Public() *PublicKey {
+	return p.prv.Public()
+}
+
+func (p contextProvider) GenerateShared(pub *PublicKey) ([]byte, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cp, ok := p.prv.(ContextKeyProvider); ok {
+		return cp.GenerateSharedContext(p.ctx, pub)
+	}
+	return p.prv.GenerateShared(pub)
+}
+
+// WithContext returns a KeyProvider calling GenerateSharedContext of prv with
+// ctx if prv implements ContextKeyProvider, and failing with the error of ctx
+// once it is done.
+func WithContext(ctx context.Context, prv KeyProvider) KeyProvider {
+	return contextProvider{ctx: ctx, prv: prv}
+}
+
+// DecryptContext decrypts an ECIES ciphertext like Decrypt, passing ctx to
+// the key agreement of prv if it implements ContextKeyProvider.
+func DecryptContext(ctx context.Context, prv KeyProvider, c, s1, s2 []byte) ([]byte, error) {
+	return DecryptWithOptions(WithContext(ctx, prv), c, s1, s2, nil)
+}
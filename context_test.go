@@ -0,0 +1,65 @@
+package ecies
+
+import (
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// remoteKey is a ContextKeyProvider recording the context it is called with.
+type remoteKey struct {
+	*PrivateKey
+	ctx context.Context
+}
+
+func (k *remoteKey) GenerateSharedContext(ctx context.Context, pub *PublicKey) ([]byte, error) {
+	k.ctx = ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return k.PrivateKey.GenerateShared(pub)
+}
+
+// Ensure DecryptContext passes the context to context-aware providers, and
+// stops once the context is done.
+func TestDecryptContext(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	remote := &remoteKey{PrivateKey: prv}
+	m, err := DecryptContext(ctx, remote, ct, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if string(m) != "message" || remote.ctx == nil || remote.ctx.Value(ctxKey{}) != "value" {
+		fmt.Println("ecies: context not passed to the provider")
+		t.FailNow()
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = DecryptContext(cancelled, remote, ct, nil, nil); err != context.Canceled {
+		fmt.Println("ecies: decrypted with a cancelled context", err)
+		t.FailNow()
+	}
+	if _, err = DecryptContext(cancelled, prv, ct, nil, nil); err != context.Canceled {
+		fmt.Println("ecies: decrypted with a cancelled context", err)
+		t.FailNow()
+	}
+	if m, err = DecryptContext(context.Background(), prv, ct, nil, nil); err != nil || string(m) != "message" {
+		fmt.Println("ecies: plain provider failed", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,163 @@
+package ecies
+
+// Datagram mode: one ciphertext per packet, with sequence numbers and a
+// sliding replay window, e.g. for encrypted UDP telemetry.
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrReplay              = newError(KindValidity, "ecies: replayed message")
+	ErrSequenceExhausted   = newError(KindState, "ecies: datagram sequence numbers exhausted")
+	ErrInvalidDatagramSize = newError(KindEncoding, "ecies: datagram too short")
+)
+
+// DatagramReplayWindow is the number of sequence numbers below the highest
+// one received that are still accepted, if not received yet.
+const DatagramReplayWindow = 64
+
+// A datagram is the sender ID and sequence number, both 64-bit big-endian,
+// followed by the ciphertext. The header is the MAC shared information, so it
+// can not be changed.
+const datagramHeaderSize = 16
+
+// DatagramSender seals messages into datagrams for a receiver. It is safe for
+// concurrent use.
+type DatagramSender struct {
+	pub  *PublicKey
+	id   uint64
+	next uint64 // atomic
+}
+
+// NewDatagramSender returns a sender to pub. The sender ID tells senders
+// apart on the receiver, which keeps a replay window per sender. The
+// sequence numbers start at next: a sender reusing its ID after a restart
+// must resume from the value of Next saved before, or its datagrams will be
+// rejected as replays.
+func NewDatagramSender(pub *PublicKey, senderID, next uint64) *DatagramSender {
+	return &DatagramSender{pub: pub, id: senderID, next: next}
+}
+
+// Next returns the sequence number of the next datagram.
+func (s *DatagramSender) Next() uint64 {
+	return atomic.LoadUint64(&s.next)
+}
+
+// Seal encrypts m into a datagram.
+func (s *DatagramSender) Seal(rand io.Reader, m []byte) ([]byte, error) {
+	var seq uint64
+	for {
+		seq = atomic.LoadUint64(&s.next)
+		if seq == math.MaxUint64 {
+			return nil, ErrSequenceExhausted
+		}
+		if atomic.CompareAndSwapUint64(&s.next, seq, seq+1) {
+			break
+		}
+	}
+	header := make([]byte, datagramHeaderSize)
+	binary.BigEndian.PutUint64(header, s.id)
+	binary.BigEndian.PutUint64(header[8:], seq)
+	c, err := Encrypt(rand, s.pub, m, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, c...), nil
+}
+
+type replayWindow struct {
+	top    uint64
+	bitmap uint64
+}
+
+func (w *replayWindow) accepts(seq uint64) bool {
+	if seq > w.top {
+		return true
+	}
+	diff := w.top - seq
+	return diff < DatagramReplayWindow && w.bitmap&(1<<diff) == 0
+}
+
+func (w *replayWindow) mark(seq uint64) {
+	if seq > w.top {
+		if shift := seq - w.top; shift < DatagramReplayWindow {
+			w.bitmap <<= shift
+		} else {
+			w.bitmap = 0
+		}
+		w.top = seq
+	}
+	w.bitmap |= 1 << (w.top - seq)
+}
+
+// DatagramReceiver opens datagrams sealed by DatagramSenders, rejecting
+// replays. It is safe for concurrent use.
+type DatagramReceiver struct {
+	prv KeyProvider
+
+	mu      sync.Mutex
+	windows map[uint64]*replayWindow
+}
+
+// NewDatagramReceiver returns a receiver decrypting with prv.
+func NewDatagramReceiver(prv KeyProvider) *DatagramReceiver {
+	return &DatagramReceiver{prv: prv, windows: make(map[uint64]*replayWindow)}
+}
+
+func (r *DatagramReceiver) accepts(id, seq uint64) bool {
+	w, ok := r.windows[id]
+	return !ok || w.accepts(seq)
+}
+
+// Open decrypts a datagram, returning its sender ID, sequence number and
+// message. Datagrams already received, or too far behind the latest one of
+// their sender, are rejected with ErrReplay.
+func (r *DatagramReceiver) Open(packet []byte) (senderID, seq uint64, m []byte, err error) {
+	if len(packet) < datagramHeaderSize {
+		err = ErrInvalidDatagramSize
+		return
+	}
+	header := packet[:datagramHeaderSize]
+	senderID = binary.BigEndian.Uint64(header)
+	seq = binary.BigEndian.Uint64(header[8:])
+
+	r.mu.Lock()
+	ok := r.accepts(senderID, seq)
+	r.mu.Unlock()
+	if !ok {
+		err = ErrReplay
+		return
+	}
+
+	m, err = Decrypt(r.prv, packet[datagramHeaderSize:], nil, header)
+	if err != nil {
+		return
+	}
+
+	// Only authentic datagrams move the window, checking again in case the
+	// same one was opened concurrently.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.accepts(senderID, seq) {
+		return 0, 0, nil, ErrReplay
+	}
+	w, ok := r.windows[senderID]
+	if !ok {
+		w = &replayWindow{top: seq}
+		r.windows[senderID] = w
+	}
+	w.mark(seq)
+	return
+}
+
+// Forget drops the replay window of a sender, e.g. once it is decommissioned.
+func (r *DatagramReceiver) Forget(senderID uint64) {
+	r.mu.Lock()
+	delete(r.windows, senderID)
+	r.mu.Unlock()
+}
@@ -0,0 +1,68 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure datagrams are accepted once, in any order within the window.
+func TestDatagram(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	sender := NewDatagramSender(&prv.PublicKey, 7, 0)
+	receiver := NewDatagramReceiver(prv)
+
+	var packets [][]byte
+	for i := 0; i < 100; i++ {
+		p, err := sender.Seal(rand.Reader, []byte(fmt.Sprint("reading ", i)))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		packets = append(packets, p)
+	}
+	if sender.Next() != 100 {
+		fmt.Println("ecies: unexpected next sequence number", sender.Next())
+		t.FailNow()
+	}
+
+	for _, i := range []int{10, 5, 11, 99, 40} {
+		id, seq, m, err := receiver.Open(packets[i])
+		if err != nil || id != 7 || seq != uint64(i) || !bytes.Equal(m, []byte(fmt.Sprint("reading ", i))) {
+			fmt.Println("ecies: failed to open datagram", i, err)
+			t.FailNow()
+		}
+	}
+	// Replays, and datagrams which fell out of the window.
+	for _, i := range []int{10, 99, 40, 35, 0} {
+		if _, _, _, err = receiver.Open(packets[i]); err != ErrReplay {
+			fmt.Println("ecies: accepted replayed datagram", i, err)
+			t.FailNow()
+		}
+	}
+
+	// The header is authenticated.
+	forged := append([]byte(nil), packets[97]...)
+	forged[15] ^= 1
+	if _, _, _, err = receiver.Open(forged); err != ErrInvalidMessage {
+		fmt.Println("ecies: accepted forged sequence number", err)
+		t.FailNow()
+	}
+	if _, _, _, err = receiver.Open(packets[97]); err != nil {
+		fmt.Println("ecies: forged datagram moved the window", err)
+		t.FailNow()
+	}
+
+	// Other senders have their own window.
+	other := NewDatagramSender(&prv.PublicKey, 8, 0)
+	p, _ := other.Seal(rand.Reader, []byte("hello"))
+	if _, _, _, err = receiver.Open(p); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,146 @@
+package ecies
+
+// Data encapsulation with an AEAD, in place of the SEC 1 block cipher in CTR
+// mode followed by an HMAC tag.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"io"
+)
+
+// demAlgorithm identifies the data encapsulation mechanism of the standard
+// parameter sets, for the suite identifiers and ASN.1 encodings.
+type demAlgorithm int
+
+const (
+	demCTR demAlgorithm = iota
+	demAESGCM
+	demChaCha20Poly1305
+	demXChaCha20Poly1305
+	// demAESCBC is AES-CBC with a zero IV and PKCS#7 padding, the SC1 of
+	// ISO 18033-2, followed by the tag.
+	demAESCBC
+)
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// derivedKeyLen returns the length of the keys derived for each message: the
+// encryption key, followed by the MAC key unless the DEM is an AEAD.
+func (params *ECIESParams) derivedKeyLen() int {
+	if params.AEAD != nil {
+		return params.KeyLen
+	}
+	return 2 * params.KeyLen
+}
+
+// demOverhead returns the size of the IV, or nonce, prepended to the
+// encrypted message and of the tag following it.
+func (params *ECIESParams) demOverhead() (ivSize, tagSize int) {
+	if params.dem == demAESCBC {
+		return 0, params.macSize()
+	}
+	if params.AEAD == nil {
+		return params.BlockSize, params.macSize()
+	}
+	aead, err := params.AEAD(make([]byte, params.KeyLen))
+	if err != nil {
+		return 0, 0
+	}
+	return aead.NonceSize(), aead.Overhead()
+}
+
+// demMaxOverhead bounds the bytes added to a message by the DEMs: an IV or
+// nonce of up to 24 bytes, the CBC padding, and a tag of up to 64 bytes.
+const demMaxOverhead = 24 + 16 + 64
+
+// aeadSeal encrypts m with a random nonce, which is prepended.
+func aeadSeal(rand io.Reader, params *ECIESParams, key, m, ad []byte) ([]byte, error) {
+	return appendAEADSeal(nil, rand, params, key, m, ad)
+}
+
+// appendAEADSeal is aeadSeal, appending the nonce and sealed message to dst
+// rather than to a new slice.
+func appendAEADSeal(dst []byte, rand io.Reader, params *ECIESParams, key, m, ad []byte) ([]byte, error) {
+	aead, err := params.AEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	n := len(dst)
+	dst = grow(dst, aead.NonceSize())
+	if cap(dst)-len(dst) < len(m)+aead.Overhead() {
+		dst = append(make([]byte, 0, len(dst)+len(m)+aead.Overhead()), dst...)
+	}
+	nonce := dst[n:]
+	if _, err = io.ReadFull(rand, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(dst, nonce, m, ad), nil
+}
+
+// aeadOpen decrypts and authenticates the output of aeadSeal.
+func aeadOpen(params *ECIESParams, key, em, ad []byte) ([]byte, error) {
+	return appendAEADOpen(nil, params, key, em, ad)
+}
+
+// appendAEADOpen is aeadOpen, appending the message to dst rather than to a
+// new slice.
+func appendAEADOpen(dst []byte, params *ECIESParams, key, em, ad []byte) ([]byte, error) {
+	aead, err := params.AEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(em) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidMessage
+	}
+	m, err := aead.Open(dst, em[:aead.NonceSize()], em[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
+
+// demSeal encrypts m with the keys K derived for a message, returning the
+// encrypted message followed by its tag, if any.
+func demSeal(rand io.Reader, params *ECIESParams, K, m, s2 []byte) ([]byte, error) {
+	if params.AEAD != nil {
+		return aeadSeal(rand, params, K, m, s2)
+	}
+	Ke := K[:params.KeyLen]
+	Km := macKey(params, K[params.KeyLen:])
+
+	em, err := symEncrypt(rand, params, Ke, m)
+	if err != nil {
+		return nil, err
+	}
+	if params.dem != demAESCBC && len(em) <= params.BlockSize {
+		return nil, ErrInvalidMessage
+	}
+	return append(em, messageTag(params, Km, em, s2)...), nil
+}
+
+// demOpen authenticates and decrypts the output of demSeal.
+func demOpen(params *ECIESParams, K, c, s2 []byte) ([]byte, error) {
+	if params.AEAD != nil {
+		return aeadOpen(params, K, c, s2)
+	}
+	ivLen, tagLen := params.demOverhead()
+	if len(c) < ivLen+tagLen+1 {
+		return nil, ErrInvalidMessage
+	}
+	Ke := K[:params.KeyLen]
+	Km := macKey(params, K[params.KeyLen:])
+
+	em := c[:len(c)-tagLen]
+	if subtle.ConstantTimeCompare(c[len(em):], messageTag(params, Km, em, s2)) != 1 {
+		return nil, ErrInvalidMessage
+	}
+	return symDecrypt(params, Ke, em)
+}
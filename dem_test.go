@@ -0,0 +1,302 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Ensure messages round trip with the AES-GCM parameters, which survive a
+// DER round trip of the public key.
+func TestAESGCM(t *testing.T) {
+	for c, params := range map[elliptic.Curve]*ECIESParams{
+		elliptic.P256(): ECIES_AES128_GCM_SHA256,
+		elliptic.P384(): ECIES_AES192_GCM_SHA384,
+		elliptic.P521(): ECIES_AES256_GCM_SHA512,
+	} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, params)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		for _, message := range [][]byte{[]byte("Hello, world."), {}} {
+			ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, []byte("s2"))
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			if len(ct) != len(CompressPublicKey(&prv.PublicKey))*2-1+12+len(message)+16 {
+				fmt.Println(name, "ecies: unexpected AES-GCM ciphertext size", len(ct))
+				t.FailNow()
+			}
+			pt, err := Decrypt(prv, ct, nil, []byte("s2"))
+			if err != nil || !bytes.Equal(pt, message) {
+				fmt.Println(name, "ecies: AES-GCM message not decrypted", err)
+				t.FailNow()
+			}
+			if _, err = Decrypt(prv, ct, nil, []byte("s3")); err != ErrInvalidMessage {
+				fmt.Println(name, "ecies: AES-GCM additional data not authenticated", err)
+				t.FailNow()
+			}
+			ct[len(ct)-1] ^= 1
+			if _, err = Decrypt(prv, ct, nil, []byte("s2")); err != ErrInvalidMessage {
+				fmt.Println(name, "ecies: tampered AES-GCM message accepted", err)
+				t.FailNow()
+			}
+		}
+
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublic(der)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		want, _ := suiteID(params)
+		if id, ok := suiteID(pub.Params); !ok || id != want {
+			fmt.Println(name, "ecies: AES-GCM parameters lost in DER", id)
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, pub, []byte("Hello, world."), nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		env, err := EncodeEnvelope(pub, ct)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if _, err = Decrypt(prv, env, nil, nil); err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure ChaCha20-Poly1305 ciphertexts decrypt against an independently
+// computed vector, and round trip with each hash.
+func TestChaCha20Poly1305(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	prv, err := NewPrivateKey(elliptic.P256(), hexBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_CHACHA20POLY1305_SHA256
+	ct := hexBytes("04e266ddfdc12668db30d4ca3e8f7749432c416044f2d2b8c10bf3d4012aeffa8abfa86404a2e9ffe67d47c587ef7a97a7f456b863b4d02cfc6928973ab5b1cb39" +
+		"000102030405060708090a0b" +
+		"2a3fd99a6f34d4a65dced45b964cad5c7cc4e1fe9a93a9f35ba0d8d0e83534746bfd252c7299341dd9")
+	pt, err := Decrypt(prv, ct, []byte("s1"), []byte("s2"))
+	if err != nil || string(pt) != "Hello, ChaCha20-Poly1305." {
+		fmt.Println("ecies: ChaCha20-Poly1305 vector not decrypted", err)
+		t.FailNow()
+	}
+	if _, err = Decrypt(prv, ct, []byte("s1"), nil); err != ErrInvalidMessage {
+		fmt.Println("ecies: ChaCha20-Poly1305 additional data not authenticated", err)
+		t.FailNow()
+	}
+
+	for c, params := range map[elliptic.Curve]*ECIESParams{
+		elliptic.P256(): ECIES_CHACHA20POLY1305_SHA256,
+		elliptic.P384(): ECIES_CHACHA20POLY1305_SHA384,
+		elliptic.P521(): ECIES_CHACHA20POLY1305_SHA512,
+	} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, params)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublic(der)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		want, _ := suiteID(params)
+		if id, ok := suiteID(pub.Params); !ok || id != want {
+			fmt.Println(name, "ecies: ChaCha20-Poly1305 parameters lost in DER", id)
+			t.FailNow()
+		}
+		message := []byte("Hello, world.")
+		ct, err := Encrypt(rand.Reader, pub, message, nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if len(ct) != len(CompressPublicKey(pub))*2-1+chacha20poly1305.NonceSize+len(message)+chacha20poly1305.Overhead {
+			fmt.Println(name, "ecies: unexpected ChaCha20-Poly1305 ciphertext size", len(ct))
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: ChaCha20-Poly1305 message not decrypted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure XChaCha20-Poly1305 ciphertexts carry the 24-byte nonce, and that the
+// parameters have a suite identifier but no DER encoding.
+func TestXChaCha20Poly1305(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), ECIES_XCHACHA20POLY1305_SHA256)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, []byte("s2"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if len(ct) != 65+chacha20poly1305.NonceSizeX+len(message)+chacha20poly1305.Overhead {
+		fmt.Println("ecies: unexpected XChaCha20-Poly1305 ciphertext size", len(ct))
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, ct, nil, []byte("s2")); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: XChaCha20-Poly1305 message not decrypted", err)
+		t.FailNow()
+	}
+	ct[65] ^= 1
+	if _, err = Decrypt(prv, ct, nil, []byte("s2")); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered XChaCha20-Poly1305 nonce accepted", err)
+		t.FailNow()
+	}
+
+	if _, err = MarshalPublic(&prv.PublicKey); err != ErrUnsupportedECIESParameters {
+		fmt.Println("ecies: XChaCha20-Poly1305 parameters encoded in DER", err)
+		t.FailNow()
+	}
+	ct, err = Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	env, err := EncodeEnvelope(&prv.PublicKey, ct)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, env, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: XChaCha20-Poly1305 envelope not decrypted", err)
+		t.FailNow()
+	}
+}
+
+// Ensure messages round trip with the ISO 18033-2 parameters, including empty
+// ones, that the label is authenticated, and that the parameters have no DER
+// encoding.
+func TestISO18033(t *testing.T) {
+	for c, params := range map[elliptic.Curve]*ECIESParams{
+		elliptic.P256(): ECIES_AES128_ISO18033_SHA256,
+		elliptic.P521(): ECIES_AES256_ISO18033_SHA512,
+	} {
+		prv, err := GenerateKey(rand.Reader, c, params)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, message := range [][]byte{nil, []byte("Hello, world."), make([]byte, 32)} {
+			ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, []byte("label"))
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			rLen := 1 + 2*((c.Params().BitSize+7)/8)
+			if len(ct) != rLen+(len(message)/16+1)*16+params.Hash().Size() {
+				fmt.Println("ecies: unexpected ISO 18033-2 ciphertext size", len(ct))
+				t.FailNow()
+			}
+			if pt, err := Decrypt(prv, ct, nil, []byte("label")); err != nil || !bytes.Equal(pt, message) {
+				fmt.Println("ecies: ISO 18033-2 message not decrypted", err)
+				t.FailNow()
+			}
+			if _, err = Decrypt(prv, ct, nil, []byte("other")); err != ErrInvalidMessage {
+				fmt.Println("ecies: decrypted with the wrong label", err)
+				t.FailNow()
+			}
+		}
+		if _, err = MarshalPublic(&prv.PublicKey); err != ErrUnsupportedECIESParameters {
+			fmt.Println("ecies: ISO 18033-2 parameters encoded in DER", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Rebuild a ciphertext of Bouncy Castle's ECIESwithSHA256andAES-CBC, with a
+// zero nonce, following its IESEngine, and ensure that it is decrypted with
+// the DHAES parameters, whose tag covers the length of the label.
+func TestDHAES(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), ECIES_AES128_DHAES_SHA256)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	eph, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	z, err := eph.GenerateShared(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	V := elliptic.Marshal(elliptic.P256(), eph.X, eph.Y)
+	P1, P2, m := []byte("derivation"), []byte("encoding"), []byte("Hello, Bouncy Castle.")
+
+	// KDF2(V || Z, P1) with SHA-256: one block for K1 and K2.
+	h := sha256.New()
+	h.Write(V)
+	h.Write(z)
+	h.Write(binary.BigEndian.AppendUint32(nil, 1))
+	h.Write(P1)
+	K := h.Sum(nil)
+
+	block, _ := aes.NewCipher(K[:16])
+	pad := 16 - len(m)%16
+	C := append(append([]byte{}, m...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, make([]byte, 16)).CryptBlocks(C, C)
+	mac := hmac.New(sha256.New, K[16:])
+	mac.Write(C)
+	mac.Write(P2)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(P2))*8))
+
+	ct := append(append(V, C...), mac.Sum(nil)...)
+	if pt, err := Decrypt(prv, ct, P1, P2); err != nil || !bytes.Equal(pt, m) {
+		fmt.Println("ecies: DHAES message not decrypted", err)
+		t.FailNow()
+	}
+
+	// The ISO 18033-2 parameters leave the label length out of the tag.
+	prv.Params = ECIES_AES128_ISO18033_SHA256
+	if _, err = Decrypt(prv, ct, P1, P2); err != ErrInvalidMessage {
+		fmt.Println("ecies: DHAES message decrypted without the label length", err)
+		t.FailNow()
+	}
+}
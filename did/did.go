@@ -0,0 +1,214 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+// Package did resolves the key agreement keys of Decentralized Identifiers
+// (did:key and did:web) into ECIES public keys.
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+var (
+	ErrUnsupportedMethod = fmt.Errorf("did: unsupported DID method")
+	ErrInvalidDID        = fmt.Errorf("did: invalid DID")
+	ErrNoKeyAgreement    = fmt.Errorf("did: no supported key agreement key")
+)
+
+// maxDocumentSize bounds the size of the fetched did:web documents.
+const maxDocumentSize = 1 << 20
+
+// Resolver resolves did:key and did:web identifiers into the public keys
+// listed in the keyAgreement relationship of their DID documents.
+// It implements the ecies.RecipientResolver interface.
+type Resolver struct {
+	// Client fetches the did:web documents. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+var _ ecies.RecipientResolver = (*Resolver)(nil)
+
+// ResolveRecipient returns the key agreement public keys of the DID. A DID URL
+// fragment selects a single verification method.
+func (r *Resolver) ResolveRecipient(ctx context.Context, did string) ([]*ecies.PublicKey, error) {
+	did, fragment := splitFragment(did)
+	switch {
+	case strings.HasPrefix(did, "did:key:"):
+		pub, err := ecies.DecodePublicMultibase(strings.TrimPrefix(did, "did:key:"))
+		if err != nil {
+			return nil, err
+		}
+		// For did:key, the key agreement key is the key itself, or the
+		// X25519 key converted from an Ed25519 key.
+		return []*ecies.PublicKey{pub}, nil
+	case strings.HasPrefix(did, "did:web:"):
+		doc, err := r.fetchWeb(ctx, did)
+		if err != nil {
+			return nil, err
+		}
+		return keyAgreementKeys(doc, fragment)
+	}
+	return nil, ErrUnsupportedMethod
+}
+
+func splitFragment(did string) (string, string) {
+	if i := strings.IndexByte(did, '#'); i >= 0 {
+		return did[:i], did[i:]
+	}
+	return did, ""
+}
+
+// webURL returns the location of a did:web document, as per
+// https://w3c-ccg.github.io/did-method-web/#read-resolve
+func webURL(did string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(parts[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", ErrInvalidDID
+	}
+	path := "/.well-known"
+	if len(parts) > 1 {
+		for _, p := range parts[1:] {
+			if p == "" || p == "." || p == ".." {
+				return "", ErrInvalidDID
+			}
+		}
+		path = "/" + strings.Join(parts[1:], "/")
+	}
+	return "https://" + host + path + "/did.json", nil
+}
+
+func (r *Resolver) fetchWeb(ctx context.Context, did string) (*Document, error) {
+	loc, err := webURL(did)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("did: fetching %s: %s", loc, res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseDocument(body)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID != did {
+		return nil, ErrInvalidDID
+	}
+	return doc, nil
+}
+
+// VerificationMethod is a public key entry of a DID document.
+type VerificationMethod struct {
+	ID                 string          `json:"id"`
+	Type               string          `json:"type"`
+	Controller         string          `json:"controller"`
+	PublicKeyMultibase string          `json:"publicKeyMultibase,omitempty"`
+	PublicKeyJwk       json.RawMessage `json:"publicKeyJwk,omitempty"`
+}
+
+// Document is the subset of a DID document needed to find its key agreement keys.
+type Document struct {
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	// KeyAgreement entries are either references to a verification method
+	// or embedded verification methods.
+	KeyAgreement []json.RawMessage `json:"keyAgreement"`
+}
+
+// ParseDocument decodes a DID document in the JSON representation.
+func ParseDocument(in []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.ID, "did:") {
+		return nil, ErrInvalidDID
+	}
+	return &doc, nil
+}
+
+// KeyAgreementKeys returns the supported public keys of the document's keyAgreement
+// relationship, in their order of appearance. Entries of unsupported types are skipped.
+func (doc *Document) KeyAgreementKeys() ([]*ecies.PublicKey, error) {
+	return keyAgreementKeys(doc, "")
+}
+
+func keyAgreementKeys(doc *Document, fragment string) ([]*ecies.PublicKey, error) {
+	var keys []*ecies.PublicKey
+	for _, raw := range doc.KeyAgreement {
+		var vm VerificationMethod
+		var ref string
+		if err := json.Unmarshal(raw, &ref); err == nil {
+			found := false
+			for _, m := range doc.VerificationMethod {
+				if doc.absoluteID(m.ID) == doc.absoluteID(ref) {
+					vm, found = m, true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		} else if err := json.Unmarshal(raw, &vm); err != nil {
+			return nil, err
+		}
+		if fragment != "" && doc.absoluteID(vm.ID) != doc.ID+fragment {
+			continue
+		}
+		if pub := vm.publicKey(); pub != nil {
+			keys = append(keys, pub)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeyAgreement
+	}
+	return keys, nil
+}
+
+// absoluteID resolves a relative DID URL such as "#key-1" against the document.
+func (doc *Document) absoluteID(id string) string {
+	if strings.HasPrefix(id, "#") {
+		return doc.ID + id
+	}
+	return id
+}
+
+func (vm *VerificationMethod) publicKey() *ecies.PublicKey {
+	if vm.PublicKeyMultibase != "" {
+		pub, err := ecies.DecodePublicMultibase(vm.PublicKeyMultibase)
+		if err == nil {
+			return pub
+		}
+	}
+	if len(vm.PublicKeyJwk) > 0 {
+		pub, err := ecies.UnmarshalPublicJWK(vm.PublicKeyJwk)
+		if err == nil {
+			return pub
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package did
+
+import (
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+func cmpPublic(pub1, pub2 *ecies.PublicKey) bool {
+	return pub1.Curve == pub2.Curve && pub1.X.Cmp(pub2.X) == 0 && pub1.Y.Cmp(pub2.Y) == 0
+}
+
+// Ensure that a did:key resolves to the key it embeds.
+func TestResolveKey(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	mb, err := ecies.EncodePublicMultibase(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	var r Resolver
+	keys, err := r.ResolveRecipient(context.Background(), "did:key:"+mb+"#"+mb)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if len(keys) != 1 || !cmpPublic(keys[0], &prv.PublicKey) {
+		fmt.Println("did: resolved the wrong key")
+		t.FailNow()
+	}
+
+	if _, err := r.ResolveRecipient(context.Background(), "did:example:123"); err != ErrUnsupportedMethod {
+		fmt.Println("did: resolved an unsupported method")
+		t.FailNow()
+	}
+}
+
+// Ensure that a did:web document is fetched, and its key agreement keys
+// extracted, whether referenced or embedded.
+func TestResolveWeb(t *testing.T) {
+	prv1, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv2, err := ecies.GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	mb, err := ecies.EncodePublicMultibase(&prv1.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	jwk, err := ecies.MarshalPublicJWK(&prv2.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	var did string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/devices/alpha/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{
+  "id": "%s",
+  "verificationMethod": [
+    {"id": "#signing", "type": "JsonWebKey2020", "controller": "%[1]s", "publicKeyJwk": {"kty": "OKP", "crv": "Ed25519", "x": "AA"}},
+    {"id": "%[1]s#key-1", "type": "Multikey", "controller": "%[1]s", "publicKeyMultibase": "%s"}
+  ],
+  "keyAgreement": [
+    "#key-1",
+    {"id": "#key-2", "type": "JsonWebKey2020", "controller": "%[1]s", "publicKeyJwk": %[3]s},
+    "#missing"
+  ]
+}`, did, mb, jwk)
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	did = "did:web:" + strings.ReplaceAll(host, ":", "%3A") + ":devices:alpha"
+	r := Resolver{Client: srv.Client()}
+
+	keys, err := r.ResolveRecipient(context.Background(), did)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if len(keys) != 2 || !cmpPublic(keys[0], &prv1.PublicKey) || !cmpPublic(keys[1], &prv2.PublicKey) {
+		fmt.Println("did: resolved the wrong keys")
+		t.FailNow()
+	}
+
+	keys, err = r.ResolveRecipient(context.Background(), did+"#key-2")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if len(keys) != 1 || !cmpPublic(keys[0], &prv2.PublicKey) {
+		fmt.Println("did: resolved the wrong key for a fragment")
+		t.FailNow()
+	}
+
+	if _, err := r.ResolveRecipient(context.Background(), "did:web:"+strings.ReplaceAll(host, ":", "%3A")); err == nil {
+		fmt.Println("did: resolved a missing document")
+		t.FailNow()
+	}
+}
+
+// Ensure that did:web identifiers map to the right URLs.
+func TestWebURL(t *testing.T) {
+	for did, expected := range map[string]string{
+		"did:web:w3c-ccg.github.io":             "https://w3c-ccg.github.io/.well-known/did.json",
+		"did:web:w3c-ccg.github.io:user:alice":  "https://w3c-ccg.github.io/user/alice/did.json",
+		"did:web:example.com%3A3000:user:alice": "https://example.com:3000/user/alice/did.json",
+		"did:web:example.com:..:etc":            "",
+		"did:web:example.com%2Fevil.com":        "",
+		"did:web:":                              "",
+	} {
+		loc, err := webURL(did)
+		if expected == "" && err != ErrInvalidDID {
+			fmt.Println(did, "did: accepted an invalid did:web")
+			t.FailNow()
+		} else if expected != "" && loc != expected {
+			fmt.Println(did, "did: unexpected URL", loc, err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that a did:key of an X25519 key agreement key resolves.
+func TestResolveKeyX25519(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, ecies.X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	mb, err := ecies.EncodePublicMultibase(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var r Resolver
+	keys, err := r.ResolveRecipient(context.Background(), "did:key:"+mb)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if len(keys) != 1 || !cmpPublic(keys[0], &prv.PublicKey) {
+		fmt.Println("did: resolved the wrong key")
+		t.FailNow()
+	}
+}
+
+// Ensure that a did:key of an Ed25519 key resolves to its X25519 key
+// agreement key, as in the example of the did:key specification.
+func TestResolveKeyEd25519(t *testing.T) {
+	var r Resolver
+	keys, err := r.ResolveRecipient(context.Background(), "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK#z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	want, _ := ecies.DecodePublicMultibase("z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p")
+	if len(keys) != 1 || !cmpPublic(keys[0], want) {
+		fmt.Println("did: resolved the wrong key")
+		t.FailNow()
+	}
+}
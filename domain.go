@@ -0,0 +1,81 @@
+package ecies
+
+// Domain separation: a structured Context in place of the raw shared
+// information s1 and s2, which most callers leave nil.
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+var ErrInvalidContext = newError(KindParams, "ecies: invalid encryption context")
+
+// Context identifies the use of a message, so that a ciphertext made for one
+// protocol, purpose or recipient doesn't decrypt for another.
+type Context struct {
+	// Protocol names the application or protocol, e.g. "example.com/sync v1".
+	// It is required.
+	Protocol string
+	// Purpose tells the use of the message within the protocol, e.g. "backup".
+	Purpose string
+	// Recipient identifies the recipient, e.g. a device serial number or a
+	// user ID.
+	Recipient string
+}
+
+// contextLabel starts the encodings of contexts, apart from any other s1 and
+// s2 of the package.
+const contextLabel = "go-ecies context v1"
+
+// encode returns the canonical encoding of the context for the given use,
+// the KDF or the MAC: each of the label, the use, the protocol, the purpose
+// and the recipient, as a 16-bit big-endian length followed by its bytes.
+func (info *Context) encode(use string) ([]byte, error) {
+	if info == nil || info.Protocol == "" {
+		return nil, ErrInvalidContext
+	}
+	fields := []string{contextLabel, use, info.Protocol, info.Purpose, info.Recipient}
+	var out []byte
+	for _, f := range fields {
+		if len(f) > math.MaxUint16 {
+			return nil, ErrInvalidContext
+		}
+		out = binary.BigEndian.AppendUint16(out, uint16(len(f)))
+		out = append(out, f...)
+	}
+	return out, nil
+}
+
+// sharedInfo returns the shared information of the KDF and of the MAC for
+// the context.
+func (info *Context) sharedInfo() (s1, s2 []byte, err error) {
+	if s1, err = info.encode("kdf"); err != nil {
+		return nil, nil, err
+	}
+	if s2, err = info.encode("mac"); err != nil {
+		return nil, nil, err
+	}
+	return s1, s2, nil
+}
+
+// EncryptWithContext encrypts a message like Encrypt, with the canonical
+// encodings of info as the shared information of the KDF and of the MAC. It
+// must be decrypted with DecryptWithContext and the same context.
+func EncryptWithContext(rand io.Reader, pub *PublicKey, m []byte, info *Context) ([]byte, error) {
+	s1, s2, err := info.sharedInfo()
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(rand, pub, m, s1, s2)
+}
+
+// DecryptWithContext decrypts a message encrypted with EncryptWithContext.
+// Messages encrypted with another context fail to authenticate.
+func DecryptWithContext(prv KeyProvider, c []byte, info *Context) ([]byte, error) {
+	s1, s2, err := info.sharedInfo()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(prv, c, s1, s2)
+}
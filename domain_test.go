@@ -0,0 +1,48 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure messages only decrypt with the context they were encrypted with.
+func TestEncryptWithContext(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	info := &Context{Protocol: "example.com/sync v1", Purpose: "backup", Recipient: "device-42"}
+	message := []byte("Hello, context.")
+	ct, err := EncryptWithContext(rand.Reader, &prv.PublicKey, message, info)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pt, err := DecryptWithContext(prv, ct, info)
+	if err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: message not decrypted with its context", err)
+		t.FailNow()
+	}
+	for _, other := range []*Context{
+		{Protocol: "example.com/sync v1", Purpose: "backup", Recipient: "device-43"},
+		{Protocol: "example.com/sync v1", Purpose: "backupdevice-42"},
+		{Protocol: "example.com/sync v2", Purpose: "backup", Recipient: "device-42"},
+	} {
+		if _, err = DecryptWithContext(prv, ct, other); err != ErrInvalidMessage {
+			fmt.Println("ecies: message decrypted with another context", err)
+			t.FailNow()
+		}
+	}
+	if _, err = Decrypt(prv, ct, nil, nil); err != ErrInvalidMessage {
+		fmt.Println("ecies: message decrypted without its context", err)
+		t.FailNow()
+	}
+	if _, err = EncryptWithContext(rand.Reader, &prv.PublicKey, message, &Context{}); err != ErrInvalidContext {
+		fmt.Println("ecies: context without a protocol accepted", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,143 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// Compatibility with the eccrypto npm package, which encrypts as follows:
+//
+//	z = X coordinate of r·Q
+//	Ke || Km = SHA-512(z)
+//	c = AES-256-CBC(Ke, iv, PKCS#7 padded m)
+//	mac = HMAC-SHA-256(Km, iv || uncompressed R || c)
+//
+// and returns the iv, ephemPublicKey, ciphertext and mac fields separately.
+
+import (
+	"crypto/aes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// EccryptoMessage is the encrypted message structure of eccrypto.
+//
+// It is encoded to JSON with hex strings, and decoded from either hex strings
+// or the {"type": "Buffer", "data": [...]} objects of Node's JSON.stringify.
+type EccryptoMessage struct {
+	IV             EccryptoBytes `json:"iv"`
+	EphemPublicKey EccryptoBytes `json:"ephemPublicKey"`
+	Ciphertext     EccryptoBytes `json:"ciphertext"`
+	MAC            EccryptoBytes `json:"mac"`
+}
+
+// EccryptoBytes is a byte slice with the JSON encoding of EccryptoMessage.
+type EccryptoBytes []byte
+
+func (b EccryptoBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *EccryptoBytes) UnmarshalJSON(in []byte) error {
+	var s string
+	if err := json.Unmarshal(in, &s); err == nil {
+		*b, err = hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		return err
+	}
+	var buf struct {
+		Type string `json:"type"`
+		Data []int  `json:"data"`
+	}
+	if err := json.Unmarshal(in, &buf); err != nil {
+		return err
+	} else if buf.Type != "Buffer" {
+		return ErrInvalidMessage
+	}
+	out := make([]byte, len(buf.Data))
+	for i, v := range buf.Data {
+		if v < 0 || v > 255 {
+			return ErrInvalidMessage
+		}
+		out[i] = byte(v)
+	}
+	*b = out
+	return nil
+}
+
+// EncryptEccrypto encrypts a message to a secp256k1 or P-256 public key as
+// eccrypto does.
+func EncryptEccrypto(rand io.Reader, pub *PublicKey, m []byte) (msg *EccryptoMessage, err error) {
+	if err = ValidatePublicKey(pub); err != nil {
+		return
+	}
+	if err = enforceEccrypto(pub.Curve); err != nil {
+		return
+	}
+	R, err := GenerateKey(rand, pub.Curve, nil)
+	if err != nil {
+		return
+	}
+	z, err := R.GenerateShared(pub)
+	if err != nil {
+		return
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err = io.ReadFull(rand, iv); err != nil {
+		return
+	}
+	K := sha512.Sum512(z)
+
+	msg = &EccryptoMessage{
+		IV:             iv,
+		EphemPublicKey: elliptic.Marshal(pub.Curve, R.X, R.Y),
+	}
+	if msg.Ciphertext, err = cbcEncrypt(K[:32], iv, m); err != nil {
+		return nil, err
+	}
+	msg.MAC = eccryptoTag(K[32:], msg)
+	return
+}
+
+// eccrypto uses AES-256 and HMAC-SHA256.
+func enforceEccrypto(curve elliptic.Curve) error {
+	return enforcePolicy(nil, SuiteEccrypto, curve, sha256.Size, 32)
+}
+
+// DecryptEccrypto decrypts a message encrypted by eccrypto.
+func DecryptEccrypto(prv KeyProvider, msg *EccryptoMessage) ([]byte, error) {
+	pub := prv.Public()
+	if err := enforceEccrypto(pub.Curve); err != nil {
+		return nil, err
+	}
+	if len(msg.IV) != aes.BlockSize || len(msg.MAC) != sha256.Size {
+		return nil, ErrInvalidMessage
+	}
+	R := new(PublicKey)
+	R.Curve = pub.Curve
+	R.X, R.Y = unmarshalPoint(R.Curve, msg.EphemPublicKey, AllowCompressedPoints)
+	if R.X == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	K := sha512.Sum512(z)
+	if subtle.ConstantTimeCompare(msg.MAC, eccryptoTag(K[32:], msg)) != 1 {
+		return nil, ErrInvalidMessage
+	}
+	return cbcDecrypt(K[:32], msg.IV, msg.Ciphertext)
+}
+
+func eccryptoTag(Km []byte, msg *EccryptoMessage) []byte {
+	mac := hmac.New(sha256.New, Km)
+	mac.Write(msg.IV)
+	mac.Write(msg.EphemPublicKey)
+	mac.Write(msg.Ciphertext)
+	return mac.Sum(nil)
+}
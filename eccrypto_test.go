@@ -0,0 +1,109 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that eccrypto messages round trip through JSON on both curves.
+func TestEccrypto(t *testing.T) {
+	for _, c := range []elliptic.Curve{Secp256k1(), elliptic.P256()} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		message := []byte("Hello, world.")
+		msg, err := EncryptEccrypto(rand.Reader, &prv.PublicKey, message)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		out, err := json.Marshal(msg)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		var msg2 EccryptoMessage
+		if err := json.Unmarshal(out, &msg2); err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pt, err := DecryptEccrypto(prv, &msg2)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: plaintext doesn't match message")
+			t.FailNow()
+		}
+
+		msg2.IV[0] ^= 1
+		if _, err := DecryptEccrypto(prv, &msg2); err != ErrInvalidMessage {
+			fmt.Println(name, "ecies: decrypted a tampered message")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that messages produced with Node's crypto module, as eccrypto does,
+// are decrypted from both of their JSON forms.
+func TestVectorEccrypto(t *testing.T) {
+	d, _ := hex.DecodeString("1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100")
+	for _, v := range []struct {
+		Curve elliptic.Curve
+		JSON  string
+	}{
+		{
+			Secp256k1(),
+			`{"iv":{"type":"Buffer","data":[0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15]},"ephemPublicKey":{"type":"Buffer","data":[4,27,109,90,14,231,44,26,7,123,10,249,23,35,149,43,222,230,32,229,133,191,68,173,15,11,99,55,20,191,233,185,202,150,87,139,248,77,191,115,245,130,80,239,184,67,233,9,26,195,33,190,107,224,34,162,216,235,141,89,22,138,84,171,24]},"ciphertext":{"type":"Buffer","data":[155,253,135,153,116,42,163,248,246,227,25,91,197,201,101,190,116,137,225,210,63,181,20,226,109,169,111,59,140,25,68,231]},"mac":{"type":"Buffer","data":[53,31,29,51,17,195,65,112,12,0,103,233,146,233,215,146,9,106,152,228,251,151,233,195,195,6,193,228,26,242,89,47]}}`,
+		},
+		{
+			Secp256k1(),
+			`{"iv":"000102030405060708090a0b0c0d0e0f","ephemPublicKey":"041b6d5a0ee72c1a077b0af91723952bdee620e585bf44ad0f0b633714bfe9b9ca96578bf84dbf73f58250efb843e9091ac321be6be022a2d8eb8d59168a54ab18","ciphertext":"9bfd8799742aa3f8f6e3195bc5c965be7489e1d23fb514e26da96f3b8c1944e7","mac":"351f1d3311c341700c0067e992e9d792096a98e4fb97e9c3c306c1e41af2592f"}`,
+		},
+		{
+			elliptic.P256(),
+			`{"iv":"f0e0d0c0b0a090807060504030201000","ephemPublicKey":"04e48813e656219b4090c282a020f40e07b4e1efd60a3dd17492a1667c5758ee5b760f9b9b1c840b4f4f63ab4043c0537ca29b3512c32e50e56f5e4e8d42d0d31e","ciphertext":"924d799ac53c4ac7d6600e615279bdd6532bfd56c181deafc77a4e9e7ef1bc0a","mac":"b2b2a8ff2429f71b1c54ea6f8be44304436fbfb375ed62be751d3c7c9f9bb902"}`,
+		},
+	} {
+		prv, err := NewPrivateKey(v.Curve, d)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		var msg EccryptoMessage
+		if err := json.Unmarshal([]byte(v.JSON), &msg); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pt, err := DecryptEccrypto(prv, &msg)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if string(pt) != "Hello, eccrypto." {
+			fmt.Println("ecies: decrypted doesn't match vector", string(pt))
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that messages aren't encrypted to points off the curve.
+func TestOffCurveEccrypto(t *testing.T) {
+	pub := offCurveKey(t, Secp256k1())
+	if _, err := EncryptEccrypto(rand.Reader, pub, []byte("message")); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: encrypted to an off-curve point", err)
+		t.FailNow()
+	}
+}
@@ -1,24 +1,29 @@
 package ecies
 
 import (
+	"crypto"
 	"crypto/cipher"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/subtle"
-	"fmt"
+	"encoding/binary"
+	"errors"
 	"hash"
 	"io"
 	"math/big"
+	"slices"
+	"time"
 )
 
 var (
-	ErrImport                     = fmt.Errorf("ecies: failed to import key")
-	ErrInvalidCurve               = fmt.Errorf("ecies: invalid elliptic curve")
-	ErrInvalidParams              = fmt.Errorf("ecies: invalid ECIES parameters")
-	ErrInvalidPublicKey           = fmt.Errorf("ecies: invalid public key")
-	ErrSharedKeyIsPointAtInfinity = fmt.Errorf("ecies: shared key is point at infinity")
-	ErrSharedKeyTooBig            = fmt.Errorf("ecies: shared key params are too big")
+	ErrImport                     = newError(KindKey, "ecies: failed to import key")
+	ErrInvalidCurve               = newError(KindKey, "ecies: invalid elliptic curve")
+	ErrInvalidParams              = newError(KindParams, "ecies: invalid ECIES parameters")
+	ErrInvalidPublicKey           = newError(KindKey, "ecies: invalid public key")
+	ErrInvalidPrivateKey          = newError(KindKey, "ecies: invalid private key")
+	ErrSharedKeyIsPointAtInfinity = newError(KindKey, "ecies: shared key is point at infinity")
+	ErrSharedKeyTooBig            = newError(KindParams, "ecies: shared key params are too big")
 )
 
 // PublicKey is a representation of an elliptic curve public key.
@@ -29,18 +34,44 @@ type PublicKey struct {
 	Params *ECIESParams
 }
 
+// NewPublicKey returns a public key for the given point, with the default
+// parameters for the curve. The point must pass ValidatePublicKey.
+func NewPublicKey(curve elliptic.Curve, x, y *big.Int) (*PublicKey, error) {
+	pub := &PublicKey{X: x, Y: y, Curve: curve, Params: ParamsFromCurve(curve)}
+	if err := ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// NewPublicKeyFromBytes returns a public key for the given SEC 1 encoded point,
+// with the default parameters for the curve.
+func NewPublicKeyFromBytes(curve elliptic.Curve, data []byte) (*PublicKey, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	x, y := unmarshalPoint(curve, data, AllowAllPoints)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return NewPublicKey(curve, x, y)
+}
+
 // Export an ECIES public key as an ECDSA public key.
 func (pub *PublicKey) ExportECDSA() *ecdsa.PublicKey {
 	return &ecdsa.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}
 }
 
-// Import an ECDSA public key as an ECIES public key.
+// Import an ECDSA public key as an ECIES public key. The key isn't validated
+// here, but before any use: see ValidatePublicKey. Keys on secp256k1 curves
+// of other packages are imported on Secp256k1().
 func ImportECDSAPublic(pub *ecdsa.PublicKey) *PublicKey {
+	curve := importCurve(pub.Curve)
 	return &PublicKey{
 		X:      pub.X,
 		Y:      pub.Y,
-		Curve:  pub.Curve,
-		Params: ParamsFromCurve(pub.Curve),
+		Curve:  curve,
+		Params: ParamsFromCurve(curve),
 	}
 }
 
@@ -72,8 +103,19 @@ func ImportECDSA(prv *ecdsa.PrivateKey) *PrivateKey {
 }
 
 // Generate an elliptic curve public / private keypair. If params is nil,
-// the recommended default paramters for the key will be chosen.
+// the recommended default paramters for the key will be chosen. If rand is
+// nil, the entropy source of the package is used, see SetEntropySource.
 func GenerateKey(rand io.Reader, curve elliptic.Curve, params *ECIESParams) (prv *PrivateKey, err error) {
+	rand = entropy(rand)
+	if curve == X25519() {
+		return generateX25519(rand, params)
+	}
+	if curve == X448() {
+		return generateX448(rand, params)
+	}
+	if nistECDH(curve) != nil {
+		return generateNIST(rand, curve, params)
+	}
 	pb, x, y, err := elliptic.GenerateKey(curve, rand)
 	if err != nil {
 		return
@@ -90,16 +132,108 @@ func GenerateKey(rand io.Reader, curve elliptic.Curve, params *ECIESParams) (prv
 	return
 }
 
+// NewPrivateKey returns the private key for the given big-endian scalar, with
+// the default parameters for the curve. The scalar must be in the range [1, N-1].
+func NewPrivateKey(curve elliptic.Curve, d []byte) (*PrivateKey, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if curve == X25519() {
+		if len(d) != x25519KeySize {
+			return nil, ErrInvalidPrivateKey
+		}
+		priv, err := x25519PrivateKey(d)
+		if err != nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		return newX25519PrivateKey(priv), nil
+	}
+	if curve == X448() {
+		if len(d) != x448KeySize {
+			return nil, ErrInvalidPrivateKey
+		}
+		return newX448PrivateKey(d), nil
+	}
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	if nistECDH(curve) != nil {
+		priv, err := nistPrivateKey(curve, k)
+		if err != nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		return newNISTPrivateKey(curve, priv), nil
+	}
+	prv := new(PrivateKey)
+	prv.PublicKey.X, prv.PublicKey.Y = curve.ScalarBaseMult(k.Bytes())
+	prv.PublicKey.Curve = curve
+	prv.PublicKey.Params = ParamsFromCurve(curve)
+	prv.D = k
+	return prv, nil
+}
+
 func (prv *PrivateKey) Public() *PublicKey {
 	return &prv.PublicKey
 }
 
+// Equal reports whether pub and x are the same point on the same curve. The
+// ECIES parameters aren't compared.
+func (pub *PublicKey) Equal(x crypto.PublicKey) bool {
+	other, ok := x.(*PublicKey)
+	if !ok || pub.X == nil || pub.Y == nil || other.X == nil || other.Y == nil {
+		return false
+	}
+	return pub.Curve == other.Curve && pub.X.Cmp(other.X) == 0 && pub.Y.Cmp(other.Y) == 0
+}
+
+// Equal reports whether prv and x are the same key. The scalars are compared
+// in constant time.
+func (prv *PrivateKey) Equal(x crypto.PrivateKey) bool {
+	other, ok := x.(*PrivateKey)
+	if !ok || prv.D == nil || other.D == nil || !prv.PublicKey.Equal(&other.PublicKey) {
+		return false
+	}
+	size := (prv.Curve.Params().N.BitLen() + 7) / 8
+	if c, ok := prv.Curve.(rawPointCurve); ok {
+		size = c.pointLen()
+	}
+	if prv.D.BitLen() > 8*size || other.D.BitLen() > 8*size {
+		return false
+	}
+	d1, d2 := prv.D.FillBytes(make([]byte, size)), other.D.FillBytes(make([]byte, size))
+	defer wipe(d1)
+	defer wipe(d2)
+	return subtle.ConstantTimeCompare(d1, d2) == 1
+}
+
+// Fingerprint returns the SHA-256 hash of the uncompressed encoding of the
+// point, or of the raw encoding of X25519 keys, which identifies the key
+// regardless of its ECIES parameters and of how it was encoded. The key must
+// be valid.
+func (pub *PublicKey) Fingerprint() [sha256.Size]byte {
+	return sha256.Sum256(marshalPoint(pub.Curve, pub.X, pub.Y))
+}
+
 // SEC 1 section 3.3.1: ECDH key agreement method used to establish secret keys for encryption.
 func (prv *PrivateKey) GenerateShared(pub *PublicKey) ([]byte, error) {
 	if prv.PublicKey.Curve != pub.Curve {
 		return nil, ErrInvalidCurve
 	}
-	x, _ := pub.Curve.ScalarMult(pub.X, pub.Y, prv.D.Bytes())
+	if nistECDH(pub.Curve) != nil {
+		return nistShared(prv, pub)
+	}
+	var x *big.Int
+	if h := CurrentHardening(); h != 0 && !constantTimeCurve(pub.Curve) && !montgomeryCurve(pub.Curve) {
+		var err error
+		if x, _, err = blindedScalarMult(prv, pub.X, pub.Y, h); err != nil {
+			return nil, err
+		}
+	} else {
+		d := prv.D.Bytes()
+		x, _ = pub.Curve.ScalarMult(pub.X, pub.Y, d)
+		wipe(d)
+	}
 	if x == nil {
 		return nil, ErrSharedKeyIsPointAtInfinity
 	}
@@ -109,108 +243,209 @@ func (prv *PrivateKey) GenerateShared(pub *PublicKey) ([]byte, error) {
 }
 
 var (
-	ErrKeyDataTooLong = fmt.Errorf("ecies: can't supply requested key data")
-	ErrSharedTooLong  = fmt.Errorf("ecies: shared secret is too long")
-	ErrInvalidMessage = fmt.Errorf("ecies: invalid message")
-)
-
-var (
-	big2To32   = new(big.Int).Exp(big.NewInt(2), big.NewInt(32), nil)
-	big2To32M1 = new(big.Int).Sub(big2To32, big.NewInt(1))
+	ErrKeyDataTooLong = newError(KindKDF, "ecies: can't supply requested key data")
+	ErrSharedTooLong  = newError(KindKDF, "ecies: shared secret is too long")
+	ErrInvalidMessage = newError(KindAuthentication, "ecies: invalid message")
+	ErrKeyDerivation  = newError(KindKDF, "ecies: key derivation failed")
+
+	// errMessageTooShort is the cause of ciphertexts too short to hold an
+	// ephemeral key, an encrypted message and a tag.
+	errMessageTooShort = errors.New("message too short")
 )
 
-func incCounter(ctr []byte) {
-	if ctr[3]++; ctr[3] != 0 {
-		return
-	} else if ctr[2]++; ctr[2] != 0 {
-		return
-	} else if ctr[1]++; ctr[1] != 0 {
-		return
-	} else if ctr[0]++; ctr[0] != 0 {
-		return
-	}
-}
-
 // NIST SP 800-56c Concatenation Key Derivation Function (see section 4.1).
 func concatKDF(hash hash.Hash, z, s1 []byte, kdLen int) (k []byte, err error) {
-	if s1 == nil {
-		s1 = make([]byte, 0)
-	}
-
-	reps := ((kdLen + 7) * 8) / (hash.BlockSize() * 8)
-	if big.NewInt(int64(reps)).Cmp(big2To32M1) > 0 {
-		fmt.Println(big2To32M1)
+	size := hash.Size()
+	reps := (kdLen + size - 1) / size
+	if uint64(reps) > 1<<32-1 {
 		return nil, ErrKeyDataTooLong
 	}
 
-	counter := []byte{0, 0, 0, 1}
-	k = make([]byte, 0)
-
-	for i := 0; i <= reps; i++ {
-		hash.Write(counter)
+	var counter [4]byte
+	k = make([]byte, 0, reps*size)
+	for i := 1; i <= reps; i++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(i))
+		hash.Write(counter[:])
 		hash.Write(z)
 		hash.Write(s1)
-		k = append(k, hash.Sum(nil)...)
+		k = hash.Sum(k)
 		hash.Reset()
-		incCounter(counter)
 	}
-
-	k = k[:kdLen]
-	return
-}
-
-// messageTag computes the MAC of a message (called the tag) as per SEC 1, 3.5.
-func messageTag(hash func() hash.Hash, km, msg, shared []byte) []byte {
-	mac := hmac.New(hash, km)
-	mac.Write(msg)
-	mac.Write(shared)
-	tag := mac.Sum(nil)
-	return tag
-}
-
-// Generate an initialisation vector for CTR mode.
-func generateIV(params *ECIESParams, rand io.Reader) (iv []byte, err error) {
-	iv = make([]byte, params.BlockSize)
-	_, err = io.ReadFull(rand, iv)
-	return
+	return k[:kdLen], nil
 }
 
 // symEncrypt carries out CTR encryption using the block cipher specified in the parameters.
 func symEncrypt(rand io.Reader, params *ECIESParams, key, m []byte) (ct []byte, err error) {
+	return appendSymEncrypt(nil, rand, params, key, m)
+}
+
+// appendSymEncrypt is symEncrypt, appending the IV and encrypted message to
+// dst rather than to a new slice.
+func appendSymEncrypt(dst []byte, rand io.Reader, params *ECIESParams, key, m []byte) ([]byte, error) {
+	if params.dem == demAESCBC {
+		em, err := cbcEncrypt(key, make([]byte, params.BlockSize), m)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, em...), nil
+	}
 	c, err := params.Cipher(key)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	iv, err := generateIV(params, rand)
-	if err != nil {
-		return
+	n := len(dst)
+	dst = grow(dst, params.BlockSize+len(m))
+	iv := dst[n : n+params.BlockSize]
+	if _, err = io.ReadFull(rand, iv); err != nil {
+		return nil, err
 	}
-	ctr := cipher.NewCTR(c, iv)
+	cipher.NewCTR(c, iv).XORKeyStream(dst[n+params.BlockSize:], m)
+	return dst, nil
+}
 
-	ct = make([]byte, len(m)+params.BlockSize)
-	copy(ct, iv)
-	ctr.XORKeyStream(ct[params.BlockSize:], m)
-	return
+// grow extends b by n bytes, reallocating it only if its capacity is too
+// small.
+func grow(b []byte, n int) []byte {
+	if cap(b)-len(b) < n {
+		b = append(make([]byte, 0, len(b)+n), b...)
+	}
+	return b[:len(b)+n]
 }
 
 // symDecrypt carries out CTR decryption using the block cipher specified in the parameters
 func symDecrypt(params *ECIESParams, key, ct []byte) (m []byte, err error) {
+	return appendSymDecrypt(nil, params, key, ct)
+}
+
+// appendSymDecrypt is symDecrypt, appending the message to dst rather than
+// to a new slice.
+func appendSymDecrypt(dst []byte, params *ECIESParams, key, ct []byte) ([]byte, error) {
+	if params.dem == demAESCBC {
+		m, err := cbcDecrypt(key, make([]byte, params.BlockSize), ct)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, m...), nil
+	}
 	c, err := params.Cipher(key)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	ctr := cipher.NewCTR(c, ct[:params.BlockSize])
 
-	m = make([]byte, len(ct)-params.BlockSize)
-	ctr.XORKeyStream(m, ct[params.BlockSize:])
-	return
+	n := len(dst)
+	dst = grow(dst, len(ct)-params.BlockSize)
+	ctr.XORKeyStream(dst[n:], ct[params.BlockSize:])
+	return dst, nil
+}
+
+// EncryptOptions tune the encryption in EncryptWithOptions.
+type EncryptOptions struct {
+	// Policy is enforced in addition to the global policy.
+	Policy *Policy
+	// Timestamp, if not zero, is prepended to the ciphertext along with the
+	// optional MessageID of up to 255 bytes, both authenticated. Such
+	// ciphertexts must be decrypted with DecryptOptions.Timestamped.
+	Timestamp time.Time
+	MessageID []byte
+	// NotBefore and NotAfter, if either is not zero, are prepended to the
+	// ciphertext, authenticated, and enforced when decrypting with
+	// DecryptOptions.Validity. A zero time leaves that end unbounded.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// RecipientHeader prepends the fingerprint of the recipient public key,
+	// along with the optional KeyID of up to 255 bytes, to the ciphertext,
+	// authenticated, and ahead of any other header, see PeekRecipient. Such
+	// ciphertexts must be decrypted with DecryptOptions.RecipientHeader.
+	RecipientHeader bool
+	KeyID           string
+	// Padding pads the message before encryption, so that the ciphertext
+	// doesn't give its exact length away, see PaddingScheme. PaddingBlock is
+	// the multiple of PadBlock. Such ciphertexts must be decrypted with
+	// DecryptOptions.Padded.
+	Padding      PaddingScheme
+	PaddingBlock int
+	// CompressEphemeral encodes the ephemeral public key as a compressed
+	// SEC 1 point, saving the size of a coordinate. Decrypt accepts it
+	// unless DecryptOptions.PointFormats only allows uncompressed points.
+	CompressEphemeral bool
+	// Sender authenticates the message with the long-term key of the sender,
+	// see EncryptAuthenticated.
+	Sender KeyProvider
+	// BindContext, the v2 transcripts, binds the curve, the parameters, the
+	// ephemeral public key and both s1 and s2 into the KDF and the MAC, so
+	// that the ciphertext can't be opened under other parameters. Such
+	// ciphertexts must be decrypted with DecryptOptions.BindContext.
+	BindContext bool
+	// Hedged derives the ephemeral key from the output of rand together with
+	// the recipient key and the message, rather than from rand alone, so that
+	// a weak or broken random source doesn't give the ephemeral key, and the
+	// message, away. See hedgedEphemeral.
+	Hedged bool
+	// Pool provides the ephemeral key, generated ahead of time. It must be
+	// for the curve of the recipient, and can't be combined with Hedged.
+	Pool *EphemeralPool
+
+	// ephemeral is the fixed ephemeral key of the known-answer tests.
+	ephemeral *PrivateKey
 }
 
 // Encrypt encrypts a message using ECIES as specified in SEC 1, 5.1. If
 // the shared information parameters aren't being used, they should be nil.
+// If rand is nil, the entropy source of the package is used, see
+// SetEntropySource.
 func Encrypt(rand io.Reader, pub *PublicKey, m, s1, s2 []byte) (ct []byte, err error) {
+	return EncryptWithOptions(rand, pub, m, s1, s2, nil)
+}
+
+// EncryptWithOptions encrypts a message like Encrypt. If opts is nil, the
+// default options are used.
+func EncryptWithOptions(rand io.Reader, pub *PublicKey, m, s1, s2 []byte, opts *EncryptOptions) (ct []byte, err error) {
+	return appendEncrypt(nil, rand, pub, m, s1, s2, opts)
+}
+
+// EncryptAppend encrypts a message like Encrypt, and appends the ciphertext
+// to dst, which is reallocated only if its capacity is too small. The
+// updated slice is returned. dst and m must not overlap.
+func EncryptAppend(dst []byte, rand io.Reader, pub *PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	return appendEncrypt(dst, rand, pub, m, s1, s2, nil)
+}
+
+// EncryptWithEphemeral encrypts a message like Encrypt, but with the given
+// ephemeral key rather than a random one, and all-zero IVs and nonces, so that
+// the ciphertext is reproducible. It is meant for known-answer tests and the
+// test vectors of other implementations only: a reused ephemeral key gives
+// the messages encrypted with it away. The ephemeral key isn't wiped.
+func EncryptWithEphemeral(ephemeral *PrivateKey, pub *PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	if ephemeral == nil || ephemeral.D == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	if pub == nil || ephemeral.Curve != pub.Curve {
+		return nil, ErrInvalidCurve
+	}
+	R := &PrivateKey{PublicKey: ephemeral.PublicKey, D: new(big.Int).Set(ephemeral.D)}
+	return appendEncrypt(nil, zeroRand{}, pub, m, s1, s2, &EncryptOptions{ephemeral: R})
+}
+
+// zeroRand is the random source of EncryptWithEphemeral, for all-zero IVs and
+// nonces.
+type zeroRand struct{}
+
+func (zeroRand) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// appendEncrypt is EncryptWithOptions, appending the ciphertext to dst.
+func appendEncrypt(dst []byte, rand io.Reader, pub *PublicKey, m, s1, s2 []byte, opts *EncryptOptions) (ct []byte, err error) {
+	if ob := startObservation(OpEncrypt, pub, len(m)); ob != nil {
+		defer func() { ob.done(len(ct)-len(dst), err) }()
+	}
+	if err = ValidatePublicKey(pub); err != nil {
+		return
+	}
 	params := pub.Params
 	if params == nil {
 		if params = ParamsFromCurve(pub.Curve); params == nil {
@@ -218,38 +453,121 @@ func Encrypt(rand io.Reader, pub *PublicKey, m, s1, s2 []byte) (ct []byte, err e
 			return
 		}
 	}
-	R, err := GenerateKey(rand, pub.Curve, params)
+	return sealTo(dst, rand, pub, params, m, s1, s2, opts)
+}
+
+// sealTo is appendEncrypt to a validated public key, with its parameters.
+func sealTo(dst []byte, rand io.Reader, pub *PublicKey, params *ECIESParams, m, s1, s2 []byte, opts *EncryptOptions) (ct []byte, err error) {
+	if opts == nil {
+		opts = &EncryptOptions{}
+	}
+	rand = entropy(rand)
+	if err = enforceParams(opts.Policy, pub.Curve, params); err != nil {
+		return
+	}
+	if opts.Sender != nil {
+		if err = checkSender(opts.Sender.Public(), pub); err != nil {
+			return
+		}
+	}
+	var header []byte
+	if opts.RecipientHeader {
+		var recipient *recipientHeader
+		if recipient, err = newRecipientHeader(pub, opts.KeyID); err != nil {
+			return
+		}
+		header = append(header, recipient.header...)
+	}
+	if !opts.NotBefore.IsZero() || !opts.NotAfter.IsZero() {
+		var validity *validityWindow
+		if validity, err = newValidity(opts.NotBefore, opts.NotAfter); err != nil {
+			return
+		}
+		header = append(header, validity.header...)
+	}
+	if !opts.Timestamp.IsZero() {
+		var stamp *messageStamp
+		if stamp, err = newStamp(opts.Timestamp, opts.MessageID); err != nil {
+			return
+		}
+		header = append(header, stamp.header...)
+	}
+	if header != nil {
+		s2 = bindHeader(header, s2)
+	}
+	if opts.Padding != NoPadding {
+		if m, err = pad(m, opts.Padding, opts.PaddingBlock); err != nil {
+			return
+		}
+		defer wipe(m)
+	}
+	var R *PrivateKey
+	if opts.Hedged && opts.Pool != nil {
+		err = ErrInvalidParams
+	} else if opts.ephemeral != nil {
+		R = opts.ephemeral
+	} else if opts.Hedged {
+		R, err = hedgedEphemeral(rand, pub, m)
+	} else if opts.Pool != nil {
+		R, err = opts.Pool.ephemeral(pub)
+	} else {
+		R, err = GenerateKey(rand, pub.Curve, params)
+	}
 	if err != nil {
 		return
 	}
-
-	hash := params.Hash()
-	z, err := R.GenerateShared(pub)
+	defer R.Wipe()
+	sec, err := newSecrets()
 	if err != nil {
 		return
 	}
-	K, err := concatKDF(hash, z, s1, params.KeyLen+params.KeyLen)
+	defer sec.wipe()
+	z, Rb, err := encapsulateWith(R, pub, opts.CompressEphemeral)
 	if err != nil {
 		return
 	}
-	Ke := K[:params.KeyLen]
-	Km := K[params.KeyLen:]
-	hash.Write(Km)
-	Km = hash.Sum(nil)
-	hash.Reset()
-
-	em, err := symEncrypt(rand, params, Ke, m)
-	if err != nil || len(em) <= params.BlockSize {
+	z = sec.keep(z)
+	if opts.Sender != nil {
+		var zs []byte
+		if zs, err = opts.Sender.GenerateShared(pub); err != nil {
+			return
+		}
+		sec.keep(zs)
+		z, s1 = authenticate(z, zs, s1, opts.Sender.Public(), pub)
+		z = sec.keep(z)
+	}
+	if opts.BindContext {
+		s1, s2 = bindContext(pub.Curve, params, Rb, s1, s2)
+	}
+	K, err := params.deriveKeys(sec.keep(params.kdfInput(Rb, z)), s1, params.derivedKeyLen())
+	if err != nil {
 		return
 	}
+	K = sec.keep(K)
+
+	// The encrypted message and tag are appended in place.
+	out := slices.Grow(dst, len(header)+len(Rb)+len(m)+demMaxOverhead)
+	out = append(append(out, header...), Rb...)
+	n := len(out)
+	if params.AEAD != nil {
+		if out, err = appendAEADSeal(out, rand, params, K, m, s2); err != nil {
+			return
+		}
+	} else {
+		Ke := K[:params.KeyLen]
+		Km := sec.keep(macKey(params, K[params.KeyLen:]))
 
-	d := messageTag(params.Hash, Km, em, s2)
-
-	Rb := elliptic.Marshal(pub.Curve, R.PublicKey.X, R.PublicKey.Y)
-	ct = make([]byte, len(Rb)+len(em)+len(d))
-	copy(ct, Rb)
-	copy(ct[len(Rb):], em)
-	copy(ct[len(Rb)+len(em):], d)
+		out, err = appendSymEncrypt(out, rand, params, Ke, m)
+		if err != nil {
+			return
+		}
+		if params.dem != demAESCBC && len(out)-n <= params.BlockSize {
+			// An empty message has no ciphertext.
+			return dst, nil
+		}
+		out = append(out, messageTag(params, Km, out[n:], s2)...)
+	}
+	ct = out
 	return
 }
 
@@ -258,13 +576,90 @@ func (prv *PrivateKey) Decrypt(rand io.Reader, c, s1, s2 []byte) (m []byte, err
 	return Decrypt(prv, c, s1, s2)
 }
 
-// Decrypt decrypts an ECIES ciphertext.
-func Decrypt(prv KeyProvider, c, s1, s2 []byte) (m []byte, err error) {
-	if len(c) == 0 {
-		err = ErrInvalidMessage
-		return
+// DecryptOptions tune the validation of ciphertexts in DecryptWithOptions.
+type DecryptOptions struct {
+	// Strict rejects ephemeral keys encoded in the hybrid point format
+	// (SEC 1 section 2.3.3), which is otherwise accepted and validated.
+	Strict bool
+	// PointFormats restricts the encodings accepted for the ephemeral key,
+	// as some compliance profiles forbid compressed points.
+	PointFormats PointFormatPolicy
+	// Policy is enforced in addition to the global policy.
+	Policy *Policy
+	// Timestamped expects the ciphertext to start with the timestamp and
+	// message ID set in EncryptOptions. If MaxAge is set, messages with a
+	// timestamp further than MaxAge away from the current time are rejected
+	// with ErrStale. If ReplayCache is set, messages already decrypted, as
+	// told by their ID or else their tag, are rejected with ErrReplay.
+	Timestamped bool
+	MaxAge      time.Duration
+	ReplayCache ReplayCache
+	// Validity expects the ciphertext to start with the validity window set
+	// in EncryptOptions, ahead of any timestamp, and rejects messages
+	// outside of it with ErrNotYetValid or ErrExpired.
+	Validity bool
+	// RecipientHeader expects the ciphertext to start with the recipient
+	// header set in EncryptOptions, ahead of any other header, and rejects
+	// messages for another key with ErrNotRecipient.
+	RecipientHeader bool
+	// Padded strips the padding set in EncryptOptions once the message is
+	// authenticated, and rejects messages without it with ErrInvalidPadding.
+	Padded bool
+	// Clock returns the current time for the checks above. If nil,
+	// time.Now is used.
+	Clock func() time.Time
+	// Sender expects a message authenticated by the long-term key of the
+	// sender, see DecryptAuthenticated.
+	Sender *PublicKey
+	// BindContext expects the v2 transcripts set in EncryptOptions.
+	BindContext bool
+}
+
+func (opts *DecryptOptions) now() time.Time {
+	if opts.Clock != nil {
+		return opts.Clock()
+	}
+	return time.Now()
+}
+
+func (opts *DecryptOptions) pointFormats() PointFormatPolicy {
+	if opts.Strict && opts.PointFormats == AllowAllPoints {
+		return AllowCompressedPoints
 	}
+	return opts.PointFormats
+}
+
+// Decrypt decrypts an ECIES ciphertext using the default options.
+func Decrypt(prv KeyProvider, c, s1, s2 []byte) (m []byte, err error) {
+	return DecryptWithOptions(prv, c, s1, s2, nil)
+}
+
+// DecryptWithOptions decrypts an ECIES ciphertext, either raw or wrapped in
+// the versioned envelope (see EncodeEnvelope). If opts is nil, the default
+// options are used.
+//
+// Malformed headers, invalid ephemeral keys and bad message tags are not
+// reported straight away: the key agreement, KDF and MAC still run (over a
+// stand-in ephemeral key if needed), so that all failures take comparable
+// time and can't be told apart by timing the responses.
+func DecryptWithOptions(prv KeyProvider, c, s1, s2 []byte, opts *DecryptOptions) (m []byte, err error) {
+	return appendDecrypt(nil, prv, c, s1, s2, opts)
+}
+
+// DecryptAppend decrypts an ECIES ciphertext like Decrypt, and appends the
+// message to dst, which is reallocated only if its capacity is too small.
+// The updated slice is returned. dst and c must not overlap. If the
+// ciphertext is rejected, the spare capacity of dst may be overwritten.
+func DecryptAppend(dst []byte, prv KeyProvider, c, s1, s2 []byte) ([]byte, error) {
+	return appendDecrypt(dst, prv, c, s1, s2, nil)
+}
+
+// appendDecrypt is DecryptWithOptions, appending the message to dst.
+func appendDecrypt(dst []byte, prv KeyProvider, c, s1, s2 []byte, opts *DecryptOptions) (m []byte, err error) {
 	pub := prv.Public()
+	if ob := startObservation(OpDecrypt, pub, len(c)); ob != nil {
+		defer func() { ob.done(len(m)-len(dst), err) }()
+	}
 	params := pub.Params
 	if params == nil {
 		if params = ParamsFromCurve(pub.Curve); params == nil {
@@ -272,62 +667,156 @@ func Decrypt(prv KeyProvider, c, s1, s2 []byte) (m []byte, err error) {
 			return
 		}
 	}
-	hash := params.Hash()
-
-	var kLen, hLen, mStart, mEnd int
-	hLen = hash.Size()
-	kLen = (pub.Curve.Params().BitSize + 7) / 8
-	switch c[0] {
-	case 2, 3:
-		// https://github.com/golang/go/blob/go1.19.5/src/crypto/elliptic/elliptic.go#L147
-		mStart = 1 + kLen
-	case 4:
-		// https://github.com/golang/go/blob/go1.19.5/src/crypto/elliptic/elliptic.go#L120
-		mStart = 1 + 2*kLen
-	default:
-		err = ErrInvalidPublicKey
-		return
+	return openWith(dst, prv, pub, params, c, s1, s2, opts)
+}
+
+// openWith is appendDecrypt with the public key of prv, and its parameters.
+func openWith(dst []byte, prv KeyProvider, pub *PublicKey, params *ECIESParams, c, s1, s2 []byte, opts *DecryptOptions) (m []byte, err error) {
+	if opts == nil {
+		opts = &DecryptOptions{}
 	}
-	if len(c) < (mStart + hLen + 1) {
-		err = ErrInvalidMessage
+	if err = enforceParams(opts.Policy, pub.Curve, params); err != nil {
 		return
 	}
-	mEnd = len(c) - hLen
+	if opts.Sender != nil {
+		if err = checkSender(opts.Sender, pub); err != nil {
+			return
+		}
+	}
+	policy := opts.pointFormats()
+
+	var hLen, mStart, mEnd int
+	var fail error
+	// The AEAD tag is opened along with the message.
+	_, tagLen := params.demOverhead()
+	hLen = tagLen
+	if params.AEAD != nil {
+		hLen = 0
+	}
+	if isEnvelope(c) {
+		c, fail = unwrapEnvelope(pub, params, c)
+	}
+	var recipient *recipientHeader
+	var validity *validityWindow
+	var stamp *messageStamp
+	var rest []byte
+	body := c
+	if opts.RecipientHeader && fail == nil {
+		if recipient, rest, fail = parseRecipientHeader(body); fail == nil {
+			body = rest
+			fail = recipient.check(pub)
+		}
+	}
+	if opts.Validity && fail == nil {
+		if validity, rest, fail = parseValidity(body); fail == nil {
+			body = rest
+		}
+	}
+	if opts.Timestamped && fail == nil {
+		if stamp, rest, fail = parseStamp(body); fail == nil {
+			body = rest
+		}
+	}
+	if len(body) < len(c) {
+		s2 = bindHeader(c[:len(c)-len(body)], s2)
+		c = body
+	}
+	if fail == nil {
+		mStart, fail = splitCiphertext(pub.Curve, params, c, policy)
+	}
 
-	R := new(PublicKey)
-	R.Curve = pub.Curve
-	R.X, R.Y = elliptic.Unmarshal(R.Curve, c[:mStart])
-	if R.X == nil {
-		err = ErrInvalidPublicKey
-		return
+	var R *PublicKey
+	if fail == nil {
+		R, fail = parseEncapsulation(pub, c[:mStart], policy)
 	}
-	if !R.Curve.IsOnCurve(R.X, R.Y) {
-		err = ErrInvalidCurve
-		return
+	mEnd = len(c) - hLen
+	if fail != nil {
+		// Keep going with our own public key in place of the ephemeral one,
+		// and the whole input in place of the encrypted message.
+		R = pub
+		mStart, mEnd = 0, len(c)
 	}
 
-	z, err := prv.GenerateShared(R)
+	sec, err := newSecrets()
 	if err != nil {
 		return
 	}
+	defer sec.wipe()
+	z, err := generateShared(prv, R)
+	if err != nil {
+		if fail != nil {
+			err = fail
+		}
+		return
+	}
+	z = sec.keep(z)
+	if opts.Sender != nil {
+		var zs []byte
+		if zs, err = generateShared(prv, opts.Sender); err != nil {
+			return
+		}
+		sec.keep(zs)
+		z, s1 = authenticate(z, zs, s1, opts.Sender, pub)
+		z = sec.keep(z)
+	}
+	if opts.BindContext {
+		s1, s2 = bindContext(pub.Curve, params, c[:mStart], s1, s2)
+	}
 
-	K, err := concatKDF(hash, z, s1, params.KeyLen+params.KeyLen)
+	K, err := params.deriveKeys(sec.keep(params.kdfInput(c[:mStart], z)), s1, params.derivedKeyLen())
 	if err != nil {
 		return
 	}
+	K = sec.keep(K)
 
-	Ke := K[:params.KeyLen]
-	Km := K[params.KeyLen:]
-	hash.Write(Km)
-	Km = hash.Sum(nil)
-	hash.Reset()
+	var Ke, d []byte
+	if params.AEAD != nil {
+		var openErr error
+		if m, openErr = appendAEADOpen(dst, params, K, c[mStart:mEnd], s2); openErr != nil && fail == nil {
+			fail = openErr
+		}
+		if fail == nil {
+			d = c[len(c)-tagLen:]
+		}
+	} else {
+		Ke = K[:params.KeyLen]
+		Km := sec.keep(macKey(params, K[params.KeyLen:]))
 
-	d := messageTag(params.Hash, Km, c[mStart:mEnd], s2)
-	if subtle.ConstantTimeCompare(c[mEnd:], d) != 1 {
-		err = ErrInvalidMessage
-		return
+		d = messageTag(params, Km, c[mStart:mEnd], s2)
+		if subtle.ConstantTimeCompare(c[mEnd:], d) != 1 && fail == nil {
+			fail = ErrInvalidMessage
+		}
+	}
+	if fail != nil {
+		return nil, fail
+	}
+	if validity != nil {
+		err = validity.check(opts.now())
+	}
+	if stamp != nil && err == nil {
+		err = stamp.check(opts, d)
+	}
+	if err != nil {
+		if params.AEAD != nil {
+			// Don't leave the message behind in the buffer of the caller.
+			clear(m[len(dst):])
+		}
+		return nil, err
 	}
 
-	m, err = symDecrypt(params, Ke, c[mStart:mEnd])
+	if params.AEAD == nil {
+		if m, err = appendSymDecrypt(dst, params, Ke, c[mStart:mEnd]); err != nil {
+			return
+		}
+	}
+	if opts.Padded {
+		var n int
+		if n, err = unpad(m[len(dst):]); err != nil {
+			clear(m[len(dst):])
+			return nil, err
+		}
+		clear(m[len(dst)+n:])
+		m = m[:len(dst)+n]
+	}
 	return
 }
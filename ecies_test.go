@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"math/big"
@@ -63,35 +64,6 @@ func TestKDF(t *testing.T) {
 
 var ErrBadSharedKeys = fmt.Errorf("ecies: shared keys don't match")
 
-// cmpPublic returns true if the two public keys represent the same pojnt.
-func cmpPublic(pub1, pub2 PublicKey) bool {
-	if pub1.X == nil || pub1.Y == nil {
-		fmt.Println(ErrInvalidPublicKey.Error())
-		return false
-	}
-	if pub2.X == nil || pub2.Y == nil {
-		fmt.Println(ErrInvalidPublicKey.Error())
-		return false
-	}
-	pub1Out := elliptic.Marshal(pub1.Curve, pub1.X, pub1.Y)
-	pub2Out := elliptic.Marshal(pub2.Curve, pub2.X, pub2.Y)
-
-	return bytes.Equal(pub1Out, pub2Out)
-}
-
-// cmpPrivate returns true if the two private keys are the same.
-func cmpPrivate(prv1, prv2 *PrivateKey) bool {
-	if prv1 == nil || prv1.D == nil {
-		return false
-	} else if prv2 == nil || prv2.D == nil {
-		return false
-	} else if prv1.D.Cmp(prv2.D) != 0 {
-		return false
-	} else {
-		return cmpPublic(prv1.PublicKey, prv2.PublicKey)
-	}
-}
-
 // Validate the ECDH component.
 func TestSharedKey(t *testing.T) {
 	for c := range paramsFromCurve {
@@ -214,133 +186,78 @@ func TestVectorSharedKey(t *testing.T) {
 	}
 }
 
-// Ensure a public key can be successfully marshalled and unmarshalled, and
-// that the decoded key is the same as the original.
-func TestMarshalPublic(t *testing.T) {
-	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	out, err := MarshalPublic(&prv.PublicKey)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	pub, err := UnmarshalPublic(out)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	if !cmpPublic(prv.PublicKey, *pub) {
-		fmt.Println("ecies: failed to unmarshal public key")
-		t.FailNow()
+// Benchmark the generation of P256 keys.
+func BenchmarkGenerateKeyP256(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := GenerateKey(rand.Reader, elliptic.P256(), nil); err != nil {
+			fmt.Println(err.Error())
+			b.FailNow()
+		}
 	}
 }
 
-// Ensure that a private key can be encoded into DER format, and that
-// the resulting key is properly parsed back into a public key.
-func TestMarshalPrivate(t *testing.T) {
-	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	out, err := MarshalPrivate(prv)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-	dumpEnc(out)
-
-	prv2, err := UnmarshalPrivate(out)
+// Benchmark the generation of P256 shared keys.
+func BenchmarkGenSharedKeyP256(b *testing.B) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
 	if err != nil {
 		fmt.Println(err.Error())
-		t.FailNow()
+		b.FailNow()
 	}
 
-	if !cmpPrivate(prv, prv2) {
-		fmt.Println("ecdh: private key import failed")
-		t.FailNow()
+	for i := 0; i < b.N; i++ {
+		_, err := prv.GenerateShared(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			b.FailNow()
+		}
 	}
 }
 
-// Ensure that a private key can be successfully encoded to PEM format, and
-// the resulting key is properly parsed back in.
-func TestPrivatePEM(t *testing.T) {
-	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+// Benchmark the encryption of 1Kb message using default P256 curve params.
+func BenchmarkEncrypt1KbP256(b *testing.B) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
 	if err != nil {
 		fmt.Println(err.Error())
-		t.FailNow()
+		b.FailNow()
 	}
 
-	out, err := ExportPrivatePEM(prv)
-	if err != nil {
+	message := make([]byte, 1024)
+	if _, err := rand.Read(message); err != nil {
 		fmt.Println(err.Error())
-		t.FailNow()
+		b.FailNow()
 	}
-	dumpEnc(out)
-
-	prv2, err := ImportPrivatePEM(out)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	} else if !cmpPrivate(prv, prv2) {
-		fmt.Println("ecdh: import from PEM failed")
-		t.FailNow()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			b.FailNow()
+		}
 	}
 }
 
-// Ensure that a public key can be successfully encoded to PEM format, and
-// the resulting key is properly parsed back in.
-func TestPublicPEM(t *testing.T) {
-	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	out, err := ExportPublicPEM(&prv.PublicKey)
+// Benchmark the decryption of 1Kb message encrypted using default P256 curve params.
+func BenchmarkDecrypt1KbP256(b *testing.B) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
 	if err != nil {
 		fmt.Println(err.Error())
-		t.FailNow()
+		b.FailNow()
 	}
-	dumpEnc(out)
 
-	pub2, err := ImportPublicPEM(out)
-	if err != nil {
+	message := make([]byte, 1024)
+	if _, err := rand.Read(message); err != nil {
 		fmt.Println(err.Error())
-		t.FailNow()
-	} else if !cmpPublic(prv.PublicKey, *pub2) {
-		fmt.Println("ecdh: import from PEM failed")
-		t.FailNow()
-	}
-}
-
-// Benchmark the generation of P256 keys.
-func BenchmarkGenerateKeyP256(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		if _, err := GenerateKey(rand.Reader, elliptic.P256(), nil); err != nil {
-			fmt.Println(err.Error())
-			b.FailNow()
-		}
+		b.FailNow()
 	}
-}
-
-// Benchmark the generation of P256 shared keys.
-func BenchmarkGenSharedKeyP256(b *testing.B) {
-	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
 	if err != nil {
 		fmt.Println(err.Error())
 		b.FailNow()
 	}
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_, err := prv.GenerateShared(&prv.PublicKey)
+		_, err := prv.Decrypt(rand.Reader, ct, nil, nil)
 		if err != nil {
 			fmt.Println(err.Error())
 			b.FailNow()
@@ -348,8 +265,8 @@ func BenchmarkGenSharedKeyP256(b *testing.B) {
 	}
 }
 
-// Benchmark the encryption of 1Kb message using default P256 curve params.
-func BenchmarkEncrypt1KbP256(b *testing.B) {
+// Benchmark the encryption of 1Kb message into a reused buffer.
+func BenchmarkEncryptAppend1KbP256(b *testing.B) {
 	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -361,8 +278,10 @@ func BenchmarkEncrypt1KbP256(b *testing.B) {
 		fmt.Println(err.Error())
 		b.FailNow()
 	}
+	buf := make([]byte, 0, 2048)
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		_, err := EncryptAppend(buf, rand.Reader, &prv.PublicKey, message, nil, nil)
 		if err != nil {
 			fmt.Println(err.Error())
 			b.FailNow()
@@ -370,8 +289,8 @@ func BenchmarkEncrypt1KbP256(b *testing.B) {
 	}
 }
 
-// Benchmark the decryption of 1Kb message encrypted using default P256 curve params.
-func BenchmarkDecrypt1KbP256(b *testing.B) {
+// Benchmark the decryption of 1Kb message into a reused buffer.
+func BenchmarkDecryptAppend1KbP256(b *testing.B) {
 	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -389,8 +308,10 @@ func BenchmarkDecrypt1KbP256(b *testing.B) {
 		b.FailNow()
 	}
 
+	buf := make([]byte, 0, len(message))
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_, err := prv.Decrypt(rand.Reader, ct, nil, nil)
+		_, err := DecryptAppend(buf, prv, ct, nil, nil)
 		if err != nil {
 			fmt.Println(err.Error())
 			b.FailNow()
@@ -563,53 +484,6 @@ func TestVectorEncryptDecrypt(t *testing.T) {
 	}
 }
 
-// TestMarshalEncryption validates the encode/decode produces a valid
-// ECIES encryption key.
-func TestMarshalEncryption(t *testing.T) {
-	prv1, err := GenerateKey(rand.Reader, DefaultCurve, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	out, err := MarshalPrivate(prv1)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	prv2, err := UnmarshalPrivate(out)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	message := []byte("Hello, world.")
-	ct, err := Encrypt(rand.Reader, &prv2.PublicKey, message, nil, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	pt, err := prv2.Decrypt(rand.Reader, ct, nil, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-	if !bytes.Equal(pt, message) {
-		fmt.Println("ecies: plaintext doesn't match message")
-		t.FailNow()
-	}
-
-	_, err = prv1.Decrypt(rand.Reader, ct, nil, nil)
-	if err != nil {
-		fmt.Println(err.Error())
-		t.FailNow()
-	}
-
-}
-
 type testCase struct {
 	Curve    elliptic.Curve
 	Name     string
@@ -719,10 +593,303 @@ func TestBasicKeyValidation(t *testing.T) {
 
 	for _, b := range badBytes {
 		ct[0] = b
-		_, err := prv.Decrypt(rand.Reader, ct, nil, nil)
+		_, err := DecryptWithOptions(prv, ct, nil, nil, &DecryptOptions{Strict: true})
 		if err != ErrInvalidPublicKey {
 			fmt.Println("ecies: validated an invalid key")
 			t.FailNow()
 		}
+		if b == pointHybridEven || b == pointHybridOdd {
+			// Accepted by default, see TestHybridPoint.
+			continue
+		}
+		_, err = prv.Decrypt(rand.Reader, ct, nil, nil)
+		if err != ErrInvalidPublicKey {
+			fmt.Println("ecies: validated an invalid key")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that ephemeral keys in the hybrid point format are validated and
+// accepted, unless in strict mode.
+func TestHybridPoint(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		message := []byte("Hello, world.")
+		ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		yLen := (c.Params().BitSize + 7) / 8
+		odd := ct[2*yLen]&1 == 1
+		good, bad := byte(pointHybridEven), byte(pointHybridOdd)
+		if odd {
+			good, bad = bad, good
+		}
+
+		ct[0] = good
+		pt, err := Decrypt(prv, ct, nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: plaintext doesn't match message")
+			t.FailNow()
+		}
+		if _, err = DecryptWithOptions(prv, ct, nil, nil, &DecryptOptions{Strict: true}); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: hybrid point accepted in strict mode")
+			t.FailNow()
+		}
+
+		ct[0] = bad
+		if _, err = Decrypt(prv, ct, nil, nil); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: hybrid point with a wrong parity accepted")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that malformed ciphertexts are still rejected with the right error
+// now that the decryption keeps going on failures.
+func TestDecryptMalformed(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	offCurve := append([]byte{}, ct...)
+	offCurve[1] ^= 0xff
+	badTag := append([]byte{}, ct...)
+	badTag[len(badTag)-1] ^= 0xff
+
+	for _, c := range []struct {
+		Name     string
+		Input    []byte
+		Expected error
+	}{
+		{"empty", nil, ErrInvalidMessage},
+		{"header only", ct[:65], ErrInvalidMessage},
+		{"truncated", ct[:len(ct)-len(message)-32], ErrInvalidMessage},
+		{"off curve", offCurve, ErrInvalidPublicKey},
+		{"bad tag", badTag, ErrInvalidMessage},
+	} {
+		if _, err := Decrypt(prv, c.Input, nil, nil); !errors.Is(err, c.Expected) {
+			fmt.Printf("ecies: unexpected error for %s: %v\n", c.Name, err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that the point format policy restricts the ephemeral key encodings.
+func TestPointFormatPolicy(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	message := []byte("Hello, world.")
+	uncompressed, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	rLen := 1 + 2*32
+	x, y := elliptic.Unmarshal(DefaultCurve, uncompressed[:rLen])
+	compressed := append(elliptic.MarshalCompressed(DefaultCurve, x, y), uncompressed[rLen:]...)
+	hybrid := append([]byte{}, uncompressed...)
+	hybrid[0] = pointHybridEven | byte(y.Bit(0))
+
+	for _, c := range []struct {
+		Policy   PointFormatPolicy
+		Accepted []bool
+	}{
+		{AllowAllPoints, []bool{true, true, true}},
+		{AllowCompressedPoints, []bool{true, true, false}},
+		{UncompressedPointsOnly, []bool{true, false, false}},
+	} {
+		opts := &DecryptOptions{PointFormats: c.Policy}
+		for i, ct := range [][]byte{uncompressed, compressed, hybrid} {
+			pt, err := DecryptWithOptions(prv, ct, nil, nil, opts)
+			if c.Accepted[i] && (err != nil || !bytes.Equal(pt, message)) {
+				fmt.Printf("ecies: policy %d rejected point format %d: %v\n", c.Policy, ct[0], err)
+				t.FailNow()
+			} else if !c.Accepted[i] && err != ErrInvalidPublicKey {
+				fmt.Printf("ecies: policy %d accepted point format %d\n", c.Policy, ct[0])
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure ephemeral keys are compressed on request, on each curve.
+func TestCompressEphemeral(t *testing.T) {
+	message := []byte("Hello, world.")
+	for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		uncompressed, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		ct, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil, &EncryptOptions{CompressEphemeral: true})
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if len(uncompressed)-len(ct) != (c.Params().BitSize+7)/8 || (ct[0] != pointCompressedEven && ct[0] != pointCompressedOdd) {
+			fmt.Println(name, "ecies: ephemeral key not compressed", len(ct))
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: message with compressed ephemeral key not decrypted", err)
+			t.FailNow()
+		}
+		opts := &DecryptOptions{PointFormats: UncompressedPointsOnly}
+		if _, err = DecryptWithOptions(prv, ct, nil, nil, opts); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: compressed ephemeral key accepted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that EncryptAppend and DecryptAppend keep the contents of dst, and
+// reuse its storage when large enough.
+func TestEncryptDecryptAppend(t *testing.T) {
+	message := []byte("Hello, world.")
+	prefix := []byte("prefix")
+	for i, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES128_GCM_SHA256, ECIES_AES128_ISO18033_SHA256} {
+		name := fmt.Sprint("params ", i)
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		buf := append(make([]byte, 0, 1024), prefix...)
+		ct, err := EncryptAppend(buf, rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if !bytes.HasPrefix(ct, prefix) || &ct[0] != &buf[0] {
+			fmt.Println(name, "ecies: ciphertext not appended in place")
+			t.FailNow()
+		}
+		pt, err := Decrypt(prv, ct[len(prefix):], nil, nil)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: appended ciphertext not decrypted", err)
+			t.FailNow()
+		}
+
+		out := append(make([]byte, 0, 1024), prefix...)
+		pt, err = DecryptAppend(out, prv, ct[len(prefix):], nil, nil)
+		if err != nil || !bytes.Equal(pt, append(prefix, message...)) || &pt[0] != &out[0] {
+			fmt.Println(name, "ecies: message not appended in place", err)
+			t.FailNow()
+		}
+		// Without room, dst is reallocated.
+		pt, err = DecryptAppend(prefix[:len(prefix):len(prefix)], prv, ct[len(prefix):], nil, nil)
+		if err != nil || !bytes.Equal(pt, append(prefix, message...)) {
+			fmt.Println(name, "ecies: message not appended", err)
+			t.FailNow()
+		}
+		ct[len(ct)-1] ^= 1
+		if _, err = DecryptAppend(out, prv, ct[len(prefix):], nil, nil); err != ErrInvalidMessage {
+			fmt.Println(name, "ecies: tampered ciphertext accepted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure the recommended parameters match the requested security level.
+func TestParamsForSecurityBits(t *testing.T) {
+	for _, c := range []struct {
+		Bits   int
+		Curve  elliptic.Curve
+		KeyLen int
+	}{
+		{128, elliptic.P256(), 16},
+		{192, elliptic.P384(), 24},
+		{256, elliptic.P521(), 32},
+	} {
+		for _, choose := range []func(int) (elliptic.Curve, *ECIESParams, error){ParamsForSecurityBits, ChooseParams} {
+			curve, params, err := choose(c.Bits)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			if curve != c.Curve || params.KeyLen != c.KeyLen || params.Hash().Size() != 2*c.KeyLen {
+				fmt.Println("ecies: unexpected parameters for", c.Bits, "bits")
+				t.FailNow()
+			}
+		}
+	}
+	if _, _, err := ParamsForSecurityBits(112); err != ErrInvalidParams {
+		fmt.Println("ecies: accepted an unsupported security level")
+		t.FailNow()
+	}
+}
+
+// Ensure encryptions with a given ephemeral key are reproducible, decrypt,
+// and leave the ephemeral key usable.
+func TestEncryptWithEphemeral(t *testing.T) {
+	for _, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES128_GCM_SHA256} {
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		R, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ct1, err := EncryptWithEphemeral(R, &prv.PublicKey, []byte("message"), []byte("s1"), []byte("s2"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ct2, err := EncryptWithEphemeral(R, &prv.PublicKey, []byte("message"), []byte("s1"), []byte("s2"))
+		if err != nil || !bytes.Equal(ct1, ct2) {
+			fmt.Println("ecies: encryption with a given ephemeral key not reproducible", err)
+			t.FailNow()
+		}
+		if !bytes.HasPrefix(ct1, elliptic.Marshal(elliptic.P256(), R.X, R.Y)) {
+			fmt.Println("ecies: ephemeral key not used")
+			t.FailNow()
+		}
+		if m, err := Decrypt(prv, ct1, []byte("s1"), []byte("s2")); err != nil || string(m) != "message" {
+			fmt.Println("ecies: message not restored", err)
+			t.FailNow()
+		}
+	}
+
+	R, _ := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	prv, _ := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if _, err := EncryptWithEphemeral(R, &prv.PublicKey, []byte("message"), nil, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: ephemeral key on another curve accepted", err)
+		t.FailNow()
 	}
 }
@@ -0,0 +1,158 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// Compatibility with the default configuration of the eciespy Python package
+// and its eciesjs and Rust ecies siblings, on secp256k1:
+//
+//	S = r·Q, the full ECDH point rather than its X coordinate
+//	K = HKDF-SHA-256(uncompressed R || uncompressed S), 32 bytes
+//	c, tag = AES-256-GCM(K, nonce, m), with a 16-byte nonce
+//	ciphertext = uncompressed R || nonce || tag || c
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	eciespyNonceSize = 16
+	eciespyTagSize   = 16
+)
+
+// EncryptEciespy encrypts a message to a secp256k1 public key as eciespy
+// does.
+func EncryptEciespy(rand io.Reader, pub *PublicKey, m []byte) (ct []byte, err error) {
+	if err = ValidatePublicKey(pub); err != nil {
+		return
+	}
+	if err = enforceEciespy(pub.Curve); err != nil {
+		return
+	}
+	R, err := GenerateKey(rand, pub.Curve, nil)
+	if err != nil {
+		return
+	}
+	x, y := affineOrNil(pub.Curve.ScalarMult(pub.X, pub.Y, R.D.Bytes()))
+	if x == nil {
+		err = ErrSharedKeyIsPointAtInfinity
+		return
+	}
+	eph := marshalPoint(pub.Curve, R.X, R.Y)
+	aead, err := eciespyAEAD(eph, pub.Curve.Params().BitSize, x, y)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, eciespyNonceSize)
+	if _, err = io.ReadFull(rand, nonce); err != nil {
+		return
+	}
+	sealed := aead.Seal(nil, nonce, m, nil)
+	body, tag := sealed[:len(m)], sealed[len(m):]
+
+	ct = make([]byte, 0, len(eph)+eciespyNonceSize+eciespyTagSize+len(m))
+	ct = append(ct, eph...)
+	ct = append(ct, nonce...)
+	ct = append(ct, tag...)
+	return append(ct, body...), nil
+}
+
+// eciespy uses AES-256-GCM and HKDF-SHA256, on secp256k1 only.
+func enforceEciespy(curve elliptic.Curve) error {
+	if curve != Secp256k1() {
+		return ErrInvalidCurve
+	}
+	return enforcePolicy(nil, SuiteEciespy, curve, sha256.Size, 32)
+}
+
+// DecryptEciespy decrypts a message encrypted by eciespy. The ephemeral key
+// may also be compressed, as eciespy writes it when configured to.
+//
+// As with DecryptBIE1, the key provider only reveals the X coordinate of the
+// ECDH point: the message is opened with both candidate points, and the one
+// which authenticates selected.
+func DecryptEciespy(prv KeyProvider, c []byte) (m []byte, err error) {
+	pub := prv.Public()
+	if err = enforceEciespy(pub.Curve); err != nil {
+		return
+	}
+	byteLen := (pub.Curve.Params().BitSize + 7) / 8
+	rLen := 1 + 2*byteLen
+	if len(c) > 0 && (c[0] == pointCompressedEven || c[0] == pointCompressedOdd) {
+		rLen = 1 + byteLen
+	}
+	if len(c) < rLen+eciespyNonceSize+eciespyTagSize {
+		err = ErrInvalidMessage
+		return
+	}
+	R := new(PublicKey)
+	R.Curve = pub.Curve
+	R.X, R.Y = unmarshalPoint(R.Curve, c[:rLen], AllowCompressedPoints)
+	if R.X == nil {
+		err = ErrInvalidPublicKey
+		return
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return
+	}
+	x, y := unmarshalPoint(pub.Curve, append([]byte{pointCompressedEven}, z...), AllowCompressedPoints)
+	if x == nil {
+		err = ErrInvalidMessage
+		return
+	}
+	eph := marshalPoint(R.Curve, R.X, R.Y)
+	nonce := c[rLen : rLen+eciespyNonceSize]
+	sealed := make([]byte, 0, len(c)-rLen-eciespyNonceSize)
+	sealed = append(sealed, c[rLen+eciespyNonceSize+eciespyTagSize:]...)
+	sealed = append(sealed, c[rLen+eciespyNonceSize:rLen+eciespyNonceSize+eciespyTagSize]...)
+
+	even, evenErr := eciespyOpen(eph, pub.Curve, x, y, nonce, sealed)
+	odd, oddErr := eciespyOpen(eph, pub.Curve, x, new(big.Int).Sub(pub.Curve.Params().P, y), nonce, sealed)
+	switch {
+	case evenErr == nil:
+		return even, nil
+	case oddErr == nil:
+		return odd, nil
+	}
+	return nil, ErrInvalidMessage
+}
+
+// eciespyOpen opens sealed, the ciphertext followed by its tag, with the
+// shared point (x, y).
+func eciespyOpen(eph []byte, curve elliptic.Curve, x, y *big.Int, nonce, sealed []byte) ([]byte, error) {
+	aead, err := eciespyAEAD(eph, curve.Params().BitSize, x, y)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// eciespyAEAD derives the AES-256-GCM cipher from the uncompressed ephemeral
+// key and shared point.
+func eciespyAEAD(eph []byte, bitSize int, x, y *big.Int) (cipher.AEAD, error) {
+	byteLen := (bitSize + 7) / 8
+	master := make([]byte, 0, len(eph)+1+2*byteLen)
+	master = append(master, eph...)
+	master = append(master, pointUncompressed)
+	master = append(master, x.FillBytes(make([]byte, byteLen))...)
+	master = append(master, y.FillBytes(make([]byte, byteLen))...)
+	defer wipe(master)
+	K := make([]byte, 32)
+	defer wipe(K)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, nil), K); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(K)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, eciespyNonceSize)
+}
@@ -0,0 +1,90 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that eciespy messages round trip, whichever the parity of their
+// shared point, with compressed ephemeral keys too, and that tampering is
+// detected. No ciphertexts produced by eciespy itself were available to pin
+// the format against.
+func TestEciespy(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, Secp256k1(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for i := 0; i < 16; i++ {
+		message := make([]byte, i*7)
+		rand.Read(message)
+		ct, err := EncryptEciespy(rand.Reader, &prv.PublicKey, message)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if len(ct) != 65+16+16+len(message) {
+			fmt.Println("ecies: eciespy ciphertext of the wrong size", len(ct))
+			t.FailNow()
+		}
+		if pt, err := DecryptEciespy(prv, ct); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: eciespy message not decrypted", err)
+			t.FailNow()
+		}
+
+		x, y := elliptic.Unmarshal(Secp256k1(), ct[:65])
+		compressed := append(elliptic.MarshalCompressed(Secp256k1(), x, y), ct[65:]...)
+		if pt, err := DecryptEciespy(prv, compressed); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: eciespy message with a compressed key not decrypted", err)
+			t.FailNow()
+		}
+
+		ct[len(ct)-1] ^= 1
+		if _, err = DecryptEciespy(prv, ct); err != ErrInvalidMessage {
+			fmt.Println("ecies: decrypted a tampered eciespy message", err)
+			t.FailNow()
+		}
+	}
+	if _, err = DecryptEciespy(prv, make([]byte, 96)); err == nil {
+		fmt.Println("ecies: decrypted a short eciespy message")
+		t.FailNow()
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptEciespy(rand.Reader, &other.PublicKey, []byte("message")); err != ErrInvalidCurve {
+		fmt.Println("ecies: encrypted an eciespy message on P-256", err)
+		t.FailNow()
+	}
+}
+
+// Ensure the eciespy suite can be forbidden by policy, and that messages
+// aren't encrypted to points off the curve.
+func TestPolicyEciespy(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, Secp256k1(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptEciespy(rand.Reader, offCurveKey(t, Secp256k1()), []byte("message")); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: encrypted to an off-curve point", err)
+		t.FailNow()
+	}
+	SetPolicy(&Policy{ForbiddenSuites: []string{SuiteEciespy}})
+	defer SetPolicy(nil)
+
+	if _, err = EncryptEciespy(rand.Reader, &prv.PublicKey, []byte("message")); !errors.Is(err, ErrPolicyViolation) {
+		fmt.Println("ecies: used a forbidden suite", err)
+		t.FailNow()
+	}
+}
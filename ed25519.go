@@ -0,0 +1,67 @@
+package ecies
+
+// Ed25519 keys as X25519 keys, through the birational map between the
+// twisted Edwards curve and Curve25519 (RFC 7748, section 4.1):
+//
+//	u = (1 + y) / (1 - y)
+//
+// The X25519 private key is the scalar of the Ed25519 key, the first half of
+// the SHA-512 of its seed (RFC 8032, section 5.1.5), clamped by X25519.
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"io"
+
+	"filippo.io/edwards25519"
+)
+
+// Ed25519PublicKeyToX25519 converts an Ed25519 public key to the X25519 public
+// key of the same identity. It returns ErrInvalidPublicKey if pub isn't a
+// valid point, or is of small order.
+func Ed25519PublicKeyToX25519(pub ed25519.PublicKey) (*PublicKey, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	p, err := new(edwards25519.Point).SetBytes(pub)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	// Small order points would give an all-zero shared secret.
+	if new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, ErrInvalidPublicKey
+	}
+	return DecompressPublicKey(X25519(), p.BytesMontgomery())
+}
+
+// Ed25519PrivateKeyToX25519 converts an Ed25519 private key to the X25519
+// private key of the same identity. Its public key is the conversion of the
+// Ed25519 public key by Ed25519PublicKeyToX25519.
+func Ed25519PrivateKeyToX25519(priv ed25519.PrivateKey) (*PrivateKey, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidPrivateKey
+	}
+	h := sha512.Sum512(priv.Seed())
+	return NewPrivateKey(X25519(), h[:x25519KeySize])
+}
+
+// EncryptToEd25519 encrypts a message to the X25519 conversion of an Ed25519
+// public key, as Encrypt does. The message can be decrypted with
+// DecryptWithEd25519, or with Decrypt and the converted private key.
+func EncryptToEd25519(rand io.Reader, pub ed25519.PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	xpub, err := Ed25519PublicKeyToX25519(pub)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(rand, xpub, m, s1, s2)
+}
+
+// DecryptWithEd25519 decrypts a message encrypted by EncryptToEd25519 with the
+// Ed25519 private key of the recipient.
+func DecryptWithEd25519(priv ed25519.PrivateKey, c, s1, s2 []byte) ([]byte, error) {
+	prv, err := Ed25519PrivateKeyToX25519(priv)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(prv, c, s1, s2)
+}
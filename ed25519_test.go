@@ -0,0 +1,72 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure that converted Ed25519 keys form an X25519 key pair, which checks the
+// birational map against the scalar derivation, and that messages round trip
+// through them.
+func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	xpub, err := Ed25519PublicKeyToX25519(pub)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	xprv, err := Ed25519PrivateKeyToX25519(priv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Equal(CompressPublicKey(xpub), CompressPublicKey(&xprv.PublicKey)) {
+		fmt.Println("ecies: converted Ed25519 keys don't match")
+		t.FailNow()
+	}
+
+	message := []byte("Hello, Ed25519.")
+	ct, err := EncryptToEd25519(rand.Reader, pub, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := DecryptWithEd25519(priv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: message not decrypted", err)
+		t.FailNow()
+	}
+	if pt, err := Decrypt(xprv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: message not decrypted with the converted key", err)
+		t.FailNow()
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = DecryptWithEd25519(other, ct, nil, nil); err == nil {
+		fmt.Println("ecies: message decrypted with another key")
+		t.FailNow()
+	}
+
+	// The identity, and a point of order 8.
+	identity := make([]byte, ed25519.PublicKeySize)
+	identity[0] = 1
+	small := []byte{
+		0xc7, 0x17, 0x6a, 0x70, 0x3d, 0x4d, 0xd8, 0x4f, 0xba, 0x3c, 0x0b, 0x76, 0x0d, 0x10, 0x67, 0x0f,
+		0x2a, 0x20, 0x53, 0xfa, 0x2c, 0x39, 0xcc, 0xc6, 0x4e, 0xc7, 0xfd, 0x77, 0x92, 0xac, 0x03, 0x7a,
+	}
+	for _, bad := range [][]byte{identity, small, pub[:16]} {
+		if _, err = EncryptToEd25519(rand.Reader, bad, message, nil, nil); err != ErrInvalidPublicKey {
+			fmt.Println("ecies: accepted an invalid Ed25519 key", err)
+			t.FailNow()
+		}
+	}
+}
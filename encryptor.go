@@ -0,0 +1,135 @@
+package ecies
+
+// Reusable encryptors and decryptors: the key, parameters and options are
+// checked once, rather than on every message, and shared by concurrent
+// callers.
+
+import (
+	"io"
+	"slices"
+)
+
+// Encryptor encrypts messages to one recipient, with fixed parameters and
+// options. Its methods may be called concurrently.
+type Encryptor struct {
+	pub    *PublicKey
+	params *ECIESParams
+	opts   EncryptOptions
+}
+
+// NewEncryptor returns an encryptor to pub with the parameters params, or
+// those of the key if nil, and the options opts, which may be nil. The key is
+// validated, and the parameters and options checked, once here: errors are
+// those of EncryptWithOptions. The policies are enforced again by every
+// encryption, as the global one may change. opts is copied.
+func NewEncryptor(pub *PublicKey, params *ECIESParams, opts *EncryptOptions) (*Encryptor, error) {
+	if err := ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		if params = pub.Params; params == nil {
+			params = ParamsFromCurve(pub.Curve)
+		}
+	}
+	if params == nil || !params.complete() {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	e := &Encryptor{params: params}
+	if opts != nil {
+		e.opts = *opts
+		e.opts.MessageID = slices.Clone(opts.MessageID)
+	}
+	if e.opts.Hedged && e.opts.Pool != nil {
+		return nil, ErrInvalidParams
+	}
+	if err := enforceParams(e.opts.Policy, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	if e.opts.Sender != nil {
+		if err := checkSender(e.opts.Sender.Public(), pub); err != nil {
+			return nil, err
+		}
+	}
+	// The ciphertexts carry the parameters, as they would those of the key.
+	key := *pub
+	key.Params = params
+	e.pub = &key
+	return e, nil
+}
+
+// PublicKey returns the recipient of the encryptor, with its parameters.
+func (e *Encryptor) PublicKey() *PublicKey {
+	return e.pub
+}
+
+// Encrypt encrypts a message like EncryptWithOptions.
+func (e *Encryptor) Encrypt(rand io.Reader, m, s1, s2 []byte) ([]byte, error) {
+	return e.EncryptAppend(nil, rand, m, s1, s2)
+}
+
+// EncryptAppend encrypts a message like Encrypt, and appends the ciphertext
+// to dst as EncryptAppend does.
+func (e *Encryptor) EncryptAppend(dst []byte, rand io.Reader, m, s1, s2 []byte) (ct []byte, err error) {
+	if ob := startObservation(OpEncrypt, e.pub, len(m)); ob != nil {
+		defer func() { ob.done(len(ct)-len(dst), err) }()
+	}
+	opts := e.opts
+	return sealTo(dst, rand, e.pub, e.params, m, s1, s2, &opts)
+}
+
+// Decryptor decrypts messages with one private key, with fixed options. Its
+// methods may be called concurrently if those of the key may.
+type Decryptor struct {
+	prv    KeyProvider
+	pub    *PublicKey
+	params *ECIESParams
+	opts   DecryptOptions
+}
+
+// NewDecryptor returns a decryptor with prv and the options opts, which may
+// be nil. The parameters of the key and the options are checked once here:
+// errors are those of DecryptWithOptions. The policies are enforced again by
+// every decryption, as the global one may change. opts is copied.
+func NewDecryptor(prv KeyProvider, opts *DecryptOptions) (*Decryptor, error) {
+	if prv == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	pub := prv.Public()
+	if pub == nil || pub.Curve == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	d := &Decryptor{prv: prv, pub: pub, params: params}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if err := enforceParams(d.opts.Policy, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	if d.opts.Sender != nil {
+		if err := checkSender(d.opts.Sender, pub); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Decrypt decrypts a ciphertext like DecryptWithOptions.
+func (d *Decryptor) Decrypt(c, s1, s2 []byte) ([]byte, error) {
+	return d.DecryptAppend(nil, c, s1, s2)
+}
+
+// DecryptAppend decrypts a ciphertext like Decrypt, and appends the message
+// to dst as DecryptAppend does.
+func (d *Decryptor) DecryptAppend(dst, c, s1, s2 []byte) (m []byte, err error) {
+	if ob := startObservation(OpDecrypt, d.pub, len(c)); ob != nil {
+		defer func() { ob.done(len(m)-len(dst), err) }()
+	}
+	opts := d.opts
+	return openWith(dst, d.prv, d.pub, d.params, c, s1, s2, &opts)
+}
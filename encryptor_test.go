@@ -0,0 +1,95 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Ensure encryptors and decryptors round trip concurrently, with their
+// parameters and options.
+func TestEncryptor(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	opts := &EncryptOptions{RecipientHeader: true, Padding: PadBlock, PaddingBlock: 32}
+	enc, err := NewEncryptor(&prv.PublicKey, nil, opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	opts.RecipientHeader = false
+	dec, err := NewDecryptor(prv, &DecryptOptions{RecipientHeader: true, Padded: true})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := []byte(fmt.Sprintf("message %d", i))
+			ct, err := enc.Encrypt(rand.Reader, message, nil, []byte("s2"))
+			if err == nil {
+				var m []byte
+				if m, err = dec.Decrypt(ct, nil, []byte("s2")); err == nil && !bytes.Equal(m, message) {
+					err = fmt.Errorf("ecies: message %d not restored", i)
+				}
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+	}
+
+	// The parameters of the encryptor take over those of the key.
+	params := ECIES_AES256_SHA512
+	enc, err = NewEncryptor(&prv.PublicKey, params, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if enc.PublicKey().Params != params || prv.PublicKey.Params == params {
+		fmt.Println("ecies: unexpected encryptor parameters")
+		t.FailNow()
+	}
+	ct, err := enc.Encrypt(rand.Reader, []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	key := *prv
+	key.PublicKey.Params = params
+	if m, err := Decrypt(&key, ct, nil, nil); err != nil || string(m) != "message" {
+		fmt.Println("ecies: message not restored", err)
+		t.FailNow()
+	}
+
+	if _, err = NewEncryptor(&PublicKey{Curve: elliptic.P256()}, nil, nil); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: invalid key accepted", err)
+		t.FailNow()
+	}
+	if _, err = NewEncryptor(&prv.PublicKey, nil, &EncryptOptions{Hedged: true, Pool: &EphemeralPool{}}); err != ErrInvalidParams {
+		fmt.Println("ecies: invalid options accepted", err)
+		t.FailNow()
+	}
+	other, _ := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if _, err = NewDecryptor(prv, &DecryptOptions{Sender: &other.PublicKey}); err != ErrInvalidCurve {
+		fmt.Println("ecies: invalid sender accepted", err)
+		t.FailNow()
+	}
+}
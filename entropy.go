@@ -0,0 +1,41 @@
+package ecies
+
+// The entropy source of the operations which don't take a random source, and
+// of those given a nil one: crypto/rand.Reader, unless replaced, e.g. by the
+// hardware generator of a device whose runtime has no system source.
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+)
+
+type entropyHolder struct {
+	r io.Reader
+}
+
+var globalEntropy atomic.Value // entropyHolder
+
+// SetEntropySource replaces crypto/rand.Reader as the random source of the
+// package, for the operations which don't take one and those given a nil one.
+// It must be safe for concurrent use, and a cryptographically secure source.
+// A nil source restores crypto/rand.Reader.
+func SetEntropySource(r io.Reader) {
+	globalEntropy.Store(entropyHolder{r})
+}
+
+// CurrentEntropySource returns the random source of the package.
+func CurrentEntropySource() io.Reader {
+	return entropy(nil)
+}
+
+// entropy returns r, or the random source of the package if r is nil.
+func entropy(r io.Reader) io.Reader {
+	if r != nil {
+		return r
+	}
+	if h, ok := globalEntropy.Load().(entropyHolder); ok && h.r != nil {
+		return h.r
+	}
+	return rand.Reader
+}
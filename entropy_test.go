@@ -0,0 +1,65 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// countingReader counts the bytes read from crypto/rand.
+type countingReader struct {
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.n.Add(int64(len(p)))
+	return rand.Read(p)
+}
+
+// Ensure the entropy source replaces crypto/rand for nil random sources, and
+// that raw keys and compact ciphertexts round trip without the ASN.1 formats.
+func TestEntropySource(t *testing.T) {
+	r := new(countingReader)
+	SetEntropySource(r)
+	defer SetEntropySource(nil)
+	if CurrentEntropySource() != io.Reader(r) {
+		fmt.Println("ecies: entropy source not set")
+		t.FailNow()
+	}
+
+	prv, err := GenerateKey(nil, elliptic.P256(), nil)
+	if err != nil || r.n.Load() == 0 {
+		fmt.Println("ecies: entropy source not used to generate keys", err)
+		t.FailNow()
+	}
+	restored, err := NewPrivateKey(elliptic.P256(), prv.D.FillBytes(make([]byte, 32)))
+	if err != nil || !restored.Equal(prv) {
+		fmt.Println("ecies: raw private key not restored", err)
+		t.FailNow()
+	}
+	pub, err := ParsePublicKeyBytes(elliptic.P256(), prv.PublicKey.BytesCompressed())
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	n := r.n.Load()
+	ct, err := EncryptWithOptions(nil, pub, []byte("message"), nil, nil, &EncryptOptions{CompressEphemeral: true})
+	if err != nil || r.n.Load() == n {
+		fmt.Println("ecies: entropy source not used to encrypt", err)
+		t.FailNow()
+	}
+	if m, err := Decrypt(restored, ct, nil, nil); err != nil || string(m) != "message" {
+		fmt.Println("ecies: message not restored", err)
+		t.FailNow()
+	}
+
+	SetEntropySource(nil)
+	if CurrentEntropySource() != rand.Reader {
+		fmt.Println("ecies: crypto/rand not restored")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,185 @@
+package ecies
+
+// Envelope encryption: the payload is sealed once with AES-256-GCM under a
+// random data encryption key (DEK), which is wrapped with WrapKey for each
+// recipient.
+//
+// The binary encoding is the version byte, the 16-bit big-endian number of
+// recipients, then for each of them the SHA-256 fingerprint of its public key
+// and the 16-bit big-endian length of the wrapped DEK followed by it, then the
+// GCM nonce and the sealed payload. The encoding up to the nonce is
+// authenticated as additional data, along with the caller's, whatever the
+// encoding of the envelope.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	envelopeFormatVersion = 1
+	dekSize               = 32
+	gcmNonceSize          = 12
+)
+
+// Envelope is a payload encrypted once for several recipients. It is encoded
+// with MarshalBinary, or as JSON with encoding/json.
+//
+// Envelopes are unrelated to the versioned ciphertexts of EncodeEnvelope.
+type Envelope struct {
+	Version    int                 `json:"version"`
+	Recipients []EnvelopeRecipient `json:"recipients"`
+	Nonce      []byte              `json:"nonce"`
+	Ciphertext []byte              `json:"ciphertext"`
+}
+
+// EnvelopeRecipient holds the DEK of an envelope, wrapped for a recipient.
+type EnvelopeRecipient struct {
+	// KeyID is the SHA-256 fingerprint of the public key of the recipient.
+	KeyID      []byte `json:"kid"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// SealEnvelope encrypts a payload for several recipients, which may use
+// different curves and parameters. The additional data ad is authenticated
+// but not stored: it must be given to Open as well.
+func SealEnvelope(rand io.Reader, pubs []*PublicKey, payload, ad []byte) (*Envelope, error) {
+	if len(pubs) == 0 || len(pubs) > maxRecipients {
+		return nil, ErrNoRecipients
+	}
+	dek := make([]byte, dekSize)
+	if _, err := io.ReadFull(rand, dek); err != nil {
+		return nil, err
+	}
+	e := &Envelope{Version: envelopeFormatVersion}
+	for _, pub := range pubs {
+		wrapped, err := WrapKey(rand, pub, dek)
+		if err != nil {
+			return nil, err
+		}
+		e.Recipients = append(e.Recipients, EnvelopeRecipient{KeyID: keyFingerprint(pub), WrappedKey: wrapped})
+	}
+	header, err := e.header()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAESGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand, e.Nonce); err != nil {
+		return nil, err
+	}
+	e.Ciphertext = aead.Seal(nil, e.Nonce, payload, append(header, ad...))
+	return e, nil
+}
+
+// Open decrypts the payload of the envelope with the DEK wrapped for the key
+// of prv. It returns ErrNotRecipient if the envelope has no DEK for it.
+func (e *Envelope) Open(prv KeyProvider, ad []byte) ([]byte, error) {
+	header, err := e.header()
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != gcmNonceSize {
+		return nil, ErrInvalidMessage
+	}
+	id := keyFingerprint(prv.Public())
+	found := false
+	var dek []byte
+	for _, r := range e.Recipients {
+		if !bytes.Equal(r.KeyID, id) {
+			continue
+		}
+		found = true
+		if dek, err = UnwrapKey(prv, r.WrappedKey); err == nil && len(dek) == dekSize {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNotRecipient
+	}
+	if len(dek) != dekSize {
+		return nil, ErrInvalidMessage
+	}
+	aead, err := newAESGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := aead.Open(nil, e.Nonce, e.Ciphertext, append(header, ad...))
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	return payload, nil
+}
+
+// header returns the binary encoding of the envelope up to the nonce.
+func (e *Envelope) header() ([]byte, error) {
+	if e.Version != envelopeFormatVersion {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	if len(e.Recipients) == 0 || len(e.Recipients) > maxRecipients {
+		return nil, ErrNoRecipients
+	}
+	out := []byte{envelopeFormatVersion}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.Recipients)))
+	for _, r := range e.Recipients {
+		if len(r.KeyID) != sha256.Size || len(r.WrappedKey) > maxWrappedKeyLen {
+			return nil, ErrInvalidMessage
+		}
+		out = append(out, r.KeyID...)
+		out = appendField(out, r.WrappedKey)
+	}
+	return out, nil
+}
+
+// MarshalBinary encodes the envelope in its binary format.
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	header, err := e.header()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+len(e.Nonce)+len(e.Ciphertext))
+	out = append(out, header...)
+	out = append(out, e.Nonce...)
+	return append(out, e.Ciphertext...), nil
+}
+
+// UnmarshalBinary decodes an envelope encoded by MarshalBinary.
+func (e *Envelope) UnmarshalBinary(in []byte) error {
+	if len(in) < 3 {
+		return ErrInvalidMessage
+	}
+	if in[0] != envelopeFormatVersion {
+		return ErrUnsupportedECIESParameters
+	}
+	n := int(binary.BigEndian.Uint16(in[1:]))
+	if n == 0 {
+		return ErrNoRecipients
+	}
+	out := Envelope{Version: int(in[0])}
+	rest := in[3:]
+	for i := 0; i < n; i++ {
+		if len(rest) < sha256.Size {
+			return ErrInvalidMessage
+		}
+		r := EnvelopeRecipient{KeyID: append([]byte{}, rest[:sha256.Size]...)}
+		wrapped, next, ok := readField(rest[sha256.Size:])
+		if !ok {
+			return ErrInvalidMessage
+		}
+		r.WrappedKey = append([]byte{}, wrapped...)
+		out.Recipients = append(out.Recipients, r)
+		rest = next
+	}
+	if len(rest) < gcmNonceSize {
+		return ErrInvalidMessage
+	}
+	out.Nonce = append([]byte{}, rest[:gcmNonceSize]...)
+	out.Ciphertext = append([]byte{}, rest[gcmNonceSize:]...)
+	*e = out
+	return nil
+}
@@ -0,0 +1,86 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// Ensure that envelopes open for each recipient, through both encodings, and
+// that their recipient list and additional data are authenticated.
+func TestEnvelope(t *testing.T) {
+	var prvs []*PrivateKey
+	var pubs []*PublicKey
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P521(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prvs = append(prvs, prv)
+		pubs = append(pubs, &prv.PublicKey)
+	}
+	payload := make([]byte, 100000)
+	rand.Read(payload)
+	ad := []byte("bucket/object")
+
+	e, err := SealEnvelope(rand.Reader, pubs, payload, ad)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	bin, err := e.MarshalBinary()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	js, err := json.Marshal(e)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var fromBin, fromJSON Envelope
+	if err = fromBin.UnmarshalBinary(bin); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = json.Unmarshal(js, &fromJSON); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, env := range []*Envelope{e, &fromBin, &fromJSON} {
+		for _, prv := range prvs {
+			if pt, err := env.Open(prv, ad); err != nil || !bytes.Equal(pt, payload) {
+				fmt.Println("ecies: envelope not opened", err)
+				t.FailNow()
+			}
+		}
+	}
+
+	if _, err = e.Open(prvs[0], []byte("other/object")); err != ErrInvalidMessage {
+		fmt.Println("ecies: envelope opened with other additional data", err)
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = e.Open(other, ad); err != ErrNotRecipient {
+		fmt.Println("ecies: envelope opened by another key", err)
+		t.FailNow()
+	}
+	// Dropping a recipient invalidates the envelope.
+	fromBin.Recipients = fromBin.Recipients[1:]
+	if _, err = fromBin.Open(prvs[1], ad); err != ErrInvalidMessage {
+		fmt.Println("ecies: envelope with a dropped recipient opened", err)
+		t.FailNow()
+	}
+	if err = fromBin.UnmarshalBinary(bin[:len(bin)-len(e.Ciphertext)-1]); err != ErrInvalidMessage {
+		fmt.Println("ecies: truncated envelope decoded", err)
+		t.FailNow()
+	}
+}
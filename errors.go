@@ -0,0 +1,123 @@
+package ecies
+
+// Typed errors: each sentinel error of the package has a kind, telling
+// callers what went wrong in broad terms, and errors refining a sentinel wrap
+// the underlying cause, e.g. an encoding/asn1 error. Both work with errors.Is
+// and errors.As:
+//
+//	errors.Is(err, ErrInvalidMessage)  // the sentinel, or a refinement of it
+//	errors.Is(err, KindAuthentication) // any error of that kind
+//	var e *Error; errors.As(err, &e)   // e.Kind, e.Err
+//
+// ECIES can't tell a tampered ciphertext from one decrypted with the wrong
+// key of the right curve: both fail the authentication of the message.
+
+import "errors"
+
+// ErrorKind classifies the errors of the package. It is itself an error, so
+// that errors.Is(err, kind) tells whether err is of that kind.
+type ErrorKind int
+
+const (
+	// KindAuthentication errors are ciphertexts or signatures which don't
+	// authenticate: tampered with, or meant for another key.
+	KindAuthentication ErrorKind = iota + 1
+	// KindEncoding errors are malformed encodings of keys, ciphertexts or
+	// parameters, such as truncated messages or invalid DER.
+	KindEncoding
+	// KindKey errors are invalid keys, or keys which don't fit, e.g. on
+	// another curve than the ciphertext or the other key.
+	KindKey
+	// KindParams errors are invalid, unsupported or disallowed parameters.
+	KindParams
+	// KindKDF errors are failures of the key derivation.
+	KindKDF
+	// KindValidity errors are messages outside of their validity, stale or
+	// replayed.
+	KindValidity
+	// KindUnsupported errors are formats or features not supported, by the
+	// package, the key or the platform.
+	KindUnsupported
+	// KindState errors are uses of closed or exhausted objects.
+	KindState
+)
+
+var kindNames = map[ErrorKind]string{
+	KindAuthentication: "authentication failed",
+	KindEncoding:       "malformed encoding",
+	KindKey:            "invalid key",
+	KindParams:         "invalid parameters",
+	KindKDF:            "key derivation failed",
+	KindValidity:       "message not valid",
+	KindUnsupported:    "not supported",
+	KindState:          "invalid state",
+}
+
+func (k ErrorKind) Error() string {
+	if name, ok := kindNames[k]; ok {
+		return "ecies: " + name
+	}
+	return "ecies: unknown error"
+}
+
+// Error is the type of the errors of the package.
+type Error struct {
+	Kind ErrorKind
+	// Err is the underlying cause, if any.
+	Err error
+
+	msg string
+	// base is the sentinel error refined, if any.
+	base *Error
+}
+
+// newError returns a sentinel error.
+func newError(kind ErrorKind, msg string) error {
+	return &Error{Kind: kind, msg: msg}
+}
+
+// wrapError refines the sentinel error with its cause.
+func wrapError(sentinel, cause error) error {
+	return refineError(sentinel, sentinel.(*Error).Kind, cause)
+}
+
+// refineError refines the sentinel error with its cause and another kind.
+func refineError(sentinel error, kind ErrorKind, cause error) error {
+	s := sentinel.(*Error)
+	return &Error{Kind: kind, Err: cause, msg: s.msg, base: s}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.msg + ": " + e.Err.Error()
+	}
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e is, or refines, the target sentinel error, or is of
+// the target kind.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorKind:
+		return e.Kind == t
+	case *Error:
+		return e == t || e.base == t
+	}
+	return false
+}
+
+// errTrailingData is the cause of DER encodings followed by extra bytes.
+var errTrailingData = errors.New("trailing data after DER encoding")
+
+// asn1Error refines the sentinel error with the cause of a failed
+// asn1.Unmarshal: its error, or else trailing data.
+func asn1Error(sentinel, err error) error {
+	if err == nil {
+		err = errTrailingData
+	}
+	return refineError(sentinel, KindEncoding, err)
+}
@@ -0,0 +1,107 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// Ensure that the errors of Decrypt tell malformed, tampered and mismatched
+// ciphertexts apart, by sentinel and by kind.
+func TestErrorKinds(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("Hello, world."), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	otherCt, err := Encrypt(rand.Reader, &other.PublicKey, []byte("Hello, world."), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	tampered := append([]byte{}, ct...)
+	tampered[len(tampered)-1] ^= 1
+
+	for _, c := range []struct {
+		Name     string
+		Key      *PrivateKey
+		Input    []byte
+		Sentinel error
+		Kind     ErrorKind
+	}{
+		{"truncated", prv, ct[:70], ErrInvalidMessage, KindEncoding},
+		{"tampered", prv, tampered, ErrInvalidMessage, KindAuthentication},
+		{"other curve", prv, otherCt, ErrInvalidPublicKey, KindKey},
+	} {
+		_, err := Decrypt(c.Key, c.Input, nil, nil)
+		var e *Error
+		if !errors.Is(err, c.Sentinel) || !errors.Is(err, c.Kind) || !errors.As(err, &e) || e.Kind != c.Kind {
+			fmt.Println("ecies: unexpected error for", c.Name, err)
+			t.FailNow()
+		}
+		for _, kind := range []ErrorKind{KindEncoding, KindAuthentication, KindKey} {
+			if kind != c.Kind && errors.Is(err, kind) {
+				fmt.Println("ecies: error of several kinds for", c.Name, err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure that the causes of failures are wrapped.
+func TestErrorCauses(t *testing.T) {
+	_, err := UnmarshalPublic([]byte{0x30, 0x03, 0x01})
+	var syntax asn1.SyntaxError
+	if !errors.Is(err, ErrInvalidPublicKey) || !errors.Is(err, KindEncoding) || !errors.As(err, &syntax) {
+		fmt.Println("ecies: ASN.1 error not wrapped", err)
+		t.FailNow()
+	}
+
+	der, err := MarshalPrivatePKCS8(mustGenerateKey(t))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = UnmarshalPrivatePKCS8(append(der, 0)); !errors.Is(err, ErrInvalidPrivateKey) || !errors.Is(err, errTrailingData) {
+		fmt.Println("ecies: trailing data not reported", err)
+		t.FailNow()
+	}
+
+	cause := errors.New("hardware KDF unavailable")
+	params := *ECIES_AES128_SHA256
+	params.KDF = func(hash.Hash, []byte, []byte, int) ([]byte, error) {
+		return nil, cause
+	}
+	prv := mustGenerateKey(t)
+	prv.PublicKey.Params = &params
+	if _, err = Encrypt(rand.Reader, &prv.PublicKey, []byte("Hello, world."), nil, nil); !errors.Is(err, ErrKeyDerivation) || !errors.Is(err, cause) {
+		fmt.Println("ecies: KDF error not wrapped", err)
+		t.FailNow()
+	}
+}
+
+func mustGenerateKey(t *testing.T) *PrivateKey {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	return prv
+}
@@ -0,0 +1,263 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// File encryption for backup tools: files are encrypted in the chunked
+// stream format of NewEncryptingWriter, and written atomically, to a
+// temporary file renamed over the destination once complete, with the
+// permissions of the source.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FileOptions tune EncryptFile.
+type FileOptions struct {
+	// Resume keeps the partial output of an interrupted encryption, in the
+	// destination path with a ".partial" suffix, and carries on from its
+	// last complete chunk when called again with the same source and
+	// destination, if the source hasn't changed meanwhile. The stream key
+	// is kept until then in a ".partial.state" file readable by its owner
+	// only: it decrypts the partial output, so it must be protected as
+	// the source file is.
+	Resume bool
+}
+
+// resumeState is the content of the state file of a resumable encryption.
+type resumeState struct {
+	Header  []byte `json:"header"`
+	Secret  []byte `json:"secret"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// EncryptFile encrypts the file src for pub into dst, as NewEncryptingWriter
+// does. dst is only replaced once its encryption is complete, and gets the
+// permissions of src. If opts is nil, the default options are used.
+func EncryptFile(pub *PublicKey, src, dst string, opts *FileOptions) error {
+	if opts == nil {
+		opts = &FileOptions{}
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	var out *os.File
+	var stream *EncryptingWriter
+	if opts.Resume {
+		out, stream, err = resumeEncryption(pub, in, fi, dst)
+	} else {
+		out, stream, err = createEncryption(pub, dst)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(stream, in); err == nil {
+		err = stream.Close()
+	}
+	if err == nil {
+		err = commitFile(out, fi.Mode().Perm(), dst)
+	} else {
+		out.Close()
+	}
+	switch {
+	case err != nil && !opts.Resume:
+		os.Remove(out.Name())
+	case err == nil && opts.Resume:
+		os.Remove(out.Name() + ".state")
+	}
+	return err
+}
+
+// createEncryption starts the encryption of dst in a temporary file.
+func createEncryption(pub *PublicKey, dst string) (*os.File, *EncryptingWriter, error) {
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := newFileStream(pub, out, nil)
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, nil, err
+	}
+	return out, stream, nil
+}
+
+// newFileStream starts a stream for pub in out. If state is set, the stream
+// secret is saved in it before any chunk is written.
+func newFileStream(pub *PublicKey, out *os.File, state *resumeState) (*EncryptingWriter, error) {
+	params, z, header, err := startStream(pub)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(z)
+	if _, err = out.Write(header); err != nil {
+		return nil, err
+	}
+	if state != nil {
+		state.Header, state.Secret = header, z
+		if err = saveResumeState(out.Name()+".state", state); err != nil {
+			return nil, err
+		}
+	}
+	return continueStream(params, z, header, out, 0)
+}
+
+// resumeEncryption opens the partial output of dst, carrying on from the
+// last complete chunk it holds which isn't the last chunk of the stream, or
+// starts it over if it can't be resumed. The input is positioned to match.
+func resumeEncryption(pub *PublicKey, in *os.File, fi os.FileInfo, dst string) (*os.File, *EncryptingWriter, error) {
+	partial := dst + ".partial"
+	state := &resumeState{Size: fi.Size(), ModTime: fi.ModTime().UnixNano()}
+	out, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := reopenFileStream(pub, in, out, state)
+	if err == nil && stream == nil {
+		// Nothing to resume: start over.
+		if err = out.Truncate(0); err == nil {
+			if _, err = out.Seek(0, io.SeekStart); err == nil {
+				stream, err = newFileStream(pub, out, state)
+			}
+		}
+	}
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+	return out, stream, nil
+}
+
+// reopenFileStream returns the stream of a partial output saved in its state
+// file, positioned after its last complete chunk, or nil if there is none.
+func reopenFileStream(pub *PublicKey, in, out *os.File, want *resumeState) (*EncryptingWriter, error) {
+	saved, err := loadResumeState(out.Name() + ".state")
+	if err != nil || saved.Size != want.Size || saved.ModTime != want.ModTime {
+		return nil, nil
+	}
+	defer wipe(saved.Secret)
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, nil
+		}
+	}
+	head := make([]byte, len(saved.Header))
+	if _, err = io.ReadFull(out, head); err != nil || !bytes.Equal(head, saved.Header) {
+		return nil, nil
+	}
+	fi, err := out.Stat()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := continueStream(params, saved.Secret, saved.Header, out, 0)
+	if err != nil {
+		return nil, nil
+	}
+	// The chunk holding the end of the input is sealed as the last one,
+	// so it is always written again.
+	chunks := (fi.Size() - int64(len(saved.Header))) / int64(streamChunkSize+stream.aead.Overhead())
+	if last := max(want.Size-1, 0) / streamChunkSize; chunks > last {
+		chunks = last
+	}
+	end := int64(len(saved.Header)) + chunks*int64(streamChunkSize+stream.aead.Overhead())
+	if err = out.Truncate(end); err != nil {
+		return nil, err
+	}
+	if _, err = out.Seek(end, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err = in.Seek(chunks*streamChunkSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+	stream.counter = uint64(chunks)
+	return stream, nil
+}
+
+func saveResumeState(name string, state *resumeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	defer wipe(data)
+	return os.WriteFile(name, data, 0o600)
+}
+
+func loadResumeState(name string) (*resumeState, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(data)
+	state := new(resumeState)
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if len(state.Header) == 0 || len(state.Secret) == 0 {
+		return nil, errors.New("incomplete resume state")
+	}
+	return state, nil
+}
+
+// commitFile syncs and closes out, sets its permissions and renames it to
+// dst.
+func commitFile(out *os.File, perm os.FileMode, dst string) error {
+	err := out.Sync()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	return err
+}
+
+// DecryptFile decrypts the file src, encrypted by EncryptFile or written by
+// NewEncryptingWriter, into dst. The message is written to a temporary file
+// readable by its owner only, which only replaces dst, with the permissions
+// of src, once the whole file is authenticated.
+func DecryptFile(prv KeyProvider, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := NewDecryptingReader(prv, in)
+	if err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, r); err == nil {
+		err = commitFile(out, fi.Mode().Perm(), dst)
+	} else {
+		out.Close()
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Ensure files are encrypted and decrypted with the permissions of their
+// source, and that interrupted encryptions resume where they stopped.
+func TestEncryptFile(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.tar")
+	enc := filepath.Join(dir, "backup.tar.ecies")
+	dec := filepath.Join(dir, "restored.tar")
+	message := make([]byte, 3*streamChunkSize+100)
+	rand.Read(message)
+	if err = os.WriteFile(src, message, 0o640); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	check := func() {
+		if err := DecryptFile(prv, enc, dec); err != nil {
+			fmt.Println("ecies: file not decrypted", err)
+			t.FailNow()
+		}
+		got, err := os.ReadFile(dec)
+		if err != nil || !bytes.Equal(got, message) {
+			fmt.Println("ecies: file decrypted wrong", err)
+			t.FailNow()
+		}
+		for _, name := range []string{enc, dec} {
+			fi, err := os.Stat(name)
+			if err != nil || fi.Mode().Perm() != 0o640 {
+				fmt.Println("ecies: file permissions not preserved", name, err)
+				t.FailNow()
+			}
+		}
+	}
+	if err = EncryptFile(&prv.PublicKey, src, enc, nil); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	check()
+
+	// Interrupt an encryption after two chunks.
+	in, err := os.Open(src)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	defer in.Close()
+	fi, _ := in.Stat()
+	out, stream, err := resumeEncryption(&prv.PublicKey, in, fi, enc)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = io.CopyN(stream, in, 2*streamChunkSize+10); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	out.Close()
+	partial, err := os.ReadFile(enc + ".partial")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = EncryptFile(&prv.PublicKey, src, enc, &FileOptions{Resume: true}); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	check()
+	resumed, _ := os.ReadFile(enc)
+	if !bytes.HasPrefix(resumed, partial) {
+		fmt.Println("ecies: encryption not resumed")
+		t.FailNow()
+	}
+	if _, err = os.Stat(enc + ".partial.state"); !os.IsNotExist(err) {
+		fmt.Println("ecies: resume state left behind", err)
+		t.FailNow()
+	}
+
+	// A tampered file doesn't replace the destination.
+	resumed[len(resumed)-1] ^= 1
+	os.WriteFile(enc, resumed, 0o640)
+	os.Remove(dec)
+	if err = DecryptFile(prv, enc, dec); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered file decrypted", err)
+		t.FailNow()
+	}
+	if _, err = os.Stat(dec); !os.IsNotExist(err) {
+		fmt.Println("ecies: tampered file written", err)
+		t.FailNow()
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		fmt.Println("ecies: temporary files left behind", len(entries))
+		t.FailNow()
+	}
+}
@@ -0,0 +1,305 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Registry of the wire and key formats this package has emitted. Every format
+// listed here stays readable; new code writes the current ones only.
+
+import (
+	"encoding/pem"
+	"strings"
+)
+
+// FormatKind tells ciphertext formats and key formats apart.
+type FormatKind int
+
+const (
+	CiphertextFormat FormatKind = iota
+	KeyFormat
+)
+
+// Format describes one wire or key encoding.
+type Format struct {
+	Name    string
+	Kind    FormatKind
+	Version int
+	// Current is set for the formats Migrate* convert into.
+	Current bool
+	// Overhead is the size the format adds to a raw ciphertext.
+	Overhead int
+
+	detect func(in []byte) bool
+}
+
+var (
+	// FormatRaw is the unversioned R || em || tag layout returned by Encrypt.
+	// It carries no curve or parameter information.
+	FormatRaw = &Format{Name: "raw", Kind: CiphertextFormat, Version: 0, detect: isRawCiphertext}
+	// FormatEnvelope is the versioned ciphertext layout: the "ECIES" magic,
+	// a version byte, a curve ID and a suite ID, followed by the raw
+	// ciphertext.
+	FormatEnvelope = &Format{Name: "envelope", Kind: CiphertextFormat, Version: envelopeVersion, Current: true, Overhead: envelopeHeaderSize, detect: isEnvelope}
+	// FormatASN1 is the SEC 1 ECIES-Ciphertext-Value DER structure produced
+	// by MarshalCiphertextASN1. Its overhead depends on the field sizes.
+	FormatASN1 = &Format{Name: "asn1", Kind: CiphertextFormat, Version: 1, detect: isASN1Ciphertext}
+	// FormatArmor is the "ECIES MESSAGE" PEM block produced by Armor.
+	FormatArmor = &Format{Name: "armor", Kind: CiphertextFormat, Version: 1, detect: isArmor}
+
+	// FormatDERPublic and FormatDERPrivate are the DER encodings produced by
+	// MarshalPublic and MarshalPrivate.
+	FormatDERPublic  = &Format{Name: "der-public", Kind: KeyFormat, Version: 1, detect: isDERPublic}
+	FormatDERPrivate = &Format{Name: "der-private", Kind: KeyFormat, Version: 1, detect: isDERPrivate}
+	// FormatPEMPublic and FormatPEMPrivate are the PEM encodings produced by
+	// ExportPublicPEM and ExportPrivatePEM.
+	FormatPEMPublic  = &Format{Name: "pem-public", Kind: KeyFormat, Version: 1, Current: true, detect: isPEMPublic}
+	FormatPEMPrivate = &Format{Name: "pem-private", Kind: KeyFormat, Version: 1, Current: true, detect: isPEMPrivate}
+	// FormatBackup is the Bech32m private key backup string.
+	FormatBackup = &Format{Name: "backup", Kind: KeyFormat, Version: 1, detect: isBackup}
+	// FormatCompactPublic is the Bech32 public key string.
+	FormatCompactPublic = &Format{Name: "compact-public", Kind: KeyFormat, Version: 1, detect: isCompactPublic}
+	// FormatPEMPKCS8, FormatPEMSEC1 and FormatPEMPKIX are the standard
+	// "PRIVATE KEY", "EC PRIVATE KEY" and "PUBLIC KEY" PEM blocks of
+	// MarshalPrivatePKCS8, MarshalPrivateSEC1 and MarshalPublicPKIX. X25519
+	// and X448 keys migrate into PKCS #8 and PKIX, as the formats of
+	// ExportPrivatePEM and ExportPublicPEM can't hold them.
+	FormatPEMPKCS8 = &Format{Name: "pem-pkcs8", Kind: KeyFormat, Version: 1, detect: isPEMPKCS8}
+	FormatPEMSEC1  = &Format{Name: "pem-sec1", Kind: KeyFormat, Version: 1, detect: isPEMSEC1}
+	FormatPEMPKIX  = &Format{Name: "pem-pkix", Kind: KeyFormat, Version: 1, detect: isPEMPKIX}
+	// FormatPEMEncrypted is the "ENCRYPTED PRIVATE KEY" PEM block produced by
+	// ExportPrivatePEMEncrypted. It can't be migrated without the passphrase.
+	FormatPEMEncrypted = &Format{Name: "pem-encrypted", Kind: KeyFormat, Version: 1, detect: isPEMEncrypted}
+	// FormatDERPKCS8 is the DER encoding produced by MarshalPrivatePKCS8.
+	FormatDERPKCS8 = &Format{Name: "der-pkcs8", Kind: KeyFormat, Version: 1, detect: isDERPKCS8}
+)
+
+// The registry, most specific formats first. Entries must never be removed.
+var formats = []*Format{
+	FormatEnvelope,
+	FormatRaw,
+	FormatASN1,
+	FormatArmor,
+	FormatPEMPrivate,
+	FormatPEMPublic,
+	FormatBackup,
+	FormatCompactPublic,
+	FormatDERPrivate,
+	FormatDERPublic,
+	FormatPEMPKCS8,
+	FormatPEMSEC1,
+	FormatPEMPKIX,
+	FormatPEMEncrypted,
+	FormatDERPKCS8,
+}
+
+// Formats returns every format this package can read.
+func Formats() []*Format {
+	return append([]*Format(nil), formats...)
+}
+
+// DetectFormat returns the format of the given blob, or ErrUnknownFormat.
+// Raw ciphertexts are only recognised by their leading point encoding byte,
+// so DetectFormat can not tell whether they are actually valid. The raw
+// ciphertexts of X25519 and X448 start with a bare key, which has no such
+// byte: DetectFormat can't tell them, and may take them for another format.
+// MigrateCiphertext, which knows the key, recognises them.
+func DetectFormat(in []byte) (*Format, error) {
+	for _, f := range formats {
+		if f.detect(in) {
+			return f, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+func isRawCiphertext(in []byte) bool {
+	if len(in) == 0 {
+		return false
+	}
+	switch in[0] {
+	case pointCompressedEven, pointCompressedOdd, pointUncompressed, pointHybridEven, pointHybridOdd:
+		return true
+	}
+	return false
+}
+
+func pemType(in []byte) string {
+	p, _ := pem.Decode(in)
+	if p == nil {
+		return ""
+	}
+	return p.Type
+}
+
+func isPEMPublic(in []byte) bool  { return pemType(in) == "ELLIPTIC CURVE PUBLIC KEY" }
+func isPEMPrivate(in []byte) bool { return pemType(in) == "ELLIPTIC CURVE PRIVATE KEY" }
+func isPEMPKCS8(in []byte) bool   { return pemType(in) == "PRIVATE KEY" }
+func isPEMSEC1(in []byte) bool    { return pemType(in) == "EC PRIVATE KEY" }
+func isPEMPKIX(in []byte) bool    { return pemType(in) == "PUBLIC KEY" }
+
+func isPEMEncrypted(in []byte) bool { return pemType(in) == "ENCRYPTED PRIVATE KEY" }
+
+func isDERPublic(in []byte) bool {
+	_, err := UnmarshalPublic(in)
+	return err == nil
+}
+
+func isDERPrivate(in []byte) bool {
+	_, err := UnmarshalPrivate(in)
+	return err == nil
+}
+
+func isDERPKCS8(in []byte) bool {
+	_, err := UnmarshalPrivatePKCS8(in)
+	return err == nil
+}
+
+func isBackup(in []byte) bool {
+	return strings.HasPrefix(strings.ToLower(string(in)), backupHRP+"1")
+}
+
+func isCompactPublic(in []byte) bool {
+	return strings.HasPrefix(strings.ToLower(string(in)), publicHRP+"1")
+}
+
+// MigrateCiphertext re-encodes a ciphertext for pub in any readable format
+// into the current one. The message itself is not decrypted, so this can
+// run without access to the private key.
+func MigrateCiphertext(pub *PublicKey, c []byte) ([]byte, error) {
+	f, err := detectCiphertext(pub, c)
+	if err != nil {
+		return nil, err
+	}
+	switch f {
+	case FormatEnvelope:
+		params := pub.Params
+		if params == nil {
+			params = ParamsFromCurve(pub.Curve)
+		}
+		if params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+		if _, err := unwrapEnvelope(pub, params, c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case FormatRaw:
+		return EncodeEnvelope(pub, c)
+	case FormatASN1:
+		raw, err := UnmarshalCiphertextASN1(c)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeEnvelope(pub, raw)
+	case FormatArmor:
+		raw, err := Unarmor(pub, c)
+		if err != nil {
+			return nil, err
+		}
+		return MigrateCiphertext(pub, raw)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// detectCiphertext returns the format of the ciphertext c for pub. The raw
+// ciphertexts of X25519 and X448 keys start with random bytes, which may look
+// like any format: c is only taken as an envelope if its header matches pub,
+// and as armor if it is one, as the ASN.1 encoding has no such keys.
+// Anything else is raw.
+func detectCiphertext(pub *PublicKey, c []byte) (*Format, error) {
+	if _, ok := pub.Curve.(rawPointCurve); !ok {
+		return DetectFormat(c)
+	}
+	switch {
+	case isEnvelope(c) && c[len(envelopeMagic)] == envelopeVersion && c[len(envelopeMagic)+1] == curveIDs[pub.Curve]:
+		return FormatEnvelope, nil
+	case isArmor(c):
+		return FormatArmor, nil
+	case len(c) == 0:
+		return nil, ErrUnknownFormat
+	}
+	return FormatRaw, nil
+}
+
+// MigrateKey re-encodes a public or private key in any readable format into
+// the current PEM format: that of ExportPublicPEM and ExportPrivatePEM, or
+// PKIX and PKCS #8 for X25519 and X448 keys. Metadata in PEM headers is
+// preserved. Encrypted keys are rejected with ErrEncryptedKey.
+func MigrateKey(in []byte) ([]byte, error) {
+	f, err := DetectFormat(in)
+	if err != nil {
+		return nil, err
+	}
+	switch f {
+	case FormatPEMPublic, FormatPEMPKIX:
+		pub, meta, err := ImportPublicPEMWithMetadata(in)
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPublic(pub, meta)
+	case FormatPEMPrivate, FormatPEMPKCS8, FormatPEMSEC1:
+		prv, meta, err := ImportPrivatePEMWithMetadata(in)
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPrivate(prv, meta)
+	case FormatPEMEncrypted:
+		return nil, ErrEncryptedKey
+	case FormatDERPublic:
+		pub, err := UnmarshalPublic(in)
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPublic(pub, nil)
+	case FormatDERPrivate:
+		prv, err := UnmarshalPrivate(in)
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPrivate(prv, nil)
+	case FormatDERPKCS8:
+		prv, err := UnmarshalPrivatePKCS8(in)
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPrivate(prv, nil)
+	case FormatBackup:
+		prv, err := ImportPrivateBackup(string(in))
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPrivate(prv, nil)
+	case FormatCompactPublic:
+		pub, err := DecodePublicBech32(strings.TrimSpace(string(in)))
+		if err != nil {
+			return nil, err
+		}
+		return exportCurrentPublic(pub, nil)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// exportCurrentPublic encodes pub in the current PEM format.
+func exportCurrentPublic(pub *PublicKey, meta *KeyMetadata) ([]byte, error) {
+	if !montgomeryCurve(pub.Curve) {
+		return ExportPublicPEMWithMetadata(pub, meta)
+	}
+	der, err := MarshalPublicPKIX(pub)
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM("PUBLIC KEY", der, meta)
+}
+
+// exportCurrentPrivate encodes prv in the current PEM format.
+func exportCurrentPrivate(prv *PrivateKey, meta *KeyMetadata) ([]byte, error) {
+	if !montgomeryCurve(prv.Curve) {
+		return ExportPrivatePEMWithMetadata(prv, meta)
+	}
+	der, err := MarshalPrivatePKCS8(prv)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(der)
+	return encodePEM("PRIVATE KEY", der, meta)
+}
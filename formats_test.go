@@ -0,0 +1,297 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure raw ciphertexts migrate into envelopes which still decrypt.
+func TestMigrateCiphertext(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	raw, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if f, err := DetectFormat(raw); err != nil || f != FormatRaw {
+		fmt.Println("ecies: raw ciphertext not detected", err)
+		t.FailNow()
+	}
+
+	env, err := MigrateCiphertext(&prv.PublicKey, raw)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if f, err := DetectFormat(env); err != nil || f != FormatEnvelope || !f.Current {
+		fmt.Println("ecies: envelope not detected", err)
+		t.FailNow()
+	}
+	if !bytes.Equal(env[envelopeHeaderSize:], raw) {
+		fmt.Println("ecies: envelope does not wrap the raw ciphertext")
+		t.FailNow()
+	}
+	again, err := MigrateCiphertext(&prv.PublicKey, env)
+	if err != nil || !bytes.Equal(again, env) {
+		fmt.Println("ecies: migrating an envelope should be a no-op", err)
+		t.FailNow()
+	}
+
+	for _, c := range [][]byte{raw, env} {
+		pt, err := Decrypt(prv, c, nil, nil)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: failed to decrypt", err)
+			t.FailNow()
+		}
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = MigrateCiphertext(&other.PublicKey, env); err != ErrInvalidCurve {
+		fmt.Println("ecies: envelope for the wrong curve accepted", err)
+		t.FailNow()
+	}
+	if _, err = Decrypt(other, env, nil, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: decrypted envelope for the wrong curve", err)
+		t.FailNow()
+	}
+	bad := append([]byte(nil), env...)
+	bad[len(envelopeMagic)] = 2
+	if _, err = Decrypt(prv, bad, nil, nil); err != ErrUnknownFormat {
+		fmt.Println("ecies: accepted unknown envelope version", err)
+		t.FailNow()
+	}
+}
+
+// Ensure the raw ciphertexts of X25519 and X448 migrate, whatever their
+// leading bytes look like, and that their envelopes are recognised.
+func TestMigrateCiphertextRaw(t *testing.T) {
+	message := []byte("Hello, world.")
+	for _, curve := range []elliptic.Curve{X25519(), X448()} {
+		name := curve.Params().Name
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		// Find a ciphertext starting with a SEC 1 point encoding byte, which
+		// DetectFormat can't tell from those of other curves.
+		var found []byte
+		for found == nil {
+			ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			if ct[0] >= pointCompressedEven && ct[0] <= pointHybridOdd {
+				found = ct
+			}
+		}
+		for _, raw := range [][]byte{found} {
+			env, err := MigrateCiphertext(&prv.PublicKey, raw)
+			if err != nil || !bytes.Equal(env[envelopeHeaderSize:], raw) {
+				fmt.Println(name, "ecies: raw ciphertext not migrated", err)
+				t.FailNow()
+			}
+			if pt, err := Decrypt(prv, env, nil, nil); err != nil || !bytes.Equal(pt, message) {
+				fmt.Println(name, "ecies: migrated ciphertext not decrypted", err)
+				t.FailNow()
+			}
+			if again, err := MigrateCiphertext(&prv.PublicKey, env); err != nil || !bytes.Equal(again, env) {
+				fmt.Println(name, "ecies: migrating an envelope should be a no-op", err)
+				t.FailNow()
+			}
+			armored, err := Armor(&prv.PublicKey, raw)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			if again, err := MigrateCiphertext(&prv.PublicKey, armored); err != nil || !bytes.Equal(again, env) {
+				fmt.Println(name, "ecies: armored ciphertext not migrated", err)
+				t.FailNow()
+			}
+		}
+
+		// Keys starting with an ASN.1 SEQUENCE, which DetectFormat doesn't take
+		// as raw, and with the envelope magic for another curve.
+		for _, raw := range [][]byte{
+			append([]byte{0x30, 0x03, 0x02, 0x01, 0x00}, make([]byte, 100)...),
+			append([]byte("ECIES\x01\x01\x01"), make([]byte, 100)...),
+		} {
+			if env, err := MigrateCiphertext(&prv.PublicKey, raw); err != nil || !bytes.Equal(env[envelopeHeaderSize:], raw) {
+				fmt.Println(name, "ecies: raw ciphertext taken for another format", err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure every historical key format migrates to the current PEM format.
+func TestMigrateKey(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prvPEM, err := ExportPrivatePEM(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pubPEM, err := ExportPublicPEM(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prvDER, _ := MarshalPrivate(prv)
+	pubDER, _ := MarshalPublic(&prv.PublicKey)
+	backup, _ := ExportPrivateBackup(prv)
+	compact, _ := EncodePublicBech32(&prv.PublicKey)
+	pkcs8DER, _ := MarshalPrivatePKCS8(prv)
+	sec1DER, _ := MarshalPrivateSEC1(prv)
+	pkixDER, _ := MarshalPublicPKIX(&prv.PublicKey)
+	pkcs8PEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER})
+	sec1PEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1DER})
+	pkixPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkixDER})
+
+	for _, c := range []struct {
+		In     []byte
+		Format *Format
+		Want   []byte
+	}{
+		{prvPEM, FormatPEMPrivate, prvPEM},
+		{pubPEM, FormatPEMPublic, pubPEM},
+		{prvDER, FormatDERPrivate, prvPEM},
+		{pubDER, FormatDERPublic, pubPEM},
+		{[]byte(backup), FormatBackup, prvPEM},
+		{[]byte(compact), FormatCompactPublic, pubPEM},
+		{pkcs8PEM, FormatPEMPKCS8, prvPEM},
+		{sec1PEM, FormatPEMSEC1, prvPEM},
+		{pkixPEM, FormatPEMPKIX, pubPEM},
+		{pkcs8DER, FormatDERPKCS8, prvPEM},
+		{sec1DER, FormatDERPrivate, prvPEM},
+		{pkixDER, FormatDERPublic, pubPEM},
+	} {
+		f, err := DetectFormat(c.In)
+		if err != nil || f != c.Format {
+			fmt.Println("ecies: wrong format detected for", c.Format.Name, err)
+			t.FailNow()
+		}
+		out, err := MigrateKey(c.In)
+		if err != nil || !bytes.Equal(out, c.Want) {
+			fmt.Println("ecies: failed to migrate", c.Format.Name, err)
+			t.FailNow()
+		}
+	}
+
+	encrypted, err := ExportPrivatePEMEncrypted(rand.Reader, prv, []byte("passphrase"), &PEMEncryptionOptions{PBKDF2Iterations: 1000})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if f, _ := DetectFormat(encrypted); f != FormatPEMEncrypted {
+		fmt.Println("ecies: encrypted key not detected")
+		t.FailNow()
+	}
+	if _, err = MigrateKey(encrypted); err != ErrEncryptedKey {
+		fmt.Println("ecies: encrypted key migrated without its passphrase", err)
+		t.FailNow()
+	}
+
+	// X25519 keys stay in PKCS #8 and PKIX, which hold them.
+	x, err := GenerateKey(rand.Reader, X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	xPrv, _ := x.MarshalText()
+	xPub, _ := x.PublicKey.MarshalText()
+	xDER, _ := MarshalPrivatePKCS8(x)
+	xBackup, _ := ExportPrivateBackup(x)
+	for _, c := range [][2][]byte{{xPrv, xPrv}, {xPub, xPub}, {xDER, xPrv}, {[]byte(xBackup), xPrv}} {
+		if out, err := MigrateKey(c[0]); err != nil || !bytes.Equal(out, c[1]) {
+			fmt.Println("ecies: failed to migrate an X25519 key", err)
+			t.FailNow()
+		}
+	}
+
+	if _, err = MigrateKey([]byte("not a key")); err != ErrUnknownFormat {
+		fmt.Println("ecies: migrated garbage", err)
+		t.FailNow()
+	}
+}
+
+// Ensure ciphertexts survive the SEC 1 ASN.1 structure, whose fields split
+// the ephemeral key, the encrypted message and the tag, and that the
+// structure is detected and migrated.
+func TestCiphertextASN1(t *testing.T) {
+	for _, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES128_GCM_SHA256} {
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		message := []byte("Hello, world.")
+		raw, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalCiphertextASN1(&prv.PublicKey, raw)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		var ct asnCiphertext
+		if _, err = asn1.Unmarshal(der, &ct); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		_, tagLen := params.demOverhead()
+		if len(ct.EphemeralPublicKey) != 65 || len(ct.MACTag) != tagLen {
+			fmt.Println("ecies: unexpected ASN.1 ciphertext fields")
+			t.FailNow()
+		}
+		if f, err := DetectFormat(der); err != nil || f != FormatASN1 {
+			fmt.Println("ecies: ASN.1 ciphertext not detected", err)
+			t.FailNow()
+		}
+
+		back, err := UnmarshalCiphertextASN1(der)
+		if err != nil || !bytes.Equal(back, raw) {
+			fmt.Println("ecies: ASN.1 ciphertext does not round trip", err)
+			t.FailNow()
+		}
+		env, err := MigrateCiphertext(&prv.PublicKey, der)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, env, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: migrated ASN.1 ciphertext not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	if _, err := UnmarshalCiphertextASN1([]byte{0x30, 0x00}); !errors.Is(err, ErrInvalidMessage) {
+		fmt.Println("ecies: accepted an empty ASN.1 ciphertext", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,109 @@
+package ecies
+
+// Length-prefixed framing of ciphertexts over streams.
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+var (
+	ErrMessageTooLarge = newError(KindParams, "ecies: framed message too large")
+	ErrInvalidFrame    = newError(KindEncoding, "ecies: invalid message frame")
+)
+
+// DefaultMaxMessageSize caps the size of the messages read by ReadMessage,
+// unless another limit is given.
+const DefaultMaxMessageSize = 1 << 20
+
+// The frame header is a 32-bit big-endian length, whose top bit tells whether
+// a suite byte follows. The length covers the suite byte and the ciphertext.
+const (
+	frameHeaderSize = 4
+	frameSuiteFlag  = 1 << 31
+)
+
+// WriteMessage writes a ciphertext to w as a single length-prefixed frame.
+// If suite is not 0, the suite identifier is sent along, e.g. as negotiated
+// with NegotiateSuite.
+func WriteMessage(w io.Writer, suite byte, c []byte) error {
+	size := len(c)
+	if suite != 0 {
+		size++
+	}
+	if size >= frameSuiteFlag {
+		return ErrMessageTooLarge
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+size)
+	header := uint32(size)
+	if suite != 0 {
+		header |= frameSuiteFlag
+		frame = append(frame, suite)
+	}
+	binary.BigEndian.PutUint32(frame, header)
+	frame = append(frame, c...)
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadMessage reads a frame written by WriteMessage, returning the suite
+// identifier, or 0 if there is none, and the ciphertext. Frames larger than
+// maxSize are rejected before being read; if maxSize is not positive,
+// DefaultMaxMessageSize is used.
+//
+// ReadMessage returns io.EOF if r is at its end before a frame starts, and
+// io.ErrUnexpectedEOF if it ends in the middle of a frame.
+func ReadMessage(r io.Reader, maxSize int) (suite byte, c []byte, err error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	var header [frameHeaderSize]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	hasSuite := size&frameSuiteFlag != 0
+	size &^= frameSuiteFlag
+	if uint64(size) > uint64(maxSize) {
+		err = ErrMessageTooLarge
+		return
+	}
+	if hasSuite && size < 1 {
+		err = ErrInvalidFrame
+		return
+	}
+
+	body := make([]byte, size)
+	if _, err = io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if hasSuite {
+		if body[0] == 0 {
+			err = ErrInvalidFrame
+			return
+		}
+		return body[0], body[1:], nil
+	}
+	return 0, body, nil
+}
+
+// appendField appends a field prefixed by its 16-bit length.
+func appendField(out, field []byte) []byte {
+	out = binary.BigEndian.AppendUint16(out, uint16(len(field)))
+	return append(out, field...)
+}
+
+// readField splits a field prefixed by its 16-bit length off in.
+func readField(in []byte) (field, rest []byte, ok bool) {
+	if len(in) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(in))
+	if len(in)-2 < n {
+		return nil, nil, false
+	}
+	return in[2 : 2+n], in[2+n:], true
+}
@@ -0,0 +1,66 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// Ensure framed messages round trip over a stream, including partial reads.
+func TestFraming(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	var stream bytes.Buffer
+	var sent [][]byte
+	for i, suite := range []byte{0, 1, 3} {
+		c, err := Encrypt(rand.Reader, &prv.PublicKey, bytes.Repeat([]byte{'a'}, (i+1)*100), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if err = WriteMessage(&stream, suite, c); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		sent = append(sent, c)
+	}
+	raw := stream.Bytes()
+
+	r := iotest.OneByteReader(bytes.NewReader(raw))
+	for i, want := range []byte{0, 1, 3} {
+		suite, c, err := ReadMessage(r, 0)
+		if err != nil || suite != want || !bytes.Equal(c, sent[i]) {
+			fmt.Println("ecies: framed message mismatch", i, err)
+			t.FailNow()
+		}
+		if _, err = Decrypt(prv, c, nil, nil); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+	}
+	if _, _, err = ReadMessage(r, 0); err != io.EOF {
+		fmt.Println("ecies: expected EOF after the last frame", err)
+		t.FailNow()
+	}
+
+	if _, _, err = ReadMessage(bytes.NewReader(raw[:len(raw)-1]), 0); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	truncated := bytes.NewReader(raw[:frameHeaderSize+10])
+	if _, _, err = ReadMessage(truncated, 0); err != io.ErrUnexpectedEOF {
+		fmt.Println("ecies: expected unexpected EOF on a truncated frame", err)
+		t.FailNow()
+	}
+	if _, _, err = ReadMessage(bytes.NewReader(raw), 10); err != ErrMessageTooLarge {
+		fmt.Println("ecies: size cap not enforced", err)
+		t.FailNow()
+	}
+}
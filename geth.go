@@ -0,0 +1,115 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// The ECIES scheme of go-ethereum's crypto/ecies package, used by the RLPx
+// handshake of devp2p, which this package derives from:
+//
+//	z = X coordinate of r·Q
+//	Ke || Km' = ConcatKDF(z, s1), Km = H(Km')
+//	c = iv || AES-CTR(Ke, iv, m)
+//	d = HMAC(Km, c || s2)
+//	ciphertext = uncompressed R || c || d
+//
+// The parameters are chosen by go-ethereum from the curve alone:
+// AES-128/SHA-256 for secp256k1 and P-256, AES-192/SHA-384 for P-384.
+// EncryptGeth and DecryptGeth pin this layout, whatever the parameters of the
+// key and the options of Encrypt. P-521 is not supported, as go-ethereum
+// truncates its shared secret to 64 bytes.
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"io"
+)
+
+// gethParams returns the parameters go-ethereum uses on curve.
+func gethParams(curve elliptic.Curve) (*ECIESParams, error) {
+	var params *ECIESParams
+	switch curve {
+	case Secp256k1(), elliptic.P256():
+		params = ECIES_AES128_SHA256
+	case elliptic.P384():
+		params = ECIES_AES192_SHA384
+	default:
+		return nil, ErrUnsupportedECIESParameters
+	}
+	if err := enforcePolicy(nil, SuiteGeth, curve, params.Hash().Size(), params.KeyLen); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// EncryptGeth encrypts a message to a public key as go-ethereum's
+// ecies.Encrypt does, with the same shared information s1 and s2.
+func EncryptGeth(rand io.Reader, pub *PublicKey, m, s1, s2 []byte) (ct []byte, err error) {
+	params, err := gethParams(pub.Curve)
+	if err != nil {
+		return
+	}
+	z, Rb, err := encapsulate(rand, pub, params, false)
+	if err != nil {
+		return
+	}
+	K, err := concatKDF(params.Hash(), z, s1, 2*params.KeyLen)
+	if err != nil {
+		return
+	}
+	Ke := K[:params.KeyLen]
+	Km := macKey(params, K[params.KeyLen:])
+
+	em, err := symEncrypt(rand, params, Ke, m)
+	if err != nil {
+		return
+	}
+	d := messageTag(params, Km, em, s2)
+
+	ct = make([]byte, 0, len(Rb)+len(em)+len(d))
+	ct = append(ct, Rb...)
+	ct = append(ct, em...)
+	ct = append(ct, d...)
+	return
+}
+
+// DecryptGeth decrypts a ciphertext produced by go-ethereum's ecies.Encrypt
+// or by EncryptGeth. Like go-ethereum, it only accepts an uncompressed
+// ephemeral key.
+func DecryptGeth(prv KeyProvider, c, s1, s2 []byte) (m []byte, err error) {
+	pub := prv.Public()
+	params, err := gethParams(pub.Curve)
+	if err != nil {
+		return
+	}
+	rLen := 1 + 2*((pub.Curve.Params().BitSize+7)/8)
+	macLen := params.Hash().Size()
+	if len(c) == 0 || c[0] != pointUncompressed {
+		err = ErrInvalidPublicKey
+		return
+	}
+	if len(c) < rLen+params.BlockSize+macLen {
+		err = ErrInvalidMessage
+		return
+	}
+	R, err := parseEncapsulation(pub, c[:rLen], UncompressedPointsOnly)
+	if err != nil {
+		return
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return
+	}
+	K, err := concatKDF(params.Hash(), z, s1, 2*params.KeyLen)
+	if err != nil {
+		return
+	}
+	Ke := K[:params.KeyLen]
+	Km := macKey(params, K[params.KeyLen:])
+
+	em := c[rLen : len(c)-macLen]
+	if subtle.ConstantTimeCompare(c[len(c)-macLen:], messageTag(params, Km, em, s2)) != 1 {
+		err = ErrInvalidMessage
+		return
+	}
+	return symDecrypt(params, Ke, em)
+}
@@ -0,0 +1,172 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that go-ethereum ciphertexts round trip on the supported curves,
+// whatever the parameters of the key, and that P-521 is rejected.
+func TestGeth(t *testing.T) {
+	for _, curve := range []elliptic.Curve{Secp256k1(), elliptic.P256(), elliptic.P384()} {
+		prv, err := GenerateKey(rand.Reader, curve, ECIES_AES128_GCM_SHA256)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, message := range [][]byte{nil, []byte("Hello, world."), make([]byte, 100)} {
+			ct, err := EncryptGeth(rand.Reader, &prv.PublicKey, message, []byte("s1"), []byte("s2"))
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			if ct[0] != pointUncompressed {
+				fmt.Println("ecies: ephemeral key is not uncompressed")
+				t.FailNow()
+			}
+			pt, err := DecryptGeth(prv, ct, []byte("s1"), []byte("s2"))
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			} else if !bytes.Equal(pt, message) {
+				fmt.Println("ecies: plaintext doesn't match message")
+				t.FailNow()
+			}
+			if _, err := DecryptGeth(prv, ct, nil, []byte("s2")); err != ErrInvalidMessage {
+				fmt.Println("ecies: decrypted with the wrong shared information")
+				t.FailNow()
+			}
+			ct[len(ct)-1] ^= 1
+			if _, err := DecryptGeth(prv, ct, []byte("s1"), []byte("s2")); err != ErrInvalidMessage {
+				fmt.Println("ecies: decrypted a tampered message")
+				t.FailNow()
+			}
+		}
+	}
+
+	prv, err := GenerateKey(rand.Reader, elliptic.P521(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptGeth(rand.Reader, &prv.PublicKey, []byte("message"), nil, nil); err != ErrUnsupportedECIESParameters {
+		fmt.Println("ecies: encrypted on P-521")
+		t.FailNow()
+	}
+}
+
+// Ensure that go-ethereum ciphertexts are those of Encrypt with the default
+// parameters, and that compressed ephemeral keys are rejected, as go-ethereum
+// does.
+func TestGethDefault(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, go-ethereum.")
+
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := DecryptGeth(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: Encrypt ciphertext not decrypted", err)
+		t.FailNow()
+	}
+	if ct, err = EncryptGeth(rand.Reader, &prv.PublicKey, message, nil, nil); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: EncryptGeth ciphertext not decrypted", err)
+		t.FailNow()
+	}
+
+	ct, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil, &EncryptOptions{CompressEphemeral: true})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = DecryptGeth(prv, ct, nil, nil); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: accepted a compressed ephemeral key")
+		t.FailNow()
+	}
+}
+
+// Verify the shared secret of the static key pair of go-ethereum's
+// TestSharedKeyStatic, and rebuild a ciphertext with the standard library
+// following go-ethereum's ecies.Encrypt step by step.
+func TestVectorGeth(t *testing.T) {
+	d1, _ := hex.DecodeString("7ebbc6a8358bc76dd73ebc557056702c8cfc34e5cfcd90eb83af0347575fd2ad")
+	d2, _ := hex.DecodeString("6a3d6396903245bba5837752b9e0348874e72db0c4e11e9c485a81b4ea4353b9")
+	shared, _ := hex.DecodeString("167ccc13ac5e8a26b131c3446030c60fbfac6aa8e31149d0869f93626a4cdf62")
+	prv1, err := NewPrivateKey(Secp256k1(), d1)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv2, err := NewPrivateKey(Secp256k1(), d2)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	z, err := prv1.GenerateShared(&prv2.PublicKey)
+	if err != nil || !bytes.Equal(z, shared) {
+		fmt.Println("ecies: shared secret doesn't match go-ethereum", err)
+		t.FailNow()
+	}
+
+	// ConcatKDF(z, s1) with SHA-256: one block for AES-128 and HMAC keys.
+	s1, s2, m := []byte("shared info 1"), []byte("shared info 2"), []byte("Hello, RLPx.")
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint32(nil, 1))
+	h.Write(z)
+	h.Write(s1)
+	K := h.Sum(nil)
+	Km := sha256.Sum256(K[16:])
+
+	iv := make([]byte, aes.BlockSize)
+	block, _ := aes.NewCipher(K[:16])
+	em := append([]byte{}, iv...)
+	em = append(em, make([]byte, len(m))...)
+	cipher.NewCTR(block, iv).XORKeyStream(em[aes.BlockSize:], m)
+	mac := hmac.New(sha256.New, Km[:])
+	mac.Write(em)
+	mac.Write(s2)
+
+	ct := elliptic.Marshal(Secp256k1(), prv2.X, prv2.Y)
+	ct = append(ct, em...)
+	ct = mac.Sum(ct)
+	pt, err := DecryptGeth(prv1, ct, s1, s2)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !bytes.Equal(pt, m) {
+		fmt.Println("ecies: plaintext doesn't match message")
+		t.FailNow()
+	}
+}
+
+// Ensure that messages aren't encrypted to points off the curve.
+func TestOffCurveGeth(t *testing.T) {
+	pub := offCurveKey(t, elliptic.P256())
+	if _, err := EncryptGeth(rand.Reader, pub, []byte("message"), nil, nil); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: encrypted to an off-curve point", err)
+		t.FailNow()
+	}
+}
@@ -1,3 +1,24 @@
 module github.com/foundriesio/go-ecies
 
-go 1.17
+go 1.24.0
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.36.3
+	github.com/aws/aws-sdk-go-v2/service/kms v1.38.1
+	github.com/google/go-tpm v0.9.1
+	github.com/miekg/pkcs11 v1.1.1
+	golang.org/x/crypto v0.48.0
+)
+
+require (
+	filippo.io/age v1.0.0
+	filippo.io/edwards25519 v1.1.0
+	github.com/cloudflare/circl v1.6.1
+	golang.org/x/sys v0.41.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.34 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.34 // indirect
+	github.com/aws/smithy-go v1.22.2 // indirect
+)
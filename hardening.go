@@ -0,0 +1,113 @@
+package ecies
+
+// Side-channel hardening of the key agreement on curves without a
+// constant-time implementation.
+
+import (
+	"crypto/elliptic"
+	"io"
+	"math/big"
+	"sync/atomic"
+)
+
+// Hardening selects countermeasures against timing and power analysis in
+// PrivateKey.GenerateShared. They only apply to curves other than P-256,
+// P-384 and P-521, whose standard library implementations are constant time,
+// e.g. P-224 or curves added with AddParamsForCurve, and cost extra scalar
+// multiplications. They don't apply to X448, which has no point addition.
+type Hardening uint32
+
+const (
+	// HardenScalarBlinding multiplies by d + r*N, for a random 64-bit r,
+	// instead of the private scalar d.
+	HardenScalarBlinding Hardening = 1 << iota
+	// HardenPointRandomization multiplies the peer point offset by a random
+	// point r*G, then removes r*Q, Q being our public key.
+	HardenPointRandomization
+)
+
+var hardening uint32 // atomic
+
+// SetHardening selects the countermeasures used from now on.
+func SetHardening(h Hardening) {
+	atomic.StoreUint32(&hardening, uint32(h))
+}
+
+// CurrentHardening returns the countermeasures in use.
+func CurrentHardening() Hardening {
+	return Hardening(atomic.LoadUint32(&hardening))
+}
+
+// montgomeryCurve reports whether curve is X25519 or X448, on which only the
+// x-coordinate ladder is available.
+func montgomeryCurve(curve elliptic.Curve) bool {
+	_, ok := curve.(rawPointCurve)
+	return ok
+}
+
+func constantTimeCurve(curve elliptic.Curve) bool {
+	return curve == elliptic.P256() || curve == elliptic.P384() || curve == elliptic.P521() ||
+		curve == X25519()
+}
+
+// blindedScalarMult returns d*(x, y) on the curve of prv, with the given
+// countermeasures. It returns nil coordinates if the result is the point at
+// infinity.
+func blindedScalarMult(prv *PrivateKey, x, y *big.Int, h Hardening) (*big.Int, *big.Int, error) {
+	curve := prv.Curve
+	N := curve.Params().N
+	scalar := prv.D.Bytes()
+	if h&HardenScalarBlinding != 0 {
+		r, err := randomFactor()
+		if err != nil {
+			return nil, nil, err
+		}
+		k := new(big.Int).Mul(r, N)
+		k.Add(k, prv.D)
+		scalar = k.FillBytes(make([]byte, (N.BitLen()+7)/8+8))
+	}
+	if h&HardenPointRandomization == 0 {
+		x, y = affineOrNil(curve.ScalarMult(x, y, scalar))
+		return x, y, nil
+	}
+
+	// d*P = d*(P + r*G) - r*Q, with Q = d*G public.
+	r, err := randomFactor()
+	if err != nil {
+		return nil, nil, err
+	}
+	rx, ry := curve.ScalarBaseMult(r.Bytes())
+	px, py := curve.Add(x, y, rx, ry)
+	if px, py = affineOrNil(px, py); px == nil {
+		return nil, nil, ErrSharedKeyIsPointAtInfinity
+	}
+	sx, sy := curve.ScalarMult(px, py, scalar)
+	ux, uy := curve.ScalarMult(prv.PublicKey.X, prv.PublicKey.Y, r.Bytes())
+	if ux, uy = affineOrNil(ux, uy); ux != nil {
+		uy = new(big.Int).Sub(curve.Params().P, uy)
+		sx, sy = curve.Add(sx, sy, ux, uy)
+	}
+	sx, sy = affineOrNil(sx, sy)
+	return sx, sy, nil
+}
+
+// randomFactor returns a random non-zero 64-bit factor.
+func randomFactor() (*big.Int, error) {
+	var b [8]byte
+	for {
+		if _, err := io.ReadFull(entropy(nil), b[:]); err != nil {
+			return nil, err
+		}
+		if r := new(big.Int).SetBytes(b[:]); r.Sign() != 0 {
+			return r, nil
+		}
+	}
+}
+
+// affineOrNil maps the (0, 0) encoding of the point at infinity to nil.
+func affineOrNil(x, y *big.Int) (*big.Int, *big.Int) {
+	if x == nil || (x.Sign() == 0 && y.Sign() == 0) {
+		return nil, nil
+	}
+	return x, y
+}
@@ -0,0 +1,46 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure the countermeasures don't change the shared secret.
+func TestHardening(t *testing.T) {
+	defer SetHardening(0)
+	for _, curve := range []elliptic.Curve{elliptic.P224(), Secp256k1()} {
+		prv1, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prv2, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		SetHardening(0)
+		want, err := prv1.GenerateShared(&prv2.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, h := range []Hardening{
+			HardenScalarBlinding,
+			HardenPointRandomization,
+			HardenScalarBlinding | HardenPointRandomization,
+		} {
+			SetHardening(h)
+			for i := 0; i < 4; i++ {
+				got, err := prv1.GenerateShared(&prv2.PublicKey)
+				if err != nil || !bytes.Equal(got, want) {
+					fmt.Println("ecies: hardened key agreement mismatch", curve.Params().Name, h, err)
+					t.FailNow()
+				}
+			}
+		}
+	}
+}
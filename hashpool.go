@@ -0,0 +1,102 @@
+package ecies
+
+// Recycling of the hash states of the standard parameters, which Encrypt and
+// Decrypt would otherwise allocate several times per message for the KDF,
+// the MAC key and the HMAC.
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"reflect"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// hashState is a recycled hash, with a buffer of its block size.
+type hashState struct {
+	hash.Hash
+	buf []byte
+}
+
+// hashPools holds a pool per hash function of the standard parameters, keyed
+// by the address of the function. Closures and other functions, which may
+// capture state, aren't pooled.
+var hashPools = make(map[uintptr]*sync.Pool)
+
+func init() {
+	for _, h := range []func() hash.Hash{
+		sha256.New, sha512.New384, sha512.New,
+		sha3.New256, sha3.New384, sha3.New512, NewSHAKE128, NewSHAKE256,
+	} {
+		h := h
+		hashPools[reflect.ValueOf(h).Pointer()] = &sync.Pool{New: func() any {
+			s := h()
+			return &hashState{s, make([]byte, 0, s.BlockSize())}
+		}}
+	}
+}
+
+// getHash returns a reset hash of the function h, to be handed back with
+// putHash once done.
+func getHash(h func() hash.Hash) *hashState {
+	if pool, ok := hashPools[reflect.ValueOf(h).Pointer()]; ok {
+		s := pool.Get().(*hashState)
+		s.Reset()
+		return s
+	}
+	s := h()
+	return &hashState{s, make([]byte, 0, s.BlockSize())}
+}
+
+// putHash returns a hash obtained from getHash to its pool, wiping the
+// HMAC pads in its buffer.
+func putHash(h func() hash.Hash, s *hashState) {
+	wipe(s.buf[:cap(s.buf)])
+	if pool, ok := hashPools[reflect.ValueOf(h).Pointer()]; ok {
+		s.Reset()
+		pool.Put(s)
+	}
+}
+
+// hashSize returns the output size of the hash of the parameters.
+func (params *ECIESParams) hashSize() int {
+	s := getHash(params.Hash)
+	defer putHash(params.Hash, s)
+	return s.Size()
+}
+
+// hmacSum computes HMAC (RFC 2104) over the concatenation of data, as
+// crypto/hmac does, with recycled hash states.
+func hmacSum(h func() hash.Hash, key []byte, data ...[]byte) []byte {
+	inner, outer := getHash(h), getHash(h)
+	defer putHash(h, inner)
+	defer putHash(h, outer)
+	bs := inner.BlockSize()
+	if len(key) > bs {
+		outer.Write(key)
+		key = outer.Sum(nil)
+		outer.Reset()
+	}
+
+	pad := inner.buf[:bs]
+	clear(pad)
+	copy(pad, key)
+	for i := range pad {
+		pad[i] ^= 0x36
+	}
+	inner.Write(pad)
+	for _, d := range data {
+		inner.Write(d)
+	}
+	pad = outer.buf[:bs]
+	clear(pad)
+	copy(pad, key)
+	for i := range pad {
+		pad[i] ^= 0x5c
+	}
+	outer.Write(pad)
+	outer.Write(inner.Sum(inner.buf[:0]))
+	return outer.Sum(nil)
+}
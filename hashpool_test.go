@@ -0,0 +1,53 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// Ensure that the HMAC over recycled hash states matches crypto/hmac, for
+// keys shorter and longer than the block size, and with a hash that isn't
+// pooled.
+func TestHMACSum(t *testing.T) {
+	custom := func() hash.Hash { return sha256.New() }
+	hashes := []func() hash.Hash{custom}
+	for _, params := range []*ECIESParams{
+		ECIES_AES128_SHA256, ECIES_AES192_SHA384, ECIES_AES256_SHA512,
+		ECIES_AES128_SHA3_256, ECIES_AES192_SHA3_384, ECIES_AES256_SHA3_512,
+		ECIES_AES128_SHAKE128, ECIES_AES256_SHAKE256,
+	} {
+		hashes = append(hashes, params.Hash)
+	}
+	msg := []byte("Hello, world.")
+	for i, h := range hashes {
+		for _, keyLen := range []int{0, 16, 64, 200} {
+			key := bytes.Repeat([]byte{byte(keyLen)}, keyLen)
+			// Twice, to use recycled states.
+			for j := 0; j < 2; j++ {
+				mac := hmac.New(h, key)
+				mac.Write(msg[:5])
+				mac.Write(msg[5:])
+				if !bytes.Equal(hmacSum(h, key, msg[:5], msg[5:]), mac.Sum(nil)) {
+					fmt.Println("ecies: HMAC mismatch", i, keyLen)
+					t.FailNow()
+				}
+			}
+		}
+	}
+}
+
+// Key derivation and tag of a message, with recycled hash states.
+func BenchmarkDeriveAndTag1Kb(b *testing.B) {
+	params := ECIES_AES128_SHA256
+	z := make([]byte, 32)
+	msg := make([]byte, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		K, _ := params.deriveKeys(z, nil, params.derivedKeyLen())
+		messageTag(params, macKey(params, K[params.KeyLen:]), msg, nil)
+	}
+}
@@ -0,0 +1,57 @@
+package ecies
+
+// Hedged ephemeral keys, in the spirit of RFC 6979 and its hedged variants:
+// the ephemeral scalar is derived from fresh randomness, the recipient public
+// key and the digest of the message,
+//
+//	prk = HMAC-SHA-256(entropy, "ecies-hedged-v1" || Q || SHA-256(m))
+//	okm = HKDF-Expand-SHA-256(prk, curve name, L)
+//
+// with L and the reduction of okm to a scalar as in GenerateKeyFromSeed. With
+// a good random source, the key is as random as one from GenerateKey. With a
+// broken one, it still differs for each recipient and message, and can't be
+// computed without the message, so that the ciphertext only reveals whether
+// the same message was encrypted to the same key.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var hedgedLabel = []byte("ecies-hedged-v1")
+
+// hedgedEntropySize is the number of bytes read from the random source.
+const hedgedEntropySize = 32
+
+// hedgedEphemeral derives an ephemeral key pair for encrypting m to pub.
+func hedgedEphemeral(rand io.Reader, pub *PublicKey, m []byte) (*PrivateKey, error) {
+	entropy := make([]byte, hedgedEntropySize)
+	if _, err := io.ReadFull(rand, entropy); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(m)
+	mac := hmac.New(sha256.New, entropy)
+	mac.Write(hedgedLabel)
+	mac.Write(marshalPoint(pub.Curve, pub.X, pub.Y))
+	mac.Write(digest[:])
+	prk := mac.Sum(nil)
+
+	// X25519 and X448 keys are the raw bytes themselves, of fixed length.
+	c, raw := pub.Curve.(rawPointCurve)
+	size := scalarSeedSize(pub.Curve)
+	if raw {
+		size = c.pointLen()
+	}
+	okm := make([]byte, size)
+	defer wipe(okm)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte(pub.Curve.Params().Name)), okm); err != nil {
+		return nil, err
+	}
+	if raw {
+		return NewPrivateKey(pub.Curve, okm)
+	}
+	return NewPrivateKey(pub.Curve, scalarFromSeed(pub.Curve, okm).Bytes())
+}
@@ -0,0 +1,85 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// zeroReader is a broken random source.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// Ensure that hedged ephemeral keys still differ for each message and
+// recipient with a broken random source, and that the messages decrypt.
+func TestHedged(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), X25519(), X448()} {
+		name := curve.Params().Name
+		prv1, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		prv2, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		size := pointSize(curve, 4, AllowAllPoints)
+		ephemeral := make(map[string]bool)
+		opts := &EncryptOptions{Hedged: true}
+		for _, prv := range []*PrivateKey{prv1, prv2} {
+			for _, m := range []string{"attack at dawn", "attack at dusk"} {
+				ct, err := EncryptWithOptions(zeroReader{}, &prv.PublicKey, []byte(m), nil, nil, opts)
+				if err != nil {
+					fmt.Println(name, err.Error())
+					t.FailNow()
+				}
+				if pt, err := Decrypt(prv, ct, nil, nil); err != nil || string(pt) != m {
+					fmt.Println(name, "ecies: hedged message not decrypted", err)
+					t.FailNow()
+				}
+				ephemeral[string(ct[:size])] = true
+			}
+		}
+		if len(ephemeral) != 4 {
+			fmt.Println(name, "ecies: hedged ephemeral keys repeat")
+			t.FailNow()
+		}
+
+		// With a working source, the same message gets fresh ephemeral keys.
+		ct1, err := EncryptWithOptions(rand.Reader, &prv1.PublicKey, []byte("attack at dawn"), nil, nil, opts)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		ct2, err := EncryptWithOptions(rand.Reader, &prv1.PublicKey, []byte("attack at dawn"), nil, nil, opts)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if bytes.Equal(ct1[:size], ct2[:size]) {
+			fmt.Println(name, "ecies: hedged ephemeral key ignores the random source")
+			t.FailNow()
+		}
+
+		// The keys of raw curves have leading zeros once in 256 messages:
+		// they must be kept.
+		if _, raw := curve.(rawPointCurve); raw {
+			for i := 0; i < 512; i++ {
+				if _, err := EncryptWithOptions(rand.Reader, &prv1.PublicKey, []byte("attack at dawn"), nil, nil, opts); err != nil {
+					fmt.Println(name, i, err.Error())
+					t.FailNow()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package hpke implements the base mode of Hybrid Public Key Encryption
+// (RFC 9180) with the key types of the ecies package, for interoperability
+// with the HPKE libraries of other languages.
+//
+// The DHKEM key encapsulation mechanisms over P-256, P-384, P-521 and X25519
+// are supported, with HKDF-SHA256/384/512 and the AES-128-GCM, AES-256-GCM
+// and ChaCha20-Poly1305 AEADs.
+package hpke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/foundriesio/go-ecies"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrUnsupportedSuite  = fmt.Errorf("hpke: unsupported cipher suite")
+	ErrKeyMismatch       = fmt.Errorf("hpke: key does not match the KEM")
+	ErrInvalidEncap      = fmt.Errorf("hpke: invalid encapsulated key")
+	ErrOpen              = fmt.Errorf("hpke: message authentication failed")
+	ErrMessageLimit      = fmt.Errorf("hpke: message limit reached")
+	ErrExportTooLong     = fmt.Errorf("hpke: exported secret too long")
+	ErrInvalidCiphertext = fmt.Errorf("hpke: invalid ciphertext")
+)
+
+// KEM identifies a key encapsulation mechanism.
+type KEM uint16
+
+const (
+	KEM_P256_HKDF_SHA256   KEM = 0x0010
+	KEM_P384_HKDF_SHA384   KEM = 0x0011
+	KEM_P521_HKDF_SHA512   KEM = 0x0012
+	KEM_X25519_HKDF_SHA256 KEM = 0x0020
+)
+
+// KDF identifies a key derivation function.
+type KDF uint16
+
+const (
+	KDF_HKDF_SHA256 KDF = 0x0001
+	KDF_HKDF_SHA384 KDF = 0x0002
+	KDF_HKDF_SHA512 KDF = 0x0003
+)
+
+// AEAD identifies an authenticated encryption algorithm.
+type AEAD uint16
+
+const (
+	AEAD_AES128GCM        AEAD = 0x0001
+	AEAD_AES256GCM        AEAD = 0x0002
+	AEAD_ChaCha20Poly1305 AEAD = 0x0003
+)
+
+// Suite is an HPKE cipher suite.
+type Suite struct {
+	KEM  KEM
+	KDF  KDF
+	AEAD AEAD
+}
+
+// KEMForCurve returns the KEM of the keys on the given curve.
+func KEMForCurve(curve elliptic.Curve) (KEM, error) {
+	switch curve {
+	case elliptic.P256():
+		return KEM_P256_HKDF_SHA256, nil
+	case elliptic.P384():
+		return KEM_P384_HKDF_SHA384, nil
+	case elliptic.P521():
+		return KEM_P521_HKDF_SHA512, nil
+	case ecies.X25519():
+		return KEM_X25519_HKDF_SHA256, nil
+	}
+	return 0, ErrUnsupportedSuite
+}
+
+func (kem KEM) curve() elliptic.Curve {
+	switch kem {
+	case KEM_P256_HKDF_SHA256:
+		return elliptic.P256()
+	case KEM_P384_HKDF_SHA384:
+		return elliptic.P384()
+	case KEM_P521_HKDF_SHA512:
+		return elliptic.P521()
+	case KEM_X25519_HKDF_SHA256:
+		return ecies.X25519()
+	}
+	return nil
+}
+
+func (kem KEM) kdf() KDF {
+	switch kem {
+	case KEM_P384_HKDF_SHA384:
+		return KDF_HKDF_SHA384
+	case KEM_P521_HKDF_SHA512:
+		return KDF_HKDF_SHA512
+	}
+	return KDF_HKDF_SHA256
+}
+
+func (kdf KDF) hash() func() hash.Hash {
+	switch kdf {
+	case KDF_HKDF_SHA256:
+		return sha256.New
+	case KDF_HKDF_SHA384:
+		return sha512.New384
+	case KDF_HKDF_SHA512:
+		return sha512.New
+	}
+	return nil
+}
+
+func (aead AEAD) keySize() int {
+	switch aead {
+	case AEAD_AES128GCM:
+		return 16
+	case AEAD_AES256GCM, AEAD_ChaCha20Poly1305:
+		return 32
+	}
+	return 0
+}
+
+func (aead AEAD) new(key []byte) (cipher.AEAD, error) {
+	if aead == AEAD_ChaCha20Poly1305 {
+		return chacha20poly1305.New(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s Suite) valid() bool {
+	return s.KEM.curve() != nil && s.KDF.hash() != nil && s.AEAD.keySize() != 0
+}
+
+func (s Suite) id() []byte {
+	id := []byte("HPKE")
+	id = binary.BigEndian.AppendUint16(id, uint16(s.KEM))
+	id = binary.BigEndian.AppendUint16(id, uint16(s.KDF))
+	return binary.BigEndian.AppendUint16(id, uint16(s.AEAD))
+}
+
+func kemID(kem KEM) []byte {
+	return binary.BigEndian.AppendUint16([]byte("KEM"), uint16(kem))
+}
+
+// labeledExtract and labeledExpand are defined in RFC 9180 section 4.
+func labeledExtract(h func() hash.Hash, suiteID []byte, salt []byte, label string, ikm []byte) []byte {
+	in := append([]byte("HPKE-v1"), suiteID...)
+	in = append(in, label...)
+	return hkdf.Extract(h, append(in, ikm...), salt)
+}
+
+func labeledExpand(h func() hash.Hash, suiteID []byte, prk []byte, label string, info []byte, length int) []byte {
+	in := binary.BigEndian.AppendUint16(nil, uint16(length))
+	in = append(in, "HPKE-v1"...)
+	in = append(in, suiteID...)
+	in = append(in, label...)
+	in = append(in, info...)
+	out := make([]byte, length)
+	io.ReadFull(hkdf.Expand(h, prk, in), out)
+	return out
+}
+
+// serialize encodes a public key as per RFC 9180 section 7.1.1.
+func serialize(pub *ecies.PublicKey) []byte {
+	if pub.Curve == ecies.X25519() {
+		return ecies.CompressPublicKey(pub)
+	}
+	return elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+}
+
+func deserialize(kem KEM, enc []byte) (*ecies.PublicKey, error) {
+	curve := kem.curve()
+	if curve != ecies.X25519() && (len(enc) == 0 || enc[0] != 4) {
+		return nil, ErrInvalidEncap
+	}
+	pub, err := ecies.NewPublicKeyFromBytes(curve, enc)
+	if err != nil {
+		return nil, ErrInvalidEncap
+	}
+	return pub, nil
+}
+
+// extractAndExpand derives the KEM shared secret, RFC 9180 section 4.1.
+func extractAndExpand(kem KEM, dh, kemContext []byte) []byte {
+	h := kem.kdf().hash()
+	prk := labeledExtract(h, kemID(kem), nil, "eae_prk", dh)
+	return labeledExpand(h, kemID(kem), prk, "shared_secret", kemContext, h().Size())
+}
+
+// Context is the encryption context shared by a sender and a receiver.
+type Context struct {
+	suite     Suite
+	aead      cipher.AEAD
+	baseNonce []byte
+	seq       uint64
+	exporter  []byte
+}
+
+func keySchedule(s Suite, sharedSecret, info []byte) (*Context, error) {
+	h := s.KDF.hash()
+	id := s.id()
+	pskIDHash := labeledExtract(h, id, nil, "psk_id_hash", nil)
+	infoHash := labeledExtract(h, id, nil, "info_hash", info)
+	ksContext := append([]byte{0x00}, pskIDHash...) // mode_base
+	ksContext = append(ksContext, infoHash...)
+	secret := labeledExtract(h, id, sharedSecret, "secret", nil)
+
+	key := labeledExpand(h, id, secret, "key", ksContext, s.AEAD.keySize())
+	aead, err := s.AEAD.new(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Context{
+		suite:     s,
+		aead:      aead,
+		baseNonce: labeledExpand(h, id, secret, "base_nonce", ksContext, aead.NonceSize()),
+		exporter:  labeledExpand(h, id, secret, "exp", ksContext, h().Size()),
+	}, nil
+}
+
+// SetupBaseS sets up an encryption context to pub, with the KEM of the
+// suite matching the curve of pub. It returns the encapsulated key to be
+// sent along with the messages.
+func SetupBaseS(rand io.Reader, s Suite, pub *ecies.PublicKey, info []byte) (enc []byte, ctx *Context, err error) {
+	if !s.valid() {
+		return nil, nil, ErrUnsupportedSuite
+	}
+	eph, err := ecies.GenerateKey(rand, s.KEM.curve(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return setupBaseS(s, pub, info, eph)
+}
+
+func setupBaseS(s Suite, pub *ecies.PublicKey, info []byte, eph *ecies.PrivateKey) ([]byte, *Context, error) {
+	if pub.Curve != s.KEM.curve() {
+		return nil, nil, ErrKeyMismatch
+	}
+	dh, err := eph.GenerateShared(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc := serialize(&eph.PublicKey)
+	sharedSecret := extractAndExpand(s.KEM, dh, append(append([]byte{}, enc...), serialize(pub)...))
+	ctx, err := keySchedule(s, sharedSecret, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, ctx, nil
+}
+
+// SetupBaseR sets up the decryption context for the encapsulated key enc.
+func SetupBaseR(s Suite, prv ecies.KeyProvider, enc, info []byte) (*Context, error) {
+	if !s.valid() {
+		return nil, ErrUnsupportedSuite
+	}
+	pub := prv.Public()
+	if pub.Curve != s.KEM.curve() {
+		return nil, ErrKeyMismatch
+	}
+	eph, err := deserialize(s.KEM, enc)
+	if err != nil {
+		return nil, err
+	}
+	dh, err := prv.GenerateShared(eph)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret := extractAndExpand(s.KEM, dh, append(append([]byte{}, enc...), serialize(pub)...))
+	return keySchedule(s, sharedSecret, info)
+}
+
+func (ctx *Context) nonce() ([]byte, error) {
+	n := len(ctx.baseNonce)
+	if ctx.seq == 1<<64-1 {
+		return nil, ErrMessageLimit
+	}
+	nonce := make([]byte, n)
+	binary.BigEndian.PutUint64(nonce[n-8:], ctx.seq)
+	for i := range nonce {
+		nonce[i] ^= ctx.baseNonce[i]
+	}
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates the next message of the sender.
+func (ctx *Context) Seal(aad, pt []byte) ([]byte, error) {
+	nonce, err := ctx.nonce()
+	if err != nil {
+		return nil, err
+	}
+	ctx.seq++
+	return ctx.aead.Seal(nil, nonce, pt, aad), nil
+}
+
+// Open authenticates and decrypts the next message of the receiver.
+func (ctx *Context) Open(aad, ct []byte) ([]byte, error) {
+	nonce, err := ctx.nonce()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := ctx.aead.Open(nil, nonce, ct, aad)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	ctx.seq++
+	return pt, nil
+}
+
+// Export derives a secret of the given length from the context, as per
+// RFC 9180 section 5.3.
+func (ctx *Context) Export(exporterContext []byte, length int) ([]byte, error) {
+	h := ctx.suite.KDF.hash()
+	if length > 255*h().Size() {
+		return nil, ErrExportTooLong
+	}
+	return labeledExpand(h, ctx.suite.id(), ctx.exporter, "sec", exporterContext, length), nil
+}
+
+// Seal encrypts a single message to pub, returning the encapsulated key
+// and the ciphertext.
+func Seal(rand io.Reader, s Suite, pub *ecies.PublicKey, info, aad, pt []byte) (enc, ct []byte, err error) {
+	enc, ctx, err := SetupBaseS(rand, s, pub, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ct, err = ctx.Seal(aad, pt); err != nil {
+		return nil, nil, err
+	}
+	return enc, ct, nil
+}
+
+// Open decrypts a single message sealed with Seal.
+func Open(s Suite, prv ecies.KeyProvider, enc, info, aad, ct []byte) ([]byte, error) {
+	ctx, err := SetupBaseR(s, prv, enc, info)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Open(aad, ct)
+}
@@ -0,0 +1,168 @@
+package hpke
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Ensure that the test vectors of RFC 9180 appendix A are met in base mode.
+func TestVectors(t *testing.T) {
+	vectors := []struct {
+		suite         Suite
+		curve         elliptic.Curve
+		skEm, skRm    string
+		enc           string
+		exporterValue string
+		ct            string
+	}{
+		{ // A.1.1
+			suite:         Suite{KEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES128GCM},
+			curve:         ecies.X25519(),
+			skEm:          "52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736",
+			skRm:          "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8",
+			enc:           "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+			exporterValue: "3853fe2b4035195a573ffc53856e77058e15d9ea064de3e59f4961d0095250ee",
+			ct:            "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+		},
+	}
+
+	info := decodeHex("4f6465206f6e2061204772656369616e2055726e")
+	pt := []byte("Beauty is truth, truth beauty")
+	for _, v := range vectors {
+		eph, err := ecies.NewPrivateKey(v.curve, decodeHex(v.skEm))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prv, err := ecies.NewPrivateKey(v.curve, decodeHex(v.skRm))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		enc, sender, err := setupBaseS(v.suite, &prv.PublicKey, info, eph)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if !bytes.Equal(enc, decodeHex(v.enc)) {
+			fmt.Printf("hpke: wrong encapsulated key %x\n", enc)
+			t.FailNow()
+		}
+		ct, err := sender.Seal([]byte("Count-0"), pt)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(ct, decodeHex(v.ct)) {
+			fmt.Printf("hpke: wrong ciphertext %x\n", ct)
+			t.FailNow()
+		}
+		exported, err := sender.Export(nil, 32)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(exported, decodeHex(v.exporterValue)) {
+			fmt.Printf("hpke: wrong exported value %x\n", exported)
+			t.FailNow()
+		}
+
+		receiver, err := SetupBaseR(v.suite, prv, enc, info)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		m, err := receiver.Open([]byte("Count-0"), ct)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(m, pt) {
+			fmt.Println("hpke: plaintext doesn't match")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that messages sealed with each suite open, in sequence, and that
+// the wrong key or sequence fails.
+func TestSealOpen(t *testing.T) {
+	suites := []Suite{
+		{KEM_P256_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES128GCM},
+		{KEM_P256_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_ChaCha20Poly1305},
+		{KEM_P384_HKDF_SHA384, KDF_HKDF_SHA384, AEAD_AES256GCM},
+		{KEM_P521_HKDF_SHA512, KDF_HKDF_SHA512, AEAD_AES256GCM},
+		{KEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_ChaCha20Poly1305},
+	}
+	for _, s := range suites {
+		prv, err := ecies.GenerateKey(rand.Reader, s.KEM.curve(), nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		enc, ct, err := Seal(rand.Reader, s, &prv.PublicKey, []byte("info"), []byte("aad"), []byte("message"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		m, err := Open(s, prv, enc, []byte("info"), []byte("aad"), ct)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if string(m) != "message" {
+			fmt.Println("hpke: plaintext doesn't match")
+			t.FailNow()
+		}
+		if _, err = Open(s, prv, enc, []byte("other"), []byte("aad"), ct); err != ErrOpen {
+			fmt.Println("hpke: opened with the wrong info")
+			t.FailNow()
+		}
+
+		enc, sender, err := SetupBaseS(rand.Reader, s, &prv.PublicKey, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ct0, _ := sender.Seal(nil, []byte("first"))
+		ct1, _ := sender.Seal(nil, []byte("second"))
+		receiver, err := SetupBaseR(s, prv, enc, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err = receiver.Open(nil, ct1); err != ErrOpen {
+			fmt.Println("hpke: opened a message out of sequence")
+			t.FailNow()
+		}
+		if m, err = receiver.Open(nil, ct0); err != nil || string(m) != "first" {
+			fmt.Println("hpke: failed to open the first message")
+			t.FailNow()
+		}
+		if m, err = receiver.Open(nil, ct1); err != nil || string(m) != "second" {
+			fmt.Println("hpke: failed to open the second message")
+			t.FailNow()
+		}
+	}
+
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	s := Suite{KEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES128GCM}
+	if _, _, err = Seal(rand.Reader, s, &prv.PublicKey, nil, nil, nil); err != ErrKeyMismatch {
+		fmt.Println("hpke: sealed to a key of the wrong curve")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,185 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Post-quantum hybrid encryption: the ECIES key agreement is combined with
+// an ML-KEM-768 (FIPS 203) encapsulation, and the KDF is run over both
+// shared secrets, so that the message stays confidential unless both are
+// broken.
+//
+// The ciphertext is the version byte, the ML-KEM ciphertext, the ephemeral
+// public key, then the encrypted message and tag as with Encrypt. The
+// version byte and ML-KEM ciphertext are authenticated along with s2.
+
+import (
+	"crypto/elliptic"
+	"crypto/mlkem"
+	"io"
+)
+
+var ErrInvalidHybridKey = newError(KindKey, "ecies: invalid hybrid key")
+
+// hybridVersion1 starts the ciphertexts of the ML-KEM-768 hybrid mode.
+const hybridVersion1 = 0x01
+
+// HybridPublicKey is the public key of the hybrid mode: an ECIES public key
+// along with an ML-KEM-768 encapsulation key.
+type HybridPublicKey struct {
+	EC    *PublicKey
+	MLKEM *mlkem.EncapsulationKey768
+}
+
+// HybridPrivateKey is the private key of the hybrid mode. The EC key may be
+// any key provider, so that existing keys, including those held in hardware,
+// can be extended with an ML-KEM-768 key.
+type HybridPrivateKey struct {
+	EC    KeyProvider
+	MLKEM *mlkem.DecapsulationKey768
+}
+
+// GenerateHybridKey generates a hybrid key pair on the given curve. The
+// ML-KEM key is always generated from crypto/rand.
+func GenerateHybridKey(rand io.Reader, curve elliptic.Curve, params *ECIESParams) (*HybridPrivateKey, error) {
+	prv, err := GenerateKey(rand, curve, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewHybridKey(prv)
+}
+
+// NewHybridKey extends an existing key with a new ML-KEM-768 key, to migrate
+// it to the hybrid mode. The ML-KEM key should be stored, with the bytes of
+// its seed, for the decryption of the messages encrypted to the new key.
+func NewHybridKey(ec KeyProvider) (*HybridPrivateKey, error) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		return nil, err
+	}
+	return &HybridPrivateKey{EC: ec, MLKEM: dk}, nil
+}
+
+// NewHybridPrivateKey returns the hybrid key of ec and of the ML-KEM-768 key
+// with the given 64-byte seed, as returned by the Bytes method of the key.
+func NewHybridPrivateKey(ec KeyProvider, seed []byte) (*HybridPrivateKey, error) {
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	return &HybridPrivateKey{EC: ec, MLKEM: dk}, nil
+}
+
+// Public returns the public key of prv.
+func (prv *HybridPrivateKey) Public() *HybridPublicKey {
+	return &HybridPublicKey{EC: prv.EC.Public(), MLKEM: prv.MLKEM.EncapsulationKey()}
+}
+
+// Bytes encodes pub as the ML-KEM-768 encapsulation key followed by the EC
+// public key as an uncompressed point.
+func (pub *HybridPublicKey) Bytes() []byte {
+	out := pub.MLKEM.Bytes()
+	return append(out, marshalPoint(pub.EC.Curve, pub.EC.X, pub.EC.Y)...)
+}
+
+// NewHybridPublicKey decodes a hybrid public key on the given curve encoded
+// by HybridPublicKey.Bytes, with the default parameters of the curve.
+func NewHybridPublicKey(curve elliptic.Curve, data []byte) (*HybridPublicKey, error) {
+	if len(data) < mlkem.EncapsulationKeySize768 {
+		return nil, ErrInvalidHybridKey
+	}
+	ek, err := mlkem.NewEncapsulationKey768(data[:mlkem.EncapsulationKeySize768])
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	ec, err := NewPublicKeyFromBytes(curve, data[mlkem.EncapsulationKeySize768:])
+	if err != nil {
+		return nil, err
+	}
+	return &HybridPublicKey{EC: ec, MLKEM: ek}, nil
+}
+
+// hybridParams returns the parameters of the EC key of a hybrid key.
+func hybridParams(pub *PublicKey) (*ECIESParams, error) {
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// EncryptHybrid encrypts a message to a hybrid public key, with the
+// parameters of its EC key. The shared information s1 and s2 play the same
+// role as with Encrypt.
+func EncryptHybrid(rand io.Reader, pub *HybridPublicKey, m, s1, s2 []byte) ([]byte, error) {
+	if pub.EC == nil || pub.MLKEM == nil {
+		return nil, ErrInvalidHybridKey
+	}
+	params, err := hybridParams(pub.EC)
+	if err != nil {
+		return nil, err
+	}
+	ss, kemCt := pub.MLKEM.Encapsulate()
+	z, Rb, err := encapsulate(rand, pub.EC, params, false)
+	if err != nil {
+		return nil, err
+	}
+	K, err := params.deriveKeys(params.kdfInput(Rb, append(z, ss...)), s1, params.derivedKeyLen())
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{hybridVersion1}, kemCt...)
+	em, err := demSeal(rand, params, K, m, bindHeader(header, s2))
+	if err != nil {
+		return nil, err
+	}
+	ct := make([]byte, 0, len(header)+len(Rb)+len(em))
+	ct = append(ct, header...)
+	ct = append(ct, Rb...)
+	return append(ct, em...), nil
+}
+
+// DecryptHybrid decrypts a ciphertext produced by EncryptHybrid.
+func DecryptHybrid(prv *HybridPrivateKey, c, s1, s2 []byte) ([]byte, error) {
+	if prv.EC == nil || prv.MLKEM == nil {
+		return nil, ErrInvalidHybridKey
+	}
+	pub := prv.EC.Public()
+	params, err := hybridParams(pub)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := 1 + mlkem.CiphertextSize768
+	if len(c) <= headerLen {
+		return nil, ErrInvalidMessage
+	}
+	if c[0] != hybridVersion1 {
+		return nil, ErrUnknownFormat
+	}
+	header, body := c[:headerLen], c[headerLen:]
+	rLen := pointSize(pub.Curve, body[0], AllowCompressedPoints)
+	if rLen == 0 || len(body) < rLen {
+		return nil, ErrInvalidPublicKey
+	}
+	R, err := parseEncapsulation(pub, body[:rLen], AllowCompressedPoints)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := prv.MLKEM.Decapsulate(header[1:])
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	z, err := prv.EC.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	K, err := params.deriveKeys(params.kdfInput(body[:rLen], append(z, ss...)), s1, params.derivedKeyLen())
+	if err != nil {
+		return nil, err
+	}
+	return demOpen(params, K, body[rLen:], bindHeader(header, s2))
+}
@@ -0,0 +1,101 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure hybrid ciphertexts decrypt with the CTR and AEAD parameters, with
+// keys restored from their encodings, and that tampering is detected.
+func TestHybrid(t *testing.T) {
+	for _, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES256_GCM_SHA512} {
+		for _, c := range []elliptic.Curve{elliptic.P256(), X25519()} {
+			name := c.Params().Name
+			prv, err := GenerateHybridKey(rand.Reader, c, params)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			pub, err := NewHybridPublicKey(c, prv.Public().Bytes())
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			pub.EC.Params = params
+			ct, err := EncryptHybrid(rand.Reader, pub, []byte("message"), []byte("s1"), []byte("s2"))
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+
+			restored, err := NewHybridPrivateKey(prv.EC, prv.MLKEM.Bytes())
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			m, err := DecryptHybrid(restored, ct, []byte("s1"), []byte("s2"))
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if !bytes.Equal(m, []byte("message")) {
+				fmt.Println(name, "ecies: plaintext doesn't match")
+				t.FailNow()
+			}
+
+			if _, err = DecryptHybrid(prv, ct, []byte("s1"), []byte("other")); err != ErrInvalidMessage {
+				fmt.Println(name, "ecies: decrypted with the wrong shared information", err)
+				t.FailNow()
+			}
+			ct[10] ^= 1
+			if _, err = DecryptHybrid(prv, ct, []byte("s1"), []byte("s2")); err != ErrInvalidMessage {
+				fmt.Println(name, "ecies: decrypted a tampered ML-KEM ciphertext", err)
+				t.FailNow()
+			}
+			ct[10] ^= 1
+			ct[0] = 2
+			if _, err = DecryptHybrid(prv, ct, []byte("s1"), []byte("s2")); err != ErrUnknownFormat {
+				fmt.Println(name, "ecies: decrypted an unknown version", err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure an existing key migrated to the hybrid mode still decrypts the
+// classic ciphertexts.
+func TestHybridMigration(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	classic, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("old"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	hybrid, err := NewHybridKey(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := EncryptHybrid(rand.Reader, hybrid.Public(), []byte("new"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if m, err := Decrypt(hybrid.EC, classic, nil, nil); err != nil || string(m) != "old" {
+		fmt.Println("ecies: classic message not decrypted", err)
+		t.FailNow()
+	}
+	if m, err := DecryptHybrid(hybrid, ct, nil, nil); err != nil || string(m) != "new" {
+		fmt.Println("ecies: hybrid message not decrypted", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,258 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// JSON Web Encryption (RFC 7516) compact serialization with the ECDH-ES key
+// agreement of RFC 7518 section 4.6.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+var (
+	ErrInvalidJWE     = newError(KindEncoding, "ecies: invalid JWE")
+	ErrUnsupportedJWE = newError(KindUnsupported, "ecies: unsupported JWE algorithm")
+)
+
+// The JWE key management algorithms.
+const (
+	JWEDirect = "ECDH-ES"
+	JWEA128KW = "ECDH-ES+A128KW"
+	JWEA192KW = "ECDH-ES+A192KW"
+	JWEA256KW = "ECDH-ES+A256KW"
+)
+
+const (
+	jweA128GCM  = "A128GCM"
+	jweA192GCM  = "A192GCM"
+	jweA256GCM  = "A256GCM"
+	jweGCMNonce = 12
+)
+
+// The key sizes of the key wrapping and content encryption algorithms.
+var (
+	jweWrapKeySizes    = map[string]int{JWEA128KW: 16, JWEA192KW: 24, JWEA256KW: 32}
+	jweContentKeySizes = map[string]int{jweA128GCM: 16, jweA192GCM: 24, jweA256GCM: 32}
+)
+
+// jweAlgorithms returns the size of the key derived by ECDH-ES, the algorithm
+// ID of the derivation and the size of the content encryption key.
+func jweAlgorithms(alg, enc string) (kdfSize int, algID string, cekSize int, err error) {
+	cekSize, ok := jweContentKeySizes[enc]
+	if !ok {
+		return 0, "", 0, ErrUnsupportedJWE
+	}
+	// In direct mode, the derived key is the content encryption key, and
+	// the algorithm ID is the content encryption algorithm.
+	if alg == JWEDirect {
+		return cekSize, enc, cekSize, nil
+	}
+	if kdfSize, ok = jweWrapKeySizes[alg]; !ok {
+		return 0, "", 0, ErrUnsupportedJWE
+	}
+	return kdfSize, alg, cekSize, nil
+}
+
+// JWEOptions tune EncryptJWE.
+type JWEOptions struct {
+	// Algorithm is the key management algorithm, JWEDirect or one of the
+	// key wrapping ones; it defaults to JWEA256KW.
+	Algorithm string
+	// Encryption is the content encryption algorithm, A128GCM, A192GCM or
+	// A256GCM; it defaults to A256GCM.
+	Encryption string
+	// PartyUInfo and PartyVInfo are the "apu" and "apv" inputs of the key
+	// derivation, identifying the sender and the recipient.
+	PartyUInfo []byte
+	PartyVInfo []byte
+	// KeyID and ContentType set the "kid" and "cty" header parameters.
+	KeyID       string
+	ContentType string
+}
+
+type jweHeader struct {
+	Alg  string      `json:"alg"`
+	Enc  string      `json:"enc"`
+	Kid  string      `json:"kid,omitempty"`
+	Cty  string      `json:"cty,omitempty"`
+	Epk  *jsonWebKey `json:"epk"`
+	Apu  string      `json:"apu,omitempty"`
+	Apv  string      `json:"apv,omitempty"`
+	Zip  string      `json:"zip,omitempty"`
+	Crit []string    `json:"crit,omitempty"`
+}
+
+// jweKDF derives the key of the key wrapping algorithm, or the content
+// encryption key in direct mode, with the Concat KDF of RFC 7518 section
+// 4.6.2.
+func jweKDF(z []byte, alg string, size int, apu, apv []byte) ([]byte, error) {
+	var info []byte
+	for _, field := range [][]byte{[]byte(alg), apu, apv} {
+		info = binary.BigEndian.AppendUint32(info, uint32(len(field)))
+		info = append(info, field...)
+	}
+	info = binary.BigEndian.AppendUint32(info, uint32(size*8))
+	return concatKDF(sha256.New(), z, info, size)
+}
+
+// EncryptJWE encrypts a message for pub as a JWE in the compact serialization,
+// with an ephemeral key on the curve of pub, as the JOSE libraries expect.
+func EncryptJWE(rand io.Reader, pub *PublicKey, m []byte, opts *JWEOptions) (string, error) {
+	if opts == nil {
+		opts = &JWEOptions{}
+	}
+	h := jweHeader{Alg: opts.Algorithm, Enc: opts.Encryption, Kid: opts.KeyID, Cty: opts.ContentType}
+	if h.Alg == "" {
+		h.Alg = JWEA256KW
+	}
+	if h.Enc == "" {
+		h.Enc = jweA256GCM
+	}
+	kekSize, algID, cekSize, err := jweAlgorithms(h.Alg, h.Enc)
+	if err != nil {
+		return "", err
+	}
+
+	eph, err := GenerateKey(rand, pub.Curve, nil)
+	if err != nil {
+		return "", err
+	}
+	if h.Epk, err = jwkFromPublic(&eph.PublicKey); err != nil {
+		return "", err
+	}
+	z, err := eph.GenerateShared(pub)
+	if err != nil {
+		return "", err
+	}
+	h.Apu = base64.RawURLEncoding.EncodeToString(opts.PartyUInfo)
+	h.Apv = base64.RawURLEncoding.EncodeToString(opts.PartyVInfo)
+	kek, err := jweKDF(z, algID, kekSize, opts.PartyUInfo, opts.PartyVInfo)
+	if err != nil {
+		return "", err
+	}
+
+	cek, encryptedKey := kek, []byte(nil)
+	if h.Alg != JWEDirect {
+		cek = make([]byte, cekSize)
+		if _, err = io.ReadFull(rand, cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = aesKeyWrap(kek, cek); err != nil {
+			return "", err
+		}
+	}
+
+	header, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	nonce := make([]byte, jweGCMNonce)
+	if _, err = io.ReadFull(rand, nonce); err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nil, nonce, m, []byte(protected))
+	ct, tag := sealed[:len(m)], sealed[len(m):]
+
+	enc := base64.RawURLEncoding
+	return strings.Join([]string{
+		protected,
+		enc.EncodeToString(encryptedKey),
+		enc.EncodeToString(nonce),
+		enc.EncodeToString(ct),
+		enc.EncodeToString(tag),
+	}, "."), nil
+}
+
+// DecryptJWE decrypts a JWE in the compact serialization, encrypted to the key
+// of prv with ECDH-ES, directly or with AES key wrapping, and AES-GCM.
+// Compressed payloads and critical header parameters are not supported.
+func DecryptJWE(prv KeyProvider, jwe string) ([]byte, error) {
+	parts := strings.Split(jwe, ".")
+	if len(parts) != 5 {
+		return nil, ErrInvalidJWE
+	}
+	var raw [5][]byte
+	for i, part := range parts {
+		var err error
+		if raw[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, ErrInvalidJWE
+		}
+	}
+	var h jweHeader
+	if err := json.Unmarshal(raw[0], &h); err != nil || h.Epk == nil {
+		return nil, ErrInvalidJWE
+	}
+	if h.Zip != "" || len(h.Crit) != 0 {
+		return nil, ErrUnsupportedJWE
+	}
+	kekSize, algID, cekSize, err := jweAlgorithms(h.Alg, h.Enc)
+	if err != nil {
+		return nil, err
+	}
+	apu, err := base64.RawURLEncoding.DecodeString(h.Apu)
+	if err != nil {
+		return nil, ErrInvalidJWE
+	}
+	apv, err := base64.RawURLEncoding.DecodeString(h.Apv)
+	if err != nil {
+		return nil, ErrInvalidJWE
+	}
+
+	epk, err := h.Epk.public()
+	if err != nil {
+		return nil, err
+	}
+	if epk.Curve != prv.Public().Curve {
+		return nil, ErrInvalidCurve
+	}
+	z, err := prv.GenerateShared(epk)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := jweKDF(z, algID, kekSize, apu, apv)
+	if err != nil {
+		return nil, err
+	}
+	cek := kek
+	if h.Alg == JWEDirect {
+		if len(raw[1]) != 0 {
+			return nil, ErrInvalidJWE
+		}
+	} else if cek, err = aesKeyUnwrap(kek, raw[1]); err != nil || len(cek) != cekSize {
+		return nil, ErrInvalidMessage
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw[2]) != jweGCMNonce || len(raw[4]) != aead.Overhead() {
+		return nil, ErrInvalidJWE
+	}
+	m, err := aead.Open(nil, raw[2], append(raw[3], raw[4]...), []byte(parts[0]))
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
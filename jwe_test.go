@@ -0,0 +1,107 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// A JWE produced by go-jose for the P-256 key below, with ECDH-ES+A256KW and
+// A256GCM.
+const (
+	joseJWE = "eyJhbGciOiJFQ0RILUVTK0EyNTZLVyIsImVuYyI6IkEyNTZHQ00iLCJlcGsiOnsia3R5IjoiRUMiLCJjcnYiOiJQLTI1NiIsIngiOiJyNzA2LVZkcDlEaHhCVzNlZERxVmtteXEwWkV5MXdSQ3lESzBwclpoNTVVIiwieSI6InQ1aEtaRHpTdTRPeWlDRE5SQTBwZUlZRUVoQkxwdGZUbktLTmx1VlgwMWcifX0." +
+		"GGKkkmXSXzyuURouQZS0helqZnsfgm9TE0CrcGfwLOcB42G6jzEkeg.jM1fbADU7-DGabtC.3NpBf-0.WsxJNHwK8jg3cJiP1u-0gQ"
+	joseKey = "30770201010420f941b4bd3ea3cf3fcd463fb8fde3736579095ab400e3e7b2badd8d097dc8bebca00a06082a8648ce3d030107a144034200049452fd08437ddba5b49d783d19c66d9d226dec0e525f6488a79e0caa72efc9f6fccceb9a93910ef1a07e98eae48d1572ff0f2a83f3f0e7eaecf54493518ea086"
+)
+
+// Validate the key derivation against RFC 7518 appendix C.
+func TestJWEKDF(t *testing.T) {
+	b64 := func(s string) *big.Int {
+		b, _ := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b)
+	}
+	bob := &PrivateKey{D: b64("VEmDZpDXXK8p8N0Cndsxs924q6nS1RXFASRl6BfUqdw")}
+	bob.Curve = elliptic.P256()
+	alice, err := NewPublicKey(elliptic.P256(),
+		b64("gI0GAILBdu7T53akrFmMyGcsF3n5dO7MmwNBHKW5SV0"),
+		b64("SLW_xSffzlPWrHEVI30DHM_4egVwt3NQqeUD7nMFpps"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	z, err := bob.GenerateShared(alice)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	k, err := jweKDF(z, "A128GCM", 16, []byte("Alice"), []byte("Bob"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if base64.RawURLEncoding.EncodeToString(k) != "VqqN6vgjbSBcIijNcacQGg" {
+		fmt.Println("ecies: JWE key derivation doesn't match")
+		t.FailNow()
+	}
+}
+
+// Ensure that JWEs round trip with all the algorithms, and that those of
+// go-jose are decrypted.
+func TestJWE(t *testing.T) {
+	der, _ := hex.DecodeString(joseKey)
+	prv, err := UnmarshalPrivateSEC1(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	m, err := DecryptJWE(prv, joseJWE)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if string(m) != "world" {
+		fmt.Println("ecies: go-jose JWE not decrypted")
+		t.FailNow()
+	}
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P521(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, alg := range []string{JWEDirect, JWEA128KW, JWEA192KW, JWEA256KW} {
+			opts := &JWEOptions{Algorithm: alg, Encryption: "A128GCM", PartyVInfo: []byte("device")}
+			jwe, err := EncryptJWE(rand.Reader, &prv.PublicKey, []byte("payload"), opts)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			m, err := DecryptJWE(prv, jwe)
+			if err != nil {
+				fmt.Println(curve.Params().Name, alg, err.Error())
+				t.FailNow()
+			} else if !bytes.Equal(m, []byte("payload")) {
+				fmt.Println("ecies: JWE plaintext doesn't match")
+				t.FailNow()
+			}
+		}
+	}
+
+	if _, err = EncryptJWE(rand.Reader, &prv.PublicKey, nil, &JWEOptions{Algorithm: "RSA-OAEP"}); err != ErrUnsupportedJWE {
+		fmt.Println("ecies: unsupported JWE algorithm accepted")
+		t.FailNow()
+	}
+	tampered := strings.Replace(joseJWE, ".3NpBf", ".4NpBf", 1)
+	if _, err = DecryptJWE(prv, tampered); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered JWE accepted", err)
+		t.FailNow()
+	}
+}
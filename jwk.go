@@ -0,0 +1,119 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// JSON Web Key (RFC 7517) encoding of the elliptic curve public keys.
+
+import (
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// The JWK curve names, see RFC 7518 section 6.2.1.1 and RFC 8812 section 3.1.
+var jwkCurveNames = map[elliptic.Curve]string{
+	elliptic.P256(): "P-256",
+	elliptic.P384(): "P-384",
+	elliptic.P521(): "P-521",
+	Secp256k1():     "secp256k1",
+}
+
+func curveFromJWKName(name string) elliptic.Curve {
+	for curve, n := range jwkCurveNames {
+		if n == name {
+			return curve
+		}
+	}
+	return nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+}
+
+// MarshalPublicJWK encodes a public key as a JSON Web Key, or as an octet key
+// pair (RFC 8037) for X25519 and X448 keys.
+func MarshalPublicJWK(pub *PublicKey) ([]byte, error) {
+	jwk, err := jwkFromPublic(pub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jwk)
+}
+
+func jwkFromPublic(pub *PublicKey) (*jsonWebKey, error) {
+	if montgomeryCurve(pub.Curve) && pub.X != nil {
+		return &jsonWebKey{
+			Kty: "OKP",
+			Crv: pub.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(marshalPoint(pub.Curve, pub.X, pub.Y)),
+		}, nil
+	}
+	name, ok := jwkCurveNames[pub.Curve]
+	if !ok || pub.X == nil || pub.Y == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	return &jsonWebKey{
+		Kty: "EC",
+		Crv: name,
+		X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+	}, nil
+}
+
+// UnmarshalPublicJWK decodes an elliptic curve JSON Web Key, or an X25519 or
+// X448 octet key pair. Private key members, if any, are ignored.
+func UnmarshalPublicJWK(in []byte) (*PublicKey, error) {
+	var jwk jsonWebKey
+	if err := json.Unmarshal(in, &jwk); err != nil {
+		return nil, err
+	}
+	return jwk.public()
+}
+
+func (jwk *jsonWebKey) public() (*PublicKey, error) {
+	if jwk.Kty == "OKP" {
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "X25519":
+			curve = X25519()
+		case "X448":
+			curve = X448()
+		default:
+			return nil, ErrInvalidCurve
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != pointSize(curve, 0, AllowAllPoints) {
+			return nil, ErrInvalidPublicKey
+		}
+		return &PublicKey{
+			X:      new(big.Int).SetBytes(x),
+			Y:      new(big.Int),
+			Curve:  curve,
+			Params: ParamsFromCurve(curve),
+		}, nil
+	}
+	if jwk.Kty != "EC" {
+		return nil, ErrInvalidPublicKey
+	}
+	curve := curveFromJWKName(jwk.Crv)
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil || len(x) != size {
+		return nil, ErrInvalidPublicKey
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil || len(y) != size {
+		return nil, ErrInvalidPublicKey
+	}
+	return NewPublicKey(curve, new(big.Int).SetBytes(x), new(big.Int).SetBytes(y))
+}
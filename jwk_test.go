@@ -0,0 +1,46 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that the RFC 7517 appendix A.1 key is decoded and encoded back.
+func TestVectorPublicJWK(t *testing.T) {
+	in := `{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM","use":"enc","kid":"1"}`
+	pub, err := UnmarshalPublicJWK([]byte(in))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if pub.Curve != elliptic.P256() {
+		fmt.Println("ecies: decoded JWK on the wrong curve")
+		t.FailNow()
+	}
+
+	out, err := MarshalPublicJWK(pub)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	expected := `{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}`
+	if string(out) != expected {
+		fmt.Println("ecies: unexpected JWK", string(out))
+		t.FailNow()
+	}
+
+	for _, bad := range []string{
+		`{"kty":"RSA","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}`,
+		`{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyA"}`,
+		`{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"}`,
+	} {
+		if _, err := UnmarshalPublicJWK([]byte(bad)); !errors.Is(err, ErrInvalidPublicKey) {
+			fmt.Println("ecies: accepted an invalid JWK", bad)
+			t.FailNow()
+		}
+	}
+}
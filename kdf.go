@@ -0,0 +1,90 @@
+package ecies
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// kdfAlgorithm identifies the key derivation function of the standard
+// parameter sets, for the suite identifiers and ASN.1 encodings.
+type kdfAlgorithm int
+
+const (
+	kdfConcat kdfAlgorithm = iota
+	kdfHKDF
+	kdfX963
+)
+
+// deriveKeys derives length bytes of keys for the data encapsulation from
+// the shared secret z and the shared information s1. Errors of a custom KDF
+// are wrapped in ErrKeyDerivation.
+func (params *ECIESParams) deriveKeys(z, s1 []byte, length int) ([]byte, error) {
+	if params.KDF != nil {
+		k, err := params.KDF(params.Hash(), z, s1, length)
+		if err != nil && !errors.Is(err, KindKDF) {
+			err = wrapError(ErrKeyDerivation, err)
+		} else if err == nil && len(k) != length {
+			err = ErrKeyDataTooLong
+		}
+		return k, err
+	}
+	if params.kdf == kdfHKDF {
+		return hkdfKDF(params.Hash, z, s1, length)
+	}
+	h := getHash(params.Hash)
+	defer putHash(params.Hash, h)
+	if params.kdf == kdfX963 {
+		return x963KDF(h, z, s1, length)
+	}
+	return concatKDF(h, z, s1, length)
+}
+
+// kdfInput returns the input of the KDF: the shared secret z, preceded by
+// the encoded ephemeral key Rb if the parameters say so.
+func (params *ECIESParams) kdfInput(Rb, z []byte) []byte {
+	if !params.EphemeralInKDF {
+		return z
+	}
+	return append(append(make([]byte, 0, len(Rb)+len(z)), Rb...), z...)
+}
+
+// x963KDF is the ANSI X9.63 KDF, as used by Bouncy Castle and the Apple
+// Security framework: counterKDF from 1, with s1 as the shared information.
+func x963KDF(h hash.Hash, z, s1 []byte, length int) ([]byte, error) {
+	if uint64(length) >= uint64(h.Size())*(1<<32-1) {
+		return nil, ErrKeyDataTooLong
+	}
+	return counterKDF(h, z, s1, length, 1), nil
+}
+
+// hkdfKDF is HKDF (RFC 5869) with an empty salt and s1 as the info.
+func hkdfKDF(h func() hash.Hash, z, s1 []byte, length int) ([]byte, error) {
+	if length > 255*h().Size() {
+		return nil, ErrKeyDataTooLong
+	}
+	k := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(h, z, nil, s1), k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// counterKDF is the ISO 18033-2 KDF1/KDF2 and ANSI X9.63 construction:
+// Hash(z || counter || info) for consecutive 32-bit counters from start.
+func counterKDF(h hash.Hash, z, info []byte, length int, start uint32) []byte {
+	var counter [4]byte
+	k := make([]byte, 0, length+h.Size())
+	for i := start; len(k) < length; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		h.Reset()
+		h.Write(z)
+		h.Write(counter[:])
+		h.Write(info)
+		k = h.Sum(k)
+	}
+	return k[:length]
+}
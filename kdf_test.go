@@ -0,0 +1,235 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// Ensure HKDF matches RFC 5869 test case 3, and the HKDF parameters decrypt
+// an independently computed ciphertext and survive a DER round trip.
+func TestHKDF(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	okm, err := hkdfKDF(sha256.New, bytes.Repeat([]byte{0x0b}, 22), nil, 42)
+	if err != nil || !bytes.Equal(okm, hexBytes("8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8")) {
+		fmt.Println("ecies: unexpected HKDF output", err)
+		t.FailNow()
+	}
+	if _, err = hkdfKDF(sha256.New, nil, nil, 255*32+1); err != ErrKeyDataTooLong {
+		fmt.Println("ecies: HKDF output too long", err)
+		t.FailNow()
+	}
+
+	prv, err := NewPrivateKey(elliptic.P256(), hexBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_AES128_HKDF_SHA256
+	ct := hexBytes("04e266ddfdc12668db30d4ca3e8f7749432c416044f2d2b8c10bf3d4012aeffa8abfa86404a2e9ffe67d47c587ef7a97a7f456b863b4d02cfc6928973ab5b1cb39" +
+		"000102030405060708090a0b0c0d0e0f559203eaab3e54ef72f0164d" +
+		"aad8061506ff62e91a3313464ebbb5f1a53c1c78d716571640087997b4149963")
+	pt, err := Decrypt(prv, ct, []byte("s1"), []byte("s2"))
+	if err != nil || string(pt) != "Hello, HKDF." {
+		fmt.Println("ecies: HKDF vector not decrypted", err)
+		t.FailNow()
+	}
+
+	der, err := MarshalPublic(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Contains(der, hexBytes("060b2a864886f70d010910031c")) {
+		fmt.Println("ecies: HKDF identifier not advertised")
+		t.FailNow()
+	}
+	pub, err := UnmarshalPublic(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if id, ok := suiteID(pub.Params); !ok || id != 13 {
+		fmt.Println("ecies: HKDF parameters lost in DER", id)
+		t.FailNow()
+	}
+	prv.PublicKey.Params = pub.Params
+	if pt, err = Decrypt(prv, ct, []byte("s1"), []byte("s2")); err != nil || string(pt) != "Hello, HKDF." {
+		fmt.Println("ecies: HKDF vector not decrypted with decoded parameters", err)
+		t.FailNow()
+	}
+}
+
+// Ensure the X9.63 KDF matches the NIST CAVS vector, and the X9.63
+// parameters decrypt an independently computed ciphertext.
+func TestX963KDF(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	k, err := x963KDF(sha256.New(), hexBytes("96c05619d56c328ab95fe84b18264b08725b85e33fd34f08"), nil, 16)
+	if err != nil || !bytes.Equal(k, hexBytes("443024c3dae66b95e6f5670601558f71")) {
+		fmt.Println("ecies: unexpected X9.63 KDF output", err)
+		t.FailNow()
+	}
+
+	prv, err := NewPrivateKey(elliptic.P256(), hexBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_AES128_X963_SHA256
+	ct := hexBytes("04e266ddfdc12668db30d4ca3e8f7749432c416044f2d2b8c10bf3d4012aeffa8abfa86404a2e9ffe67d47c587ef7a97a7f456b863b4d02cfc6928973ab5b1cb39" +
+		"000102030405060708090a0b0c0d0e0f2b019a3ffe0c721738084af5fe" +
+		"8dc20703eed2354d323cbc6e1471bbec4bfba5a68b0bb5e312f7c2e7f542b065")
+	pt, err := Decrypt(prv, ct, []byte("s1"), []byte("s2"))
+	if err != nil || string(pt) != "Hello, X9.63." {
+		fmt.Println("ecies: X9.63 vector not decrypted", err)
+		t.FailNow()
+	}
+
+	der, err := MarshalPublic(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub, err := UnmarshalPublic(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if id, ok := suiteID(pub.Params); !ok || id != 16 {
+		fmt.Println("ecies: X9.63 parameters lost in DER", id)
+		t.FailNow()
+	}
+}
+
+// Ensure a custom KDF replaces the standard one, and that parameters using
+// it are neither encoded nor identified.
+func TestCustomKDF(t *testing.T) {
+	calls := 0
+	params := *ECIES_AES128_SHA256
+	params.KDF = func(h hash.Hash, z, info []byte, length int) ([]byte, error) {
+		calls++
+		return concatKDF(h, z, info, length)
+	}
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), &params)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, []byte("s1"), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_AES128_SHA256
+	if pt, err := Decrypt(prv, ct, []byte("s1"), nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: custom KDF output differs from concatKDF", err)
+		t.FailNow()
+	}
+	prv.PublicKey.Params = &params
+	if pt, err := Decrypt(prv, ct, []byte("s1"), nil); err != nil || !bytes.Equal(pt, message) || calls != 2 {
+		fmt.Println("ecies: custom KDF not used", err, calls)
+		t.FailNow()
+	}
+
+	params.KDF = func(h hash.Hash, z, info []byte, length int) ([]byte, error) {
+		return make([]byte, length-1), nil
+	}
+	if _, err = Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil); err != ErrKeyDataTooLong {
+		fmt.Println("ecies: short custom KDF output accepted", err)
+		t.FailNow()
+	}
+	if _, err = MarshalPublic(&prv.PublicKey); err != ErrUnsupportedECIESParameters {
+		fmt.Println("ecies: custom KDF encoded in DER", err)
+		t.FailNow()
+	}
+	if _, err = EncodeEnvelope(&prv.PublicKey, ct); err != ErrUnsupportedECIESParameters {
+		fmt.Println("ecies: custom KDF given a suite identifier", err)
+		t.FailNow()
+	}
+}
+
+// Ensure the keys derived with and without the ephemeral key in the KDF input
+// match independently computed vectors, for KDF2-SHA256 over P-256, and that
+// each setting only decrypts its own ciphertexts.
+func TestEphemeralInKDF(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	d := make([]byte, 32)
+	for i := range d {
+		d[i] = byte(i + 1)
+	}
+	prv, err := NewPrivateKey(elliptic.P256(), d)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	R, err := NewPrivateKey(elliptic.P256(), hexBytes("8341425cafede9d24b0599aefdfdeff1c1526ed75b07217eb99bf8c0b7498b81"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	z, Rb, err := encapsulateWith(R, &prv.PublicKey, false)
+	if err != nil || !bytes.Equal(Rb, hexBytes("049a781ca6d055a7f30d0c9ff87936c739f6816ef5f5e72b4b946404b0a1a83b2ac19f842945ea2bf65aa1649b2b02ff79854c8d5ecfcd403862e8a97ea66c71c1")) ||
+		!bytes.Equal(z, hexBytes("15b7df8875b0bbcd53ef666e29e1c045c6f390c392d968ba0446cbf95aefceab")) {
+		fmt.Println("ecies: unexpected encapsulation", err)
+		t.FailNow()
+	}
+
+	without := *ECIES_AES128_ISO18033_SHA256
+	without.EphemeralInKDF = false
+	for _, v := range []struct {
+		params *ECIESParams
+		s1     string
+		k      string
+	}{
+		{ECIES_AES128_ISO18033_SHA256, "", "75f9e23b58416fadd90ff22738774048c820a222a3e50ed9a8cb9e1807907f43"},
+		{ECIES_AES128_ISO18033_SHA256, "s1", "7a0ce788edeb470391bba7dc5a16c8d7f0c2d9f07ad0f654f7a44f74499787c3"},
+		{&without, "", "3e1462fc6af34c7e1c16da26dfe826c319f0fc2b78fb5bb5d0093b7fafe4ec89"},
+		{&without, "s1", "56f631ab18a9b541dd3aec1e083be6760674c2b7213b86cbf4efdf25ae3ec41d"},
+	} {
+		k, err := v.params.deriveKeys(v.params.kdfInput(Rb, z), []byte(v.s1), v.params.derivedKeyLen())
+		if err != nil || !bytes.Equal(k, hexBytes(v.k)) {
+			fmt.Printf("ecies: unexpected keys with EphemeralInKDF %v: %x %v\n", v.params.EphemeralInKDF, k, err)
+			t.FailNow()
+		}
+
+		// The encryption wipes its ephemeral key.
+		R, _ := NewPrivateKey(elliptic.P256(), hexBytes("8341425cafede9d24b0599aefdfdeff1c1526ed75b07217eb99bf8c0b7498b81"))
+		prv.PublicKey.Params = v.params
+		ct, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, []byte("message"), []byte(v.s1), nil, &EncryptOptions{ephemeral: R})
+		if err != nil || !bytes.HasPrefix(ct, Rb) {
+			fmt.Println("ecies: unexpected ciphertext", err)
+			t.FailNow()
+		}
+		if m, err := Decrypt(prv, ct, []byte(v.s1), nil); err != nil || string(m) != "message" {
+			fmt.Println("ecies: message not restored", err)
+			t.FailNow()
+		}
+		other := ECIES_AES128_ISO18033_SHA256
+		if v.params.EphemeralInKDF {
+			other = &without
+		}
+		prv.PublicKey.Params = other
+		if _, err = Decrypt(prv, ct, []byte(v.s1), nil); err != ErrInvalidMessage {
+			fmt.Println("ecies: message decrypted with the other KDF input", err)
+			t.FailNow()
+		}
+	}
+}
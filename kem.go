@@ -0,0 +1,134 @@
+package ecies
+
+// The key encapsulation mechanism of ECIES, on its own: an ephemeral key
+// agreement with the recipient's public key, whose ephemeral public key is
+// the encapsulation of the shared secret.
+
+import (
+	"io"
+	"math/big"
+)
+
+// encapsulate generates an ephemeral key pair for pub, returning the shared
+// secret and the encoded ephemeral public key. pub is validated first.
+func encapsulate(rand io.Reader, pub *PublicKey, params *ECIESParams, compress bool) (z, enc []byte, err error) {
+	if err = ValidatePublicKey(pub); err != nil {
+		return nil, nil, err
+	}
+	R, err := GenerateKey(rand, pub.Curve, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encapsulateWith(R, pub, compress)
+}
+
+// encapsulateWith is encapsulate with the given ephemeral key pair.
+func encapsulateWith(R *PrivateKey, pub *PublicKey, compress bool) (z, enc []byte, err error) {
+	if z, err = generateShared(R, pub); err != nil {
+		return nil, nil, err
+	}
+	if compress {
+		enc = marshalCompressedPoint(pub.Curve, R.PublicKey.X, R.PublicKey.Y)
+	} else {
+		enc = marshalPoint(pub.Curve, R.PublicKey.X, R.PublicKey.Y)
+	}
+	return z, enc, nil
+}
+
+// parseEncapsulation decodes an ephemeral public key on the curve of pub.
+func parseEncapsulation(pub *PublicKey, enc []byte, policy PointFormatPolicy) (*PublicKey, error) {
+	var x, y *big.Int
+	if x, y = unmarshalPoint(pub.Curve, enc, policy); x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	if !pub.Curve.IsOnCurve(x, y) {
+		return nil, ErrInvalidCurve
+	}
+	return &PublicKey{X: x, Y: y, Curve: pub.Curve, Params: pub.Params}, nil
+}
+
+// Encapsulate generates a shared key for pub and its encapsulation, to be
+// sent to the owner of the private key, who recovers the key with
+// Decapsulate. The key is derived with the KDF of the parameters of pub, and
+// has the size of their hash, for use with any data encapsulation.
+func Encapsulate(rand io.Reader, pub *PublicKey) (sharedKey, encapsulation []byte, err error) {
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err = enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, nil, err
+	}
+	z, enc, err := encapsulate(rand, pub, params, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sharedKey, err = params.deriveKeys(params.kdfInput(enc, z), nil, params.Hash().Size()); err != nil {
+		return nil, nil, err
+	}
+	return sharedKey, enc, nil
+}
+
+// Decapsulate recovers the shared key from an encapsulation produced by
+// Encapsulate.
+func Decapsulate(prv KeyProvider, encapsulation []byte) (sharedKey []byte, err error) {
+	pub := prv.Public()
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err = enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	if len(encapsulation) == 0 || pointSize(pub.Curve, encapsulation[0], AllowCompressedPoints) != len(encapsulation) {
+		return nil, ErrInvalidPublicKey
+	}
+	R, err := parseEncapsulation(pub, encapsulation, AllowCompressedPoints)
+	if err != nil {
+		return nil, err
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	return params.deriveKeys(params.kdfInput(encapsulation, z), nil, params.Hash().Size())
+}
+
+// DeriveKey returns a key of length bytes shared with the owner of peer: the
+// ECDH shared secret of prv and peer, through the KDF of the parameters of
+// prv with info as the shared information s1. The owner of peer derives the
+// same key from its private key and the public key of prv, provided both
+// keys have the same parameters. It is meant for protocols which only need a
+// symmetric key, e.g. for an existing AEAD channel; the key is static, so
+// info should tell its uses apart.
+func DeriveKey(prv KeyProvider, peer *PublicKey, info []byte, length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, ErrInvalidParams
+	}
+	pub := prv.Public()
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	if peer == nil || peer.Curve != pub.Curve {
+		return nil, ErrInvalidCurve
+	}
+	if err := ValidatePublicKey(peer); err != nil {
+		return nil, err
+	}
+	z, err := prv.GenerateShared(peer)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(z)
+	return params.deriveKeys(z, info, length)
+}
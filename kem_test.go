@@ -0,0 +1,101 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure encapsulated keys are recovered on each kind of curve, and that
+// invalid encapsulations are rejected.
+func TestEncapsulate(t *testing.T) {
+	for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P521(), X25519()} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		key, enc, err := Encapsulate(rand.Reader, &prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if len(key) != prv.Params.Hash().Size() || len(enc) != pointSize(c, enc[0], AllowCompressedPoints) {
+			fmt.Println(name, "ecies: unexpected encapsulation sizes", len(key), len(enc))
+			t.FailNow()
+		}
+		shared, err := Decapsulate(prv, enc)
+		if err != nil || !bytes.Equal(shared, key) {
+			fmt.Println(name, "ecies: encapsulated key not recovered", err)
+			t.FailNow()
+		}
+		if _, err = Decapsulate(prv, enc[:len(enc)-1]); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: truncated encapsulation accepted", err)
+			t.FailNow()
+		}
+	}
+
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	_, enc, err := Encapsulate(rand.Reader, &prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	enc[len(enc)-1] ^= 1
+	if _, err = Decapsulate(prv, enc); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: point off the curve accepted", err)
+		t.FailNow()
+	}
+}
+
+// Ensure both sides of DeriveKey agree on the key, which depends on info.
+func TestDeriveKey(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), X25519()} {
+		alice, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		bob, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		k1, err := DeriveKey(alice, &bob.PublicKey, []byte("channel"), 32)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		k2, err := DeriveKey(bob, &alice.PublicKey, []byte("channel"), 32)
+		if err != nil || !bytes.Equal(k1, k2) || len(k1) != 32 {
+			fmt.Println("ecies: derived keys differ", err)
+			t.FailNow()
+		}
+		k3, err := DeriveKey(bob, &alice.PublicKey, []byte("other"), 32)
+		if err != nil || bytes.Equal(k1, k3) {
+			fmt.Println("ecies: derived key ignores info", err)
+			t.FailNow()
+		}
+	}
+	p384, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	p256, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err := DeriveKey(p256, &p384.PublicKey, nil, 32); err != ErrInvalidCurve {
+		fmt.Println("ecies: key derived across curves", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,145 @@
+package ecies
+
+// Keyrings: several private keys tried in turn, so that ciphertexts for the
+// old and new keys both decrypt while keys are rotated.
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+var ErrNoMatchingKey = newError(KindKey, "ecies: no key of the keyring decrypts the message")
+
+// Keyring holds the key providers of a service. It is safe for concurrent
+// use.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []KeyProvider
+}
+
+// NewKeyring returns a keyring holding keys, the newest last.
+func NewKeyring(keys ...KeyProvider) *Keyring {
+	kr := new(Keyring)
+	for _, prv := range keys {
+		kr.Add(prv)
+	}
+	return kr
+}
+
+// Add adds a key to the keyring, as its newest key. A key already in the
+// keyring becomes its newest key.
+func (kr *Keyring) Add(prv KeyProvider) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.remove(prv.Public())
+	kr.keys = append([]KeyProvider{prv}, kr.keys...)
+}
+
+// Remove removes the key of pub from the keyring, and reports whether it was
+// there.
+func (kr *Keyring) Remove(pub *PublicKey) bool {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return kr.remove(pub)
+}
+
+func (kr *Keyring) remove(pub *PublicKey) bool {
+	for i, prv := range kr.keys {
+		if prv.Public().Equal(pub) {
+			kr.keys = append(kr.keys[:i:i], kr.keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of keys of the keyring.
+func (kr *Keyring) Len() int {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return len(kr.keys)
+}
+
+// DecryptAny decrypts a ciphertext for any of the keys of the keyring, with
+// the default options.
+func (kr *Keyring) DecryptAny(c, s1, s2 []byte) ([]byte, error) {
+	return kr.DecryptAnyWithOptions(c, s1, s2, nil)
+}
+
+// DecryptAnyWithOptions decrypts a ciphertext produced by Encrypt, or by
+// EncryptToMany, for any of the keys of the keyring. The keys named by the
+// ciphertext are used directly: the recipients of EncryptToMany, to which
+// opts don't apply, and the recipient header expected with
+// DecryptOptions.RecipientHeader. Otherwise the keys whose curve and
+// parameters fit the ciphertext are tried in turn, the newest first, which
+// takes a key agreement per key tried. It returns ErrNoMatchingKey, wrapping
+// the last decryption error if any, when no key decrypts the ciphertext.
+func (kr *Keyring) DecryptAnyWithOptions(c, s1, s2 []byte, opts *DecryptOptions) ([]byte, error) {
+	kr.mu.RLock()
+	keys := append([]KeyProvider(nil), kr.keys...)
+	kr.mu.RUnlock()
+
+	if blocks, _, err := parseRecipients(c); err == nil {
+		for _, prv := range keys {
+			id := recipientID(prv.Public())
+			for _, b := range blocks {
+				if bytes.Equal(b.id, id) {
+					return DecryptFromMany(prv, c, s1, s2)
+				}
+			}
+		}
+	}
+
+	if opts != nil && opts.RecipientHeader {
+		fp, _, err := PeekRecipient(c)
+		if err != nil {
+			return nil, wrapError(ErrNoMatchingKey, err)
+		}
+		for _, prv := range keys {
+			if prv.Public().Fingerprint() == fp {
+				return DecryptWithOptions(prv, c, s1, s2, opts)
+			}
+		}
+		return nil, ErrNoMatchingKey
+	}
+
+	var last error
+	for _, prv := range keys {
+		if !fitsCiphertext(prv.Public(), c, opts) {
+			continue
+		}
+		m, err := DecryptWithOptions(prv, c, s1, s2, opts)
+		if err == nil || errors.Is(err, KindValidity) {
+			// Stale and replayed messages did authenticate with the key.
+			return m, err
+		}
+		last = err
+	}
+	if last == nil {
+		return nil, ErrNoMatchingKey
+	}
+	return nil, wrapError(ErrNoMatchingKey, last)
+}
+
+// fitsCiphertext tells whether c may be a ciphertext for pub, from the curve
+// and parameters of its envelope, or else the encoding of its ephemeral key.
+func fitsCiphertext(pub *PublicKey, c []byte, opts *DecryptOptions) bool {
+	params := pub.Params
+	if params == nil {
+		params = ParamsFromCurve(pub.Curve)
+	}
+	if params == nil {
+		return false
+	}
+	if isEnvelope(c) {
+		_, err := unwrapEnvelope(pub, params, c)
+		return err == nil
+	}
+	if opts != nil && (opts.Validity || opts.Timestamped) {
+		// The ephemeral key follows the headers.
+		return true
+	}
+	_, err := splitCiphertext(pub.Curve, params, c, AllowAllPoints)
+	return err == nil
+}
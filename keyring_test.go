@@ -0,0 +1,85 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure a keyring decrypts the messages for its old and new keys, in every
+// format, and only those.
+func TestKeyring(t *testing.T) {
+	var prvs []*PrivateKey
+	for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P256(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prvs = append(prvs, prv)
+	}
+	old, current, other := prvs[0], prvs[1], prvs[2]
+	kr := NewKeyring(old, current)
+	message := []byte("Hello, rotation.")
+
+	for _, prv := range []*PrivateKey{old, current} {
+		ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		env, err := EncodeEnvelope(&prv.PublicKey, ct)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		many, err := EncryptToMany(rand.Reader, []*PublicKey{&other.PublicKey, &prv.PublicKey}, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, c := range [][]byte{ct, env, many} {
+			pt, err := kr.DecryptAny(c, nil, nil)
+			if err != nil || !bytes.Equal(pt, message) {
+				fmt.Println("ecies: keyring message not decrypted", err)
+				t.FailNow()
+			}
+		}
+	}
+
+	ct, err := Encrypt(rand.Reader, &other.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = kr.DecryptAny(ct, nil, nil); !errors.Is(err, ErrNoMatchingKey) {
+		fmt.Println("ecies: message decrypted without its key", err)
+		t.FailNow()
+	}
+	kr.Add(other)
+	if _, err = kr.DecryptAny(ct, nil, nil); err != nil {
+		fmt.Println("ecies: message not decrypted with an added key", err)
+		t.FailNow()
+	}
+
+	ct, err = Encrypt(rand.Reader, &old.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !kr.Remove(&old.PublicKey) || kr.Remove(&old.PublicKey) || kr.Len() != 2 {
+		fmt.Println("ecies: keyring key not removed")
+		t.FailNow()
+	}
+	if _, err = kr.DecryptAny(ct, nil, nil); !errors.Is(err, ErrNoMatchingKey) || !errors.Is(err, ErrInvalidMessage) {
+		fmt.Println("ecies: message decrypted with a removed key", err)
+		t.FailNow()
+	}
+	if _, err = NewKeyring().DecryptAny(ct, nil, nil); err != ErrNoMatchingKey {
+		fmt.Println("ecies: message decrypted by an empty keyring", err)
+		t.FailNow()
+	}
+}
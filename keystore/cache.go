@@ -0,0 +1,158 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+var ErrLocked = fmt.Errorf("keystore: keys are locked")
+
+// Cache holds the keys of a passphrase protected KeyStore unlocked in memory,
+// so that they are not unsealed from the file on every use. The keys are
+// locked again after being idle for the TTL, or on an explicit Lock, after
+// which Unlock must be called with the passphrase again.
+//
+// The providers returned by a Cache remain valid across lock cycles: they
+// fail with ErrLocked while the cache is locked.
+type Cache struct {
+	path string
+	ttl  time.Duration
+
+	mu       sync.RWMutex
+	keys     map[string]*ecies.PrivateKey
+	timer    *time.Timer
+	lastUsed int64 // UnixNano, atomic
+}
+
+// NewCache returns a locked cache for the keystore at path. A TTL of zero
+// keeps the keys unlocked until Lock is called.
+func NewCache(path string, ttl time.Duration) *Cache {
+	return &Cache{path: path, ttl: ttl}
+}
+
+// Unlock reads the keystore and unseals all its keys with the passphrase.
+// The cache is left untouched if any key fails to unseal.
+func (c *Cache) Unlock(passphrase []byte) error {
+	ks, err := Open(c.path, NewPassphraseKey(passphrase, nil))
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*ecies.PrivateKey)
+	for _, id := range ks.IDs() {
+		prv, err := ks.unseal(id)
+		if err != nil {
+			for _, prv := range keys {
+				wipeKey(prv)
+			}
+			return err
+		}
+		keys[id] = prv
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lockLocked()
+	c.keys = keys
+	c.touch()
+	if c.ttl > 0 {
+		c.timer = time.AfterFunc(c.ttl, c.expire)
+	}
+	return nil
+}
+
+// Lock drops the unlocked keys.
+func (c *Cache) Lock() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lockLocked()
+}
+
+// Locked tells whether Unlock must be called before the keys can be used.
+func (c *Cache) Locked() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keys == nil
+}
+
+func (c *Cache) lockLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	for _, prv := range c.keys {
+		wipeKey(prv)
+	}
+	c.keys = nil
+}
+
+func (c *Cache) touch() {
+	atomic.StoreInt64(&c.lastUsed, time.Now().UnixNano())
+}
+
+// expire locks the cache if it has been idle for the TTL, or checks again
+// when it would be.
+func (c *Cache) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		return
+	}
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&c.lastUsed)))
+	if idle >= c.ttl {
+		c.lockLocked()
+		return
+	}
+	c.timer = time.AfterFunc(c.ttl-idle, c.expire)
+}
+
+// Provider returns a KeyProvider for the key stored as id. The cache must be
+// unlocked.
+func (c *Cache) Provider(id string) (ecies.KeyProvider, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.keys == nil {
+		return nil, ErrLocked
+	}
+	prv, ok := c.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	pub := prv.PublicKey
+	return &cachedKey{c: c, id: id, pub: &pub}, nil
+}
+
+type cachedKey struct {
+	c   *Cache
+	id  string
+	pub *ecies.PublicKey
+}
+
+func (k *cachedKey) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+func (k *cachedKey) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	k.c.mu.RLock()
+	defer k.c.mu.RUnlock()
+	if k.c.keys == nil {
+		return nil, ErrLocked
+	}
+	prv, ok := k.c.keys[k.id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	k.c.touch()
+	return prv.GenerateShared(pub)
+}
+
+// wipeKey clears the private scalar in place.
+func wipeKey(prv *ecies.PrivateKey) {
+	prv.Wipe()
+}
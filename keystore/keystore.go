@@ -0,0 +1,264 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+// Package keystore keeps ECIES private keys in a file, encrypted at rest under
+// a master key. Keys are only decrypted for the duration of a key agreement,
+// and plaintext keys are never written to disk.
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+var (
+	ErrNotFound         = fmt.Errorf("keystore: key not found")
+	ErrExists           = fmt.Errorf("keystore: key already exists")
+	ErrInvalidMasterKey = fmt.Errorf("keystore: invalid master key or corrupted key")
+	ErrInvalidFormat    = fmt.Errorf("keystore: invalid keystore file")
+)
+
+const fileVersion = 1
+
+type entry struct {
+	Public []byte `json:"public"`
+	Sealed []byte `json:"sealed"`
+}
+
+type file struct {
+	Version int               `json:"version"`
+	Keys    map[string]*entry `json:"keys"`
+}
+
+// KeyStore is a file of private keys, each sealed under the master key. The
+// file is replaced atomically on every change, and guarded by an advisory
+// lock on a sibling ".lock" file, so that several processes can share it.
+type KeyStore struct {
+	path   string
+	master MasterKey
+
+	mu   sync.RWMutex
+	keys map[string]*entry
+}
+
+// Open loads the keystore at path, which does not need to exist yet.
+func Open(path string, master MasterKey) (*KeyStore, error) {
+	ks := &KeyStore{path: path, master: master}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload reads the keystore file again, picking up changes made by other
+// processes.
+func (ks *KeyStore) Reload() error {
+	var keys map[string]*entry
+	err := ks.withLock(false, func() (err error) {
+		keys, err = ks.read()
+		return
+	})
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// IDs returns the sorted identifiers of the stored keys.
+func (ks *KeyStore) IDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Public returns the public key stored under id.
+func (ks *KeyStore) Public(id string) (*ecies.PublicKey, error) {
+	ks.mu.RLock()
+	e, ok := ks.keys[id]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ecies.UnmarshalPublic(e.Public)
+}
+
+// Add seals prv under the master key and stores it as id.
+func (ks *KeyStore) Add(id string, prv *ecies.PrivateKey) error {
+	pub, err := ecies.MarshalPublic(&prv.PublicKey)
+	if err != nil {
+		return err
+	}
+	der, err := ecies.MarshalPrivate(prv)
+	if err != nil {
+		return err
+	}
+	sealed, err := ks.master.Seal(der, additionalData(id))
+	wipe(der)
+	if err != nil {
+		return err
+	}
+
+	return ks.update(func(keys map[string]*entry) error {
+		if _, ok := keys[id]; ok {
+			return ErrExists
+		}
+		keys[id] = &entry{Public: pub, Sealed: sealed}
+		return nil
+	})
+}
+
+// Delete removes the key stored as id.
+func (ks *KeyStore) Delete(id string) error {
+	return ks.update(func(keys map[string]*entry) error {
+		if _, ok := keys[id]; !ok {
+			return ErrNotFound
+		}
+		delete(keys, id)
+		return nil
+	})
+}
+
+// Provider returns a KeyProvider for the key stored as id. The private key
+// is unsealed on every key agreement, and dropped right after.
+func (ks *KeyStore) Provider(id string) (ecies.KeyProvider, error) {
+	pub, err := ks.Public(id)
+	if err != nil {
+		return nil, err
+	}
+	return &storedKey{ks: ks, id: id, pub: pub}, nil
+}
+
+// unseal returns the private key stored as id.
+func (ks *KeyStore) unseal(id string) (*ecies.PrivateKey, error) {
+	ks.mu.RLock()
+	e, ok := ks.keys[id]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	der, err := ks.master.Open(e.Sealed, additionalData(id))
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(der)
+	return ecies.UnmarshalPrivate(der)
+}
+
+type storedKey struct {
+	ks  *KeyStore
+	id  string
+	pub *ecies.PublicKey
+}
+
+func (k *storedKey) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+func (k *storedKey) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	prv, err := k.ks.unseal(k.id)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeKey(prv)
+	return prv.GenerateShared(pub)
+}
+
+// update applies fn to the keys read from disk under an exclusive lock, and
+// saves the result.
+func (ks *KeyStore) update(fn func(keys map[string]*entry) error) error {
+	return ks.withLock(true, func() error {
+		keys, err := ks.read()
+		if err != nil {
+			return err
+		}
+		if err = fn(keys); err != nil {
+			return err
+		}
+		if err = ks.write(keys); err != nil {
+			return err
+		}
+		ks.mu.Lock()
+		ks.keys = keys
+		ks.mu.Unlock()
+		return nil
+	})
+}
+
+func (ks *KeyStore) read() (map[string]*entry, error) {
+	data, err := os.ReadFile(ks.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]*entry), nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f file
+	if err = json.Unmarshal(data, &f); err != nil || f.Version != fileVersion {
+		return nil, ErrInvalidFormat
+	}
+	if f.Keys == nil {
+		f.Keys = make(map[string]*entry)
+	}
+	return f.Keys, nil
+}
+
+// write replaces the keystore file atomically, with a synced temporary file
+// renamed over it.
+func (ks *KeyStore) write(keys map[string]*entry) error {
+	data, err := json.MarshalIndent(&file{Version: fileVersion, Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), filepath.Base(ks.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ks.path)
+}
+
+func (ks *KeyStore) withLock(exclusive bool, fn func() error) error {
+	f, err := os.OpenFile(ks.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Closing the lock file releases the lock.
+	if err = lockFile(f, exclusive); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func additionalData(id string) []byte {
+	return []byte("ecies-keystore:" + id)
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
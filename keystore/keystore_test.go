@@ -0,0 +1,297 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// Cheap KDF parameters, to keep the tests fast.
+var testArgon2idParams = &ecies.Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+
+func roundTrip(t *testing.T, kp ecies.KeyProvider) error {
+	message := []byte("Hello, world.")
+	ct, err := ecies.Encrypt(rand.Reader, kp.Public(), message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pt, err := ecies.Decrypt(kp, ct, nil, nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pt, message) {
+		fmt.Println("keystore: plaintext mismatch")
+		t.FailNow()
+	}
+	return nil
+}
+
+// Ensure keys are sealed on disk, and usable after reopening the keystore.
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := Open(path, NewPassphraseKey([]byte("secret"), testArgon2idParams))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = ks.Add("device", prv); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = ks.Add("device", prv); err != ErrExists {
+		fmt.Println("keystore: overwrote an existing key", err)
+		t.FailNow()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	der, _ := ecies.MarshalPrivate(prv)
+	if bytes.Contains(data, prv.D.Bytes()) || bytes.Contains(data, der) {
+		fmt.Println("keystore: plaintext key written to disk")
+		t.FailNow()
+	}
+
+	ks, err = Open(path, NewPassphraseKey([]byte("secret"), nil))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if ids := ks.IDs(); len(ids) != 1 || ids[0] != "device" {
+		fmt.Println("keystore: unexpected key IDs", ids)
+		t.FailNow()
+	}
+	kp, err := ks.Provider("device")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = roundTrip(t, kp); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	ks, err = Open(path, NewPassphraseKey([]byte("wrong"), nil))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	kp, _ = ks.Provider("device")
+	if err = roundTrip(t, kp); err != ErrInvalidMasterKey {
+		fmt.Println("keystore: used a key with the wrong passphrase", err)
+		t.FailNow()
+	}
+
+	if err = ks.Delete("device"); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = ks.Provider("device"); err != ErrNotFound {
+		fmt.Println("keystore: deleted key still present", err)
+		t.FailNow()
+	}
+}
+
+// Ensure sealed keys can't request unbounded Argon2id parameters.
+func TestPassphraseKeyBounds(t *testing.T) {
+	key := NewPassphraseKey([]byte("passphrase"), testArgon2idParams)
+	sealed, err := key.Seal([]byte("secret"), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := NewPassphraseKey([]byte("passphrase"), nil).Open(sealed, nil); err != nil || string(pt) != "secret" {
+		fmt.Println("keystore: sealed key not opened", err)
+		t.FailNow()
+	}
+	for _, tweak := range []func(h []byte){
+		func(h []byte) { binary.BigEndian.PutUint32(h[1:5], maxArgon2idTime+1) },
+		func(h []byte) { binary.BigEndian.PutUint32(h[5:9], 1<<31) },
+		func(h []byte) { h[9] = 0 },
+	} {
+		crafted := append([]byte(nil), sealed...)
+		tweak(crafted)
+		if _, err = NewPassphraseKey([]byte("passphrase"), nil).Open(crafted, nil); err != ErrInvalidMasterKey {
+			fmt.Println("keystore: out of bounds Argon2id parameters accepted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure keys can be sealed to a key provider.
+func TestProviderKey(t *testing.T) {
+	master, err := ecies.GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ks, err := Open(filepath.Join(t.TempDir(), "keys.json"), &ProviderKey{Provider: master})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = ks.Add("device", prv); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	kp, err := ks.Provider("device")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = roundTrip(t, kp); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+}
+
+// Ensure the cache locks on request and after being idle for the TTL.
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := Open(path, NewPassphraseKey([]byte("secret"), testArgon2idParams))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = ks.Add("device", prv); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	cache := NewCache(path, 50*time.Millisecond)
+	if _, err = cache.Provider("device"); err != ErrLocked {
+		fmt.Println("keystore: new cache is not locked", err)
+		t.FailNow()
+	}
+	if err = cache.Unlock([]byte("wrong")); err != ErrInvalidMasterKey || !cache.Locked() {
+		fmt.Println("keystore: unlocked with the wrong passphrase", err)
+		t.FailNow()
+	}
+	if err = cache.Unlock([]byte("secret")); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	kp, err := cache.Provider("device")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = roundTrip(t, kp); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	cache.Lock()
+	if err = roundTrip(t, kp); err != ErrLocked {
+		fmt.Println("keystore: used a key after Lock", err)
+		t.FailNow()
+	}
+	if err = cache.Unlock([]byte("secret")); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = roundTrip(t, kp); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if !cache.Locked() {
+		fmt.Println("keystore: cache not locked after the TTL")
+		t.FailNow()
+	}
+	if err = roundTrip(t, kp); err != ErrLocked {
+		fmt.Println("keystore: used a key after the TTL", err)
+		t.FailNow()
+	}
+}
+
+func writeKey(t *testing.T, path string, prv *ecies.PrivateKey) {
+	out, err := ecies.ExportPrivatePEM(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = os.WriteFile(path+".new", out, 0600); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = os.Rename(path+".new", path); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+}
+
+// Ensure the watcher swaps in rotated keys.
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	prv1, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv2, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	writeKey(t, path, prv1)
+
+	w, err := NewWatcher(path, 10*time.Millisecond, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	defer w.Close()
+	if w.Public().X.Cmp(prv1.X) != 0 {
+		fmt.Println("keystore: watcher loaded the wrong key")
+		t.FailNow()
+	}
+	if err = roundTrip(t, w.Current()); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	writeKey(t, path, prv2)
+	for i := 0; w.Public().X.Cmp(prv2.X) != 0; i++ {
+		if i == 200 {
+			fmt.Println("keystore: watcher did not pick up the rotated key")
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = roundTrip(t, w.Current()); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !ecies_tiny && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !ecies_tiny,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package keystore
+
+import "os"
+
+// Platforms without advisory file locks only get the in-process locking.
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
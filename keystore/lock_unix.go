@@ -0,0 +1,23 @@
+//go:build !ecies_tiny && (darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+// +build !ecies_tiny
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package keystore
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,158 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/foundriesio/go-ecies"
+	"golang.org/x/crypto/argon2"
+)
+
+// MasterKey seals the private keys of a KeyStore. The additional data binds
+// each sealed key to its entry in the store.
+//
+// PassphraseKey derives the master key from a passphrase, and ProviderKey
+// delegates to an ecies.KeyProvider, so that the master key can live in a
+// KMS or a TPM.
+type MasterKey interface {
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	Open(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+const (
+	passphraseKeyVersion = 1
+	passphraseSaltSize   = 16
+	// version, time, memory, threads and salt.
+	passphraseHeaderSize = 1 + 4 + 4 + 1 + passphraseSaltSize
+)
+
+// PassphraseKey is a MasterKey derived from a passphrase with Argon2id, and
+// used with AES-256-GCM. The KDF parameters and salt are stored with every
+// sealed key, and the derived keys are cached.
+type PassphraseKey struct {
+	passphrase []byte
+	params     ecies.Argon2idParams
+
+	mu     sync.Mutex
+	header []byte
+	keys   map[string]cipher.AEAD
+}
+
+// NewPassphraseKey returns a master key for the passphrase. If params is nil,
+// ecies.DefaultArgon2idParams are used for sealing.
+func NewPassphraseKey(passphrase []byte, params *ecies.Argon2idParams) *PassphraseKey {
+	if params == nil {
+		params = ecies.DefaultArgon2idParams
+	}
+	return &PassphraseKey{
+		passphrase: append([]byte(nil), passphrase...),
+		params:     *params,
+		keys:       make(map[string]cipher.AEAD),
+	}
+}
+
+func (k *PassphraseKey) aead(header []byte) (cipher.AEAD, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if aead, ok := k.keys[string(header)]; ok {
+		return aead, nil
+	}
+	passes := binary.BigEndian.Uint32(header[1:5])
+	memory := binary.BigEndian.Uint32(header[5:9])
+	threads := header[9]
+	if passes == 0 || threads == 0 || passes > maxArgon2idTime || memory > maxArgon2idMemory {
+		return nil, ErrInvalidMasterKey
+	}
+	key := argon2.IDKey(k.passphrase, header[10:], passes, memory, threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[string(header)] = aead
+	return aead, nil
+}
+
+// Seal encrypts plaintext under the key derived with the configured
+// parameters and a salt picked on first use.
+func (k *PassphraseKey) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	k.mu.Lock()
+	if k.header == nil {
+		header := make([]byte, passphraseHeaderSize)
+		header[0] = passphraseKeyVersion
+		binary.BigEndian.PutUint32(header[1:5], k.params.Time)
+		binary.BigEndian.PutUint32(header[5:9], k.params.Memory)
+		header[9] = k.params.Threads
+		if _, err := io.ReadFull(rand.Reader, header[10:]); err != nil {
+			k.mu.Unlock()
+			return nil, err
+		}
+		k.header = header
+	}
+	header := k.header
+	k.mu.Unlock()
+
+	aead, err := k.aead(header)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, passphraseHeaderSize+aead.NonceSize(), passphraseHeaderSize+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	if _, err := io.ReadFull(rand.Reader, out[passphraseHeaderSize:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[passphraseHeaderSize:], plaintext, additionalData), nil
+}
+
+// Open decrypts a sealed key, returning ErrInvalidMasterKey if the passphrase
+// is wrong or the ciphertext was tampered with.
+func (k *PassphraseKey) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < passphraseHeaderSize || ciphertext[0] != passphraseKeyVersion {
+		return nil, ErrInvalidMasterKey
+	}
+	aead, err := k.aead(ciphertext[:passphraseHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+	body := ciphertext[passphraseHeaderSize:]
+	if len(body) < aead.NonceSize() {
+		return nil, ErrInvalidMasterKey
+	}
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrInvalidMasterKey
+	}
+	return plaintext, nil
+}
+
+// ProviderKey is a MasterKey sealing with ECIES to the public key of a key
+// provider, e.g. one backed by a KMS or a TPM, which is needed to open.
+type ProviderKey struct {
+	Provider ecies.KeyProvider
+}
+
+// Seal encrypts plaintext to the public key of the provider, using the
+// additional data as the MAC shared information.
+func (k *ProviderKey) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	return ecies.Encrypt(rand.Reader, k.Provider.Public(), plaintext, nil, additionalData)
+}
+
+// Open decrypts a sealed key with the provider.
+func (k *ProviderKey) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	plaintext, err := ecies.Decrypt(k.Provider, ciphertext, nil, additionalData)
+	if err != nil {
+		return nil, ErrInvalidMasterKey
+	}
+	return plaintext, nil
+}
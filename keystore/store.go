@@ -0,0 +1,557 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+// Store keeps each private key in its own JSON key file, encrypted under a
+// passphrase of its own, in the manner of the Ethereum keystore v3 files:
+// the file carries the public key, the KDF and its parameters, and the
+// AES-256-GCM encrypted private key, so that it can be moved or backed up on
+// its own. Rotating a key adds a version, and keeps the previous ones for the
+// messages already encrypted to them.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrInvalidID         = fmt.Errorf("keystore: invalid key identifier")
+	ErrInvalidPassphrase = fmt.Errorf("keystore: invalid passphrase or corrupted key file")
+)
+
+// The KDFs of the key files.
+const (
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+const (
+	keyFileVersion = 1
+	keyFileCipher  = "aes-256-gcm"
+	keyFileSuffix  = ".json"
+	saltSize       = 16
+)
+
+// Bounds of the KDF parameters, which key files and the keys sealed by a
+// PassphraseKey carry, so that a crafted file can't make Load or Open exhaust
+// the memory or time of the process: 1 GiB of memory for both KDFs.
+const (
+	maxArgon2idTime   = 16
+	maxArgon2idMemory = 1 << 20 // KiB
+	maxScryptMemory   = 1 << 30 // 128·N·r bytes
+	maxScryptP        = 16
+)
+
+// StoreOptions select how the key files of a Store are encrypted. They only
+// apply to new key files: existing ones carry their own parameters.
+type StoreOptions struct {
+	// KDF is KDFArgon2id, the default, or KDFScrypt.
+	KDF string
+	// Argon2id defaults to ecies.DefaultArgon2idParams.
+	Argon2id *ecies.Argon2idParams
+	// Scrypt defaults to ecies.DefaultScryptParams.
+	Scrypt *ecies.ScryptParams
+}
+
+// Store is a directory of key files, named after the identifier and version
+// of their key.
+type Store struct {
+	dir  string
+	opts StoreOptions
+
+	// mu serializes the rotations of the process; new versions are created
+	// without replacing existing files, so concurrent processes can't
+	// overwrite each other's keys.
+	mu sync.Mutex
+}
+
+// KeyInfo describes a key file, as read without its passphrase.
+type KeyInfo struct {
+	ID      string
+	Version int
+	Created time.Time
+	Public  *ecies.PublicKey
+}
+
+type keyFile struct {
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	KeyVersion int       `json:"key_version"`
+	Created    time.Time `json:"created"`
+	Curve      string    `json:"curve"`
+	Public     []byte    `json:"public"`
+	Crypto     keyCrypto `json:"crypto"`
+}
+
+type keyCrypto struct {
+	Cipher     string    `json:"cipher"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	KDF        string    `json:"kdf"`
+	KDFParams  kdfParams `json:"kdfparams"`
+}
+
+type kdfParams struct {
+	Salt []byte `json:"salt"`
+	// Argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// NewStore returns the store of the key files in dir, which is created if
+// needed. If opts is nil, the default options are used.
+func NewStore(dir string, opts *StoreOptions) (*Store, error) {
+	s := &Store{dir: dir}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.KDF == "" {
+		s.opts.KDF = KDFArgon2id
+	}
+	if s.opts.KDF != KDFArgon2id && s.opts.KDF != KDFScrypt {
+		return nil, fmt.Errorf("keystore: unsupported KDF %q", s.opts.KDF)
+	}
+	if s.opts.Argon2id == nil {
+		s.opts.Argon2id = ecies.DefaultArgon2idParams
+	}
+	if s.opts.Scrypt == nil {
+		s.opts.Scrypt = ecies.DefaultScryptParams
+	}
+	params := s.newKDFParams()
+	if err := params.check(s.opts.KDF); err != nil {
+		return nil, fmt.Errorf("keystore: %s parameters out of bounds", s.opts.KDF)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create generates a key on curve, with the default parameters of the curve,
+// and stores it as the first version of id, encrypted under passphrase.
+func (s *Store) Create(id string, curve elliptic.Curve, passphrase []byte) (*ecies.PublicKey, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	prv, err := ecies.GenerateKey(rand.Reader, curve, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeKey(prv)
+	if err = s.write(id, 1, prv, passphrase); err != nil {
+		return nil, err
+	}
+	return &prv.PublicKey, nil
+}
+
+// Import stores prv as the first version of id, encrypted under passphrase.
+func (s *Store) Import(id string, prv *ecies.PrivateKey, passphrase []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	return s.write(id, 1, prv, passphrase)
+}
+
+// Rotate generates a new key on the curve and with the parameters of the
+// latest version of id, and stores it as the next version, under the same
+// passphrase. The previous versions are kept, for the messages encrypted to
+// them.
+func (s *Store) Rotate(id string, passphrase []byte) (*ecies.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, err := s.latest(id)
+	if err != nil {
+		return nil, err
+	}
+	// Check the passphrase before sealing another key under it.
+	current, err := s.LoadVersion(id, version, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeKey(current)
+	prv, err := ecies.GenerateKey(rand.Reader, current.Curve, current.Params)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeKey(prv)
+	if err = s.write(id, version+1, prv, passphrase); err != nil {
+		return nil, err
+	}
+	return &prv.PublicKey, nil
+}
+
+// Load decrypts the latest version of id. It returns ErrInvalidPassphrase if
+// the passphrase is wrong or the file was tampered with.
+func (s *Store) Load(id string, passphrase []byte) (*ecies.PrivateKey, error) {
+	version, err := s.latest(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadVersion(id, version, passphrase)
+}
+
+// LoadVersion decrypts the given version of id.
+func (s *Store) LoadVersion(id string, version int, passphrase []byte) (*ecies.PrivateKey, error) {
+	kf, err := s.read(id, version)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return kf.open(aead)
+}
+
+// List returns the keys of the store, sorted by identifier and version.
+func (s *Store) List() ([]KeyInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var infos []KeyInfo
+	for _, e := range entries {
+		id, version, ok := parseFileName(e.Name())
+		if !ok {
+			continue
+		}
+		kf, err := s.read(id, version)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := kf.public()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, KeyInfo{ID: id, Version: version, Created: kf.Created, Public: pub})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ID != infos[j].ID {
+			return infos[i].ID < infos[j].ID
+		}
+		return infos[i].Version < infos[j].Version
+	})
+	return infos, nil
+}
+
+// Provider returns a KeyProvider for the latest version of id. The passphrase
+// is checked, and the key it derives kept, but the private key is decrypted
+// on every key agreement, and dropped right after.
+func (s *Store) Provider(id string, passphrase []byte) (ecies.KeyProvider, error) {
+	version, err := s.latest(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.provider(id, version, passphrase)
+}
+
+// Keyring returns a keyring of all the versions of id, the latest first, for
+// the decryption of the messages encrypted to any of them.
+func (s *Store) Keyring(id string, passphrase []byte) (*ecies.Keyring, error) {
+	versions, err := s.versions(id)
+	if err != nil {
+		return nil, err
+	}
+	// NewKeyring takes the newest key last.
+	keys := make([]ecies.KeyProvider, 0, len(versions))
+	for _, version := range versions {
+		kp, err := s.provider(id, version, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, kp)
+	}
+	return ecies.NewKeyring(keys...), nil
+}
+
+func (s *Store) provider(id string, version int, passphrase []byte) (ecies.KeyProvider, error) {
+	kf, err := s.read(id, version)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	prv, err := kf.open(aead)
+	if err != nil {
+		return nil, err
+	}
+	pub := &ecies.PublicKey{X: prv.X, Y: prv.Y, Curve: prv.Curve, Params: prv.Params}
+	wipeKey(prv)
+	return &fileKey{file: kf, aead: aead, pub: pub}, nil
+}
+
+type fileKey struct {
+	file *keyFile
+	aead cipher.AEAD
+	pub  *ecies.PublicKey
+}
+
+func (k *fileKey) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+func (k *fileKey) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	prv, err := k.file.open(k.aead)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeKey(prv)
+	return prv.GenerateShared(pub)
+}
+
+// write encrypts prv under passphrase into the key file of the version of
+// id, which must not exist yet.
+func (s *Store) write(id string, version int, prv *ecies.PrivateKey, passphrase []byte) error {
+	pub, err := prv.PublicKey.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	kf := &keyFile{
+		Version:    keyFileVersion,
+		ID:         id,
+		KeyVersion: version,
+		Created:    time.Now().UTC().Truncate(time.Second),
+		Curve:      prv.Curve.Params().Name,
+		Public:     pub,
+		Crypto:     keyCrypto{Cipher: keyFileCipher, KDF: s.opts.KDF},
+	}
+	params := &kf.Crypto.KDFParams
+	*params = s.newKDFParams()
+	params.Salt = make([]byte, saltSize)
+	if _, err = io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return err
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return err
+	}
+	der, err := prv.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	defer wipe(der)
+	kf.Crypto.Nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, kf.Crypto.Nonce); err != nil {
+		return err
+	}
+	kf.Crypto.Ciphertext = aead.Seal(nil, kf.Crypto.Nonce, der, kf.additionalData())
+
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-"+id)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// Unlike a rename, a link fails rather than replace an existing file.
+	if err = os.Link(tmp.Name(), s.path(id, version)); errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	return err
+}
+
+func (s *Store) read(id string, version int) (*keyFile, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(id, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err = json.Unmarshal(data, &kf); err != nil || kf.Version != keyFileVersion ||
+		kf.ID != id || kf.KeyVersion != version || kf.Crypto.Cipher != keyFileCipher {
+		return nil, ErrInvalidFormat
+	}
+	return &kf, nil
+}
+
+// versions returns the versions of id, in increasing order.
+func (s *Store) versions(id string) ([]int, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, e := range entries {
+		if fid, version, ok := parseFileName(e.Name()); ok && fid == id {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+func (s *Store) latest(id string) (int, error) {
+	versions, err := s.versions(id)
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+func (s *Store) path(id string, version int) string {
+	return filepath.Join(s.dir, id+".v"+strconv.Itoa(version)+keyFileSuffix)
+}
+
+// parseFileName returns the identifier and version of a key file name.
+func parseFileName(name string) (id string, version int, ok bool) {
+	base, found := strings.CutSuffix(name, keyFileSuffix)
+	if !found {
+		return "", 0, false
+	}
+	i := strings.LastIndex(base, ".v")
+	if i < 0 {
+		return "", 0, false
+	}
+	id = base[:i]
+	version, err := strconv.Atoi(base[i+2:])
+	if err != nil || version < 1 || checkID(id) != nil {
+		return "", 0, false
+	}
+	return id, version, true
+}
+
+// checkID accepts the identifiers made of ASCII letters, digits, '-' and '_',
+// which are safe in file names.
+func checkID(id string) error {
+	if id == "" || len(id) > 128 {
+		return ErrInvalidID
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return ErrInvalidID
+		}
+	}
+	return nil
+}
+
+// newKDFParams returns the KDF parameters of new key files, without salt.
+func (s *Store) newKDFParams() kdfParams {
+	if s.opts.KDF == KDFArgon2id {
+		return kdfParams{Time: s.opts.Argon2id.Time, Memory: s.opts.Argon2id.Memory, Threads: s.opts.Argon2id.Threads}
+	}
+	return kdfParams{N: s.opts.Scrypt.N, R: s.opts.Scrypt.R, P: s.opts.Scrypt.P}
+}
+
+// check checks that the parameters of kdf are within bounds.
+func (p *kdfParams) check(kdf string) error {
+	switch kdf {
+	case KDFArgon2id:
+		if p.Time == 0 || p.Threads == 0 || p.Time > maxArgon2idTime || p.Memory > maxArgon2idMemory {
+			return ErrInvalidFormat
+		}
+	case KDFScrypt:
+		// scrypt.Key checks that N is a power of two greater than 1.
+		if p.R <= 0 || p.P <= 0 || p.P > maxScryptP || p.N > maxScryptMemory/128/p.R {
+			return ErrInvalidFormat
+		}
+	}
+	return nil
+}
+
+// aead returns the cipher keyed with the passphrase, through the KDF of the
+// key file.
+func (kf *keyFile) aead(passphrase []byte) (cipher.AEAD, error) {
+	params := kf.Crypto.KDFParams
+	if len(params.Salt) < saltSize || params.check(kf.Crypto.KDF) != nil {
+		return nil, ErrInvalidFormat
+	}
+	var key []byte
+	switch kf.Crypto.KDF {
+	case KDFArgon2id:
+		key = argon2.IDKey(passphrase, params.Salt, params.Time, params.Memory, params.Threads, 32)
+	case KDFScrypt:
+		var err error
+		if key, err = scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, 32); err != nil {
+			return nil, ErrInvalidFormat
+		}
+	default:
+		return nil, ErrInvalidFormat
+	}
+	defer wipe(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// open decrypts the private key of the key file.
+func (kf *keyFile) open(aead cipher.AEAD) (*ecies.PrivateKey, error) {
+	if len(kf.Crypto.Nonce) != aead.NonceSize() {
+		return nil, ErrInvalidFormat
+	}
+	der, err := aead.Open(nil, kf.Crypto.Nonce, kf.Crypto.Ciphertext, kf.additionalData())
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	defer wipe(der)
+	prv := new(ecies.PrivateKey)
+	if err = prv.UnmarshalBinary(der); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	return prv, nil
+}
+
+func (kf *keyFile) public() (*ecies.PublicKey, error) {
+	pub := new(ecies.PublicKey)
+	if err := pub.UnmarshalBinary(kf.Public); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	return pub, nil
+}
+
+// additionalData binds the encrypted key to the identifier, version and
+// public key of its file.
+func (kf *keyFile) additionalData() []byte {
+	var version [4]byte
+	binary.BigEndian.PutUint32(version[:], uint32(kf.KeyVersion))
+	ad := append([]byte("ecies-keystore-file:"+kf.ID+"\x00"), version[:]...)
+	return append(ad, kf.Public...)
+}
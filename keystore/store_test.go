@@ -0,0 +1,173 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// Ensure key files are encrypted, load with their passphrase only, and keep
+// their previous versions across rotations.
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	for _, kdf := range []string{KDFArgon2id, KDFScrypt} {
+		s, err := NewStore(dir, &StoreOptions{
+			KDF:      kdf,
+			Argon2id: testArgon2idParams,
+			Scrypt:   &ecies.ScryptParams{N: 16, R: 1, P: 1},
+		})
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		id := "device-" + kdf
+		pub, err := s.Create(id, elliptic.P256(), []byte("secret"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err = s.Create(id, elliptic.P256(), []byte("secret")); err != ErrExists {
+			fmt.Println("keystore: key file overwritten", err)
+			t.FailNow()
+		}
+		data, err := os.ReadFile(filepath.Join(dir, id+".v1.json"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if !bytes.Contains(data, []byte(`"kdf": "`+kdf+`"`)) {
+			fmt.Println("keystore: unexpected key file", string(data))
+			t.FailNow()
+		}
+
+		if _, err = s.Load(id, []byte("wrong")); err != ErrInvalidPassphrase {
+			fmt.Println("keystore: key loaded with the wrong passphrase", err)
+			t.FailNow()
+		}
+		prv, err := s.Load(id, []byte("secret"))
+		if err != nil || !prv.PublicKey.Equal(pub) {
+			fmt.Println("keystore: key not loaded", err)
+			t.FailNow()
+		}
+		old, err := ecies.Encrypt(rand.Reader, pub, []byte("old"), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		if _, err = s.Rotate(id, []byte("wrong")); err != ErrInvalidPassphrase {
+			fmt.Println("keystore: key rotated with the wrong passphrase", err)
+			t.FailNow()
+		}
+		rotated, err := s.Rotate(id, []byte("secret"))
+		if err != nil || rotated.Equal(pub) {
+			fmt.Println("keystore: key not rotated", err)
+			t.FailNow()
+		}
+		kp, err := s.Provider(id, []byte("secret"))
+		if err != nil || !kp.Public().Equal(rotated) {
+			fmt.Println("keystore: provider not of the latest version", err)
+			t.FailNow()
+		}
+		if err = roundTrip(t, kp); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ring, err := s.Keyring(id, []byte("secret"))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if m, err := ring.DecryptAny(old, nil, nil); err != nil || string(m) != "old" {
+			fmt.Println("keystore: message to a previous version not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	s, err := NewStore(dir, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	infos, err := s.List()
+	if err != nil || len(infos) != 4 || infos[0].ID != "device-argon2id" || infos[1].Version != 2 ||
+		infos[3].ID != "device-scrypt" || infos[3].Public == nil {
+		fmt.Println("keystore: unexpected key list", infos, err)
+		t.FailNow()
+	}
+	if _, err = s.Create("../escape", elliptic.P256(), []byte("secret")); err != ErrInvalidID {
+		fmt.Println("keystore: invalid identifier accepted", err)
+		t.FailNow()
+	}
+	x25519, err := ecies.GenerateKey(rand.Reader, ecies.X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = s.Import("x25519", x25519, []byte("secret")); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if prv, err := s.Load("x25519", []byte("secret")); err != nil || !prv.Equal(x25519) {
+		fmt.Println("keystore: imported X25519 key not loaded", err)
+		t.FailNow()
+	}
+}
+
+// Ensure the KDF parameters of key files and store options are bounded, so
+// that a crafted file can't exhaust the memory of Load.
+func TestStoreKDFBounds(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir, &StoreOptions{Argon2id: testArgon2idParams})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = s.Create("device", elliptic.P256(), []byte("secret")); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	path := filepath.Join(dir, "device.v1.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, crafted := range []string{
+		`"memory": 4294967295`,
+		`"time": 4294967295`,
+	} {
+		field := crafted[:strings.Index(crafted, ":")]
+		re := regexp.MustCompile(field + `: \d+`)
+		if err = os.WriteFile(path, re.ReplaceAll(data, []byte(crafted)), 0600); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err = s.Load("device", []byte("secret")); err != ErrInvalidFormat {
+			fmt.Println("keystore: loaded a key file with", crafted, err)
+			t.FailNow()
+		}
+	}
+
+	for _, opts := range []*StoreOptions{
+		{Argon2id: &ecies.Argon2idParams{Time: 1, Memory: 1 << 24, Threads: 1}},
+		{KDF: KDFScrypt, Scrypt: &ecies.ScryptParams{N: 1 << 24, R: 8, P: 1}},
+		{KDF: KDFScrypt, Scrypt: &ecies.ScryptParams{N: 16, R: 1, P: 1 << 20}},
+	} {
+		if _, err = NewStore(dir, opts); err == nil {
+			fmt.Println("keystore: store created with unbounded parameters")
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package keystore
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// LoadFunc loads the key provider kept in a file. To follow a KeyStore file,
+// use a function opening it and returning the Provider of a given key.
+type LoadFunc func(path string) (ecies.KeyProvider, error)
+
+// LoadPEM loads a PEM-encoded private key file.
+func LoadPEM(path string) (ecies.KeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ecies.ImportPrivatePEM(data)
+}
+
+// Watcher follows a key file, polling it for changes, and swaps the active
+// key provider when the file is rotated, so long-running services pick up new
+// keys without a restart. Rotations should replace the file atomically, e.g.
+// with a rename.
+//
+// Watcher is itself a KeyProvider delegating to the active provider. As a
+// rotation can happen between two calls, use Current to get a consistent
+// provider for a whole decryption.
+type Watcher struct {
+	path     string
+	load     LoadFunc
+	interval time.Duration
+
+	active  atomic.Value // activeKey
+	lastErr atomic.Value // loadError
+	info    os.FileInfo
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewWatcher loads the key file at path, and starts polling it every
+// interval. If load is nil, LoadPEM is used.
+func NewWatcher(path string, interval time.Duration, load LoadFunc) (*Watcher, error) {
+	if load == nil {
+		load = LoadPEM
+	}
+	w := &Watcher{path: path, load: load, interval: interval, stop: make(chan struct{})}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	go w.poll()
+	return w, nil
+}
+
+// atomic.Value needs a consistent concrete type.
+type activeKey struct {
+	ecies.KeyProvider
+}
+
+// Current returns the active key provider.
+func (w *Watcher) Current() ecies.KeyProvider {
+	return w.active.Load().(activeKey).KeyProvider
+}
+
+type loadError struct {
+	err error
+}
+
+// Err returns the error of the last attempt to load a changed key file, if
+// it failed. The previous provider stays active in that case.
+func (w *Watcher) Err() error {
+	if e, ok := w.lastErr.Load().(loadError); ok {
+		return e.err
+	}
+	return nil
+}
+
+// Public returns the public key of the active provider.
+func (w *Watcher) Public() *ecies.PublicKey {
+	return w.Current().Public()
+}
+
+// GenerateShared runs the key agreement with the active provider.
+func (w *Watcher) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	return w.Current().GenerateShared(pub)
+}
+
+// Close stops polling the key file.
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *Watcher) poll() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		if !w.changed() {
+			continue
+		}
+		w.lastErr.Store(loadError{w.reload()})
+	}
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(info, w.info) || !info.ModTime().Equal(w.info.ModTime()) || info.Size() != w.info.Size()
+}
+
+func (w *Watcher) reload() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	// Don't retry the same broken file on every tick.
+	w.info = info
+	kp, err := w.load(w.path)
+	if err != nil {
+		return err
+	}
+	w.active.Store(activeKey{kp})
+	return nil
+}
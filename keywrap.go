@@ -0,0 +1,157 @@
+package ecies
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+)
+
+var ErrInvalidKeyLength = newError(KindParams, "ecies: wrapped keys must be a multiple of 8 bytes, and at least 16 bytes long")
+
+// keyWrapLabel is the KDF shared information of the key encryption keys of
+// WrapKey, separating them from the keys of Encrypt and Encapsulate.
+var keyWrapLabel = []byte("ecies-keywrap-v1")
+
+// The default initial value of the AES Key Wrap, see RFC 3394 section 2.2.3.1.
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps key, a multiple of 64 bits of at least 128 bits, with the
+// AES Key Wrap algorithm of RFC 3394 under kek.
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, ErrInvalidMessage
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, keyWrapIV)
+	copy(out[8:], key)
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap unwraps a key wrapped by aesKeyWrap, checking its integrity.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, ErrInvalidMessage
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped)
+	key := make([]byte, len(wrapped)-8)
+	copy(key, wrapped[8:])
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], key[8*(i-1):])
+			block.Decrypt(b[:], b[:])
+			copy(a, b[:8])
+			copy(key[8*(i-1):], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, keyWrapIV) != 1 {
+		return nil, ErrInvalidMessage
+	}
+	return key, nil
+}
+
+// keyWrapParams returns the parameters of the key, which must have an AES key
+// length.
+func keyWrapParams(pub *PublicKey) (*ECIESParams, error) {
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	switch params.KeyLen {
+	case 16, 24, 32:
+		return params, nil
+	}
+	return nil, ErrUnsupportedECIESParameters
+}
+
+// WrapKey protects a symmetric key, such as the data encryption key of a
+// database or an object store, for the owner of pub: a key encryption key is
+// derived with the KEM of ECIES, as in Encapsulate, and wraps the key with the
+// AES Key Wrap of RFC 3394. The AES key size is the key length of the
+// parameters of pub. The key must be a multiple of 8 bytes, of at least 16
+// bytes, or ErrInvalidKeyLength is returned.
+//
+// The result is the ephemeral public key followed by the wrapped key, 8 bytes
+// longer than the key.
+func WrapKey(rand io.Reader, pub *PublicKey, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, ErrInvalidKeyLength
+	}
+	params, err := keyWrapParams(pub)
+	if err != nil {
+		return nil, err
+	}
+	z, enc, err := encapsulate(rand, pub, params, false)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := params.deriveKeys(params.kdfInput(enc, z), keyWrapLabel, params.KeyLen)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := aesKeyWrap(kek, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(enc, wrapped...), nil
+}
+
+// UnwrapKey recovers a key wrapped by WrapKey. It returns ErrInvalidMessage
+// if the blob was altered or wrapped for another key.
+func UnwrapKey(prv KeyProvider, blob []byte) ([]byte, error) {
+	pub := prv.Public()
+	params, err := keyWrapParams(pub)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 {
+		return nil, ErrInvalidMessage
+	}
+	n := pointSize(pub.Curve, blob[0], AllowCompressedPoints)
+	if n == 0 || len(blob) < n {
+		return nil, ErrInvalidPublicKey
+	}
+	R, err := parseEncapsulation(pub, blob[:n], AllowCompressedPoints)
+	if err != nil {
+		return nil, err
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := params.deriveKeys(params.kdfInput(blob[:n], z), keyWrapLabel, params.KeyLen)
+	if err != nil {
+		return nil, err
+	}
+	return aesKeyUnwrap(kek, blob[n:])
+}
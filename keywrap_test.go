@@ -0,0 +1,83 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Validate the AES Key Wrap against RFC 3394 section 4.
+func TestAESKeyWrap(t *testing.T) {
+	for _, v := range []struct{ kek, key, wrapped string }{
+		{"000102030405060708090A0B0C0D0E0F", "00112233445566778899AABBCCDDEEFF",
+			"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5"},
+		{"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F", "00112233445566778899AABBCCDDEEFF0001020304050607",
+			"A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1"},
+	} {
+		kek, _ := hex.DecodeString(v.kek)
+		key, _ := hex.DecodeString(v.key)
+		expected, _ := hex.DecodeString(v.wrapped)
+		wrapped, err := aesKeyWrap(kek, key)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !bytes.Equal(wrapped, expected) {
+			fmt.Println("ecies: AES key wrap doesn't match")
+			t.FailNow()
+		}
+		unwrapped, err := aesKeyUnwrap(kek, wrapped)
+		if err != nil || !bytes.Equal(unwrapped, key) {
+			fmt.Println("ecies: AES key unwrap failed", err)
+			t.FailNow()
+		}
+		wrapped[0] ^= 1
+		if _, err = aesKeyUnwrap(kek, wrapped); err != ErrInvalidMessage {
+			fmt.Println("ecies: corrupted wrapped key accepted")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that wrapped keys unwrap with the recipient key only, for each AES
+// key size.
+func TestWrapKey(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		other, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		dek := make([]byte, 32)
+		rand.Read(dek)
+		blob, err := WrapKey(rand.Reader, &prv.PublicKey, dek)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if key, err := UnwrapKey(prv, blob); err != nil || !bytes.Equal(key, dek) {
+			fmt.Println("ecies: key not unwrapped", err)
+			t.FailNow()
+		}
+		if _, err = UnwrapKey(other, blob); err != ErrInvalidMessage {
+			fmt.Println("ecies: key unwrapped by another key", err)
+			t.FailNow()
+		}
+		blob[len(blob)-1] ^= 1
+		if _, err = UnwrapKey(prv, blob); err != ErrInvalidMessage {
+			fmt.Println("ecies: corrupted key unwrapped", err)
+			t.FailNow()
+		}
+		if _, err = WrapKey(rand.Reader, &prv.PublicKey, dek[:20]); err != ErrInvalidKeyLength {
+			fmt.Println("ecies: wrapped a key of 20 bytes", err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ecies
+
+import (
+	"sync"
+)
+
+var ErrLockedMemoryUnsupported = newError(KindUnsupported, "ecies: locked memory is not supported on this platform")
+
+// LockedBuffer is memory outside of the Go heap, locked into RAM so that it
+// is never swapped to disk, for holding secrets such as seeds or messages.
+// It must be destroyed once done, which wipes it.
+type LockedBuffer struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// NewLockedBuffer allocates and locks size bytes. Memory locking is
+// typically limited per process, see RLIMIT_MEMLOCK.
+func NewLockedBuffer(size int) (*LockedBuffer, error) {
+	if size < 1 {
+		return nil, ErrInvalidParams
+	}
+	b, err := lockedAlloc(size)
+	if err != nil {
+		return nil, err
+	}
+	return &LockedBuffer{b: b}, nil
+}
+
+// Bytes returns the memory of the buffer, or nil once it is destroyed.
+func (lb *LockedBuffer) Bytes() []byte {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b
+}
+
+// Destroy wipes, unlocks and frees the buffer. The slices returned by Bytes
+// must not be used afterwards.
+func (lb *LockedBuffer) Destroy() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.b == nil {
+		return
+	}
+	wipe(lb.b)
+	lockedFree(lb.b)
+	lb.b = nil
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ecies
+
+// Platforms without mlock can't keep secrets out of swap.
+const lockedMemorySupported = false
+
+func lockedAlloc(size int) ([]byte, error) {
+	return nil, ErrLockedMemoryUnsupported
+}
+
+func lockedFree(b []byte) {}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ecies
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const lockedMemorySupported = true
+
+func lockedAlloc(size int) ([]byte, error) {
+	b, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("ecies: allocating locked memory: %w", err)
+	}
+	if err = syscall.Mlock(b); err != nil {
+		syscall.Munmap(b)
+		return nil, fmt.Errorf("ecies: locking memory: %w", err)
+	}
+	return b, nil
+}
+
+func lockedFree(b []byte) {
+	syscall.Munlock(b)
+	syscall.Munmap(b)
+}
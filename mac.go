@@ -0,0 +1,164 @@
+package ecies
+
+// Message authentication codes for the tag of the CTR mode: HMAC or CMAC as
+// per SEC 1, or KMAC as per NIST SP 800-185.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// macAlgorithm identifies the MAC of the standard parameter sets.
+type macAlgorithm int
+
+const (
+	macHMAC macAlgorithm = iota
+	macKMAC128
+	macKMAC256
+	macCMAC
+	// macHMACRaw is HMAC keyed with the derived MAC key as is, as in
+	// ISO 18033-2 and IEEE 1363a.
+	macHMACRaw
+)
+
+// macKey returns the MAC key for the derived keying material km. It is
+// hashed, as in go-ethereum, except for CMAC whose key is an AES key and for
+// the HMAC of ISO 18033-2.
+func macKey(params *ECIESParams, km []byte) []byte {
+	if params.mac == macCMAC || params.mac == macHMACRaw {
+		return km
+	}
+	hash := getHash(params.Hash)
+	defer putHash(params.Hash, hash)
+	hash.Write(km)
+	return hash.Sum(nil)
+}
+
+// Output sizes of KMAC128 and KMAC256, for 128 and 256 bits of security.
+const (
+	kmac128Size = 32
+	kmac256Size = 64
+)
+
+// macSize returns the size of the tag.
+func (params *ECIESParams) macSize() int {
+	switch params.mac {
+	case macKMAC128:
+		return kmac128Size
+	case macKMAC256:
+		return kmac256Size
+	case macCMAC:
+		return aes.BlockSize
+	}
+	return params.hashSize()
+}
+
+// messageTag computes the MAC of a message (called the tag) as per SEC 1, 3.5.
+func messageTag(params *ECIESParams, km, msg, shared []byte) []byte {
+	if params.MACLabelLength {
+		shared = binary.BigEndian.AppendUint64(append([]byte{}, shared...), uint64(len(shared))*8)
+	}
+	switch params.mac {
+	case macKMAC128:
+		return kmac(sha3.NewCShake128([]byte("KMAC"), nil), 168, kmac128Size, km, msg, shared)
+	case macKMAC256:
+		return kmac(sha3.NewCShake256([]byte("KMAC"), nil), 136, kmac256Size, km, msg, shared)
+	case macCMAC:
+		block, err := aes.NewCipher(km)
+		if err != nil {
+			return nil
+		}
+		return cmac(block, append(append([]byte{}, msg...), shared...))
+	}
+	return hmacSum(params.Hash, km, msg, shared)
+}
+
+// kmac computes KMAC (SP 800-185 section 4) with an empty customization
+// string over the concatenation of data, given the cSHAKE instance with the
+// "KMAC" function name and its rate.
+func kmac(h sha3.ShakeHash, rate, size int, key []byte, data ...[]byte) []byte {
+	// bytepad(encode_string(key), rate)
+	pad := leftEncode(uint64(rate))
+	pad = append(pad, leftEncode(uint64(len(key))*8)...)
+	pad = append(pad, key...)
+	if n := len(pad) % rate; n != 0 {
+		pad = append(pad, make([]byte, rate-n)...)
+	}
+	h.Write(pad)
+	for _, b := range data {
+		h.Write(b)
+	}
+	h.Write(rightEncode(uint64(size) * 8))
+	tag := make([]byte, size)
+	h.Read(tag)
+	return tag
+}
+
+// leftEncode and rightEncode are the integer encodings of SP 800-185
+// section 2.3.1: the big-endian bytes of x, at least one, with their count
+// prepended or appended.
+func leftEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append([]byte{byte(len(b))}, b...)
+}
+
+func rightEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append(b, byte(len(b)))
+}
+
+func encodeUint(x uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], x)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	return b[i:]
+}
+
+// cmac computes CMAC (NIST SP 800-38B, RFC 4493) of m.
+func cmac(block cipher.Block, m []byte) []byte {
+	bs := block.BlockSize()
+	k1 := make([]byte, bs)
+	block.Encrypt(k1, k1)
+	cmacDouble(k1)
+	k2 := append([]byte{}, k1...)
+	cmacDouble(k2)
+
+	// The last block is complete and xored with K1, or padded and xored
+	// with K2.
+	n := (len(m) + bs - 1) / bs
+	last := make([]byte, bs)
+	if n > 0 && len(m)%bs == 0 {
+		subtle.XORBytes(last, m[(n-1)*bs:], k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		copy(last, m[(n-1)*bs:])
+		last[len(m)-(n-1)*bs] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x, x, m[i*bs:(i+1)*bs])
+		block.Encrypt(x, x)
+	}
+	subtle.XORBytes(x, x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// cmacDouble multiplies b by x in GF(2^128).
+func cmacDouble(b []byte) {
+	msb := b[0] >> 7
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] = b[len(b)-1]<<1 ^ byte(subtle.ConstantTimeSelect(int(msb), 0x87, 0))
+}
@@ -0,0 +1,153 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Ensure KMAC matches the NIST SP 800-185 samples 1 and 4.
+func TestKMAC(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	key := hexBytes("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+	data := []byte{0, 1, 2, 3}
+	tag := kmac(sha3.NewCShake128([]byte("KMAC"), nil), 168, 32, key, data)
+	if !bytes.Equal(tag, hexBytes("e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e")) {
+		fmt.Println("ecies: unexpected KMAC128 output")
+		t.FailNow()
+	}
+	tag = kmac(sha3.NewCShake256([]byte("KMAC"), []byte("My Tagged Application")), 136, 64, key, data[:2], data[2:])
+	if !bytes.Equal(tag, hexBytes("20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7"+
+		"f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd")) {
+		fmt.Println("ecies: unexpected KMAC256 output")
+		t.FailNow()
+	}
+}
+
+// Ensure messages round trip with the KMAC parameters, which survive a DER
+// round trip of the public key.
+func TestKMACParams(t *testing.T) {
+	for c, params := range map[elliptic.Curve]*ECIESParams{
+		elliptic.P256(): ECIES_AES128_KMAC128_SHA3_256,
+		elliptic.P521(): ECIES_AES256_KMAC256_SHA3_512,
+	} {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, params)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublic(der)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		want, _ := suiteID(params)
+		if id, ok := suiteID(pub.Params); !ok || id != want {
+			fmt.Println(name, "ecies: KMAC parameters lost in DER", id)
+			t.FailNow()
+		}
+		message := []byte("Hello, world.")
+		ct, err := Encrypt(rand.Reader, pub, message, nil, []byte("s2"))
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if len(ct) != len(CompressPublicKey(pub))*2-1+16+len(message)+params.macSize() {
+			fmt.Println(name, "ecies: unexpected KMAC ciphertext size", len(ct))
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, []byte("s2")); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(name, "ecies: KMAC message not decrypted", err)
+			t.FailNow()
+		}
+		if _, err = Decrypt(prv, ct, nil, []byte("s3")); err != ErrInvalidMessage {
+			fmt.Println(name, "ecies: KMAC shared information not authenticated", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure CMAC matches RFC 4493 section 4, and the CMAC parameters decrypt an
+// independently computed ciphertext and survive a DER round trip.
+func TestCMAC(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	block, _ := aes.NewCipher(hexBytes("2b7e151628aed2a6abf7158809cf4f3c"))
+	for m, tag := range map[string]string{
+		"":                                 "bb1d6929e95937287fa37d129b756746",
+		"6bc1bee22e409f96e93d7e117393172a": "070a16b46b4d4144f79bdd9dd04a287c",
+		"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411": "dfa66747de9ae63030ca32611497c827",
+	} {
+		if !bytes.Equal(cmac(block, hexBytes(m)), hexBytes(tag)) {
+			fmt.Println("ecies: unexpected CMAC output for", m)
+			t.FailNow()
+		}
+	}
+
+	prv, err := NewPrivateKey(elliptic.P256(), hexBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_AES128_CMAC_SHA256
+	ct := hexBytes("04e266ddfdc12668db30d4ca3e8f7749432c416044f2d2b8c10bf3d4012aeffa8abfa86404a2e9ffe67d47c587ef7a97a7f456b863b4d02cfc6928973ab5b1cb39" +
+		"000102030405060708090a0b0c0d0e0f0904a1f094eb9e52ad5281f4" +
+		"0506f3a98a3f2f2a9ee47425c093549d")
+	pt, err := Decrypt(prv, ct, []byte("s1"), []byte("s2"))
+	if err != nil || string(pt) != "Hello, CMAC." {
+		fmt.Println("ecies: CMAC vector not decrypted", err)
+		t.FailNow()
+	}
+
+	for _, params := range []*ECIESParams{ECIES_AES128_CMAC_SHA256, ECIES_AES192_CMAC_SHA384, ECIES_AES256_CMAC_SHA512} {
+		want, _ := suiteID(params)
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublic(der)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		if id, ok := suiteID(pub.Params); !ok || id != want {
+			fmt.Println(want, "ecies: CMAC parameters lost in DER", id)
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, pub, []byte("Hello, world."), nil, nil)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || string(pt) != "Hello, world." {
+			fmt.Println(want, "ecies: CMAC message not decrypted", err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// The encoding.BinaryMarshaler and encoding.TextMarshaler interfaces, so that
+// keys can be stored with encoding/json, encoding/gob or database/sql
+// drivers. Keys are encoded in the DER format of MarshalPublic and
+// MarshalPrivate, which carries their ECIES parameters, and as PEM blocks of
+// that format for text. X25519 and X448 keys, which that format can't carry,
+// are encoded in the standard PKIX and PKCS #8 formats instead, and decoded
+// with the default parameters.
+
+import "encoding/pem"
+
+// MarshalBinary encodes the public key in DER format.
+func (pub *PublicKey) MarshalBinary() ([]byte, error) {
+	if montgomeryCurve(pub.Curve) {
+		return MarshalPublicPKIX(pub)
+	}
+	return MarshalPublic(pub)
+}
+
+// UnmarshalBinary decodes a public key encoded by MarshalBinary, or in any
+// of the DER formats read by UnmarshalPublic.
+func (pub *PublicKey) UnmarshalBinary(data []byte) error {
+	decoded, err := UnmarshalPublic(data)
+	if err != nil {
+		return err
+	}
+	*pub = *decoded
+	return nil
+}
+
+// MarshalText encodes the public key as a PEM block.
+func (pub *PublicKey) MarshalText() ([]byte, error) {
+	der, err := pub.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	typ := "ELLIPTIC CURVE PUBLIC KEY"
+	if montgomeryCurve(pub.Curve) {
+		typ = "PUBLIC KEY"
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), nil
+}
+
+// UnmarshalText decodes a public key encoded by MarshalText, or any PEM
+// block read by ImportPublicPEM.
+func (pub *PublicKey) UnmarshalText(text []byte) error {
+	decoded, err := ImportPublicPEM(text)
+	if err != nil {
+		return err
+	}
+	*pub = *decoded
+	return nil
+}
+
+// MarshalBinary encodes the private key in DER format, unencrypted.
+func (prv *PrivateKey) MarshalBinary() ([]byte, error) {
+	if montgomeryCurve(prv.Curve) {
+		return MarshalPrivatePKCS8(prv)
+	}
+	return MarshalPrivate(prv)
+}
+
+// UnmarshalBinary decodes a private key encoded by MarshalBinary, or in any
+// of the DER formats read by UnmarshalPrivate.
+func (prv *PrivateKey) UnmarshalBinary(data []byte) error {
+	decoded, err := UnmarshalPrivate(data)
+	if err != nil {
+		var err2 error
+		if decoded, err2 = UnmarshalPrivatePKCS8(data); err2 != nil {
+			return err
+		}
+	}
+	*prv = *decoded
+	return nil
+}
+
+// MarshalText encodes the private key as an unencrypted PEM block.
+func (prv *PrivateKey) MarshalText() ([]byte, error) {
+	der, err := prv.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(der)
+	typ := "ELLIPTIC CURVE PRIVATE KEY"
+	if montgomeryCurve(prv.Curve) {
+		typ = "PRIVATE KEY"
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), nil
+}
+
+// UnmarshalText decodes a private key encoded by MarshalText, or any
+// unencrypted PEM block read by ImportPrivatePEM.
+func (prv *PrivateKey) UnmarshalText(text []byte) error {
+	decoded, err := ImportPrivatePEM(text)
+	if err != nil {
+		return err
+	}
+	*prv = *decoded
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// Ensure that keys round trip through encoding/json and encoding/gob.
+func TestKeyMarshalers(t *testing.T) {
+	type config struct {
+		Public  *PublicKey
+		Private *PrivateKey
+	}
+	for _, curve := range []elliptic.Curve{elliptic.P256(), Secp256k1(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		in := config{&prv.PublicKey, prv}
+
+		data, err := json.Marshal(in)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		var fromJSON config
+		if err = json.Unmarshal(data, &fromJSON); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		var buf bytes.Buffer
+		if err = gob.NewEncoder(&buf).Encode(in); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		var fromGob config
+		if err = gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		want, _ := suiteID(prv.Params)
+		for _, out := range []config{fromJSON, fromGob} {
+			if !out.Public.Equal(in.Public) || !out.Private.Equal(in.Private) {
+				fmt.Println("ecies: key round trip failed")
+				t.FailNow()
+			}
+			id1, ok1 := suiteID(out.Public.Params)
+			id2, ok2 := suiteID(out.Private.Params)
+			if !ok1 || !ok2 || id1 != want || id2 != want {
+				fmt.Println("ecies: key parameters lost")
+				t.FailNow()
+			}
+		}
+	}
+
+	var pub PublicKey
+	if err := pub.UnmarshalText([]byte("not a key")); err == nil {
+		fmt.Println("ecies: decoded an invalid key")
+		t.FailNow()
+	}
+	var prv PrivateKey
+	if err := prv.UnmarshalBinary([]byte{0x30, 0}); err == nil {
+		fmt.Println("ecies: decoded an invalid key")
+		t.FailNow()
+	}
+}
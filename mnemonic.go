@@ -0,0 +1,127 @@
+package ecies
+
+// Paper backups of private keys as BIP39 mnemonics: the private scalar is the
+// entropy of the mnemonic, followed by the first ENT/32 bits of its SHA-256
+// hash as a checksum, and written as 11-bit indices into the English word
+// list. 32-byte scalars give the 24 words of standard BIP39; larger scalars,
+// padded with leading zeros to a multiple of 4 bytes, give longer lists of the
+// same construction.
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidMnemonic = newError(KindEncoding, "ecies: invalid mnemonic")
+
+var (
+	bip39Once  sync.Once
+	bip39Words []string
+	bip39Index map[string]int
+)
+
+func initBIP39() {
+	bip39Words = strings.Fields(bip39English)
+	bip39Index = make(map[string]int, len(bip39Words))
+	for i, w := range bip39Words {
+		bip39Index[w] = i
+	}
+}
+
+// mnemonicSizes returns the size of the private keys on curve, and of the
+// entropy of their mnemonics: the size of the keys, rounded up to 4 bytes.
+func mnemonicSizes(curve elliptic.Curve) (keySize, entropySize int) {
+	keySize = (curve.Params().N.BitLen() + 7) / 8
+	if c, ok := curve.(rawPointCurve); ok {
+		keySize = c.pointLen()
+	}
+	return keySize, (keySize + 3) &^ 3
+}
+
+// ExportMnemonic returns the private key as a BIP39 mnemonic, words separated
+// by spaces. The mnemonic encodes the key itself, not a BIP39 seed: it is
+// restored with ImportMnemonic, on the same curve, and not with the BIP32
+// wallets. It must be kept as secret as the key.
+func ExportMnemonic(prv *PrivateKey) (string, error) {
+	if prv == nil || prv.D == nil || prv.Curve == nil {
+		return "", ErrInvalidPrivateKey
+	}
+	keySize, size := mnemonicSizes(prv.Curve)
+	if prv.D.BitLen() > 8*keySize {
+		return "", ErrInvalidPrivateKey
+	}
+	bip39Once.Do(initBIP39)
+	entropy := prv.D.FillBytes(make([]byte, size))
+	defer wipe(entropy)
+	sum := sha256.Sum256(entropy)
+	defer wipe(sum[:])
+
+	// The entropy and checksum bits, read 11 at a time.
+	bits := 8*size + 8*size/32
+	bit := func(i int) int {
+		if i < 8*size {
+			return int(entropy[i/8]>>(7-i%8)) & 1
+		}
+		i -= 8 * size
+		return int(sum[i/8]>>(7-i%8)) & 1
+	}
+	words := make([]string, 0, bits/11)
+	for i := 0; i < bits; i += 11 {
+		index := 0
+		for j := 0; j < 11; j++ {
+			index = index<<1 | bit(i+j)
+		}
+		words = append(words, bip39Words[index])
+	}
+	return strings.Join(words, " "), nil
+}
+
+// ImportMnemonic restores a private key on curve from the mnemonic written by
+// ExportMnemonic. Words are separated by white space, in any case. Unknown
+// words, a wrong number of words for the curve and bad checksums are
+// rejected with ErrInvalidMnemonic.
+func ImportMnemonic(mnemonic string, curve elliptic.Curve) (*PrivateKey, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	bip39Once.Do(initBIP39)
+	keySize, size := mnemonicSizes(curve)
+	bits := 8*size + 8*size/32
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != bits/11 {
+		return nil, ErrInvalidMnemonic
+	}
+
+	data := make([]byte, (bits+7)/8)
+	defer wipe(data)
+	for i, w := range words {
+		index, ok := bip39Index[w]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		for j := 0; j < 11; j++ {
+			if index>>(10-j)&1 == 1 {
+				k := 11*i + j
+				data[k/8] |= 0x80 >> (k % 8)
+			}
+		}
+	}
+	entropy := data[:size]
+	sum := sha256.Sum256(entropy)
+	defer wipe(sum[:])
+	for k := 0; k < 8*size/32; k++ {
+		i := 8*size + k
+		if data[i/8]>>(7-i%8)&1 != sum[k/8]>>(7-k%8)&1 {
+			return nil, ErrInvalidMnemonic
+		}
+	}
+
+	for _, b := range entropy[:size-keySize] {
+		if b != 0 {
+			return nil, ErrInvalidPrivateKey
+		}
+	}
+	return NewPrivateKey(curve, entropy[size-keySize:])
+}
@@ -0,0 +1,79 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure mnemonics match the BIP39 test vectors, and restore the keys of each
+// curve.
+func TestMnemonic(t *testing.T) {
+	sum := sha256.Sum256([]byte(bip39English))
+	if hex.EncodeToString(sum[:]) != "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda" {
+		fmt.Println("ecies: corrupted BIP39 word list")
+		t.FailNow()
+	}
+
+	for _, v := range []struct{ entropy, words string }{
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title"},
+		{"8080808080808080808080808080808080808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless"},
+	} {
+		d, _ := hex.DecodeString(v.entropy)
+		prv, err := NewPrivateKey(elliptic.P256(), d)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		words, err := ExportMnemonic(prv)
+		if err != nil || words != v.words {
+			fmt.Println("ecies: unexpected mnemonic", words, err)
+			t.FailNow()
+		}
+		restored, err := ImportMnemonic(strings.ToUpper(v.words)+"\n", elliptic.P256())
+		if err != nil || !restored.Equal(prv) {
+			fmt.Println("ecies: mnemonic not restored", err)
+			t.FailNow()
+		}
+	}
+
+	for _, curve := range []elliptic.Curve{elliptic.P384(), elliptic.P521(), Secp256k1(), X25519(), X448()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		words, err := ExportMnemonic(prv)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		restored, err := ImportMnemonic(words, curve)
+		if err != nil || !restored.Equal(prv) {
+			fmt.Println("ecies: mnemonic not restored", curve.Params().Name, err)
+			t.FailNow()
+		}
+		list := strings.Fields(words)
+		list[0], list[1] = list[1], list[0]
+		if list[0] != list[1] {
+			if _, err = ImportMnemonic(strings.Join(list, " "), curve); err != ErrInvalidMnemonic {
+				fmt.Println("ecies: swapped words not detected", curve.Params().Name, err)
+				t.FailNow()
+			}
+		}
+		if _, err = ImportMnemonic(words+" abandon", curve); err != ErrInvalidMnemonic {
+			fmt.Println("ecies: extra word accepted", err)
+			t.FailNow()
+		}
+	}
+	if _, err := ImportMnemonic(strings.Repeat("abandon ", 23)+"bitcoin", elliptic.P256()); err != ErrInvalidMnemonic {
+		fmt.Println("ecies: unknown word accepted", err)
+		t.FailNow()
+	}
+}
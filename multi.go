@@ -0,0 +1,153 @@
+package ecies
+
+// Multi-recipient encryption: the message is encrypted once under a random
+// content key, which is wrapped with ECIES for each recipient.
+//
+// The ciphertext is the 16-bit big-endian number of recipients, then for
+// each of them the first 8 bytes of the SHA-256 fingerprint of its public
+// key and the 16-bit big-endian length of the wrapped content key followed
+// by it, then the AES-256-GCM nonce and sealed message. The recipient blocks
+// are authenticated as additional data along with s2.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+var (
+	ErrNoRecipients = newError(KindParams, "ecies: no or too many recipients")
+	ErrNotRecipient = newError(KindKey, "ecies: not a recipient of the message")
+)
+
+const (
+	maxRecipients    = 1<<16 - 1
+	recipientIDSize  = 8
+	contentKeySize   = 32
+	maxWrappedKeyLen = 1<<16 - 1
+)
+
+// recipientID identifies the recipient blocks meant for pub.
+func recipientID(pub *PublicKey) []byte {
+	return keyFingerprint(pub)[:recipientIDSize]
+}
+
+// EncryptToMany encrypts a message once for several recipients, which may
+// use different curves and parameters. Each recipient can decrypt it with
+// DecryptFromMany. The shared information s1 is used to wrap the content key
+// for each recipient, and s2 is authenticated along with the message.
+//
+// The ciphertext discloses the fingerprints of the recipients' keys.
+func EncryptToMany(rand io.Reader, pubs []*PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	if len(pubs) == 0 || len(pubs) > maxRecipients {
+		return nil, ErrNoRecipients
+	}
+	key := make([]byte, contentKeySize)
+	if _, err := io.ReadFull(rand, key); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 2)
+	binary.BigEndian.PutUint16(header, uint16(len(pubs)))
+	for _, pub := range pubs {
+		wrapped, err := Encrypt(rand, pub, key, s1, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(wrapped) > maxWrappedKeyLen {
+			return nil, ErrUnsupportedECIESParameters
+		}
+		header = append(header, recipientID(pub)...)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+		header = append(header, wrapped...)
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(m)+aead.Overhead())
+	copy(out, header)
+	if _, err = io.ReadFull(rand, out[len(header):]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[len(header):], m, append(header, s2...)), nil
+}
+
+// recipientBlock is the wrapped content key of a recipient.
+type recipientBlock struct {
+	id, wrapped []byte
+}
+
+// parseRecipients returns the recipient blocks of a ciphertext produced by
+// EncryptToMany, and the size of the header they make up.
+func parseRecipients(c []byte) ([]recipientBlock, int, error) {
+	if len(c) < 2 {
+		return nil, 0, ErrInvalidMessage
+	}
+	n := int(binary.BigEndian.Uint16(c))
+	if n == 0 {
+		return nil, 0, ErrInvalidMessage
+	}
+	blocks := make([]recipientBlock, 0, n)
+	off := 2
+	for i := 0; i < n; i++ {
+		if len(c)-off < recipientIDSize+2 {
+			return nil, 0, ErrInvalidMessage
+		}
+		id := c[off : off+recipientIDSize]
+		size := int(binary.BigEndian.Uint16(c[off+recipientIDSize:]))
+		off += recipientIDSize + 2
+		if len(c)-off < size {
+			return nil, 0, ErrInvalidMessage
+		}
+		blocks = append(blocks, recipientBlock{id, c[off : off+size]})
+		off += size
+	}
+	return blocks, off, nil
+}
+
+// DecryptFromMany decrypts a ciphertext produced by EncryptToMany, using the
+// recipient block matching the public key of prv.
+func DecryptFromMany(prv KeyProvider, c, s1, s2 []byte) ([]byte, error) {
+	blocks, off, err := parseRecipients(c)
+	if err != nil {
+		return nil, err
+	}
+	id := recipientID(prv.Public())
+	var wrapped [][]byte
+	for _, b := range blocks {
+		if bytes.Equal(b.id, id) {
+			wrapped = append(wrapped, b.wrapped)
+		}
+	}
+	header := c[:off]
+	if len(wrapped) == 0 {
+		return nil, ErrNotRecipient
+	}
+
+	var key []byte
+	for _, w := range wrapped {
+		if key, err = Decrypt(prv, w, s1, nil); err == nil && len(key) == contentKeySize {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != contentKeySize {
+		return nil, ErrInvalidMessage
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := c[off:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidMessage
+	}
+	ad := append(append([]byte{}, header...), s2...)
+	m, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
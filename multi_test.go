@@ -0,0 +1,63 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// Ensure each recipient decrypts a multi-recipient message, and others don't.
+func TestEncryptToMany(t *testing.T) {
+	var prvs []*PrivateKey
+	var pubs []*PublicKey
+	for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prvs = append(prvs, prv)
+		pubs = append(pubs, &prv.PublicKey)
+	}
+	message := []byte("Hello, everyone.")
+	ct, err := EncryptToMany(rand.Reader, pubs, message, []byte("s1"), []byte("s2"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, prv := range prvs {
+		pt, err := DecryptFromMany(prv, ct, []byte("s1"), []byte("s2"))
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(prv.Curve.Params().Name, "ecies: multi-recipient message not decrypted", err)
+			t.FailNow()
+		}
+		if _, err = DecryptFromMany(prv, ct, []byte("s1"), []byte("s3")); err != ErrInvalidMessage {
+			fmt.Println("ecies: multi-recipient additional data not authenticated", err)
+			t.FailNow()
+		}
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = DecryptFromMany(other, ct, []byte("s1"), []byte("s2")); err != ErrNotRecipient {
+		fmt.Println("ecies: message decrypted by a non-recipient", err)
+		t.FailNow()
+	}
+	// Dropping the first recipient breaks the authentication of the rest.
+	size := 2 + recipientIDSize + 2 + int(binary.BigEndian.Uint16(ct[2+recipientIDSize:]))
+	stripped := append([]byte{0, 2}, ct[size:]...)
+	if _, err = DecryptFromMany(prvs[1], stripped, []byte("s1"), []byte("s2")); err != ErrInvalidMessage {
+		fmt.Println("ecies: stripped recipient list accepted", err)
+		t.FailNow()
+	}
+	if _, err = EncryptToMany(rand.Reader, nil, message, nil, nil); err != ErrNoRecipients {
+		fmt.Println("ecies: message encrypted without recipients", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,225 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Multicodec/Multikey and libp2p public key encodings, as used by the
+// did:key method and libp2p peer identities.
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/binary"
+	"math/big"
+)
+
+// Multicodec table codes of the compressed public keys.
+// See https://github.com/multiformats/multicodec/blob/master/table.csv
+var multicodecFromCurve = map[elliptic.Curve]uint64{
+	elliptic.P256(): 0x1200, // p256-pub
+	elliptic.P384(): 0x1201, // p384-pub
+	elliptic.P521(): 0x1202, // p521-pub
+	Secp256k1():     0xe7,   // secp256k1-pub
+	SM2():           0x1206, // sm2-pub
+	X25519():        0xec,   // x25519-pub
+	X448():          0x1204, // x448-pub
+}
+
+// multicodecEd25519 is the code of Ed25519 public keys (ed25519-pub), which
+// decode to the X25519 key of the same identity, as did:key derives its key
+// agreement key from them.
+const multicodecEd25519 = 0xed
+
+func curveFromMulticodec(code uint64) elliptic.Curve {
+	for curve, c := range multicodecFromCurve {
+		if c == code {
+			return curve
+		}
+	}
+	return nil
+}
+
+// MarshalPublicMulticodec encodes a public key as its multicodec varint
+// prefix followed by the compressed point, or the raw key of X25519 and X448.
+func MarshalPublicMulticodec(pub *PublicKey) ([]byte, error) {
+	code, ok := multicodecFromCurve[pub.Curve]
+	if !ok || pub.X == nil || pub.Y == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	out := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(out, code)
+	return append(out[:n], CompressPublicKey(pub)...), nil
+}
+
+// UnmarshalPublicMulticodec decodes a multicodec prefixed public key. Ed25519
+// keys are converted to X25519 keys, see Ed25519PublicKeyToX25519.
+func UnmarshalPublicMulticodec(in []byte) (*PublicKey, error) {
+	code, n := binary.Uvarint(in)
+	if n <= 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	if code == multicodecEd25519 {
+		if len(in[n:]) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPublicKey
+		}
+		return Ed25519PublicKeyToX25519(in[n:])
+	}
+	curve := curveFromMulticodec(code)
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	return NewPublicKeyFromBytes(curve, in[n:])
+}
+
+// EncodePublicMultibase encodes a public key in the Multikey format: the
+// base58btc multibase encoding ('z' prefix) of the multicodec public key.
+// This is the publicKeyMultibase value of DID documents and the method
+// specific identifier of did:key.
+func EncodePublicMultibase(pub *PublicKey) (string, error) {
+	mc, err := MarshalPublicMulticodec(pub)
+	if err != nil {
+		return "", err
+	}
+	return "z" + base58Encode(mc), nil
+}
+
+// DecodePublicMultibase decodes a public key in the Multikey format.
+func DecodePublicMultibase(s string) (*PublicKey, error) {
+	if len(s) < 2 || s[0] != 'z' {
+		return nil, ErrInvalidPublicKey
+	}
+	mc, ok := base58Decode(s[1:])
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+	return UnmarshalPublicMulticodec(mc)
+}
+
+// The libp2p key types, see https://github.com/libp2p/specs/blob/master/peer-ids/peer-ids.md
+const (
+	libp2pKeyTypeSecp256k1 = 2
+	libp2pKeyTypeECDSA     = 3
+)
+
+// MarshalPublicLibp2p encodes a public key as a libp2p PublicKey protobuf
+// message, of the ECDSA type with the PKIX encoded key as data, or of the
+// Secp256k1 type with the compressed point as data. libp2p has no SM2, X25519
+// or X448 keys.
+func MarshalPublicLibp2p(pub *PublicKey) ([]byte, error) {
+	_, raw := pub.Curve.(rawPointCurve)
+	if _, ok := multicodecFromCurve[pub.Curve]; !ok || pub.Curve == SM2() || raw {
+		return nil, ErrInvalidPublicKey
+	}
+	var keyType byte
+	var data []byte
+	if pub.Curve == Secp256k1() {
+		keyType, data = libp2pKeyTypeSecp256k1, CompressPublicKey(pub)
+	} else {
+		var err error
+		if data, err = x509.MarshalPKIXPublicKey(pub.ExportECDSA()); err != nil {
+			return nil, ErrInvalidPublicKey
+		}
+		keyType = libp2pKeyTypeECDSA
+	}
+	out := make([]byte, 3+binary.MaxVarintLen64)
+	copy(out, []byte{0x08, keyType, 0x12})
+	n := binary.PutUvarint(out[3:], uint64(len(data)))
+	return append(out[:3+n], data...), nil
+}
+
+// UnmarshalPublicLibp2p decodes a libp2p PublicKey protobuf message of the
+// ECDSA or Secp256k1 type.
+func UnmarshalPublicLibp2p(in []byte) (*PublicKey, error) {
+	var keyType uint64
+	var data []byte
+	for len(in) > 0 {
+		tag, n := binary.Uvarint(in)
+		if n <= 0 {
+			return nil, ErrInvalidPublicKey
+		}
+		in = in[n:]
+		v, n := binary.Uvarint(in)
+		if n <= 0 {
+			return nil, ErrInvalidPublicKey
+		}
+		in = in[n:]
+		switch tag {
+		case 0x08: // field 1 (Type), varint
+			keyType = v
+		case 0x12: // field 2 (Data), length-delimited
+			if v > uint64(len(in)) {
+				return nil, ErrInvalidPublicKey
+			}
+			data, in = in[:v], in[v:]
+		default:
+			return nil, ErrInvalidPublicKey
+		}
+	}
+	if keyType == libp2pKeyTypeSecp256k1 {
+		return DecompressPublicKey(Secp256k1(), data)
+	}
+	if keyType != libp2pKeyTypeECDSA {
+		return nil, ErrInvalidPublicKey
+	}
+	key, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+	return NewPublicKey(ecKey.Curve, ecKey.X, ecKey.Y)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data with the Bitcoin base58 alphabet.
+func base58Encode(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeros; i++ {
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// base58Decode decodes a string in the Bitcoin base58 alphabet.
+func base58Decode(s string) ([]byte, bool) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(s); i++ {
+		v := -1
+		for j := 0; j < len(base58Alphabet); j++ {
+			if base58Alphabet[j] == s[i] {
+				v = j
+				break
+			}
+		}
+		if v < 0 {
+			return nil, false
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(v)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), true
+}
@@ -0,0 +1,139 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Ensure that the did:key test vectors decode to keys on the right curves.
+func TestVectorMultibase(t *testing.T) {
+	for _, c := range []struct {
+		Key   string
+		Curve elliptic.Curve
+	}{
+		{"zDnaerDaTF5BXEavCrfRZEk316dpbLsfPDZ3WJ5hRTPFU2169", elliptic.P256()},
+		{"zDnaerx9CtbPJ1q36T5Ln5wYt3MQYeGRG5ehnPAmxcf5mDZpv", elliptic.P256()},
+		// The keyAgreement key of did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK.
+		{"z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p", X25519()},
+	} {
+		pub, err := DecodePublicMultibase(c.Key)
+		if err != nil {
+			fmt.Println(c.Key, err.Error())
+			t.FailNow()
+		} else if pub.Curve != c.Curve {
+			fmt.Println(c.Key, "ecies: decoded key on the wrong curve")
+			t.FailNow()
+		}
+		if out, err := EncodePublicMultibase(pub); err != nil || out != c.Key {
+			fmt.Println(c.Key, "ecies: multibase round trip failed", out, err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that Ed25519 did:key keys decode to their X25519 key agreement key,
+// as in the example of the did:key specification.
+func TestVectorMultibaseEd25519(t *testing.T) {
+	pub, err := DecodePublicMultibase("z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK")
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if out, err := EncodePublicMultibase(pub); err != nil || out != "z6LSj72tK8brWgZja8NLRwPigth2T9QRiG1uH9oKZuKjdh9p" {
+		fmt.Println("ecies: unexpected X25519 key of an Ed25519 key", out, err)
+		t.FailNow()
+	}
+	for _, bad := range []string{"z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2do", "z6Mk"} {
+		if _, err = DecodePublicMultibase(bad); err == nil {
+			fmt.Println(bad, "ecies: truncated Ed25519 key accepted")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that public keys survive the multicodec and libp2p round trips.
+func TestMultikey(t *testing.T) {
+	prefixes := map[elliptic.Curve]string{
+		elliptic.P256(): "zDn",
+		elliptic.P384(): "z82",
+		elliptic.P521(): "z2J9",
+		Secp256k1():     "zQ3s",
+	}
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		mb, err := EncodePublicMultibase(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !strings.HasPrefix(mb, prefixes[c]) {
+			fmt.Println(name, "ecies: unexpected multibase prefix", mb)
+			t.FailNow()
+		}
+		pub, err := DecodePublicMultibase(mb)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println(name, "ecies: multibase public key doesn't match")
+			t.FailNow()
+		}
+
+		p2p, err := MarshalPublicLibp2p(&prv.PublicKey)
+		if c == SM2() {
+			if err != ErrInvalidPublicKey {
+				fmt.Println(name, "ecies: SM2 key encoded for libp2p")
+				t.FailNow()
+			}
+			continue
+		}
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if pub, err = UnmarshalPublicLibp2p(p2p); err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println(name, "ecies: libp2p public key doesn't match")
+			t.FailNow()
+		}
+	}
+
+	for _, c := range []elliptic.Curve{X25519(), X448()} {
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		mb, err := EncodePublicMultibase(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(c.Params().Name, err.Error())
+			t.FailNow()
+		}
+		if pub, err := DecodePublicMultibase(mb); err != nil || !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println(c.Params().Name, "ecies: multibase public key doesn't match", err)
+			t.FailNow()
+		}
+		if _, err = MarshalPublicLibp2p(&prv.PublicKey); err != ErrInvalidPublicKey {
+			fmt.Println(c.Params().Name, "ecies: key encoded for libp2p")
+			t.FailNow()
+		}
+	}
+
+	if _, err := DecodePublicMultibase("z0OIl"); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: decoded an invalid base58 string")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Suite negotiation for client/server protocols.
+
+import (
+	"encoding/binary"
+)
+
+var ErrNoMutualSuite = newError(KindParams, "ecies: no mutually supported suite")
+
+// SuiteIDs returns the identifiers of the supported suites allowed by the
+// global policy, to be advertised to a peer.
+func SuiteIDs() []byte {
+	var ids []byte
+	for _, s := range SupportedSuites() {
+		if suiteAllowed(s) {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+func suiteAllowed(s SuiteInfo) bool {
+	p := CurrentPolicy()
+	return p == nil || (s.Params.Hash().Size() >= p.MinHashSize && s.Params.KeyLen >= p.MinKeyLen)
+}
+
+// NegotiateSuite picks the strongest suite advertised by both sides. The
+// result does not depend on which side is local, nor on the order of the
+// lists, so both peers reach the same choice. Unknown identifiers, and suites
+// not allowed by the global policy, are ignored.
+//
+// To detect a downgrade by an attacker tampering with the advertised lists,
+// bind them into the messages, e.g. with NegotiationTranscript as the KDF
+// shared information.
+func NegotiateSuite(local, remote []byte) (byte, *ECIESParams, error) {
+	var best *SuiteInfo
+	suites := SupportedSuites()
+	for i := range suites {
+		s := &suites[i]
+		if !containsID(local, s.ID) || !containsID(remote, s.ID) || !suiteAllowed(*s) {
+			continue
+		}
+		if best == nil || s.SecurityBits > best.SecurityBits ||
+			(s.SecurityBits == best.SecurityBits && s.ID > best.ID) {
+			best = s
+		}
+	}
+	if best == nil {
+		return 0, nil, ErrNoMutualSuite
+	}
+	return best.ID, best.Params, nil
+}
+
+// NegotiationTranscript encodes the suite lists advertised by the client and
+// the server, length prefixed, to be used as shared information.
+func NegotiationTranscript(client, server []byte) []byte {
+	out := make([]byte, 4+len(client)+len(server))
+	binary.BigEndian.PutUint16(out, uint16(len(client)))
+	n := 2 + copy(out[2:], client)
+	binary.BigEndian.PutUint16(out[n:], uint16(len(server)))
+	copy(out[n+2:], server)
+	return out
+}
+
+func containsID(ids []byte, id byte) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Ensure both sides pick the same, strongest mutual suite.
+func TestNegotiateSuite(t *testing.T) {
+	for _, c := range []struct {
+		Local, Remote []byte
+		ID            byte
+		Err           error
+	}{
+		{[]byte{1, 2, 3}, []byte{1, 2, 3}, 3, nil},
+		{[]byte{1, 2}, []byte{3, 2, 1}, 2, nil},
+		{[]byte{1, 42}, []byte{42, 1}, 1, nil},
+		{[]byte{1}, []byte{2, 3}, 0, ErrNoMutualSuite},
+		{nil, []byte{1}, 0, ErrNoMutualSuite},
+	} {
+		for _, swap := range []bool{false, true} {
+			local, remote := c.Local, c.Remote
+			if swap {
+				local, remote = remote, local
+			}
+			id, params, err := NegotiateSuite(local, remote)
+			if err != c.Err || id != c.ID || (err == nil && params == nil) {
+				fmt.Println("ecies: unexpected negotiation result", local, remote, id, err)
+				t.FailNow()
+			}
+		}
+	}
+
+	if !bytes.Equal(SuiteIDs(), []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28}) {
+		fmt.Println("ecies: unexpected advertised suites")
+		t.FailNow()
+	}
+	SetPolicy(&Policy{MinKeyLen: 24})
+	defer SetPolicy(nil)
+	if !bytes.Equal(SuiteIDs(), []byte{2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 14, 15, 17, 18, 20, 21, 23, 25, 27, 28}) {
+		fmt.Println("ecies: advertised suites not filtered by policy")
+		t.FailNow()
+	}
+	if _, _, err := NegotiateSuite([]byte{1}, []byte{1}); err != ErrNoMutualSuite {
+		fmt.Println("ecies: negotiated a suite forbidden by policy")
+		t.FailNow()
+	}
+
+	if bytes.Equal(NegotiationTranscript([]byte{1, 2}, []byte{3}), NegotiationTranscript([]byte{1}, []byte{2, 3})) {
+		fmt.Println("ecies: ambiguous negotiation transcript")
+		t.FailNow()
+	}
+}
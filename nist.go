@@ -0,0 +1,119 @@
+package ecies
+
+// Key generation and agreement on P-256, P-384 and P-521 with crypto/ecdh,
+// whose implementations are constant time, rather than with the deprecated
+// big.Int methods of crypto/elliptic. Keys keep their big.Int coordinates and
+// scalar, so the key types and the wire format are unchanged.
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"io"
+	"math/big"
+)
+
+// nistECDH returns the crypto/ecdh implementation of curve, or nil if it
+// has none.
+func nistECDH(curve elliptic.Curve) ecdh.Curve {
+	switch curve {
+	case elliptic.P256():
+		return ecdh.P256()
+	case elliptic.P384():
+		return ecdh.P384()
+	case elliptic.P521():
+		return ecdh.P521()
+	}
+	return nil
+}
+
+// generateNIST samples the scalar from rand as elliptic.GenerateKey does, so
+// that keys generated from a given source don't change, and computes the
+// public key with crypto/ecdh.
+func generateNIST(rand io.Reader, curve elliptic.Curve, params *ECIESParams) (*PrivateKey, error) {
+	N := curve.Params().N
+	d := make([]byte, (N.BitLen()+7)/8)
+	defer wipe(d)
+	var priv *ecdh.PrivateKey
+	for priv == nil {
+		if _, err := io.ReadFull(rand, d); err != nil {
+			return nil, err
+		}
+		// Mask the excess bits of P-521, and avoid the zero scalar with
+		// sources returning only zeros, as elliptic.GenerateKey does.
+		if excess := N.BitLen() % 8; excess != 0 {
+			d[0] &= byte(1<<excess - 1)
+		}
+		d[1] ^= 0x42
+		// Out of range scalars are sampled again.
+		priv, _ = nistECDH(curve).NewPrivateKey(d)
+	}
+	prv := newNISTPrivateKey(curve, priv)
+	if params != nil {
+		prv.PublicKey.Params = params
+	}
+	return prv, nil
+}
+
+func newNISTPrivateKey(curve elliptic.Curve, priv *ecdh.PrivateKey) *PrivateKey {
+	// The public key is encoded as 0x04 || X || Y.
+	point := priv.PublicKey().Bytes()
+	size := (len(point) - 1) / 2
+	prv := new(PrivateKey)
+	prv.PublicKey.X = new(big.Int).SetBytes(point[1 : 1+size])
+	prv.PublicKey.Y = new(big.Int).SetBytes(point[1+size:])
+	prv.PublicKey.Curve = curve
+	prv.PublicKey.Params = ParamsFromCurve(curve)
+	d := priv.Bytes()
+	prv.D = new(big.Int).SetBytes(d)
+	wipe(d)
+	return prv
+}
+
+// nistPrivateKey returns the crypto/ecdh private key for the scalar d, which
+// must be in the range [1, N-1].
+func nistPrivateKey(curve elliptic.Curve, d *big.Int) (*ecdh.PrivateKey, error) {
+	N := curve.Params().N
+	if d == nil || d.Sign() <= 0 || d.Cmp(N) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	key := d.FillBytes(make([]byte, (N.BitLen()+7)/8))
+	defer wipe(key)
+	return nistECDH(curve).NewPrivateKey(key)
+}
+
+// nistPublicKey returns the crypto/ecdh public key for the point (x, y),
+// which must be on the curve.
+func nistPublicKey(curve elliptic.Curve, x, y *big.Int) (*ecdh.PublicKey, error) {
+	P := curve.Params().P
+	if x == nil || y == nil || x.Sign() < 0 || y.Sign() < 0 || x.Cmp(P) >= 0 || y.Cmp(P) >= 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	size := (P.BitLen() + 7) / 8
+	point := make([]byte, 1+2*size)
+	point[0] = pointUncompressed
+	x.FillBytes(point[1 : 1+size])
+	y.FillBytes(point[1+size:])
+	pub, err := nistECDH(curve).NewPublicKey(point)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return pub, nil
+}
+
+// nistShared returns the x-coordinate of d*Q, for the scalar of prv and the
+// point of pub.
+func nistShared(prv *PrivateKey, pub *PublicKey) ([]byte, error) {
+	priv, err := nistPrivateKey(prv.Curve, prv.D)
+	if err != nil {
+		return nil, err
+	}
+	Q, err := nistPublicKey(pub.Curve, pub.X, pub.Y)
+	if err != nil {
+		return nil, err
+	}
+	z, err := priv.ECDH(Q)
+	if err != nil {
+		return nil, ErrSharedKeyIsPointAtInfinity
+	}
+	return z, nil
+}
@@ -0,0 +1,59 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure that keys and shared secrets computed with crypto/ecdh match those
+// of crypto/elliptic, and that points off the curve are rejected.
+func TestNISTECDH(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		name := curve.Params().Name
+		seed := make([]byte, 256)
+		if _, err := rand.Read(seed); err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		prv, err := GenerateKey(bytes.NewReader(seed), curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		d, x, y, err := elliptic.GenerateKey(curve, bytes.NewReader(seed))
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if prv.D.Cmp(new(big.Int).SetBytes(d)) != 0 || prv.X.Cmp(x) != 0 || prv.Y.Cmp(y) != 0 {
+			fmt.Println(name, "ecies: key differs from elliptic.GenerateKey")
+			t.FailNow()
+		}
+
+		peer, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		z, err := prv.GenerateShared(&peer.PublicKey)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		zx, _ := curve.ScalarMult(peer.X, peer.Y, prv.D.Bytes())
+		if !bytes.Equal(z, zx.FillBytes(make([]byte, len(z)))) {
+			fmt.Println(name, "ecies: shared secret differs from elliptic.ScalarMult")
+			t.FailNow()
+		}
+
+		off := &PublicKey{X: peer.X, Y: new(big.Int).Add(peer.Y, big.NewInt(1)), Curve: curve}
+		if _, err = prv.GenerateShared(off); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: point off the curve accepted", err)
+			t.FailNow()
+		}
+	}
+}
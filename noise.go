@@ -0,0 +1,261 @@
+package ecies
+
+// The one-way N pattern of the Noise Protocol Framework (revision 34), in
+// which the initiator only knows the static key of the responder:
+//
+//	N:
+//	  <- s
+//	  ...
+//	  -> e, es
+//
+// The handshake message is the ephemeral public key of the initiator,
+// followed by the payload sealed with the key derived from the DH of the
+// ephemeral key and the responder key. Firmware built on Noise libraries can
+// thus send messages which the responder decrypts with any KeyProvider.
+//
+// X25519 keys use the "25519" DH functions of the specification. P-256 keys
+// use "P256", as some Noise libraries do: the public keys are uncompressed
+// SEC 1 points, and the DH output is the x-coordinate of the shared point.
+
+import (
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// noiseMaxMessageSize is the largest Noise message.
+const noiseMaxMessageSize = 65535
+
+// NoiseCipher is a cipher function of the Noise Protocol Framework.
+type NoiseCipher int
+
+const (
+	NoiseChaChaPoly NoiseCipher = iota
+	NoiseAESGCM
+)
+
+// NoiseHash is a hash function of the Noise Protocol Framework.
+type NoiseHash int
+
+const (
+	NoiseSHA256 NoiseHash = iota
+	NoiseSHA512
+	NoiseBLAKE2s
+	NoiseBLAKE2b
+)
+
+// NoiseConfig selects the protocol of NoiseSeal and NoiseOpen. The zero
+// value is Noise_N_25519_ChaChaPoly_SHA256 for X25519 keys, and
+// Noise_N_P256_ChaChaPoly_SHA256 for P-256 keys.
+type NoiseConfig struct {
+	Cipher NoiseCipher
+	Hash   NoiseHash
+	// Prologue is data both parties must agree on, authenticated by the
+	// handshake.
+	Prologue []byte
+}
+
+func (cfg *NoiseConfig) hash() (func() hash.Hash, string) {
+	switch cfg.Hash {
+	case NoiseSHA512:
+		return sha512.New, "SHA512"
+	case NoiseBLAKE2s:
+		return func() hash.Hash { h, _ := blake2s.New256(nil); return h }, "BLAKE2s"
+	case NoiseBLAKE2b:
+		return func() hash.Hash { h, _ := blake2b.New512(nil); return h }, "BLAKE2b"
+	}
+	return sha256.New, "SHA256"
+}
+
+// protocolName returns the name of the protocol for keys on curve.
+func (cfg *NoiseConfig) protocolName(curve elliptic.Curve) (string, error) {
+	var dh string
+	switch curve {
+	case X25519():
+		dh = "25519"
+	case elliptic.P256():
+		dh = "P256"
+	default:
+		return "", ErrInvalidCurve
+	}
+	cipher := "ChaChaPoly"
+	switch cfg.Cipher {
+	case NoiseChaChaPoly:
+	case NoiseAESGCM:
+		cipher = "AESGCM"
+	default:
+		return "", ErrUnsupportedECIESParameters
+	}
+	_, h := cfg.hash()
+	return "Noise_N_" + dh + "_" + cipher + "_" + h, nil
+}
+
+// noiseState is the symmetric state of a handshake.
+type noiseState struct {
+	cfg  *NoiseConfig
+	hash func() hash.Hash
+	ck   []byte
+	h    []byte
+	k    []byte
+}
+
+// newNoiseState initializes the handshake state of the N pattern for the
+// responder key pub, up to its pre-message.
+func newNoiseState(cfg *NoiseConfig, pub *PublicKey) (*noiseState, error) {
+	name, err := cfg.protocolName(pub.Curve)
+	if err != nil {
+		return nil, err
+	}
+	s := &noiseState{cfg: cfg}
+	s.hash, _ = cfg.hash()
+	hashLen := s.hash().Size()
+	if len(name) <= hashLen {
+		s.h = make([]byte, hashLen)
+		copy(s.h, name)
+	} else {
+		s.h = s.sum([]byte(name))
+	}
+	s.ck = s.h
+	s.mixHash(cfg.Prologue)
+	s.mixHash(marshalPoint(pub.Curve, pub.X, pub.Y))
+	return s, nil
+}
+
+func (s *noiseState) sum(data ...[]byte) []byte {
+	h := s.hash()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func (s *noiseState) mixHash(data []byte) {
+	s.h = s.sum(s.h, data)
+}
+
+// mixKey sets the chaining key and the cipher key from the HKDF of the
+// specification, with the chaining key as the salt.
+func (s *noiseState) mixKey(input []byte) {
+	prk := hmacSum(s.hash, s.ck, input)
+	defer wipe(prk)
+	s.ck = hmacSum(s.hash, prk, []byte{1})
+	k := hmacSum(s.hash, prk, s.ck, []byte{2})
+	// Keys are truncated to 32 bytes with 64-byte hashes.
+	wipe(k[chacha20poly1305.KeySize:])
+	s.k = k[:chacha20poly1305.KeySize]
+}
+
+// nonce returns the encoding of the first nonce of a cipher key, the only
+// one the handshake uses: all zeros, in either byte order.
+func (s *noiseState) nonce(size int) []byte {
+	return make([]byte, size)
+}
+
+func (s *noiseState) seal(payload []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	c := aead.Seal(nil, s.nonce(aead.NonceSize()), payload, s.h)
+	s.mixHash(c)
+	return c, nil
+}
+
+func (s *noiseState) open(c []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	m, err := aead.Open(nil, s.nonce(aead.NonceSize()), c, s.h)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+	s.mixHash(c)
+	return m, nil
+}
+
+func (s *noiseState) aead() (cipher.AEAD, error) {
+	if s.cfg.Cipher == NoiseAESGCM {
+		return newAESGCM(s.k)
+	}
+	return chacha20poly1305.New(s.k)
+}
+
+func (s *noiseState) wipe() {
+	wipe(s.ck)
+	wipe(s.k)
+}
+
+// NoiseSeal returns the handshake message of the Noise N pattern carrying
+// payload to the holder of the private key of pub. If cfg is nil, the
+// default configuration is used.
+func NoiseSeal(rand io.Reader, pub *PublicKey, payload []byte, cfg *NoiseConfig) ([]byte, error) {
+	if cfg == nil {
+		cfg = &NoiseConfig{}
+	}
+	if err := ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
+	s, err := newNoiseState(cfg, pub)
+	if err != nil {
+		return nil, err
+	}
+	defer s.wipe()
+	z, e, err := encapsulate(rand, pub, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(z)
+	if len(e)+len(payload)+chacha20poly1305.Overhead > noiseMaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	s.mixHash(e)
+	s.mixKey(z)
+	c, err := s.seal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return append(e, c...), nil
+}
+
+// NoiseOpen returns the payload of a handshake message of the Noise N
+// pattern for the key of prv, produced by NoiseSeal or by any Noise library
+// with the same protocol and prologue. If cfg is nil, the default
+// configuration is used.
+func NoiseOpen(prv KeyProvider, msg []byte, cfg *NoiseConfig) ([]byte, error) {
+	if cfg == nil {
+		cfg = &NoiseConfig{}
+	}
+	pub := prv.Public()
+	s, err := newNoiseState(cfg, pub)
+	if err != nil {
+		return nil, err
+	}
+	defer s.wipe()
+	if len(msg) > noiseMaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	size := pointSize(pub.Curve, pointUncompressed, UncompressedPointsOnly)
+	if len(msg) < size+chacha20poly1305.Overhead {
+		return nil, refineError(ErrInvalidMessage, KindEncoding, errMessageTooShort)
+	}
+	e, err := parseEncapsulation(pub, msg[:size], UncompressedPointsOnly)
+	if err != nil {
+		return nil, err
+	}
+	z, err := prv.GenerateShared(e)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(z)
+	s.mixHash(msg[:size])
+	s.mixKey(z)
+	return s.open(msg[size:])
+}
@@ -0,0 +1,127 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// Ensure Noise N messages decrypt with every cipher and hash, and only with
+// the same prologue.
+func TestNoise(t *testing.T) {
+	payload := []byte("Hello, Noise.")
+	for _, curve := range []elliptic.Curve{X25519(), elliptic.P256()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, c := range []NoiseCipher{NoiseChaChaPoly, NoiseAESGCM} {
+			for _, h := range []NoiseHash{NoiseSHA256, NoiseSHA512, NoiseBLAKE2s, NoiseBLAKE2b} {
+				cfg := &NoiseConfig{Cipher: c, Hash: h, Prologue: []byte("device v1")}
+				msg, err := NoiseSeal(rand.Reader, &prv.PublicKey, payload, cfg)
+				if err != nil {
+					fmt.Println(err.Error())
+					t.FailNow()
+				}
+				if len(msg) != pointSize(curve, pointUncompressed, AllowAllPoints)+len(payload)+16 {
+					fmt.Println("ecies: wrong Noise message size", len(msg))
+					t.FailNow()
+				}
+				got, err := NoiseOpen(prv, msg, cfg)
+				if err != nil || !bytes.Equal(got, payload) {
+					fmt.Println("ecies: Noise message not decrypted", c, h, err)
+					t.FailNow()
+				}
+				other := &NoiseConfig{Cipher: c, Hash: h, Prologue: []byte("device v2")}
+				if _, err = NoiseOpen(prv, msg, other); err != ErrInvalidMessage {
+					fmt.Println("ecies: Noise message decrypted with another prologue", err)
+					t.FailNow()
+				}
+				msg[len(msg)-1] ^= 1
+				if _, err = NoiseOpen(prv, msg, cfg); err != ErrInvalidMessage {
+					fmt.Println("ecies: tampered Noise message decrypted", err)
+					t.FailNow()
+				}
+			}
+		}
+	}
+	name, _ := (&NoiseConfig{Cipher: NoiseAESGCM, Hash: NoiseBLAKE2b}).protocolName(X25519())
+	if name != "Noise_N_25519_AESGCM_BLAKE2b" {
+		fmt.Println("ecies: wrong Noise protocol name", name)
+		t.FailNow()
+	}
+	prv, _ := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if _, err := NoiseSeal(rand.Reader, &prv.PublicKey, payload, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: Noise message sealed on an unsupported curve", err)
+		t.FailNow()
+	}
+}
+
+// Ensure Noise N messages match the Noise_N_25519 vectors of cacophony, as
+// shipped in vectors.txt of github.com/flynn/noise v1.1.0: the responder key
+// is 0x01..0x20, the initiator ephemeral key 0x20..0x3f and the payload
+// "test_msg_0", with no prologue or the prologue "notsecret".
+func TestNoiseVectors(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	prv, err := NewPrivateKey(X25519(), hexBytes("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ephemeral := hexBytes("202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f")
+	payload := []byte("test_msg_0")
+	for _, c := range []struct {
+		Name     string
+		Cfg      NoiseConfig
+		Prologue string
+		Msg      string
+	}{
+		{"Noise_N_25519_ChaChaPoly_SHA256", NoiseConfig{}, "",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254a703e3bfcc38dbdb465b7d5ded3686008b3ff4c92f20e9fe4b44"},
+		{"Noise_N_25519_ChaChaPoly_SHA256", NoiseConfig{}, "notsecret",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254a703e3bfcc38dbdb465bc83726dbcf8aa4764c684931d2985245"},
+		{"Noise_N_25519_AESGCM_SHA256", NoiseConfig{Cipher: NoiseAESGCM}, "notsecret",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254df115f83f13b64589fec852ae179184185e9d29fed35f4d235dc"},
+		{"Noise_N_25519_ChaChaPoly_SHA512", NoiseConfig{Hash: NoiseSHA512}, "notsecret",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd1662548d5c068cc55c86ec32343e3869720328a5659aa70ee82a7e2153"},
+		{"Noise_N_25519_ChaChaPoly_BLAKE2s", NoiseConfig{Hash: NoiseBLAKE2s}, "notsecret",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254ae81c7528e1cf6662cf390a71ae79e4927b62e8f1c66496d38c2"},
+		{"Noise_N_25519_ChaChaPoly_BLAKE2b", NoiseConfig{Hash: NoiseBLAKE2b}, "notsecret",
+			"358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254609e1a34b71f412922514c3e94964753215ede1067c59472f88c"},
+	} {
+		cfg := c.Cfg
+		if c.Prologue != "" {
+			cfg.Prologue = []byte(c.Prologue)
+		}
+		if name, _ := cfg.protocolName(X25519()); name != c.Name {
+			fmt.Println("ecies: wrong Noise protocol name", name)
+			t.FailNow()
+		}
+		want := hexBytes(c.Msg)
+		if got, err := NoiseOpen(prv, want, &cfg); err != nil || !bytes.Equal(got, payload) {
+			fmt.Println(c.Name, c.Prologue, "ecies: Noise vector not decrypted", err)
+			t.FailNow()
+		}
+		// crypto/ecdh may read a byte of rand before the key: retry until
+		// the ephemeral key is the one of the vector.
+		for i := 0; ; i++ {
+			msg, err := NoiseSeal(bytes.NewReader(ephemeral), &prv.PublicKey, payload, &cfg)
+			if err == io.ErrUnexpectedEOF && i < 64 {
+				continue
+			}
+			if err != nil || !bytes.Equal(msg, want) {
+				fmt.Println(c.Name, c.Prologue, "ecies: unexpected Noise message", hex.EncodeToString(msg), err)
+				t.FailNow()
+			}
+			break
+		}
+	}
+}
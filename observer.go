@@ -0,0 +1,145 @@
+package ecies
+
+// Observability: an optional observer receives an event per encryption,
+// decryption and key agreement, with the curve, suite, sizes, duration and
+// error kind of the operation, for metrics and audit logs. Events never carry
+// keys, secrets or messages.
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Operation is the kind of operation an Event reports.
+type Operation int
+
+const (
+	// OpEncrypt is an encryption, by Encrypt and its variants.
+	OpEncrypt Operation = iota + 1
+	// OpDecrypt is a decryption, by Decrypt and its variants.
+	OpDecrypt
+	// OpGenerateShared is the key agreement of an encryption or decryption,
+	// through the KeyProvider for decryptions.
+	OpGenerateShared
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpEncrypt:
+		return "encrypt"
+	case OpDecrypt:
+		return "decrypt"
+	case OpGenerateShared:
+		return "generate_shared"
+	}
+	return "unknown"
+}
+
+// Event describes an operation once done.
+type Event struct {
+	Operation Operation
+	// Curve is the name of the curve of the key, e.g. "P-256".
+	Curve string
+	// Suite is the name of the parameters of the key, as in ParamsFromName,
+	// or empty for custom parameters.
+	Suite string
+	// InputSize and OutputSize are the sizes of the message and ciphertext
+	// of an encryption, and of the ciphertext and message of a decryption.
+	// OutputSize is the size of the shared secret of a key agreement. It is 0
+	// on failure.
+	InputSize, OutputSize int
+	Duration              time.Duration
+	// Err is the kind of the error of a failed operation, or 0 on success
+	// and for errors which aren't of this package, e.g. of the random source
+	// or of a KeyProvider.
+	Err ErrorKind
+	// Failed reports whether the operation failed.
+	Failed bool
+}
+
+// Observer receives the events of the operations. It is called synchronously,
+// from the goroutines running the operations, so it must be safe for
+// concurrent use, and fast.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+type observerHolder struct {
+	observer Observer
+}
+
+var globalObserver atomic.Value // observerHolder
+
+// SetObserver installs the observer of all the operations of the package. A
+// nil observer removes it.
+func SetObserver(o Observer) {
+	globalObserver.Store(observerHolder{o})
+}
+
+// CurrentObserver returns the global observer, or nil if there is none.
+func CurrentObserver() Observer {
+	if h, ok := globalObserver.Load().(observerHolder); ok {
+		return h.observer
+	}
+	return nil
+}
+
+// observation is an operation being timed for the observer.
+type observation struct {
+	observer Observer
+	event    Event
+	start    time.Time
+}
+
+// startObservation starts timing an operation with the key pub, or returns
+// nil if there is no observer.
+func startObservation(op Operation, pub *PublicKey, inputSize int) *observation {
+	o := CurrentObserver()
+	if o == nil {
+		return nil
+	}
+	ob := &observation{observer: o, event: Event{Operation: op, InputSize: inputSize}}
+	if pub != nil && pub.Curve != nil {
+		ob.event.Curve = pub.Curve.Params().Name
+		params := pub.Params
+		if params == nil {
+			params = ParamsFromCurve(pub.Curve)
+		}
+		if params != nil {
+			ob.event.Suite, _ = suiteName(params)
+		}
+	}
+	ob.start = time.Now()
+	return ob
+}
+
+// done reports the operation to the observer.
+func (ob *observation) done(outputSize int, err error) {
+	ob.event.Duration = time.Since(ob.start)
+	if err != nil {
+		ob.event.Failed = true
+		var e *Error
+		if errors.As(err, &e) {
+			ob.event.Err = e.Kind
+		}
+	} else {
+		ob.event.OutputSize = outputSize
+	}
+	ob.observer.Observe(ob.event)
+}
+
+// generateShared is prv.GenerateShared, reported to the observer.
+func generateShared(prv KeyProvider, pub *PublicKey) (z []byte, err error) {
+	if ob := startObservation(OpGenerateShared, prv.Public(), 0); ob != nil {
+		defer func() { ob.done(len(z), err) }()
+	}
+	return prv.GenerateShared(pub)
+}
@@ -0,0 +1,72 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Ensure the observer receives an event per operation, with its metadata.
+func TestObserver(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var mu sync.Mutex
+	var events []Event
+	SetObserver(ObserverFunc(func(e Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}))
+	defer SetObserver(nil)
+
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = Decrypt(prv, ct, nil, nil); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct[len(ct)-1] ^= 1
+	if _, err = Decrypt(prv, ct, nil, nil); err == nil {
+		fmt.Println("ecies: tampered message decrypted")
+		t.FailNow()
+	}
+
+	ops := []Operation{OpGenerateShared, OpEncrypt, OpGenerateShared, OpDecrypt, OpGenerateShared, OpDecrypt}
+	if len(events) != len(ops) {
+		fmt.Println("ecies: unexpected number of events", len(events))
+		t.FailNow()
+	}
+	for i, e := range events {
+		if e.Operation != ops[i] || e.Curve != "P-256" || e.Suite == "" || e.Duration < 0 {
+			fmt.Println("ecies: unexpected event", i, e)
+			t.FailNow()
+		}
+	}
+	if e := events[1]; e.InputSize != len(message) || e.OutputSize != len(ct) || e.Failed {
+		fmt.Println("ecies: unexpected encryption event", e)
+		t.FailNow()
+	}
+	if e := events[3]; e.InputSize != len(ct) || e.OutputSize != len(message) || e.Failed {
+		fmt.Println("ecies: unexpected decryption event", e)
+		t.FailNow()
+	}
+	if e := events[5]; !e.Failed || e.Err != KindAuthentication || e.OutputSize != 0 {
+		fmt.Println("ecies: unexpected failed decryption event", e)
+		t.FailNow()
+	}
+
+	SetObserver(nil)
+	if _, err = Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil); err != nil || len(events) != len(ops) {
+		fmt.Println("ecies: event reported without observer", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,86 @@
+package ecies
+
+// Padding of the message before encryption, so that the length of the
+// ciphertext doesn't give away the exact length of the message.
+
+import (
+	"crypto/subtle"
+	"math/bits"
+)
+
+var ErrInvalidPadding = newError(KindEncoding, "ecies: invalid message padding")
+
+// PaddingScheme chooses the length of padded messages.
+type PaddingScheme int
+
+const (
+	// NoPadding leaves the message as is.
+	NoPadding PaddingScheme = iota
+	// PadBlock pads the message to a multiple of EncryptOptions.PaddingBlock
+	// bytes, or of defaultPaddingBlock bytes if it is not set.
+	PadBlock
+	// PadPadme pads the message to the lengths of the Padmé scheme, which
+	// leak O(log log n) bits of the length n of the message, for an overhead
+	// of at most 12%.
+	PadPadme
+)
+
+// defaultPaddingBlock is the padding multiple of PadBlock by default.
+const defaultPaddingBlock = 32
+
+// paddedLen returns the length of a message of n bytes, including the
+// padding marker, once padded with the scheme.
+func (s PaddingScheme) paddedLen(n, block int) (int, error) {
+	switch s {
+	case PadBlock:
+		if block == 0 {
+			block = defaultPaddingBlock
+		}
+		if block < 0 {
+			return 0, ErrInvalidParams
+		}
+		return (n + block - 1) / block * block, nil
+	case PadPadme:
+		// The length keeps its log2(log2(n))+1 top bits, and is rounded up
+		// over the others.
+		e := bits.Len(uint(n)) - 1
+		s := bits.Len(uint(e))
+		if e <= s {
+			return n, nil
+		}
+		mask := 1<<(e-s) - 1
+		return (n + mask) &^ mask, nil
+	}
+	return 0, ErrInvalidParams
+}
+
+// pad appends the padding marker 0x80 to the message, then zeros up to the
+// length of the scheme, as in ISO/IEC 7816-4.
+func pad(m []byte, scheme PaddingScheme, block int) ([]byte, error) {
+	n, err := scheme.paddedLen(len(m)+1, block)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, n)
+	copy(out, m)
+	out[len(m)] = 0x80
+	return out, nil
+}
+
+// unpad returns the length of a padded message without its padding. It
+// runs in time depending on the padded length only.
+func unpad(m []byte) (int, error) {
+	n, found, bad := 0, 0, 0
+	for i := len(m) - 1; i >= 0; i-- {
+		nonZero := 1 - subtle.ConstantTimeByteEq(m[i], 0)
+		// The last non-zero byte must be the marker.
+		last := nonZero &^ found
+		n = subtle.ConstantTimeSelect(last, i, n)
+		bad |= last &^ subtle.ConstantTimeByteEq(m[i], 0x80)
+		found |= nonZero
+	}
+	if found == 0 || bad != 0 {
+		return 0, ErrInvalidPadding
+	}
+	return n, nil
+}
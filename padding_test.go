@@ -0,0 +1,68 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure padded messages hide their length and decrypt to the message.
+func TestPadding(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	aead, err := GenerateKey(rand.Reader, elliptic.P256(), ECIES_AES128_GCM_SHA256)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, key := range []*PrivateKey{prv, aead} {
+		for _, scheme := range []PaddingScheme{PadBlock, PadPadme} {
+			opts := &EncryptOptions{Padding: scheme}
+			short, err := EncryptWithOptions(rand.Reader, &key.PublicKey, []byte("yes"), nil, nil, opts)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			for _, m := range [][]byte{nil, []byte("no"), []byte("maybe"), bytes.Repeat([]byte{0x80, 0}, 500)} {
+				ct, err := EncryptWithOptions(rand.Reader, &key.PublicKey, m, nil, nil, opts)
+				if err != nil {
+					fmt.Println(err.Error())
+					t.FailNow()
+				}
+				if scheme == PadBlock && len(m) < 10 && len(ct) != len(short) {
+					fmt.Println("ecies: padded message length leaked", scheme, len(m))
+					t.FailNow()
+				}
+				pt, err := DecryptWithOptions(key, ct, nil, nil, &DecryptOptions{Padded: true})
+				if err != nil || !bytes.Equal(pt, m) {
+					fmt.Println("ecies: padded message not decrypted", scheme, err)
+					t.FailNow()
+				}
+			}
+		}
+		ct, err := Encrypt(rand.Reader, &key.PublicKey, []byte("no marker\x00"), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err = DecryptWithOptions(key, ct, nil, nil, &DecryptOptions{Padded: true}); err != ErrInvalidPadding {
+			fmt.Println("ecies: message without padding accepted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure the Padmé lengths match the reference values.
+func TestPadmeLength(t *testing.T) {
+	for n, want := range map[int]int{1: 1, 4: 4, 9: 10, 100: 104, 1000: 1024, 1025: 1088} {
+		if got, _ := PadPadme.paddedLen(n, 0); got != want {
+			fmt.Println("ecies: wrong Padmé length", n, got, want)
+			t.FailNow()
+		}
+	}
+}
@@ -9,16 +9,20 @@ import (
 	"crypto/elliptic"
 	"crypto/sha256"
 	"crypto/sha512"
-	"fmt"
 	"hash"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+	"golang.org/x/sys/cpu"
 )
 
 // The default curve is the NIST P256 curve, which provides security equivalent to AES-128.
 var DefaultCurve = elliptic.P256()
 
 var (
-	ErrUnsupportedECDHAlgorithm   = fmt.Errorf("ecies: unsupported ECDH algorithm")
-	ErrUnsupportedECIESParameters = fmt.Errorf("ecies: unsupported ECIES parameters")
+	ErrUnsupportedECDHAlgorithm   = newError(KindUnsupported, "ecies: unsupported ECDH algorithm")
+	ErrUnsupportedECIESParameters = newError(KindParams, "ecies: unsupported ECIES parameters")
 )
 
 type ECIESParams struct {
@@ -27,6 +31,29 @@ type ECIESParams struct {
 	Cipher    func([]byte) (cipher.Block, error) // symmetric cipher
 	BlockSize int                                // block size of symmetric cipher
 	KeyLen    int                                // length of symmetric key
+	// AEAD, if set, replaces the CTR mode and the HMAC tag: messages are
+	// sealed under a KeyLen-byte key with a random nonce, which is prepended,
+	// and the shared information s2 as additional data. Cipher and BlockSize
+	// are not used, and may be unset for stream ciphers.
+	AEAD func(key []byte) (cipher.AEAD, error)
+	// KDF, if set, replaces the standard key derivation: it returns length
+	// bytes of keying material derived from the shared secret z and the
+	// shared information s1, passed as info, using a fresh hash from Hash.
+	// Parameters with a custom KDF have no suite identifier nor ASN.1
+	// encoding.
+	KDF func(hash hash.Hash, z, info []byte, length int) ([]byte, error)
+	// EphemeralInKDF prepends the encoded ephemeral public key to the shared
+	// secret in the KDF input, as ISO/IEC 18033-2 does outside of its single
+	// hash mode, and IEEE 1363a in its DHAES mode. MACLabelLength appends
+	// the length of s2 in bits, as an 8-byte big-endian integer, to the MAC
+	// input, as the DHAES mode also does. Parameters with either have no
+	// suite identifier nor ASN.1 encoding.
+	EphemeralInKDF bool
+	MACLabelLength bool
+
+	dem demAlgorithm
+	kdf kdfAlgorithm
+	mac macAlgorithm
 }
 
 // Standard ECIES parameters selected according to SEC 1 sections 3.5 - 3.8.
@@ -58,97 +85,444 @@ var (
 	}
 )
 
+// ECIES parameters deriving the keys with HKDF (RFC 5869) instead of the
+// NIST concatenation KDF. The shared information s1 is the HKDF info, and
+// the salt is empty.
+var (
+	ECIES_AES128_HKDF_SHA256 = &ECIESParams{
+		Hash:      sha256.New,
+		hashAlgo:  crypto.SHA256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+		kdf:       kdfHKDF,
+	}
+
+	ECIES_AES192_HKDF_SHA384 = &ECIESParams{
+		Hash:      sha512.New384,
+		hashAlgo:  crypto.SHA384,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    24,
+		kdf:       kdfHKDF,
+	}
+
+	ECIES_AES256_HKDF_SHA512 = &ECIESParams{
+		Hash:      sha512.New,
+		hashAlgo:  crypto.SHA512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+		kdf:       kdfHKDF,
+	}
+)
+
+// ECIES parameters deriving the keys with the ANSI X9.63 KDF instead of the
+// NIST concatenation KDF, for interoperability with libraries using it. The
+// shared information s1 is the X9.63 SharedInfo.
+var (
+	ECIES_AES128_X963_SHA256 = &ECIESParams{
+		Hash:      sha256.New,
+		hashAlgo:  crypto.SHA256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+		kdf:       kdfX963,
+	}
+
+	ECIES_AES192_X963_SHA384 = &ECIESParams{
+		Hash:      sha512.New384,
+		hashAlgo:  crypto.SHA384,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    24,
+		kdf:       kdfX963,
+	}
+
+	ECIES_AES256_X963_SHA512 = &ECIESParams{
+		Hash:      sha512.New,
+		hashAlgo:  crypto.SHA512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+		kdf:       kdfX963,
+	}
+)
+
+// ECIES parameters of ISO/IEC 18033-2, as emitted by some smart cards and
+// HSMs: ECIES-KEM with KDF2 over the encoded ephemeral key and the shared
+// secret, and DEM1 with AES-CBC under a zero IV (SC1) and an HMAC keyed with
+// the derived MAC key as is. The shared information s2 is the DEM1 label,
+// appended to the encrypted message in the tag; s1 is appended to the KDF2
+// input and should be empty.
+var (
+	ECIES_AES128_ISO18033_SHA256 = &ECIESParams{
+		Hash:           sha256.New,
+		hashAlgo:       crypto.SHA256,
+		Cipher:         aes.NewCipher,
+		BlockSize:      aes.BlockSize,
+		KeyLen:         16,
+		EphemeralInKDF: true,
+		dem:            demAESCBC,
+		kdf:            kdfX963,
+		mac:            macHMACRaw,
+	}
+
+	ECIES_AES256_ISO18033_SHA512 = &ECIESParams{
+		Hash:           sha512.New,
+		hashAlgo:       crypto.SHA512,
+		Cipher:         aes.NewCipher,
+		BlockSize:      aes.BlockSize,
+		KeyLen:         32,
+		EphemeralInKDF: true,
+		dem:            demAESCBC,
+		kdf:            kdfX963,
+		mac:            macHMACRaw,
+	}
+)
+
+// ECIES parameters of IEEE 1363a in DHAES mode, which are those of ISO
+// 18033-2 with the length of the label s2 in the tag, and s1 as the shared
+// information of KDF2. They match the ECIESwithSHA256andAES-CBC and
+// ECIESwithSHA512andAES-CBC ciphers of Bouncy Castle given a zero nonce, and
+// 128 or 256-bit keys.
+var (
+	ECIES_AES128_DHAES_SHA256 = &ECIESParams{
+		Hash:           sha256.New,
+		hashAlgo:       crypto.SHA256,
+		Cipher:         aes.NewCipher,
+		BlockSize:      aes.BlockSize,
+		KeyLen:         16,
+		EphemeralInKDF: true,
+		MACLabelLength: true,
+		dem:            demAESCBC,
+		kdf:            kdfX963,
+		mac:            macHMACRaw,
+	}
+
+	ECIES_AES256_DHAES_SHA512 = &ECIESParams{
+		Hash:           sha512.New,
+		hashAlgo:       crypto.SHA512,
+		Cipher:         aes.NewCipher,
+		BlockSize:      aes.BlockSize,
+		KeyLen:         32,
+		EphemeralInKDF: true,
+		MACLabelLength: true,
+		dem:            demAESCBC,
+		kdf:            kdfX963,
+		mac:            macHMACRaw,
+	}
+)
+
+// ECIES parameters with the SHA-3 family hash functions (FIPS 202) instead
+// of SHA-2, for the KDF and the HMAC. SHAKE128 and SHAKE256 have a 256-bit
+// and a 512-bit output respectively.
+var (
+	ECIES_AES128_SHA3_256 = &ECIESParams{
+		Hash:      sha3.New256,
+		hashAlgo:  crypto.SHA3_256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+	}
+
+	ECIES_AES192_SHA3_384 = &ECIESParams{
+		Hash:      sha3.New384,
+		hashAlgo:  crypto.SHA3_384,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    24,
+	}
+
+	ECIES_AES256_SHA3_512 = &ECIESParams{
+		Hash:      sha3.New512,
+		hashAlgo:  crypto.SHA3_512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+	}
+
+	ECIES_AES128_SHAKE128 = &ECIESParams{
+		Hash:      NewSHAKE128,
+		hashAlgo:  hashSHAKE128,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+	}
+
+	ECIES_AES256_SHAKE256 = &ECIESParams{
+		Hash:      NewSHAKE256,
+		hashAlgo:  hashSHAKE256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+	}
+)
+
+// ECIES parameters with KMAC (NIST SP 800-185) instead of HMAC for the tag,
+// and SHA-3 for the KDF. KMAC128 and KMAC256 output 256-bit and 512-bit tags.
+var (
+	ECIES_AES128_KMAC128_SHA3_256 = &ECIESParams{
+		Hash:      sha3.New256,
+		hashAlgo:  crypto.SHA3_256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+		mac:       macKMAC128,
+	}
+
+	ECIES_AES256_KMAC256_SHA3_512 = &ECIESParams{
+		Hash:      sha3.New512,
+		hashAlgo:  crypto.SHA3_512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+		mac:       macKMAC256,
+	}
+)
+
+// ECIES parameters with AES-CMAC instead of HMAC for the tag, as permitted
+// by SEC 1 section 3.7. The CMAC key is used as derived, without the hashing
+// applied to HMAC keys, for interoperability with SEC 1 implementations.
+var (
+	ECIES_AES128_CMAC_SHA256 = &ECIESParams{
+		Hash:      sha256.New,
+		hashAlgo:  crypto.SHA256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+		mac:       macCMAC,
+	}
+
+	ECIES_AES192_CMAC_SHA384 = &ECIESParams{
+		Hash:      sha512.New384,
+		hashAlgo:  crypto.SHA384,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    24,
+		mac:       macCMAC,
+	}
+
+	ECIES_AES256_CMAC_SHA512 = &ECIESParams{
+		Hash:      sha512.New,
+		hashAlgo:  crypto.SHA512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+		mac:       macCMAC,
+	}
+)
+
+// ECIES parameters with AES-GCM as the data encapsulation mechanism, which
+// carry a single AEAD tag instead of an HMAC tag.
+var (
+	ECIES_AES128_GCM_SHA256 = &ECIESParams{
+		Hash:      sha256.New,
+		hashAlgo:  crypto.SHA256,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    16,
+		AEAD:      newAESGCM,
+		dem:       demAESGCM,
+	}
+
+	ECIES_AES192_GCM_SHA384 = &ECIESParams{
+		Hash:      sha512.New384,
+		hashAlgo:  crypto.SHA384,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    24,
+		AEAD:      newAESGCM,
+		dem:       demAESGCM,
+	}
+
+	ECIES_AES256_GCM_SHA512 = &ECIESParams{
+		Hash:      sha512.New,
+		hashAlgo:  crypto.SHA512,
+		Cipher:    aes.NewCipher,
+		BlockSize: aes.BlockSize,
+		KeyLen:    32,
+		AEAD:      newAESGCM,
+		dem:       demAESGCM,
+	}
+)
+
+// ECIES parameters with ChaCha20-Poly1305 as the data encapsulation
+// mechanism, for platforms without AES hardware. The 256-bit key is derived
+// with the hash function, which sets the security level.
+var (
+	ECIES_CHACHA20POLY1305_SHA256 = &ECIESParams{
+		Hash:     sha256.New,
+		hashAlgo: crypto.SHA256,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.New,
+		dem:      demChaCha20Poly1305,
+	}
+
+	ECIES_CHACHA20POLY1305_SHA384 = &ECIESParams{
+		Hash:     sha512.New384,
+		hashAlgo: crypto.SHA384,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.New,
+		dem:      demChaCha20Poly1305,
+	}
+
+	ECIES_CHACHA20POLY1305_SHA512 = &ECIESParams{
+		Hash:     sha512.New,
+		hashAlgo: crypto.SHA512,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.New,
+		dem:      demChaCha20Poly1305,
+	}
+)
+
+// ECIES parameters with XChaCha20-Poly1305 as the data encapsulation
+// mechanism. Its 24-byte random nonce is carried in the ciphertext like the
+// ChaCha20-Poly1305 one. There is no registered identifier for it, so these
+// parameters cannot be carried in a DER-encoded public key.
+var (
+	ECIES_XCHACHA20POLY1305_SHA256 = &ECIESParams{
+		Hash:     sha256.New,
+		hashAlgo: crypto.SHA256,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.NewX,
+		dem:      demXChaCha20Poly1305,
+	}
+
+	ECIES_XCHACHA20POLY1305_SHA384 = &ECIESParams{
+		Hash:     sha512.New384,
+		hashAlgo: crypto.SHA384,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.NewX,
+		dem:      demXChaCha20Poly1305,
+	}
+
+	ECIES_XCHACHA20POLY1305_SHA512 = &ECIESParams{
+		Hash:     sha512.New,
+		hashAlgo: crypto.SHA512,
+		KeyLen:   chacha20poly1305.KeySize,
+		AEAD:     chacha20poly1305.NewX,
+		dem:      demXChaCha20Poly1305,
+	}
+)
+
+// curvesMu guards paramsFromCurve and the curves registered by RegisterCurve.
+var curvesMu sync.RWMutex
+
 var paramsFromCurve = map[elliptic.Curve]*ECIESParams{
 	elliptic.P256(): ECIES_AES128_SHA256,
 	elliptic.P384(): ECIES_AES192_SHA384,
 	elliptic.P521(): ECIES_AES256_SHA512,
+	Secp256k1():     ECIES_AES128_SHA256,
+	SM2():           ECIES_AES128_SHA256,
+}
+
+// Default parameters of the curves without SEC 1 point encodings, which are
+// kept apart as they can't be used with the SEC 1 and ASN.1 based formats.
+var paramsFromRawCurve = map[elliptic.Curve]*ECIESParams{
+	X25519(): ECIES_AES128_SHA256,
+	X448():   ECIES_AES256_SHA512,
 }
 
 func AddParamsForCurve(curve elliptic.Curve, params *ECIESParams) {
+	curvesMu.Lock()
+	defer curvesMu.Unlock()
 	paramsFromCurve[curve] = params
 }
 
 // Select parameters optimal for the given elliptic curve.
 func ParamsFromCurve(curve elliptic.Curve) (params *ECIESParams) {
-	return paramsFromCurve[curve]
+	curvesMu.RLock()
+	params = paramsFromCurve[curve]
+	curvesMu.RUnlock()
+	if params == nil {
+		params = paramsFromRawCurve[curve]
+	}
+	return
 }
 
-// ASN.1 encode the ECIES parameters relevant to the encryption operations.
-func paramsToASNECIES(params *ECIESParams) (asnParams asnECIESParameters) {
-	if nil == params {
-		return
-	}
-	asnParams.KDF = asnNISTConcatenationKDF
-	asnParams.MAC = hmacFull
-	switch params.KeyLen {
-	case 16:
-		asnParams.Sym = aes128CTRinECIES
-	case 24:
-		asnParams.Sym = aes192CTRinECIES
-	case 32:
-		asnParams.Sym = aes256CTRinECIES
+type securitySuite struct {
+	params *ECIESParams
+	aes    bool
+}
+
+// Recommended curve and parameters for each security level, in bits. The
+// first suite of each level is the default one.
+var securityLevels = []struct {
+	bits   int
+	curve  elliptic.Curve
+	suites []securitySuite
+}{
+	{128, elliptic.P256(), []securitySuite{{ECIES_AES128_SHA256, true}, {ECIES_CHACHA20POLY1305_SHA256, false}}},
+	{192, elliptic.P384(), []securitySuite{{ECIES_AES192_SHA384, true}, {ECIES_CHACHA20POLY1305_SHA384, false}}},
+	{256, elliptic.P521(), []securitySuite{{ECIES_AES256_SHA512, true}, {ECIES_CHACHA20POLY1305_SHA512, false}}},
+}
+
+// ParamsForSecurityBits returns the recommended curve and parameters for the
+// given security level, which must be 128, 192 or 256 bits. The curve, hash
+// and cipher strengths match, as in SEC 1 section 3.
+func ParamsForSecurityBits(bits int) (elliptic.Curve, *ECIESParams, error) {
+	for _, level := range securityLevels {
+		if level.bits == bits {
+			return level.curve, level.suites[0].params, nil
+		}
 	}
-	return
+	return nil, nil, ErrInvalidParams
 }
 
-// ASN.1 encode the ECIES parameters relevant to ECDH.
-func paramsToASNECDH(params *ECIESParams) (algo asnECDHAlgorithm) {
-	switch params.hashAlgo {
+// ChooseParams is ParamsForSecurityBits, picking among the suites of the
+// security level the fastest one on this machine: AES suites are avoided
+// when AES is not accelerated in hardware, as software AES is slow and not
+// constant time, unless no other suite is available.
+func ChooseParams(bits int) (elliptic.Curve, *ECIESParams, error) {
+	for _, level := range securityLevels {
+		if level.bits != bits {
+			continue
+		}
+		if !hasAESHardware() {
+			for _, suite := range level.suites {
+				if !suite.aes {
+					return level.curve, suite.params, nil
+				}
+			}
+		}
+		return level.curve, level.suites[0].params, nil
+	}
+	return nil, nil, ErrInvalidParams
+}
+
+// hasAESHardware tells whether the CPU has AES instructions.
+func hasAESHardware() bool {
+	return cpu.X86.HasAES || cpu.ARM64.HasAES || cpu.S390X.HasAES
+}
+
+// complete reports whether the parameters have all the functions needed to
+// encrypt.
+func (params *ECIESParams) complete() bool {
+	return params.Hash != nil && params.KeyLen > 0 && (params.AEAD != nil || params.Cipher != nil)
+}
+
+// hashFunction returns the constructor of a hash of the parameters.
+func hashFunction(h crypto.Hash) func() hash.Hash {
+	switch h {
 	case crypto.SHA224:
-		algo = dhSinglePass_stdDH_sha224kdf
+		return sha256.New224
 	case crypto.SHA256:
-		algo = dhSinglePass_stdDH_sha256kdf
+		return sha256.New
 	case crypto.SHA384:
-		algo = dhSinglePass_stdDH_sha384kdf
+		return sha512.New384
 	case crypto.SHA512:
-		algo = dhSinglePass_stdDH_sha512kdf
-	}
-	return
-}
-
-// ASN.1 decode the ECIES parameters relevant to the encryption stage.
-func asnECIEStoParams(asnParams asnECIESParameters, params *ECIESParams) {
-	if !asnParams.KDF.Cmp(asnNISTConcatenationKDF) {
-		params = nil
-		return
-	} else if !asnParams.MAC.Cmp(hmacFull) {
-		params = nil
-		return
-	}
-
-	switch {
-	case asnParams.Sym.Cmp(aes128CTRinECIES):
-		params.KeyLen = 16
-		params.BlockSize = 16
-		params.Cipher = aes.NewCipher
-	case asnParams.Sym.Cmp(aes192CTRinECIES):
-		params.KeyLen = 24
-		params.BlockSize = 16
-		params.Cipher = aes.NewCipher
-	case asnParams.Sym.Cmp(aes256CTRinECIES):
-		params.KeyLen = 32
-		params.BlockSize = 16
-		params.Cipher = aes.NewCipher
-	default:
-		params = nil
+		return sha512.New
 	}
+	return sha3HashFunction(h)
 }
 
-// ASN.1 decode the ECIES parameters relevant to ECDH.
-func asnECDHtoParams(asnParams asnECDHAlgorithm, params *ECIESParams) {
-	if asnParams.Cmp(dhSinglePass_stdDH_sha224kdf) {
-		params.hashAlgo = crypto.SHA224
-		params.Hash = sha256.New224
-	} else if asnParams.Cmp(dhSinglePass_stdDH_sha256kdf) {
-		params.hashAlgo = crypto.SHA256
-		params.Hash = sha256.New
-	} else if asnParams.Cmp(dhSinglePass_stdDH_sha384kdf) {
-		params.hashAlgo = crypto.SHA384
-		params.Hash = sha512.New384
-	} else if asnParams.Cmp(dhSinglePass_stdDH_sha512kdf) {
-		params.hashAlgo = crypto.SHA512
-		params.Hash = sha512.New
-	} else {
-		params = nil
+// setHash sets the hash of params, unless another one is already set.
+func setHash(h crypto.Hash, params *ECIESParams) bool {
+	if params.Hash != nil {
+		return params.hashAlgo == h
 	}
+	params.hashAlgo = h
+	params.Hash = hashFunction(h)
+	return true
 }
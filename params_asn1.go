@@ -0,0 +1,275 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// The ASN.1 encoding of the ECIES parameters, as in SEC 1 appendix C.4, with
+// the hash algorithm identifiers it carries. It is compiled out with the
+// ecies_tiny build tag.
+
+import (
+	"crypto"
+	"crypto/aes"
+	"encoding/asn1"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Hash algorithm identifiers of the SHA-2 family, see RFC 5754 section 2.
+var sha2HashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA224: {2, 16, 840, 1, 101, 3, 4, 2, 4},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+// NIST hash algorithm identifiers for the SHA-3 family, see RFC 8702 and
+// the NIST Computer Security Objects Register.
+var sha3HashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA3_256: {2, 16, 840, 1, 101, 3, 4, 2, 8},
+	crypto.SHA3_384: {2, 16, 840, 1, 101, 3, 4, 2, 9},
+	crypto.SHA3_512: {2, 16, 840, 1, 101, 3, 4, 2, 10},
+	hashSHAKE128:    {2, 16, 840, 1, 101, 3, 4, 2, 11},
+	hashSHAKE256:    {2, 16, 840, 1, 101, 3, 4, 2, 12},
+}
+
+// hashAlgorithm returns the DER-encoded AlgorithmIdentifier of h, which SEC 1
+// carries as the parameters of the KDF and HMAC identifiers, or nothing if h
+// has no identifier.
+func hashAlgorithm(h crypto.Hash) asn1.RawValue {
+	oid, ok := sha2HashOIDs[h]
+	if !ok {
+		if oid, ok = sha3HashOIDs[h]; !ok {
+			return asn1.RawValue{}
+		}
+	}
+	der, err := asn1.Marshal(asnAlgorithmIdentifier{Algorithm: oid})
+	if err != nil {
+		return asn1.RawValue{}
+	}
+	return asn1.RawValue{FullBytes: der}
+}
+
+// setHashAlgorithm sets the hash of params from the AlgorithmIdentifier in
+// parameters, which encodings older than the hash parameters leave out. It
+// reports false for unknown hashes, and hashes other than the one already
+// set, as the KDF and MAC share their hash.
+func setHashAlgorithm(parameters asn1.RawValue, params *ECIESParams) bool {
+	if len(parameters.FullBytes) == 0 {
+		return true
+	}
+	var alg asnAlgorithmIdentifier
+	if rest, err := asn1.Unmarshal(parameters.FullBytes, &alg); err != nil || len(rest) != 0 {
+		return false
+	}
+	for _, oids := range []map[crypto.Hash]asn1.ObjectIdentifier{sha2HashOIDs, sha3HashOIDs} {
+		for h, oid := range oids {
+			if alg.Algorithm.Equal(oid) {
+				return setHash(h, params)
+			}
+		}
+	}
+	return false
+}
+
+// ASN.1 encode the ECIES parameters relevant to the encryption operations.
+// The NIST and X9.63 KDFs and HMAC carry the hash function as parameters,
+// as in SEC 1 section C.4.
+func paramsToASNECIES(params *ECIESParams) (asnParams asnECIESParameters) {
+	if nil == params {
+		return
+	}
+	asnParams.KDF = asnNISTConcatenationKDF
+	if params.kdf == kdfX963 {
+		asnParams.KDF = asnX963KDF
+	}
+	asnParams.KDF.Parameters = hashAlgorithm(params.hashAlgo)
+	if params.kdf == kdfHKDF {
+		switch params.hashAlgo {
+		case crypto.SHA256:
+			asnParams.KDF = hkdfWithSHA256
+		case crypto.SHA384:
+			asnParams.KDF = hkdfWithSHA384
+		case crypto.SHA512:
+			asnParams.KDF = hkdfWithSHA512
+		}
+	}
+	if params.dem == demAESGCM {
+		switch params.KeyLen {
+		case 16:
+			asnParams.Sym = aes128GCM
+		case 24:
+			asnParams.Sym = aes192GCM
+		case 32:
+			asnParams.Sym = aes256GCM
+		}
+		asnParams.MAC = asnMessageAuthenticationCode(asnParams.Sym)
+		return
+	}
+	if params.dem == demChaCha20Poly1305 {
+		asnParams.Sym = chacha20Poly1305
+		asnParams.MAC = asnMessageAuthenticationCode(asnParams.Sym)
+		return
+	}
+	switch params.mac {
+	case macKMAC128:
+		asnParams.MAC = kmacWithSHAKE128
+	case macKMAC256:
+		asnParams.MAC = kmacWithSHAKE256
+	case macCMAC:
+		switch params.KeyLen {
+		case 16:
+			asnParams.MAC = cmacAES128
+		case 24:
+			asnParams.MAC = cmacAES192
+		case 32:
+			asnParams.MAC = cmacAES256
+		}
+	default:
+		asnParams.MAC = hmacFull
+		asnParams.MAC.Parameters = hashAlgorithm(params.hashAlgo)
+	}
+	switch params.KeyLen {
+	case 16:
+		asnParams.Sym = aes128CTRinECIES
+	case 24:
+		asnParams.Sym = aes192CTRinECIES
+	case 32:
+		asnParams.Sym = aes256CTRinECIES
+	}
+	return
+}
+
+// ASN.1 encode the ECIES parameters relevant to ECDH.
+func paramsToASNECDH(params *ECIESParams) (algo asnECDHAlgorithm) {
+	switch params.hashAlgo {
+	case crypto.SHA224:
+		algo = dhSinglePass_stdDH_sha224kdf
+	case crypto.SHA256:
+		algo = dhSinglePass_stdDH_sha256kdf
+	case crypto.SHA384:
+		algo = dhSinglePass_stdDH_sha384kdf
+	case crypto.SHA512:
+		algo = dhSinglePass_stdDH_sha512kdf
+	default:
+		oid, ok := sha3HashOIDs[params.hashAlgo]
+		if !ok {
+			return
+		}
+		hashAlg, err := asn1.Marshal(asnAlgorithmIdentifier{Algorithm: oid})
+		if err != nil {
+			return
+		}
+		kdf := asnNISTConcatenationKDF
+		if params.kdf == kdfX963 {
+			kdf = asnX963KDF
+		}
+		kdf.Parameters = asn1.RawValue{FullBytes: hashAlg}
+		kdfAlg, err := asn1.Marshal(kdf)
+		if err != nil {
+			return
+		}
+		algo = ecdhWithKDF
+		algo.Parameters = asn1.RawValue{FullBytes: kdfAlg}
+	}
+	return
+}
+
+// ASN.1 decode the ECIES parameters relevant to the encryption stage. It
+// reports false if they can't be decoded in full, or don't agree with the
+// hash already decoded from the ECDH algorithm.
+func asnECIEStoParams(asnParams asnECIESParameters, params *ECIESParams) bool {
+	switch {
+	case asnParams.KDF.Cmp(asnNISTConcatenationKDF):
+	case asnParams.KDF.Cmp(asnX963KDF):
+		params.kdf = kdfX963
+	case asnParams.KDF.Cmp(hkdfWithSHA256):
+		params.kdf = kdfHKDF
+		return setHash(crypto.SHA256, params) && asnDEMtoParams(asnParams, params)
+	case asnParams.KDF.Cmp(hkdfWithSHA384):
+		params.kdf = kdfHKDF
+		return setHash(crypto.SHA384, params) && asnDEMtoParams(asnParams, params)
+	case asnParams.KDF.Cmp(hkdfWithSHA512):
+		params.kdf = kdfHKDF
+		return setHash(crypto.SHA512, params) && asnDEMtoParams(asnParams, params)
+	default:
+		return false
+	}
+	return setHashAlgorithm(asnParams.KDF.Parameters, params) && asnDEMtoParams(asnParams, params)
+}
+
+// asnDEMtoParams decodes the symmetric encryption and MAC of the parameters.
+func asnDEMtoParams(asnParams asnECIESParameters, params *ECIESParams) bool {
+	for keyLen, sym := range map[int]asnSymmetricEncryption{16: aes128GCM, 24: aes192GCM, 32: aes256GCM} {
+		if asnParams.Sym.Cmp(sym) && asnParams.MAC.Cmp(asnMessageAuthenticationCode(sym)) {
+			params.KeyLen = keyLen
+			params.BlockSize = aes.BlockSize
+			params.Cipher = aes.NewCipher
+			params.AEAD = newAESGCM
+			params.dem = demAESGCM
+			return true
+		}
+	}
+	if asnParams.Sym.Cmp(chacha20Poly1305) && asnParams.MAC.Cmp(asnMessageAuthenticationCode(chacha20Poly1305)) {
+		params.KeyLen = chacha20poly1305.KeySize
+		params.AEAD = chacha20poly1305.New
+		params.dem = demChaCha20Poly1305
+		return true
+	}
+
+	switch {
+	case asnParams.Sym.Cmp(aes128CTRinECIES):
+		params.KeyLen = 16
+	case asnParams.Sym.Cmp(aes192CTRinECIES):
+		params.KeyLen = 24
+	case asnParams.Sym.Cmp(aes256CTRinECIES):
+		params.KeyLen = 32
+	default:
+		return false
+	}
+	params.BlockSize = aes.BlockSize
+	params.Cipher = aes.NewCipher
+
+	switch {
+	case asnParams.MAC.Cmp(hmacFull):
+		return setHashAlgorithm(asnParams.MAC.Parameters, params)
+	case asnParams.MAC.Cmp(kmacWithSHAKE128):
+		params.mac = macKMAC128
+	case asnParams.MAC.Cmp(kmacWithSHAKE256):
+		params.mac = macKMAC256
+	case asnParams.MAC.Cmp(cmacAES128):
+		params.mac = macCMAC
+		return params.KeyLen == 16
+	case asnParams.MAC.Cmp(cmacAES192):
+		params.mac = macCMAC
+		return params.KeyLen == 24
+	case asnParams.MAC.Cmp(cmacAES256):
+		params.mac = macCMAC
+		return params.KeyLen == 32
+	default:
+		return false
+	}
+	return true
+}
+
+// ASN.1 decode the ECIES parameters relevant to ECDH. It reports false if
+// they can't be decoded.
+func asnECDHtoParams(asnParams asnECDHAlgorithm, params *ECIESParams) bool {
+	switch {
+	case asnParams.Cmp(dhSinglePass_stdDH_sha224kdf):
+		return setHash(crypto.SHA224, params)
+	case asnParams.Cmp(dhSinglePass_stdDH_sha256kdf):
+		return setHash(crypto.SHA256, params)
+	case asnParams.Cmp(dhSinglePass_stdDH_sha384kdf):
+		return setHash(crypto.SHA384, params)
+	case asnParams.Cmp(dhSinglePass_stdDH_sha512kdf):
+		return setHash(crypto.SHA512, params)
+	case asnParams.Cmp(ecdhWithKDF):
+		var kdf asnKeyDerivationFunction
+		if _, err := asn1.Unmarshal(asnParams.Parameters.FullBytes, &kdf); err != nil {
+			return false
+		}
+		return len(kdf.Parameters.FullBytes) != 0 && setHashAlgorithm(kdf.Parameters, params)
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that the parameters of the standard suites survive the DER encoding
+// of public keys, with or without the hash parameters of the KDF and HMAC
+// identifiers, and that conflicting hashes aren't decoded.
+func TestParamsASN1(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, s := range SupportedSuites() {
+		pub := prv.PublicKey
+		pub.Params = s.Params
+		subj, err := marshalSubjectPublicKeyInfo(&pub)
+		if errors.Is(err, ErrUnsupportedECIESParameters) {
+			continue
+		} else if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		// Keys encoded before the hash parameters were added.
+		legacy := subj
+		legacy.Supplements.ECCAlgorithms.ECIES.KDF.Parameters = asn1.RawValue{}
+		legacy.Supplements.ECCAlgorithms.ECIES.MAC.Parameters = asn1.RawValue{}
+		for _, in := range []asnSubjectPublicKeyInfo{subj, legacy} {
+			der, err := asn1.Marshal(in)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			decoded, err := UnmarshalPublicStrict(der)
+			if err != nil {
+				fmt.Println(s.Name, err.Error())
+				t.FailNow()
+			}
+			if id, ok := suiteID(decoded.Params); !ok || id != s.ID {
+				fmt.Println("ecies: parameters lost in DER for", s.Name)
+				t.FailNow()
+			}
+		}
+	}
+
+	pub := prv.PublicKey
+	pub.Params = ECIES_AES256_SHA512
+	subj, err := marshalSubjectPublicKeyInfo(&pub)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	subj.Supplements.ECCAlgorithms.ECIES.MAC.Parameters = hashAlgorithm(ECIES_AES128_SHA256.hashAlgo)
+	der, err := asn1.Marshal(subj)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = UnmarshalPublicStrict(der); !errors.Is(err, ErrUnsupportedECIESParameters) {
+		fmt.Println("ecies: decoded conflicting hash parameters:", err)
+		t.FailNow()
+	}
+	if decoded, err := UnmarshalPublic(der); err != nil || decoded.Params != ParamsFromCurve(elliptic.P384()) {
+		fmt.Println("ecies: conflicting hash parameters not replaced by the defaults:", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,88 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrInvalidPassword = newError(KindAuthentication, "ecies: invalid password, salt or password hashing parameters")
+
+// PasswordParams selects the password hashing function used by GenerateKeyFromPassword.
+// It is implemented by ScryptParams and Argon2idParams.
+type PasswordParams interface {
+	deriveSeed(password, salt []byte, size int) ([]byte, error)
+}
+
+// ScryptParams are the scrypt (RFC 7914) cost parameters.
+type ScryptParams struct {
+	N int // CPU/memory cost, a power of two
+	R int // block size
+	P int // parallelization
+}
+
+// Argon2idParams are the Argon2id (RFC 9106) cost parameters.
+type Argon2idParams struct {
+	Time    uint32 // number of passes
+	Memory  uint32 // memory size in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// Recommended password hashing parameters for interactive use.
+var (
+	DefaultScryptParams   = &ScryptParams{N: 1 << 15, R: 8, P: 1}
+	DefaultArgon2idParams = &Argon2idParams{Time: 1, Memory: 64 * 1024, Threads: 4}
+)
+
+func (p *ScryptParams) deriveSeed(password, salt []byte, size int) ([]byte, error) {
+	seed, err := scrypt.Key(password, salt, p.N, p.R, p.P, size)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return seed, nil
+}
+
+func (p *Argon2idParams) deriveSeed(password, salt []byte, size int) ([]byte, error) {
+	if p.Time == 0 || p.Threads == 0 {
+		return nil, ErrInvalidPassword
+	}
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, uint32(size)), nil
+}
+
+// GenerateKeyFromPassword deterministically derives an elliptic curve keypair from
+// a password and a salt, with the default parameters for the curve.
+// The same password, salt, hashing parameters and curve always produce the same key.
+//
+// The salt should be unique per key (e.g. a user or device identifier) so that
+// identical passwords don't produce identical keys. If kdf is nil, the
+// DefaultArgon2idParams are used.
+func GenerateKeyFromPassword(password, salt []byte, kdf PasswordParams, curve elliptic.Curve) (*PrivateKey, error) {
+	if len(password) == 0 || len(salt) == 0 {
+		return nil, ErrInvalidPassword
+	}
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if kdf == nil {
+		kdf = DefaultArgon2idParams
+	}
+	// X25519 and X448 keys are the raw bytes themselves, of fixed length.
+	c, raw := curve.(rawPointCurve)
+	size := scalarSeedSize(curve)
+	if raw {
+		size = c.pointLen()
+	}
+	seed, err := kdf.deriveSeed(password, salt, size)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(seed)
+	if raw {
+		return NewPrivateKey(curve, seed)
+	}
+	return NewPrivateKey(curve, scalarFromSeed(curve, seed).Bytes())
+}
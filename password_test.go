@@ -0,0 +1,123 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Cheap password hashing parameters, so the tests run fast.
+var testPasswordParams = []PasswordParams{
+	&ScryptParams{N: 1 << 10, R: 8, P: 1},
+	&Argon2idParams{Time: 1, Memory: 1024, Threads: 1},
+}
+
+// Ensure that password-derived keys are deterministic and usable.
+func TestGenerateKeyFromPassword(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	salt := []byte("device-0001")
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		for _, kdf := range testPasswordParams {
+			prv1, err := GenerateKeyFromPassword(password, salt, kdf, c)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			prv2, err := GenerateKeyFromPassword(password, salt, kdf, c)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if !cmpPrivate(prv1, prv2) {
+				fmt.Println(name, "ecies: password-derived key is not deterministic")
+				t.FailNow()
+			}
+			prv3, err := GenerateKeyFromPassword(password, []byte("device-0002"), kdf, c)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if cmpPrivate(prv1, prv3) {
+				fmt.Println(name, "ecies: password-derived key ignores the salt")
+				t.FailNow()
+			}
+
+			message := []byte("Hello, world.")
+			ct, err := Encrypt(rand.Reader, &prv1.PublicKey, message, nil, nil)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			pt, err := Decrypt(prv2, ct, nil, nil)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			} else if !bytes.Equal(pt, message) {
+				fmt.Println(name, "ecies: plaintext doesn't match message")
+				t.FailNow()
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		Password []byte
+		Salt     []byte
+		KDF      PasswordParams
+	}{
+		{nil, salt, nil},
+		{password, nil, nil},
+		{password, salt, &ScryptParams{N: 1000, R: 8, P: 1}},
+		{password, salt, &Argon2idParams{Time: 0, Memory: 1024, Threads: 1}},
+	} {
+		if _, err := GenerateKeyFromPassword(c.Password, c.Salt, c.KDF, DefaultCurve); err != ErrInvalidPassword {
+			fmt.Println("ecies: accepted invalid password parameters", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that X25519 and X448 keys, which paramsFromCurve doesn't list, are
+// derived from every password: their raw bytes may start with zeros.
+func TestGenerateKeyFromPasswordRaw(t *testing.T) {
+	kdf := &Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+	for _, c := range []elliptic.Curve{X25519(), X448()} {
+		for i := 0; i < 64; i++ {
+			salt := []byte(fmt.Sprintf("device-%04d", i))
+			prv, err := GenerateKeyFromPassword([]byte("correct horse battery staple"), salt, kdf, c)
+			if err != nil {
+				fmt.Println(c.Params().Name, string(salt), err.Error())
+				t.FailNow()
+			}
+			prv2, err := GenerateKeyFromPassword([]byte("correct horse battery staple"), salt, kdf, c)
+			if err != nil || !cmpPrivate(prv, prv2) {
+				fmt.Println(c.Params().Name, "ecies: password-derived key is not deterministic", err)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure that the password-to-key derivation doesn't change, as keys can't be
+// recovered otherwise.
+func TestVectorGenerateKeyFromPassword(t *testing.T) {
+	expected := []string{
+		"20ce5f6fb680ec762b7d813cf25a67f19d4c03fa423aae72da2dc822affa50f4",
+		"813d7d44ee95e58e7aa6c981f41edc5614ba649fb02b32e3d6b70b215c60e360",
+	}
+	for i, kdf := range testPasswordParams {
+		prv, err := GenerateKeyFromPassword([]byte("correct horse battery staple"), []byte("device-0001"), kdf, DefaultCurve)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if d := hex.EncodeToString(prv.D.Bytes()); d != expected[i] {
+			fmt.Println("ecies: password-derived key doesn't match vector", d)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,270 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// RFC 8018, appendices A.2, A.4 and B.1
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	// RFC 7914, section 7
+	oidScrypt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	// NIST CSOR
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// asnEncryptedPKCS8 represents the EncryptedPrivateKeyInfo structure of
+// RFC 5208, section 6.
+type asnEncryptedPKCS8 struct {
+	Algorithm     asnAlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type asnPBES2Params struct {
+	KeyDerivationFunc asnAlgorithmIdentifier
+	EncryptionScheme  asnAlgorithmIdentifier
+}
+
+type asnPBKDF2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                    `asn1:"optional"`
+	PRF        asnAlgorithmIdentifier `asn1:"optional"`
+}
+
+type asnScryptParams struct {
+	Salt            []byte
+	CostParameter   int
+	BlockSize       int
+	Parallelization int
+	KeyLength       int `asn1:"optional"`
+}
+
+// PEMEncryptionOptions select the key derivation of
+// ExportPrivatePEMEncrypted.
+type PEMEncryptionOptions struct {
+	// Scrypt selects scrypt with the given cost as key derivation function.
+	// It is not supported by every PKCS #8 implementation, e.g. Java.
+	Scrypt *ScryptParams
+	// PBKDF2Iterations is the iteration count of PBKDF2-HMAC-SHA256, used
+	// if Scrypt is nil. It defaults to 600000.
+	PBKDF2Iterations int
+}
+
+const defaultPBKDF2Iterations = 600000
+
+// Export a private key to an "ENCRYPTED PRIVATE KEY" PEM block: the PKCS #8
+// encoding of the key, encrypted with PBES2 (RFC 8018) and AES-256-CBC under
+// a key derived from the passphrase. If opts is nil, the key is derived with
+// PBKDF2-HMAC-SHA256, as OpenSSL, Java and most PKCS #8 implementations can.
+func ExportPrivatePEMEncrypted(rand io.Reader, prv *PrivateKey, passphrase []byte, opts *PEMEncryptionOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &PEMEncryptionOptions{}
+	}
+	der, err := MarshalPrivatePKCS8(prv)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err = io.ReadFull(rand, salt); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(rand, iv); err != nil {
+		return nil, err
+	}
+
+	var kdf asnAlgorithmIdentifier
+	var key, params []byte
+	if opts.Scrypt != nil {
+		if key, err = opts.Scrypt.deriveSeed(passphrase, salt, 32); err != nil {
+			return nil, err
+		}
+		kdf.Algorithm = oidScrypt
+		params, err = asn1.Marshal(asnScryptParams{
+			Salt:            salt,
+			CostParameter:   opts.Scrypt.N,
+			BlockSize:       opts.Scrypt.R,
+			Parallelization: opts.Scrypt.P,
+		})
+	} else {
+		iter := opts.PBKDF2Iterations
+		if iter <= 0 {
+			iter = defaultPBKDF2Iterations
+		}
+		key = pbkdf2.Key(passphrase, salt, iter, 32, sha256.New)
+		kdf.Algorithm = oidPBKDF2
+		params, err = asn1.Marshal(asnPBKDF2Params{
+			Salt:       salt,
+			Iterations: iter,
+			PRF: asnAlgorithmIdentifier{
+				Algorithm:  oidHMACWithSHA256,
+				Parameters: asn1.RawValue{FullBytes: asn1.NullBytes},
+			},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	kdf.Parameters = asn1.RawValue{FullBytes: params}
+
+	block, _ := aes.NewCipher(key)
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	data := append(der, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	pbes2, err := asn1.Marshal(asnPBES2Params{
+		KeyDerivationFunc: kdf,
+		EncryptionScheme: asnAlgorithmIdentifier{
+			Algorithm:  oidAES256CBC,
+			Parameters: asn1.RawValue{FullBytes: ivParams},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := asn1.Marshal(asnEncryptedPKCS8{
+		Algorithm: asnAlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: pbes2},
+		},
+		EncryptedData: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: out}), nil
+}
+
+// Import a private key from an "ENCRYPTED PRIVATE KEY" PEM block, as written
+// by ExportPrivatePEMEncrypted or OpenSSL, or from any of the blocks accepted
+// by ImportPrivatePEM. PBES2 with PBKDF2 or scrypt, and AES-CBC, are
+// supported.
+func ImportPrivatePEMEncrypted(in []byte, passphrase []byte) (*PrivateKey, error) {
+	return ImportPrivatePEMWithOptions(in, &PEMOptions{Password: passphrase})
+}
+
+// decryptPKCS8 decrypts an EncryptedPrivateKeyInfo and parses the PKCS #8
+// key it holds.
+func decryptPKCS8(der, passphrase []byte) (*PrivateKey, error) {
+	var enc asnEncryptedPKCS8
+	if rest, err := asn1.Unmarshal(der, &enc); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPrivateKey, err)
+	}
+	if !enc.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, ErrUnsupportedPEMCipher
+	}
+	var params asnPBES2Params
+	if rest, err := asn1.Unmarshal(enc.Algorithm.Parameters.FullBytes, &params); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPrivateKey, err)
+	}
+
+	var keyLen int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLen = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, ErrUnsupportedPEMCipher
+	}
+	var iv []byte
+	if rest, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(rest) != 0 || len(iv) != aes.BlockSize {
+		return nil, ErrInvalidPrivateKey
+	}
+	key, err := pbes2Key(params.KeyDerivationFunc, passphrase, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	data := enc.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	block, _ := aes.NewCipher(key)
+	data = append([]byte(nil), data...)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize ||
+		subtle.ConstantTimeCompare(data[len(data)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) != 1 {
+		return nil, ErrInvalidPassword
+	}
+	prv, err := UnmarshalPrivatePKCS8(data[:len(data)-padding])
+	if err != nil {
+		// A wrong passphrase may still get through the padding check.
+		return nil, ErrInvalidPassword
+	}
+	return prv, nil
+}
+
+// pbes2Key derives the encryption key with the PBES2 key derivation function
+// kdf.
+func pbes2Key(kdf asnAlgorithmIdentifier, passphrase []byte, keyLen int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params asnPBKDF2Params
+		if rest, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil || len(rest) != 0 {
+			return nil, ErrInvalidPrivateKey
+		}
+		if params.Iterations <= 0 || (params.KeyLength != 0 && params.KeyLength != keyLen) {
+			return nil, ErrInvalidPrivateKey
+		}
+		var h func() hash.Hash
+		switch prf := params.PRF.Algorithm; {
+		case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+			h = sha1.New
+		case prf.Equal(oidHMACWithSHA256):
+			h = sha256.New
+		case prf.Equal(oidHMACWithSHA384):
+			h = sha512.New384
+		case prf.Equal(oidHMACWithSHA512):
+			h = sha512.New
+		default:
+			return nil, ErrUnsupportedPEMCipher
+		}
+		return pbkdf2.Key(passphrase, params.Salt, params.Iterations, keyLen, h), nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params asnScryptParams
+		if rest, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil || len(rest) != 0 {
+			return nil, ErrInvalidPrivateKey
+		}
+		if params.KeyLength != 0 && params.KeyLength != keyLen {
+			return nil, ErrInvalidPrivateKey
+		}
+		key, err := scrypt.Key(passphrase, params.Salt, params.CostParameter, params.BlockSize, params.Parallelization, keyLen)
+		if err != nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		return key, nil
+	}
+	return nil, ErrUnsupportedPEMCipher
+}
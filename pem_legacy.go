@@ -0,0 +1,89 @@
+//go:build !ecies_nolegacy && !ecies_tiny
+// +build !ecies_nolegacy,!ecies_tiny
+
+package ecies
+
+// Read-only support for the legacy OpenSSL PEM encryption, as written by
+// e.g. `openssl ec -aes128`. Its key derivation is a single MD5 iteration
+// (EVP_BytesToKey), so it is only enabled on request, and compiled out with
+// the ecies_nolegacy build tag.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+)
+
+type legacyPEMCipher struct {
+	name   string
+	keyLen int
+	block  func(key []byte) (cipher.Block, error)
+}
+
+var legacyPEMCiphers = []legacyPEMCipher{
+	{"AES-128-CBC", 16, aes.NewCipher},
+	{"AES-192-CBC", 24, aes.NewCipher},
+	{"AES-256-CBC", 32, aes.NewCipher},
+	{"DES-EDE3-CBC", 24, des.NewTripleDESCipher},
+	{"DES-CBC", 8, des.NewCipher},
+}
+
+// decryptLegacyPEM returns the decrypted copy of an encrypted PEM block.
+func decryptLegacyPEM(p *pem.Block, password []byte) (*pem.Block, error) {
+	dek := strings.SplitN(p.Headers["DEK-Info"], ",", 2)
+	if len(dek) != 2 {
+		return nil, ErrInvalidPrivateKey
+	}
+	var alg *legacyPEMCipher
+	for i := range legacyPEMCiphers {
+		if legacyPEMCiphers[i].name == strings.TrimSpace(dek[0]) {
+			alg = &legacyPEMCiphers[i]
+		}
+	}
+	if alg == nil {
+		return nil, ErrUnsupportedPEMCipher
+	}
+	iv, err := hex.DecodeString(strings.TrimSpace(dek[1]))
+	if err != nil || len(iv) < 8 {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	block, err := alg.block(evpBytesToKey(password, iv[:8], alg.keyLen))
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(iv) != bs || len(p.Bytes) == 0 || len(p.Bytes)%bs != 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	der := make([]byte, len(p.Bytes))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(der, p.Bytes)
+	pad := int(der[len(der)-1])
+	if pad == 0 || pad > bs {
+		return nil, ErrInvalidPassword
+	}
+	for _, b := range der[len(der)-pad:] {
+		if int(b) != pad {
+			return nil, ErrInvalidPassword
+		}
+	}
+	return &pem.Block{Type: p.Type, Bytes: der[:len(der)-pad]}, nil
+}
+
+// evpBytesToKey is OpenSSL's EVP_BytesToKey with MD5 and one iteration.
+func evpBytesToKey(password, salt []byte, keyLen int) []byte {
+	var key, prev []byte
+	for len(key) < keyLen {
+		h := md5.New()
+		h.Write(prev)
+		h.Write(password)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		key = append(key, prev...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,11 @@
+//go:build ecies_nolegacy && !ecies_tiny
+// +build ecies_nolegacy,!ecies_tiny
+
+package ecies
+
+import "encoding/pem"
+
+// The legacy OpenSSL PEM encryption is compiled out.
+func decryptLegacyPEM(p *pem.Block, password []byte) (*pem.Block, error) {
+	return nil, ErrLegacyPEMEncrypted
+}
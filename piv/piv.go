@@ -0,0 +1,357 @@
+// Package piv implements an ecies.KeyProvider for EC keys held in a PIV slot
+// of a YubiKey, by default the key management slot 9d, using the ECDH
+// operation of the GENERAL AUTHENTICATE command. The private key never
+// leaves the token.
+//
+// The commands are sent through a Card, so that any PC/SC binding can be
+// used, e.g. *scard.Card from github.com/ebfe/scard.
+package piv
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+var (
+	ErrKeyNotFound     = fmt.Errorf("piv: no key in slot")
+	ErrNotECKey        = fmt.Errorf("piv: not an EC key")
+	ErrPINRequired     = fmt.Errorf("piv: PIN required")
+	ErrInvalidPIN      = fmt.Errorf("piv: invalid PIN")
+	ErrPINBlocked      = fmt.Errorf("piv: PIN blocked")
+	ErrAuthRequired    = fmt.Errorf("piv: PIN or touch required")
+	ErrUnsupported     = fmt.Errorf("piv: metadata not supported, firmware 5.3 or later is required")
+	ErrInvalidResponse = fmt.Errorf("piv: invalid response")
+)
+
+// Card transmits the command APDUs to a smart card and returns the response
+// APDUs, including the status words.
+type Card interface {
+	Transmit(apdu []byte) ([]byte, error)
+}
+
+// Slot is a PIV key slot.
+type Slot byte
+
+const (
+	SlotAuthentication     Slot = 0x9a
+	SlotSignature          Slot = 0x9c
+	SlotKeyManagement      Slot = 0x9d
+	SlotCardAuthentication Slot = 0x9e
+)
+
+// PINPolicy tells when the PIN must be verified for the use of a key.
+type PINPolicy byte
+
+const (
+	PINPolicyNever  PINPolicy = 1
+	PINPolicyOnce   PINPolicy = 2
+	PINPolicyAlways PINPolicy = 3
+)
+
+// TouchPolicy tells when the token must be touched for the use of a key.
+type TouchPolicy byte
+
+const (
+	TouchPolicyNever  TouchPolicy = 1
+	TouchPolicyAlways TouchPolicy = 2
+	TouchPolicyCached TouchPolicy = 3
+)
+
+// Config selects the slot of a Key and how its uses are authorized.
+type Config struct {
+	// Slot is the key slot; it defaults to SlotKeyManagement.
+	Slot Slot
+	// PIN is the PIV PIN, required unless the PIN policy of the key is
+	// PINPolicyNever.
+	PIN string
+	// Touch is called before each key agreement of a key whose touch
+	// policy is not TouchPolicyNever, e.g. to prompt the operator.
+	Touch func()
+}
+
+const (
+	algP256 = 0x11
+	algP384 = 0x14
+)
+
+// Key is a KeyProvider for an EC key in a PIV slot. The commands are sent
+// one at a time, so it is safe for concurrent use.
+type Key struct {
+	card  Card
+	cfg   Config
+	alg   byte
+	pin   PINPolicy
+	touch TouchPolicy
+	pub   *ecies.PublicKey
+
+	mu       sync.Mutex
+	verified bool
+}
+
+// New selects the PIV application of card and returns the key of the
+// configured slot, as described by its metadata.
+func New(card Card, cfg Config) (*Key, error) {
+	if cfg.Slot == 0 {
+		cfg.Slot = SlotKeyManagement
+	}
+	k := &Key{card: card, cfg: cfg}
+	if err := k.selectApplet(); err != nil {
+		return nil, err
+	}
+	if err := k.loadMetadata(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+var aidPIV = []byte{0xa0, 0x00, 0x00, 0x03, 0x08}
+
+func (k *Key) selectApplet() error {
+	apdu := append([]byte{0x00, 0xa4, 0x04, 0x00, byte(len(aidPIV))}, aidPIV...)
+	_, err := k.transmit(apdu)
+	return mapError(err)
+}
+
+// loadMetadata reads the algorithm, the policies and the public key of the
+// slot with the YubiKey GET METADATA command.
+func (k *Key) loadMetadata() error {
+	rsp, err := k.transmit([]byte{0x00, 0xf7, 0x00, byte(k.cfg.Slot), 0x00})
+	if err != nil {
+		return mapError(err)
+	}
+	tags, err := parseTLVs(rsp)
+	if err != nil {
+		return err
+	}
+	var curve elliptic.Curve
+	switch alg := tags[0x01]; {
+	case len(alg) != 1:
+		return ErrKeyNotFound
+	case alg[0] == algP256:
+		curve = elliptic.P256()
+	case alg[0] == algP384:
+		curve = elliptic.P384()
+	default:
+		return ErrNotECKey
+	}
+	k.alg = tags[0x01][0]
+	// The default policies of the slots are those of the YubiKey.
+	k.pin, k.touch = PINPolicyOnce, TouchPolicyNever
+	if k.cfg.Slot == SlotCardAuthentication {
+		k.pin = PINPolicyNever
+	}
+	if policy := tags[0x02]; len(policy) == 2 {
+		if policy[0] != 0 {
+			k.pin = PINPolicy(policy[0])
+		}
+		if policy[1] != 0 {
+			k.touch = TouchPolicy(policy[1])
+		}
+	}
+	pub, err := parseTLVs(tags[0x04])
+	if err != nil {
+		return err
+	}
+	k.pub, err = ecies.NewPublicKeyFromBytes(curve, pub[0x86])
+	return err
+}
+
+// Public returns the public key of the slot.
+func (k *Key) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+// Policies returns the PIN and touch policies of the key.
+func (k *Key) Policies() (PINPolicy, TouchPolicy) {
+	return k.pin, k.touch
+}
+
+// GenerateShared computes the shared secret with pub in the token, verifying
+// the PIN as required by the PIN policy of the key.
+func (k *Key) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	if pub.Curve != k.pub.Curve {
+		return nil, ecies.ErrInvalidCurve
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var z []byte
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		if err = k.authorize(); err != nil {
+			return nil, err
+		}
+		if k.touch != TouchPolicyNever && k.cfg.Touch != nil {
+			k.cfg.Touch()
+		}
+		z, err = k.ecdh(pub)
+		if !isSecurityError(err) || k.pin != PINPolicyOnce || !k.verified {
+			break
+		}
+		// The PIN verification was lost, e.g. the token was reset or
+		// another application selected: retry once, verifying it again.
+		k.verified = false
+		if err := k.selectApplet(); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return z, nil
+}
+
+// authorize verifies the PIN if the PIN policy requires it.
+func (k *Key) authorize() error {
+	switch {
+	case k.pin == PINPolicyNever:
+		return nil
+	case k.pin == PINPolicyOnce && k.verified:
+		return nil
+	case k.cfg.PIN == "":
+		return ErrPINRequired
+	case len(k.cfg.PIN) > 8:
+		return ErrInvalidPIN
+	}
+	apdu := []byte{0x00, 0x20, 0x00, 0x80, 0x08}
+	apdu = append(apdu, k.cfg.PIN...)
+	for len(apdu) < 13 {
+		apdu = append(apdu, 0xff)
+	}
+	if _, err := k.transmit(apdu); err != nil {
+		return mapError(err)
+	}
+	k.verified = true
+	return nil
+}
+
+// ecdh sends the peer point in a GENERAL AUTHENTICATE command, which returns
+// the X coordinate of the shared point.
+func (k *Key) ecdh(pub *ecies.PublicKey) ([]byte, error) {
+	point := elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+	data := append([]byte{0x82, 0x00}, tlv(0x85, point)...)
+	data = tlv(0x7c, data)
+	apdu := append([]byte{0x00, 0x87, k.alg, byte(k.cfg.Slot), byte(len(data))}, data...)
+	apdu = append(apdu, 0x00)
+	rsp, err := k.transmit(apdu)
+	if err != nil {
+		return nil, err
+	}
+	outer, err := parseTLVs(rsp)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := parseTLVs(outer[0x7c])
+	if err != nil {
+		return nil, err
+	}
+	z := inner[0x82]
+	if len(z) != (pub.Curve.Params().BitSize+7)/8 {
+		return nil, ecies.ErrSharedKeyIsPointAtInfinity
+	}
+	return z, nil
+}
+
+// statusError is the status words of a failed command.
+type statusError uint16
+
+func (sw statusError) Error() string {
+	return fmt.Sprintf("piv: smart card error %04x", uint16(sw))
+}
+
+// transmit sends a command and returns its response data, fetching the
+// remaining bytes of chained responses with GET RESPONSE.
+func (k *Key) transmit(apdu []byte) ([]byte, error) {
+	var data []byte
+	for {
+		rsp, err := k.card.Transmit(apdu)
+		if err != nil {
+			return nil, err
+		}
+		if len(rsp) < 2 {
+			return nil, statusError(0)
+		}
+		sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
+		data = append(data, rsp[:len(rsp)-2]...)
+		switch {
+		case sw1 == 0x90 && sw2 == 0x00:
+			return data, nil
+		case sw1 == 0x61:
+			apdu = []byte{0x00, 0xc0, 0x00, 0x00, sw2}
+		default:
+			return nil, statusError(uint16(sw1)<<8 | uint16(sw2))
+		}
+	}
+}
+
+// tlv encodes a BER-TLV with a single byte tag.
+func tlv(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// parseTLVs decodes a sequence of BER-TLVs with single byte tags.
+func parseTLVs(b []byte) (map[byte][]byte, error) {
+	tags := map[byte][]byte{}
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, ErrInvalidResponse
+		}
+		tag, n, b2 := b[0], int(b[1]), b[2:]
+		switch {
+		case n == 0x81 && len(b2) >= 1:
+			n, b2 = int(b2[0]), b2[1:]
+		case n == 0x82 && len(b2) >= 2:
+			n, b2 = int(b2[0])<<8|int(b2[1]), b2[2:]
+		case n >= 0x80:
+			return nil, ErrInvalidResponse
+		}
+		if len(b2) < n {
+			return nil, ErrInvalidResponse
+		}
+		tags[tag], b = b2[:n], b2[n:]
+	}
+	return tags, nil
+}
+
+func isSecurityError(err error) bool {
+	var sw statusError
+	return errors.As(err, &sw) && sw == 0x6982
+}
+
+// mapError maps the status words to the errors of this package, keeping the
+// original status in the message. Other errors are returned as is.
+func mapError(err error) error {
+	var sw statusError
+	if !errors.As(err, &sw) {
+		return err
+	}
+	var base error
+	switch {
+	case sw&0xfff0 == 0x63c0:
+		return fmt.Errorf("%w: %d retries left", ErrInvalidPIN, sw&0x0f)
+	case sw == 0x6983:
+		base = ErrPINBlocked
+	case sw == 0x6982:
+		base = ErrAuthRequired
+	case sw == 0x6a82, sw == 0x6a88:
+		base = ErrKeyNotFound
+	case sw == 0x6d00:
+		base = ErrUnsupported
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", base, err)
+}
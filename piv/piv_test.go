@@ -0,0 +1,162 @@
+package piv
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+)
+
+// fakeCard is a PIV application with a P-256 key in slot 9d, which answers
+// the SELECT, GET METADATA, VERIFY and GENERAL AUTHENTICATE commands.
+type fakeCard struct {
+	prv      *ecies.PrivateKey
+	pin      string
+	policy   []byte
+	retries  int
+	verified bool
+	touches  int
+	pending  []byte
+}
+
+var ok = []byte{0x90, 0x00}
+
+func (f *fakeCard) Transmit(apdu []byte) ([]byte, error) {
+	switch apdu[1] {
+	case 0xa4:
+		return ok, nil
+	case 0xf7:
+		if Slot(apdu[3]) != SlotKeyManagement {
+			return []byte{0x6a, 0x88}, nil
+		}
+		point := elliptic.Marshal(f.prv.Curve, f.prv.X, f.prv.Y)
+		rsp := append(tlv(0x01, []byte{algP256}), tlv(0x02, f.policy)...)
+		rsp = append(rsp, tlv(0x04, tlv(0x86, point))...)
+		// Chain the response, as for a short Le.
+		f.pending = rsp[10:]
+		return append(rsp[:10:10], 0x61, byte(len(f.pending))), nil
+	case 0xc0:
+		rsp := f.pending
+		f.pending = nil
+		return append(rsp, ok...), nil
+	case 0x20:
+		if f.retries == 0 {
+			return []byte{0x69, 0x83}, nil
+		}
+		if string(bytes.TrimRight(apdu[5:13], "\xff")) != f.pin {
+			f.retries--
+			return []byte{0x63, 0xc0 | byte(f.retries)}, nil
+		}
+		f.verified = true
+		return ok, nil
+	case 0x87:
+		if !f.verified {
+			return []byte{0x69, 0x82}, nil
+		}
+		if f.policy[1] == byte(TouchPolicyAlways) && f.touches == 0 {
+			return []byte{0x69, 0x82}, nil
+		}
+		data, _ := parseTLVs(apdu[5 : len(apdu)-1])
+		tags, _ := parseTLVs(data[0x7c])
+		pub, err := ecies.NewPublicKeyFromBytes(f.prv.Curve, tags[0x85])
+		if err != nil {
+			return []byte{0x6a, 0x80}, nil
+		}
+		z, _ := f.prv.GenerateShared(pub)
+		if f.policy[0] == byte(PINPolicyAlways) {
+			f.verified = false
+		}
+		return append(tlv(0x7c, tlv(0x82, z)), ok...), nil
+	}
+	return []byte{0x6d, 0x00}, nil
+}
+
+// Ensure that messages are decrypted with the key of slot 9d, as allowed by
+// its PIN and touch policies, and that the PIN errors are reported.
+func TestKey(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	card := &fakeCard{prv: prv, pin: "123456", policy: []byte{0, 0}, retries: 3}
+	key, err := New(card, Config{PIN: "123456"})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if key.Public().X.Cmp(prv.X) != 0 || key.Public().Y.Cmp(prv.Y) != 0 {
+		fmt.Println("piv: wrong public key")
+		t.FailNow()
+	}
+	if pin, touch := key.Policies(); pin != PINPolicyOnce || touch != TouchPolicyNever {
+		fmt.Println("piv: wrong default policies", pin, touch)
+		t.FailNow()
+	}
+	ct, err := ecies.Encrypt(rand.Reader, key.Public(), []byte("payload"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		m, err := ecies.Decrypt(key, ct, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if string(m) != "payload" {
+			fmt.Println("piv: plaintext doesn't match")
+			t.FailNow()
+		}
+		// The token is reset: the PIN is verified again.
+		card.verified = false
+	}
+
+	card.policy = []byte{byte(PINPolicyAlways), byte(TouchPolicyAlways)}
+	key, err = New(card, Config{PIN: "123456", Touch: func() { card.touches++ }})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = ecies.Decrypt(key, ct, nil, nil); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+	}
+	if card.touches != 2 {
+		fmt.Println("piv: touch not prompted")
+		t.FailNow()
+	}
+
+	if _, err = New(card, Config{Slot: SlotSignature}); !errors.Is(err, ErrKeyNotFound) {
+		fmt.Println("piv: empty slot not reported", err)
+		t.FailNow()
+	}
+	key, err = New(card, Config{})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = key.GenerateShared(key.Public()); err != ErrPINRequired {
+		fmt.Println("piv: missing PIN not reported", err)
+		t.FailNow()
+	}
+	key, err = New(card, Config{PIN: "000000"})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = key.GenerateShared(key.Public()); !errors.Is(err, ErrInvalidPIN) {
+		fmt.Println("piv: wrong PIN not reported", err)
+		t.FailNow()
+	}
+	card.retries = 0
+	if _, err = key.GenerateShared(key.Public()); !errors.Is(err, ErrPINBlocked) {
+		fmt.Println("piv: blocked PIN not reported", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,402 @@
+// Package pkcs11 implements an ecies.KeyProvider for EC keys held in a
+// PKCS#11 token, e.g. an HSM, using the CKM_ECDH1_DERIVE mechanism. The
+// private key never leaves the token: only the shared secret of each key
+// agreement is extracted.
+package pkcs11
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/foundriesio/go-ecies"
+	p11 "github.com/miekg/pkcs11"
+)
+
+var (
+	ErrTokenNotFound   = fmt.Errorf("pkcs11: token not found")
+	ErrTokenNotPresent = fmt.Errorf("pkcs11: token not present")
+	ErrKeyNotFound     = fmt.Errorf("pkcs11: key not found")
+	ErrAmbiguousKey    = fmt.Errorf("pkcs11: more than one key matches")
+	ErrInvalidPIN      = fmt.Errorf("pkcs11: invalid PIN")
+	ErrPINLocked       = fmt.Errorf("pkcs11: PIN locked")
+	ErrUnsupported     = fmt.Errorf("pkcs11: ECDH1 derivation not supported by the token")
+	ErrClosed          = fmt.Errorf("pkcs11: key provider closed")
+)
+
+// Config selects the token and key of a Key.
+type Config struct {
+	// Path is the path of the PKCS#11 module library.
+	Path string
+	// The token is found by its label, or else by its slot ID.
+	TokenLabel string
+	Slot       uint
+	// PIN is the user PIN of the token, if login is required.
+	PIN string
+	// The private key is found by its label and/or its ID, at least one of
+	// which must be set.
+	KeyLabel string
+	KeyID    []byte
+	// MaxSessions is the number of sessions kept open for concurrent key
+	// agreements; it defaults to 1.
+	MaxSessions int
+}
+
+// module is the subset of the PKCS#11 API used by Key, as implemented by
+// *p11.Ctx.
+type module interface {
+	Initialize() error
+	Finalize() error
+	Destroy()
+	GetSlotList(tokenPresent bool) ([]uint, error)
+	GetTokenInfo(slotID uint) (p11.TokenInfo, error)
+	OpenSession(slotID uint, flags uint) (p11.SessionHandle, error)
+	CloseSession(sh p11.SessionHandle) error
+	Login(sh p11.SessionHandle, userType uint, pin string) error
+	FindObjectsInit(sh p11.SessionHandle, temp []*p11.Attribute) error
+	FindObjects(sh p11.SessionHandle, max int) ([]p11.ObjectHandle, bool, error)
+	FindObjectsFinal(sh p11.SessionHandle) error
+	GetAttributeValue(sh p11.SessionHandle, o p11.ObjectHandle, a []*p11.Attribute) ([]*p11.Attribute, error)
+	DeriveKey(sh p11.SessionHandle, m []*p11.Mechanism, basekey p11.ObjectHandle, a []*p11.Attribute) (p11.ObjectHandle, error)
+	DestroyObject(sh p11.SessionHandle, oh p11.ObjectHandle) error
+}
+
+// Key is a KeyProvider for a private key held in a PKCS#11 token. It is safe
+// for concurrent use, up to the configured number of sessions.
+type Key struct {
+	ctx  module
+	cfg  Config
+	slot uint
+	pub  *ecies.PublicKey
+
+	// sessions is the pool of sessions, where 0 (CK_INVALID_HANDLE) stands
+	// for a session to be opened on demand.
+	sessions chan p11.SessionHandle
+	mu       sync.Mutex
+	loggedIn bool
+	closed   bool
+}
+
+// New loads the PKCS#11 module, logs into the token and finds the key
+// described by cfg. The key must be closed after use.
+func New(cfg Config) (*Key, error) {
+	ctx := p11.New(cfg.Path)
+	if ctx == nil {
+		return nil, fmt.Errorf("pkcs11: unable to load module %s", cfg.Path)
+	}
+	k, err := newKey(ctx, cfg)
+	if err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+	return k, nil
+}
+
+func newKey(ctx module, cfg Config) (*Key, error) {
+	if cfg.KeyLabel == "" && len(cfg.KeyID) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 1
+	}
+	if err := ctx.Initialize(); err != nil && err != p11.Error(p11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return nil, mapError(err)
+	}
+	k := &Key{ctx: ctx, cfg: cfg, sessions: make(chan p11.SessionHandle, cfg.MaxSessions)}
+	var err error
+	if k.slot, err = findSlot(ctx, cfg); err != nil {
+		ctx.Finalize()
+		return nil, err
+	}
+	sh, err := k.openSession()
+	if err != nil {
+		ctx.Finalize()
+		return nil, err
+	}
+	if k.pub, err = k.loadPublic(sh); err != nil {
+		ctx.CloseSession(sh)
+		ctx.Finalize()
+		return nil, err
+	}
+	k.sessions <- sh
+	for i := 1; i < cfg.MaxSessions; i++ {
+		k.sessions <- 0
+	}
+	return k, nil
+}
+
+func findSlot(ctx module, cfg Config) (uint, error) {
+	if cfg.TokenLabel == "" {
+		return cfg.Slot, nil
+	}
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == cfg.TokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, ErrTokenNotFound
+}
+
+// openSession opens a session, logging in if needed. The login state is
+// shared by all the sessions of the application with the token.
+func (k *Key) openSession() (p11.SessionHandle, error) {
+	sh, err := k.ctx.OpenSession(k.slot, p11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.cfg.PIN != "" && !k.loggedIn {
+		err = k.ctx.Login(sh, p11.CKU_USER, k.cfg.PIN)
+		if err != nil && err != p11.Error(p11.CKR_USER_ALREADY_LOGGED_IN) {
+			k.ctx.CloseSession(sh)
+			return 0, mapError(err)
+		}
+		k.loggedIn = true
+	}
+	return sh, nil
+}
+
+func (k *Key) findObject(sh p11.SessionHandle, class uint) (p11.ObjectHandle, error) {
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, class),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_EC),
+	}
+	if k.cfg.KeyLabel != "" {
+		template = append(template, p11.NewAttribute(p11.CKA_LABEL, k.cfg.KeyLabel))
+	}
+	if len(k.cfg.KeyID) != 0 {
+		template = append(template, p11.NewAttribute(p11.CKA_ID, k.cfg.KeyID))
+	}
+	if err := k.ctx.FindObjectsInit(sh, template); err != nil {
+		return 0, mapError(err)
+	}
+	objs, _, err := k.ctx.FindObjects(sh, 2)
+	k.ctx.FindObjectsFinal(sh)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	switch len(objs) {
+	case 0:
+		return 0, ErrKeyNotFound
+	case 1:
+		return objs[0], nil
+	}
+	return 0, ErrAmbiguousKey
+}
+
+// loadPublic reads the public key matching the private key, which carries
+// the curve parameters and the point.
+func (k *Key) loadPublic(sh p11.SessionHandle) (*ecies.PublicKey, error) {
+	if _, err := k.findObject(sh, p11.CKO_PRIVATE_KEY); err != nil {
+		return nil, err
+	}
+	obj, err := k.findObject(sh, p11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := k.ctx.GetAttributeValue(sh, obj, []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_EC_PARAMS, nil),
+		p11.NewAttribute(p11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var params, point []byte
+	for _, a := range attrs {
+		switch a.Type {
+		case p11.CKA_EC_PARAMS:
+			params = a.Value
+		case p11.CKA_EC_POINT:
+			point = a.Value
+		}
+	}
+	curve := curveFromParams(params)
+	if curve == nil {
+		return nil, ecies.ErrInvalidCurve
+	}
+	// CKA_EC_POINT is a DER OCTET STRING, though some tokens return the
+	// bare point.
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 {
+		raw = point
+	}
+	return ecies.NewPublicKeyFromBytes(curve, raw)
+}
+
+var (
+	oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// curveFromParams returns the named curve of the CKA_EC_PARAMS attribute.
+func curveFromParams(params []byte) elliptic.Curve {
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &oid); err != nil || len(rest) != 0 {
+		return nil
+	}
+	switch {
+	case oid.Equal(oidP256):
+		return elliptic.P256()
+	case oid.Equal(oidP384):
+		return elliptic.P384()
+	case oid.Equal(oidP521):
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// Public returns the public key of the token key.
+func (k *Key) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+// GenerateShared derives the shared secret with pub in the token, as an
+// ephemeral secret key whose value is read and which is then destroyed.
+func (k *Key) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	if pub.Curve != k.pub.Curve {
+		return nil, ecies.ErrInvalidCurve
+	}
+	sh := <-k.sessions
+	defer func() { k.sessions <- sh }()
+	k.mu.Lock()
+	closed := k.closed
+	k.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	var z []byte
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		if sh == 0 {
+			if sh, err = k.openSession(); err != nil {
+				return nil, err
+			}
+		}
+		z, err = k.derive(sh, pub)
+		if !isSessionError(err) {
+			break
+		}
+		// The session was lost, e.g. the token was reset: retry once
+		// with a new session, logging in again.
+		k.ctx.CloseSession(sh)
+		sh = 0
+		k.mu.Lock()
+		k.loggedIn = false
+		k.mu.Unlock()
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return z, nil
+}
+
+// ecdhMechanism returns the CKM_ECDH1_DERIVE mechanism with the peer point,
+// without key derivation function.
+var ecdhMechanism = func(point []byte) *p11.Mechanism {
+	return p11.NewMechanism(p11.CKM_ECDH1_DERIVE, p11.NewECDH1DeriveParams(p11.CKD_NULL, nil, point))
+}
+
+func (k *Key) derive(sh p11.SessionHandle, pub *ecies.PublicKey) ([]byte, error) {
+	prv, err := k.findObject(sh, p11.CKO_PRIVATE_KEY)
+	if err != nil {
+		return nil, err
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	point := elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+	mech := []*p11.Mechanism{ecdhMechanism(point)}
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_GENERIC_SECRET),
+		p11.NewAttribute(p11.CKA_TOKEN, false),
+		p11.NewAttribute(p11.CKA_SENSITIVE, false),
+		p11.NewAttribute(p11.CKA_EXTRACTABLE, true),
+		p11.NewAttribute(p11.CKA_VALUE_LEN, size),
+	}
+	secret, err := k.ctx.DeriveKey(sh, mech, prv, template)
+	if err != nil {
+		return nil, err
+	}
+	defer k.ctx.DestroyObject(sh, secret)
+	attrs, err := k.ctx.GetAttributeValue(sh, secret, []*p11.Attribute{p11.NewAttribute(p11.CKA_VALUE, nil)})
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) != 1 || len(attrs[0].Value) != size {
+		return nil, ecies.ErrSharedKeyIsPointAtInfinity
+	}
+	return attrs[0].Value, nil
+}
+
+// Close waits for the key agreements in progress, then closes the sessions
+// and unloads the module.
+func (k *Key) Close() error {
+	k.mu.Lock()
+	if k.closed {
+		k.mu.Unlock()
+		return nil
+	}
+	k.closed = true
+	k.mu.Unlock()
+	for i := 0; i < cap(k.sessions); i++ {
+		if sh := <-k.sessions; sh != 0 {
+			k.ctx.CloseSession(sh)
+		}
+	}
+	// Let later calls fail with ErrClosed rather than block.
+	for i := 0; i < cap(k.sessions); i++ {
+		k.sessions <- 0
+	}
+	err := k.ctx.Finalize()
+	k.ctx.Destroy()
+	return mapError(err)
+}
+
+func isSessionError(err error) bool {
+	var code p11.Error
+	if !errors.As(err, &code) {
+		return false
+	}
+	switch code {
+	case p11.CKR_SESSION_HANDLE_INVALID, p11.CKR_SESSION_CLOSED, p11.CKR_USER_NOT_LOGGED_IN, p11.CKR_DEVICE_ERROR:
+		return true
+	}
+	return false
+}
+
+// mapError maps the PKCS#11 return values to the errors of this package
+// and of ecies, keeping the original code in the message. Other values are
+// returned as is.
+func mapError(err error) error {
+	code, ok := err.(p11.Error)
+	if !ok {
+		return err
+	}
+	var base error
+	switch code {
+	case p11.CKR_PIN_INCORRECT, p11.CKR_PIN_INVALID, p11.CKR_PIN_LEN_RANGE:
+		base = ErrInvalidPIN
+	case p11.CKR_PIN_LOCKED:
+		base = ErrPINLocked
+	case p11.CKR_TOKEN_NOT_PRESENT, p11.CKR_DEVICE_REMOVED, p11.CKR_SLOT_ID_INVALID:
+		base = ErrTokenNotPresent
+	case p11.CKR_MECHANISM_INVALID:
+		base = ErrUnsupported
+	case p11.CKR_KEY_HANDLE_INVALID, p11.CKR_KEY_TYPE_INCONSISTENT, p11.CKR_KEY_FUNCTION_NOT_PERMITTED:
+		base = ecies.ErrInvalidPrivateKey
+	case p11.CKR_MECHANISM_PARAM_INVALID, p11.CKR_DOMAIN_PARAMS_INVALID:
+		base = ecies.ErrInvalidPublicKey
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", base, err)
+}
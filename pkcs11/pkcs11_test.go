@@ -0,0 +1,266 @@
+package pkcs11
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+	p11 "github.com/miekg/pkcs11"
+)
+
+// fakeToken is an in-memory token holding a single EC key pair, with the
+// private key as object 1 and the public key as object 2.
+type fakeToken struct {
+	mu       sync.Mutex
+	prv      *ecies.PrivateKey
+	label    string
+	pin      string
+	loggedIn bool
+	sessions map[p11.SessionHandle]bool
+	next     p11.SessionHandle
+	found    []p11.ObjectHandle
+	secrets  map[p11.ObjectHandle][]byte
+	nextObj  p11.ObjectHandle
+	finalize bool
+}
+
+func newFakeToken(t *testing.T) *fakeToken {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	return &fakeToken{
+		prv:      prv,
+		label:    "device",
+		pin:      "1234",
+		sessions: map[p11.SessionHandle]bool{},
+		secrets:  map[p11.ObjectHandle][]byte{},
+		nextObj:  100,
+	}
+}
+
+func (f *fakeToken) Initialize() error { return nil }
+func (f *fakeToken) Finalize() error   { f.finalize = true; return nil }
+func (f *fakeToken) Destroy()          {}
+
+func (f *fakeToken) GetSlotList(bool) ([]uint, error) { return []uint{0, 3}, nil }
+
+func (f *fakeToken) GetTokenInfo(slot uint) (p11.TokenInfo, error) {
+	if slot == 3 {
+		return p11.TokenInfo{Label: "hsm"}, nil
+	}
+	return p11.TokenInfo{Label: "other"}, nil
+}
+
+func (f *fakeToken) OpenSession(slot uint, flags uint) (p11.SessionHandle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slot != 3 {
+		return 0, p11.Error(p11.CKR_SLOT_ID_INVALID)
+	}
+	f.next++
+	f.sessions[f.next] = true
+	return f.next, nil
+}
+
+func (f *fakeToken) CloseSession(sh p11.SessionHandle) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sessions, sh)
+	return nil
+}
+
+func (f *fakeToken) Login(sh p11.SessionHandle, userType uint, pin string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if pin != f.pin {
+		return p11.Error(p11.CKR_PIN_INCORRECT)
+	}
+	f.loggedIn = true
+	return nil
+}
+
+// reset drops all the sessions and the login state, as a token reset does.
+func (f *fakeToken) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = map[p11.SessionHandle]bool{}
+	f.loggedIn = false
+}
+
+func (f *fakeToken) FindObjectsInit(sh p11.SessionHandle, temp []*p11.Attribute) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.sessions[sh] {
+		return p11.Error(p11.CKR_SESSION_HANDLE_INVALID)
+	}
+	f.found = nil
+	var class uint
+	for _, a := range temp {
+		switch a.Type {
+		case p11.CKA_CLASS:
+			class = uint(a.Value[0])
+		case p11.CKA_LABEL:
+			if string(a.Value) != f.label {
+				return nil
+			}
+		}
+	}
+	switch class {
+	case p11.CKO_PRIVATE_KEY:
+		if f.loggedIn {
+			f.found = []p11.ObjectHandle{1}
+		}
+	case p11.CKO_PUBLIC_KEY:
+		f.found = []p11.ObjectHandle{2}
+	}
+	return nil
+}
+
+func (f *fakeToken) FindObjects(sh p11.SessionHandle, max int) ([]p11.ObjectHandle, bool, error) {
+	return f.found, false, nil
+}
+
+func (f *fakeToken) FindObjectsFinal(sh p11.SessionHandle) error { return nil }
+
+func (f *fakeToken) GetAttributeValue(sh p11.SessionHandle, o p11.ObjectHandle, a []*p11.Attribute) ([]*p11.Attribute, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if o == 2 {
+		params, _ := asn1.Marshal(oidP256)
+		point, _ := asn1.Marshal(elliptic.Marshal(f.prv.Curve, f.prv.X, f.prv.Y))
+		return []*p11.Attribute{
+			p11.NewAttribute(p11.CKA_EC_PARAMS, params),
+			p11.NewAttribute(p11.CKA_EC_POINT, point),
+		}, nil
+	}
+	if v, ok := f.secrets[o]; ok {
+		return []*p11.Attribute{p11.NewAttribute(p11.CKA_VALUE, v)}, nil
+	}
+	return nil, p11.Error(p11.CKR_OBJECT_HANDLE_INVALID)
+}
+
+func (f *fakeToken) DeriveKey(sh p11.SessionHandle, m []*p11.Mechanism, basekey p11.ObjectHandle, a []*p11.Attribute) (p11.ObjectHandle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.sessions[sh] {
+		return 0, p11.Error(p11.CKR_SESSION_HANDLE_INVALID)
+	}
+	if basekey != 1 || len(m) != 1 || m[0].Mechanism != p11.CKM_ECDH1_DERIVE {
+		return 0, p11.Error(p11.CKR_MECHANISM_INVALID)
+	}
+	pub, err := ecies.NewPublicKeyFromBytes(f.prv.Curve, m[0].Parameter)
+	if err != nil {
+		return 0, p11.Error(p11.CKR_MECHANISM_PARAM_INVALID)
+	}
+	z, err := f.prv.GenerateShared(pub)
+	if err != nil {
+		return 0, p11.Error(p11.CKR_MECHANISM_PARAM_INVALID)
+	}
+	f.nextObj++
+	f.secrets[f.nextObj] = z
+	return f.nextObj, nil
+}
+
+func (f *fakeToken) DestroyObject(sh p11.SessionHandle, oh p11.ObjectHandle) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.secrets, oh)
+	return nil
+}
+
+// Ensure that messages are decrypted with the token key, across sessions
+// and token resets, and that the configuration errors are reported.
+func TestKey(t *testing.T) {
+	// The parameters are only serialized for the module: pass the peer
+	// point as is to the fake token.
+	defer func(f func([]byte) *p11.Mechanism) { ecdhMechanism = f }(ecdhMechanism)
+	ecdhMechanism = func(point []byte) *p11.Mechanism {
+		return p11.NewMechanism(p11.CKM_ECDH1_DERIVE, point)
+	}
+
+	token := newFakeToken(t)
+	cfg := Config{TokenLabel: "hsm", PIN: "1234", KeyLabel: "device", MaxSessions: 2}
+	key, err := newKey(token, cfg)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub := key.Public()
+	if pub.X.Cmp(token.prv.X) != 0 || pub.Y.Cmp(token.prv.Y) != 0 {
+		fmt.Println("pkcs11: wrong public key")
+		t.FailNow()
+	}
+
+	ct, err := ecies.Encrypt(rand.Reader, pub, []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := ecies.Decrypt(key, ct, nil, nil)
+			if err == nil && !bytes.Equal(m, []byte("message")) {
+				err = fmt.Errorf("pkcs11: plaintext doesn't match")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+	}
+	if len(token.secrets) != 0 {
+		fmt.Println("pkcs11: derived secrets not destroyed")
+		t.FailNow()
+	}
+
+	token.reset()
+	if _, err = ecies.Decrypt(key, ct, nil, nil); err != nil {
+		fmt.Println("pkcs11: no recovery from a token reset:", err)
+		t.FailNow()
+	}
+
+	if err = key.Close(); err != nil || !token.finalize || len(token.sessions) != 0 {
+		fmt.Println("pkcs11: sessions not closed", err)
+		t.FailNow()
+	}
+	if _, err = key.GenerateShared(pub); err != ErrClosed {
+		fmt.Println("pkcs11: key agreement after close", err)
+		t.FailNow()
+	}
+
+	bad := cfg
+	bad.PIN = "0000"
+	if _, err = newKey(newFakeToken(t), bad); !errors.Is(err, ErrInvalidPIN) {
+		fmt.Println("pkcs11: wrong PIN not reported", err)
+		t.FailNow()
+	}
+	bad = cfg
+	bad.TokenLabel = "missing"
+	if _, err = newKey(newFakeToken(t), bad); err != ErrTokenNotFound {
+		fmt.Println("pkcs11: missing token not reported", err)
+		t.FailNow()
+	}
+	bad = cfg
+	bad.KeyLabel = "missing"
+	if _, err = newKey(newFakeToken(t), bad); err != ErrKeyNotFound {
+		fmt.Println("pkcs11: missing key not reported", err)
+		t.FailNow()
+	}
+}
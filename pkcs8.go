@@ -0,0 +1,226 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+)
+
+var (
+	// RFC 5480, section 2.1.1
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	// RFC 8410, section 3
+	oidPublicKeyX25519 = asn1.ObjectIdentifier{1, 3, 101, 110}
+	oidPublicKeyX448   = asn1.ObjectIdentifier{1, 3, 101, 111}
+)
+
+// montgomeryOIDs are the RFC 8410 algorithm identifiers of the curves of
+// montgomeryCurve.
+var montgomeryOIDs = map[elliptic.Curve]asn1.ObjectIdentifier{
+	X25519(): oidPublicKeyX25519,
+	X448():   oidPublicKeyX448,
+}
+
+func montgomeryCurveFromOID(oid asn1.ObjectIdentifier) elliptic.Curve {
+	for curve, o := range montgomeryOIDs {
+		if o.Equal(oid) {
+			return curve
+		}
+	}
+	return nil
+}
+
+// asnECPrivateKey represents the ECPrivateKey structure of RFC 5915, which
+// is also the one of SEC 1, section C.4.
+type asnECPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// asnPKCS8 represents the PrivateKeyInfo structure of RFC 5208, section 5.
+type asnPKCS8 struct {
+	Version    int
+	Algorithm  asnAlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// marshalECPrivateKey encodes prv as an ECPrivateKey, with the curve OID if
+// withCurve is set, as in standalone SEC 1 keys. The private value is left
+// padded to the size of the curve order.
+func marshalECPrivateKey(prv *PrivateKey, withCurve bool) ([]byte, error) {
+	oid, ok := oidFromNamedCurve(prv.Curve)
+	if !ok || prv.D == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	size := (prv.Curve.Params().N.BitLen() + 7) / 8
+	if prv.D.BitLen() > size*8 {
+		return nil, ErrInvalidPrivateKey
+	}
+	ecprv := asnECPrivateKey{
+		Version:    1,
+		PrivateKey: prv.D.FillBytes(make([]byte, size)),
+	}
+	if withCurve {
+		ecprv.Curve = asn1.ObjectIdentifier(oid)
+	}
+	point := elliptic.Marshal(prv.Curve, prv.X, prv.Y)
+	ecprv.PublicKey = asn1.BitString{Bytes: point, BitLength: len(point) * 8}
+	return asn1.Marshal(ecprv)
+}
+
+// parseECPrivateKey decodes an ECPrivateKey. Its curve is curve if not nil,
+// as given by the algorithm of a PKCS #8 key, or else the one it names. The
+// public key is computed from the private value, and must match the one
+// embedded, if any.
+func parseECPrivateKey(der []byte, curve elliptic.Curve) (*PrivateKey, error) {
+	var ecprv asnECPrivateKey
+	if rest, err := asn1.Unmarshal(der, &ecprv); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPrivateKey, err)
+	}
+	if ecprv.Version != 1 {
+		return nil, ErrInvalidPrivateKey
+	}
+	if len(ecprv.Curve) != 0 {
+		named := namedCurveFromOID(secgNamedCurve(ecprv.Curve))
+		if named == nil || (curve != nil && named != curve) {
+			return nil, ErrInvalidCurve
+		}
+		curve = named
+	}
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	n := curve.Params().N
+	if len(ecprv.PrivateKey) > (n.BitLen()+7)/8 {
+		return nil, ErrInvalidPrivateKey
+	}
+	d := new(big.Int).SetBytes(ecprv.PrivateKey)
+	if d.Sign() == 0 || d.Cmp(n) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	prv := new(PrivateKey)
+	prv.D = d
+	prv.Curve = curve
+	prv.X, prv.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, (n.BitLen()+7)/8)))
+	prv.Params = ParamsFromCurve(curve)
+	if len(ecprv.PublicKey.Bytes) != 0 {
+		x, y := unmarshalPoint(curve, ecprv.PublicKey.Bytes, AllowAllPoints)
+		if x == nil || x.Cmp(prv.X) != 0 || y.Cmp(prv.Y) != 0 {
+			return nil, ErrInvalidPrivateKey
+		}
+	}
+	return prv, nil
+}
+
+// Encode a private key to the SEC 1 DER format of RFC 5915, with the named
+// curve and the public key, as x509.MarshalECPrivateKey and OpenSSL do.
+// Unlike MarshalPrivate, it does not carry the ECIES parameters of the key.
+func MarshalPrivateSEC1(prv *PrivateKey) ([]byte, error) {
+	return marshalECPrivateKey(prv, true)
+}
+
+// Decode a private key from the SEC 1 DER format of RFC 5915, as produced by
+// MarshalPrivateSEC1, x509.MarshalECPrivateKey or OpenSSL. The key is given
+// the default parameters of its curve.
+func UnmarshalPrivateSEC1(der []byte) (*PrivateKey, error) {
+	return parseECPrivateKey(der, nil)
+}
+
+// Encode a private key to the PKCS #8 DER format, with the key as an RFC 5915
+// ECPrivateKey, or as an RFC 8410 CurvePrivateKey for X25519 and X448 keys.
+// Unlike MarshalPrivate, the result can be parsed by x509.ParsePKCS8PrivateKey
+// and OpenSSL, except for secp256k1, SM2 and X448 keys which only the latter
+// supports.
+func MarshalPrivatePKCS8(prv *PrivateKey) ([]byte, error) {
+	var pkcs8 asnPKCS8
+	if prv.Curve == X25519() {
+		key, err := x25519PrivateKey(prv.D.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		pkcs8.Algorithm.Algorithm = oidPublicKeyX25519
+		if pkcs8.PrivateKey, err = asn1.Marshal(key.Bytes()); err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8)
+	}
+	if prv.Curve == X448() {
+		if prv.D == nil || prv.D.BitLen() > 8*x448KeySize {
+			return nil, ErrInvalidPrivateKey
+		}
+		key := prv.D.FillBytes(make([]byte, x448KeySize))
+		defer wipe(key)
+		var err error
+		pkcs8.Algorithm.Algorithm = oidPublicKeyX448
+		if pkcs8.PrivateKey, err = asn1.Marshal(key); err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8)
+	}
+
+	oid, ok := oidFromNamedCurve(prv.Curve)
+	if !ok {
+		return nil, ErrInvalidPrivateKey
+	}
+	params, err := asn1.Marshal(asn1.ObjectIdentifier(oid))
+	if err != nil {
+		return nil, err
+	}
+	pkcs8.Algorithm.Algorithm = oidPublicKeyECDSA
+	pkcs8.Algorithm.Parameters = asn1.RawValue{FullBytes: params}
+	if pkcs8.PrivateKey, err = marshalECPrivateKey(prv, false); err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8)
+}
+
+// Decode a private key from the PKCS #8 DER format, as produced by
+// MarshalPrivatePKCS8, x509.MarshalPKCS8PrivateKey or OpenSSL. Only EC keys
+// on the supported curves, X25519 and X448 keys are accepted.
+func UnmarshalPrivatePKCS8(der []byte) (*PrivateKey, error) {
+	var pkcs8 asnPKCS8
+	if rest, err := asn1.Unmarshal(der, &pkcs8); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPrivateKey, err)
+	}
+	if pkcs8.Version != 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	switch {
+	case pkcs8.Algorithm.Algorithm.Equal(oidPublicKeyX25519):
+		var raw []byte
+		if rest, err := asn1.Unmarshal(pkcs8.PrivateKey, &raw); err != nil || len(rest) != 0 {
+			return nil, asn1Error(ErrInvalidPrivateKey, err)
+		}
+		key, err := x25519PrivateKey(raw)
+		if err != nil || len(raw) != x25519KeySize {
+			return nil, ErrInvalidPrivateKey
+		}
+		return newX25519PrivateKey(key), nil
+	case pkcs8.Algorithm.Algorithm.Equal(oidPublicKeyX448):
+		var raw []byte
+		if rest, err := asn1.Unmarshal(pkcs8.PrivateKey, &raw); err != nil || len(rest) != 0 {
+			return nil, asn1Error(ErrInvalidPrivateKey, err)
+		}
+		if len(raw) != x448KeySize {
+			return nil, ErrInvalidPrivateKey
+		}
+		return newX448PrivateKey(raw), nil
+	case pkcs8.Algorithm.Algorithm.Equal(oidPublicKeyECDSA):
+		var oid asn1.ObjectIdentifier
+		rest, err := asn1.Unmarshal(pkcs8.Algorithm.Parameters.FullBytes, &oid)
+		if err != nil || len(rest) != 0 {
+			return nil, ErrInvalidCurve
+		}
+		curve := namedCurveFromOID(secgNamedCurve(oid))
+		if curve == nil {
+			return nil, ErrInvalidCurve
+		}
+		return parseECPrivateKey(pkcs8.PrivateKey, curve)
+	}
+	return nil, ErrInvalidPrivateKey
+}
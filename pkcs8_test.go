@@ -0,0 +1,142 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure that PKCS #8 keys round trip on all the curves, and are understood
+// by crypto/x509 both ways.
+func TestPKCS8(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521(), Secp256k1(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPrivatePKCS8(prv)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		prv2, err := UnmarshalPrivatePKCS8(der)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if prv2.Curve != curve || prv.D.Cmp(prv2.D) != 0 || prv.X.Cmp(prv2.X) != 0 {
+			fmt.Println("ecies: PKCS #8 round trip failed for", curve.Params().Name)
+			t.FailNow()
+		}
+		if curve == Secp256k1() {
+			continue
+		}
+
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			fmt.Println(curve.Params().Name, err.Error())
+			t.FailNow()
+		}
+		switch key := key.(type) {
+		case *ecdsa.PrivateKey:
+			if key.D.Cmp(prv.D) != 0 {
+				fmt.Println("ecies: x509 parsed a different key")
+				t.FailNow()
+			}
+		case *ecdh.PrivateKey:
+			if key.Curve() != ecdh.X25519() || prv.X.Cmp(new(big.Int).SetBytes(key.PublicKey().Bytes())) != 0 {
+				fmt.Println("ecies: x509 parsed a different key")
+				t.FailNow()
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	prv, err := UnmarshalPrivatePKCS8(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !cmpPrivate(prv, ImportECDSA(key)) || prv.Params != ECIES_AES192_SHA384 {
+		fmt.Println("ecies: import of an x509 PKCS #8 key failed")
+		t.FailNow()
+	}
+
+	// A key whose embedded public key doesn't match its private value.
+	other, _ := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	prv.Curve, prv.D = elliptic.P256(), other.D
+	prv.X, prv.Y = elliptic.P256().ScalarBaseMult([]byte{1})
+	if der, err = MarshalPrivatePKCS8(prv); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = UnmarshalPrivatePKCS8(der); err != ErrInvalidPrivateKey {
+		fmt.Println("ecies: inconsistent PKCS #8 key accepted")
+		t.FailNow()
+	}
+}
+
+// Ensure that SEC 1 keys are compatible with crypto/x509, and that
+// UnmarshalPrivate reads them as well as its own format.
+func TestSEC1(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv := ImportECDSA(key)
+	der, err := MarshalPrivateSEC1(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	std, _ := x509.MarshalECPrivateKey(key)
+	if !bytes.Equal(der, std) {
+		fmt.Println("ecies: SEC 1 encoding differs from crypto/x509")
+		t.FailNow()
+	}
+	legacy, err := MarshalPrivate(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, in := range [][]byte{der, legacy} {
+		prv2, err := UnmarshalPrivate(in)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !cmpPrivate(prv, prv2) {
+			fmt.Println("ecies: SEC 1 decoding failed")
+			t.FailNow()
+		}
+	}
+
+	prv, _ = GenerateKey(rand.Reader, Secp256k1(), nil)
+	if der, err = MarshalPrivateSEC1(prv); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if prv2, err := UnmarshalPrivateSEC1(der); err != nil || !cmpPrivate(prv, prv2) {
+		fmt.Println("ecies: secp256k1 SEC 1 round trip failed", err)
+		t.FailNow()
+	}
+	x, _ := GenerateKey(rand.Reader, X25519(), nil)
+	if _, err = MarshalPrivateSEC1(x); err != ErrInvalidPrivateKey {
+		fmt.Println("ecies: X25519 key encoded as SEC 1")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+)
+
+// asnPKIXPublicKey represents the SubjectPublicKeyInfo structure of RFC 5280,
+// section 4.1.
+type asnPKIXPublicKey struct {
+	Algorithm asnAlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// Encode a public key to the standard SubjectPublicKeyInfo DER format, with
+// the id-ecPublicKey algorithm and the named curve (RFC 5480), or the
+// id-X25519 and id-X448 algorithms for X25519 and X448 keys (RFC 8410).
+// Unlike MarshalPublic, the result can be parsed by x509.ParsePKIXPublicKey
+// and OpenSSL, except for secp256k1, SM2 and X448 keys which only the latter
+// supports, but it doesn't carry the ECIES parameters of the key.
+func MarshalPublicPKIX(pub *PublicKey) ([]byte, error) {
+	var spki asnPKIXPublicKey
+	var point []byte
+	if montgomeryCurve(pub.Curve) {
+		spki.Algorithm.Algorithm = montgomeryOIDs[pub.Curve]
+		point = marshalPoint(pub.Curve, pub.X, pub.Y)
+	} else {
+		oid, ok := oidFromNamedCurve(pub.Curve)
+		if !ok {
+			return nil, ErrInvalidPublicKey
+		}
+		params, err := asn1.Marshal(asn1.ObjectIdentifier(oid))
+		if err != nil {
+			return nil, err
+		}
+		spki.Algorithm.Algorithm = oidPublicKeyECDSA
+		spki.Algorithm.Parameters = asn1.RawValue{FullBytes: params}
+		point = elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+	}
+	spki.PublicKey = asn1.BitString{Bytes: point, BitLength: len(point) * 8}
+	return asn1.Marshal(spki)
+}
+
+// Decode a public key from the standard SubjectPublicKeyInfo DER format, as
+// produced by MarshalPublicPKIX, x509.MarshalPKIXPublicKey or OpenSSL. The key
+// is given the default parameters of its curve.
+func UnmarshalPublicPKIX(in []byte) (*PublicKey, error) {
+	return unmarshalPublicPKIX(in, AllowAllPoints)
+}
+
+func unmarshalPublicPKIX(in []byte, policy PointFormatPolicy) (*PublicKey, error) {
+	var spki asnPKIXPublicKey
+	if rest, err := asn1.Unmarshal(in, &spki); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPublicKey, err)
+	}
+	if spki.PublicKey.BitLength != len(spki.PublicKey.Bytes)*8 {
+		return nil, ErrInvalidPublicKey
+	}
+	data := spki.PublicKey.Bytes
+	switch {
+	case montgomeryCurveFromOID(spki.Algorithm.Algorithm) != nil:
+		curve := montgomeryCurveFromOID(spki.Algorithm.Algorithm)
+		if len(data) != pointSize(curve, 0, policy) || len(spki.Algorithm.Parameters.FullBytes) != 0 {
+			return nil, ErrInvalidPublicKey
+		}
+		pub := &PublicKey{
+			X:      new(big.Int).SetBytes(data),
+			Y:      new(big.Int),
+			Curve:  curve,
+			Params: ParamsFromCurve(curve),
+		}
+		if err := ValidatePublicKey(pub); err != nil {
+			return nil, err
+		}
+		return pub, nil
+	case spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA):
+		var oid asn1.ObjectIdentifier
+		rest, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &oid)
+		if err != nil || len(rest) != 0 {
+			return nil, ErrInvalidCurve
+		}
+		curve := namedCurveFromOID(secgNamedCurve(oid))
+		if curve == nil {
+			return nil, ErrInvalidCurve
+		}
+		x, y := unmarshalPoint(curve, data, policy)
+		if x == nil {
+			return nil, ErrInvalidPublicKey
+		}
+		pub := &PublicKey{X: x, Y: y, Curve: curve, Params: ParamsFromCurve(curve)}
+		if err := ValidatePublicKey(pub); err != nil {
+			return nil, err
+		}
+		return pub, nil
+	}
+	return nil, ErrInvalidPublicKey
+}
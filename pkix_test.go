@@ -0,0 +1,74 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that standard public keys round trip on all the curves, match the
+// encoding of crypto/x509, and are detected by UnmarshalPublic.
+func TestPKIX(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521(), Secp256k1(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublicPKIX(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		for _, unmarshal := range []func([]byte) (*PublicKey, error){UnmarshalPublicPKIX, UnmarshalPublic} {
+			pub, err := unmarshal(der)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			if pub.Curve != curve || pub.X.Cmp(prv.X) != 0 || pub.Y.Cmp(prv.Y) != 0 {
+				fmt.Println("ecies: PKIX round trip failed for", curve.Params().Name)
+				t.FailNow()
+			}
+		}
+
+		var std []byte
+		switch curve {
+		case Secp256k1():
+			continue
+		case X25519():
+			key, _ := ecdh.X25519().NewPublicKey(prv.X.FillBytes(make([]byte, 32)))
+			std, _ = x509.MarshalPKIXPublicKey(key)
+		default:
+			std, _ = x509.MarshalPKIXPublicKey(&prv.ExportECDSA().PublicKey)
+		}
+		if !bytes.Equal(der, std) {
+			fmt.Println("ecies: PKIX encoding differs from crypto/x509 for", curve.Params().Name)
+			t.FailNow()
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	der, _ := MarshalPublic(ImportECDSAPublic(&key.PublicKey))
+	if pub, err := UnmarshalPublic(der); err != nil || pub.X.Cmp(key.X) != 0 {
+		fmt.Println("ecies: supplemented public key not decoded", err)
+		t.FailNow()
+	}
+	if _, err = UnmarshalPublicPKIX(der); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: supplemented public key decoded as PKIX")
+		t.FailNow()
+	}
+}
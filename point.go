@@ -0,0 +1,179 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"math/big"
+)
+
+// SEC 1 section 2.3.3: the leading octet of an encoded elliptic curve point.
+const (
+	pointCompressedEven = 2
+	pointCompressedOdd  = 3
+	pointUncompressed   = 4
+	pointHybridEven     = 6
+	pointHybridOdd      = 7
+)
+
+// PointFormatPolicy restricts the SEC 1 encodings accepted for ephemeral public keys.
+type PointFormatPolicy int
+
+const (
+	// AllowAllPoints accepts the uncompressed, compressed and hybrid formats.
+	AllowAllPoints PointFormatPolicy = iota
+	// AllowCompressedPoints accepts the uncompressed and compressed formats.
+	AllowCompressedPoints
+	// UncompressedPointsOnly accepts the uncompressed format only.
+	UncompressedPointsOnly
+)
+
+func (policy PointFormatPolicy) allows(format byte) bool {
+	switch format {
+	case pointUncompressed:
+		return true
+	case pointCompressedEven, pointCompressedOdd:
+		return policy != UncompressedPointsOnly
+	case pointHybridEven, pointHybridOdd:
+		return policy == AllowAllPoints
+	}
+	return false
+}
+
+// rawPointCurve is implemented by the curves which points are encoded as a
+// fixed-size string, without a SEC 1 format octet, like X25519.
+type rawPointCurve interface {
+	pointLen() int
+}
+
+// pointSize returns the length of an encoded point starting with the given octet,
+// or 0 if the octet is not a point format allowed by the policy.
+func pointSize(curve elliptic.Curve, format byte, policy PointFormatPolicy) int {
+	if c, ok := curve.(rawPointCurve); ok {
+		return c.pointLen()
+	}
+	if !policy.allows(format) {
+		return 0
+	}
+	byteLen := (curve.Params().BitSize + 7) / 8
+	if format == pointCompressedEven || format == pointCompressedOdd {
+		return 1 + byteLen
+	}
+	return 1 + 2*byteLen
+}
+
+// compressedUnmarshaler is implemented by the curves which equation isn't the
+// y² = x³ - 3x + b assumed by elliptic.UnmarshalCompressed.
+type compressedUnmarshaler interface {
+	UnmarshalCompressed(data []byte) (x, y *big.Int)
+}
+
+// unmarshalPoint decodes a point in any of the SEC 1 section 2.3.4 formats.
+// Hybrid points carry both coordinates along with the parity of Y in the
+// leading octet, which must match. Formats not allowed by the policy are
+// refused. On error, x is nil.
+func unmarshalPoint(curve elliptic.Curve, data []byte, policy PointFormatPolicy) (x, y *big.Int) {
+	if curve == nil || len(data) == 0 || len(data) != pointSize(curve, data[0], policy) {
+		return
+	}
+	if _, ok := curve.(rawPointCurve); ok {
+		return new(big.Int).SetBytes(data), new(big.Int)
+	}
+	switch data[0] {
+	case pointCompressedEven, pointCompressedOdd:
+		if c, ok := curve.(compressedUnmarshaler); ok {
+			return c.UnmarshalCompressed(data)
+		}
+		return elliptic.UnmarshalCompressed(curve, data)
+	case pointHybridEven, pointHybridOdd:
+		uncompressed := make([]byte, len(data))
+		copy(uncompressed, data)
+		uncompressed[0] = pointUncompressed
+		if x, y = elliptic.Unmarshal(curve, uncompressed); x == nil {
+			return
+		}
+		if y.Bit(0) != uint(data[0]&1) {
+			return nil, nil
+		}
+		return
+	}
+	return elliptic.Unmarshal(curve, data)
+}
+
+// marshalPoint encodes a point in the uncompressed format, or the raw format
+// of the curve if it has one.
+func marshalPoint(curve elliptic.Curve, x, y *big.Int) []byte {
+	if c, ok := curve.(rawPointCurve); ok {
+		return x.FillBytes(make([]byte, c.pointLen()))
+	}
+	return elliptic.Marshal(curve, x, y)
+}
+
+// marshalCompressedPoint encodes a point in the compressed format, or the raw
+// format of the curve if it has one.
+func marshalCompressedPoint(curve elliptic.Curve, x, y *big.Int) []byte {
+	if _, ok := curve.(rawPointCurve); ok {
+		return marshalPoint(curve, x, y)
+	}
+	return elliptic.MarshalCompressed(curve, x, y)
+}
+
+// CompressPublicKey encodes a public key as a compressed SEC 1 point, or in
+// the raw format of curves such as X25519.
+func CompressPublicKey(pub *PublicKey) []byte {
+	return marshalCompressedPoint(pub.Curve, pub.X, pub.Y)
+}
+
+// DecompressPublicKey decodes a compressed SEC 1 point into a public key on the
+// given curve, with the default parameters for the curve.
+func DecompressPublicKey(curve elliptic.Curve, data []byte) (*PublicKey, error) {
+	if _, ok := curve.(rawPointCurve); ok {
+		return NewPublicKeyFromBytes(curve, data)
+	}
+	if len(data) == 0 || (data[0] != pointCompressedEven && data[0] != pointCompressedOdd) {
+		return nil, ErrInvalidPublicKey
+	}
+	return NewPublicKeyFromBytes(curve, data)
+}
+
+// CompressPoint converts a SEC 1 encoded point into the compressed format.
+func CompressPoint(curve elliptic.Curve, data []byte) ([]byte, error) {
+	x, y := unmarshalPoint(curve, data, AllowAllPoints)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return marshalCompressedPoint(curve, x, y), nil
+}
+
+// DecompressPoint converts a SEC 1 encoded point into the uncompressed format.
+func DecompressPoint(curve elliptic.Curve, data []byte) ([]byte, error) {
+	x, y := unmarshalPoint(curve, data, AllowAllPoints)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return marshalPoint(curve, x, y), nil
+}
+
+// Bytes encodes the public key as an uncompressed SEC 1 point, 65 bytes on
+// P-256, or in the raw format of curves such as X25519.
+func (pub *PublicKey) Bytes() []byte {
+	return marshalPoint(pub.Curve, pub.X, pub.Y)
+}
+
+// BytesCompressed encodes the public key as a compressed SEC 1 point, 33
+// bytes on P-256, or in the raw format of curves such as X25519.
+func (pub *PublicKey) BytesCompressed() []byte {
+	return marshalCompressedPoint(pub.Curve, pub.X, pub.Y)
+}
+
+// ParsePublicKeyBytes decodes a public key on curve encoded by Bytes or
+// BytesCompressed, with the default parameters for the curve. Unlike
+// NewPublicKeyFromBytes, it refuses the hybrid format.
+func ParsePublicKeyBytes(curve elliptic.Curve, b []byte) (*PublicKey, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	x, y := unmarshalPoint(curve, b, AllowCompressedPoints)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return NewPublicKey(curve, x, y)
+}
@@ -0,0 +1,287 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure that public keys and raw points survive a compression round trip.
+func TestPointCompression(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		compressed := CompressPublicKey(&prv.PublicKey)
+		if len(compressed) != 1+(c.Params().BitSize+7)/8 {
+			fmt.Println(name, "ecies: wrong compressed point size", len(compressed))
+			t.FailNow()
+		}
+		pub, err := DecompressPublicKey(c, compressed)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) || pub.Params != ParamsFromCurve(c) {
+			fmt.Println(name, "ecies: failed to decompress public key")
+			t.FailNow()
+		}
+
+		uncompressed := elliptic.Marshal(c, prv.X, prv.Y)
+		if _, err := DecompressPublicKey(c, uncompressed); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: decompressed an uncompressed point")
+			t.FailNow()
+		}
+		if out, err := CompressPoint(c, uncompressed); err != nil || !bytes.Equal(out, compressed) {
+			fmt.Println(name, "ecies: failed to compress point", err)
+			t.FailNow()
+		}
+		if out, err := DecompressPoint(c, compressed); err != nil || !bytes.Equal(out, uncompressed) {
+			fmt.Println(name, "ecies: failed to decompress point", err)
+			t.FailNow()
+		}
+
+		// An X coordinate beyond the field prime is never a valid point.
+		for i := 1; i < len(compressed); i++ {
+			compressed[i] = 0xff
+		}
+		if _, err := DecompressPoint(c, compressed); err != ErrInvalidPublicKey {
+			fmt.Println(name, "ecies: decompressed an invalid point")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that the public key constructors validate their input.
+func TestNewPublicKey(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	pub, err := NewPublicKey(DefaultCurve, prv.X, prv.Y)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if !cmpPublic(prv.PublicKey, *pub) || pub.Params != ParamsFromCurve(DefaultCurve) {
+		fmt.Println("ecies: public key doesn't match")
+		t.FailNow()
+	}
+
+	for _, data := range [][]byte{
+		elliptic.Marshal(DefaultCurve, prv.X, prv.Y),
+		elliptic.MarshalCompressed(DefaultCurve, prv.X, prv.Y),
+	} {
+		pub, err := NewPublicKeyFromBytes(DefaultCurve, data)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if !cmpPublic(prv.PublicKey, *pub) {
+			fmt.Println("ecies: public key doesn't match")
+			t.FailNow()
+		}
+	}
+
+	offCurve := new(big.Int).Add(prv.Y, big.NewInt(1))
+	if _, err := NewPublicKey(DefaultCurve, prv.X, offCurve); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: accepted a point off the curve")
+		t.FailNow()
+	}
+	if _, err := NewPublicKey(DefaultCurve, nil, prv.Y); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: accepted a nil coordinate")
+		t.FailNow()
+	}
+	if _, err := NewPublicKey(nil, prv.X, prv.Y); err != ErrInvalidCurve {
+		fmt.Println("ecies: accepted a nil curve")
+		t.FailNow()
+	}
+	if _, err := NewPublicKeyFromBytes(DefaultCurve, []byte{pointUncompressed, 1, 2}); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: accepted a truncated point")
+		t.FailNow()
+	}
+}
+
+// Ensure that a private key can be rebuilt from its scalar, and that
+// out-of-range scalars are rejected.
+func TestNewPrivateKey(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		prv2, err := NewPrivateKey(c, prv.D.Bytes())
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		} else if !cmpPrivate(prv, prv2) || prv2.Params != ParamsFromCurve(c) {
+			fmt.Println(name, "ecies: private key doesn't match")
+			t.FailNow()
+		}
+
+		n := c.Params().N
+		for _, d := range []*big.Int{
+			big.NewInt(0),
+			n,
+			new(big.Int).Add(n, big.NewInt(1)),
+		} {
+			if _, err := NewPrivateKey(c, d.Bytes()); err != ErrInvalidPrivateKey {
+				fmt.Println(name, "ecies: accepted an out of range scalar", d)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Ensure that the secp256k1 arithmetic matches known multiples of the generator.
+func TestSecp256k1(t *testing.T) {
+	c := Secp256k1()
+	for k, expected := range map[string]string{
+		"02": "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+		"03": "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		"aa5e28d6a97a2479a65527f7290311a3624d4cc0fa1578598ee3c2613bf99522": "0234f9460f0e4f08393d192b3c5133a6ba099aa0ad9fd54ebccfacdfa239ff49c6",
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140": "0379be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+	} {
+		d, _ := hex.DecodeString(k)
+		x, y := c.ScalarBaseMult(d)
+		if out := hex.EncodeToString(elliptic.MarshalCompressed(c, x, y)); out != expected {
+			fmt.Println(k, "ecies: unexpected secp256k1 point", out)
+			t.FailNow()
+		}
+		out, _ := hex.DecodeString(expected)
+		if x2, y2 := unmarshalPoint(c, out, AllowAllPoints); x2 == nil || x2.Cmp(x) != 0 || y2.Cmp(y) != 0 {
+			fmt.Println(k, "ecies: failed to decompress secp256k1 point")
+			t.FailNow()
+		}
+	}
+
+	x, y := c.Add(c.Params().Gx, c.Params().Gy, c.Params().Gx, c.Params().Gy)
+	x2, y2 := c.Double(c.Params().Gx, c.Params().Gy)
+	if x.Cmp(x2) != 0 || y.Cmp(y2) != 0 {
+		fmt.Println("ecies: secp256k1 G+G doesn't match 2G")
+		t.FailNow()
+	}
+}
+
+// Ensure that secp256k1 ECDSA keys can be used for encryption, and survive a
+// DER round trip.
+func TestSecp256k1ECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(Secp256k1(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv := ImportECDSA(key)
+	if prv.Params != ECIES_AES128_SHA256 {
+		fmt.Println("ecies: unexpected secp256k1 parameters")
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: secp256k1 message not decrypted", err)
+		t.FailNow()
+	}
+}
+
+// foreignSecp256k1 is secp256k1 as implemented by another package, under
+// another name.
+type foreignSecp256k1 struct {
+	*elliptic.CurveParams
+}
+
+func (c foreignSecp256k1) Params() *elliptic.CurveParams {
+	return c.CurveParams
+}
+
+// Ensure that keys on secp256k1 curves of other packages are imported on
+// Secp256k1(), by name or by parameters.
+func TestSecp256k1Foreign(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, Secp256k1(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	named := *Secp256k1().Params()
+	renamed := named
+	renamed.Name = "S256"
+	for _, curve := range []elliptic.Curve{&named, foreignSecp256k1{&renamed}} {
+		key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: prv.X, Y: prv.Y}, D: prv.D}
+		imported := ImportECDSA(key)
+		if imported.Curve != Secp256k1() || imported.Params != ECIES_AES128_SHA256 {
+			fmt.Println(curve.Params().Name, "ecies: foreign secp256k1 key not imported on Secp256k1()")
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, ImportECDSAPublic(&key.PublicKey), []byte("Hello, world."), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || string(pt) != "Hello, world." {
+			fmt.Println(curve.Params().Name, "ecies: message to a foreign secp256k1 key not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	other := *elliptic.P256().Params()
+	other.Name = "other"
+	if pub := ImportECDSAPublic(&ecdsa.PublicKey{Curve: &other}); pub.Curve != &other {
+		fmt.Println("ecies: unrelated curve imported as secp256k1")
+		t.FailNow()
+	}
+}
+
+// Ensure public keys survive a round trip through both raw encodings, and that
+// the hybrid format and malformed encodings are refused.
+func TestPublicKeyBytes(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521(), Secp256k1(), X25519()} {
+		name := curve.Params().Name
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		pub := &prv.PublicKey
+		for _, b := range [][]byte{pub.Bytes(), pub.BytesCompressed()} {
+			decoded, err := ParsePublicKeyBytes(curve, b)
+			if err != nil || !decoded.Equal(pub) {
+				fmt.Println(name, "public key not restored", err)
+				t.FailNow()
+			}
+		}
+		if _, ok := curve.(rawPointCurve); ok {
+			continue
+		}
+		byteLen := (curve.Params().BitSize + 7) / 8
+		if len(pub.Bytes()) != 1+2*byteLen || len(pub.BytesCompressed()) != 1+byteLen {
+			fmt.Println(name, "unexpected encoding sizes")
+			t.FailNow()
+		}
+		hybrid := pub.Bytes()
+		hybrid[0] = byte(pointHybridEven | pub.Y.Bit(0))
+		if _, err = ParsePublicKeyBytes(curve, hybrid); err != ErrInvalidPublicKey {
+			fmt.Println(name, "hybrid point accepted", err)
+			t.FailNow()
+		}
+		if _, err = ParsePublicKeyBytes(curve, pub.Bytes()[:byteLen]); err != ErrInvalidPublicKey {
+			fmt.Println(name, "truncated point accepted", err)
+			t.FailNow()
+		}
+	}
+}
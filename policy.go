@@ -0,0 +1,101 @@
+package ecies
+
+// Organization-wide restrictions on the curves and suites in use.
+
+import (
+	"crypto/elliptic"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrPolicyViolation = newError(KindParams, "ecies: rejected by policy")
+
+// Names of the compatibility suites, for Policy.ForbiddenSuites.
+const (
+	SuiteBIE1      = "bie1"
+	SuiteEccrypto  = "eccrypto"
+	SuiteBotan     = "botan"
+	SuiteGeth      = "geth"
+	SuiteApple     = "apple"
+	SuiteSealedBox = "sealedbox"
+	SuiteEciespy   = "eciespy"
+)
+
+// Policy restricts the curves and parameters which may be used to encrypt and
+// decrypt. A policy can be installed globally with SetPolicy, and per call
+// with EncryptOptions and DecryptOptions; both are enforced.
+type Policy struct {
+	// Curves lists the allowed curves. If empty, any curve is allowed.
+	Curves []elliptic.Curve
+	// MinHashSize is the minimum output size, in bytes, of the KDF and MAC
+	// hash functions.
+	MinHashSize int
+	// MinKeyLen is the minimum length, in bytes, of the symmetric keys.
+	MinKeyLen int
+	// ForbiddenSuites lists the compatibility suites which must not be used,
+	// e.g. SuiteBIE1.
+	ForbiddenSuites []string
+}
+
+type policyHolder struct {
+	policy *Policy
+}
+
+var globalPolicy atomic.Value // policyHolder
+
+// SetPolicy installs the policy enforced on all encryptions and decryptions.
+// A nil policy removes it.
+func SetPolicy(p *Policy) {
+	globalPolicy.Store(policyHolder{p})
+}
+
+// CurrentPolicy returns the global policy, or nil if there is none.
+func CurrentPolicy() *Policy {
+	if h, ok := globalPolicy.Load().(policyHolder); ok {
+		return h.policy
+	}
+	return nil
+}
+
+func (p *Policy) check(suite string, curve elliptic.Curve, hashSize, keyLen int) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Curves) > 0 {
+		allowed := false
+		for _, c := range p.Curves {
+			if c == curve {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: curve %s is not allowed", ErrPolicyViolation, curve.Params().Name)
+		}
+	}
+	if hashSize < p.MinHashSize {
+		return fmt.Errorf("%w: %d-byte hash is too short", ErrPolicyViolation, hashSize)
+	}
+	if keyLen < p.MinKeyLen {
+		return fmt.Errorf("%w: %d-byte key is too short", ErrPolicyViolation, keyLen)
+	}
+	for _, s := range p.ForbiddenSuites {
+		if s == suite {
+			return fmt.Errorf("%w: suite %s is forbidden", ErrPolicyViolation, suite)
+		}
+	}
+	return nil
+}
+
+// enforcePolicy checks the global policy, and the per call one if not nil.
+func enforcePolicy(p *Policy, suite string, curve elliptic.Curve, hashSize, keyLen int) error {
+	if err := CurrentPolicy().check(suite, curve, hashSize, keyLen); err != nil {
+		return err
+	}
+	return p.check(suite, curve, hashSize, keyLen)
+}
+
+// enforceParams checks the native ECIES suite with the given parameters.
+func enforceParams(p *Policy, curve elliptic.Curve, params *ECIESParams) error {
+	return enforcePolicy(p, "", curve, params.hashSize(), params.KeyLen)
+}
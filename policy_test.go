@@ -0,0 +1,67 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure global and per call policies are enforced on both paths.
+func TestPolicy(t *testing.T) {
+	prv256, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv384, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct256, err := Encrypt(rand.Reader, &prv256.PublicKey, []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	SetPolicy(&Policy{
+		Curves: []elliptic.Curve{elliptic.P384(), elliptic.P521()},
+	})
+	defer SetPolicy(nil)
+
+	if _, err = Encrypt(rand.Reader, &prv256.PublicKey, []byte("message"), nil, nil); !errors.Is(err, ErrPolicyViolation) {
+		fmt.Println("ecies: encrypted to a curve outside the policy", err)
+		t.FailNow()
+	}
+	if _, err = Decrypt(prv256, ct256, nil, nil); !errors.Is(err, ErrPolicyViolation) {
+		fmt.Println("ecies: decrypted with a curve outside the policy", err)
+		t.FailNow()
+	}
+
+	ct384, err := Encrypt(rand.Reader, &prv384.PublicKey, []byte("message"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	strict := &Policy{MinKeyLen: 32, MinHashSize: 64}
+	if _, err = DecryptWithOptions(prv384, ct384, nil, nil, &DecryptOptions{Policy: strict}); !errors.Is(err, ErrPolicyViolation) {
+		fmt.Println("ecies: per call policy not enforced on decrypt", err)
+		t.FailNow()
+	}
+	if _, err = EncryptWithOptions(rand.Reader, &prv384.PublicKey, []byte("message"), nil, nil, &EncryptOptions{Policy: strict}); !errors.Is(err, ErrPolicyViolation) {
+		fmt.Println("ecies: per call policy not enforced on encrypt", err)
+		t.FailNow()
+	}
+	if _, err = Decrypt(prv384, ct384, nil, nil); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	SetPolicy(nil)
+	if _, err = Decrypt(prv256, ct256, nil, nil); err != nil {
+		fmt.Println("ecies: policy still enforced after removal", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,112 @@
+package ecies
+
+// Precomputed ephemeral keys, for high-throughput encryption: the scalar
+// generation and base point multiplication of each ephemeral key run ahead of
+// time on background goroutines.
+
+import (
+	"crypto/elliptic"
+	"io"
+	"sync"
+)
+
+var ErrPoolClosed = newError(KindState, "ecies: ephemeral key pool closed")
+
+// EphemeralPool holds ephemeral key pairs for a curve, generated in the
+// background. Each key pair is handed out once. Set it as EncryptOptions.Pool
+// to encrypt with its keys.
+type EphemeralPool struct {
+	rand  io.Reader
+	curve elliptic.Curve
+	keys  chan *PrivateKey
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// NewEphemeralPool starts workers goroutines which keep up to size key pairs
+// on curve ready. The random source is read concurrently, so it must be safe
+// for concurrent use, as crypto/rand.Reader is. The pool must be closed to
+// stop the goroutines.
+func NewEphemeralPool(rand io.Reader, curve elliptic.Curve, size, workers int) (*EphemeralPool, error) {
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if size < 1 || workers < 1 {
+		return nil, ErrInvalidParams
+	}
+	p := &EphemeralPool{
+		rand:  rand,
+		curve: curve,
+		keys:  make(chan *PrivateKey, size),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.fill()
+	}
+	return p, nil
+}
+
+func (p *EphemeralPool) fill() {
+	defer p.wg.Done()
+	for {
+		R, err := GenerateKey(p.rand, p.curve, nil)
+		if err != nil {
+			// get reports the error once the pool runs dry.
+			return
+		}
+		select {
+		case p.keys <- R:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Curve returns the curve of the key pairs of the pool.
+func (p *EphemeralPool) Curve() elliptic.Curve {
+	return p.curve
+}
+
+// get returns a key pair of the pool, or a new one if the pool is empty, so
+// that encryption never waits on the workers.
+func (p *EphemeralPool) get() (*PrivateKey, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+	select {
+	case R := <-p.keys:
+		return R, nil
+	default:
+		return GenerateKey(p.rand, p.curve, nil)
+	}
+}
+
+// Close stops the workers and discards the remaining key pairs.
+func (p *EphemeralPool) Close() {
+	p.once.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+		for len(p.keys) > 0 {
+			<-p.keys
+		}
+	})
+}
+
+// ephemeral returns an ephemeral key pair for pub from the pool, checking
+// that it is on the curve of pub.
+func (p *EphemeralPool) ephemeral(pub *PublicKey) (*PrivateKey, error) {
+	if pub.Curve != p.curve {
+		return nil, ErrInvalidCurve
+	}
+	return p.get()
+}
+
+// EncryptWithPool encrypts a message like Encrypt, with an ephemeral key pair
+// from pool. The random source is still used for the IV or nonce.
+func EncryptWithPool(rand io.Reader, pool *EphemeralPool, pub *PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	return EncryptWithOptions(rand, pub, m, s1, s2, &EncryptOptions{Pool: pool})
+}
@@ -0,0 +1,82 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Ensure that pooled ephemeral keys are never reused, including under
+// concurrent use and once the pool runs dry, and that the pool only serves
+// its curve.
+func TestEphemeralPool(t *testing.T) {
+	pool, err := NewEphemeralPool(rand.Reader, elliptic.P256(), 4, 2)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, pool.")
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ct, err := EncryptWithPool(rand.Reader, pool, &prv.PublicKey, message, nil, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+				errs <- fmt.Errorf("ecies: pooled message not decrypted: %v", err)
+				return
+			}
+			mu.Lock()
+			seen[string(ct[:65])] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if len(seen) != 64 {
+		fmt.Println("ecies: pooled ephemeral key reused")
+		t.FailNow()
+	}
+
+	other, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = EncryptWithPool(rand.Reader, pool, &other.PublicKey, message, nil, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: pool used for another curve", err)
+		t.FailNow()
+	}
+	opts := &EncryptOptions{Pool: pool, Hedged: true}
+	if _, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil, opts); err != ErrInvalidParams {
+		fmt.Println("ecies: pool combined with hedging", err)
+		t.FailNow()
+	}
+
+	pool.Close()
+	pool.Close()
+	if _, err = EncryptWithPool(rand.Reader, pool, &prv.PublicKey, message, nil, nil); err != ErrPoolClosed {
+		fmt.Println("ecies: closed pool used", err)
+		t.FailNow()
+	}
+}
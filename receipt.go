@@ -0,0 +1,151 @@
+package ecies
+
+// Signed encryption receipts, for auditors to confirm what was encrypted to
+// whom without access to the plaintext.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+var (
+	ErrInvalidReceipt    = newError(KindEncoding, "ecies: invalid encryption receipt")
+	ErrReceiptMismatch   = newError(KindAuthentication, "ecies: receipt does not match the ciphertext or recipient")
+	ErrUnsupportedSigner = newError(KindUnsupported, "ecies: unsupported signing key")
+)
+
+// receiptContext separates receipt signatures from any other use of the key.
+var receiptContext = []byte("ecies-receipt-v1\x00")
+
+// The signed body of a receipt is the suite identifier, the SHA-256 hashes of
+// the ciphertext and of the recipient public key, and the creation time in
+// Unix milliseconds as a 64-bit big-endian integer.
+const receiptBodySize = 1 + sha256.Size + sha256.Size + 8
+
+// Receipt attests that a ciphertext was encrypted to a recipient with a
+// suite. It is signed by the encryptor.
+type Receipt struct {
+	Suite          byte
+	CiphertextHash []byte
+	Recipient      []byte
+	Created        time.Time
+	Signature      []byte
+}
+
+// keyFingerprint returns the fingerprint of the public key as a slice.
+func keyFingerprint(pub *PublicKey) []byte {
+	h := pub.Fingerprint()
+	return h[:]
+}
+
+func (r *Receipt) body() []byte {
+	out := make([]byte, 0, receiptBodySize)
+	out = append(out, r.Suite)
+	out = append(out, r.CiphertextHash...)
+	out = append(out, r.Recipient...)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], unixMillis(r.Created))
+	return append(out, ts[:]...)
+}
+
+// signedMessage returns what is signed: the context and body, hashed unless
+// the key signs messages directly.
+func (r *Receipt) signedMessage(pub crypto.PublicKey) ([]byte, crypto.SignerOpts, error) {
+	msg := append(append([]byte{}, receiptContext...), r.body()...)
+	switch pub.(type) {
+	case *ecdsa.PublicKey:
+		h := sha256.Sum256(msg)
+		return h[:], crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return msg, crypto.Hash(0), nil
+	}
+	return nil, nil, ErrUnsupportedSigner
+}
+
+// NewReceipt returns a receipt for c, encrypted to recipient, signed with an
+// ECDSA or Ed25519 signer.
+func NewReceipt(rand io.Reader, signer crypto.Signer, recipient *PublicKey, c []byte) (*Receipt, error) {
+	params := recipient.Params
+	if params == nil {
+		if params = ParamsFromCurve(recipient.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	suite, ok := suiteID(params)
+	if !ok {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	h := sha256.Sum256(c)
+	r := &Receipt{
+		Suite:          suite,
+		CiphertextHash: h[:],
+		Recipient:      keyFingerprint(recipient),
+		Created:        time.Now().UTC().Truncate(time.Millisecond),
+	}
+	msg, opts, err := r.signedMessage(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	if r.Signature, err = signer.Sign(rand, msg, opts); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Verify checks the signature of the receipt with the encryptor's public
+// key. If recipient or c are not nil, they must match the receipt.
+func (r *Receipt) Verify(signer crypto.PublicKey, recipient *PublicKey, c []byte) error {
+	if len(r.CiphertextHash) != sha256.Size || len(r.Recipient) != sha256.Size {
+		return ErrInvalidReceipt
+	}
+	msg, _, err := r.signedMessage(signer)
+	if err != nil {
+		return err
+	}
+	switch pub := signer.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, msg, r.Signature) {
+			return ErrInvalidReceipt
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, msg, r.Signature) {
+			return ErrInvalidReceipt
+		}
+	}
+	if recipient != nil && !bytes.Equal(r.Recipient, keyFingerprint(recipient)) {
+		return ErrReceiptMismatch
+	}
+	if c != nil {
+		if h := sha256.Sum256(c); !bytes.Equal(r.CiphertextHash, h[:]) {
+			return ErrReceiptMismatch
+		}
+	}
+	return nil
+}
+
+// Marshal encodes the receipt: the signed body followed by the signature.
+func (r *Receipt) Marshal() []byte {
+	return append(r.body(), r.Signature...)
+}
+
+// ParseReceipt decodes a receipt encoded by Marshal. It does not verify it.
+func ParseReceipt(in []byte) (*Receipt, error) {
+	if len(in) <= receiptBodySize {
+		return nil, ErrInvalidReceipt
+	}
+	r := &Receipt{Suite: in[0]}
+	n := 1
+	r.CiphertextHash = append([]byte{}, in[n:n+sha256.Size]...)
+	n += sha256.Size
+	r.Recipient = append([]byte{}, in[n:n+sha256.Size]...)
+	n += sha256.Size
+	r.Created = fromUnixMillis(binary.BigEndian.Uint64(in[n:])).UTC()
+	r.Signature = append([]byte{}, in[receiptBodySize:]...)
+	return r, nil
+}
@@ -0,0 +1,71 @@
+package ecies
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure receipts verify against the ciphertext and recipient they were
+// issued for, and nothing else.
+func TestReceipt(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	c, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("audited"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, signer := range []crypto.Signer{ecKey, edKey} {
+		r, err := NewReceipt(rand.Reader, signer, &prv.PublicKey, c)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		r, err = ParseReceipt(r.Marshal())
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if err = r.Verify(signer.Public(), &prv.PublicKey, c); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if err = r.Verify(signer.Public(), &other.PublicKey, c); err != ErrReceiptMismatch {
+			fmt.Println("ecies: receipt accepted for another recipient", err)
+			t.FailNow()
+		}
+		if err = r.Verify(signer.Public(), nil, c[1:]); err != ErrReceiptMismatch {
+			fmt.Println("ecies: receipt accepted for another ciphertext", err)
+			t.FailNow()
+		}
+		r.Suite++
+		if err = r.Verify(signer.Public(), nil, nil); err != ErrInvalidReceipt {
+			fmt.Println("ecies: tampered receipt accepted", err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ecies
+
+// Recipient headers, naming the key a ciphertext is for, so that routers can
+// dispatch messages to the service holding the key without decrypting them.
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+var (
+	ErrMissingRecipient = newError(KindEncoding, "ecies: recipient header missing")
+	ErrKeyIDTooLarge    = newError(KindParams, "ecies: key ID too long")
+)
+
+// The recipient header is prepended to the ciphertext, ahead of any validity
+// window and timestamp: a magic, the fingerprint of the recipient public key
+// (see PublicKey.Fingerprint), and the length-prefixed key ID. It is
+// authenticated as the head of the MAC shared information.
+var recipientMagic = []byte("ECRCPT")
+
+const (
+	recipientHeaderSize = 6 + sha256.Size + 1
+	maxKeyIDSize        = 255
+)
+
+type recipientHeader struct {
+	header      []byte
+	fingerprint [sha256.Size]byte
+	keyID       string
+}
+
+func newRecipientHeader(pub *PublicKey, keyID string) (*recipientHeader, error) {
+	if len(keyID) > maxKeyIDSize {
+		return nil, ErrKeyIDTooLarge
+	}
+	fp := pub.Fingerprint()
+	header := make([]byte, 0, recipientHeaderSize+len(keyID))
+	header = append(append(header, recipientMagic...), fp[:]...)
+	header = append(append(header, byte(len(keyID))), keyID...)
+	return &recipientHeader{header: header, fingerprint: fp, keyID: keyID}, nil
+}
+
+// parseRecipientHeader splits the recipient header off c.
+func parseRecipientHeader(c []byte) (*recipientHeader, []byte, error) {
+	if len(c) < recipientHeaderSize || !bytes.HasPrefix(c, recipientMagic) {
+		return nil, nil, ErrMissingRecipient
+	}
+	n := recipientHeaderSize + int(c[recipientHeaderSize-1])
+	if len(c) < n {
+		return nil, nil, ErrMissingRecipient
+	}
+	r := &recipientHeader{header: c[:n], keyID: string(c[recipientHeaderSize:n])}
+	copy(r.fingerprint[:], c[len(recipientMagic):])
+	return r, c[n:], nil
+}
+
+// check tells whether the header names pub. The header is only authenticated
+// once the message is.
+func (r *recipientHeader) check(pub *PublicKey) error {
+	if r.fingerprint != pub.Fingerprint() {
+		return ErrNotRecipient
+	}
+	return nil
+}
+
+// PeekRecipient returns the fingerprint of the recipient public key and the
+// key ID of a ciphertext encrypted with EncryptOptions.RecipientHeader, raw
+// or wrapped in the versioned envelope. Nothing is authenticated: the header
+// is only fit to route the message, the recipient still checks it when
+// decrypting with DecryptOptions.RecipientHeader.
+func PeekRecipient(c []byte) (fingerprint [sha256.Size]byte, keyID string, err error) {
+	if isEnvelope(c) {
+		c = c[envelopeHeaderSize:]
+	}
+	r, _, err := parseRecipientHeader(c)
+	if err != nil {
+		return fingerprint, "", err
+	}
+	return r.fingerprint, r.keyID, nil
+}
@@ -0,0 +1,83 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Ensure the recipient header names the key, is authenticated, and lets a
+// keyring pick the key without trying the others.
+func TestRecipientHeader(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, router.")
+	ct, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil, &EncryptOptions{
+		RecipientHeader: true,
+		KeyID:           "signing-2026",
+		Timestamp:       time.Now(),
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	env, err := EncodeEnvelope(&prv.PublicKey, ct)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	opts := &DecryptOptions{RecipientHeader: true, Timestamped: true}
+	for _, c := range [][]byte{ct, env} {
+		fp, id, err := PeekRecipient(c)
+		if err != nil || fp != prv.PublicKey.Fingerprint() || id != "signing-2026" {
+			fmt.Println("ecies: recipient not peeked", err)
+			t.FailNow()
+		}
+		pt, err := DecryptWithOptions(prv, c, nil, nil, opts)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: message with a recipient header not decrypted", err)
+			t.FailNow()
+		}
+	}
+	if _, err = DecryptWithOptions(other, ct, nil, nil, opts); err != ErrNotRecipient {
+		fmt.Println("ecies: message decrypted for another recipient", err)
+		t.FailNow()
+	}
+
+	tampered := bytes.Clone(ct)
+	tampered[recipientHeaderSize] = 'S'
+	if _, err = DecryptWithOptions(prv, tampered, nil, nil, opts); err != ErrInvalidMessage {
+		fmt.Println("ecies: recipient header not authenticated")
+		t.FailNow()
+	}
+
+	kr := NewKeyring(prv, other)
+	if pt, err := kr.DecryptAnyWithOptions(ct, nil, nil, opts); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: keyring didn't pick the recipient key", err)
+		t.FailNow()
+	}
+	if _, _, err = PeekRecipient(ct[len(recipientMagic):]); err != ErrMissingRecipient {
+		fmt.Println("ecies: recipient peeked without a header", err)
+		t.FailNow()
+	}
+	_, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil, &EncryptOptions{
+		RecipientHeader: true,
+		KeyID:           string(make([]byte, 256)),
+	})
+	if !errors.Is(err, ErrKeyIDTooLarge) {
+		fmt.Println("ecies: key ID too long accepted", err)
+		t.FailNow()
+	}
+}
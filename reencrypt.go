@@ -0,0 +1,248 @@
+package ecies
+
+// Bulk re-encryption of stored ciphertexts, for key rotation.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var ErrCheckpointNotFound = newError(KindState, "ecies: re-encryption checkpoint not found")
+
+// CiphertextIterator walks a corpus of ciphertexts. The order must be stable
+// across runs for ReEncryptor checkpoints to be usable.
+type CiphertextIterator interface {
+	// Next returns the next ciphertext and its identifier, or io.EOF once
+	// the corpus is exhausted.
+	Next(ctx context.Context) (id string, c []byte, err error)
+}
+
+// CiphertextSink stores re-encrypted ciphertexts. Put is called concurrently
+// by the ReEncryptor workers.
+type CiphertextSink interface {
+	Put(ctx context.Context, id string, c []byte) error
+}
+
+// ReEncryptProgress reports the state of a ReEncryptor run.
+type ReEncryptProgress struct {
+	// Done counts the ciphertexts re-encrypted by this run, and Skipped the
+	// ones skipped up to the resume checkpoint.
+	Done    int
+	Skipped int
+	// Checkpoint is the identifier of the last ciphertext such that it and
+	// all the ones before it are stored. Pass it as ReEncryptor.Resume to
+	// carry on after an interruption.
+	Checkpoint string
+}
+
+// ReEncryptor decrypts a corpus of ciphertexts with the old keys, and
+// encrypts them again to new recipients.
+type ReEncryptor struct {
+	// Keys are tried in order to decrypt each ciphertext, which may be one
+	// produced by Encrypt or by EncryptToMany.
+	Keys []KeyProvider
+	// Recipient is the public key the ciphertexts are re-encrypted to.
+	Recipient *PublicKey
+	// Recipients, instead of Recipient, re-encrypts the ciphertexts to
+	// several public keys with EncryptToMany.
+	Recipients []*PublicKey
+	// S1 and S2 are the shared information for both decryption and
+	// encryption.
+	S1, S2 []byte
+	// Parallelism bounds the number of ciphertexts processed concurrently.
+	// It defaults to 1.
+	Parallelism int
+	// Resume skips the ciphertexts up to and including this checkpoint.
+	Resume string
+	// Progress, if set, is called after every stored ciphertext. It is not
+	// called concurrently.
+	Progress func(ReEncryptProgress)
+}
+
+type reEncryptJob struct {
+	seq int
+	id  string
+	c   []byte
+}
+
+type reEncryptResult struct {
+	seq int
+	id  string
+	err error
+}
+
+// Run re-encrypts all the ciphertexts of src into dst. It stops at the first
+// error, returning the progress made so far, whose checkpoint can be used
+// to resume.
+func (r *ReEncryptor) Run(ctx context.Context, src CiphertextIterator, dst CiphertextSink) (ReEncryptProgress, error) {
+	progress := ReEncryptProgress{Checkpoint: r.Resume}
+	if len(r.Keys) == 0 || (r.Recipient == nil) == (len(r.Recipients) == 0) {
+		return progress, ErrInvalidParams
+	}
+	workers := r.Parallelism
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan reEncryptJob)
+	results := make(chan reEncryptResult)
+	var srcErr error
+	go func() {
+		defer close(jobs)
+		skipping := r.Resume != ""
+		for seq := 0; ; {
+			id, c, err := src.Next(ctx)
+			if err == io.EOF {
+				if skipping {
+					srcErr = ErrCheckpointNotFound
+				}
+				return
+			} else if err != nil {
+				srcErr = err
+				return
+			}
+			if skipping {
+				progress.Skipped++
+				skipping = id != r.Resume
+				continue
+			}
+			select {
+			case jobs <- reEncryptJob{seq, id, c}:
+				seq++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := r.reEncrypt(ctx, job, dst)
+				if err != nil {
+					err = fmt.Errorf("ecies: re-encrypting %s: %w", job.id, err)
+				}
+				results <- reEncryptResult{job.seq, job.id, err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Advance the checkpoint over the contiguous run of stored ciphertexts.
+	var err error
+	next := 0
+	pending := make(map[int]string)
+	for res := range results {
+		if res.err != nil {
+			if err == nil {
+				err = res.err
+				cancel()
+			}
+			continue
+		}
+		progress.Done++
+		pending[res.seq] = res.id
+		for id, ok := pending[next]; ok; id, ok = pending[next] {
+			delete(pending, next)
+			progress.Checkpoint = id
+			next++
+		}
+		if r.Progress != nil {
+			r.Progress(progress)
+		}
+	}
+	if err == nil {
+		err = srcErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return progress, err
+}
+
+// reEncrypt keeps the format of the input, i.e. envelopes stay envelopes,
+// unless there are several recipients.
+func (r *ReEncryptor) reEncrypt(ctx context.Context, job reEncryptJob, dst CiphertextSink) error {
+	pubs := r.Recipients
+	if r.Recipient != nil {
+		pubs = []*PublicKey{r.Recipient}
+	}
+	c, err := reEncrypt(entropy(nil), r.Keys, pubs, job.c, r.S1, r.S2)
+	if err != nil {
+		return err
+	}
+	return dst.Put(ctx, job.id, c)
+}
+
+// reEncrypt decrypts c with the first of keys that can, as a single or a
+// multi-recipient ciphertext, and encrypts the message again to pubs: to a
+// single one in the format of c, or to several with EncryptToMany. The
+// message is wiped afterwards.
+func reEncrypt(rand io.Reader, keys []KeyProvider, pubs []*PublicKey, c, s1, s2 []byte) ([]byte, error) {
+	m, err := decryptWithAny(keys, c, s1, s2)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(m)
+	if len(pubs) > 1 {
+		return EncryptToMany(rand, pubs, m, s1, s2)
+	}
+	pub := pubs[0]
+	out, err := Encrypt(rand, pub, m, s1, s2)
+	if err != nil {
+		return nil, err
+	}
+	if isEnvelope(c) {
+		return EncodeEnvelope(pub, out)
+	}
+	return out, nil
+}
+
+// decryptWithAny decrypts c with the first of keys that can. Ciphertexts
+// which no key decrypts are then tried as multi-recipient ones, and the
+// error of the first attempt is returned if they aren't either.
+func decryptWithAny(keys []KeyProvider, c, s1, s2 []byte) (m []byte, err error) {
+	for _, key := range keys {
+		if m, err = Decrypt(key, c, s1, s2); err == nil {
+			return m, nil
+		}
+	}
+	for _, key := range keys {
+		if m, manyErr := DecryptFromMany(key, c, s1, s2); manyErr == nil {
+			return m, nil
+		}
+	}
+	return nil, err
+}
+
+// ReEncrypt decrypts a ciphertext with the old key and encrypts it to the new
+// recipient, without handing the message to the caller, e.g. to rotate the
+// key of a stored ciphertext. Envelopes stay envelopes.
+func ReEncrypt(rand io.Reader, oldKey KeyProvider, newPub *PublicKey, c, s1, s2 []byte) ([]byte, error) {
+	return reEncrypt(rand, []KeyProvider{oldKey}, []*PublicKey{newPub}, c, s1, s2)
+}
+
+// ReEncryptBatch re-encrypts several ciphertexts like ReEncrypt. It stops at
+// the first one that fails, with an error naming its index. For corpora that
+// don't fit in memory, or to resume after a failure, see ReEncryptor.
+func ReEncryptBatch(rand io.Reader, oldKey KeyProvider, newPub *PublicKey, cs [][]byte, s1, s2 []byte) ([][]byte, error) {
+	out := make([][]byte, len(cs))
+	for i, c := range cs {
+		var err error
+		if out[i], err = ReEncrypt(rand, oldKey, newPub, c, s1, s2); err != nil {
+			return nil, fmt.Errorf("ecies: re-encrypting ciphertext %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
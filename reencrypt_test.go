@@ -0,0 +1,230 @@
+package ecies
+
+import (
+	"bytes"
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+type sliceIterator struct {
+	ids []string
+	cts map[string][]byte
+	pos int
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (string, []byte, error) {
+	if it.pos == len(it.ids) {
+		return "", nil, io.EOF
+	}
+	id := it.ids[it.pos]
+	it.pos++
+	return id, it.cts[id], nil
+}
+
+type mapSink struct {
+	mu     sync.Mutex
+	cts    map[string][]byte
+	failOn string
+}
+
+func (s *mapSink) Put(ctx context.Context, id string, c []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == s.failOn {
+		return fmt.Errorf("storage failure")
+	}
+	s.cts[id] = c
+	return nil
+}
+
+// Ensure a corpus is re-encrypted to the new key, and that an interrupted run
+// can be resumed from its checkpoint.
+func TestReEncryptor(t *testing.T) {
+	oldKey, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	newKey, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	corpus := &sliceIterator{cts: make(map[string][]byte)}
+	for i := 0; i < 32; i++ {
+		id := fmt.Sprintf("item-%02d", i)
+		c, err := Encrypt(rand.Reader, &oldKey.PublicKey, []byte(id), nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if i%2 == 0 {
+			c, _ = EncodeEnvelope(&oldKey.PublicKey, c)
+		}
+		corpus.ids = append(corpus.ids, id)
+		corpus.cts[id] = c
+	}
+
+	sink := &mapSink{cts: make(map[string][]byte), failOn: "item-20"}
+	r := &ReEncryptor{
+		Keys:        []KeyProvider{newKey, oldKey},
+		Recipient:   &newKey.PublicKey,
+		Parallelism: 4,
+	}
+	progress, err := r.Run(context.Background(), corpus, sink)
+	if err == nil || progress.Checkpoint >= "item-20" {
+		fmt.Println("ecies: expected the run to stop before item-20", progress.Checkpoint, err)
+		t.FailNow()
+	}
+
+	corpus.pos = 0
+	sink.failOn = ""
+	r.Resume = progress.Checkpoint
+	resumed, err := r.Run(context.Background(), corpus, sink)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if resumed.Checkpoint != "item-31" || resumed.Skipped+resumed.Done != 32 {
+		fmt.Println("ecies: unexpected progress after resuming", resumed)
+		t.FailNow()
+	}
+
+	for i, id := range corpus.ids {
+		c := sink.cts[id]
+		if isEnvelope(c) != (i%2 == 0) {
+			fmt.Println("ecies: re-encryption changed the format of", id)
+			t.FailNow()
+		}
+		m, err := Decrypt(newKey, c, nil, nil)
+		if err != nil || !bytes.Equal(m, []byte(id)) {
+			fmt.Println("ecies: failed to decrypt re-encrypted", id, err)
+			t.FailNow()
+		}
+	}
+
+	corpus.pos = 0
+	r.Resume = "item-31"
+	if resumed, err = r.Run(context.Background(), corpus, sink); err != nil || resumed.Checkpoint != "item-31" || resumed.Done != 0 {
+		fmt.Println("ecies: lost the checkpoint of a finished run", resumed, err)
+		t.FailNow()
+	}
+
+	corpus.pos = 0
+	r.Resume = "missing"
+	if _, err = r.Run(context.Background(), corpus, sink); err != ErrCheckpointNotFound {
+		fmt.Println("ecies: resumed from a missing checkpoint", err)
+		t.FailNow()
+	}
+}
+
+// Ensure a corpus of single and multi-recipient ciphertexts is re-encrypted
+// to several recipients.
+func TestReEncryptorRecipients(t *testing.T) {
+	var keys []*PrivateKey
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		keys = append(keys, prv)
+	}
+	old := keys[0]
+
+	corpus := &sliceIterator{cts: make(map[string][]byte)}
+	for i := 0; i < 8; i++ {
+		id := fmt.Sprintf("item-%02d", i)
+		c, err := Encrypt(rand.Reader, &old.PublicKey, []byte(id), nil, nil)
+		if i%2 == 0 {
+			c, err = EncryptToMany(rand.Reader, []*PublicKey{&keys[1].PublicKey, &old.PublicKey}, []byte(id), nil, nil)
+		}
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		corpus.ids = append(corpus.ids, id)
+		corpus.cts[id] = c
+	}
+
+	sink := &mapSink{cts: make(map[string][]byte)}
+	r := &ReEncryptor{
+		Keys:       []KeyProvider{old},
+		Recipients: []*PublicKey{&keys[1].PublicKey, &keys[2].PublicKey},
+	}
+	if progress, err := r.Run(context.Background(), corpus, sink); err != nil || progress.Done != 8 {
+		fmt.Println("ecies: corpus not re-encrypted", progress, err)
+		t.FailNow()
+	}
+	for _, id := range corpus.ids {
+		for _, prv := range keys[1:] {
+			if m, err := DecryptFromMany(prv, sink.cts[id], nil, nil); err != nil || string(m) != id {
+				fmt.Println("ecies: failed to decrypt re-encrypted", id, err)
+				t.FailNow()
+			}
+		}
+	}
+
+	r.Recipient = &keys[1].PublicKey
+	if _, err := r.Run(context.Background(), corpus, sink); err != ErrInvalidParams {
+		fmt.Println("ecies: accepted both a recipient and recipients", err)
+		t.FailNow()
+	}
+}
+
+// Ensure ciphertexts are re-encrypted to the new key, in their format, and
+// that a batch reports the ciphertext which fails.
+func TestReEncrypt(t *testing.T) {
+	old, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rotated, err := GenerateKey(rand.Reader, elliptic.P384(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, rotation.")
+	raw, err := Encrypt(rand.Reader, &old.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	env, err := EncodeEnvelope(&old.PublicKey, raw)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	cs, err := ReEncryptBatch(rand.Reader, old, &rotated.PublicKey, [][]byte{raw, env}, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if isEnvelope(cs[0]) || !isEnvelope(cs[1]) {
+		fmt.Println("ecies: re-encryption changed the ciphertext format")
+		t.FailNow()
+	}
+	for _, c := range cs {
+		if pt, err := Decrypt(rotated, c, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: re-encrypted ciphertext not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	tampered := append([]byte{}, raw...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err = ReEncryptBatch(rand.Reader, old, &rotated.PublicKey, [][]byte{raw, tampered}, nil, nil); !errors.Is(err, ErrInvalidMessage) ||
+		err.Error() != "ecies: re-encrypting ciphertext 1: "+ErrInvalidMessage.Error() {
+		fmt.Println("ecies: unexpected batch error", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,85 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Registration of curves beyond the built-in ones, so that applications can
+// use their own curves with GenerateKey, Encrypt and the ASN.1 formats
+// without patching the package.
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"sync"
+)
+
+var ErrCurveRegistered = newError(KindParams, "ecies: curve or OID already registered")
+
+// registerMu makes the checks and the update of RegisterCurve atomic.
+var registerMu sync.Mutex
+
+// registeredCurves holds the curves added by RegisterCurve with an OID.
+var registeredCurves []registeredCurve
+
+type registeredCurve struct {
+	curve elliptic.Curve
+	oid   secgNamedCurve
+}
+
+// RegisterCurve makes curve known to the package: oid identifies it in the
+// DER and PEM formats, and params are its default parameters, as returned by
+// ParamsFromCurve. Either may be nil, in which case the curve can't be used
+// in those formats, or GenerateKey and Encrypt must be given parameters.
+// Neither the curve nor the OID may already be known. Curves are registered
+// once, usually from an init function: they can't be unregistered.
+//
+// The curve must implement elliptic.Curve in full, with a prime order group
+// or a cofactor which ValidatePublicKey can clear with the order N. Its
+// public keys are validated with a scalar multiplication by N.
+func RegisterCurve(curve elliptic.Curve, oid asn1.ObjectIdentifier, params *ECIESParams) error {
+	if curve == nil || curve.Params() == nil {
+		return ErrInvalidCurve
+	}
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if _, ok := oidFromNamedCurve(curve); ok || ParamsFromCurve(curve) != nil {
+		return ErrCurveRegistered
+	}
+	if oid != nil && namedCurveFromOID(secgNamedCurve(oid)) != nil {
+		return ErrCurveRegistered
+	}
+
+	curvesMu.Lock()
+	defer curvesMu.Unlock()
+	if oid != nil {
+		registeredCurves = append(registeredCurves, registeredCurve{curve, append(secgNamedCurve(nil), oid...)})
+	}
+	if params != nil {
+		paramsFromCurve[curve] = params
+	}
+	return nil
+}
+
+// registeredCurveFromOID returns the registered curve with the OID, if any.
+func registeredCurveFromOID(oid secgNamedCurve) elliptic.Curve {
+	curvesMu.RLock()
+	defer curvesMu.RUnlock()
+	for _, r := range registeredCurves {
+		if r.oid.Equal(oid) {
+			return r.curve
+		}
+	}
+	return nil
+}
+
+// registeredOID returns the OID of the registered curve, if any.
+func registeredOID(curve elliptic.Curve) (secgNamedCurve, bool) {
+	curvesMu.RLock()
+	defer curvesMu.RUnlock()
+	for _, r := range registeredCurves {
+		if r.curve == curve {
+			return r.oid, true
+		}
+	}
+	return nil, false
+}
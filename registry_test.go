@@ -0,0 +1,97 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// registerTestCurve registers a copy of the P-256 domain parameters as a
+// custom curve, for the duration of the test.
+func registerTestCurve(t *testing.T, oid asn1.ObjectIdentifier) elliptic.Curve {
+	p := *elliptic.P256().Params()
+	p.Name = "P-256-copy"
+	curve := &p
+	if err := RegisterCurve(curve, oid, ECIES_AES128_SHA256); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		curvesMu.Lock()
+		defer curvesMu.Unlock()
+		delete(paramsFromCurve, curve)
+		registeredCurves = slices.DeleteFunc(registeredCurves, func(r registeredCurve) bool {
+			return r.curve == curve
+		})
+	})
+	return curve
+}
+
+// Ensure that registered curves are used for keys, encryption and the ASN.1
+// formats, and that known curves and OIDs can't be registered again.
+func TestRegisterCurve(t *testing.T) {
+	oid := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	curve := registerTestCurve(t, oid)
+
+	prv, err := GenerateKey(rand.Reader, curve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if prv.Params != ECIES_AES128_SHA256 {
+		fmt.Println("ecies: registered parameters not used")
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pt, err := prv.Decrypt(rand.Reader, ct, nil, nil)
+	if err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: decryption on a registered curve failed", err)
+		t.FailNow()
+	}
+
+	der, err := MarshalPrivate(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv2, err := UnmarshalPrivate(der)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if prv2.Curve != curve || !prv2.Equal(prv) {
+		fmt.Println("ecies: registered curve not decoded")
+		t.FailNow()
+	}
+
+	for _, tc := range []struct {
+		curve elliptic.Curve
+		oid   asn1.ObjectIdentifier
+	}{
+		{elliptic.P256(), nil},
+		{curve, nil},
+		{X25519(), nil},
+		{&elliptic.CurveParams{Name: "other"}, oid},
+		{&elliptic.CurveParams{Name: "other"}, asn1.ObjectIdentifier(secgNamedCurveP384)},
+	} {
+		if err := RegisterCurve(tc.curve, tc.oid, nil); !errors.Is(err, ErrCurveRegistered) {
+			fmt.Println("ecies: registered a known curve or OID:", err)
+			t.FailNow()
+		}
+	}
+	if err := RegisterCurve(nil, oid, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: registered a nil curve:", err)
+		t.FailNow()
+	}
+}
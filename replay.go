@@ -0,0 +1,130 @@
+package ecies
+
+// Timestamp binding and replay detection, e.g. for commands sent to devices.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStale         = newError(KindValidity, "ecies: message timestamp out of range")
+	ErrMissingStamp  = newError(KindEncoding, "ecies: message timestamp missing")
+	ErrStampTooLarge = newError(KindParams, "ecies: message ID too long")
+)
+
+// maxMessageIDSize is the largest message ID, as its length is a single byte.
+const maxMessageIDSize = 255
+
+// ReplayCache remembers the messages already decrypted. Implementations can
+// be shared between processes, e.g. backed by a database.
+type ReplayCache interface {
+	// CheckAndStore records key until expiry, telling whether it was already
+	// recorded. It must be atomic.
+	CheckAndStore(key []byte, expiry time.Time) (seen bool, err error)
+}
+
+// A stamp is prepended to the ciphertext: the timestamp in Unix milliseconds
+// as a 64-bit big-endian integer, and the length-prefixed message ID. It is
+// authenticated as the head of the MAC shared information.
+type messageStamp struct {
+	header    []byte
+	timestamp time.Time
+	id        []byte
+}
+
+func newStamp(timestamp time.Time, id []byte) (*messageStamp, error) {
+	if len(id) > maxMessageIDSize {
+		return nil, ErrStampTooLarge
+	}
+	header := make([]byte, 9, 9+len(id))
+	binary.BigEndian.PutUint64(header, unixMillis(timestamp))
+	header[8] = byte(len(id))
+	header = append(header, id...)
+	return &messageStamp{header: header, timestamp: timestamp, id: id}, nil
+}
+
+// parseStamp splits the stamp off c.
+func parseStamp(c []byte) (*messageStamp, []byte, error) {
+	if len(c) < 9 || len(c) < 9+int(c[8]) {
+		return nil, nil, ErrMissingStamp
+	}
+	n := 9 + int(c[8])
+	return &messageStamp{
+		header:    c[:n],
+		timestamp: fromUnixMillis(binary.BigEndian.Uint64(c)),
+		id:        c[9:n],
+	}, c[n:], nil
+}
+
+// bindHeader prepends a ciphertext header to the MAC shared information.
+func bindHeader(header, s2 []byte) []byte {
+	out := make([]byte, 0, len(header)+len(s2))
+	out = append(out, header...)
+	return append(out, s2...)
+}
+
+// check verifies the age of an authenticated stamp, and records the message
+// in the replay cache. The message ID, or else the tag, identifies it.
+func (s *messageStamp) check(opts *DecryptOptions, tag []byte) error {
+	now := opts.now()
+	if opts.MaxAge > 0 && (now.Sub(s.timestamp) > opts.MaxAge || s.timestamp.Sub(now) > opts.MaxAge) {
+		return ErrStale
+	}
+	if opts.ReplayCache == nil {
+		return nil
+	}
+	key := tag
+	if len(s.id) > 0 {
+		h := sha256.Sum256(s.id)
+		key = h[:]
+	}
+	expiry := s.timestamp.Add(opts.MaxAge)
+	if opts.MaxAge <= 0 {
+		expiry = time.Time{}
+	}
+	seen, err := opts.ReplayCache.CheckAndStore(key, expiry)
+	if err != nil {
+		return err
+	}
+	if seen {
+		return ErrReplay
+	}
+	return nil
+}
+
+// MemoryReplayCache is an in-process ReplayCache. Expired entries are purged
+// from time to time as new ones are stored.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryReplayCache returns an empty cache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{entries: make(map[string]time.Time)}
+}
+
+// CheckAndStore implements the ReplayCache interface. A zero expiry keeps the
+// entry forever.
+func (c *MemoryReplayCache) CheckAndStore(key []byte, expiry time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastPurge) > time.Second {
+		for k, exp := range c.entries {
+			if !exp.IsZero() && now.After(exp) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPurge = now
+	}
+	if _, ok := c.entries[string(key)]; ok {
+		return true, nil
+	}
+	c.entries[string(key)] = expiry
+	return false, nil
+}
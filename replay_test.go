@@ -0,0 +1,86 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Ensure timestamped messages decrypt once, and only while fresh.
+func TestTimestampReplay(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("reboot")
+	seal := func(ts time.Time, id []byte) []byte {
+		c, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, []byte("cmd"),
+			&EncryptOptions{Timestamp: ts, MessageID: id})
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		return c
+	}
+
+	cache := NewMemoryReplayCache()
+	opts := &DecryptOptions{Timestamped: true, MaxAge: time.Minute, ReplayCache: cache}
+	for _, id := range [][]byte{nil, []byte("cmd-1")} {
+		c := seal(time.Now(), id)
+		pt, err := DecryptWithOptions(prv, c, nil, []byte("cmd"), opts)
+		if err != nil || string(pt) != string(message) {
+			fmt.Println("ecies: timestamped message not decrypted", err)
+			t.FailNow()
+		}
+		if _, err = DecryptWithOptions(prv, c, nil, []byte("cmd"), opts); err != ErrReplay {
+			fmt.Println("ecies: replay not detected", err)
+			t.FailNow()
+		}
+	}
+
+	// The same message ID is a replay even in a new ciphertext.
+	if _, err = DecryptWithOptions(prv, seal(time.Now(), []byte("cmd-1")), nil, []byte("cmd"), opts); err != ErrReplay {
+		fmt.Println("ecies: replayed message ID not detected", err)
+		t.FailNow()
+	}
+
+	old := seal(time.Now().Add(-time.Hour), nil)
+	if _, err = DecryptWithOptions(prv, old, nil, []byte("cmd"), opts); err != ErrStale {
+		fmt.Println("ecies: stale message accepted", err)
+		t.FailNow()
+	}
+	if _, err = DecryptWithOptions(prv, old, nil, []byte("cmd"), &DecryptOptions{Timestamped: true}); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	// Moving the timestamp breaks the tag.
+	c := seal(time.Now(), nil)
+	c[7] ^= 1
+	if _, err = DecryptWithOptions(prv, c, nil, []byte("cmd"), opts); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered timestamp accepted", err)
+		t.FailNow()
+	}
+
+	plain, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, []byte("cmd"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = DecryptWithOptions(prv, plain, nil, []byte("cmd"), opts); err == nil {
+		fmt.Println("ecies: message without timestamp accepted")
+		t.FailNow()
+	}
+	if _, err = DecryptWithOptions(prv, plain[:5], nil, nil, opts); err != ErrMissingStamp {
+		fmt.Println("ecies: expected missing timestamp", err)
+		t.FailNow()
+	}
+
+	if _, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil,
+		&EncryptOptions{Timestamp: time.Now(), MessageID: make([]byte, 256)}); err != ErrStampTooLarge {
+		fmt.Println("ecies: oversized message ID accepted", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,9 @@
+package ecies
+
+import "context"
+
+// RecipientResolver looks up the public keys to encrypt to for a recipient
+// identifier, such as a DID or a key directory entry.
+type RecipientResolver interface {
+	ResolveRecipient(ctx context.Context, recipient string) ([]*PublicKey, error)
+}
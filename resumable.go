@@ -0,0 +1,162 @@
+package ecies
+
+// Checkpoints of streams, so that the encryption of a large message can be
+// resumed after an interruption, e.g. of an upload, from its last sealed
+// chunk rather than from the start.
+//
+// A checkpoint is encoded as a version byte, a flags byte (1 if the stream
+// is indexed), the 64-bit big-endian chunk counter, message offset and
+// ciphertext offset, then the stream header, key and SHA-256 state of the
+// index, each prefixed by its 16-bit length.
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+var (
+	ErrStreamNotResumable = newError(KindState, "ecies: stream not resumable")
+	ErrInvalidCheckpoint  = newError(KindEncoding, "ecies: invalid stream checkpoint")
+)
+
+const checkpointVersion = 1
+
+// StreamCheckpoint is the state of a stream after its last sealed chunk. It
+// holds the key of the stream, which decrypts it: it must be kept as secret
+// as the message, and wiped once the stream is complete.
+type StreamCheckpoint struct {
+	counter uint64
+	offset  int64
+	written int64
+	header  []byte
+	secret  []byte
+	digest  []byte // the state of the index hash, if indexed
+}
+
+// Offset returns the size of the message sealed up to the checkpoint. The
+// message must be written to the resumed writer from that offset, exactly
+// as it was before: chunks sealed again with other content would reuse
+// their nonces.
+func (cp *StreamCheckpoint) Offset() int64 {
+	return cp.offset
+}
+
+// CiphertextOffset returns the size of the ciphertext written up to the
+// checkpoint, at which its output must be truncated before resuming.
+func (cp *StreamCheckpoint) CiphertextOffset() int64 {
+	return cp.written
+}
+
+// Wipe zeroes the stream key held by the checkpoint.
+func (cp *StreamCheckpoint) Wipe() {
+	wipe(cp.secret)
+}
+
+// MarshalBinary encodes the checkpoint, for instance to save it along with
+// the partial output.
+func (cp *StreamCheckpoint) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if cp.digest != nil {
+		flags = 1
+	}
+	out := []byte{checkpointVersion, flags}
+	out = binary.BigEndian.AppendUint64(out, cp.counter)
+	out = binary.BigEndian.AppendUint64(out, uint64(cp.offset))
+	out = binary.BigEndian.AppendUint64(out, uint64(cp.written))
+	out = appendField(out, cp.header)
+	out = appendField(out, cp.secret)
+	return appendField(out, cp.digest), nil
+}
+
+// UnmarshalBinary decodes a checkpoint encoded by MarshalBinary.
+func (cp *StreamCheckpoint) UnmarshalBinary(data []byte) error {
+	if len(data) < 26 || data[0] != checkpointVersion || data[1] > 1 {
+		return ErrInvalidCheckpoint
+	}
+	var c StreamCheckpoint
+	c.counter = binary.BigEndian.Uint64(data[2:])
+	c.offset = int64(binary.BigEndian.Uint64(data[10:]))
+	c.written = int64(binary.BigEndian.Uint64(data[18:]))
+	rest := data[26:]
+	var digest []byte
+	var ok1, ok2, ok3 bool
+	c.header, rest, ok1 = readField(rest)
+	c.secret, rest, ok2 = readField(rest)
+	digest, rest, ok3 = readField(rest)
+	if !ok1 || !ok2 || !ok3 || len(rest) != 0 || len(c.header) == 0 || len(c.secret) == 0 ||
+		c.counter > 1<<32 || c.offset != int64(c.counter)*streamChunkSize || (data[1] == 1) != (len(digest) != 0) {
+		return ErrInvalidCheckpoint
+	}
+	c.header = append([]byte{}, c.header...)
+	c.secret = append([]byte{}, c.secret...)
+	if data[1] == 1 {
+		c.digest = append([]byte{}, digest...)
+	}
+	*cp = c
+	return nil
+}
+
+// Checkpoint returns the state of the stream after its last sealed chunk,
+// from which ResumeEncryptingWriter carries on. The writer must have been
+// created with StreamOptions.Resumable, and not closed yet. The message
+// written since the last sealed chunk isn't covered by the checkpoint.
+func (s *EncryptingWriter) Checkpoint() (*StreamCheckpoint, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.secret == nil {
+		return nil, ErrStreamNotResumable
+	}
+	cp := &StreamCheckpoint{
+		counter: s.counter,
+		offset:  int64(s.counter) * streamChunkSize,
+		written: int64(len(s.header)) + int64(s.counter)*int64(streamChunkSize+s.aead.Overhead()),
+		header:  append([]byte{}, s.header...),
+		secret:  append([]byte{}, s.secret...),
+	}
+	if s.digest != nil {
+		state, err := s.digest.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		cp.digest = state
+	}
+	return cp, nil
+}
+
+// ResumeEncryptingWriter returns a writer carrying on with the stream of the
+// checkpoint for pub, writing the ciphertext to w from the offset returned
+// by CiphertextOffset. The writer is resumable as well.
+func ResumeEncryptingWriter(pub *PublicKey, cp *StreamCheckpoint, w io.Writer) (*EncryptingWriter, error) {
+	if cp == nil || len(cp.secret) == 0 {
+		return nil, ErrInvalidCheckpoint
+	}
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, err
+	}
+	s, err := continueStream(params, cp.secret, cp.header, w, cp.counter)
+	if err != nil {
+		return nil, err
+	}
+	if cp.written != int64(len(cp.header))+int64(cp.counter)*int64(streamChunkSize+s.aead.Overhead()) {
+		return nil, ErrInvalidCheckpoint
+	}
+	if cp.digest != nil {
+		var digest hash.Hash = sha256.New()
+		if err = digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(cp.digest); err != nil {
+			return nil, ErrInvalidCheckpoint
+		}
+		s.digest, s.size = digest, uint64(cp.offset)
+	}
+	s.header, s.secret = cp.header, append([]byte{}, cp.secret...)
+	return s, nil
+}
@@ -0,0 +1,140 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// Ensure an interrupted stream resumes from its checkpoint into a complete
+// stream, whose index covers the whole message.
+func TestResumeEncryptingWriter(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := make([]byte, 3*streamChunkSize+500)
+	rand.Read(message)
+
+	var out bytes.Buffer
+	w, err := NewEncryptingWriterWithOptions(&prv.PublicKey, &out, &StreamOptions{Index: true, Resumable: true})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = w.Write(message[:2*streamChunkSize+100]); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	cp, err := w.Checkpoint()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if cp.Offset() != 2*streamChunkSize || cp.CiphertextOffset() != int64(out.Len()) {
+		fmt.Println("ecies: unexpected checkpoint offsets", cp.Offset(), cp.CiphertextOffset(), out.Len())
+		t.FailNow()
+	}
+	saved, err := cp.MarshalBinary()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	// The writer is lost after sealing another chunk.
+	w.Write(message[2*streamChunkSize+100 : 3*streamChunkSize+200])
+
+	restored := new(StreamCheckpoint)
+	if err = restored.UnmarshalBinary(saved); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	out.Truncate(int(restored.CiphertextOffset()))
+	w, err = ResumeEncryptingWriter(&prv.PublicKey, restored, &out)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	restored.Wipe()
+	if _, err = w.Write(message[restored.Offset():]); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = w.Close(); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	r, err := NewDecryptingReader(prv, bytes.NewReader(out.Bytes()))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := io.ReadAll(r); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: resumed stream not decrypted", err)
+		t.FailNow()
+	}
+	d, err := NewSeekableDecrypter(prv, bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if sum := sha256.Sum256(message); !bytes.Equal(d.Digest(), sum[:]) {
+		fmt.Println("ecies: resumed stream index doesn't match the message")
+		t.FailNow()
+	}
+	if _, err = w.Checkpoint(); err != ErrStreamClosed {
+		fmt.Println("ecies: checkpoint of a closed stream", err)
+		t.FailNow()
+	}
+}
+
+// Ensure checkpoints are only taken of resumable streams, and that altered
+// checkpoints are rejected.
+func TestStreamCheckpointErrors(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	w, err := NewEncryptingWriterWithOptions(&prv.PublicKey, io.Discard, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = w.Checkpoint(); err != ErrStreamNotResumable {
+		fmt.Println("ecies: checkpoint of a stream which isn't resumable", err)
+		t.FailNow()
+	}
+
+	w, err = NewEncryptingWriterWithOptions(&prv.PublicKey, io.Discard, &StreamOptions{Resumable: true})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	cp, err := w.Checkpoint()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	saved, _ := cp.MarshalBinary()
+	for i, altered := range [][]byte{
+		saved[:len(saved)-1],
+		append(append([]byte{}, saved...), 0),
+		append([]byte{2}, saved[1:]...),
+		append([]byte{checkpointVersion, 1}, saved[2:]...),
+	} {
+		if err = new(StreamCheckpoint).UnmarshalBinary(altered); err != ErrInvalidCheckpoint {
+			fmt.Println("ecies: altered checkpoint accepted", i, err)
+			t.FailNow()
+		}
+	}
+	cp.written++
+	if _, err = ResumeEncryptingWriter(&prv.PublicKey, cp, io.Discard); err != ErrInvalidCheckpoint {
+		fmt.Println("ecies: inconsistent checkpoint accepted", err)
+		t.FailNow()
+	}
+}
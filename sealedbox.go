@@ -0,0 +1,98 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+// The sealed boxes of NaCl and libsodium (crypto_box_seal), anonymous
+// messages to an X25519 key:
+//
+//	nonce = BLAKE2b-192(R || Q)
+//	k = HSalsa20(X25519(r, Q), 0)
+//	ciphertext = R || XSalsa20-Poly1305(k, nonce, m)
+//
+// where R and Q are the raw ephemeral and recipient public keys.
+
+import (
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// sealedBoxOverhead is the size of the ephemeral key and of the Poly1305 tag.
+const sealedBoxOverhead = x25519KeySize + secretbox.Overhead
+
+func enforceSealedBox(pub *PublicKey) error {
+	if pub.Curve != X25519() {
+		return ErrInvalidCurve
+	}
+	return enforcePolicy(nil, SuiteSealedBox, pub.Curve, blake2b.Size256, 32)
+}
+
+// sealedBoxKeys returns the nonce and the key of the box from the raw
+// ephemeral and recipient public keys and their shared secret.
+func sealedBoxKeys(eph, pub, shared []byte) (nonce [24]byte, key [32]byte, err error) {
+	h, err := blake2b.New(24, nil)
+	if err != nil {
+		return
+	}
+	h.Write(eph)
+	h.Write(pub)
+	copy(nonce[:], h.Sum(nil))
+
+	var in [16]byte
+	var k [32]byte
+	copy(k[:], shared)
+	salsa.HSalsa20(&key, &in, &k, &salsa.Sigma)
+	return
+}
+
+// SealAnonymous encrypts a message to an X25519 public key as libsodium's
+// crypto_box_seal does. The recipient can open it, but can't tell who sealed
+// it.
+func SealAnonymous(rand io.Reader, pub *PublicKey, m []byte) ([]byte, error) {
+	if err := enforceSealedBox(pub); err != nil {
+		return nil, err
+	}
+	shared, eph, err := encapsulate(rand, pub, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	nonce, key, err := sealedBoxKeys(eph, CompressPublicKey(pub), shared)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(eph), len(eph)+len(m)+secretbox.Overhead)
+	copy(out, eph)
+	return secretbox.Seal(out, m, &nonce, &key), nil
+}
+
+// OpenAnonymous decrypts a sealed box produced by SealAnonymous or libsodium's
+// crypto_box_seal.
+func OpenAnonymous(prv KeyProvider, c []byte) ([]byte, error) {
+	pub := prv.Public()
+	if err := enforceSealedBox(pub); err != nil {
+		return nil, err
+	}
+	if len(c) < sealedBoxOverhead {
+		return nil, ErrInvalidMessage
+	}
+	R, err := parseEncapsulation(pub, c[:x25519KeySize], AllowAllPoints)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	nonce, key, err := sealedBoxKeys(c[:x25519KeySize], CompressPublicKey(pub), shared)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := secretbox.Open(nil, c[x25519KeySize:], &nonce, &key)
+	if !ok {
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
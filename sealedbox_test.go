@@ -0,0 +1,70 @@
+//go:build !ecies_nolegacy
+// +build !ecies_nolegacy
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Ensure that sealed boxes interoperate with the crypto_box_seal
+// implementation of golang.org/x/crypto, in both directions.
+func TestSealedBox(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv, err := NewPrivateKey(X25519(), priv[:])
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Equal(CompressPublicKey(&prv.PublicKey), pub[:]) {
+		fmt.Println("ecies: X25519 public keys don't match")
+		t.FailNow()
+	}
+
+	for _, message := range [][]byte{nil, []byte("Hello, libsodium."), make([]byte, 100)} {
+		sealed, err := box.SealAnonymous(nil, message, pub, rand.Reader)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := OpenAnonymous(prv, sealed); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: sealed box not opened", err)
+			t.FailNow()
+		}
+
+		ct, err := SealAnonymous(rand.Reader, &prv.PublicKey, message)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, ok := box.OpenAnonymous(nil, ct, pub, priv); !ok || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: sealed box not opened by x/crypto")
+			t.FailNow()
+		}
+		ct[len(ct)-1] ^= 1
+		if _, err = OpenAnonymous(prv, ct); err != ErrInvalidMessage {
+			fmt.Println("ecies: opened a tampered sealed box")
+			t.FailNow()
+		}
+	}
+
+	p256, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = SealAnonymous(rand.Reader, &p256.PublicKey, []byte("message")); err != ErrInvalidCurve {
+		fmt.Println("ecies: sealed a box to a P-256 key")
+		t.FailNow()
+	}
+}
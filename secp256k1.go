@@ -0,0 +1,270 @@
+package ecies
+
+// The secp256k1 curve (SEC 2 section 2.4.1), which isn't provided by the Go
+// standard library. Its arithmetic is implemented in Jacobian coordinates
+// over math/big, and is not constant-time.
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"sync"
+)
+
+type secp256k1Curve struct {
+	params *elliptic.CurveParams
+}
+
+var (
+	secp256k1Once sync.Once
+	secp256k1     *secp256k1Curve
+)
+
+func initSecp256k1() {
+	params := &elliptic.CurveParams{Name: "secp256k1", BitSize: 256}
+	params.P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	params.N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	params.B = big.NewInt(7)
+	params.Gx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	params.Gy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+	secp256k1 = &secp256k1Curve{params}
+}
+
+// Secp256k1 returns a Curve which implements secp256k1, y² = x³ + 7.
+//
+// Unlike the NIST curves of crypto/elliptic, its operations are not
+// constant-time: it is meant for interoperability with the secp256k1
+// keys of blockchain ecosystems.
+func Secp256k1() elliptic.Curve {
+	secp256k1Once.Do(initSecp256k1)
+	return secp256k1
+}
+
+// importCurve returns Secp256k1() for curves implementing secp256k1 in
+// other packages, e.g. those of go-ethereum or btcec, recognized by their
+// name or their parameters, so that their keys get the parameters and
+// arithmetic of this package. Other curves are returned as is.
+func importCurve(curve elliptic.Curve) elliptic.Curve {
+	if curve == nil || curve == Secp256k1() {
+		return curve
+	}
+	p, s := curve.Params(), Secp256k1().Params()
+	if p == nil {
+		return curve
+	}
+	if p.Name == s.Name {
+		return Secp256k1()
+	}
+	for _, v := range [][2]*big.Int{{p.P, s.P}, {p.N, s.N}, {p.B, s.B}, {p.Gx, s.Gx}, {p.Gy, s.Gy}} {
+		if v[0] == nil || v[0].Cmp(v[1]) != 0 {
+			return curve
+		}
+	}
+	return Secp256k1()
+}
+
+func (curve *secp256k1Curve) Params() *elliptic.CurveParams {
+	return curve.params
+}
+
+func (curve *secp256k1Curve) IsOnCurve(x, y *big.Int) bool {
+	p := curve.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	return y2.Cmp(curve.polynomial(x)) == 0
+}
+
+// polynomial returns x³ + 7 modulo P.
+func (curve *secp256k1Curve) polynomial(x *big.Int) *big.Int {
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x)
+	x3.Add(x3, curve.params.B)
+	return x3.Mod(x3, curve.params.P)
+}
+
+// jacobianPoint is (X/Z², Y/Z³), with Z = 0 for the point at infinity.
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+func (curve *secp256k1Curve) toJacobian(x, y *big.Int) *jacobianPoint {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return &jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	return &jacobianPoint{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+func (curve *secp256k1Curve) toAffine(pt *jacobianPoint) (x, y *big.Int) {
+	if pt.z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	p := curve.params.P
+	zInv := new(big.Int).ModInverse(pt.z, p)
+	zInv2 := new(big.Int).Mul(zInv, zInv)
+	x = new(big.Int).Mul(pt.x, zInv2)
+	x.Mod(x, p)
+	zInv2.Mul(zInv2, zInv)
+	y = new(big.Int).Mul(pt.y, zInv2)
+	y.Mod(y, p)
+	return
+}
+
+// double uses the "dbl-2009-l" formulas for a = 0.
+func (curve *secp256k1Curve) double(pt *jacobianPoint) *jacobianPoint {
+	p := curve.params.P
+	if pt.z.Sign() == 0 || pt.y.Sign() == 0 {
+		return &jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	a := new(big.Int).Mul(pt.x, pt.x)
+	a.Mod(a, p)
+	b := new(big.Int).Mul(pt.y, pt.y)
+	b.Mod(b, p)
+	c := new(big.Int).Mul(b, b)
+	c.Mod(c, p)
+	d := new(big.Int).Add(pt.x, b)
+	d.Mul(d, d)
+	d.Sub(d, a)
+	d.Sub(d, c)
+	d.Lsh(d, 1)
+	d.Mod(d, p)
+	e := new(big.Int).Lsh(a, 1)
+	e.Add(e, a)
+	f := new(big.Int).Mul(e, e)
+
+	x3 := new(big.Int).Sub(f, new(big.Int).Lsh(d, 1))
+	x3.Mod(x3, p)
+	y3 := new(big.Int).Sub(d, x3)
+	y3.Mul(y3, e)
+	y3.Sub(y3, new(big.Int).Lsh(c, 3))
+	y3.Mod(y3, p)
+	z3 := new(big.Int).Mul(pt.y, pt.z)
+	z3.Lsh(z3, 1)
+	z3.Mod(z3, p)
+	return &jacobianPoint{x3, y3, z3}
+}
+
+// add uses the "add-2007-bl" formulas.
+func (curve *secp256k1Curve) add(p1, p2 *jacobianPoint) *jacobianPoint {
+	p := curve.params.P
+	if p1.z.Sign() == 0 {
+		return p2
+	} else if p2.z.Sign() == 0 {
+		return p1
+	}
+	z1z1 := new(big.Int).Mul(p1.z, p1.z)
+	z1z1.Mod(z1z1, p)
+	z2z2 := new(big.Int).Mul(p2.z, p2.z)
+	z2z2.Mod(z2z2, p)
+	u1 := new(big.Int).Mul(p1.x, z2z2)
+	u1.Mod(u1, p)
+	u2 := new(big.Int).Mul(p2.x, z1z1)
+	u2.Mod(u2, p)
+	s1 := new(big.Int).Mul(p1.y, p2.z)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, p)
+	s2 := new(big.Int).Mul(p2.y, p1.z)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, p)
+
+	h := new(big.Int).Sub(u2, u1)
+	h.Mod(h, p)
+	r := new(big.Int).Sub(s2, s1)
+	r.Mod(r, p)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return curve.double(p1)
+		}
+		return &jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	r.Lsh(r, 1)
+	i := new(big.Int).Lsh(h, 1)
+	i.Mul(i, i)
+	i.Mod(i, p)
+	j := new(big.Int).Mul(h, i)
+	j.Mod(j, p)
+	v := new(big.Int).Mul(u1, i)
+	v.Mod(v, p)
+
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, j)
+	x3.Sub(x3, new(big.Int).Lsh(v, 1))
+	x3.Mod(x3, p)
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	s1.Mul(s1, j)
+	s1.Lsh(s1, 1)
+	y3.Sub(y3, s1)
+	y3.Mod(y3, p)
+	z3 := new(big.Int).Add(p1.z, p2.z)
+	z3.Mul(z3, z3)
+	z3.Sub(z3, z1z1)
+	z3.Sub(z3, z2z2)
+	z3.Mul(z3, h)
+	z3.Mod(z3, p)
+	return &jacobianPoint{x3, y3, z3}
+}
+
+func (curve *secp256k1Curve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	return curve.toAffine(curve.add(curve.toJacobian(x1, y1), curve.toJacobian(x2, y2)))
+}
+
+func (curve *secp256k1Curve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	return curve.toAffine(curve.double(curve.toJacobian(x1, y1)))
+}
+
+func (curve *secp256k1Curve) ScalarMult(bx, by *big.Int, k []byte) (x, y *big.Int) {
+	base := curve.toJacobian(bx, by)
+	acc := &jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			acc = curve.double(acc)
+			if (b>>uint(bit))&1 == 1 {
+				acc = curve.add(acc, base)
+			}
+		}
+	}
+	return curve.toAffine(acc)
+}
+
+func (curve *secp256k1Curve) ScalarBaseMult(k []byte) (x, y *big.Int) {
+	return curve.ScalarMult(curve.params.Gx, curve.params.Gy, k)
+}
+
+// Unmarshal decodes an uncompressed point, see elliptic.Unmarshal.
+func (curve *secp256k1Curve) Unmarshal(data []byte) (x, y *big.Int) {
+	if len(data) != 65 || data[0] != pointUncompressed {
+		return nil, nil
+	}
+	x = new(big.Int).SetBytes(data[1:33])
+	y = new(big.Int).SetBytes(data[33:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil
+	}
+	return
+}
+
+// UnmarshalCompressed decodes a compressed point, see elliptic.UnmarshalCompressed,
+// which assumes the y² = x³ - 3x + b equation of the NIST curves.
+func (curve *secp256k1Curve) UnmarshalCompressed(data []byte) (x, y *big.Int) {
+	if len(data) != 33 || (data[0] != pointCompressedEven && data[0] != pointCompressedOdd) {
+		return nil, nil
+	}
+	p := curve.params.P
+	x = new(big.Int).SetBytes(data[1:])
+	if x.Cmp(p) >= 0 {
+		return nil, nil
+	}
+	y = new(big.Int).ModSqrt(curve.polynomial(x), p)
+	if y == nil {
+		return nil, nil
+	}
+	if byte(y.Bit(0)) != data[0]&1 {
+		y.Sub(p, y)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, nil
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package ecies
+
+// Deterministic keys from the stable secrets of devices, such as a fused
+// hardware unique key or a PUF response:
+//
+//	okm = HKDF-SHA-256(seed, salt = "ecies-keygen-v1", info = curve name, L)
+//
+// For X25519 and X448, okm is the 32 or 56-byte private key. For the other
+// curves, L is the size of the curve order plus 8 bytes, and the scalar is
+// d = (okm mod (N-1)) + 1, as in FIPS 186-4 appendix B.4.1.
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var ErrSeedTooShort = newError(KindParams, "ecies: seed too short")
+
+// MinSeedSize is the minimum size of the seeds of GenerateKeyFromSeed.
+const MinSeedSize = 16
+
+var seedSalt = []byte("ecies-keygen-v1")
+
+// GenerateKeyFromSeed deterministically derives an elliptic curve keypair
+// from a secret seed, so that devices with a stable hardware secret can
+// regenerate their key instead of storing it. The same seed and curve always
+// produce the same key. If params is nil, the default parameters for the
+// curve are used.
+//
+// The seed must be at least MinSeedSize bytes of high entropy secret; for
+// passwords, use GenerateKeyFromPassword.
+func GenerateKeyFromSeed(seed []byte, curve elliptic.Curve, params *ECIESParams) (*PrivateKey, error) {
+	if len(seed) < MinSeedSize {
+		return nil, ErrSeedTooShort
+	}
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	c, raw := curve.(rawPointCurve)
+	size := scalarSeedSize(curve)
+	if raw {
+		size = c.pointLen()
+	}
+	okm := make([]byte, size)
+	r := hkdf.New(sha256.New, seed, seedSalt, []byte(curve.Params().Name))
+	if _, err := io.ReadFull(r, okm); err != nil {
+		return nil, err
+	}
+	d := okm
+	if !raw {
+		d = scalarFromSeed(curve, okm).Bytes()
+	}
+	prv, err := NewPrivateKey(curve, d)
+	if err != nil {
+		return nil, err
+	}
+	if params != nil {
+		prv.PublicKey.Params = params
+	}
+	return prv, nil
+}
+
+// scalarSeedSize returns the seed length needed by scalarFromSeed: 64 bits more
+// than the curve order, which makes the modulo bias negligible.
+func scalarSeedSize(curve elliptic.Curve) int {
+	return (curve.Params().N.BitLen()+7)/8 + 8
+}
+
+// scalarFromSeed maps a uniformly random seed to a scalar in [1, N-1], as per
+// FIPS 186-4 appendix B.4.1: d = (seed mod (N-1)) + 1.
+func scalarFromSeed(curve elliptic.Curve, seed []byte) *big.Int {
+	n1 := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(seed)
+	d.Mod(d, n1)
+	return d.Add(d, big.NewInt(1))
+}
@@ -0,0 +1,62 @@
+package ecies
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Ensure that keys derived from a seed are stable, match the documented
+// procedure, and depend on the seed.
+func TestGenerateKeyFromSeed(t *testing.T) {
+	seed := []byte("0123456789abcdef0123456789abcdef")
+	for _, v := range []struct {
+		curve elliptic.Curve
+		d     string
+	}{
+		{elliptic.P256(), "d38268f8d55d1daf10a57666abfeb438e41fc132228f1c34cb161a9d3d5db4d2"},
+		{X25519(), "d95ffa64324e2b41ea7eb7e7adf26e3f1005a139cfa0c9963b689cd66cebd17f"},
+	} {
+		prv, err := GenerateKeyFromSeed(seed, v.curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if hex.EncodeToString(prv.D.FillBytes(make([]byte, 32))) != v.d {
+			fmt.Println("ecies: unexpected key derived from the seed", v.curve.Params().Name)
+			t.FailNow()
+		}
+	}
+
+	for c := range paramsFromCurve {
+		prv1, err := GenerateKeyFromSeed(seed, c, ECIES_AES256_SHA512)
+		if err != nil {
+			fmt.Println(c.Params().Name, err.Error())
+			t.FailNow()
+		}
+		prv2, err := GenerateKeyFromSeed(seed, c, nil)
+		if err != nil {
+			fmt.Println(c.Params().Name, err.Error())
+			t.FailNow()
+		}
+		if !cmpPrivate(prv1, prv2) || prv1.Params != ECIES_AES256_SHA512 {
+			fmt.Println(c.Params().Name, "ecies: seeded keys don't match")
+			t.FailNow()
+		}
+		prv3, err := GenerateKeyFromSeed([]byte("0123456789abcdef0123456789abcdeg"), c, nil)
+		if err != nil {
+			fmt.Println(c.Params().Name, err.Error())
+			t.FailNow()
+		}
+		if cmpPrivate(prv1, prv3) {
+			fmt.Println(c.Params().Name, "ecies: different seeds gave the same key")
+			t.FailNow()
+		}
+	}
+
+	if _, err := GenerateKeyFromSeed(seed[:MinSeedSize-1], DefaultCurve, nil); err != ErrSeedTooShort {
+		fmt.Println("ecies: accepted a short seed")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,236 @@
+package ecies
+
+// Random access to streams written by NewEncryptingWriter: as the chunks have
+// a fixed size, any of them can be located, authenticated and decrypted on
+// its own.
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"hash"
+	"io"
+)
+
+var ErrInvalidOffset = newError(KindParams, "ecies: invalid stream offset")
+
+// SeekableDecrypter decrypts a stream written by NewEncryptingWriter with
+// random access, e.g. to serve byte ranges of an encrypted object. Only the
+// chunks covering the requested range are read and authenticated.
+//
+// ReadAt may be called concurrently, unlike Read and Seek.
+type SeekableDecrypter struct {
+	r       io.ReaderAt
+	aead    cipher.AEAD
+	prefix  []byte
+	start   int64 // offset of the first chunk
+	chunks  int64
+	size    int64 // size of the message
+	indexed bool
+	digest  []byte // of the message, if indexed
+
+	pos   int64
+	chunk int64 // index of the chunk in buf, or -1
+	buf   []byte
+
+	// The hash of the chunks read in order by Read, checked against the
+	// digest once the last one is read.
+	sum    hash.Hash
+	hashed int64 // number of chunks hashed
+	sumErr error
+}
+
+// NewSeekableDecrypter returns a decrypter of the stream of the given size
+// read from r. Only the stream header and the last chunk are read at this
+// point, so a truncated stream is rejected straight away.
+func NewSeekableDecrypter(prv KeyProvider, r io.ReaderAt, size int64) (*SeekableDecrypter, error) {
+	sr := io.NewSectionReader(r, 0, size)
+	aead, nonce, err := openStream(prv, sr)
+	if err != nil {
+		return nil, err
+	}
+	start, _ := sr.Seek(0, io.SeekCurrent)
+	d := &SeekableDecrypter{
+		r:      r,
+		aead:   aead,
+		prefix: nonce[:len(nonce)-streamSuffixSize],
+		start:  start,
+		chunk:  -1,
+		sum:    sha256.New(),
+	}
+	// Authenticating the last chunk confirms the size of the stream, and
+	// whether it is indexed.
+	body := size - start
+	if err = d.locate(body, streamIndexSize); err == ErrInvalidMessage {
+		err = d.locate(body, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// locate splits body into chunks, the last of which ends with an index of
+// the given size, and authenticates the last one.
+func (d *SeekableDecrypter) locate(body, index int64) (err error) {
+	encChunkSize := int64(streamChunkSize + d.aead.Overhead())
+	chunks := (body - index + encChunkSize - 1) / encChunkSize
+	if chunks <= 0 || body-index-(chunks-1)*encChunkSize < int64(d.aead.Overhead()) {
+		return ErrInvalidMessage
+	}
+	if chunks > 1<<32 {
+		return ErrStreamTooLarge
+	}
+	d.chunks = chunks
+	d.size = body - index - chunks*int64(d.aead.Overhead())
+	d.indexed = index != 0
+	if d.buf, d.digest, err = d.decryptChunk(chunks - 1); err != nil {
+		return err
+	}
+	d.chunk = chunks - 1
+	return nil
+}
+
+// Size returns the size of the decrypted message.
+func (d *SeekableDecrypter) Size() int64 {
+	return d.size
+}
+
+// Chunks returns the number of chunks of the stream.
+func (d *SeekableDecrypter) Chunks() int64 {
+	return d.chunks
+}
+
+// Digest returns the SHA-256 hash of the message recorded in the index of
+// the stream, or nil if it has none. It is authenticated with the last
+// chunk, so that ranges read from the stream can be tied to the message with
+// that hash without decrypting all of it. Read checks it against the message
+// when reading it in order from the start, and returns ErrInvalidMessage on
+// a mismatch.
+func (d *SeekableDecrypter) Digest() []byte {
+	if !d.indexed {
+		return nil
+	}
+	return append([]byte{}, d.digest...)
+}
+
+// DecryptChunk reads, authenticates and decrypts the chunk of index i,
+// which holds the message from offset i * 64 KiB.
+func (d *SeekableDecrypter) DecryptChunk(i int64) ([]byte, error) {
+	m, _, err := d.decryptChunk(i)
+	return m, err
+}
+
+// decryptChunk is DecryptChunk, also returning the digest in the index of
+// the last chunk.
+func (d *SeekableDecrypter) decryptChunk(i int64) (m, digest []byte, err error) {
+	if i < 0 || i >= d.chunks {
+		return nil, nil, ErrInvalidOffset
+	}
+	encChunkSize := int64(streamChunkSize + d.aead.Overhead())
+	off := d.start + i*encChunkSize
+	n := encChunkSize
+	last := i == d.chunks-1
+	flag := byte(streamChunk)
+	if last && d.indexed {
+		n = d.size - i*streamChunkSize + streamIndexSize + int64(d.aead.Overhead())
+		flag = streamLastIndexed
+	} else if last {
+		n = d.size - i*streamChunkSize + int64(d.aead.Overhead())
+		flag = streamLast
+	}
+	in := make([]byte, n)
+	if _, err = d.r.ReadAt(in, off); err != nil {
+		if err == io.EOF {
+			err = ErrInvalidMessage
+		}
+		return nil, nil, err
+	}
+	nonce := make([]byte, len(d.prefix)+streamSuffixSize)
+	copy(nonce, d.prefix)
+	streamNonce(nonce, uint32(i), flag)
+	if m, err = d.aead.Open(in[:0], nonce, in, nil); err != nil {
+		return nil, nil, ErrInvalidMessage
+	}
+	if flag == streamLastIndexed {
+		return parseStreamIndex(m, uint64(i*streamChunkSize))
+	}
+	return m, nil, nil
+}
+
+// ReadAt reads the decrypted message at offset off.
+func (d *SeekableDecrypter) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	for n < len(p) && off < d.size {
+		m, err := d.DecryptChunk(off / streamChunkSize)
+		if err != nil {
+			return n, err
+		}
+		k := copy(p[n:], m[off%streamChunkSize:])
+		n += k
+		off += int64(k)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads the decrypted message from the current offset, keeping the
+// last decrypted chunk for the next reads.
+func (d *SeekableDecrypter) Read(p []byte) (n int, err error) {
+	if d.pos >= d.size {
+		// The only chunk of an empty message is hashed here.
+		if err = d.check(d.chunk); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if i := d.pos / streamChunkSize; i != d.chunk {
+		if d.buf, err = d.DecryptChunk(i); err != nil {
+			d.chunk = -1
+			return 0, err
+		}
+		d.chunk = i
+	}
+	if err = d.check(d.chunk); err != nil {
+		return 0, err
+	}
+	n = copy(p, d.buf[d.pos%streamChunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+// check hashes the chunk of index i in buf if it is the next in order, and
+// checks the hash of the message against the digest once it is complete.
+func (d *SeekableDecrypter) check(i int64) error {
+	if !d.indexed || i != d.hashed {
+		return d.sumErr
+	}
+	d.sum.Write(d.buf)
+	d.hashed++
+	if d.hashed == d.chunks && subtle.ConstantTimeCompare(d.sum.Sum(nil), d.digest) != 1 {
+		d.sumErr = ErrInvalidMessage
+	}
+	return d.sumErr
+}
+
+// Seek sets the offset for the next Read, as per io.Seeker.
+func (d *SeekableDecrypter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, ErrInvalidOffset
+	}
+	if offset < 0 {
+		return 0, ErrInvalidOffset
+	}
+	d.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,76 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// Ensure arbitrary ranges of a stream are decrypted, and tampered chunks are
+// only reported when read.
+func TestSeekableDecrypter(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := make([]byte, 3*streamChunkSize+1000)
+	rand.Read(message)
+	ct, err := encryptStream(&prv.PublicKey, message, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	d, err := NewSeekableDecrypter(prv, bytes.NewReader(ct), int64(len(ct)))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if d.Size() != int64(len(message)) || d.Chunks() != 4 {
+		fmt.Println("ecies: unexpected stream size", d.Size(), d.Chunks())
+		t.FailNow()
+	}
+	for _, r := range [][2]int{{0, 10}, {streamChunkSize - 5, 10}, {100, 2*streamChunkSize + 10}, {len(message) - 7, 7}} {
+		p := make([]byte, r[1])
+		if n, err := d.ReadAt(p, int64(r[0])); err != nil || n != r[1] || !bytes.Equal(p, message[r[0]:r[0]+r[1]]) {
+			fmt.Println("ecies: range not decrypted", r, n, err)
+			t.FailNow()
+		}
+	}
+	if n, err := d.ReadAt(make([]byte, 10), int64(len(message)-3)); n != 3 || err != io.EOF {
+		fmt.Println("ecies: read past the end", n, err)
+		t.FailNow()
+	}
+	if _, err = d.Seek(streamChunkSize+1, io.SeekStart); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rest, err := io.ReadAll(d)
+	if err != nil || !bytes.Equal(rest, message[streamChunkSize+1:]) {
+		fmt.Println("ecies: stream not read after seeking", err)
+		t.FailNow()
+	}
+
+	tampered := append([]byte{}, ct...)
+	tampered[65+7+streamChunkSize+16+3] ^= 1
+	if d, err = NewSeekableDecrypter(prv, bytes.NewReader(tampered), int64(len(tampered))); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = d.ReadAt(make([]byte, 10), 0); err != nil {
+		fmt.Println("ecies: untampered chunk rejected", err)
+		t.FailNow()
+	}
+	if _, err = d.ReadAt(make([]byte, 10), streamChunkSize); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered chunk accepted", err)
+		t.FailNow()
+	}
+	for _, size := range []int{len(ct) - 1, len(ct) - 1000, len(ct) - 1000 - streamChunkSize - 16} {
+		if _, err = NewSeekableDecrypter(prv, bytes.NewReader(ct), int64(size)); err != ErrInvalidMessage {
+			fmt.Println("ecies: truncated stream accepted", size, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ecies
+
+// Power-on self-test: known-answer tests of the whole encryption, key
+// agreement, KDF, DEM and MAC, with keys and IVs drawn from a fixed random
+// stream, for compliance regimes requiring them at startup, and to catch
+// miscompiled cryptography on unusual platforms.
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var ErrSelfTest = newError(KindState, "ecies: self-test failed")
+
+// selfTestVectors are the known answers: the ciphertext of selfTestMessage,
+// with the recipient key, ephemeral key and IV or nonce drawn from the fixed
+// random stream of the vector.
+var selfTestVectors = []struct {
+	curve  func() elliptic.Curve
+	params *ECIESParams
+	want   string
+}{
+	{elliptic.P256, ECIES_AES128_SHA256,
+		"04f482375394deabdb74f98ebc4871be3ade1930d14c81496686ba9bb2f57bbf56d348a496aff50f1cb11b54c37ab1b8" +
+			"aafd528eedbb6073f887cb25d23275e40ed77e5bc233387061a6639c89ff2b0182a6319032f1c95243443dee0010233c" +
+			"5275004e179edf0e85cc0866b952dd591bd29b24a8e74da88573f2e104e730dd4f6d39d1f547d6fa2c12456c"},
+	{elliptic.P384, ECIES_AES192_SHA384,
+		"04191c5eb2f0643bce37046319a1d73e0a43a8bde30b59649370e99740d711831fa2674c3da1bd127b2fd0e632a853a6" +
+			"8414db5e9280a8f8e1fc13ec29d8d534b92771629afd0cc3592fde4b4a14dec7c0ebf08b3b8d0ead795a4d9736e70722" +
+			"20a7a58d588cf2c9be8391f807c07d86ed9d8a7c74650a5c14eb96e1cea16451e994570cff0fb8af482ffcd78a00c04f" +
+			"88289a3a3e8de4430a49b1ce202b5c27cdfeb1ab5b921f958b088ded02a92f91dd7642d109a0ee40083b708d"},
+	{elliptic.P521, ECIES_AES256_SHA512,
+		"0400b3a855f296563dc421928d7eb12530f8c04968bc8af2b894b4d2e5efe0bf39d56dbf5976f6c16ec97b0322fa74f7" +
+			"9006b293bd23184c80e551ff18f50f02876ee70082bc006bf798ebc7053c60931191be8f340a7b2774c5f153b5dc9f8f" +
+			"6e5c2c81ca23c2df13f24d8dff18e069d867f789ddb74e353f3f54a8d37d79d4d9c564863e2ecaf244037a2df91d4dd7" +
+			"2c52f47414487f40683e040f2fb2a8d7b29a134f8a70898abfaee802ae48cb2660e171c4eea99cbd5bd5eef9d5b718b9" +
+			"4cd4d70a650d700fb18483b2fc9db2a8ca1af67a299e0e2dff230fbe0aef75a81492490e967b06491112a83908892ebf"},
+	{X25519, ECIES_AES128_SHA256,
+		"ab362929bf1df26a455f2e9072d676ae628784ae72b0092e6371c5a2add74344e6d0c369ba87220578522170a707390c" +
+			"41c122fcb13113e71c98d0a808edbc93c448b43ae795804ce81ebf95f88c20a00abb9cba1fa092d97f7d08c5cb65d77e" +
+			"ba688bb32ce66d0b153aab"},
+	{elliptic.P256, ECIES_AES128_GCM_SHA256,
+		"043eaa701167485e46f8f4b769f30f63613ff6cafc734c095e809e31c6c02a8435d7136c16ca33a007cde784e9524813" +
+			"52adae8db9390c1728620c82e98358f3fecd71d3d2572d106bb27737ba9add45b2da187ac957477d59fe17b4122f133a" +
+			"65fc7b27b1d2abbb271e519c950cf758becf58a3b47f4232"},
+	{elliptic.P256, ECIES_CHACHA20POLY1305_SHA256,
+		"04aa0204e572ec473387e2bf6aee8eddf589379ebafb86be122301457c67db26f12f3bfdc3322570189479a4009b9558" +
+			"6fe7c36fdf0ea8d658f930c1372408f7614b20a24d960a53bb175f56e0607d506f6d3fe61bf935a092079a9141273dc7" +
+			"ed73c2532e63a0be647797e7fd5a1beb7dc66decc45b14f7"},
+	{elliptic.P256, ECIES_AES128_HKDF_SHA256,
+		"04a2137bd45f18a07a35f7e58cf5a3658851127ff14a669c2043ed6ea7516adcb48ea95f18049336b5d7a394431ee6e7" +
+			"4e499871899c017b46de1c39da760fa9e695681c693ffa52dd334cf4b23c59e7ab680af179efab110c3cce05dc99be66" +
+			"287b53609a076a6369decd08558dbc886683d04ace83486a8f261e422608d7c5f66c30f2188e55dd5e6b9c95"},
+	{elliptic.P256, ECIES_AES128_CMAC_SHA256,
+		"0458ed50a2cabdb00dda0fad3a60710845fe7a359d8b6bfaf160d88720427e761688bc20d6a78b371feaeed75ab785db" +
+			"20200d24dbd2c79ea96221dd6be528db69d6ec826a2a3c13ae1e4a609d8ea6a2de3107dc3c0dc5c7bcbf99eb51e10bdc" +
+			"01e4a75d555f3dfdc78eb737a0574330dc931349f03c4195a76e21c0"},
+}
+
+var (
+	selfTestMessage = []byte("go-ecies power-on self-test")
+	selfTestS1      = []byte("self-test s1")
+	selfTestS2      = []byte("self-test s2")
+)
+
+// selfTestRand returns the fixed random stream of the i-th vector.
+func selfTestRand(i int) io.Reader {
+	r := sha3.NewShake256()
+	fmt.Fprintf(r, "go-ecies self-test %d", i)
+	return r
+}
+
+// selfTestKey returns a key on curve whose scalar is drawn from rand. Unlike
+// GenerateKey, it doesn't depend on the sampling of crypto/ecdh, which may
+// change.
+func selfTestKey(rand io.Reader, curve elliptic.Curve, params *ECIESParams) (*PrivateKey, error) {
+	bits := curve.Params().N.BitLen()
+	d := make([]byte, (bits+7)/8)
+	defer wipe(d)
+	for {
+		if _, err := io.ReadFull(rand, d); err != nil {
+			return nil, err
+		}
+		if excess := bits % 8; excess != 0 {
+			d[0] &= byte(1<<excess - 1)
+		}
+		if prv, err := NewPrivateKey(curve, d); err == nil {
+			prv.PublicKey.Params = params
+			return prv, nil
+		}
+	}
+}
+
+// selfTestCiphertext returns the ciphertext of the i-th vector, and the
+// recipient key.
+func selfTestCiphertext(i int) ([]byte, *PrivateKey, error) {
+	v := selfTestVectors[i]
+	rand := selfTestRand(i)
+	prv, err := selfTestKey(rand, v.curve(), v.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	R, err := selfTestKey(rand, v.curve(), v.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	ct, err := EncryptWithOptions(rand, &prv.PublicKey, selfTestMessage, selfTestS1, selfTestS2, &EncryptOptions{ephemeral: R})
+	return ct, prv, err
+}
+
+// SelfTest runs the known-answer tests of the curves and parameters allowed
+// by the global policy, encrypting and decrypting a message with fixed keys.
+// It returns ErrSelfTest, wrapping the failure, if any output differs from
+// the expected one.
+func SelfTest() error {
+	for i, v := range selfTestVectors {
+		curve := v.curve()
+		if enforceParams(nil, curve, v.params) != nil {
+			continue
+		}
+		name, _ := suiteName(v.params)
+		fail := func(format string, args ...any) error {
+			args = append([]any{curve.Params().Name, name}, args...)
+			return wrapError(ErrSelfTest, fmt.Errorf("%s %s: "+format, args...))
+		}
+		ct, prv, err := selfTestCiphertext(i)
+		if err != nil {
+			return fail("encryption: %w", err)
+		}
+		if hex.EncodeToString(ct) != v.want {
+			return fail("wrong ciphertext")
+		}
+		m, err := Decrypt(prv, ct, selfTestS1, selfTestS2)
+		if err != nil {
+			return fail("decryption: %w", err)
+		}
+		if !bytes.Equal(m, selfTestMessage) {
+			return fail("wrong message")
+		}
+		ct[len(ct)-1] ^= 1
+		if _, err = Decrypt(prv, ct, selfTestS1, selfTestS2); err == nil {
+			return fail("tampered ciphertext decrypted")
+		}
+	}
+	return nil
+}
+
+// MustSelfTest runs SelfTest, and panics if it fails. It is meant to be
+// called from an init function, or at the start of main, of programs which
+// must test their cryptography on startup; building with the ecies_selftest
+// tag calls it when the package is initialized.
+func MustSelfTest() {
+	if err := SelfTest(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build ecies_selftest
+// +build ecies_selftest
+
+package ecies
+
+// Builds with the ecies_selftest tag run the known-answer tests when the
+// package is initialized, and refuse to start if they fail.
+
+func init() {
+	MustSelfTest()
+}
@@ -0,0 +1,24 @@
+package ecies
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure the known answers hold, and that a wrong answer is reported.
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	saved := selfTestVectors[0].want
+	defer func() { selfTestVectors[0].want = saved }()
+	wrong := []byte(saved)
+	wrong[0] ^= 1
+	selfTestVectors[0].want = string(wrong)
+	if err := SelfTest(); !errors.Is(err, ErrSelfTest) {
+		fmt.Println("ecies: wrong known answer accepted", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,65 @@
+package ecies
+
+// SHA-3 (FIPS 202) hash functions, including the SHAKE extendable-output
+// functions with a fixed output size as per RFC 8702.
+
+import (
+	"crypto"
+	"hash"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// crypto.Hash has no values for SHAKE, so these identify it in ECIESParams.
+const (
+	hashSHAKE128 crypto.Hash = 0x100 + iota
+	hashSHAKE256
+)
+
+type shakeHash struct {
+	sha3.ShakeHash
+	size      int
+	blockSize int
+}
+
+// NewSHAKE128 returns SHAKE128 with a 256-bit output as a hash.Hash.
+func NewSHAKE128() hash.Hash {
+	return &shakeHash{sha3.NewShake128(), 32, 168}
+}
+
+// NewSHAKE256 returns SHAKE256 with a 512-bit output as a hash.Hash.
+func NewSHAKE256() hash.Hash {
+	return &shakeHash{sha3.NewShake256(), 64, 136}
+}
+
+func (h *shakeHash) Size() int {
+	return h.size
+}
+
+func (h *shakeHash) BlockSize() int {
+	return h.blockSize
+}
+
+// Sum appends the output to b, without changing the state.
+func (h *shakeHash) Sum(b []byte) []byte {
+	out := make([]byte, h.size)
+	h.Clone().Read(out)
+	return append(b, out...)
+}
+
+// sha3HashFunction returns the constructor of a SHA-3 family hash.
+func sha3HashFunction(h crypto.Hash) func() hash.Hash {
+	switch h {
+	case crypto.SHA3_256:
+		return sha3.New256
+	case crypto.SHA3_384:
+		return sha3.New384
+	case crypto.SHA3_512:
+		return sha3.New512
+	case hashSHAKE128:
+		return NewSHAKE128
+	case hashSHAKE256:
+		return NewSHAKE256
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Ensure the SHA-3 parameters decrypt an independently computed ciphertext,
+// and that all of them survive a DER round trip.
+func TestSHA3(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	h := NewSHAKE128()
+	if !bytes.Equal(h.Sum(nil), hexBytes("7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26")) {
+		fmt.Println("ecies: unexpected SHAKE128 output")
+		t.FailNow()
+	}
+
+	prv, err := NewPrivateKey(elliptic.P256(), hexBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prv.PublicKey.Params = ECIES_AES128_SHA3_256
+	ct := hexBytes("04e266ddfdc12668db30d4ca3e8f7749432c416044f2d2b8c10bf3d4012aeffa8abfa86404a2e9ffe67d47c587ef7a97a7f456b863b4d02cfc6928973ab5b1cb39" +
+		"000102030405060708090a0b0c0d0e0f3afb782b4430a50c3d60a0d8ab" +
+		"bd29f3fec899f2728561850416ff71f4bbd5d144a5918c428c4b07d36a25f82c")
+	pt, err := Decrypt(prv, ct, []byte("s1"), []byte("s2"))
+	if err != nil || string(pt) != "Hello, SHA-3." {
+		fmt.Println("ecies: SHA3-256 vector not decrypted", err)
+		t.FailNow()
+	}
+
+	for _, params := range []*ECIESParams{
+		ECIES_AES128_SHA3_256, ECIES_AES192_SHA3_384, ECIES_AES256_SHA3_512,
+		ECIES_AES128_SHAKE128, ECIES_AES256_SHAKE256,
+	} {
+		want, _ := suiteID(params)
+		prv, err := GenerateKey(rand.Reader, elliptic.P256(), params)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		der, err := MarshalPublic(&prv.PublicKey)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		pub, err := UnmarshalPublic(der)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		if id, ok := suiteID(pub.Params); !ok || id != want {
+			fmt.Println(want, "ecies: SHA-3 parameters lost in DER", id)
+			t.FailNow()
+		}
+		message := []byte("Hello, world.")
+		ct, err := Encrypt(rand.Reader, pub, message, nil, nil)
+		if err != nil {
+			fmt.Println(want, err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(want, "ecies: SHA-3 message not decrypted", err)
+			t.FailNow()
+		}
+	}
+}
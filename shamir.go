@@ -0,0 +1,273 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"math/big"
+)
+
+var (
+	ErrInvalidKeyShares = newError(KindEncoding, "ecies: invalid or insufficient private key shares")
+	ErrKeyShareChecksum = newError(KindEncoding, "ecies: private key share checksum mismatch")
+)
+
+// KeyShare is one share of a private key split with Shamir's secret sharing.
+// Any Threshold shares of the same key can be combined to recover it, while
+// fewer shares reveal nothing about the key.
+type KeyShare struct {
+	Public    *PublicKey // the public key of the split private key
+	Threshold int        // number of shares needed to recover the key
+	Index     int        // x-coordinate of the share, in [1, 255]
+	Value     *big.Int   // y-coordinate of the share, modulo the curve order
+}
+
+const maxKeyShares = 255
+
+// SplitPrivateKey splits a private key into n shares, any t of which recover it.
+// The polynomial is defined over the scalar field of the key's curve. X25519
+// and X448 keys, whose raw bytes aren't scalars modulo the order, are rejected
+// with ErrInvalidPrivateKey.
+func SplitPrivateKey(random io.Reader, prv *PrivateKey, t, n int) ([]*KeyShare, error) {
+	if t < 2 || n < t || n > maxKeyShares {
+		return nil, ErrInvalidKeyShares
+	}
+	if prv == nil || prv.D == nil || prv.Curve == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	if _, ok := prv.Curve.(rawPointCurve); ok {
+		return nil, ErrInvalidPrivateKey
+	}
+	order := prv.Curve.Params().N
+
+	// f(x) = D + a1*x + ... + a(t-1)*x^(t-1)
+	coeffs := make([]*big.Int, t)
+	coeffs[0] = prv.D
+	for i := 1; i < t; i++ {
+		a, err := rand.Int(random, order)
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = a
+	}
+
+	shares := make([]*KeyShare, n)
+	for i := range shares {
+		x := big.NewInt(int64(i + 1))
+		y := new(big.Int)
+		for j := t - 1; j >= 0; j-- {
+			y.Mul(y, x)
+			y.Add(y, coeffs[j])
+			y.Mod(y, order)
+		}
+		shares[i] = &KeyShare{
+			Public:    &prv.PublicKey,
+			Threshold: t,
+			Index:     i + 1,
+			Value:     y,
+		}
+	}
+	return shares, nil
+}
+
+// CombinePrivateKey recovers a private key from at least Threshold of its shares.
+// The recovered key is checked against the public key carried by the shares.
+func CombinePrivateKey(shares []*KeyShare) (*PrivateKey, error) {
+	if len(shares) == 0 || shares[0].Public == nil || shares[0].Public.Curve == nil {
+		return nil, ErrInvalidKeyShares
+	}
+	pub := shares[0].Public
+	if _, ok := pub.Curve.(rawPointCurve); ok {
+		return nil, ErrInvalidKeyShares
+	}
+	t := shares[0].Threshold
+
+	var used []*KeyShare
+	seen := make(map[int]bool)
+	for _, s := range shares {
+		if s.Public == nil || s.Threshold != t || s.Value == nil || !cmpPoints(s.Public, pub) {
+			return nil, ErrInvalidKeyShares
+		}
+		if s.Index < 1 || s.Index > maxKeyShares || seen[s.Index] {
+			return nil, ErrInvalidKeyShares
+		}
+		seen[s.Index] = true
+		if len(used) < t {
+			used = append(used, s)
+		}
+	}
+	if t < 2 || len(used) < t {
+		return nil, ErrInvalidKeyShares
+	}
+
+	// Lagrange interpolation at x = 0.
+	order := pub.Curve.Params().N
+	d := new(big.Int)
+	for i, si := range used {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for j, sj := range used {
+			if i == j {
+				continue
+			}
+			num.Mul(num, big.NewInt(int64(-sj.Index)))
+			num.Mod(num, order)
+			den.Mul(den, big.NewInt(int64(si.Index-sj.Index)))
+			den.Mod(den, order)
+		}
+		den.ModInverse(den, order)
+		term := new(big.Int).Mul(si.Value, num)
+		term.Mul(term, den)
+		d.Add(d, term)
+		d.Mod(d, order)
+	}
+
+	prv, err := NewPrivateKey(pub.Curve, d.Bytes())
+	if err != nil || !cmpPoints(&prv.PublicKey, pub) {
+		return nil, ErrInvalidKeyShares
+	}
+	prv.PublicKey.Params = pub.Params
+	return prv, nil
+}
+
+// cmpPoints returns true if the two public keys are the same point on the same curve.
+func cmpPoints(pub1, pub2 *PublicKey) bool {
+	return pub1.Curve == pub2.Curve && pub1.X.Cmp(pub2.X) == 0 && pub1.Y.Cmp(pub2.Y) == 0
+}
+
+type asnKeyShareVer int
+
+// Version 2 shares end with a checksum, so that custodians restoring a share
+// by hand find out about typos before combining it.
+var (
+	asnKeyShareVer1 asnKeyShareVer = 1
+	asnKeyShareVer2 asnKeyShareVer = 2
+)
+
+const keyShareChecksumSize = 4
+
+type asnKeyShare struct {
+	Version   asnKeyShareVer
+	Curve     secgNamedCurve
+	Public    []byte
+	Threshold int
+	Index     int
+	Value     []byte
+	Checksum  []byte `asn1:"optional"`
+}
+
+// checksum returns the first bytes of the SHA-256 of the share encoded
+// without its checksum.
+func (s asnKeyShare) checksum() ([]byte, error) {
+	s.Checksum = nil
+	der, err := asn1.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(der)
+	return h[:keyShareChecksumSize], nil
+}
+
+// Encode a private key share to DER format.
+func MarshalKeyShare(share *KeyShare) ([]byte, error) {
+	if share.Public == nil || share.Value == nil {
+		return nil, ErrInvalidKeyShares
+	}
+	curve, ok := oidFromNamedCurve(share.Public.Curve)
+	if !ok {
+		return nil, ErrInvalidKeyShares
+	}
+	asnShare := asnKeyShare{
+		Version:   asnKeyShareVer2,
+		Curve:     curve,
+		Public:    CompressPublicKey(share.Public),
+		Threshold: share.Threshold,
+		Index:     share.Index,
+		Value:     share.Value.Bytes(),
+	}
+	var err error
+	if asnShare.Checksum, err = asnShare.checksum(); err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asnShare)
+}
+
+// Decode a DER-encoded private key share. The checksum of version 2 shares
+// is verified, returning ErrKeyShareChecksum on mismatch; version 1 shares
+// have none.
+func UnmarshalKeyShare(in []byte) (*KeyShare, error) {
+	var asnShare asnKeyShare
+	if rest, err := asn1.Unmarshal(in, &asnShare); err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidKeyShares, err)
+	}
+	switch asnShare.Version {
+	case asnKeyShareVer1:
+		if asnShare.Checksum != nil {
+			return nil, ErrInvalidKeyShares
+		}
+	case asnKeyShareVer2:
+		sum, err := asnShare.checksum()
+		if err != nil {
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare(sum, asnShare.Checksum) != 1 {
+			return nil, ErrKeyShareChecksum
+		}
+	default:
+		return nil, ErrInvalidKeyShares
+	}
+	curve := namedCurveFromOID(asnShare.Curve)
+	if curve == nil {
+		return nil, ErrInvalidKeyShares
+	}
+	pub, err := DecompressPublicKey(curve, asnShare.Public)
+	if err != nil {
+		return nil, ErrInvalidKeyShares
+	}
+	value := new(big.Int).SetBytes(asnShare.Value)
+	if value.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrInvalidKeyShares
+	}
+	return &KeyShare{
+		Public:    pub,
+		Threshold: asnShare.Threshold,
+		Index:     asnShare.Index,
+		Value:     value,
+	}, nil
+}
+
+// Export a private key share to PEM format.
+func ExportKeySharePEM(share *KeyShare) (out []byte, err error) {
+	der, err := MarshalKeyShare(share)
+	if err != nil {
+		return
+	}
+
+	var block pem.Block
+	block.Type = "ECIES PRIVATE KEY SHARE"
+	block.Bytes = der
+
+	buf := new(bytes.Buffer)
+	err = pem.Encode(buf, &block)
+	if err != nil {
+		return
+	} else {
+		out = buf.Bytes()
+	}
+	return
+}
+
+// Import a PEM-encoded private key share.
+func ImportKeySharePEM(in []byte) (*KeyShare, error) {
+	p, _ := pem.Decode(in)
+	if p == nil || p.Type != "ECIES PRIVATE KEY SHARE" {
+		return nil, ErrInvalidKeyShares
+	}
+	return UnmarshalKeyShare(p.Bytes)
+}
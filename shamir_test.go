@@ -0,0 +1,169 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure that any threshold of shares recovers the private key, and that the
+// shares survive a PEM round trip.
+func TestSplitPrivateKey(t *testing.T) {
+	for c := range paramsFromCurve {
+		name := c.Params().Name
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+
+		shares, err := SplitPrivateKey(rand.Reader, prv, 3, 5)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		for i, s := range shares {
+			out, err := ExportKeySharePEM(s)
+			if err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+			if shares[i], err = ImportKeySharePEM(out); err != nil {
+				fmt.Println(name, err.Error())
+				t.FailNow()
+			}
+		}
+
+		for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+			var picked []*KeyShare
+			for _, i := range subset {
+				picked = append(picked, shares[i])
+			}
+			prv2, err := CombinePrivateKey(picked)
+			if err != nil {
+				fmt.Println(name, subset, err.Error())
+				t.FailNow()
+			} else if !cmpPrivate(prv, prv2) {
+				fmt.Println(name, subset, "ecies: recovered key doesn't match")
+				t.FailNow()
+			}
+		}
+
+		if _, err := CombinePrivateKey(shares[:2]); err != ErrInvalidKeyShares {
+			fmt.Println(name, "ecies: recovered a key below the threshold")
+			t.FailNow()
+		}
+		if _, err := CombinePrivateKey([]*KeyShare{shares[0], shares[1], shares[1]}); err != ErrInvalidKeyShares {
+			fmt.Println(name, "ecies: recovered a key from duplicate shares")
+			t.FailNow()
+		}
+		shares[1].Value.Add(shares[1].Value, shares[1].Value)
+		if _, err := CombinePrivateKey(shares[:3]); err != ErrInvalidKeyShares {
+			fmt.Println(name, "ecies: recovered a key from a corrupted share")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that invalid split parameters are rejected.
+func TestSplitPrivateKeyParams(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, p := range [][2]int{{1, 3}, {4, 3}, {2, 256}} {
+		if _, err := SplitPrivateKey(rand.Reader, prv, p[0], p[1]); err != ErrInvalidKeyShares {
+			fmt.Println("ecies: accepted invalid split parameters", p)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that X25519 and X448 keys, which can't be split over the scalar
+// field, are rejected rather than split into shares that don't recombine.
+func TestSplitPrivateKeyRaw(t *testing.T) {
+	for _, c := range []elliptic.Curve{X25519(), X448()} {
+		prv, err := GenerateKey(rand.Reader, c, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, err := SplitPrivateKey(rand.Reader, prv, 2, 3); err != ErrInvalidPrivateKey {
+			fmt.Println(c.Params().Name, "ecies: split a raw point key", err)
+			t.FailNow()
+		}
+		shares := []*KeyShare{
+			{Public: &prv.PublicKey, Threshold: 2, Index: 1, Value: big.NewInt(1)},
+			{Public: &prv.PublicKey, Threshold: 2, Index: 2, Value: big.NewInt(2)},
+		}
+		if _, err := CombinePrivateKey(shares); err != ErrInvalidKeyShares {
+			fmt.Println(c.Params().Name, "ecies: combined raw point key shares", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that altered shares fail their checksum, and that version 1 shares,
+// which have none, are still accepted.
+func TestKeyShareChecksum(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	shares, err := SplitPrivateKey(rand.Reader, prv, 2, 2)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	der, err := MarshalKeyShare(shares[0])
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var asnShare asnKeyShare
+	if _, err = asn1.Unmarshal(der, &asnShare); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if asnShare.Version != asnKeyShareVer2 || len(asnShare.Checksum) != keyShareChecksumSize {
+		fmt.Println("ecies: share has no checksum")
+		t.FailNow()
+	}
+
+	asnShare.Value[0] ^= 1
+	altered, err := asn1.Marshal(asnShare)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = UnmarshalKeyShare(altered); err != ErrKeyShareChecksum {
+		fmt.Println("ecies: altered share accepted", err)
+		t.FailNow()
+	}
+
+	asnShare.Value[0] ^= 1
+	asnShare.Version = asnKeyShareVer1
+	asnShare.Checksum = nil
+	v1, err := asn1.Marshal(asnShare)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	share, err := UnmarshalKeyShare(v1)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if prv2, err := CombinePrivateKey([]*KeyShare{share, shares[1]}); err != nil || !cmpPrivate(prv, prv2) {
+		fmt.Println("ecies: version 1 share not combined", err)
+		t.FailNow()
+	}
+}
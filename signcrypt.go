@@ -0,0 +1,105 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Signcryption: sign-then-encrypt, with the signature covering the recipient
+// so that a recipient can't forward a signed message as if it had been sent
+// to someone else. The encrypted plaintext is
+//
+//	len(signer) || signer || len(signature) || signature || m
+//
+// with the lengths as 16-bit big-endian integers, and the signer public key
+// in the SubjectPublicKeyInfo DER format. The signature covers the context,
+// the fingerprint of the recipient public key and the message.
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"io"
+)
+
+var ErrInvalidSignature = newError(KindAuthentication, "ecies: invalid signature of the signcrypted message")
+
+// signcryptContext separates signcryption signatures from any other use of
+// the key.
+var signcryptContext = []byte("ecies-signcrypt-v1\x00")
+
+// signcryptMessage returns what is signed, hashed unless the key signs
+// messages directly.
+func signcryptMessage(signer crypto.PublicKey, recipient *PublicKey, m []byte) ([]byte, crypto.SignerOpts, error) {
+	msg := make([]byte, 0, len(signcryptContext)+sha256.Size+len(m))
+	msg = append(msg, signcryptContext...)
+	msg = append(msg, keyFingerprint(recipient)...)
+	msg = append(msg, m...)
+	switch signer.(type) {
+	case *ecdsa.PublicKey:
+		h := sha256.Sum256(msg)
+		return h[:], crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return msg, crypto.Hash(0), nil
+	}
+	return nil, nil, ErrUnsupportedSigner
+}
+
+// SignThenEncrypt signs a message with an ECDSA or Ed25519 signer, then
+// encrypts it to pub with Encrypt, along with the signature and the signer
+// public key. DecryptThenVerify decrypts it and checks the signature.
+func SignThenEncrypt(rand io.Reader, signer crypto.Signer, pub *PublicKey, m, s1, s2 []byte) ([]byte, error) {
+	msg, opts, err := signcryptMessage(signer.Public(), pub, m)
+	if err != nil {
+		return nil, err
+	}
+	signerKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(rand, msg, opts)
+	if err != nil {
+		return nil, err
+	}
+	pt := make([]byte, 0, 4+len(signerKey)+len(sig)+len(m))
+	pt = appendField(pt, signerKey)
+	pt = appendField(pt, sig)
+	pt = append(pt, m...)
+	return Encrypt(rand, pub, pt, s1, s2)
+}
+
+// DecryptThenVerify decrypts a message produced by SignThenEncrypt, and
+// verifies its signature for the key of prv. It returns the message and the
+// public key of the signer, which the caller must check is trusted: anyone
+// can sign a message with their own key.
+func DecryptThenVerify(prv KeyProvider, c, s1, s2 []byte) (m []byte, signer crypto.PublicKey, err error) {
+	pt, err := Decrypt(prv, c, s1, s2)
+	if err != nil {
+		return nil, nil, err
+	}
+	signerKey, rest, ok := readField(pt)
+	if !ok {
+		return nil, nil, ErrInvalidMessage
+	}
+	sig, m, ok := readField(rest)
+	if !ok {
+		return nil, nil, ErrInvalidMessage
+	}
+	if signer, err = x509.ParsePKIXPublicKey(signerKey); err != nil {
+		return nil, nil, ErrUnsupportedSigner
+	}
+	msg, _, err := signcryptMessage(signer, prv.Public(), m)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch pub := signer.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(pub, msg, sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, msg, sig)
+	}
+	if !ok {
+		return nil, nil, ErrInvalidSignature
+	}
+	return m, signer, nil
+}
@@ -0,0 +1,84 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure that signcrypted messages return their signer, and that they can't
+// be forwarded to another recipient under the same signature.
+func TestSignThenEncrypt(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	bob, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	carol, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, Bob.")
+
+	for _, signer := range []crypto.Signer{ecKey, edKey} {
+		ct, err := SignThenEncrypt(rand.Reader, signer, &bob.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pt, from, err := DecryptThenVerify(bob, ct, nil, nil)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: signcrypted message not decrypted", err)
+			t.FailNow()
+		}
+		if eq, ok := from.(interface{ Equal(crypto.PublicKey) bool }); !ok || !eq.Equal(signer.Public()) {
+			fmt.Println("ecies: unexpected signer")
+			t.FailNow()
+		}
+
+		// Bob forwards the signed message to Carol.
+		inner, err := Decrypt(bob, ct, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		forwarded, err := Encrypt(rand.Reader, &carol.PublicKey, inner, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if _, _, err = DecryptThenVerify(carol, forwarded, nil, nil); err != ErrInvalidSignature {
+			fmt.Println("ecies: forwarded message verified", err)
+			t.FailNow()
+		}
+	}
+
+	ct, err := Encrypt(rand.Reader, &bob.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, _, err = DecryptThenVerify(bob, ct, nil, nil); err == nil {
+		fmt.Println("ecies: unsigned message verified")
+		t.FailNow()
+	}
+}
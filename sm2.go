@@ -0,0 +1,246 @@
+package ecies
+
+// SM2 public key encryption (GB/T 32918.4-2016) on the SM2 curve, with the
+// SM3 hash, for interoperability with the devices which only speak SM2. Its
+// ciphertexts are C1 || C3 || C2 or C1 || C2 || C3, with C1 the ephemeral
+// point, C2 the message masked with the KDF output, and C3 the SM3 tag, or
+// the ASN.1 structure of GM/T 0009.
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"io"
+	"math/big"
+	"sync"
+)
+
+var ErrInvalidSM2Mode = newError(KindParams, "ecies: invalid SM2 ciphertext mode")
+
+// SM2Mode is the layout of an SM2 ciphertext.
+type SM2Mode int
+
+const (
+	// SM2C1C3C2 is C1 || C3 || C2, the order of GB/T 32918.4-2016.
+	SM2C1C3C2 SM2Mode = iota
+	// SM2C1C2C3 is C1 || C2 || C3, the order of the 2010 draft, still used by
+	// some devices.
+	SM2C1C2C3
+	// SM2ASN1 is the DER encoding of the SM2Cipher structure of GM/T 0009. It
+	// is refused with ErrInvalidSM2Mode in builds with the ecies_tiny tag.
+	SM2ASN1
+)
+
+type sm2Curve struct {
+	*elliptic.CurveParams
+}
+
+var (
+	sm2Once sync.Once
+	sm2     *sm2Curve
+)
+
+func initSM2() {
+	params := &elliptic.CurveParams{Name: "SM2", BitSize: 256}
+	params.P, _ = new(big.Int).SetString("fffffffeffffffffffffffffffffffffffffffff00000000ffffffffffffffff", 16)
+	params.N, _ = new(big.Int).SetString("fffffffeffffffffffffffffffffffff7203df6b21c6052b53bbf40939d54123", 16)
+	params.B, _ = new(big.Int).SetString("28e9fa9e9d9f5e344d5a9e4bcf6509a7f39789f515ab8f92ddbcbd414d940e93", 16)
+	params.Gx, _ = new(big.Int).SetString("32c4ae2c1f1981195f9904466a39c9948fe30bbff2660be1715a4589334c74c7", 16)
+	params.Gy, _ = new(big.Int).SetString("bc3736a2f4f6779c59bdcee36b692153d0a9877cc62a474002df32e52139f0a0", 16)
+	sm2 = &sm2Curve{params}
+}
+
+// SM2 returns a Curve which implements the SM2 curve of GB/T 32918.5,
+// y² = x³ - 3x + b.
+//
+// Like Secp256k1, its operations are not constant-time. Its keys work with
+// the ECIES functions of the package too, but only EncryptSM2 produces
+// ciphertexts the SM2 implementations understand.
+func SM2() elliptic.Curve {
+	sm2Once.Do(initSM2)
+	return sm2
+}
+
+// EncryptSM2 encrypts the message m for the SM2 public key pub, in the given
+// ciphertext mode.
+func EncryptSM2(rand io.Reader, pub *PublicKey, m []byte, mode SM2Mode) ([]byte, error) {
+	if mode < SM2C1C3C2 || mode > SM2ASN1 {
+		return nil, ErrInvalidSM2Mode
+	}
+	if pub == nil || pub.Curve != SM2() {
+		return nil, ErrInvalidCurve
+	}
+	if err := ValidatePublicKey(pub); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, ErrInvalidMessage
+	}
+
+	var (
+		R      *PrivateKey
+		x2, y2 []byte
+		t      []byte
+	)
+	for {
+		var err error
+		if R, err = GenerateKey(rand, pub.Curve, nil); err != nil {
+			return nil, err
+		}
+		d := R.D.Bytes()
+		x, y := pub.Curve.ScalarMult(pub.X, pub.Y, d)
+		wipe(d)
+		x2, y2 = x.FillBytes(make([]byte, 32)), y.FillBytes(make([]byte, 32))
+		if t, err = sm2KDF(x2, y2, len(m)); err != nil {
+			return nil, err
+		}
+		// An all-zero KDF output would leave the message in the clear: the
+		// standard draws another ephemeral key.
+		if t != nil {
+			break
+		}
+	}
+	defer wipe(x2)
+	defer wipe(y2)
+	defer wipe(t)
+
+	c2 := make([]byte, len(m))
+	subtle.XORBytes(c2, m, t)
+	c3 := sm2Tag(x2, m, y2)
+	if mode == SM2ASN1 {
+		return marshalSM2Cipher(R.X, R.Y, c3, c2)
+	}
+	c1 := marshalPoint(pub.Curve, R.X, R.Y)
+	out := make([]byte, 0, len(c1)+len(c3)+len(c2))
+	out = append(out, c1...)
+	if mode == SM2C1C3C2 {
+		return append(append(out, c3...), c2...), nil
+	}
+	return append(append(out, c2...), c3...), nil
+}
+
+// DecryptSM2 decrypts an SM2 ciphertext in the given mode with the private
+// key of prv, which needs only to compute the x-coordinate of the shared
+// point, as any KeyProvider does: the y-coordinate is recovered from the
+// curve equation, and the one of its two roots matching the tag is kept.
+func DecryptSM2(prv KeyProvider, c []byte, mode SM2Mode) ([]byte, error) {
+	if mode < SM2C1C3C2 || mode > SM2ASN1 {
+		return nil, ErrInvalidSM2Mode
+	}
+	pub := prv.Public()
+	if pub.Curve != SM2() {
+		return nil, ErrInvalidCurve
+	}
+
+	var R *PublicKey
+	var c2, c3 []byte
+	if mode == SM2ASN1 {
+		var err error
+		if R, c2, c3, err = unmarshalSM2Cipher(pub, c); err != nil {
+			return nil, err
+		}
+	} else {
+		size := 0
+		if len(c) > 0 {
+			size = pointSize(pub.Curve, c[0], AllowCompressedPoints)
+		}
+		if size == 0 || len(c) <= size+sm3Size {
+			return nil, ErrInvalidMessage
+		}
+		var err error
+		if R, err = parseEncapsulation(pub, c[:size], AllowCompressedPoints); err != nil {
+			return nil, err
+		}
+		if mode == SM2C1C3C2 {
+			c3, c2 = c[size:size+sm3Size], c[size+sm3Size:]
+		} else {
+			c2, c3 = c[size:len(c)-sm3Size], c[len(c)-sm3Size:]
+		}
+	}
+	if len(c2) == 0 || len(c3) != sm3Size {
+		return nil, ErrInvalidMessage
+	}
+
+	x2, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(x2)
+	y, ok := sm2Y(pub.Curve, x2)
+	if !ok {
+		return nil, ErrInvalidMessage
+	}
+
+	// Both roots are tried, so that the time taken doesn't tell which one
+	// the shared point has.
+	m := make([]byte, len(c2))
+	candidate := make([]byte, len(c2))
+	defer wipe(candidate)
+	found := 0
+	for _, root := range []*big.Int{y, new(big.Int).Sub(pub.Curve.Params().P, y)} {
+		y2 := root.FillBytes(make([]byte, 32))
+		t, err := sm2KDF(x2, y2, len(c2))
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			wipe(y2)
+			continue
+		}
+		subtle.XORBytes(candidate, c2, t)
+		match := subtle.ConstantTimeCompare(sm2Tag(x2, candidate, y2), c3)
+		subtle.ConstantTimeCopy(match, m, candidate)
+		found |= match
+		wipe(t)
+		wipe(y2)
+	}
+	if found != 1 {
+		wipe(m)
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
+
+// sm2KDF returns the KDF output masking a message of length bytes, or nil if
+// it is all zeros.
+func sm2KDF(x2, y2 []byte, length int) ([]byte, error) {
+	z := make([]byte, 0, len(x2)+len(y2))
+	z = append(append(z, x2...), y2...)
+	defer wipe(z)
+	t, err := x963KDF(NewSM3(), z, nil, length)
+	if err != nil {
+		return nil, err
+	}
+	var acc byte
+	for _, b := range t {
+		acc |= b
+	}
+	if acc == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// sm2Tag returns C3 = SM3(x2 || M || y2).
+func sm2Tag(x2, m, y2 []byte) []byte {
+	h := NewSM3()
+	h.Write(x2)
+	h.Write(m)
+	h.Write(y2)
+	return h.Sum(nil)
+}
+
+// sm2Y returns a square root of x³ - 3x + b, the y-coordinate of one of the
+// points of x-coordinate x.
+func sm2Y(curve elliptic.Curve, x []byte) (*big.Int, bool) {
+	params := curve.Params()
+	X := new(big.Int).SetBytes(x)
+	y2 := new(big.Int).Mul(X, X)
+	y2.Mul(y2, X)
+	threeX := new(big.Int).Lsh(X, 1)
+	threeX.Add(threeX, X)
+	y2.Sub(y2, threeX)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	y := new(big.Int).ModSqrt(y2, params.P)
+	return y, y != nil
+}
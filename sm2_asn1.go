@@ -0,0 +1,38 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// The ASN.1 SM2 ciphertexts of GM/T 0009, compiled out with the ecies_tiny
+// build tag.
+
+import (
+	"encoding/asn1"
+	"math/big"
+)
+
+// sm2Cipher is the SM2Cipher structure of GM/T 0009-2012.
+type sm2Cipher struct {
+	X, Y       *big.Int
+	Hash       []byte
+	CipherText []byte
+}
+
+func marshalSM2Cipher(x, y *big.Int, c3, c2 []byte) ([]byte, error) {
+	return asn1.Marshal(sm2Cipher{x, y, c3, c2})
+}
+
+// unmarshalSM2Cipher decodes an SM2Cipher for pub into the ephemeral key, the
+// masked message and the tag.
+func unmarshalSM2Cipher(pub *PublicKey, c []byte) (R *PublicKey, c2, c3 []byte, err error) {
+	var sc sm2Cipher
+	rest, err := asn1.Unmarshal(c, &sc)
+	if err != nil || len(rest) != 0 {
+		return nil, nil, nil, asn1Error(ErrInvalidMessage, err)
+	}
+	if sc.X.Sign() < 0 || sc.Y.Sign() < 0 || !pub.Curve.IsOnCurve(sc.X, sc.Y) {
+		return nil, nil, nil, ErrInvalidPublicKey
+	}
+	R = &PublicKey{X: sc.X, Y: sc.Y, Curve: pub.Curve, Params: pub.Params}
+	return R, sc.CipherText, sc.Hash, nil
+}
@@ -0,0 +1,15 @@
+//go:build ecies_tiny
+// +build ecies_tiny
+
+package ecies
+
+import "math/big"
+
+// The ASN.1 SM2 ciphertexts are compiled out.
+func marshalSM2Cipher(x, y *big.Int, c3, c2 []byte) ([]byte, error) {
+	return nil, ErrInvalidSM2Mode
+}
+
+func unmarshalSM2Cipher(pub *PublicKey, c []byte) (R *PublicKey, c2, c3 []byte, err error) {
+	return nil, nil, nil, ErrInvalidSM2Mode
+}
@@ -0,0 +1,160 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Ensure SM3 matches the examples of GB/T 32905-2016.
+func TestSM3(t *testing.T) {
+	for _, v := range []struct{ in, out string }{
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{string(bytes.Repeat([]byte("abcd"), 16)), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	} {
+		h := NewSM3()
+		h.Write([]byte(v.in[:1]))
+		h.Write([]byte(v.in[1:]))
+		if hex.EncodeToString(h.Sum(nil)) != v.out {
+			fmt.Println("ecies: SM3 digest mismatch")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure SM2 ciphertexts decrypt in each mode, to the message only, and
+// that tampering is detected.
+func TestSM2(t *testing.T) {
+	curve := SM2()
+	params := curve.Params()
+	if !curve.IsOnCurve(params.Gx, params.Gy) {
+		fmt.Println("ecies: SM2 base point not on the curve")
+		t.FailNow()
+	}
+	if x, _ := affineOrNil(curve.ScalarBaseMult(params.N.Bytes())); x != nil {
+		fmt.Println("ecies: SM2 base point of wrong order")
+		t.FailNow()
+	}
+
+	prv, err := GenerateKey(rand.Reader, curve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, curve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	der, err := MarshalPublicPKIX(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pub, err := UnmarshalPublicPKIX(der); err != nil || !pub.Equal(&prv.PublicKey) {
+		fmt.Println("ecies: SM2 public key not decoded", err)
+		t.FailNow()
+	}
+
+	message := []byte("Hello, world.")
+	for _, mode := range []SM2Mode{SM2C1C3C2, SM2C1C2C3, SM2ASN1} {
+		ct, err := EncryptSM2(rand.Reader, &prv.PublicKey, message, mode)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if mode != SM2ASN1 && len(ct) != 65+len(message)+32 {
+			fmt.Println("ecies: unexpected SM2 ciphertext length", len(ct))
+			t.FailNow()
+		}
+		pt, err := DecryptSM2(prv, ct, mode)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: SM2 message not decrypted", mode, err)
+			t.FailNow()
+		}
+		if _, err := DecryptSM2(other, ct, mode); err == nil {
+			fmt.Println("ecies: SM2 message decrypted with the wrong key")
+			t.FailNow()
+		}
+		for _, i := range []int{len(ct) - 1, len(ct) - len(message) - 1} {
+			ct[i] ^= 1
+			if _, err := DecryptSM2(prv, ct, mode); err == nil {
+				fmt.Println("ecies: tampered SM2 message decrypted", mode)
+				t.FailNow()
+			}
+			ct[i] ^= 1
+		}
+	}
+
+	ct, err := EncryptSM2(rand.Reader, &prv.PublicKey, message, SM2C1C3C2)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err := DecryptSM2(prv, ct, SM2C1C2C3); err == nil {
+		fmt.Println("ecies: SM2 message decrypted in the wrong mode")
+		t.FailNow()
+	}
+	p256, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err := EncryptSM2(rand.Reader, &p256.PublicKey, message, SM2C1C3C2); err != ErrInvalidCurve {
+		fmt.Println("ecies: SM2 encryption to a P-256 key")
+		t.FailNow()
+	}
+}
+
+// Ensure SM2 ciphertexts match those of tjfoc/gmsm v1.4.1 (sm2.Encrypt in
+// the C1C3C2 and C1C2C3 modes, and sm2.EncryptAsn1 for GM/T 0009), for the
+// message "encryption standard", the private key d below and the ephemeral
+// key k = 59276e27d506861a16680f3ad9c02dccef3cc1fa3cdbe4ce6d54b80deac1bc21.
+func TestSM2Vectors(t *testing.T) {
+	hexBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	prv, err := NewPrivateKey(SM2(), hexBytes("3945208f7b2144b13f36e38ac6d39f95889393692860b51a42fb81ef4df7c5b8"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if hex.EncodeToString(marshalPoint(SM2(), prv.X, prv.Y)) != "0409f9df311e5421a150dd7d161e4bc5c672179fad1833fc076bb08ff356f35020ccea490ce26775a52dc6ea718cc1aa600aed05fbf35e084a6632f6072da9ad13" {
+		fmt.Println("ecies: unexpected SM2 public key")
+		t.FailNow()
+	}
+	// elliptic.GenerateKey flips bits of the second byte it reads.
+	k := hexBytes("59276e27d506861a16680f3ad9c02dccef3cc1fa3cdbe4ce6d54b80deac1bc21")
+	k[1] ^= 0x42
+	message := []byte("encryption standard")
+	for _, c := range []struct {
+		Mode SM2Mode
+		C    string
+	}{
+		{SM2C1C3C2, "0404ebfc718e8d1798620432268e77feb6415e2ede0e073c0f4f640ecd2e149a73e858f9d81e5430a57b36daab8f950a3c64e6ee6a63094d99283aff767e124df0" +
+			"59983c18f809e262923c53aec295d30383b54e39d609d160afcb1908d0bd8766" + "21886ca989ca9c7d58087307ca93092d651efa"},
+		{SM2C1C2C3, "0404ebfc718e8d1798620432268e77feb6415e2ede0e073c0f4f640ecd2e149a73e858f9d81e5430a57b36daab8f950a3c64e6ee6a63094d99283aff767e124df0" +
+			"21886ca989ca9c7d58087307ca93092d651efa" + "59983c18f809e262923c53aec295d30383b54e39d609d160afcb1908d0bd8766"},
+		{SM2ASN1, "307c" + "022004ebfc718e8d1798620432268e77feb6415e2ede0e073c0f4f640ecd2e149a73" +
+			"022100e858f9d81e5430a57b36daab8f950a3c64e6ee6a63094d99283aff767e124df0" +
+			"042059983c18f809e262923c53aec295d30383b54e39d609d160afcb1908d0bd8766" + "041321886ca989ca9c7d58087307ca93092d651efa"},
+	} {
+		want := hexBytes(c.C)
+		if m, err := DecryptSM2(prv, want, c.Mode); err != nil || !bytes.Equal(m, message) {
+			fmt.Println(c.Mode, "ecies: SM2 vector not decrypted", err)
+			t.FailNow()
+		}
+		got, err := EncryptSM2(bytes.NewReader(k), &prv.PublicKey, message, c.Mode)
+		if err != nil || !bytes.Equal(got, want) {
+			fmt.Println(c.Mode, "ecies: unexpected SM2 ciphertext", hex.EncodeToString(got), err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrUnsupportedSSHKey = newError(KindUnsupported, "ecies: unsupported SSH key type")
+
+// ParseSSHPublicKey parses a public key in the OpenSSH authorized_keys format,
+// such as a line of an authorized_keys file or the content of an id_*.pub file.
+// Options and comments are ignored.
+//
+// ECDSA keys (ecdsa-sha2-nistp256, -nistp384 and -nistp521) are returned as is,
+// and Ed25519 keys as their X25519 conversion, as in EncryptToEd25519. Other
+// key types, including RSA and security key backed keys, return
+// ErrUnsupportedSSHKey.
+func ParseSSHPublicKey(authorizedKeyLine []byte) (*PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(authorizedKeyLine)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return importSSHPublicKey(key)
+}
+
+// ParseSSHAuthorizedKeys parses the keys of an authorized_keys file which can
+// receive messages, as ParseSSHPublicKey does. Blank lines and comments are
+// skipped, as are the keys of unsupported types, so that a file can be used as
+// a recipient list as is.
+func ParseSSHAuthorizedKeys(in []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	for _, line := range bytes.Split(in, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		pub, err := ParseSSHPublicKey(line)
+		if err == ErrUnsupportedSSHKey {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pub)
+	}
+	return keys, nil
+}
+
+func importSSHPublicKey(key ssh.PublicKey) (*PublicKey, error) {
+	switch key.Type() {
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoED25519:
+	default:
+		return nil, ErrUnsupportedSSHKey
+	}
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, ErrUnsupportedSSHKey
+	}
+	switch pub := cryptoKey.CryptoPublicKey().(type) {
+	case *ecdsa.PublicKey:
+		return importECDSAPublic(pub)
+	case ed25519.PublicKey:
+		return Ed25519PublicKeyToX25519(pub)
+	}
+	return nil, ErrUnsupportedSSHKey
+}
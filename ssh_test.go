@@ -0,0 +1,125 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func authorizedKey(t *testing.T, key interface{}, options string) []byte {
+	pub, err := ssh.NewPublicKey(key)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	line := bytes.TrimSpace(ssh.MarshalAuthorizedKey(pub))
+	return append([]byte(options), append(line, " user@host"...)...)
+}
+
+// Ensure that ECDSA and Ed25519 SSH keys are imported, and can decrypt the
+// messages encrypted to them.
+func TestSSHPublicKey(t *testing.T) {
+	message := []byte("Hello, SSH.")
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		prv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pub, err := ParseSSHPublicKey(authorizedKey(t, &prv.PublicKey, `from="10.0.0.0/8" `))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pub.Curve != curve || pub.X.Cmp(prv.X) != 0 || pub.Y.Cmp(prv.Y) != 0 {
+			fmt.Println("ecies: SSH key not imported")
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, pub, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(ImportECDSA(prv), ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: message not decrypted", err)
+			t.FailNow()
+		}
+	}
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub, err := ParseSSHPublicKey(authorizedKey(t, edPub, ""))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := Encrypt(rand.Reader, pub, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := DecryptWithEd25519(edPriv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: message not decrypted with the Ed25519 key", err)
+		t.FailNow()
+	}
+
+	if _, err = ParseSSHPublicKey([]byte("ssh-ed25519 AAAA")); err != ErrInvalidPublicKey {
+		fmt.Println("ecies: accepted an invalid SSH key", err)
+		t.FailNow()
+	}
+}
+
+// Ensure that the unsupported keys of an authorized_keys file are skipped.
+func TestSSHAuthorizedKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	rsaLine := authorizedKey(t, &rsaKey.PublicKey, "")
+	if _, err = ParseSSHPublicKey(rsaLine); err != ErrUnsupportedSSHKey {
+		fmt.Println("ecies: accepted an RSA key", err)
+		t.FailNow()
+	}
+
+	var file bytes.Buffer
+	file.WriteString("# deploy keys\n\n")
+	file.Write(authorizedKey(t, &ecKey.PublicKey, ""))
+	file.WriteString("\n")
+	file.Write(rsaLine)
+	file.WriteString("\n")
+	file.Write(authorizedKey(t, edKey, "no-pty "))
+	file.WriteString("\n")
+	keys, err := ParseSSHAuthorizedKeys(file.Bytes())
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if len(keys) != 2 || keys[0].Curve != elliptic.P256() || keys[1].Curve != X25519() {
+		fmt.Println("ecies: unexpected authorized keys")
+		t.FailNow()
+	}
+}
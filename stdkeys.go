@@ -0,0 +1,122 @@
+package ecies
+
+// Encryption to, and decryption with, the key types of the standard library,
+// crypto/ecdsa and crypto/ecdh, without converting them first.
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"io"
+)
+
+var ErrUnsupportedKeyType = newError(KindUnsupported, "ecies: unsupported key type")
+
+// StdPublicKey is the set of public key types EncryptTo accepts.
+type StdPublicKey interface {
+	*PublicKey | *ecdsa.PublicKey | *ecdh.PublicKey
+}
+
+// StdPrivateKey is the set of private key types DecryptWith accepts.
+type StdPrivateKey interface {
+	*PrivateKey | *ecdsa.PrivateKey | *ecdh.PrivateKey
+}
+
+// EncryptTo encrypts a message like Encrypt, to a public key of this package,
+// crypto/ecdsa or crypto/ecdh. Keys of the latter two get the default
+// parameters of their curve.
+func EncryptTo[K StdPublicKey](rand io.Reader, pub K, m, s1, s2 []byte) ([]byte, error) {
+	key, err := ImportPublic(pub)
+	if err != nil {
+		return nil, err
+	}
+	return Encrypt(rand, key, m, s1, s2)
+}
+
+// DecryptWith decrypts a message like Decrypt, with a private key of this
+// package, crypto/ecdsa or crypto/ecdh.
+func DecryptWith[K StdPrivateKey](prv K, c, s1, s2 []byte) ([]byte, error) {
+	key, err := ImportPrivate(prv)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(key, c, s1, s2)
+}
+
+// ImportPublic returns the public key of this package for a *PublicKey,
+// returned as is, or for an *ecdsa.PublicKey or *ecdh.PublicKey, with the
+// default parameters of its curve. It returns ErrUnsupportedKeyType for other
+// types, and ErrInvalidCurve for curves without default parameters.
+func ImportPublic(pub crypto.PublicKey) (*PublicKey, error) {
+	switch k := pub.(type) {
+	case *PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		if k == nil || k.Curve == nil || ParamsFromCurve(k.Curve) == nil {
+			return nil, ErrInvalidCurve
+		}
+		key := ImportECDSAPublic(k)
+		if err := ValidatePublicKey(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case *ecdh.PublicKey:
+		if k == nil {
+			return nil, ErrInvalidPublicKey
+		}
+		curve := curveFromECDH(k.Curve())
+		if curve == nil {
+			return nil, ErrInvalidCurve
+		}
+		return NewPublicKeyFromBytes(curve, k.Bytes())
+	}
+	return nil, ErrUnsupportedKeyType
+}
+
+// ImportPrivate returns the private key of this package for a *PrivateKey,
+// returned as is, or for an *ecdsa.PrivateKey or *ecdh.PrivateKey, with the
+// default parameters of its curve. It returns ErrUnsupportedKeyType for other
+// types, and ErrInvalidCurve for curves without default parameters.
+func ImportPrivate(prv crypto.PrivateKey) (*PrivateKey, error) {
+	switch k := prv.(type) {
+	case *PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		if k == nil || k.Curve == nil || ParamsFromCurve(k.Curve) == nil {
+			return nil, ErrInvalidCurve
+		}
+		if k.D == nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		return ImportECDSA(k), nil
+	case *ecdh.PrivateKey:
+		if k == nil {
+			return nil, ErrInvalidPrivateKey
+		}
+		if k.Curve() == ecdh.X25519() {
+			return newX25519PrivateKey(k), nil
+		}
+		curve := curveFromECDH(k.Curve())
+		if curve == nil {
+			return nil, ErrInvalidCurve
+		}
+		return newNISTPrivateKey(curve, k), nil
+	}
+	return nil, ErrUnsupportedKeyType
+}
+
+// curveFromECDH returns the curve of this package for a crypto/ecdh curve.
+func curveFromECDH(curve ecdh.Curve) elliptic.Curve {
+	switch curve {
+	case ecdh.X25519():
+		return X25519()
+	case ecdh.P256():
+		return elliptic.P256()
+	case ecdh.P384():
+		return elliptic.P384()
+	case ecdh.P521():
+		return elliptic.P521()
+	}
+	return nil
+}
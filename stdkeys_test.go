@@ -0,0 +1,78 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure messages round trip between the keys of this package and those of
+// crypto/ecdsa and crypto/ecdh.
+func TestStdKeys(t *testing.T) {
+	message := []byte("Hello, world.")
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := EncryptTo(rand.Reader, &ecdsaKey.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err := DecryptWith(ecdsaKey, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: message to an ECDSA key not decrypted", err)
+		t.FailNow()
+	}
+	if pt, err := Decrypt(ImportECDSA(ecdsaKey), ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: default P-384 parameters not inferred", err)
+		t.FailNow()
+	}
+
+	for _, curve := range []ecdh.Curve{ecdh.X25519(), ecdh.P256(), ecdh.P521()} {
+		ecdhKey, err := curve.GenerateKey(rand.Reader)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		ct, err := EncryptTo(rand.Reader, ecdhKey.PublicKey(), message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := DecryptWith(ecdhKey, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: message to an ECDH key not decrypted", curve, err)
+			t.FailNow()
+		}
+		prv, err := ImportPrivate(ecdhKey)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		pub, err := ImportPublic(ecdhKey.PublicKey())
+		if err != nil || !pub.Equal(&prv.PublicKey) || pub.Params != prv.Params {
+			fmt.Println("ecies: ECDH keys imported differently", curve, err)
+			t.FailNow()
+		}
+	}
+
+	if _, err := ImportPublic("not a key"); !errors.Is(err, ErrUnsupportedKeyType) {
+		fmt.Println("ecies: unsupported key type imported", err)
+		t.FailNow()
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err := EncryptTo(rand.Reader, &p224.PublicKey, message, nil, nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: encrypted to a curve without default parameters", err)
+		t.FailNow()
+	}
+}
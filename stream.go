@@ -0,0 +1,435 @@
+package ecies
+
+// Streaming encryption: a single key agreement, then the message is sealed
+// in fixed-size chunks with the STREAM construction (Hoang, Reyhanitabar,
+// Rogaway and Vizár), so that it can be processed with constant memory.
+//
+// The stream starts with the ephemeral public key and a random nonce
+// prefix. Each chunk holds streamChunkSize bytes of the message, but the
+// last one which may be shorter, sealed with the nonce prefix || 32-bit
+// big-endian chunk counter || last chunk flag. Reordered, dropped or
+// truncated chunks fail to authenticate.
+//
+// With StreamOptions.Index, the last chunk is flagged 2 instead of 1, and
+// its message is followed by an index: the 32-bit big-endian chunk size, the
+// 64-bit big-endian size of the message and its SHA-256 hash. As the chunks
+// have a fixed size, their offsets follow from the index, and the hash is
+// authenticated along with the last chunk.
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+var (
+	ErrStreamClosed   = newError(KindState, "ecies: stream already closed")
+	ErrStreamTooLarge = newError(KindParams, "ecies: stream too large")
+)
+
+// streamChunkSize is the size of the message in each chunk but the last.
+const streamChunkSize = 64 * 1024
+
+// streamSuffixSize is the size of the counter and flag ending the nonces.
+const streamSuffixSize = 5
+
+// The flags ending the nonces of the chunks.
+const (
+	streamChunk       = 0
+	streamLast        = 1
+	streamLastIndexed = 2
+)
+
+// streamIndexSize is the size of the index ending indexed streams.
+const streamIndexSize = 4 + 8 + sha256.Size
+
+// streamReadCapacity is the read buffer of NewDecryptingReader, which must
+// hold a chunk, its tag and the index.
+const streamReadCapacity = streamChunkSize + 1024
+
+// StreamOptions tune NewEncryptingWriterWithOptions.
+type StreamOptions struct {
+	// Index ends the stream with the size of its chunks and message, and
+	// the SHA-256 hash of the message, sealed in its last chunk. Readers
+	// can then locate chunks without authenticating the last one first,
+	// and confirm which message ranges read with a SeekableDecrypter
+	// belong to, from its Digest.
+	Index bool
+	// Resumable keeps the stream key in the writer, for Checkpoint.
+	Resumable bool
+}
+
+// streamAEAD returns the AEAD sealing the chunks: that of params if any, or
+// AES-GCM with the key size of params.
+func streamAEAD(params *ECIESParams, z []byte) (cipher.AEAD, error) {
+	key, err := params.deriveKeys(z, nil, params.KeyLen)
+	if err != nil {
+		return nil, err
+	}
+	if params.AEAD != nil {
+		return params.AEAD(key)
+	}
+	return newAESGCM(key)
+}
+
+// streamNonce sets the counter and flag of nonce.
+func streamNonce(nonce []byte, counter uint32, flag byte) {
+	n := len(nonce) - streamSuffixSize
+	binary.BigEndian.PutUint32(nonce[n:], counter)
+	nonce[len(nonce)-1] = flag
+}
+
+// appendStreamIndex appends the index of a message of the given size and
+// hash to b.
+func appendStreamIndex(b []byte, size uint64, digest []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, streamChunkSize)
+	b = binary.BigEndian.AppendUint64(b, size)
+	return append(b, digest...)
+}
+
+// parseStreamIndex splits the index off the message of a last chunk,
+// checking it against the size of the message before.
+func parseStreamIndex(m []byte, before uint64) (data, digest []byte, err error) {
+	if len(m) < streamIndexSize {
+		return nil, nil, ErrInvalidMessage
+	}
+	data, index := m[:len(m)-streamIndexSize], m[len(m)-streamIndexSize:]
+	if binary.BigEndian.Uint32(index) != streamChunkSize ||
+		binary.BigEndian.Uint64(index[4:]) != before+uint64(len(data)) {
+		return nil, nil, ErrInvalidMessage
+	}
+	return data, index[12:], nil
+}
+
+// EncryptingWriter encrypts a stream, as returned by
+// NewEncryptingWriterWithOptions.
+type EncryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	out     []byte
+	err     error
+
+	digest hash.Hash // of the sealed chunks, if indexed
+	size   uint64    // of the sealed chunks
+
+	// Kept for checkpoints, if resumable.
+	header []byte
+	secret []byte
+}
+
+// NewEncryptingWriter returns a writer encrypting everything written to it
+// for pub, and writing the ciphertext to w. The ciphertext is only complete,
+// and decryptable, once the writer is closed.
+func NewEncryptingWriter(pub *PublicKey, w io.Writer) (io.WriteCloser, error) {
+	return NewEncryptingWriterWithOptions(pub, w, nil)
+}
+
+// NewEncryptingWriterWithOptions is NewEncryptingWriter with options. If
+// opts is nil, the default options are used.
+func NewEncryptingWriterWithOptions(pub *PublicKey, w io.Writer, opts *StreamOptions) (*EncryptingWriter, error) {
+	params, z, header, err := startStream(pub)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(z)
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	s, err := continueStream(params, z, header, w, 0)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Index {
+		s.digest = sha256.New()
+	}
+	if opts != nil && opts.Resumable {
+		s.header, s.secret = header, append([]byte{}, z...)
+	}
+	return s, nil
+}
+
+// startStream runs the key agreement of a new stream for pub, returning its
+// parameters, the shared secret and the stream header: the ephemeral public
+// key and the nonce prefix.
+func startStream(pub *PublicKey) (params *ECIESParams, z, header []byte, err error) {
+	params = pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, nil, nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err = enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, nil, nil, err
+	}
+	if z, header, err = encapsulate(entropy(nil), pub, params, false); err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := streamAEAD(params, z)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	prefix := make([]byte, aead.NonceSize()-streamSuffixSize)
+	if _, err = io.ReadFull(entropy(nil), prefix); err != nil {
+		return nil, nil, nil, err
+	}
+	return params, z, append(header, prefix...), nil
+}
+
+// continueStream returns a writer sealing the chunks of the stream with the
+// given shared secret and header from the given chunk on. The header must
+// have been written already.
+func continueStream(params *ECIESParams, z, header []byte, w io.Writer, counter uint64) (*EncryptingWriter, error) {
+	aead, err := streamAEAD(params, z)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if len(header) < len(nonce) {
+		return nil, ErrInvalidMessage
+	}
+	copy(nonce, header[len(header)-len(nonce)+streamSuffixSize:])
+	return &EncryptingWriter{
+		w:       w,
+		aead:    aead,
+		nonce:   nonce,
+		counter: counter,
+		buf:     make([]byte, 0, streamChunkSize+streamIndexSize),
+		out:     make([]byte, 0, streamChunkSize+streamIndexSize+aead.Overhead()),
+	}, nil
+}
+
+// Write buffers p, sealing and writing every complete chunk but the last.
+func (s *EncryptingWriter) Write(p []byte) (n int, err error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	for len(p) > 0 {
+		// A full chunk is only written once more data comes, as the
+		// last chunk must be flagged so.
+		if len(s.buf) == streamChunkSize {
+			if err = s.flush(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(s.buf[len(s.buf):streamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Close seals and writes the last chunk. It does not close the underlying
+// writer.
+func (s *EncryptingWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.flush(true); err != nil {
+		return err
+	}
+	s.err = ErrStreamClosed
+	wipe(s.secret)
+	return nil
+}
+
+func (s *EncryptingWriter) flush(last bool) error {
+	if s.counter > 1<<32-1 {
+		s.err = ErrStreamTooLarge
+		return s.err
+	}
+	if s.digest != nil {
+		s.digest.Write(s.buf)
+		s.size += uint64(len(s.buf))
+	}
+	flag := byte(streamChunk)
+	if last && s.digest != nil {
+		flag = streamLastIndexed
+		s.buf = appendStreamIndex(s.buf, s.size, s.digest.Sum(nil))
+	} else if last {
+		flag = streamLast
+	}
+	streamNonce(s.nonce, uint32(s.counter), flag)
+	s.out = s.aead.Seal(s.out[:0], s.nonce, s.buf, nil)
+	if _, err := s.w.Write(s.out); err != nil {
+		s.err = err
+		return err
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	size    uint64
+	digest  hash.Hash // of the chunks read, for the index
+	in      []byte
+	buf     []byte
+	done    bool
+	err     error
+}
+
+// NewDecryptingReader returns a reader decrypting the output of a writer
+// returned by NewEncryptingWriter, read from r. Each chunk is authenticated
+// before any of its data is returned; a stream which is truncated or
+// otherwise tampered with results in ErrInvalidMessage. The index of the
+// stream, if any, is checked against the message.
+func NewDecryptingReader(prv KeyProvider, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, streamReadCapacity)
+	aead, nonce, err := openStream(prv, br)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:      br,
+		aead:   aead,
+		nonce:  nonce,
+		digest: sha256.New(),
+		in:     make([]byte, streamChunkSize+streamIndexSize+aead.Overhead()),
+	}, nil
+}
+
+// openStream reads the header of a stream, returning the AEAD sealing its
+// chunks and a nonce starting with the nonce prefix.
+func openStream(prv KeyProvider, r io.Reader) (cipher.AEAD, []byte, error) {
+	pub := prv.Public()
+	params := pub.Params
+	if params == nil {
+		if params = ParamsFromCurve(pub.Curve); params == nil {
+			return nil, nil, ErrUnsupportedECIESParameters
+		}
+	}
+	if err := enforceParams(nil, pub.Curve, params); err != nil {
+		return nil, nil, err
+	}
+	R, err := readStreamKey(r, pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := prv.GenerateShared(R)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := streamAEAD(params, z)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(r, nonce[:len(nonce)-streamSuffixSize]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+	return aead, nonce, nil
+}
+
+// readStreamKey reads the ephemeral public key starting a stream.
+func readStreamKey(r io.Reader, pub *PublicKey) (*PublicKey, error) {
+	var format [1]byte
+	if _, err := io.ReadFull(r, format[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	size := pointSize(pub.Curve, format[0], AllowCompressedPoints)
+	if size == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	point := make([]byte, size)
+	point[0] = format[0]
+	if _, err := io.ReadFull(r, point[1:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return parseEncapsulation(pub, point, AllowCompressedPoints)
+}
+
+// unexpectedEOF reports a stream ending before its first chunk.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (s *decryptingReader) Read(p []byte) (n int, err error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n = copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// next reads, authenticates and decrypts the next chunk. As chunks aren't
+// delimited, the end of the stream is looked for past the next chunk: the
+// remainder is then either a last chunk, with or without an index, or a
+// full chunk followed by a last one.
+func (s *decryptingReader) next() error {
+	if s.counter > 1<<32-1 {
+		return ErrStreamTooLarge
+	}
+	encChunkSize := streamChunkSize + s.aead.Overhead()
+	ahead, err := s.r.Peek(encChunkSize + streamIndexSize + 1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	n := len(ahead)
+	if n <= encChunkSize+streamIndexSize && s.open(ahead, streamLastIndexed) == nil {
+		data, digest, err := parseStreamIndex(s.buf, s.size)
+		if err != nil {
+			return err
+		}
+		s.digest.Write(data)
+		if subtle.ConstantTimeCompare(s.digest.Sum(nil), digest) != 1 {
+			return ErrInvalidMessage
+		}
+		s.buf = data
+		return s.advance(n, true)
+	}
+	if n <= encChunkSize {
+		if err = s.open(ahead, streamLast); err != nil {
+			return err
+		}
+		return s.advance(n, true)
+	}
+	if err = s.open(ahead[:encChunkSize], streamChunk); err != nil {
+		return err
+	}
+	s.digest.Write(s.buf)
+	return s.advance(encChunkSize, false)
+}
+
+// open authenticates and decrypts the chunk c, with the given flag, into
+// buf. c is left as is, for another attempt.
+func (s *decryptingReader) open(c []byte, flag byte) (err error) {
+	streamNonce(s.nonce, uint32(s.counter), flag)
+	n := copy(s.in, c)
+	if s.buf, err = s.aead.Open(s.in[:0], s.nonce, s.in[:n], nil); err != nil {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// advance consumes the n bytes of the chunk opened.
+func (s *decryptingReader) advance(n int, last bool) error {
+	if _, err := s.r.Discard(n); err != nil {
+		return err
+	}
+	s.counter++
+	s.size += uint64(len(s.buf))
+	s.done = last
+	return nil
+}
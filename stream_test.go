@@ -0,0 +1,235 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func encryptStream(pub *PublicKey, message []byte, opts *StreamOptions) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := NewEncryptingWriterWithOptions(pub, &out, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Write in odd-sized pieces, across chunk boundaries.
+	for len(message) > 0 {
+		n := 1000
+		if n > len(message) {
+			n = len(message)
+		}
+		if _, err = w.Write(message[:n]); err != nil {
+			return nil, err
+		}
+		message = message[n:]
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Ensure streams of various sizes round trip, including empty ones and
+// multiples of the chunk size.
+func TestStream(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3 * streamChunkSize} {
+		message := make([]byte, size)
+		rand.Read(message)
+		ct, err := encryptStream(&prv.PublicKey, message, nil)
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		chunks := (size + streamChunkSize - 1) / streamChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		if len(ct) != 65+7+size+16*chunks {
+			fmt.Println(size, "ecies: unexpected stream size", len(ct))
+			t.FailNow()
+		}
+		r, err := NewDecryptingReader(prv, bytes.NewReader(ct))
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		pt, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(size, "ecies: stream not decrypted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure indexed streams round trip, and that their index gives the size
+// and hash of the message to seekable decrypters.
+func TestStreamIndex(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 2*streamChunkSize - 10} {
+		message := make([]byte, size)
+		rand.Read(message)
+		ct, err := encryptStream(&prv.PublicKey, message, &StreamOptions{Index: true})
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		chunks := max((size+streamChunkSize-1)/streamChunkSize, 1)
+		if len(ct) != 65+7+size+16*chunks+streamIndexSize {
+			fmt.Println(size, "ecies: unexpected indexed stream size", len(ct))
+			t.FailNow()
+		}
+		r, err := NewDecryptingReader(prv, bytes.NewReader(ct))
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		if pt, err := io.ReadAll(r); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(size, "ecies: indexed stream not decrypted", err)
+			t.FailNow()
+		}
+		d, err := NewSeekableDecrypter(prv, bytes.NewReader(ct), int64(len(ct)))
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		sum := sha256.Sum256(message)
+		if d.Size() != int64(size) || d.Chunks() != int64(chunks) || !bytes.Equal(d.Digest(), sum[:]) {
+			fmt.Println(size, "ecies: unexpected index", d.Size(), d.Chunks(), d.Digest())
+			t.FailNow()
+		}
+		if pt, err := io.ReadAll(d); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println(size, "ecies: indexed stream not read", err)
+			t.FailNow()
+		}
+
+		tampered := append([]byte{}, ct...)
+		tampered[len(tampered)-20] ^= 1
+		r, _ = NewDecryptingReader(prv, bytes.NewReader(tampered))
+		if _, err = io.ReadAll(r); err != ErrInvalidMessage {
+			fmt.Println(size, "ecies: tampered index accepted", err)
+			t.FailNow()
+		}
+		if _, err = NewSeekableDecrypter(prv, bytes.NewReader(tampered), int64(len(tampered))); err != ErrInvalidMessage {
+			fmt.Println(size, "ecies: tampered index accepted", err)
+			t.FailNow()
+		}
+	}
+
+	ct, err := encryptStream(&prv.PublicKey, []byte("Hello, world."), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if d, err := NewSeekableDecrypter(prv, bytes.NewReader(ct), int64(len(ct))); err != nil || d.Digest() != nil {
+		fmt.Println("ecies: digest of a stream without index", err)
+		t.FailNow()
+	}
+}
+
+// Ensure an index with the hash of another message, which the sender can
+// seal, is rejected once the whole message is read.
+func TestStreamForgedDigest(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, size := range []int{0, 1, streamChunkSize, 2*streamChunkSize - 10} {
+		message := make([]byte, size)
+		rand.Read(message)
+		var out bytes.Buffer
+		w, err := NewEncryptingWriterWithOptions(&prv.PublicKey, &out, &StreamOptions{Index: true})
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		w.digest.Write([]byte("forged"))
+		if _, err = w.Write(message); err != nil || w.Close() != nil {
+			fmt.Println(size, "ecies: stream not encrypted", err)
+			t.FailNow()
+		}
+		ct := out.Bytes()
+
+		r, err := NewDecryptingReader(prv, bytes.NewReader(ct))
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		if _, err = io.ReadAll(r); err != ErrInvalidMessage {
+			fmt.Println(size, "ecies: forged digest accepted", err)
+			t.FailNow()
+		}
+		d, err := NewSeekableDecrypter(prv, bytes.NewReader(ct), int64(len(ct)))
+		if err != nil {
+			fmt.Println(size, err.Error())
+			t.FailNow()
+		}
+		if _, err = io.ReadAll(d); err != ErrInvalidMessage {
+			fmt.Println(size, "ecies: forged digest read", err)
+			t.FailNow()
+		}
+		// Ranges can still be read, as the digest isn't known to be wrong
+		// without the whole message.
+		if pt, err := d.DecryptChunk(0); err != nil || !bytes.Equal(pt, message[:min(size, streamChunkSize)]) {
+			fmt.Println(size, "ecies: chunk not decrypted", err)
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure truncated, reordered and tampered streams are rejected.
+func TestStreamTampering(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := make([]byte, 2*streamChunkSize+100)
+	ct, err := encryptStream(&prv.PublicKey, message, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	header := 65 + 7
+	chunk := streamChunkSize + 16
+	swapped := append([]byte{}, ct[:header]...)
+	swapped = append(swapped, ct[header+chunk:header+2*chunk]...)
+	swapped = append(swapped, ct[header:header+chunk]...)
+	swapped = append(swapped, ct[header+2*chunk:]...)
+	tampered := append([]byte{}, ct...)
+	tampered[header+chunk+10] ^= 1
+
+	for name, c := range map[string][]byte{
+		"truncated at a chunk boundary": ct[:header+2*chunk],
+		"truncated in a chunk":          ct[:len(ct)-1],
+		"without chunks":                ct[:header],
+		"reordered":                     swapped,
+		"tampered":                      tampered,
+	} {
+		r, err := NewDecryptingReader(prv, bytes.NewReader(c))
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		if _, err = io.ReadAll(r); err != ErrInvalidMessage {
+			fmt.Println("ecies: stream", name, "accepted", err)
+			t.FailNow()
+		}
+	}
+	if _, err = NewDecryptingReader(prv, bytes.NewReader(ct[:40])); err != io.ErrUnexpectedEOF {
+		fmt.Println("ecies: truncated stream header accepted", err)
+		t.FailNow()
+	}
+}
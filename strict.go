@@ -0,0 +1,142 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// Strict parsing of keys, for encodings from untrusted sources: the size of
+// the input is bounded, trailing data is rejected, every field is validated,
+// and parameters which can't be decoded in full are rejected rather than
+// replaced by the default ones of the curve.
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+)
+
+// MaxEncodedKeySize bounds the size of the DER and PEM encodings accepted by
+// the strict parsers. Keys with their parameters take a few hundred bytes.
+const MaxEncodedKeySize = 16 << 10
+
+var (
+	errEncodingTooLarge = errors.New("encoding too large")
+	errTrailingPEM      = errors.New("trailing data after PEM block")
+)
+
+// hasAlgorithms reports whether the supplements of a key carry any ECIES
+// parameters.
+func (algs eccAlgorithmSet) hasAlgorithms() bool {
+	return len(algs.ECDH.Algorithm) != 0 || len(algs.ECIES.KDF.Algorithm) != 0 ||
+		len(algs.ECIES.Sym.Algorithm) != 0 || len(algs.ECIES.MAC.Algorithm) != 0
+}
+
+func checkEncodingSize(sentinel error, in []byte) error {
+	if len(in) > MaxEncodedKeySize {
+		return refineError(sentinel, KindEncoding, errEncodingTooLarge)
+	}
+	return nil
+}
+
+// UnmarshalPrivateStrict decodes a private key like UnmarshalPrivate, but
+// rejects inputs over MaxEncodedKeySize, trailing data, parameters which
+// can't be decoded, points in the hybrid format, and scalars out of range or
+// not matching the public key. Keys without a public key are accepted, as in
+// UnmarshalPrivate.
+func UnmarshalPrivateStrict(in []byte) (*PrivateKey, error) {
+	if err := checkEncodingSize(ErrInvalidPrivateKey, in); err != nil {
+		return nil, err
+	}
+	var ecprv asnPrivateKey
+	rest, err := asn1.Unmarshal(in, &ecprv)
+	if err != nil || ecprv.Curve == nil {
+		// SEC 1 keys, whose tagged fields are skipped here, are parsed as
+		// strictly.
+		if prv, err2 := UnmarshalPrivateSEC1(in); err2 == nil {
+			return prv, nil
+		}
+	}
+	if err != nil || len(rest) != 0 {
+		return nil, asn1Error(ErrInvalidPrivateKey, err)
+	}
+	if ecprv.Version != asnECPrivKeyVer1 {
+		return nil, ErrInvalidPrivateKey
+	}
+	curve := namedCurveFromOID(ecprv.Curve)
+	if curve == nil {
+		return nil, ErrInvalidCurve
+	}
+	if ecprv.Public.BitLength != len(ecprv.Public.Bytes)*8 {
+		return nil, ErrInvalidPrivateKey
+	}
+	prv, err := privateFromScalar(curve, ecprv.Private)
+	if err != nil || len(ecprv.Public.Bytes) == 0 {
+		return prv, err
+	}
+	pub, err := unmarshalPublic(ecprv.Public.Bytes, AllowCompressedPoints, true)
+	if err != nil {
+		return nil, err
+	}
+	if pub.Curve != curve {
+		return nil, ErrInvalidCurve
+	}
+	if err = checkKeyPair(prv, pub); err != nil {
+		return nil, err
+	}
+	prv.PublicKey.Params = pub.Params
+	return prv, nil
+}
+
+// strictPEM decodes the single PEM block of in, which may only be followed
+// by white space.
+func strictPEM(sentinel error, in []byte) (*pem.Block, error) {
+	if err := checkEncodingSize(sentinel, in); err != nil {
+		return nil, err
+	}
+	p, rest := pem.Decode(in)
+	if p == nil {
+		return nil, sentinel
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, refineError(sentinel, KindEncoding, errTrailingPEM)
+	}
+	return p, nil
+}
+
+// ImportPublicPEMStrict imports a PEM-encoded public key like
+// ImportPublicPEM, but requires in to hold a single "ELLIPTIC CURVE PUBLIC
+// KEY" or "PUBLIC KEY" block, parsed as UnmarshalPublicStrict does.
+func ImportPublicPEMStrict(in []byte) (*PublicKey, error) {
+	p, err := strictPEM(ErrInvalidPublicKey, in)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Type {
+	case "ELLIPTIC CURVE PUBLIC KEY":
+		return UnmarshalPublicStrict(p.Bytes)
+	case "PUBLIC KEY":
+		return unmarshalPublicPKIX(p.Bytes, AllowCompressedPoints)
+	}
+	return nil, ErrInvalidPublicKey
+}
+
+// ImportPrivatePEMStrict imports a PEM-encoded private key like
+// ImportPrivatePEM, but requires in to hold a single unencrypted private key
+// block, parsed as UnmarshalPrivateStrict does for the format of
+// ExportPrivatePEM.
+func ImportPrivatePEMStrict(in []byte) (*PrivateKey, error) {
+	p, err := strictPEM(ErrInvalidPrivateKey, in)
+	if err != nil {
+		return nil, err
+	}
+	if !isPrivatePEMType(p.Type) {
+		return nil, ErrInvalidPrivateKey
+	}
+	if isLegacyEncrypted(p) || p.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, ErrEncryptedKey
+	}
+	if p.Type == "ELLIPTIC CURVE PRIVATE KEY" {
+		return UnmarshalPrivateStrict(p.Bytes)
+	}
+	return parsePrivatePEMBlock(p)
+}
@@ -0,0 +1,207 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Ensure that the strict parsers reject what the lenient ones let through.
+func TestStrictParsing(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	other, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pubDER, err := MarshalPublic(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prvDER, err := MarshalPrivate(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pub, err := UnmarshalPublicStrict(pubDER); err != nil || !bytes.Equal(marshalPoint(pub.Curve, pub.X, pub.Y), marshalPoint(prv.Curve, prv.X, prv.Y)) {
+		fmt.Println("ecies: strict public key not decoded", err)
+		t.FailNow()
+	}
+	if key, err := UnmarshalPrivateStrict(prvDER); err != nil || key.D.Cmp(prv.D) != 0 || !key.Params.complete() {
+		fmt.Println("ecies: strict private key not decoded", err)
+		t.FailNow()
+	}
+
+	subj, err := marshalSubjectPublicKeyInfo(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	subj.Supplements.ECCAlgorithms.ECIES.KDF.Algorithm = asn1.ObjectIdentifier{1, 2, 3, 4}
+	unknownParams, err := asn1.Marshal(subj)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ecprv, err := marshalPrivateKey(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ecprv.Private = other.D.Bytes()
+	mismatched, err := asn1.Marshal(ecprv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+
+	for _, c := range []struct {
+		Name     string
+		Input    []byte
+		Expected error
+	}{
+		{"trailing data", append(append([]byte{}, pubDER...), 0), ErrInvalidPublicKey},
+		{"too large", append(append([]byte{}, pubDER...), make([]byte, MaxEncodedKeySize)...), ErrInvalidPublicKey},
+		{"unknown parameters", unknownParams, ErrUnsupportedECIESParameters},
+	} {
+		if _, err := UnmarshalPublicStrict(c.Input); !errors.Is(err, c.Expected) {
+			fmt.Println("ecies: strict public key accepted with", c.Name, err)
+			t.FailNow()
+		}
+	}
+	// The lenient parser falls back to the default parameters.
+	if pub, err := UnmarshalPublic(unknownParams); err != nil || pub.Params != ParamsFromCurve(pub.Curve) {
+		fmt.Println("ecies: unknown parameters not replaced", err)
+		t.FailNow()
+	}
+
+	for _, c := range []struct {
+		Name     string
+		Input    []byte
+		Expected error
+	}{
+		{"trailing data", append(append([]byte{}, prvDER...), 0), ErrInvalidPrivateKey},
+		{"too large", append(append([]byte{}, prvDER...), make([]byte, MaxEncodedKeySize)...), ErrInvalidPrivateKey},
+		{"mismatched scalar", mismatched, ErrInvalidPrivateKey},
+	} {
+		if _, err := UnmarshalPrivateStrict(c.Input); !errors.Is(err, c.Expected) {
+			fmt.Println("ecies: strict private key accepted with", c.Name, err)
+			t.FailNow()
+		}
+	}
+
+	pubPEM, err := ExportPublicPEM(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	prvPEM, err := ExportPrivatePEM(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = ImportPublicPEMStrict(append(append([]byte{}, pubPEM...), "\n\n"...)); err != nil {
+		fmt.Println("ecies: strict PEM public key not imported", err)
+		t.FailNow()
+	}
+	if _, err = ImportPrivatePEMStrict(prvPEM); err != nil {
+		fmt.Println("ecies: strict PEM private key not imported", err)
+		t.FailNow()
+	}
+	if _, err = ImportPublicPEMStrict(append(append([]byte{}, pubPEM...), pubPEM...)); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: strict PEM accepted two blocks", err)
+		t.FailNow()
+	}
+	if _, err = ImportPrivatePEMStrict(append(append([]byte{}, prvPEM...), "garbage"...)); !errors.Is(err, ErrInvalidPrivateKey) {
+		fmt.Println("ecies: strict PEM accepted trailing data", err)
+		t.FailNow()
+	}
+}
+
+// FuzzParseKeys checks that the key parsers don't panic, that the keys they
+// return are usable, and that the strict parsers only accept what the
+// lenient ones do. The seed corpus runs as a regression test.
+func FuzzParseKeys(f *testing.F) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), X25519()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			f.Fatal(err)
+		}
+		bare := prv.PublicKey
+		bare.Params = nil
+		for _, marshal := range []func() ([]byte, error){
+			func() ([]byte, error) { return MarshalPublic(&prv.PublicKey) },
+			func() ([]byte, error) { return MarshalPublic(&bare) },
+			func() ([]byte, error) { return MarshalPublicPKIX(&prv.PublicKey) },
+			func() ([]byte, error) { return MarshalPrivate(prv) },
+			func() ([]byte, error) { return MarshalPrivateSEC1(prv) },
+			func() ([]byte, error) { return MarshalPrivatePKCS8(prv) },
+			func() ([]byte, error) { return ExportPublicPEM(&prv.PublicKey) },
+			func() ([]byte, error) { return ExportPrivatePEM(prv) },
+		} {
+			if der, err := marshal(); err == nil {
+				f.Add(der)
+			}
+		}
+	}
+	f.Add([]byte{0x30, 0x00})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var pubs []*PublicKey
+		var prvs []*PrivateKey
+		lenientPub, lenientErr := UnmarshalPublic(in)
+		if lenientErr == nil {
+			pubs = append(pubs, lenientPub)
+		}
+		if pub, err := UnmarshalPublicStrict(in); err == nil {
+			if lenientErr != nil {
+				t.Fatal("strict parser accepted a public key the lenient one rejects")
+			}
+			pubs = append(pubs, pub)
+		}
+		if pub, err := UnmarshalPublicPKIX(in); err == nil {
+			pubs = append(pubs, pub)
+		}
+		if pub, err := ImportPublicPEM(in); err == nil {
+			pubs = append(pubs, pub)
+		}
+		if pub, err := ImportPublicPEMStrict(in); err == nil {
+			pubs = append(pubs, pub)
+		}
+		lenientPrv, lenientErr := UnmarshalPrivate(in)
+		if lenientErr == nil {
+			prvs = append(prvs, lenientPrv)
+		}
+		if prv, err := UnmarshalPrivateStrict(in); err == nil {
+			if lenientErr != nil {
+				t.Fatal("strict parser accepted a private key the lenient one rejects")
+			}
+			prvs = append(prvs, prv)
+		}
+		for _, parse := range []func([]byte) (*PrivateKey, error){UnmarshalPrivateSEC1, UnmarshalPrivatePKCS8, ImportPrivatePEM, ImportPrivatePEMStrict} {
+			if prv, err := parse(in); err == nil {
+				prvs = append(prvs, prv)
+			}
+		}
+
+		for _, pub := range pubs {
+			Encrypt(rand.Reader, pub, []byte("Hello, world."), nil, nil)
+		}
+		for _, prv := range prvs {
+			prv.GenerateShared(&prv.PublicKey)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package ecies
+
+// Names of the standard parameter sets, for configuration files and logs:
+// the suite names of SupportedSuites, such as "AES-128-CTR/HMAC-SHA-256".
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParamsFromName returns the standard parameters with the given suite name,
+// compared without regard to case. It returns ErrUnsupportedECIESParameters
+// for unknown names.
+func ParamsFromName(name string) (*ECIESParams, error) {
+	for _, s := range suiteIDs {
+		if strings.EqualFold(s.name, name) {
+			return s.params, nil
+		}
+	}
+	return nil, wrapError(ErrUnsupportedECIESParameters, fmt.Errorf("unknown suite %q", name))
+}
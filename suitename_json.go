@@ -0,0 +1,34 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+// JSON encoding of the parameters as their suite names, which the ecies_tiny
+// profile leaves out with encoding/json.
+
+import "encoding/json"
+
+// MarshalJSON encodes the parameters as their suite name. Parameters which
+// aren't a standard suite, such as those with a custom KDF, can't be encoded.
+func (params *ECIESParams) MarshalJSON() ([]byte, error) {
+	name, ok := suiteName(params)
+	if !ok {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON decodes parameters encoded by MarshalJSON, or any suite name
+// known to ParamsFromName.
+func (params *ECIESParams) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return refineError(ErrUnsupportedECIESParameters, KindEncoding, err)
+	}
+	std, err := ParamsFromName(name)
+	if err != nil {
+		return err
+	}
+	*params = *std
+	return nil
+}
@@ -0,0 +1,65 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+)
+
+// Ensure that the standard suites are found by name, and round trip through
+// encoding/json.
+func TestParamsFromName(t *testing.T) {
+	type config struct {
+		Params *ECIESParams
+	}
+	for _, s := range SupportedSuites() {
+		params, err := ParamsFromName(strings.ToLower(s.Name))
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if params != s.Params {
+			fmt.Println("ecies: wrong parameters for", s.Name)
+			t.FailNow()
+		}
+
+		data, err := json.Marshal(config{params})
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		} else if string(data) != `{"Params":"`+s.Name+`"}` {
+			fmt.Println("ecies: unexpected JSON", string(data))
+			t.FailNow()
+		}
+		var decoded config
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if id, ok := suiteID(decoded.Params); !ok || id != s.ID {
+			fmt.Println("ecies: parameters lost in JSON for", s.Name)
+			t.FailNow()
+		}
+	}
+
+	if _, err := ParamsFromName("ECIES-NOPE"); !errors.Is(err, ErrUnsupportedECIESParameters) {
+		fmt.Println("ecies: found an unknown suite:", err)
+		t.FailNow()
+	}
+	var decoded config
+	if err := json.Unmarshal([]byte(`{"Params":1}`), &decoded); !errors.Is(err, KindEncoding) {
+		fmt.Println("ecies: decoded parameters from a number:", err)
+		t.FailNow()
+	}
+	custom := *ECIES_AES128_SHA256
+	custom.KDF = func(h hash.Hash, z, info []byte, length int) ([]byte, error) { return nil, nil }
+	if _, err := json.Marshal(config{&custom}); !errors.Is(err, ErrUnsupportedECIESParameters) {
+		fmt.Println("ecies: encoded custom parameters:", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,219 @@
+// Package tpm implements an ecies.KeyProvider for ECC keys held in a TPM 2.0,
+// using the TPM2_ECDH_ZGen command, so that device payloads encrypted with
+// ecies.Encrypt can be decrypted with a key which never leaves the TPM.
+package tpm
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/foundriesio/go-ecies"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+var (
+	ErrNotDecryptionKey = fmt.Errorf("tpm: not an unrestricted ECC decryption key")
+	ErrKeyNotFound      = fmt.Errorf("tpm: key not found")
+	ErrAuthFailed       = fmt.Errorf("tpm: authorization failed")
+	ErrPolicyFailed     = fmt.Errorf("tpm: policy check failed")
+	ErrClosed           = fmt.Errorf("tpm: key provider closed")
+)
+
+// AuthFunc returns the authorization session for a use of the key, along
+// with a function closing it.
+type AuthFunc func(t transport.TPM) (s tpm2.Session, close func() error, err error)
+
+// PasswordAuth authorizes the uses of the key with its auth value.
+func PasswordAuth(password []byte) AuthFunc {
+	return func(transport.TPM) (tpm2.Session, func() error, error) {
+		return tpm2.PasswordAuth(password), func() error { return nil }, nil
+	}
+}
+
+// PolicyAuth authorizes the uses of the key with a SHA-256 policy session,
+// which policy runs the policy commands on, e.g. TPM2_PolicyPCR to bind the
+// key to the boot state of the device. A new session is started for each
+// use, as policy sessions are consumed.
+func PolicyAuth(policy func(t transport.TPM, s tpm2.Session) error, opts ...tpm2.AuthOption) AuthFunc {
+	return func(t transport.TPM) (tpm2.Session, func() error, error) {
+		s, closer, err := tpm2.PolicySession(t, tpm2.TPMAlgSHA256, 16, opts...)
+		if err != nil {
+			return nil, nil, mapError(err)
+		}
+		if err = policy(t, s); err != nil {
+			closer()
+			return nil, nil, mapError(err)
+		}
+		return s, closer, nil
+	}
+}
+
+// Key is a KeyProvider for an ECC key in a TPM. The commands are sent one at
+// a time, so it is safe for concurrent use.
+type Key struct {
+	tpm       transport.TPM
+	handle    tpm2.NamedHandle
+	auth      AuthFunc
+	transient bool
+	pub       *ecies.PublicKey
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Open returns the key at a persistent handle, e.g. 0x81000002. If auth is
+// nil, the uses of the key are authorized with an empty password.
+func Open(t transport.TPM, handle tpm2.TPMHandle, auth AuthFunc) (*Key, error) {
+	rsp, err := tpm2.ReadPublic{ObjectHandle: handle}.Execute(t)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return newKey(t, tpm2.NamedHandle{Handle: handle, Name: rsp.Name}, rsp.OutPublic, auth, false)
+}
+
+// Load loads a key created under parent, from the public and private areas
+// returned by TPM2_Create, e.g. as sealed on the device storage. The key is
+// flushed from the TPM by Close.
+func Load(t transport.TPM, parent tpm2.AuthHandle, public tpm2.TPM2BPublic, private tpm2.TPM2BPrivate, auth AuthFunc) (*Key, error) {
+	rsp, err := tpm2.Load{ParentHandle: parent, InPrivate: private, InPublic: public}.Execute(t)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	k, err := newKey(t, tpm2.NamedHandle{Handle: rsp.ObjectHandle, Name: rsp.Name}, public, auth, true)
+	if err != nil {
+		tpm2.FlushContext{FlushHandle: rsp.ObjectHandle}.Execute(t)
+		return nil, err
+	}
+	return k, nil
+}
+
+func newKey(t transport.TPM, handle tpm2.NamedHandle, public tpm2.TPM2BPublic, auth AuthFunc, transient bool) (*Key, error) {
+	pub, err := publicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		auth = PasswordAuth(nil)
+	}
+	return &Key{tpm: t, handle: handle, auth: auth, transient: transient, pub: pub}, nil
+}
+
+// publicKey returns the public key of the public area of an unrestricted ECC
+// decryption key.
+func publicKey(public tpm2.TPM2BPublic) (*ecies.PublicKey, error) {
+	area, err := public.Contents()
+	if err != nil || area.Type != tpm2.TPMAlgECC {
+		return nil, ErrNotDecryptionKey
+	}
+	if !area.ObjectAttributes.Decrypt || area.ObjectAttributes.Restricted {
+		return nil, ErrNotDecryptionKey
+	}
+	params, err := area.Parameters.ECCDetail()
+	if err != nil {
+		return nil, ErrNotDecryptionKey
+	}
+	curve := curveFromID(params.CurveID)
+	if curve == nil {
+		return nil, ecies.ErrInvalidCurve
+	}
+	point, err := area.Unique.ECC()
+	if err != nil {
+		return nil, ErrNotDecryptionKey
+	}
+	x := new(big.Int).SetBytes(point.X.Buffer)
+	y := new(big.Int).SetBytes(point.Y.Buffer)
+	return ecies.NewPublicKey(curve, x, y)
+}
+
+func curveFromID(id tpm2.TPMECCCurve) elliptic.Curve {
+	switch id {
+	case tpm2.TPMECCNistP256:
+		return elliptic.P256()
+	case tpm2.TPMECCNistP384:
+		return elliptic.P384()
+	case tpm2.TPMECCNistP521:
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// Public returns the public key of the TPM key.
+func (k *Key) Public() *ecies.PublicKey {
+	return k.pub
+}
+
+// GenerateShared computes the shared secret with pub with TPM2_ECDH_ZGen.
+func (k *Key) GenerateShared(pub *ecies.PublicKey) ([]byte, error) {
+	if pub.Curve != k.pub.Curve {
+		return nil, ecies.ErrInvalidCurve
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil, ErrClosed
+	}
+	s, closeAuth, err := k.auth(k.tpm)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	in := tpm2.TPMSECCPoint{
+		X: tpm2.TPM2BECCParameter{Buffer: pub.X.FillBytes(make([]byte, size))},
+		Y: tpm2.TPM2BECCParameter{Buffer: pub.Y.FillBytes(make([]byte, size))},
+	}
+	rsp, err := tpm2.ECDHZGen{
+		KeyHandle: tpm2.AuthHandle{Handle: k.handle.Handle, Name: k.handle.Name, Auth: s},
+		InPoint:   tpm2.New2B(in),
+	}.Execute(k.tpm)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	out, err := rsp.OutPoint.Contents()
+	if err != nil || len(out.X.Buffer) > size {
+		return nil, ecies.ErrSharedKeyIsPointAtInfinity
+	}
+	return new(big.Int).SetBytes(out.X.Buffer).FillBytes(make([]byte, size)), nil
+}
+
+// Close flushes a loaded key from the TPM. It does not close the TPM.
+func (k *Key) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil
+	}
+	k.closed = true
+	if !k.transient {
+		return nil
+	}
+	_, err := tpm2.FlushContext{FlushHandle: k.handle.Handle}.Execute(k.tpm)
+	return mapError(err)
+}
+
+// mapError maps the TPM response codes to the errors of this package and of
+// ecies, keeping the original code in the message. Other errors are returned
+// as is.
+func mapError(err error) error {
+	var base error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, tpm2.TPMRCAuthFail), errors.Is(err, tpm2.TPMRCBadAuth):
+		base = ErrAuthFailed
+	case errors.Is(err, tpm2.TPMRCPolicyFail):
+		base = ErrPolicyFailed
+	case errors.Is(err, tpm2.TPMRCHandle):
+		base = ErrKeyNotFound
+	case errors.Is(err, tpm2.TPMRCECCPoint):
+		base = ecies.ErrInvalidPublicKey
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", base, err)
+}
@@ -0,0 +1,160 @@
+package tpm
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/foundriesio/go-ecies"
+	"github.com/google/go-tpm/tpm2"
+)
+
+const keyHandle = tpm2.TPMHandle(0x81000002)
+
+// fakeTPM answers the TPM2_ReadPublic and TPM2_ECDH_ZGen commands for a
+// persistent key, authorized with a password.
+type fakeTPM struct {
+	prv      *ecies.PrivateKey
+	attrs    tpm2.TPMAObject
+	password []byte
+}
+
+func rsp(tag uint16, rc tpm2.TPMRC, body []byte) []byte {
+	out := binary.BigEndian.AppendUint16(nil, tag)
+	out = binary.BigEndian.AppendUint32(out, uint32(10+len(body)))
+	out = binary.BigEndian.AppendUint32(out, uint32(rc))
+	return append(out, body...)
+}
+
+func (f *fakeTPM) public() tpm2.TPM2BPublic {
+	return tpm2.New2B(tpm2.TPMTPublic{
+		Type:             tpm2.TPMAlgECC,
+		NameAlg:          tpm2.TPMAlgSHA256,
+		ObjectAttributes: f.attrs,
+		Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgECC, &tpm2.TPMSECCParms{
+			Symmetric: tpm2.TPMTSymDefObject{Algorithm: tpm2.TPMAlgNull},
+			Scheme:    tpm2.TPMTECCScheme{Scheme: tpm2.TPMAlgNull},
+			CurveID:   tpm2.TPMECCNistP256,
+			KDF:       tpm2.TPMTKDFScheme{Scheme: tpm2.TPMAlgNull},
+		}),
+		Unique: tpm2.NewTPMUPublicID(tpm2.TPMAlgECC, &tpm2.TPMSECCPoint{
+			X: tpm2.TPM2BECCParameter{Buffer: f.prv.X.FillBytes(make([]byte, 32))},
+			Y: tpm2.TPM2BECCParameter{Buffer: f.prv.Y.FillBytes(make([]byte, 32))},
+		}),
+	})
+}
+
+func (f *fakeTPM) Send(cmd []byte) ([]byte, error) {
+	cc := tpm2.TPMCC(binary.BigEndian.Uint32(cmd[6:]))
+	handle := tpm2.TPMHandle(binary.BigEndian.Uint32(cmd[10:]))
+	if handle != keyHandle {
+		return rsp(0x8001, tpm2.TPMRCHandle+0x100, nil), nil
+	}
+	switch cc {
+	case tpm2.TPMCCReadPublic:
+		name := tpm2.TPM2BName{Buffer: []byte{0, 0x0b, 1, 2, 3}}
+		body := tpm2.Marshal(f.public())
+		body = append(body, tpm2.Marshal(name)...)
+		body = append(body, tpm2.Marshal(name)...)
+		return rsp(0x8001, tpm2.TPMRCSuccess, body), nil
+	case tpm2.TPMCCECDHZGen:
+		// The password session: handle, nonce, attributes, then the
+		// password, followed by the point.
+		auth := cmd[18 : 18+binary.BigEndian.Uint32(cmd[14:])]
+		n := binary.BigEndian.Uint16(auth[4:])
+		password := auth[4+2+n+1+2:]
+		if !bytes.Equal(password, f.password) {
+			return rsp(0x8001, tpm2.TPMRCAuthFail+0x900, nil), nil
+		}
+		in, err := tpm2.Unmarshal[tpm2.TPM2BECCPoint](cmd[18+len(auth):])
+		if err != nil {
+			return nil, err
+		}
+		point, _ := in.Contents()
+		pub, err := ecies.NewPublicKeyFromBytes(elliptic.P256(), append(append([]byte{4}, point.X.Buffer...), point.Y.Buffer...))
+		if err != nil {
+			return rsp(0x8001, tpm2.TPMRCECCPoint+0x1c0, nil), nil
+		}
+		z, _ := f.prv.GenerateShared(pub)
+		// TPM2_ECDH_ZGen returns the whole point, of which only X is used.
+		params := tpm2.Marshal(tpm2.New2B(tpm2.TPMSECCPoint{
+			X: tpm2.TPM2BECCParameter{Buffer: z},
+			Y: tpm2.TPM2BECCParameter{Buffer: make([]byte, 32)},
+		}))
+		body := binary.BigEndian.AppendUint32(nil, uint32(len(params)))
+		body = append(body, params...)
+		body = append(body, 0, 0, 1, 0, 0)
+		return rsp(0x8002, tpm2.TPMRCSuccess, body), nil
+	}
+	return rsp(0x8001, tpm2.TPMRCCommandCode, nil), nil
+}
+
+// Ensure that messages are decrypted with a persistent TPM key, and that the
+// key and authorization errors are reported.
+func TestKey(t *testing.T) {
+	prv, err := ecies.GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	fake := &fakeTPM{
+		prv:      prv,
+		attrs:    tpm2.TPMAObject{FixedTPM: true, FixedParent: true, UserWithAuth: true, Decrypt: true},
+		password: []byte("secret"),
+	}
+	key, err := Open(fake, keyHandle, PasswordAuth([]byte("secret")))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if key.Public().X.Cmp(prv.X) != 0 || key.Public().Y.Cmp(prv.Y) != 0 {
+		fmt.Println("tpm: wrong public key")
+		t.FailNow()
+	}
+	ct, err := ecies.Encrypt(rand.Reader, key.Public(), []byte("payload"), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	m, err := ecies.Decrypt(key, ct, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	} else if string(m) != "payload" {
+		fmt.Println("tpm: plaintext doesn't match")
+		t.FailNow()
+	}
+
+	wrong, err := Open(fake, keyHandle, PasswordAuth([]byte("wrong")))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = ecies.Decrypt(wrong, ct, nil, nil); !errors.Is(err, ErrAuthFailed) {
+		fmt.Println("tpm: wrong password not reported", err)
+		t.FailNow()
+	}
+	if _, err = Open(fake, keyHandle+1, nil); !errors.Is(err, ErrKeyNotFound) {
+		fmt.Println("tpm: missing key not reported", err)
+		t.FailNow()
+	}
+
+	if err = key.Close(); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = key.GenerateShared(key.Public()); err != ErrClosed {
+		fmt.Println("tpm: key agreement after close", err)
+		t.FailNow()
+	}
+
+	fake.attrs.Decrypt, fake.attrs.SignEncrypt = false, true
+	if _, err = Open(fake, keyHandle, nil); err != ErrNotDecryptionKey {
+		fmt.Println("tpm: signing key accepted", err)
+		t.FailNow()
+	}
+}
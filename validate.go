@@ -0,0 +1,114 @@
+package ecies
+
+// Public key validation, SEC 1 section 3.2.2.1: the point is not the point
+// at infinity, its coordinates are field elements, it is on the curve, and
+// n*Q is the point at infinity. The last check is implied on the curves of
+// prime order, and replaced by the rejection of the points of small order on
+// X25519.
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+var (
+	errPointAtInfinity = errors.New("point at infinity")
+	errNotOnCurve      = errors.New("point not on the curve")
+	errSmallOrder      = errors.New("point of small order")
+)
+
+// x25519LowOrder holds the u-coordinates of the points of small order on
+// Curve25519 and its twist, reduced modulo p.
+var x25519LowOrder = func() []*big.Int {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	c1, _ := new(big.Int).SetString("325606250916557431795983626356110631294008115727848805560023387167927233504", 10)
+	c2, _ := new(big.Int).SetString("39382357235489614581723060781553021112529911719440698176882885853963445705823", 10)
+	return []*big.Int{big.NewInt(0), big.NewInt(1), c1, c2, new(big.Int).Sub(p, big.NewInt(1)), p}
+}()
+
+// ValidatePublicKey performs the full public key validation of SEC 1
+// section 3.2.2.1 on pub, so that invalid points, and points of small order
+// which would give the shared secret away, are rejected before any key
+// agreement. It returns ErrInvalidCurve for unsupported curves, and a
+// refinement of ErrInvalidPublicKey for invalid points.
+func ValidatePublicKey(pub *PublicKey) error {
+	if pub == nil || pub.Curve == nil {
+		return ErrInvalidCurve
+	}
+	if pub.X == nil || pub.Y == nil {
+		return ErrInvalidPublicKey
+	}
+	if pub.Curve == X25519() {
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return wrapError(ErrInvalidPublicKey, errNotOnCurve)
+		}
+		if x25519IsLowOrder(pub.X) {
+			return wrapError(ErrInvalidPublicKey, errSmallOrder)
+		}
+		return nil
+	}
+	if pub.Curve == X448() {
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return wrapError(ErrInvalidPublicKey, errNotOnCurve)
+		}
+		if x448IsLowOrder(pub.X) {
+			return wrapError(ErrInvalidPublicKey, errSmallOrder)
+		}
+		return nil
+	}
+	if pub.X.Sign() == 0 && pub.Y.Sign() == 0 {
+		return wrapError(ErrInvalidPublicKey, errPointAtInfinity)
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return wrapError(ErrInvalidPublicKey, errNotOnCurve)
+	}
+	if !primeOrderCurve(pub.Curve) {
+		x, _ := affineOrNil(pub.Curve.ScalarMult(pub.X, pub.Y, pub.Curve.Params().N.Bytes()))
+		if x != nil {
+			return wrapError(ErrInvalidPublicKey, errSmallOrder)
+		}
+	}
+	return nil
+}
+
+// primeOrderCurve reports whether the points of curve all have the order of
+// the base point, its cofactor being 1.
+func primeOrderCurve(curve elliptic.Curve) bool {
+	switch curve {
+	case elliptic.P224(), elliptic.P256(), elliptic.P384(), elliptic.P521(), Secp256k1(), SM2():
+		return true
+	}
+	return false
+}
+
+// x25519IsLowOrder reports whether the X25519 public key x, its encoding
+// read as a big-endian integer, is a point of small order.
+func x25519IsLowOrder(x *big.Int) bool {
+	b := x.FillBytes(make([]byte, x25519KeySize))
+	// The encoding is little-endian, and its top bit is ignored.
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	b[0] &= 0x7f
+	u := new(big.Int).SetBytes(b)
+	for _, low := range x25519LowOrder {
+		if u.Cmp(low) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// x448IsLowOrder reports whether the X448 public key x, its encoding read as
+// a big-endian integer, is a point of small order: u = 0, 1 or -1.
+func x448IsLowOrder(x *big.Int) bool {
+	b := x.FillBytes(make([]byte, x448KeySize))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	p := X448().Params().P
+	u := new(big.Int).SetBytes(b)
+	u.Mod(u, p)
+	return u.Cmp(big.NewInt(1)) <= 0 || u.Cmp(new(big.Int).Sub(p, big.NewInt(1))) == 0
+}
@@ -0,0 +1,106 @@
+package ecies
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure the X25519 points of small order are those crypto/ecdh refuses.
+func TestValidateX25519(t *testing.T) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, u := range x25519LowOrder {
+		le := u.FillBytes(make([]byte, x25519KeySize))
+		for i, j := 0, len(le)-1; i < j; i, j = i+1, j-1 {
+			le[i], le[j] = le[j], le[i]
+		}
+		// The top bit is ignored.
+		for _, top := range []byte{0, 0x80} {
+			data := append([]byte(nil), le...)
+			data[31] |= top
+			peer, err := ecdh.X25519().NewPublicKey(data)
+			if err != nil {
+				fmt.Println(err.Error())
+				t.FailNow()
+			}
+			if _, err = priv.ECDH(peer); err == nil {
+				fmt.Printf("ecies: %x isn't of small order\n", data)
+				t.FailNow()
+			}
+			pub := &PublicKey{X: new(big.Int).SetBytes(data), Y: new(big.Int), Curve: X25519()}
+			if err = ValidatePublicKey(pub); !errors.Is(err, ErrInvalidPublicKey) || !errors.Is(err, errSmallOrder) {
+				fmt.Printf("ecies: accepted the point of small order %x: %v\n", data, err)
+				t.FailNow()
+			}
+		}
+	}
+
+	prv, err := GenerateKey(rand.Reader, X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if err = ValidatePublicKey(&prv.PublicKey); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+}
+
+// Ensure invalid points are rejected, and never reach the key agreement.
+func TestValidatePublicKey(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), Secp256k1()} {
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if err = ValidatePublicKey(&prv.PublicKey); err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+
+		P := curve.Params().P
+		for _, tc := range []struct {
+			x, y  *big.Int
+			cause error
+		}{
+			{new(big.Int), new(big.Int), errPointAtInfinity},
+			{prv.X, new(big.Int).Add(prv.Y, big.NewInt(1)), errNotOnCurve},
+			{prv.X, new(big.Int).Add(prv.Y, P), errNotOnCurve},
+			{new(big.Int).Neg(prv.X), prv.Y, errNotOnCurve},
+		} {
+			pub := &PublicKey{X: tc.x, Y: tc.y, Curve: curve, Params: prv.Params}
+			if err = ValidatePublicKey(pub); !errors.Is(err, ErrInvalidPublicKey) || !errors.Is(err, tc.cause) {
+				fmt.Println("ecies: accepted an invalid point:", err)
+				t.FailNow()
+			}
+			if _, err = Encrypt(rand.Reader, pub, []byte("message"), nil, nil); !errors.Is(err, KindKey) {
+				fmt.Println("ecies: encrypted to an invalid point:", err)
+				t.FailNow()
+			}
+		}
+	}
+
+	if err := ValidatePublicKey(nil); err != ErrInvalidCurve {
+		fmt.Println("ecies: accepted a nil key:", err)
+		t.FailNow()
+	}
+}
+
+// offCurveKey returns a public key on curve whose point isn't on the curve.
+func offCurveKey(t *testing.T, curve elliptic.Curve) *PublicKey {
+	prv, err := GenerateKey(rand.Reader, curve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	return &PublicKey{X: prv.X, Y: new(big.Int).Add(prv.Y, big.NewInt(1)), Curve: curve}
+}
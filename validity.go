@@ -0,0 +1,73 @@
+package ecies
+
+// Validity windows, e.g. for time-limited tokens and firmware activation.
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+var (
+	ErrExpired         = newError(KindValidity, "ecies: message expired")
+	ErrNotYetValid     = newError(KindValidity, "ecies: message not yet valid")
+	ErrInvalidValidity = newError(KindParams, "ecies: invalid validity window")
+)
+
+// The validity header is prepended to the ciphertext: the not-before and
+// not-after times in Unix milliseconds, as 64-bit big-endian integers, 0
+// meaning unbounded. It is authenticated as the head of the MAC shared
+// information.
+const validityHeaderSize = 16
+
+type validityWindow struct {
+	header    []byte
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+func newValidity(notBefore, notAfter time.Time) (*validityWindow, error) {
+	if !notBefore.IsZero() && !notAfter.IsZero() && notAfter.Before(notBefore) {
+		return nil, ErrInvalidValidity
+	}
+	header := make([]byte, validityHeaderSize)
+	binary.BigEndian.PutUint64(header, unixMillis(notBefore))
+	binary.BigEndian.PutUint64(header[8:], unixMillis(notAfter))
+	return &validityWindow{header: header, notBefore: notBefore, notAfter: notAfter}, nil
+}
+
+// parseValidity splits the validity header off c.
+func parseValidity(c []byte) (*validityWindow, []byte, error) {
+	if len(c) < validityHeaderSize {
+		return nil, nil, ErrInvalidMessage
+	}
+	return &validityWindow{
+		header:    c[:validityHeaderSize],
+		notBefore: fromUnixMillis(binary.BigEndian.Uint64(c)),
+		notAfter:  fromUnixMillis(binary.BigEndian.Uint64(c[8:])),
+	}, c[validityHeaderSize:], nil
+}
+
+// check tells whether now is within an authenticated window.
+func (v *validityWindow) check(now time.Time) error {
+	if !v.notBefore.IsZero() && now.Before(v.notBefore) {
+		return ErrNotYetValid
+	}
+	if !v.notAfter.IsZero() && now.After(v.notAfter) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func unixMillis(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func fromUnixMillis(ms uint64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond)
+}
@@ -0,0 +1,76 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Ensure validity windows are enforced against the configured clock.
+func TestValidityWindow(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, DefaultCurve, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("activate firmware 2.1")
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	c, err := EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil,
+		&EncryptOptions{NotBefore: start, NotAfter: end})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	for _, tc := range []struct {
+		now time.Time
+		err error
+	}{
+		{start.Add(-time.Second), ErrNotYetValid},
+		{start, nil},
+		{start.Add(time.Hour), nil},
+		{end, nil},
+		{end.Add(time.Second), ErrExpired},
+	} {
+		now := tc.now
+		opts := &DecryptOptions{Validity: true, Clock: func() time.Time { return now }}
+		pt, err := DecryptWithOptions(prv, c, nil, nil, opts)
+		if err != tc.err || (err == nil && string(pt) != string(message)) {
+			fmt.Println("ecies: unexpected validity check result", now, err)
+			t.FailNow()
+		}
+	}
+
+	// Open ended windows, combined with a timestamp.
+	now := time.Now()
+	c, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil,
+		&EncryptOptions{NotAfter: now.Add(time.Minute), Timestamp: now})
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	opts := &DecryptOptions{Validity: true, Timestamped: true, MaxAge: time.Minute}
+	if _, err = DecryptWithOptions(prv, c, nil, nil, opts); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if _, err = DecryptWithOptions(prv, c, nil, nil, &DecryptOptions{Validity: true}); err == nil {
+		fmt.Println("ecies: message with an unexpected timestamp accepted")
+		t.FailNow()
+	}
+
+	// Extending the window breaks the tag.
+	c[15] ^= 1
+	if _, err = DecryptWithOptions(prv, c, nil, nil, opts); err != ErrInvalidMessage {
+		fmt.Println("ecies: tampered validity window accepted", err)
+		t.FailNow()
+	}
+
+	if _, err = EncryptWithOptions(rand.Reader, &prv.PublicKey, message, nil, nil,
+		&EncryptOptions{NotBefore: end, NotAfter: start}); err != ErrInvalidValidity {
+		fmt.Println("ecies: inverted validity window accepted", err)
+		t.FailNow()
+	}
+}
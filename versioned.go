@@ -0,0 +1,147 @@
+package ecies
+
+// The versioned ciphertext envelope: the "ECIES" magic, a version byte and
+// the one-byte identifiers of the curve and of the parameters, followed by
+// the raw ciphertext.
+
+import (
+	"bytes"
+	"crypto/elliptic"
+)
+
+var ErrUnknownFormat = newError(KindEncoding, "ecies: unknown or unsupported format")
+
+// The envelope header: magic, version, curve ID and suite ID.
+var envelopeMagic = []byte("ECIES")
+
+const (
+	envelopeVersion    = 1
+	envelopeHeaderSize = 8
+)
+
+// Compact one-byte identifiers for the standard parameter sets.
+var suiteIDs = []struct {
+	id     byte
+	name   string
+	params *ECIESParams
+}{
+	{1, "AES-128-CTR/HMAC-SHA-256", ECIES_AES128_SHA256},
+	{2, "AES-192-CTR/HMAC-SHA-384", ECIES_AES192_SHA384},
+	{3, "AES-256-CTR/HMAC-SHA-512", ECIES_AES256_SHA512},
+	{4, "AES-128-GCM/SHA-256", ECIES_AES128_GCM_SHA256},
+	{5, "AES-192-GCM/SHA-384", ECIES_AES192_GCM_SHA384},
+	{6, "AES-256-GCM/SHA-512", ECIES_AES256_GCM_SHA512},
+	{7, "ChaCha20-Poly1305/SHA-256", ECIES_CHACHA20POLY1305_SHA256},
+	{8, "ChaCha20-Poly1305/SHA-384", ECIES_CHACHA20POLY1305_SHA384},
+	{9, "ChaCha20-Poly1305/SHA-512", ECIES_CHACHA20POLY1305_SHA512},
+	{10, "XChaCha20-Poly1305/SHA-256", ECIES_XCHACHA20POLY1305_SHA256},
+	{11, "XChaCha20-Poly1305/SHA-384", ECIES_XCHACHA20POLY1305_SHA384},
+	{12, "XChaCha20-Poly1305/SHA-512", ECIES_XCHACHA20POLY1305_SHA512},
+	{13, "AES-128-CTR/HKDF-SHA-256", ECIES_AES128_HKDF_SHA256},
+	{14, "AES-192-CTR/HKDF-SHA-384", ECIES_AES192_HKDF_SHA384},
+	{15, "AES-256-CTR/HKDF-SHA-512", ECIES_AES256_HKDF_SHA512},
+	{16, "AES-128-CTR/X9.63-SHA-256", ECIES_AES128_X963_SHA256},
+	{17, "AES-192-CTR/X9.63-SHA-384", ECIES_AES192_X963_SHA384},
+	{18, "AES-256-CTR/X9.63-SHA-512", ECIES_AES256_X963_SHA512},
+	{19, "AES-128-CTR/SHA3-256", ECIES_AES128_SHA3_256},
+	{20, "AES-192-CTR/SHA3-384", ECIES_AES192_SHA3_384},
+	{21, "AES-256-CTR/SHA3-512", ECIES_AES256_SHA3_512},
+	{22, "AES-128-CTR/SHAKE128", ECIES_AES128_SHAKE128},
+	{23, "AES-256-CTR/SHAKE256", ECIES_AES256_SHAKE256},
+	{24, "AES-128-CTR/KMAC128/SHA3-256", ECIES_AES128_KMAC128_SHA3_256},
+	{25, "AES-256-CTR/KMAC256/SHA3-512", ECIES_AES256_KMAC256_SHA3_512},
+	{26, "AES-128-CTR/CMAC/SHA-256", ECIES_AES128_CMAC_SHA256},
+	{27, "AES-192-CTR/CMAC/SHA-384", ECIES_AES192_CMAC_SHA384},
+	{28, "AES-256-CTR/CMAC/SHA-512", ECIES_AES256_CMAC_SHA512},
+}
+
+func suiteID(params *ECIESParams) (byte, bool) {
+	if params.KDF != nil || params.EphemeralInKDF || params.MACLabelLength {
+		return 0, false
+	}
+	for _, s := range suiteIDs {
+		if params.hashAlgo == s.params.hashAlgo && params.KeyLen == s.params.KeyLen &&
+			params.BlockSize == s.params.BlockSize && params.dem == s.params.dem &&
+			params.kdf == s.params.kdf && params.mac == s.params.mac {
+			return s.id, true
+		}
+	}
+	return 0, false
+}
+
+func isEnvelope(in []byte) bool {
+	return len(in) > envelopeHeaderSize && bytes.HasPrefix(in, envelopeMagic)
+}
+
+// suiteName returns the name of the suite of params, if it has one.
+func suiteName(params *ECIESParams) (string, bool) {
+	id, ok := suiteID(params)
+	if !ok {
+		return "", false
+	}
+	for _, s := range suiteIDs {
+		if s.id == id {
+			return s.name, true
+		}
+	}
+	return "", false
+}
+
+// EncodeEnvelope wraps a raw ciphertext for pub into the versioned envelope,
+// recording the curve and parameters of the recipient key.
+func EncodeEnvelope(pub *PublicKey, c []byte) ([]byte, error) {
+	cid, ok := curveIDs[pub.Curve]
+	if !ok {
+		return nil, ErrInvalidCurve
+	}
+	params := pub.Params
+	if params == nil {
+		params = ParamsFromCurve(pub.Curve)
+	}
+	if params == nil {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	sid, ok := suiteID(params)
+	if !ok {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	out := make([]byte, 0, envelopeHeaderSize+len(c))
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeVersion, cid, sid)
+	return append(out, c...), nil
+}
+
+// EnvelopeParams returns the curve and parameters recorded in the header of
+// the envelope c, without decrypting it, e.g. to find the key it was
+// encrypted to.
+func EnvelopeParams(c []byte) (elliptic.Curve, *ECIESParams, error) {
+	if !isEnvelope(c) || c[len(envelopeMagic)] != envelopeVersion {
+		return nil, nil, ErrUnknownFormat
+	}
+	curve := curveFromID(c[len(envelopeMagic)+1])
+	if curve == nil {
+		return nil, nil, ErrInvalidCurve
+	}
+	for _, s := range suiteIDs {
+		if s.id == c[len(envelopeMagic)+2] {
+			return curve, s.params, nil
+		}
+	}
+	return nil, nil, ErrUnsupportedECIESParameters
+}
+
+// unwrapEnvelope strips the envelope header off c, checking that it matches
+// the curve and parameters of the recipient key. The header is public, so
+// there is no need to hide which check failed.
+func unwrapEnvelope(pub *PublicKey, params *ECIESParams, c []byte) ([]byte, error) {
+	if c[len(envelopeMagic)] != envelopeVersion {
+		return nil, ErrUnknownFormat
+	}
+	if cid, ok := curveIDs[pub.Curve]; !ok || c[len(envelopeMagic)+1] != cid {
+		return nil, ErrInvalidCurve
+	}
+	if sid, ok := suiteID(params); !ok || c[len(envelopeMagic)+2] != sid {
+		return nil, ErrUnsupportedECIESParameters
+	}
+	return c[envelopeHeaderSize:], nil
+}
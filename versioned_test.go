@@ -0,0 +1,48 @@
+package ecies
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure the curve and parameters of envelopes are read back from their
+// header, and that unknown identifiers are rejected.
+func TestEnvelopeParams(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, X448(), ECIES_CHACHA20POLY1305_SHA512)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	ct, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("Hello, world."), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	env, err := EncodeEnvelope(&prv.PublicKey, ct)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	curve, params, err := EnvelopeParams(env)
+	if err != nil || curve != X448() || params != ECIES_CHACHA20POLY1305_SHA512 {
+		fmt.Println("ecies: envelope parameters not read", err)
+		t.FailNow()
+	}
+	if _, _, err = EnvelopeParams(ct); err != ErrUnknownFormat {
+		fmt.Println("ecies: raw ciphertext read as an envelope", err)
+		t.FailNow()
+	}
+	for i, want := range map[int]error{
+		len(envelopeMagic):     ErrUnknownFormat,
+		len(envelopeMagic) + 1: ErrInvalidCurve,
+		len(envelopeMagic) + 2: ErrUnsupportedECIESParameters,
+	} {
+		bad := append([]byte(nil), env...)
+		bad[i] = 0xff
+		if _, _, err = EnvelopeParams(bad); err != want {
+			fmt.Println("ecies: bad envelope header read", i, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ecies
+
+// Zeroization of the secrets of an operation: the shared secret, the KDF
+// input and the derived keys are cleared before Encrypt and Decrypt return,
+// and, with SetLockedMemory, kept in memory locked into RAM in the meantime.
+
+import (
+	"sync/atomic"
+)
+
+var lockedMemory uint32 // atomic
+
+// SetLockedMemory selects whether the secrets of each encryption and
+// decryption from now on are kept in a LockedBuffer, so that they are never
+// swapped to disk. It costs a few system calls per message, and fails with
+// ErrLockedMemoryUnsupported on platforms without memory locking.
+func SetLockedMemory(on bool) error {
+	if on && !lockedMemorySupported {
+		return ErrLockedMemoryUnsupported
+	}
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&lockedMemory, v)
+	return nil
+}
+
+// Wipe clears the private scalar in place, leaving the key unusable. Copies
+// of the scalar, such as those made by ExportECDSA, aren't cleared.
+func (prv *PrivateKey) Wipe() {
+	if prv == nil || prv.D == nil {
+		return
+	}
+	clear(prv.D.Bits())
+	prv.D.SetInt64(0)
+}
+
+func wipe(b []byte) {
+	clear(b)
+}
+
+// lockedSecretsSize is the size of the locked buffer of an operation, which
+// holds a shared secret, its KDF input and the derived keys.
+const lockedSecretsSize = 4096
+
+// secrets tracks the secrets of an operation, to be wiped once done.
+type secrets struct {
+	bufs   [][]byte
+	locked *LockedBuffer
+	off    int
+}
+
+// newSecrets returns the tracker of the secrets of an operation, with a
+// locked buffer if SetLockedMemory is on.
+func newSecrets() (*secrets, error) {
+	s := new(secrets)
+	if atomic.LoadUint32(&lockedMemory) != 0 {
+		var err error
+		if s.locked, err = NewLockedBuffer(lockedSecretsSize); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// keep tracks b, and returns it, or a copy in the locked buffer once b is
+// wiped.
+func (s *secrets) keep(b []byte) []byte {
+	if s.locked != nil && len(b) <= lockedSecretsSize-s.off {
+		k := s.locked.Bytes()[s.off : s.off+len(b) : s.off+len(b)]
+		s.off += copy(k, b)
+		wipe(b)
+		return k
+	}
+	s.bufs = append(s.bufs, b)
+	return b
+}
+
+// wipe clears the tracked secrets.
+func (s *secrets) wipe() {
+	for _, b := range s.bufs {
+		wipe(b)
+	}
+	if s.locked != nil {
+		s.locked.Destroy()
+	}
+}
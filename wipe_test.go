@@ -0,0 +1,95 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+// Ensure that a wiped key can't be used anymore.
+func TestWipe(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), Secp256k1(), X25519()} {
+		name := curve.Params().Name
+		prv, err := GenerateKey(rand.Reader, curve, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		ct, err := Encrypt(rand.Reader, &prv.PublicKey, []byte("Hello, world."), nil, nil)
+		if err != nil {
+			fmt.Println(name, err.Error())
+			t.FailNow()
+		}
+		prv.Wipe()
+		if prv.D.Sign() != 0 {
+			fmt.Println(name, "ecies: private scalar not wiped")
+			t.FailNow()
+		}
+		if _, err = Decrypt(prv, ct, nil, nil); err == nil {
+			fmt.Println(name, "ecies: wiped key decrypted a message")
+			t.FailNow()
+		}
+	}
+}
+
+// Ensure that the tracked secrets are wiped, and moved to locked memory when
+// enabled.
+func TestSecrets(t *testing.T) {
+	sec, err := newSecrets()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	z := []byte{1, 2, 3}
+	if k := sec.keep(z); &k[0] != &z[0] {
+		fmt.Println("ecies: secret copied without locked memory")
+		t.FailNow()
+	}
+	sec.wipe()
+	if !bytes.Equal(z, make([]byte, 3)) {
+		fmt.Println("ecies: secret not wiped")
+		t.FailNow()
+	}
+
+	if err = SetLockedMemory(true); err == ErrLockedMemoryUnsupported {
+		return
+	}
+	defer SetLockedMemory(false)
+	if sec, err = newSecrets(); err != nil {
+		// Memory locking may be forbidden, e.g. by RLIMIT_MEMLOCK.
+		fmt.Println("ecies: skipping locked memory:", err)
+		return
+	}
+	z = []byte{1, 2, 3}
+	k := sec.keep(z)
+	if !bytes.Equal(k, []byte{1, 2, 3}) || !bytes.Equal(z, make([]byte, 3)) {
+		fmt.Println("ecies: secret not moved to locked memory")
+		t.FailNow()
+	}
+	sec.wipe()
+	if sec.locked.Bytes() != nil {
+		fmt.Println("ecies: locked memory not freed")
+		t.FailNow()
+	}
+
+	prv, err := GenerateKey(rand.Reader, elliptic.P256(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("Hello, world.")
+	for _, params := range []*ECIESParams{ECIES_AES128_SHA256, ECIES_AES128_GCM_SHA256, ECIES_AES128_CMAC_SHA256} {
+		prv.PublicKey.Params = params
+		ct, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+		if err != nil {
+			fmt.Println(err.Error())
+			t.FailNow()
+		}
+		if pt, err := Decrypt(prv, ct, nil, nil); err != nil || !bytes.Equal(pt, message) {
+			fmt.Println("ecies: message not decrypted with locked memory", err)
+			t.FailNow()
+		}
+	}
+}
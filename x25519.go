@@ -0,0 +1,134 @@
+package ecies
+
+// Curve25519 key agreement (RFC 7748), through crypto/ecdh.
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// x25519KeySize is the size of the X25519 private and public keys.
+const x25519KeySize = 32
+
+type x25519Curve struct {
+	params *elliptic.CurveParams
+}
+
+var (
+	x25519Once sync.Once
+	x25519     *x25519Curve
+)
+
+func initX25519() {
+	params := &elliptic.CurveParams{Name: "X25519", BitSize: 255}
+	params.P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	params.N, _ = new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+	params.B = big.NewInt(0)
+	// The base point u = 9, in the little-endian encoding.
+	params.Gx = new(big.Int).Lsh(big.NewInt(9), 248)
+	params.Gy = big.NewInt(0)
+	x25519 = &x25519Curve{params}
+}
+
+// X25519 returns the Curve25519 Montgomery curve for key agreement.
+//
+// It only implements the parts of the elliptic.Curve interface used by this
+// package. Its points have no Y coordinate: the X field of an X25519
+// PublicKey holds the 32-byte RFC 7748 encoding of the key, read as a
+// big-endian integer, and Y is 0. Likewise, D holds the 32-byte private key.
+// Ephemeral keys are encoded as the raw 32 bytes.
+func X25519() elliptic.Curve {
+	x25519Once.Do(initX25519)
+	return x25519
+}
+
+func (curve *x25519Curve) Params() *elliptic.CurveParams {
+	return curve.params
+}
+
+func (curve *x25519Curve) pointLen() int {
+	return x25519KeySize
+}
+
+// IsOnCurve accepts any 32-byte value, as X25519 is safe to use with points
+// on the twist. Low order points are rejected by the key agreement.
+func (curve *x25519Curve) IsOnCurve(x, y *big.Int) bool {
+	return x != nil && y != nil && x.Sign() >= 0 && x.BitLen() <= 8*x25519KeySize && y.Sign() == 0
+}
+
+// Add is not supported, and returns nil.
+func (curve *x25519Curve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	return nil, nil
+}
+
+// Double is not supported, and returns nil.
+func (curve *x25519Curve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	return nil, nil
+}
+
+// ScalarMult returns the X25519 function of the private key k and the public
+// key x. It returns nil if k is not a private key or if the result is zero,
+// e.g. for low order points.
+func (curve *x25519Curve) ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int) {
+	priv, err := x25519PrivateKey(k)
+	if err != nil || !curve.IsOnCurve(x1, y1) {
+		return nil, nil
+	}
+	pub, err := ecdh.X25519().NewPublicKey(x1.FillBytes(make([]byte, x25519KeySize)))
+	if err != nil {
+		return nil, nil
+	}
+	z, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, nil
+	}
+	return new(big.Int).SetBytes(z), new(big.Int)
+}
+
+// ScalarBaseMult returns the public key for the private key k.
+func (curve *x25519Curve) ScalarBaseMult(k []byte) (x, y *big.Int) {
+	priv, err := x25519PrivateKey(k)
+	if err != nil {
+		return nil, nil
+	}
+	return new(big.Int).SetBytes(priv.PublicKey().Bytes()), new(big.Int)
+}
+
+// x25519PrivateKey returns the private key for a big-endian integer, as
+// returned by big.Int.Bytes, which omits the leading zeros.
+func x25519PrivateKey(k []byte) (*ecdh.PrivateKey, error) {
+	if len(k) > x25519KeySize {
+		return nil, ErrInvalidPrivateKey
+	}
+	key := make([]byte, x25519KeySize)
+	defer wipe(key)
+	copy(key[x25519KeySize-len(k):], k)
+	return ecdh.X25519().NewPrivateKey(key)
+}
+
+func generateX25519(rand io.Reader, params *ECIESParams) (*PrivateKey, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	prv := newX25519PrivateKey(priv)
+	if params != nil {
+		prv.PublicKey.Params = params
+	}
+	return prv, nil
+}
+
+func newX25519PrivateKey(priv *ecdh.PrivateKey) *PrivateKey {
+	prv := new(PrivateKey)
+	prv.PublicKey.X = new(big.Int).SetBytes(priv.PublicKey().Bytes())
+	prv.PublicKey.Y = new(big.Int)
+	prv.PublicKey.Curve = X25519()
+	prv.PublicKey.Params = ParamsFromCurve(X25519())
+	d := priv.Bytes()
+	prv.D = new(big.Int).SetBytes(d)
+	wipe(d)
+	return prv
+}
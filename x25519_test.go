@@ -0,0 +1,102 @@
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure the key agreement matches RFC 7748 section 6.1.
+func TestX25519Vector(t *testing.T) {
+	hexKey := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	alice, err := NewPrivateKey(X25519(), hexKey("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	bob, err := NewPublicKeyFromBytes(X25519(), hexKey("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Equal(CompressPublicKey(&alice.PublicKey), hexKey("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")) {
+		fmt.Println("ecies: unexpected X25519 public key")
+		t.FailNow()
+	}
+	z, err := alice.GenerateShared(bob)
+	if err != nil || !bytes.Equal(z, hexKey("4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")) {
+		fmt.Println("ecies: unexpected X25519 shared secret", err)
+		t.FailNow()
+	}
+
+	if _, err = NewPublicKeyFromBytes(X25519(), make([]byte, 32)); !errors.Is(err, ErrInvalidPublicKey) {
+		fmt.Println("ecies: low order X25519 point accepted", err)
+		t.FailNow()
+	}
+	lowOrder := &PublicKey{X: new(big.Int), Y: new(big.Int), Curve: X25519()}
+	if _, err = alice.GenerateShared(lowOrder); err != ErrSharedKeyIsPointAtInfinity {
+		fmt.Println("ecies: low order X25519 point accepted", err)
+		t.FailNow()
+	}
+}
+
+// Ensure messages round trip to X25519 keys, with 32-byte ephemeral keys.
+func TestX25519Encrypt(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, X25519(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	message := []byte("hello, curve25519")
+	c, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if len(c) != 32+16+len(message)+32 {
+		fmt.Println("ecies: unexpected X25519 ciphertext size", len(c))
+		t.FailNow()
+	}
+	pt, err := Decrypt(prv, c, nil, nil)
+	if err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: X25519 message not decrypted", err)
+		t.FailNow()
+	}
+	env, err := EncodeEnvelope(&prv.PublicKey, c)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pt, err = Decrypt(prv, env, nil, nil); err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: X25519 envelope not decrypted", err)
+		t.FailNow()
+	}
+
+	s, err := EncodePublicBech32(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	pub, err := DecodePublicBech32(s)
+	if err != nil || pub.Curve != X25519() || pub.X.Cmp(prv.X) != 0 {
+		fmt.Println("ecies: X25519 public key not decoded", err)
+		t.FailNow()
+	}
+	backup, err := ExportPrivateBackup(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	restored, err := ImportPrivateBackup(backup)
+	if err != nil || restored.D.Cmp(prv.D) != 0 || restored.X.Cmp(prv.X) != 0 {
+		fmt.Println("ecies: X25519 backup not restored", err)
+		t.FailNow()
+	}
+}
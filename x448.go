@@ -0,0 +1,132 @@
+package ecies
+
+// Curve448 key agreement (RFC 7748), which isn't provided by the Go standard
+// library: the constant-time implementation of circl does the computations.
+
+import (
+	"crypto/elliptic"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/cloudflare/circl/dh/x448"
+)
+
+// x448KeySize is the size of the X448 private and public keys.
+const x448KeySize = 56
+
+type x448Curve struct {
+	params *elliptic.CurveParams
+}
+
+var (
+	x448Once  sync.Once
+	x448Value *x448Curve
+)
+
+func initX448() {
+	params := &elliptic.CurveParams{Name: "X448", BitSize: 448}
+	params.P = new(big.Int).Lsh(big.NewInt(1), 448)
+	params.P.Sub(params.P, new(big.Int).Lsh(big.NewInt(1), 224))
+	params.P.Sub(params.P, big.NewInt(1))
+	params.N, _ = new(big.Int).SetString("3fffffffffffffffffffffffffffffffffffffffffffffffffffffff7cca23e9c44edb49aed63690216cc2728dc58f552378c292ab5844f3", 16)
+	params.B = big.NewInt(0)
+	// The base point u = 5, in the little-endian encoding.
+	params.Gx = new(big.Int).Lsh(big.NewInt(5), 8*(x448KeySize-1))
+	params.Gy = big.NewInt(0)
+	x448Value = &x448Curve{params}
+}
+
+// X448 returns the Curve448 Montgomery curve for key agreement, for the
+// security tiers above X25519 where P-521 is too slow.
+//
+// Like X25519, it only implements the parts of the elliptic.Curve interface
+// used by this package, and its keys are the 56-byte RFC 7748 encodings read
+// as big-endian integers, with Y set to 0.
+func X448() elliptic.Curve {
+	x448Once.Do(initX448)
+	return x448Value
+}
+
+func (curve *x448Curve) Params() *elliptic.CurveParams {
+	return curve.params
+}
+
+func (curve *x448Curve) pointLen() int {
+	return x448KeySize
+}
+
+// IsOnCurve accepts any 56-byte value, as X448 is safe to use with points on
+// the twist. Low order points are rejected by the key agreement.
+func (curve *x448Curve) IsOnCurve(x, y *big.Int) bool {
+	return x != nil && y != nil && x.Sign() >= 0 && x.BitLen() <= 8*x448KeySize && y.Sign() == 0
+}
+
+// Add is not supported, and returns nil.
+func (curve *x448Curve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	return nil, nil
+}
+
+// Double is not supported, and returns nil.
+func (curve *x448Curve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	return nil, nil
+}
+
+// ScalarMult returns the X448 function of the private key k and the public
+// key x. It returns nil if k is not a private key or if the result is zero,
+// e.g. for low order points.
+func (curve *x448Curve) ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int) {
+	if len(k) > x448KeySize || !curve.IsOnCurve(x1, y1) {
+		return nil, nil
+	}
+	u := x1.FillBytes(make([]byte, x448KeySize))
+	z := curve.x448(k, u)
+	if z == nil {
+		return nil, nil
+	}
+	return new(big.Int).SetBytes(z), new(big.Int)
+}
+
+// ScalarBaseMult returns the public key for the private key k.
+func (curve *x448Curve) ScalarBaseMult(k []byte) (x, y *big.Int) {
+	return curve.ScalarMult(curve.params.Gx, curve.params.Gy, k)
+}
+
+// x448 returns the X448 function of RFC 7748 section 5, for the private key
+// k, read as a big-endian integer as returned by big.Int.Bytes, which omits
+// the leading zeros, and the encoded u-coordinate. It returns nil for the
+// all-zero output of low order points.
+func (curve *x448Curve) x448(k, u []byte) []byte {
+	var scalar, point, shared x448.Key
+	defer wipe(scalar[:])
+	copy(scalar[x448KeySize-len(k):], k)
+	copy(point[:], u)
+	if !x448.Shared(&shared, &scalar, &point) {
+		return nil
+	}
+	return shared[:]
+}
+
+func generateX448(rand io.Reader, params *ECIESParams) (*PrivateKey, error) {
+	d := make([]byte, x448KeySize)
+	defer wipe(d)
+	if _, err := io.ReadFull(rand, d); err != nil {
+		return nil, err
+	}
+	prv := newX448PrivateKey(d)
+	if params != nil {
+		prv.PublicKey.Params = params
+	}
+	return prv, nil
+}
+
+// newX448PrivateKey returns the private key for the 56-byte key d. Any value
+// is a valid X448 private key.
+func newX448PrivateKey(d []byte) *PrivateKey {
+	prv := new(PrivateKey)
+	prv.PublicKey.X, prv.PublicKey.Y = X448().ScalarBaseMult(d)
+	prv.PublicKey.Curve = X448()
+	prv.PublicKey.Params = ParamsFromCurve(X448())
+	prv.D = new(big.Int).SetBytes(d)
+	return prv
+}
@@ -0,0 +1,130 @@
+//go:build !ecies_tiny
+// +build !ecies_tiny
+
+package ecies
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Ensure the X448 function and key agreement match RFC 7748 sections 5.2
+// and 6.2.
+func TestX448Vector(t *testing.T) {
+	hexKey := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	k := hexKey("3d262fddf9ec8e88495266fea19a34d28882acef045104d0d1aae121700a779c984c24f8cdd78fbff44943eba368f54b29259a4f1c600ad3")
+	u := hexKey("06fce640fa3487bfda5f6cf2d5263f8aad88334cbd07437f020f08f9814dc031ddbdc38c19c6da2583fa5429db94ada18aa7a7fb4ef8a086")
+	x, _ := X448().ScalarMult(new(big.Int).SetBytes(u), new(big.Int), k)
+	if x == nil || !bytes.Equal(x.FillBytes(make([]byte, x448KeySize)), hexKey("ce3e4ff95a60dc6697da1db1d85e6afbdf79b50a2412d7546d5f239fe14fbaadeb445fc66a01b0779d98223961111e21766282f73dd96b6f")) {
+		fmt.Println("ecies: unexpected X448 output")
+		t.FailNow()
+	}
+
+	alice, err := NewPrivateKey(X448(), hexKey("9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28dd9c9baf574a9419744897391006382a6f127ab1d9ac2d8c0a598726b"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	bob, err := NewPublicKeyFromBytes(X448(), hexKey("3eb7a829b0cd20f5bcfc0b599b6feccf6da4627107bdb0d4f345b43027d8b972fc3e34fb4232a13ca706dcb57aec3dae07bdc1c67bf33609"))
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if !bytes.Equal(CompressPublicKey(&alice.PublicKey), hexKey("9b08f7cc31b7e3e67d22d5aea121074a273bd2b83de09c63faa73d2c22c5d9bbc836647241d953d40c5b12da88120d53177f80e532c41fa0")) {
+		fmt.Println("ecies: unexpected X448 public key")
+		t.FailNow()
+	}
+	z, err := alice.GenerateShared(bob)
+	if err != nil || !bytes.Equal(z, hexKey("07fff4181ac6cc95ec1c16a94a0f74d12da232ce40a77552281d282bb60c0b56fd2464c335543936521c24403085d59a449a5037514a879d")) {
+		fmt.Println("ecies: unexpected X448 shared secret", err)
+		t.FailNow()
+	}
+
+	one := make([]byte, x448KeySize)
+	one[0] = 1
+	for _, low := range [][]byte{make([]byte, x448KeySize), one} {
+		if _, err = NewPublicKeyFromBytes(X448(), low); !errors.Is(err, ErrInvalidPublicKey) {
+			fmt.Println("ecies: low order X448 point accepted", err)
+			t.FailNow()
+		}
+	}
+	lowOrder := &PublicKey{X: new(big.Int), Y: new(big.Int), Curve: X448()}
+	if _, err = alice.GenerateShared(lowOrder); err != ErrSharedKeyIsPointAtInfinity {
+		fmt.Println("ecies: low order X448 point accepted", err)
+		t.FailNow()
+	}
+}
+
+// Ensure messages round trip to X448 keys with the default parameters, and
+// that the keys survive the standard encodings.
+func TestX448Encrypt(t *testing.T) {
+	prv, err := GenerateKey(rand.Reader, X448(), nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if prv.Params != ECIES_AES256_SHA512 {
+		fmt.Println("ecies: unexpected X448 parameters")
+		t.FailNow()
+	}
+	message := []byte("hello, curve448")
+	c, err := Encrypt(rand.Reader, &prv.PublicKey, message, nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if len(c) != 56+16+len(message)+64 {
+		fmt.Println("ecies: unexpected X448 ciphertext size", len(c))
+		t.FailNow()
+	}
+	pt, err := Decrypt(prv, c, nil, nil)
+	if err != nil || !bytes.Equal(pt, message) {
+		fmt.Println("ecies: X448 message not decrypted", err)
+		t.FailNow()
+	}
+
+	der, err := MarshalPrivatePKCS8(prv)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if prv2, err := UnmarshalPrivatePKCS8(der); err != nil || !prv2.Equal(prv) {
+		fmt.Println("ecies: X448 PKCS #8 key not decoded", err)
+		t.FailNow()
+	}
+	if der, err = MarshalPublicPKIX(&prv.PublicKey); err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pub, err := UnmarshalPublicPKIX(der); err != nil || !pub.Equal(&prv.PublicKey) {
+		fmt.Println("ecies: X448 PKIX key not decoded", err)
+		t.FailNow()
+	}
+	jwk, err := MarshalPublicJWK(&prv.PublicKey)
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	if pub, err := UnmarshalPublicJWK(jwk); err != nil || !pub.Equal(&prv.PublicKey) {
+		fmt.Println("ecies: X448 JWK not decoded", err)
+		t.FailNow()
+	}
+	text, err := prv.MarshalText()
+	if err != nil {
+		fmt.Println(err.Error())
+		t.FailNow()
+	}
+	var prv2 PrivateKey
+	if err = prv2.UnmarshalText(text); err != nil || !prv2.Equal(prv) {
+		fmt.Println("ecies: X448 PEM key not decoded", err)
+		t.FailNow()
+	}
+}